@@ -0,0 +1,219 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"math"
+	"sort"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	runLabel       = "compliance_run"
+	requestsMetric = "compliance_requests_total"
+	valuesMetric   = "compliance_special_values"
+
+	// samplesPerSeries samples are written for every series, stepMs apart.
+	samplesPerSeries = 60
+	stepMs           = 15000
+
+	specialCharacters = "/a b\"c\\d ünï\n"
+)
+
+// dataset is the set of series written before running the cases. All its
+// series carry the run label, so that runs against the same database do not
+// see each other's samples.
+type dataset struct {
+	run    string
+	start  int64
+	series []prompb.TimeSeries
+}
+
+func newDataset(run string, start int64) *dataset {
+	d := &dataset{run: run, start: start}
+	labelSets := []struct {
+		metric string
+		labels []string
+	}{
+		{requestsMetric, []string{"job", "api", "instance", "a"}},
+		{requestsMetric, []string{"job", "api", "instance", "b"}},
+		{requestsMetric, []string{"job", "db", "instance", "a"}},
+		{requestsMetric, []string{"job", "db"}},
+		{requestsMetric, []string{"job", "api", "path", specialCharacters}},
+		{valuesMetric, []string{"job", "api"}},
+	}
+	specialValues := []float64{math.NaN(), math.Inf(1), math.Inf(-1), math.SmallestNonzeroFloat64, -0.5, math.MaxFloat64}
+
+	for i, set := range labelSets {
+		ts := prompb.TimeSeries{
+			Labels: []prompb.Label{
+				{Name: labels.MetricName, Value: set.metric},
+				{Name: runLabel, Value: run},
+			},
+		}
+		for j := 0; j < len(set.labels); j += 2 {
+			ts.Labels = append(ts.Labels, prompb.Label{Name: set.labels[j], Value: set.labels[j+1]})
+		}
+		for j := 0; j < samplesPerSeries; j++ {
+			value := float64(i*1000 + j)
+			if set.metric == valuesMetric {
+				value = specialValues[j%len(specialValues)]
+			}
+			ts.Samples = append(ts.Samples, prompb.Sample{Timestamp: start + int64(j)*stepMs, Value: value})
+		}
+		d.series = append(d.series, ts)
+	}
+	return d
+}
+
+// query is a remote read query relative to the dataset: its matchers are
+// combined with the run label, and its time range is given as offsets from
+// the first sample.
+type query struct {
+	matchers    []*prompb.LabelMatcher
+	startOffset int64
+	endOffset   int64
+}
+
+// wholeRange covers every sample of the dataset.
+var wholeRange = [2]int64{0, (samplesPerSeries - 1) * stepMs}
+
+func newQuery(timeRange [2]int64, matchers ...*prompb.LabelMatcher) query {
+	return query{matchers: matchers, startOffset: timeRange[0], endOffset: timeRange[1]}
+}
+
+func matcher(t prompb.LabelMatcher_Type, name, value string) *prompb.LabelMatcher {
+	return &prompb.LabelMatcher{Type: t, Name: name, Value: value}
+}
+
+// testCase is a single entry of the corpus. All the queries of a case are
+// sent in a single read request.
+type testCase struct {
+	name    string
+	feature string
+	queries []query
+}
+
+// corpus lists the cases, following the semantics of the Prometheus remote
+// read API and of its label matchers.
+func corpus() []testCase {
+	eq, neq, re, nre := prompb.LabelMatcher_EQ, prompb.LabelMatcher_NEQ, prompb.LabelMatcher_RE, prompb.LabelMatcher_NRE
+	requests := matcher(eq, labels.MetricName, requestsMetric)
+	middle := [2]int64{10 * stepMs, 20 * stepMs}
+
+	return []testCase{
+		{"metric name", "equality matchers", []query{newQuery(wholeRange, requests)}},
+		{"label value", "equality matchers", []query{newQuery(wholeRange, requests, matcher(eq, "job", "db"))}},
+		{"no matching series", "equality matchers", []query{newQuery(wholeRange, requests, matcher(eq, "job", "missing"))}},
+		{"label value", "inequality matchers", []query{newQuery(wholeRange, requests, matcher(neq, "job", "api"))}},
+		{"absent label", "inequality matchers", []query{newQuery(wholeRange, requests, matcher(neq, "instance", "a"))}},
+		{"alternation", "regex matchers", []query{newQuery(wholeRange, requests, matcher(re, "instance", "a|b"))}},
+		{"anchored", "regex matchers", []query{newQuery(wholeRange, requests, matcher(re, "job", "ap"))}},
+		{"metric name", "regex matchers", []query{newQuery(wholeRange, matcher(re, labels.MetricName, "compliance_.*"))}},
+		{"label value", "negative regex matchers", []query{newQuery(wholeRange, requests, matcher(nre, "job", "a.*"))}},
+		{"anchored", "negative regex matchers", []query{newQuery(wholeRange, requests, matcher(nre, "job", "d|api"))}},
+		{"equal to empty", "empty label matchers", []query{newQuery(wholeRange, requests, matcher(eq, "instance", ""))}},
+		{"not equal to empty", "empty label matchers", []query{newQuery(wholeRange, requests, matcher(neq, "instance", ""))}},
+		{"regex matching empty", "empty label matchers", []query{newQuery(wholeRange, requests, matcher(re, "instance", "a|"))}},
+		{"regex not matching empty", "empty label matchers", []query{newQuery(wholeRange, requests, matcher(re, "instance", ".+"))}},
+		{"special characters", "label values", []query{newQuery(wholeRange, requests, matcher(eq, "path", specialCharacters))}},
+		{"inclusive bounds", "time ranges", []query{newQuery(middle, requests, matcher(eq, "job", "db"))}},
+		{"before the samples", "time ranges", []query{newQuery([2]int64{-10 * stepMs, -stepMs}, requests)}},
+		{"between two samples", "time ranges", []query{newQuery([2]int64{stepMs / 3, 2 * stepMs / 3}, requests)}},
+		{"special float values", "sample values", []query{newQuery(wholeRange, matcher(eq, labels.MetricName, valuesMetric))}},
+		{"two queries", "multiple queries", []query{
+			newQuery(middle, requests, matcher(eq, "job", "db")),
+			newQuery(wholeRange, matcher(eq, labels.MetricName, valuesMetric)),
+		}},
+	}
+}
+
+// readRequest builds the read request of c against the dataset.
+func (d *dataset) readRequest(c testCase) *prompb.ReadRequest {
+	req := &prompb.ReadRequest{}
+	for _, q := range c.queries {
+		matchers := append([]*prompb.LabelMatcher{matcher(prompb.LabelMatcher_EQ, runLabel, d.run)}, q.matchers...)
+		req.Queries = append(req.Queries, &prompb.Query{
+			StartTimestampMs: d.start + q.startOffset,
+			EndTimestampMs:   d.start + q.endOffset,
+			Matchers:         matchers,
+		})
+	}
+	return req
+}
+
+// expected computes the response Prometheus gives to req over the dataset.
+func (d *dataset) expected(req *prompb.ReadRequest) (*prompb.ReadResponse, error) {
+	resp := &prompb.ReadResponse{}
+	for _, q := range req.Queries {
+		matchers := make([]*labels.Matcher, 0, len(q.Matchers))
+		for _, m := range q.Matchers {
+			lm, err := labels.NewMatcher(labels.MatchType(m.Type), m.Name, m.Value)
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, lm)
+		}
+
+		result := &prompb.QueryResult{}
+		for _, ts := range d.series {
+			if !matchesAll(matchers, ts.Labels) {
+				continue
+			}
+			var samples []prompb.Sample
+			for _, s := range ts.Samples {
+				if s.Timestamp >= q.StartTimestampMs && s.Timestamp <= q.EndTimestampMs {
+					samples = append(samples, s)
+				}
+			}
+			if len(samples) > 0 {
+				result.Timeseries = append(result.Timeseries, &prompb.TimeSeries{Labels: ts.Labels, Samples: samples})
+			}
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return resp, nil
+}
+
+// matchesAll reports whether the label set matches every matcher. A label
+// missing from the set matches as an empty value.
+func matchesAll(matchers []*labels.Matcher, set []prompb.Label) bool {
+	for _, m := range matchers {
+		value := ""
+		for _, l := range set {
+			if l.Name == m.Name {
+				value = l.Value
+				break
+			}
+		}
+		if !m.Matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortLabels sorts the labels of every series of the response, and the
+// series by their labels, since neither order is part of the API.
+func sortLabels(resp *prompb.ReadResponse) {
+	for _, result := range resp.Results {
+		for _, ts := range result.Timeseries {
+			sort.Slice(ts.Labels, func(i, j int) bool { return ts.Labels[i].Name < ts.Labels[j].Name })
+		}
+		sort.Slice(result.Timeseries, func(i, j int) bool {
+			return labelsString(result.Timeseries[i].Labels) < labelsString(result.Timeseries[j].Labels)
+		})
+	}
+}
+
+func labelsString(set []prompb.Label) string {
+	ls := make(labels.Labels, 0, len(set))
+	for _, l := range set {
+		ls = append(ls, labels.Label{Name: l.Name, Value: l.Value})
+	}
+	return ls.String()
+}
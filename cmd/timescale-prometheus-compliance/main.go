@@ -0,0 +1,289 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+// timescale-prometheus-compliance checks a live connector against a corpus of
+// remote read cases following the semantics of Prometheus: it writes a known
+// dataset, reads it back with every case of the corpus, and reports which
+// features behave as in Prometheus.
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/jamiealquiza/envy"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+type config struct {
+	url             string
+	bearerTokenFile string
+	runID           string
+	timeout         time.Duration
+	output          string
+}
+
+// caseResult is the outcome of a single case of the corpus.
+type caseResult struct {
+	Name    string `json:"name"`
+	Feature string `json:"feature"`
+	Passed  bool   `json:"passed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// report is the outcome of a whole run.
+type report struct {
+	RunID  string       `json:"run_id"`
+	Passed int          `json:"passed"`
+	Total  int          `json:"total"`
+	Cases  []caseResult `json:"cases"`
+}
+
+func main() {
+	cfg := parseFlags()
+	if cfg.output != outputText && cfg.output != outputJSON {
+		fmt.Fprintf(os.Stderr, "-output must be %q or %q\n", outputText, outputJSON)
+		os.Exit(2)
+	}
+
+	token := ""
+	if cfg.bearerTokenFile != "" {
+		data, err := ioutil.ReadFile(cfg.bearerTokenFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Cannot read the bearer token:", err)
+			os.Exit(1)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	c := &client{http: &http.Client{Timeout: cfg.timeout}, url: strings.TrimSuffix(cfg.url, "/"), token: token}
+	start := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	rep, err := run(c, newDataset(cfg.runID, start.UnixNano()/int64(time.Millisecond)))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot run the compliance tests:", err)
+		os.Exit(1)
+	}
+	if err = writeReport(os.Stdout, rep, cfg.output); err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot write the report:", err)
+		os.Exit(1)
+	}
+	if rep.Passed != rep.Total {
+		os.Exit(1)
+	}
+}
+
+func parseFlags() *config {
+	cfg := &config{}
+
+	flag.StringVar(&cfg.url, "url", "http://localhost:9201", "Base URL of the connector under test. Its /write and /read endpoints are used.")
+	flag.StringVar(&cfg.bearerTokenFile, "bearer-token-file", "", "File holding the bearer token sent with the requests, for connectors with -auth-bearer-tokens-file.")
+	flag.StringVar(&cfg.runID, "run-id", fmt.Sprintf("%d", time.Now().Unix()), "Value of the compliance_run label of the written series, which isolates them from those of other runs.")
+	flag.DurationVar(&cfg.timeout, "timeout", 30*time.Second, "Timeout of a single request.")
+	flag.StringVar(&cfg.output, "output", outputText, "Format of the report, text or json.")
+	envy.Parse("TS_PROM_COMPLIANCE")
+	flag.Parse()
+
+	return cfg
+}
+
+// client sends remote write and read requests to a connector.
+type client struct {
+	http  *http.Client
+	url   string
+	token string
+}
+
+func (c *client) post(path string, msg proto.Marshaler) ([]byte, error) {
+	data, err := msg.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", c.url+path, bytes.NewReader(snappy.Encode(nil, data)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func (c *client) write(req *prompb.WriteRequest) error {
+	_, err := c.post("/write", req)
+	return err
+}
+
+func (c *client) read(req *prompb.ReadRequest) (*prompb.ReadResponse, error) {
+	compressed, err := c.post("/read", req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, err
+	}
+	var resp prompb.ReadResponse
+	if err = proto.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// run writes the dataset and checks every case of the corpus against it.
+// Only a failed write aborts the run, failed reads fail their case.
+func run(c *client, d *dataset) (*report, error) {
+	if err := c.write(&prompb.WriteRequest{Timeseries: d.series}); err != nil {
+		return nil, fmt.Errorf("writing the dataset: %w", err)
+	}
+
+	rep := &report{RunID: d.run}
+	for _, tc := range corpus() {
+		result := caseResult{Name: tc.name, Feature: tc.feature}
+		if err := check(c, d, tc); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Passed = true
+			rep.Passed++
+		}
+		rep.Total++
+		rep.Cases = append(rep.Cases, result)
+	}
+	return rep, nil
+}
+
+func check(c *client, d *dataset, tc testCase) error {
+	req := d.readRequest(tc)
+	expected, err := d.expected(req)
+	if err != nil {
+		return err
+	}
+	got, err := c.read(req)
+	if err != nil {
+		return err
+	}
+	return compare(expected, got)
+}
+
+// compare returns an error describing the first difference between the
+// expected and the actual response.
+func compare(expected, got *prompb.ReadResponse) error {
+	sortLabels(expected)
+	sortLabels(got)
+	if len(got.Results) != len(expected.Results) {
+		return fmt.Errorf("got %d query results, expected %d", len(got.Results), len(expected.Results))
+	}
+	for i, exp := range expected.Results {
+		series := got.Results[i].Timeseries
+		if len(series) != len(exp.Timeseries) {
+			return fmt.Errorf("query %d: got %d series, expected %d", i, len(series), len(exp.Timeseries))
+		}
+		for j, ts := range exp.Timeseries {
+			name := labelsString(ts.Labels)
+			if gotName := labelsString(series[j].Labels); gotName != name {
+				return fmt.Errorf("query %d: got series %s, expected %s", i, gotName, name)
+			}
+			if err := compareSamples(ts.Samples, series[j].Samples); err != nil {
+				return fmt.Errorf("query %d: series %s: %w", i, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func compareSamples(expected, got []prompb.Sample) error {
+	if len(got) != len(expected) {
+		return fmt.Errorf("got %d samples, expected %d", len(got), len(expected))
+	}
+	for i, s := range expected {
+		g := got[i]
+		sameValue := g.Value == s.Value || (math.IsNaN(g.Value) && math.IsNaN(s.Value))
+		if g.Timestamp != s.Timestamp || !sameValue {
+			return fmt.Errorf("got sample %v@%d, expected %v@%d", g.Value, g.Timestamp, s.Value, s.Timestamp)
+		}
+	}
+	return nil
+}
+
+// writeReport writes a summary of every feature followed by the failed
+// cases, or the whole report as JSON.
+func writeReport(out io.Writer, rep *report, format string) error {
+	if format == outputJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+
+	type featureStats struct{ passed, total int }
+	features := make(map[string]*featureStats)
+	names := make([]string, 0)
+	for _, r := range rep.Cases {
+		stats, ok := features[r.Feature]
+		if !ok {
+			stats = &featureStats{}
+			features[r.Feature] = stats
+			names = append(names, r.Feature)
+		}
+		stats.total++
+		if r.Passed {
+			stats.passed++
+		}
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FEATURE\tPASSED\tSTATUS")
+	for _, name := range names {
+		stats := features[name]
+		status := "supported"
+		if stats.passed != stats.total {
+			status = "not supported"
+		}
+		fmt.Fprintf(tw, "%s\t%d/%d\t%s\n", name, stats.passed, stats.total, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, r := range rep.Cases {
+		if !r.Passed {
+			fmt.Fprintf(out, "FAILED %s / %s: %s\n", r.Feature, r.Name, r.Error)
+		}
+	}
+	_, err := fmt.Fprintf(out, "%d/%d cases passed\n", rep.Passed, rep.Total)
+	return err
+}
@@ -0,0 +1,145 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// fakeConnector stores the written series in memory and answers reads with
+// them, after letting filter drop the matchers it does not support.
+type fakeConnector struct {
+	t       *testing.T
+	written *dataset
+	filter  func(m *prompb.LabelMatcher) bool
+}
+
+func (f *fakeConnector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	compressed, _ := ioutil.ReadAll(r.Body)
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+
+	switch r.URL.Path {
+	case "/write":
+		var req prompb.WriteRequest
+		if err = proto.Unmarshal(data, &req); err != nil {
+			f.t.Fatal(err)
+		}
+		f.written = &dataset{series: req.Timeseries}
+	case "/read":
+		var req prompb.ReadRequest
+		if err = proto.Unmarshal(data, &req); err != nil {
+			f.t.Fatal(err)
+		}
+		for _, q := range req.Queries {
+			matchers := q.Matchers[:0]
+			for _, m := range q.Matchers {
+				if f.filter == nil || f.filter(m) {
+					matchers = append(matchers, m)
+				}
+			}
+			q.Matchers = matchers
+		}
+		resp, err := f.written.expected(&req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ = proto.Marshal(resp)
+		_, _ = w.Write(snappy.Encode(nil, data))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func runAgainst(t *testing.T, f *fakeConnector) *report {
+	server := httptest.NewServer(f)
+	defer server.Close()
+	c := &client{http: server.Client(), url: server.URL, token: "token"}
+	rep, err := run(c, newDataset("test", 1000000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rep
+}
+
+func TestRunCompliant(t *testing.T) {
+	rep := runAgainst(t, &fakeConnector{t: t})
+	if rep.Total != len(corpus()) || rep.Passed != rep.Total {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+
+	var out bytes.Buffer
+	if err := writeReport(&out, rep, outputText); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "regex matchers") || strings.Contains(out.String(), "FAILED") {
+		t.Errorf("unexpected text report:\n%s", out.String())
+	}
+}
+
+func TestRunNonCompliant(t *testing.T) {
+	rep := runAgainst(t, &fakeConnector{t: t, filter: func(m *prompb.LabelMatcher) bool {
+		return m.Type != prompb.LabelMatcher_NRE
+	}})
+	for _, r := range rep.Cases {
+		if r.Passed == (r.Feature == "negative regex matchers") {
+			t.Errorf("unexpected result of %s / %s: %v", r.Feature, r.Name, r.Error)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writeReport(&out, rep, outputJSON); err != nil {
+		t.Fatal(err)
+	}
+	var decoded report
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Passed != rep.Total-2 || decoded.Total != rep.Total {
+		t.Errorf("unexpected JSON report: %s", out.String())
+	}
+}
+
+func TestRunWriteFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database down", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	c := &client{http: server.Client(), url: server.URL}
+	if _, err := run(c, newDataset("test", 0)); err == nil || !strings.Contains(err.Error(), "database down") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCompareSamples(t *testing.T) {
+	nan := []prompb.Sample{{Timestamp: 1, Value: math.NaN()}}
+	if err := compareSamples(nan, []prompb.Sample{{Timestamp: 1, Value: math.NaN()}}); err != nil {
+		t.Errorf("NaN samples differ: %v", err)
+	}
+	if err := compareSamples(nan, []prompb.Sample{{Timestamp: 1, Value: 0}}); err == nil {
+		t.Error("NaN and 0 are equal")
+	}
+	if err := compareSamples(nan, nil); err == nil {
+		t.Error("missing sample not reported")
+	}
+}
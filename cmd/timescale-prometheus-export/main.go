@@ -0,0 +1,247 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+// timescale-prometheus-export dumps the series matching a set of selectors
+// over a time range, as Prometheus TSDB blocks or as OpenMetrics text, to
+// back them up or to move them off TimescaleDB.
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jamiealquiza/envy"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/tsdb"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgclient"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+	"github.com/timescale/timescale-prometheus/pkg/util"
+)
+
+const (
+	formatOpenMetrics = "openmetrics"
+	formatTSDB        = "tsdb"
+)
+
+type config struct {
+	pgmodelCfg    pgclient.Config
+	selectors     stringList
+	start         string
+	end           string
+	format        string
+	output        string
+	blockDuration time.Duration
+}
+
+// stringList is a flag that can be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func main() {
+	cfg := parseFlags()
+
+	selectors, start, end, err := validate(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	pool, err := pgxpool.Connect(context.Background(), cfg.pgmodelCfg.GetConnectionStr())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot connect to the database:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+	defer pool.Close()
+
+	reader := pgmodel.NewPgxReader(pool)
+	reader.EnableTombstones()
+	stats, err := export(context.Background(), cfg, reader, selectors, start, end, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Export failed:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d samples of %d series\n", stats.Samples, stats.Series)
+}
+
+func parseFlags() *config {
+	cfg := &config{}
+
+	pgclient.ParseFlags(&cfg.pgmodelCfg)
+
+	flag.Var(&cfg.selectors, "match", "Series selector of the series to export, such as 'up{job=\"api\"}'. Can be repeated.")
+	flag.StringVar(&cfg.start, "start", "", "Start of the exported range, as a RFC 3339 or Unix timestamp.")
+	flag.StringVar(&cfg.end, "end", "", "End of the exported range, as a RFC 3339 or Unix timestamp. Defaults to now.")
+	flag.StringVar(&cfg.format, "format", formatOpenMetrics, "Format of the export [ \""+formatOpenMetrics+"\", \""+formatTSDB+"\" ].")
+	flag.StringVar(&cfg.output, "output", "", "File the OpenMetrics text is written to, stdout if empty, or directory the TSDB blocks are written to.")
+	flag.DurationVar(&cfg.blockDuration, "block-duration", 2*time.Hour, "Time range of each TSDB block written.")
+	envy.Parse("TS_PROM_EXPORT")
+	flag.Parse()
+
+	return cfg
+}
+
+// validate checks the flags and parses the selectors and the time range.
+func validate(cfg *config) ([][]*prompb.LabelMatcher, time.Time, time.Time, error) {
+	var start, end time.Time
+	if len(cfg.selectors) == 0 {
+		return nil, start, end, fmt.Errorf("-match is required")
+	}
+	selectors := make([][]*prompb.LabelMatcher, 0, len(cfg.selectors))
+	for _, s := range cfg.selectors {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			return nil, start, end, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		selectors = append(selectors, toLabelMatchers(matchers))
+	}
+
+	if cfg.start == "" {
+		return nil, start, end, fmt.Errorf("-start is required")
+	}
+	start, err := parseTime(cfg.start)
+	if err != nil {
+		return nil, start, end, fmt.Errorf("invalid -start: %w", err)
+	}
+	end = time.Now()
+	if cfg.end != "" {
+		if end, err = parseTime(cfg.end); err != nil {
+			return nil, start, end, fmt.Errorf("invalid -end: %w", err)
+		}
+	}
+	if end.Before(start) {
+		return nil, start, end, fmt.Errorf("-end must not be before -start")
+	}
+
+	switch cfg.format {
+	case formatOpenMetrics:
+	case formatTSDB:
+		if cfg.output == "" {
+			return nil, start, end, fmt.Errorf("-output is required with the %s format", formatTSDB)
+		}
+		if cfg.blockDuration <= 0 {
+			return nil, start, end, fmt.Errorf("-block-duration must be positive")
+		}
+	default:
+		return nil, start, end, fmt.Errorf("invalid format %q", cfg.format)
+	}
+	return selectors, start, end, nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t, nil
+	}
+	seconds, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a RFC 3339 nor a Unix timestamp", v)
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))).Round(time.Millisecond), nil
+}
+
+func toLabelMatchers(matchers []*labels.Matcher) []*prompb.LabelMatcher {
+	result := make([]*prompb.LabelMatcher, 0, len(matchers))
+	for _, m := range matchers {
+		var mtype prompb.LabelMatcher_Type
+		switch m.Type {
+		case labels.MatchEqual:
+			mtype = prompb.LabelMatcher_EQ
+		case labels.MatchNotEqual:
+			mtype = prompb.LabelMatcher_NEQ
+		case labels.MatchRegexp:
+			mtype = prompb.LabelMatcher_RE
+		case labels.MatchNotRegexp:
+			mtype = prompb.LabelMatcher_NRE
+		}
+		result = append(result, &prompb.LabelMatcher{Type: mtype, Name: m.Name, Value: m.Value})
+	}
+	return result
+}
+
+type exporter interface {
+	Export(context.Context, [][]*prompb.LabelMatcher, time.Time, time.Time, time.Duration, pgmodel.ExportWriter) (pgmodel.ExportStats, error)
+}
+
+// export writes the series in the configured format, to stdout unless an
+// output is configured.
+func export(ctx context.Context, cfg *config, e exporter, selectors [][]*prompb.LabelMatcher, start, end time.Time, stdout io.Writer) (pgmodel.ExportStats, error) {
+	if cfg.format == formatTSDB {
+		return e.Export(ctx, selectors, start, end, cfg.blockDuration, newBlockWriter(cfg.output))
+	}
+
+	out := stdout
+	if cfg.output != "" {
+		f, err := os.Create(cfg.output)
+		if err != nil {
+			return pgmodel.ExportStats{}, err
+		}
+		defer f.Close()
+		out = f
+	}
+	w := pgmodel.NewOpenMetricsWriter(out)
+	stats, err := e.Export(ctx, selectors, start, end, 0, w)
+	if err != nil {
+		return stats, err
+	}
+	return stats, w.Close()
+}
+
+// blockWriter writes a TSDB block per window. The samples of a window are
+// kept in memory until it is flushed.
+type blockWriter struct {
+	dir     string
+	samples []*tsdb.MetricSample
+	blocks  []string
+}
+
+func newBlockWriter(dir string) *blockWriter {
+	return &blockWriter{dir: dir}
+}
+
+func (b *blockWriter) WriteSeries(ts *prompb.TimeSeries) error {
+	lset := make(labels.Labels, 0, len(ts.Labels))
+	for _, l := range ts.Labels {
+		lset = append(lset, labels.Label{Name: l.Name, Value: l.Value})
+	}
+	sort.Sort(lset)
+	for _, s := range ts.Samples {
+		b.samples = append(b.samples, &tsdb.MetricSample{TimestampMs: s.Timestamp, Value: s.Value, Labels: lset})
+	}
+	return nil
+}
+
+func (b *blockWriter) FlushWindow(mint, maxt int64) error {
+	if len(b.samples) == 0 {
+		return nil
+	}
+	// The head the block is cut from rejects the samples older than half
+	// its range before the first one appended.
+	sort.SliceStable(b.samples, func(i, j int) bool { return b.samples[i].TimestampMs < b.samples[j].TimestampMs })
+	block, err := tsdb.CreateBlock(b.samples, b.dir, mint, maxt, kitlog.NewNopLogger())
+	if err != nil {
+		return fmt.Errorf("writing the block from %d to %d: %w", mint, maxt, err)
+	}
+	b.blocks = append(b.blocks, block)
+	b.samples = nil
+	return nil
+}
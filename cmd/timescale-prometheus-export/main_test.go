@@ -0,0 +1,119 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/prometheus/prometheus/tsdb"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		cfg   config
+		valid bool
+	}{
+		{
+			name:  "openmetrics to stdout",
+			cfg:   config{selectors: stringList{`up{job="api"}`, "node_load1"}, start: "2020-05-01T00:00:00Z", end: "1588377600", format: formatOpenMetrics},
+			valid: true,
+		},
+		{
+			name:  "tsdb blocks",
+			cfg:   config{selectors: stringList{"up"}, start: "1588291200.5", format: formatTSDB, output: "blocks", blockDuration: time.Hour},
+			valid: true,
+		},
+		{
+			name: "no selector",
+			cfg:  config{start: "0", format: formatOpenMetrics},
+		},
+		{
+			name: "invalid selector",
+			cfg:  config{selectors: stringList{"up{"}, start: "0", format: formatOpenMetrics},
+		},
+		{
+			name: "no start",
+			cfg:  config{selectors: stringList{"up"}, format: formatOpenMetrics},
+		},
+		{
+			name: "end before start",
+			cfg:  config{selectors: stringList{"up"}, start: "10", end: "5", format: formatOpenMetrics},
+		},
+		{
+			name: "tsdb without output",
+			cfg:  config{selectors: stringList{"up"}, start: "0", format: formatTSDB, blockDuration: time.Hour},
+		},
+		{
+			name: "unknown format",
+			cfg:  config{selectors: stringList{"up"}, start: "0", format: "csv"},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			_, _, _, err := validate(&c.cfg)
+			if c.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !c.valid && err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestBlockWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w := newBlockWriter(dir)
+	series := []*prompb.TimeSeries{
+		{
+			Labels:  []prompb.Label{{Name: "job", Value: "api"}, {Name: "__name__", Value: "up"}},
+			Samples: []prompb.Sample{{Timestamp: 5000000, Value: 0}},
+		},
+		{
+			// Older than half the block range before the first sample written.
+			Labels:  []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "db"}},
+			Samples: []prompb.Sample{{Timestamp: 0, Value: 1}, {Timestamp: 1000, Value: 1}},
+		},
+	}
+	for _, ts := range series {
+		if err = w.WriteSeries(ts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.FlushWindow(0, 7200000); err != nil {
+		t.Fatal(err)
+	}
+	// Empty windows write no block.
+	if err = w.FlushWindow(7200000, 14400000); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.blocks) != 1 {
+		t.Fatalf("unexpected blocks: %v", w.blocks)
+	}
+
+	block, err := tsdb.OpenBlock(kitlog.NewNopLogger(), w.blocks[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer block.Close()
+	meta := block.Meta()
+	if meta.MinTime != 0 || meta.MaxTime != 7200000 {
+		t.Errorf("unexpected block range: %d to %d", meta.MinTime, meta.MaxTime)
+	}
+	if meta.Stats.NumSeries != 2 || meta.Stats.NumSamples != 3 {
+		t.Errorf("unexpected block stats: %+v", meta.Stats)
+	}
+}
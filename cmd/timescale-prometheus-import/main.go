@@ -0,0 +1,271 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+// timescale-prometheus-import backfills TimescaleDB with the history of a
+// Prometheus server, read from its TSDB blocks on disk or through its remote
+// read API, and written with the COPYs of the connector.
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jamiealquiza/envy"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/pgclient"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+	"github.com/timescale/timescale-prometheus/pkg/util"
+)
+
+type config struct {
+	pgmodelCfg      pgclient.Config
+	tsdbDir         string
+	remoteReadURL   string
+	bearerTokenFile string
+	selectors       stringList
+	start           string
+	end             string
+	readWindow      time.Duration
+	readTimeout     time.Duration
+	batchSize       int
+}
+
+// stringList is a flag that can be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// importRange is the time range imported, in milliseconds, inclusive.
+type importRange struct {
+	mint, maxt int64
+}
+
+// importStats counts the imported series and samples.
+type importStats struct {
+	series  int
+	samples int
+}
+
+func main() {
+	cfg := parseFlags()
+	if err := log.Init("info"); err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot start logging:", err)
+		os.Exit(1)
+	}
+
+	selectors, r, err := validate(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	src, err := newSource(cfg, selectors, r)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	relaxIngestLimits(&cfg.pgmodelCfg)
+	client, err := pgclient.NewClient(&cfg.pgmodelCfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot connect to the database:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	stats, err := importSeries(context.Background(), src, client, cfg.batchSize)
+	fmt.Fprintf(os.Stdout, "Imported %d samples of %d series\n", stats.samples, stats.series)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Import failed:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+}
+
+func parseFlags() *config {
+	cfg := &config{}
+
+	pgclient.ParseFlags(&cfg.pgmodelCfg)
+
+	flag.StringVar(&cfg.tsdbDir, "tsdb-dir", "", "Data directory of a Prometheus server, or directory of a single TSDB block, to import the blocks of.")
+	flag.StringVar(&cfg.remoteReadURL, "remote-read-url", "", "Remote read endpoint of the Prometheus server to import from, such as http://prometheus:9090/api/v1/read.")
+	flag.StringVar(&cfg.bearerTokenFile, "bearer-token-file", "", "File holding the bearer token sent with the remote reads.")
+	flag.Var(&cfg.selectors, "match", "Series selector of the series to import, such as 'up{job=\"api\"}'. Can be repeated. Required with -remote-read-url, all the series of the blocks are imported otherwise.")
+	flag.StringVar(&cfg.start, "start", "", "Start of the imported range, as a RFC 3339 or Unix timestamp. Required with -remote-read-url, the start of the oldest block otherwise.")
+	flag.StringVar(&cfg.end, "end", "", "End of the imported range, as a RFC 3339 or Unix timestamp. Defaults to now.")
+	flag.DurationVar(&cfg.readWindow, "read-window", 2*time.Hour, "Time range read by each remote read, to bound the memory the responses take.")
+	flag.DurationVar(&cfg.readTimeout, "read-timeout", 5*time.Minute, "Timeout of a single remote read.")
+	flag.IntVar(&cfg.batchSize, "batch-size", 50000, "Number of samples written to the database at once.")
+	envy.Parse("TS_PROM_IMPORT")
+	flag.Parse()
+
+	return cfg
+}
+
+// validate checks the flags and parses the selectors and the time range.
+func validate(cfg *config) ([][]*labels.Matcher, importRange, error) {
+	r := importRange{mint: minTime, maxt: timestamp(time.Now())}
+	if (cfg.tsdbDir == "") == (cfg.remoteReadURL == "") {
+		return nil, r, fmt.Errorf("exactly one of -tsdb-dir and -remote-read-url is required")
+	}
+	if cfg.remoteReadURL != "" {
+		if len(cfg.selectors) == 0 {
+			return nil, r, fmt.Errorf("-match is required with -remote-read-url")
+		}
+		if cfg.start == "" {
+			return nil, r, fmt.Errorf("-start is required with -remote-read-url")
+		}
+		if cfg.readWindow <= 0 {
+			return nil, r, fmt.Errorf("-read-window must be positive")
+		}
+	}
+	if cfg.batchSize <= 0 {
+		return nil, r, fmt.Errorf("-batch-size must be positive")
+	}
+
+	selectors := make([][]*labels.Matcher, 0, len(cfg.selectors))
+	for _, s := range cfg.selectors {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			return nil, r, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		selectors = append(selectors, matchers)
+	}
+	if len(selectors) == 0 {
+		selectors = append(selectors, []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, labels.MetricName, ".+")})
+	}
+
+	if cfg.start != "" {
+		t, err := parseTime(cfg.start)
+		if err != nil {
+			return nil, r, fmt.Errorf("invalid -start: %w", err)
+		}
+		r.mint = timestamp(t)
+	}
+	if cfg.end != "" {
+		t, err := parseTime(cfg.end)
+		if err != nil {
+			return nil, r, fmt.Errorf("invalid -end: %w", err)
+		}
+		r.maxt = timestamp(t)
+	}
+	if r.maxt < r.mint {
+		return nil, r, fmt.Errorf("-end must not be before -start")
+	}
+	return selectors, r, nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t, nil
+	}
+	seconds, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a RFC 3339 nor a Unix timestamp", v)
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))).Round(time.Millisecond), nil
+}
+
+func timestamp(t time.Time) int64 {
+	return t.Unix()*1000 + int64(t.Nanosecond())/int64(time.Millisecond)
+}
+
+func newSource(cfg *config, selectors [][]*labels.Matcher, r importRange) (source, error) {
+	if cfg.tsdbDir != "" {
+		return newBlockSource(cfg.tsdbDir, selectors, r)
+	}
+	token := ""
+	if cfg.bearerTokenFile != "" {
+		data, err := ioutil.ReadFile(cfg.bearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the bearer token: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	client := &http.Client{Timeout: cfg.readTimeout}
+	return newRemoteReadSource(client, cfg.remoteReadURL, token, selectors, r, cfg.readWindow), nil
+}
+
+// relaxIngestLimits turns off the checks of the connector that protect live
+// ingestion but would reject or drop history: the bounds on the sample age,
+// the series limits and the HA deduplication. Acks are synchronous so that
+// a failed write fails the import, and nothing is spilled.
+func relaxIngestLimits(cfg *pgclient.Config) {
+	cfg.SampleBounds = pgmodel.SampleBoundsConfig{}
+	cfg.SeriesLimit.MaxSeriesPerMetric = 0
+	cfg.HADedup = false
+	cfg.AsyncAcks = false
+	if cfg.AckMode == string(pgmodel.AckModeAsync) {
+		cfg.AckMode = string(pgmodel.AckModeSync)
+	}
+	cfg.SpillDir = ""
+}
+
+type ingester interface {
+	Ingest(context.Context, []prompb.TimeSeries, *prompb.WriteRequest) (uint64, error)
+}
+
+// importSeries writes the series of src to the database in batches of up
+// to batchSize samples. The series longer than a batch are split.
+func importSeries(ctx context.Context, src source, ing ingester, batchSize int) (importStats, error) {
+	var (
+		stats   importStats
+		req     = pgmodel.NewWriteRequest()
+		samples int
+	)
+	flush := func() error {
+		if len(req.Timeseries) == 0 {
+			return nil
+		}
+		// Ingest recycles the request.
+		if _, err := ing.Ingest(ctx, req.Timeseries, req); err != nil {
+			return err
+		}
+		stats.samples += samples
+		req, samples = pgmodel.NewWriteRequest(), 0
+		return nil
+	}
+
+	err := src.forEach(ctx, func(ts prompb.TimeSeries) error {
+		stats.series++
+		for len(ts.Samples) > 0 {
+			n := batchSize - samples
+			if n > len(ts.Samples) {
+				n = len(ts.Samples)
+			}
+			// The labels are cleared once ingested, but may still be needed
+			// by the rest of the series.
+			labels := append([]prompb.Label(nil), ts.Labels...)
+			req.Timeseries = append(req.Timeseries, prompb.TimeSeries{Labels: labels, Samples: ts.Samples[:n]})
+			samples += n
+			ts.Samples = ts.Samples[n:]
+			if samples >= batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+	return stats, flush()
+}
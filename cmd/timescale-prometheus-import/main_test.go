@@ -0,0 +1,226 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		cfg   config
+		valid bool
+	}{
+		{
+			name:  "blocks",
+			cfg:   config{tsdbDir: "data", batchSize: 10},
+			valid: true,
+		},
+		{
+			name:  "remote read",
+			cfg:   config{remoteReadURL: "http://prometheus:9090/api/v1/read", selectors: stringList{`up{job="api"}`}, start: "2020-05-01T00:00:00Z", readWindow: time.Hour, batchSize: 10},
+			valid: true,
+		},
+		{
+			name: "no source",
+			cfg:  config{batchSize: 10},
+		},
+		{
+			name: "two sources",
+			cfg:  config{tsdbDir: "data", remoteReadURL: "http://prometheus:9090/api/v1/read", selectors: stringList{"up"}, start: "0", readWindow: time.Hour, batchSize: 10},
+		},
+		{
+			name: "remote read without selector",
+			cfg:  config{remoteReadURL: "http://prometheus:9090/api/v1/read", start: "0", readWindow: time.Hour, batchSize: 10},
+		},
+		{
+			name: "remote read without start",
+			cfg:  config{remoteReadURL: "http://prometheus:9090/api/v1/read", selectors: stringList{"up"}, readWindow: time.Hour, batchSize: 10},
+		},
+		{
+			name: "invalid selector",
+			cfg:  config{tsdbDir: "data", selectors: stringList{"up{"}, batchSize: 10},
+		},
+		{
+			name: "end before start",
+			cfg:  config{tsdbDir: "data", start: "10", end: "5", batchSize: 10},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			_, _, err := validate(&c.cfg)
+			if c.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !c.valid && err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// mockIngester records the batches written.
+type mockIngester struct {
+	batches [][]prompb.TimeSeries
+}
+
+func (m *mockIngester) Ingest(_ context.Context, tts []prompb.TimeSeries, _ *prompb.WriteRequest) (uint64, error) {
+	m.batches = append(m.batches, append([]prompb.TimeSeries(nil), tts...))
+	n := 0
+	for _, ts := range tts {
+		n += len(ts.Samples)
+	}
+	return uint64(n), nil
+}
+
+func createBlock(t *testing.T, dir string, mint, maxt int64, samples ...*tsdb.MetricSample) {
+	if _, err := tsdb.CreateBlock(samples, dir, mint, maxt, kitlog.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportBlocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	up := labels.FromStrings(labels.MetricName, "up", "job", "api")
+	// The newest block is written first.
+	createBlock(t, dir, 7200000, 14400000,
+		&tsdb.MetricSample{TimestampMs: 7200000, Value: 3, Labels: up},
+	)
+	createBlock(t, dir, 0, 7200000,
+		&tsdb.MetricSample{TimestampMs: 1000, Value: 1, Labels: up},
+		&tsdb.MetricSample{TimestampMs: 2000, Value: 2, Labels: up},
+		&tsdb.MetricSample{TimestampMs: 1000, Value: 5, Labels: labels.FromStrings(labels.MetricName, "other")},
+	)
+
+	selectors, r, err := validate(&config{tsdbDir: dir, selectors: stringList{"up"}, start: "1.5", batchSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := newBlockSource(dir, selectors, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ingester := &mockIngester{}
+	stats, err := importSeries(context.Background(), src, ingester, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats != (importStats{series: 2, samples: 2}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	expectedLabels := []prompb.Label{{Name: labels.MetricName, Value: "up"}, {Name: "job", Value: "api"}}
+	expected := [][]prompb.TimeSeries{
+		{{Labels: expectedLabels, Samples: []prompb.Sample{{Timestamp: 2000, Value: 2}}}},
+		{{Labels: expectedLabels, Samples: []prompb.Sample{{Timestamp: 7200000, Value: 3}}}},
+	}
+	if !reflect.DeepEqual(ingester.batches, expected) {
+		t.Errorf("unexpected batches: got %v wanted %v", ingester.batches, expected)
+	}
+
+	if _, err = newBlockSource(dir, selectors, importRange{mint: 20000000, maxt: 30000000}); err == nil {
+		t.Error("expected an error without blocks in the range")
+	}
+}
+
+func TestImportSplitsSeries(t *testing.T) {
+	ts := prompb.TimeSeries{
+		Labels:  []prompb.Label{{Name: labels.MetricName, Value: "up"}},
+		Samples: []prompb.Sample{{Timestamp: 1, Value: 1}, {Timestamp: 2, Value: 2}, {Timestamp: 3, Value: 3}},
+	}
+	ingester := &mockIngester{}
+	stats, err := importSeries(context.Background(), seriesSource{ts}, ingester, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (importStats{series: 1, samples: 3}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if len(ingester.batches) != 2 || len(ingester.batches[0][0].Samples) != 2 || len(ingester.batches[1][0].Samples) != 1 {
+		t.Errorf("unexpected batches: %v", ingester.batches)
+	}
+}
+
+type seriesSource []prompb.TimeSeries
+
+func (s seriesSource) forEach(_ context.Context, process func(prompb.TimeSeries) error) error {
+	for _, ts := range s {
+		if err := process(ts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestImportRemoteRead(t *testing.T) {
+	windows := make([][2]int64, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		compressed, _ := ioutil.ReadAll(r.Body)
+		data, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var req prompb.ReadRequest
+		if err = proto.Unmarshal(data, &req); err != nil {
+			t.Fatal(err)
+		}
+		q := req.Queries[0]
+		windows = append(windows, [2]int64{q.StartTimestampMs, q.EndTimestampMs})
+		resp := &prompb.ReadResponse{Results: []*prompb.QueryResult{{
+			Timeseries: []*prompb.TimeSeries{{
+				Labels:  []prompb.Label{{Name: labels.MetricName, Value: "up"}},
+				Samples: []prompb.Sample{{Timestamp: q.StartTimestampMs, Value: 1}},
+			}},
+		}}}
+		data, err = proto.Marshal(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Encoding", "snappy")
+		_, _ = w.Write(snappy.Encode(nil, data))
+	}))
+	defer server.Close()
+
+	cfg := &config{remoteReadURL: server.URL, selectors: stringList{"up"}, start: "0", end: "2.5", readWindow: time.Second, batchSize: 10}
+	selectors, r, err := validate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := newRemoteReadSource(server.Client(), server.URL, "token", selectors, r, cfg.readWindow)
+	ingester := &mockIngester{}
+	stats, err := importSeries(context.Background(), src, ingester, cfg.batchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (importStats{series: 3, samples: 3}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if expected := [][2]int64{{0, 999}, {1000, 1999}, {2000, 2500}}; !reflect.DeepEqual(windows, expected) {
+		t.Errorf("unexpected windows: got %v wanted %v", windows, expected)
+	}
+}
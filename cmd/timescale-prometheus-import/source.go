@@ -0,0 +1,270 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	minTime = math.MinInt64
+
+	blockMetaFile = "meta.json"
+)
+
+// source reads the series to import.
+type source interface {
+	// forEach calls process with every series read, the oldest samples
+	// first. A series may be passed several times, with the samples of
+	// different time ranges.
+	forEach(ctx context.Context, process func(prompb.TimeSeries) error) error
+}
+
+// blockSource reads the TSDB blocks of a Prometheus data directory. The head
+// block, still in the write-ahead log, is not read.
+type blockSource struct {
+	dirs      []string
+	selectors [][]*labels.Matcher
+	r         importRange
+}
+
+type blockMeta struct {
+	dir     string
+	MinTime int64 `json:"minTime"`
+	MaxTime int64 `json:"maxTime"`
+}
+
+// newBlockSource returns a source reading the block in dir, or the blocks
+// of the data directory dir overlapping r, oldest first.
+func newBlockSource(dir string, selectors [][]*labels.Matcher, r importRange) (*blockSource, error) {
+	candidates := []string{dir}
+	if _, err := os.Stat(filepath.Join(dir, blockMetaFile)); os.IsNotExist(err) {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		candidates = candidates[:0]
+		for _, e := range entries {
+			if e.IsDir() {
+				candidates = append(candidates, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+
+	metas := make([]blockMeta, 0, len(candidates))
+	for _, c := range candidates {
+		data, err := ioutil.ReadFile(filepath.Join(c, blockMetaFile))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		meta := blockMeta{dir: c}
+		if err = json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("reading the meta of block %s: %w", c, err)
+		}
+		// The maxTime of a block is exclusive.
+		if meta.MaxTime <= r.mint || meta.MinTime > r.maxt {
+			continue
+		}
+		metas = append(metas, meta)
+	}
+	if len(metas) == 0 {
+		return nil, fmt.Errorf("no TSDB block in the imported range found in %s", dir)
+	}
+	sort.SliceStable(metas, func(i, j int) bool { return metas[i].MinTime < metas[j].MinTime })
+
+	s := &blockSource{selectors: selectors, r: r}
+	for _, m := range metas {
+		s.dirs = append(s.dirs, m.dir)
+	}
+	return s, nil
+}
+
+func (s *blockSource) forEach(ctx context.Context, process func(prompb.TimeSeries) error) error {
+	for _, dir := range s.dirs {
+		if err := s.readBlock(ctx, dir, process); err != nil {
+			return fmt.Errorf("reading block %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+func (s *blockSource) readBlock(ctx context.Context, dir string, process func(prompb.TimeSeries) error) error {
+	block, err := tsdb.OpenBlock(kitlog.NewNopLogger(), dir, nil)
+	if err != nil {
+		return err
+	}
+	defer block.Close()
+	querier, err := tsdb.NewBlockQuerier(block, s.r.mint, s.r.maxt)
+	if err != nil {
+		return err
+	}
+	defer querier.Close()
+
+	for _, matchers := range s.selectors {
+		set, _, err := querier.Select(false, nil, matchers...)
+		if err != nil {
+			return err
+		}
+		for set.Next() {
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+			series := set.At()
+			ts := prompb.TimeSeries{Labels: make([]prompb.Label, 0, len(series.Labels()))}
+			for _, l := range series.Labels() {
+				ts.Labels = append(ts.Labels, prompb.Label{Name: l.Name, Value: l.Value})
+			}
+			it := series.Iterator()
+			for it.Next() {
+				t, v := it.At()
+				if t >= s.r.mint && t <= s.r.maxt {
+					ts.Samples = append(ts.Samples, prompb.Sample{Timestamp: t, Value: v})
+				}
+			}
+			if err = it.Err(); err != nil {
+				return err
+			}
+			if len(ts.Samples) == 0 {
+				continue
+			}
+			if err = process(ts); err != nil {
+				return err
+			}
+		}
+		if err = set.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remoteReadSource reads the series from the remote read API of a
+// Prometheus server, one window of time after the other.
+type remoteReadSource struct {
+	client    *http.Client
+	url       string
+	token     string
+	selectors [][]*prompb.LabelMatcher
+	r         importRange
+	window    int64
+}
+
+func newRemoteReadSource(client *http.Client, url, token string, selectors [][]*labels.Matcher, r importRange, window time.Duration) *remoteReadSource {
+	s := &remoteReadSource{client: client, url: url, token: token, r: r, window: window.Milliseconds()}
+	for _, matchers := range selectors {
+		s.selectors = append(s.selectors, toLabelMatchers(matchers))
+	}
+	return s
+}
+
+func (s *remoteReadSource) forEach(ctx context.Context, process func(prompb.TimeSeries) error) error {
+	for start := s.r.mint; start <= s.r.maxt; start += s.window {
+		end := start + s.window - 1
+		if end > s.r.maxt {
+			end = s.r.maxt
+		}
+		req := &prompb.ReadRequest{}
+		for _, matchers := range s.selectors {
+			req.Queries = append(req.Queries, &prompb.Query{StartTimestampMs: start, EndTimestampMs: end, Matchers: matchers})
+		}
+		resp, err := s.read(ctx, req)
+		if err != nil {
+			return fmt.Errorf("reading from %d to %d: %w", start, end, err)
+		}
+		for _, result := range resp.Results {
+			for _, ts := range result.Timeseries {
+				if len(ts.Samples) == 0 {
+					continue
+				}
+				if err = process(*ts); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *remoteReadSource) read(ctx context.Context, readReq *prompb.ReadRequest) (*prompb.ReadResponse, error) {
+	data, err := proto.Marshal(readReq)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(snappy.Encode(nil, data)))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Read-Version", "0.1.0")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	compressed, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	data, err = snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, err
+	}
+	var readResp prompb.ReadResponse
+	if err = proto.Unmarshal(data, &readResp); err != nil {
+		return nil, err
+	}
+	return &readResp, nil
+}
+
+func toLabelMatchers(matchers []*labels.Matcher) []*prompb.LabelMatcher {
+	result := make([]*prompb.LabelMatcher, 0, len(matchers))
+	for _, m := range matchers {
+		var mtype prompb.LabelMatcher_Type
+		switch m.Type {
+		case labels.MatchEqual:
+			mtype = prompb.LabelMatcher_EQ
+		case labels.MatchNotEqual:
+			mtype = prompb.LabelMatcher_NEQ
+		case labels.MatchRegexp:
+			mtype = prompb.LabelMatcher_RE
+		case labels.MatchNotRegexp:
+			mtype = prompb.LabelMatcher_NRE
+		}
+		result = append(result, &prompb.LabelMatcher{Type: mtype, Name: m.Name, Value: m.Value})
+	}
+	return result
+}
@@ -0,0 +1,181 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+// timescale-prometheus-relabel rewrites label values of stored series
+// across their history, such as to fix a typo in a label: the series with
+// the new labels are created, the samples are moved to them in batches and
+// the old series are deleted. An interrupted rewrite is resumed by running
+// it again.
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jamiealquiza/envy"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/pgclient"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+	"github.com/timescale/timescale-prometheus/pkg/util"
+)
+
+type config struct {
+	pgmodelCfg    pgclient.Config
+	selectors     stringList
+	set           stringList
+	batchDuration time.Duration
+	dryRun        bool
+}
+
+// stringList is a flag that can be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func main() {
+	cfg := parseFlags()
+
+	relabelCfg, err := validate(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err = log.Init("info"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	pool, err := pgxpool.Connect(context.Background(), cfg.pgmodelCfg.GetConnectionStr())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot connect to the database:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+	defer pool.Close()
+
+	relabelCfg.Progress = func(p pgmodel.SeriesRelabelProgress) { printProgress(os.Stderr, p) }
+	relabelings, err := pgmodel.NewSeriesRelabeler(pool).Relabel(context.Background(), relabelCfg)
+	report(os.Stdout, relabelings, cfg.dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Relabeling failed, run the same command again to resume:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+}
+
+func parseFlags() *config {
+	cfg := &config{}
+
+	pgclient.ParseFlags(&cfg.pgmodelCfg)
+
+	flag.Var(&cfg.selectors, "match", "Series selector of the series to rewrite, such as 'up{job=\"apu\"}'. Can be repeated.")
+	flag.Var(&cfg.set, "set", "Label to rewrite, as name=value, such as job=api. An empty value removes the label. Can be repeated.")
+	flag.DurationVar(&cfg.batchDuration, "batch-duration", pgmodel.DefaultRelabelBatchDuration, "Time range of the samples moved at once.")
+	flag.BoolVar(&cfg.dryRun, "dry-run", false, "Only print the series that would be rewritten and their number of samples.")
+	envy.Parse("TS_PROM_RELABEL")
+	flag.Parse()
+
+	return cfg
+}
+
+// validate checks the flags and returns the rewrite they configure.
+func validate(cfg *config) (pgmodel.SeriesRelabelConfig, error) {
+	relabelCfg := pgmodel.SeriesRelabelConfig{BatchDuration: cfg.batchDuration, DryRun: cfg.dryRun}
+	if len(cfg.selectors) == 0 {
+		return relabelCfg, fmt.Errorf("-match is required")
+	}
+	for _, s := range cfg.selectors {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			return relabelCfg, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		relabelCfg.Selectors = append(relabelCfg.Selectors, toLabelMatchers(matchers))
+	}
+
+	if len(cfg.set) == 0 {
+		return relabelCfg, fmt.Errorf("-set is required")
+	}
+	relabelCfg.Set = make(map[string]string, len(cfg.set))
+	for _, s := range cfg.set {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || !model.LabelName(parts[0]).IsValid() {
+			return relabelCfg, fmt.Errorf("invalid label %q, expected name=value", s)
+		}
+		if parts[0] == pgmodel.MetricNameLabelName {
+			return relabelCfg, fmt.Errorf("the %s label cannot be rewritten", pgmodel.MetricNameLabelName)
+		}
+		relabelCfg.Set[parts[0]] = parts[1]
+	}
+
+	if cfg.batchDuration <= 0 {
+		return relabelCfg, fmt.Errorf("-batch-duration must be positive")
+	}
+	return relabelCfg, nil
+}
+
+func toLabelMatchers(matchers []*labels.Matcher) []*prompb.LabelMatcher {
+	result := make([]*prompb.LabelMatcher, 0, len(matchers))
+	for _, m := range matchers {
+		var mtype prompb.LabelMatcher_Type
+		switch m.Type {
+		case labels.MatchEqual:
+			mtype = prompb.LabelMatcher_EQ
+		case labels.MatchNotEqual:
+			mtype = prompb.LabelMatcher_NEQ
+		case labels.MatchRegexp:
+			mtype = prompb.LabelMatcher_RE
+		case labels.MatchNotRegexp:
+			mtype = prompb.LabelMatcher_NRE
+		}
+		result = append(result, &prompb.LabelMatcher{Type: mtype, Name: m.Name, Value: m.Value})
+	}
+	return result
+}
+
+func printProgress(w io.Writer, p pgmodel.SeriesRelabelProgress) {
+	percent := 100.0
+	if total := p.End.Sub(p.Start); total > 0 {
+		percent = 100 * float64(p.Through.Sub(p.Start)) / float64(total)
+	}
+	fmt.Fprintf(w, "%s: moved %d samples through %s (%.0f%%)\n", p.Metric, p.Samples, p.Through.UTC().Format(time.RFC3339), percent)
+}
+
+// report prints the series rewritten, or to be rewritten by a dry run.
+func report(w io.Writer, relabelings []pgmodel.SeriesRelabeling, dryRun bool) {
+	for _, r := range relabelings {
+		for _, rewrite := range r.Rewrites {
+			fmt.Fprintf(w, "%s -> %s\n", formatLabels(rewrite.From), formatLabels(rewrite.To))
+		}
+		if dryRun {
+			fmt.Fprintf(w, "%s: %d series and %d samples to rewrite\n", r.Metric, len(r.Rewrites), r.Samples)
+			continue
+		}
+		fmt.Fprintf(w, "%s: rewrote %d series, moved %d samples and %d histograms, deleted %d old series\n",
+			r.Metric, len(r.Rewrites), r.Samples, r.Histograms, r.DeletedSeries)
+	}
+}
+
+func formatLabels(ls []prompb.Label) string {
+	res := make(labels.Labels, 0, len(ls))
+	for _, l := range ls {
+		res = append(res, labels.Label{Name: l.Name, Value: l.Value})
+	}
+	return res.String()
+}
@@ -0,0 +1,99 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		cfg   config
+		valid bool
+	}{
+		{
+			name:  "valid",
+			cfg:   config{selectors: stringList{`up{job="apu"}`}, set: stringList{"job=api", "env="}, batchDuration: time.Hour},
+			valid: true,
+		},
+		{
+			name: "no selector",
+			cfg:  config{set: stringList{"job=api"}, batchDuration: time.Hour},
+		},
+		{
+			name: "invalid selector",
+			cfg:  config{selectors: stringList{"up{"}, set: stringList{"job=api"}, batchDuration: time.Hour},
+		},
+		{
+			name: "no label",
+			cfg:  config{selectors: stringList{"up"}, batchDuration: time.Hour},
+		},
+		{
+			name: "invalid label",
+			cfg:  config{selectors: stringList{"up"}, set: stringList{"job"}, batchDuration: time.Hour},
+		},
+		{
+			name: "metric name",
+			cfg:  config{selectors: stringList{"up"}, set: stringList{"__name__=down"}, batchDuration: time.Hour},
+		},
+		{
+			name: "no batch duration",
+			cfg:  config{selectors: stringList{"up"}, set: stringList{"job=api"}},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			relabelCfg, err := validate(&c.cfg)
+			if c.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !c.valid && err == nil {
+				t.Error("expected an error")
+			}
+			if c.valid && !reflect.DeepEqual(relabelCfg.Set, map[string]string{"job": "api", "env": ""}) {
+				t.Errorf("unexpected labels: %v", relabelCfg.Set)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	relabelings := []pgmodel.SeriesRelabeling{{
+		Metric: "up",
+		Rewrites: []pgmodel.SeriesRewrite{{
+			From: []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "apu"}},
+			To:   []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "api"}},
+		}},
+		Samples:       10,
+		DeletedSeries: 1,
+	}}
+
+	var buf bytes.Buffer
+	report(&buf, relabelings, false)
+	expected := `{__name__="up", job="apu"} -> {__name__="up", job="api"}
+up: rewrote 1 series, moved 10 samples and 0 histograms, deleted 1 old series
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected report:\ngot\n%s\nwanted\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	printProgress(&buf, pgmodel.SeriesRelabelProgress{
+		Metric:  "up",
+		Start:   time.Unix(0, 0),
+		Through: time.Unix(25, 0),
+		End:     time.Unix(100, 0),
+		Samples: 3,
+	})
+	if expected := "up: moved 3 samples through 1970-01-01T00:00:25Z (25%)\n"; buf.String() != expected {
+		t.Errorf("unexpected progress: got %q wanted %q", buf.String(), expected)
+	}
+}
@@ -0,0 +1,147 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+// timescale-prometheus-replay re-issues the write requests captured by a
+// connector running with -write-capture-dir against another connector, to
+// debug failures specific to an environment with real traffic.
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jamiealquiza/envy"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+type config struct {
+	captureDir      string
+	url             string
+	bearerTokenFile string
+	rate            float64
+	timeout         time.Duration
+	stopOnError     bool
+}
+
+// replayStats counts the replayed requests.
+type replayStats struct {
+	sent   int
+	failed int
+}
+
+func main() {
+	cfg := parseFlags()
+	if err := log.Init("info"); err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot start logging:", err)
+		os.Exit(1)
+	}
+	if cfg.captureDir == "" {
+		fmt.Fprintln(os.Stderr, "-capture-dir is required")
+		os.Exit(2)
+	}
+
+	token := ""
+	if cfg.bearerTokenFile != "" {
+		data, err := ioutil.ReadFile(cfg.bearerTokenFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Cannot read the bearer token:", err)
+			os.Exit(1)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	client := &http.Client{Timeout: cfg.timeout}
+	stats, err := replay(cfg, newSender(client, cfg.url, token), os.Stderr)
+	fmt.Fprintf(os.Stdout, "Replayed %d write requests, %d failed\n", stats.sent, stats.failed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Replay aborted:", err)
+		os.Exit(1)
+	}
+	if stats.failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func parseFlags() *config {
+	cfg := &config{}
+
+	flag.StringVar(&cfg.captureDir, "capture-dir", "", "Directory of the write requests captured with -write-capture-dir.")
+	flag.StringVar(&cfg.url, "url", "http://localhost:9201/write", "Write endpoint of the connector the requests are replayed against.")
+	flag.StringVar(&cfg.bearerTokenFile, "bearer-token-file", "", "File holding the bearer token sent with the requests, for connectors with -auth-bearer-tokens-file.")
+	flag.Float64Var(&cfg.rate, "rate", 0, "Maximum number of requests replayed per second (0 means as fast as possible).")
+	flag.DurationVar(&cfg.timeout, "timeout", 30*time.Second, "Timeout of a single write request.")
+	flag.BoolVar(&cfg.stopOnError, "stop-on-error", false, "Stop at the first failed request instead of reporting it and going on.")
+	envy.Parse("TS_PROM_REPLAY")
+	flag.Parse()
+
+	return cfg
+}
+
+// newSender returns a function posting a captured request body to url as
+// Prometheus does.
+func newSender(client *http.Client, url, token string) func([]byte) error {
+	return func(body []byte) error {
+		req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+			return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+		}
+		return nil
+	}
+}
+
+// replay sends every captured request, oldest first, reporting the failed
+// ones to errOut.
+func replay(cfg *config, send func([]byte) error, errOut io.Writer) (replayStats, error) {
+	var (
+		stats    replayStats
+		interval time.Duration
+		next     time.Time
+	)
+	if cfg.rate > 0 {
+		interval = time.Duration(float64(time.Second) / cfg.rate)
+	}
+
+	err := pgmodel.ReadCapture(cfg.captureDir, func(body []byte) error {
+		if interval > 0 {
+			time.Sleep(time.Until(next))
+			next = time.Now().Add(interval)
+		}
+
+		stats.sent++
+		if err := send(body); err != nil {
+			stats.failed++
+			if cfg.stopOnError {
+				return fmt.Errorf("request %d: %w", stats.sent, err)
+			}
+			fmt.Fprintf(errOut, "Request %d failed: %v\n", stats.sent, err)
+		}
+		return nil
+	})
+	return stats, err
+}
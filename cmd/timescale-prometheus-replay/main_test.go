@@ -0,0 +1,84 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+func captureRequests(t *testing.T, bodies ...string) string {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	capture, err := pgmodel.NewRequestCapture(pgmodel.CaptureConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range bodies {
+		if err = capture.Capture([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = capture.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestReplay(t *testing.T) {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	dir := captureRequests(t, "first", "bad", "third")
+	defer os.RemoveAll(dir)
+
+	received := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = append(received, string(body))
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		if string(body) == "bad" {
+			http.Error(w, "snappy: corrupt input", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	send := newSender(server.Client(), server.URL, "token")
+
+	var errOut bytes.Buffer
+	stats, err := replay(&config{captureDir: dir}, send, &errOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (replayStats{sent: 3, failed: 1}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if !reflect.DeepEqual(received, []string{"first", "bad", "third"}) {
+		t.Errorf("unexpected replayed requests: %v", received)
+	}
+	if !strings.Contains(errOut.String(), "Request 2 failed: 400 Bad Request: snappy: corrupt input") {
+		t.Errorf("failed request not reported: %q", errOut.String())
+	}
+
+	received = received[:0]
+	stats, err = replay(&config{captureDir: dir, stopOnError: true}, send, &errOut)
+	if err == nil {
+		t.Error("expected the replay to stop at the failed request")
+	}
+	if stats != (replayStats{sent: 2, failed: 1}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
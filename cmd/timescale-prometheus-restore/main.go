@@ -0,0 +1,92 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+// timescale-prometheus-restore copies a single metric from a database where
+// a logical backup was restored into the live database, to recover an
+// accidentally deleted metric without restoring the whole database.
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jamiealquiza/envy"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgclient"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/util"
+)
+
+type config struct {
+	pgmodelCfg   pgclient.Config
+	backupURL    string
+	metric       string
+	allowOverlap bool
+	seriesBatch  int
+}
+
+func main() {
+	cfg := parseFlags()
+
+	if err := validate(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	backup, err := pgxpool.Connect(context.Background(), cfg.backupURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot connect to the backup database:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+	defer backup.Close()
+
+	live, err := pgxpool.Connect(context.Background(), cfg.pgmodelCfg.GetConnectionStr())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot connect to the database:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+	defer live.Close()
+
+	stats, err := pgmodel.NewMetricRestorer(backup, live).Restore(context.Background(), pgmodel.RestoreConfig{
+		Metric:       cfg.metric,
+		AllowOverlap: cfg.allowOverlap,
+		SeriesBatch:  cfg.seriesBatch,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Restore failed:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Restored %d samples of %d series\n", stats.Samples, stats.Series)
+}
+
+func parseFlags() *config {
+	cfg := &config{}
+
+	pgclient.ParseFlags(&cfg.pgmodelCfg)
+
+	flag.StringVar(&cfg.backupURL, "backup-db-url", "", "Connection URL of the database the backup was restored to, such as a scratch database loaded with pg_restore.")
+	flag.StringVar(&cfg.metric, "metric", "", "Name of the metric to restore.")
+	flag.BoolVar(&cfg.allowOverlap, "allow-overlap", false, "Restore the metric even if it already has samples in the time range of the backup, which may then be stored twice.")
+	flag.IntVar(&cfg.seriesBatch, "series-batch", pgmodel.DefaultRestoreSeriesBatch, "Number of series whose ids are looked up at once in the live database.")
+	envy.Parse("TS_PROM_RESTORE")
+	flag.Parse()
+
+	return cfg
+}
+
+// validate checks the flags.
+func validate(cfg *config) error {
+	switch {
+	case cfg.backupURL == "":
+		return fmt.Errorf("-backup-db-url is required")
+	case cfg.metric == "":
+		return fmt.Errorf("-metric is required")
+	case cfg.seriesBatch <= 0:
+		return fmt.Errorf("-series-batch must be positive, got %d", cfg.seriesBatch)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name string
+		cfg  config
+		err  bool
+	}{
+		{name: "valid", cfg: config{backupURL: "postgres://localhost/backup", metric: "up", seriesBatch: 10}},
+		{name: "no backup", cfg: config{metric: "up", seriesBatch: 10}, err: true},
+		{name: "no metric", cfg: config{backupURL: "postgres://localhost/backup", seriesBatch: 10}, err: true},
+		{name: "no series batch", cfg: config{backupURL: "postgres://localhost/backup", metric: "up"}, err: true},
+	}
+	for _, c := range testCases {
+		if err := validate(&c.cfg); (err != nil) != c.err {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+	}
+}
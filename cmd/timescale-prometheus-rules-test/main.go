@@ -0,0 +1,400 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+// timescale-prometheus-rules-test runs rule unit tests in the format of
+// promtool test rules against the data stored in TimescaleDB instead of
+// input series: the rule expressions are evaluated by the PromQL engine of
+// Prometheus over the series read from the database, so that migrated rules
+// can be checked to produce the same results as before the migration.
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jamiealquiza/envy"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"gopkg.in/yaml.v3"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgclient"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/util"
+)
+
+type config struct {
+	pgmodelCfg    pgclient.Config
+	lookbackDelta time.Duration
+	queryTimeout  time.Duration
+	maxSamples    int
+}
+
+func main() {
+	cfg := parseFlags()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "At least one test file is required")
+		os.Exit(2)
+	}
+
+	pool, err := pgxpool.Connect(context.Background(), cfg.pgmodelCfg.GetConnectionStr())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot connect to the database:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+	defer pool.Close()
+
+	reader := pgmodel.NewPgxReader(pool)
+	reader.EnableTombstones()
+	r := newRunner(cfg, readerQueryable{reader: reader})
+	failed := false
+	for _, f := range flag.Args() {
+		if !r.runFile(context.Background(), os.Stdout, f) {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func parseFlags() *config {
+	cfg := &config{}
+
+	pgclient.ParseFlags(&cfg.pgmodelCfg)
+
+	flag.DurationVar(&cfg.lookbackDelta, "lookback-delta", 5*time.Minute, "Maximum lookback of the instant vector selectors, as -query.lookback-delta of Prometheus.")
+	flag.DurationVar(&cfg.queryTimeout, "query-timeout", 2*time.Minute, "Maximum time an evaluation can take.")
+	flag.IntVar(&cfg.maxSamples, "query-max-samples", 50000000, "Maximum number of samples a single evaluation can load into memory.")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] test-file...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	envy.Parse("TS_PROM_RULES_TEST")
+	flag.Parse()
+
+	return cfg
+}
+
+// runner evaluates the tests against a storage.
+type runner struct {
+	engine    *promql.Engine
+	queryable storage.Queryable
+}
+
+func newRunner(cfg *config, queryable storage.Queryable) *runner {
+	engine := promql.NewEngine(promql.EngineOpts{
+		Logger:        kitlog.NewNopLogger(),
+		MaxSamples:    cfg.maxSamples,
+		Timeout:       cfg.queryTimeout,
+		LookbackDelta: cfg.lookbackDelta,
+	})
+	return &runner{engine: engine, queryable: queryable}
+}
+
+// runFile runs the tests of a file and reports their outcome to w. It
+// returns whether all of them passed.
+func (r *runner) runFile(ctx context.Context, w io.Writer, filename string) bool {
+	fmt.Fprintln(w, "Unit Testing:", filename)
+	errs := r.testFile(ctx, filename)
+	if len(errs) == 0 {
+		fmt.Fprintln(w, "  SUCCESS")
+		fmt.Fprintln(w)
+		return true
+	}
+	fmt.Fprintln(w, "  FAILED:")
+	for _, err := range errs {
+		fmt.Fprintln(w, err)
+	}
+	fmt.Fprintln(w)
+	return false
+}
+
+func (r *runner) testFile(ctx context.Context, filename string) []error {
+	tf, err := loadTestFile(filename)
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	for _, tg := range tf.Tests {
+		errs = append(errs, r.testGroup(ctx, tg, tf.EvaluationInterval, tf.RuleFiles)...)
+	}
+	return errs
+}
+
+// testFile holds the contents of a test file. It has the format of promtool
+// test rules, without the input series.
+type testFile struct {
+	RuleFiles          []string      `yaml:"rule_files"`
+	EvaluationInterval time.Duration `yaml:"evaluation_interval"`
+	Tests              []testGroup   `yaml:"tests"`
+}
+
+// testGroup is a group of tests evaluated relative to the same start time.
+type testGroup struct {
+	Name               string              `yaml:"name"`
+	Start              time.Time           `yaml:"start"`
+	ExternalLabels     map[string]string   `yaml:"external_labels"`
+	AlertRuleTests     []alertTestCase     `yaml:"alert_rule_test"`
+	RecordingRuleTests []recordingTestCase `yaml:"recording_rule_test"`
+	PromqlExprTests    []promqlTestCase    `yaml:"promql_expr_test"`
+}
+
+// alertTestCase checks the alerts firing at a time. The alerting rules are
+// evaluated from the start of the group to reproduce their for durations.
+type alertTestCase struct {
+	EvalTime  time.Duration `yaml:"eval_time"`
+	Alertname string        `yaml:"alertname"`
+	ExpAlerts []alert       `yaml:"exp_alerts"`
+}
+
+type alert struct {
+	ExpLabels      map[string]string `yaml:"exp_labels"`
+	ExpAnnotations map[string]string `yaml:"exp_annotations"`
+}
+
+// recordingTestCase checks the samples a recording rule produces at a time.
+// Unlike promtool, where the recorded series are queried back, the rule
+// itself is evaluated, as the database holds the series Prometheus recorded.
+type recordingTestCase struct {
+	Record     string        `yaml:"record"`
+	EvalTime   time.Duration `yaml:"eval_time"`
+	ExpSamples []sample      `yaml:"exp_samples"`
+}
+
+type promqlTestCase struct {
+	Expr       string        `yaml:"expr"`
+	EvalTime   time.Duration `yaml:"eval_time"`
+	ExpSamples []sample      `yaml:"exp_samples"`
+}
+
+type sample struct {
+	Labels string  `yaml:"labels"`
+	Value  float64 `yaml:"value"`
+}
+
+// loadTestFile reads a test file, resolving its rule files relative to it.
+func loadTestFile(filename string) (*testFile, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	tf := &testFile{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err = dec.Decode(tf); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	if tf.EvaluationInterval == 0 {
+		tf.EvaluationInterval = time.Minute
+	}
+	if tf.EvaluationInterval < 0 {
+		return nil, fmt.Errorf("%s: evaluation_interval must be positive", filename)
+	}
+
+	var ruleFiles []string
+	for _, rf := range tf.RuleFiles {
+		if !filepath.IsAbs(rf) {
+			rf = filepath.Join(filepath.Dir(filename), rf)
+		}
+		matches, err := filepath.Glob(rf)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: no rule file matches %s", filename, rf)
+		}
+		ruleFiles = append(ruleFiles, matches...)
+	}
+	tf.RuleFiles = ruleFiles
+
+	for _, tg := range tf.Tests {
+		if tg.Start.IsZero() {
+			return nil, fmt.Errorf("%s: test %q has no start", filename, tg.Name)
+		}
+	}
+	return tf, nil
+}
+
+// testGroup runs the tests of a group with freshly loaded rules, so that
+// the state of the alerts does not leak from a group to the next.
+func (r *runner) testGroup(ctx context.Context, tg testGroup, evalInterval time.Duration, ruleFiles []string) []error {
+	queryFunc := rules.EngineQueryFunc(r.engine, r.queryable)
+	m := rules.NewManager(&rules.ManagerOptions{
+		QueryFunc:  queryFunc,
+		Appendable: discardAppendable{},
+		Context:    ctx,
+		NotifyFunc: func(context.Context, string, ...*rules.Alert) {},
+		Logger:     kitlog.NewNopLogger(),
+	})
+	groupsMap, errs := m.LoadGroups(evalInterval, labels.FromMap(tg.ExternalLabels), ruleFiles...)
+	if errs != nil {
+		return errs
+	}
+	groups := make([]*rules.Group, 0, len(groupsMap))
+	for _, g := range groupsMap {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].File() != groups[j].File() {
+			return groups[i].File() < groups[j].File()
+		}
+		return groups[i].Name() < groups[j].Name()
+	})
+
+	errs = append(errs, tg.testAlerts(ctx, groups, evalInterval)...)
+	for _, tc := range tg.RecordingRuleTests {
+		if err := tg.testRecordingRule(ctx, groups, queryFunc, tc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, tc := range tg.PromqlExprTests {
+		got, err := queryFunc(ctx, tc.Expr, tg.Start.Add(tc.EvalTime))
+		if err == nil {
+			err = compareSamples(tc.ExpSamples, got)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("    %s: expr: %q, time: %s, %w", tg.Name, tc.Expr, tc.EvalTime, err))
+		}
+	}
+	return errs
+}
+
+// testAlerts evaluates the groups with alerting rules at every evaluation
+// interval from the start of the group to the last alert test, and checks
+// each test against the last evaluation at or before its time.
+func (tg *testGroup) testAlerts(ctx context.Context, groups []*rules.Group, evalInterval time.Duration) []error {
+	tests := append([]alertTestCase(nil), tg.AlertRuleTests...)
+	sort.SliceStable(tests, func(i, j int) bool { return tests[i].EvalTime < tests[j].EvalTime })
+
+	var errs []error
+	next := 0
+	for ts := tg.Start; next < len(tests); ts = ts.Add(evalInterval) {
+		for _, g := range groups {
+			if !g.HasAlertingRules() {
+				continue
+			}
+			g.Eval(ctx, ts)
+			for _, rule := range g.Rules() {
+				if err := rule.LastError(); err != nil {
+					return append(errs, fmt.Errorf("    %s: rule: %s, time: %s, err: %v", tg.Name, rule.Name(), ts.Sub(tg.Start), err))
+				}
+			}
+		}
+		for ; next < len(tests) && tests[next].EvalTime < ts.Add(evalInterval).Sub(tg.Start); next++ {
+			if err := checkAlerts(groups, tests[next]); err != nil {
+				errs = append(errs, fmt.Errorf("    %s: alertname: %s, time: %s, %w", tg.Name, tests[next].Alertname, tests[next].EvalTime, err))
+			}
+		}
+	}
+	return errs
+}
+
+func checkAlerts(groups []*rules.Group, tc alertTestCase) error {
+	var got []string
+	for _, g := range groups {
+		for _, ar := range g.AlertingRules() {
+			if ar.Name() != tc.Alertname {
+				continue
+			}
+			for _, a := range ar.ActiveAlerts() {
+				if a.State == rules.StateFiring {
+					got = append(got, alertString(a.Labels, a.Annotations))
+				}
+			}
+		}
+	}
+
+	var exp []string
+	for _, a := range tc.ExpAlerts {
+		// The alertname label is added by the rule, not in the tests.
+		lset := labels.NewBuilder(labels.FromMap(a.ExpLabels)).Set(labels.AlertName, tc.Alertname).Labels()
+		exp = append(exp, alertString(lset, labels.FromMap(a.ExpAnnotations)))
+	}
+	return compare(exp, got)
+}
+
+func alertString(lset, annotations labels.Labels) string {
+	return "Labels:" + lset.String() + " Annotations:" + annotations.String()
+}
+
+// testRecordingRule evaluates the recording rules recording tc.Record.
+func (tg *testGroup) testRecordingRule(ctx context.Context, groups []*rules.Group, queryFunc rules.QueryFunc, tc recordingTestCase) error {
+	var (
+		got   promql.Vector
+		found bool
+	)
+	for _, g := range groups {
+		for _, rule := range g.Rules() {
+			rr, ok := rule.(*rules.RecordingRule)
+			if !ok || rr.Name() != tc.Record {
+				continue
+			}
+			found = true
+			v, err := rr.Eval(ctx, tg.Start.Add(tc.EvalTime), queryFunc, nil)
+			if err != nil {
+				return fmt.Errorf("    %s: record: %s, time: %s, %w", tg.Name, tc.Record, tc.EvalTime, err)
+			}
+			got = append(got, v...)
+		}
+	}
+	if !found {
+		return fmt.Errorf("    %s: record: %s, no recording rule records it", tg.Name, tc.Record)
+	}
+	if err := compareSamples(tc.ExpSamples, got); err != nil {
+		return fmt.Errorf("    %s: record: %s, time: %s, %w", tg.Name, tc.Record, tc.EvalTime, err)
+	}
+	return nil
+}
+
+// compareSamples compares the samples regardless of their order.
+func compareSamples(expSamples []sample, got promql.Vector) error {
+	exp := make([]string, 0, len(expSamples))
+	for _, s := range expSamples {
+		lset, err := parser.ParseMetric(s.Labels)
+		if err != nil {
+			return fmt.Errorf("labels %q: %w", s.Labels, err)
+		}
+		exp = append(exp, sampleString(lset, s.Value))
+	}
+	gotSamples := make([]string, 0, len(got))
+	for _, s := range got {
+		gotSamples = append(gotSamples, sampleString(s.Metric, s.V))
+	}
+	return compare(exp, gotSamples)
+}
+
+func sampleString(lset labels.Labels, v float64) string {
+	return lset.String() + " " + strconv.FormatFloat(v, 'E', -1, 64)
+}
+
+func compare(exp, got []string) error {
+	sort.Strings(exp)
+	sort.Strings(got)
+	if strings.Join(exp, "\n") != strings.Join(got, "\n") {
+		return fmt.Errorf("\n        exp: %s\n        got: %s", formatList(exp), formatList(got))
+	}
+	return nil
+}
+
+func formatList(l []string) string {
+	if len(l) == 0 {
+		return "[]"
+	}
+	return "[" + strings.Join(l, ", ") + "]"
+}
@@ -0,0 +1,253 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// mockReader answers the read requests from fixed series.
+type mockReader struct {
+	series  []prompb.TimeSeries
+	queries int
+}
+
+func (m *mockReader) Read(_ context.Context, req *prompb.ReadRequest) (*prompb.ReadResponse, error) {
+	resp := &prompb.ReadResponse{}
+	for _, q := range req.Queries {
+		m.queries++
+		res := &prompb.QueryResult{}
+		for i := range m.series {
+			ts := m.series[i]
+			if !matches(ts.Labels, q.Matchers) {
+				continue
+			}
+			result := &prompb.TimeSeries{Labels: ts.Labels}
+			for _, s := range ts.Samples {
+				if s.Timestamp >= q.StartTimestampMs && s.Timestamp <= q.EndTimestampMs {
+					result.Samples = append(result.Samples, s)
+				}
+			}
+			res.Timeseries = append(res.Timeseries, result)
+		}
+		resp.Results = append(resp.Results, res)
+	}
+	return resp, nil
+}
+
+func matches(ls []prompb.Label, matchers []*prompb.LabelMatcher) bool {
+	for _, m := range matchers {
+		value := ""
+		for _, l := range ls {
+			if l.Name == m.Name {
+				value = l.Value
+			}
+		}
+		var ok bool
+		switch m.Type {
+		case prompb.LabelMatcher_EQ:
+			ok = value == m.Value
+		case prompb.LabelMatcher_NEQ:
+			ok = value != m.Value
+		case prompb.LabelMatcher_RE:
+			ok = regexp.MustCompile("^(?:" + m.Value + ")$").MatchString(value)
+		case prompb.LabelMatcher_NRE:
+			ok = !regexp.MustCompile("^(?:" + m.Value + ")$").MatchString(value)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+var start = time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+
+// requests returns a counter increasing by perMinute every minute of the
+// first hour after start.
+func requests(job string, perMinute float64) prompb.TimeSeries {
+	ts := prompb.TimeSeries{Labels: []prompb.Label{{Name: "__name__", Value: "http_requests_total"}, {Name: "job", Value: job}}}
+	for i := 0; i <= 60; i++ {
+		ts.Samples = append(ts.Samples, prompb.Sample{
+			Timestamp: start.Add(time.Duration(i)*time.Minute).UnixNano() / int64(time.Millisecond),
+			Value:     float64(i) * perMinute,
+		})
+	}
+	return ts
+}
+
+const rulesFile = `
+groups:
+- name: requests
+  rules:
+  - record: job:http_requests:rate5m
+    expr: sum by (job) (rate(http_requests_total[5m]))
+    labels:
+      source: rules
+  - alert: HighRequestRate
+    expr: sum by (job) (rate(http_requests_total[5m])) > 2
+    for: 10m
+    labels:
+      severity: page
+    annotations:
+      summary: '{{ $labels.job }} serves {{ $value }} requests per second'
+`
+
+const testsFile = `
+rule_files:
+- rules.yml
+evaluation_interval: 1m
+tests:
+- name: requests
+  start: 2020-05-01T00:00:00Z
+  promql_expr_test:
+  - expr: sum(rate(http_requests_total[5m]))
+    eval_time: 30m
+    exp_samples:
+    - labels: '{}'
+      value: 3.5
+  recording_rule_test:
+  - record: job:http_requests:rate5m
+    eval_time: 30m
+    exp_samples:
+    - labels: 'job:http_requests:rate5m{job="api", source="rules"}'
+      value: 3
+    - labels: 'job:http_requests:rate5m{job="batch", source="rules"}'
+      value: 0.5
+  alert_rule_test:
+  - alertname: HighRequestRate
+    eval_time: 10m
+  - alertname: HighRequestRate
+    eval_time: 15m
+    exp_alerts:
+    - exp_labels:
+        job: api
+        severity: page
+      exp_annotations:
+        summary: api serves 3 requests per second
+`
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "rules-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunFile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		tests   string
+		success bool
+		output  string
+	}{
+		{
+			name:    "passing",
+			tests:   testsFile,
+			success: true,
+			output:  "SUCCESS",
+		},
+		{
+			name:   "wrong value",
+			tests:  strings.Replace(testsFile, "value: 3.5", "value: 4", 1),
+			output: `expr: "sum(rate(http_requests_total[5m]))", time: 30m0s, ` + "\n        exp: [{} 4E+00]\n        got: [{} 3.5E+00]",
+		},
+		{
+			name:   "wrong recorded labels",
+			tests:  strings.Replace(testsFile, `source="rules"}'`+"\n      value: 3", `source="prometheus"}'`+"\n      value: 3", 1),
+			output: "record: job:http_requests:rate5m, time: 30m0s",
+		},
+		{
+			name:   "unknown record",
+			tests:  strings.Replace(testsFile, "record: job:http_requests:rate5m", "record: job:http_requests:rate1m", 1),
+			output: "record: job:http_requests:rate1m, no recording rule records it",
+		},
+		{
+			name:   "alert not firing before its for duration",
+			tests:  strings.Replace(testsFile, "eval_time: 15m", "eval_time: 11m", 1),
+			output: "alertname: HighRequestRate, time: 11m0s",
+		},
+		{
+			name:   "no start",
+			tests:  strings.Replace(testsFile, "  start: 2020-05-01T00:00:00Z\n", "", 1),
+			output: `test "requests" has no start`,
+		},
+		{
+			name:   "unknown field",
+			tests:  strings.Replace(testsFile, "evaluation_interval", "eval_interval", 1),
+			output: "field eval_interval not found",
+		},
+		{
+			name:   "missing rule file",
+			tests:  strings.Replace(testsFile, "rules.yml", "missing.yml", 1),
+			output: "no rule file matches",
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"rules.yml": rulesFile, "tests.yml": c.tests})
+			defer os.RemoveAll(dir)
+
+			reader := &mockReader{series: []prompb.TimeSeries{requests("api", 180), requests("batch", 30)}}
+			r := newRunner(&config{lookbackDelta: 5 * time.Minute, queryTimeout: time.Minute, maxSamples: 1000000}, readerQueryable{reader: reader})
+			var buf bytes.Buffer
+			success := r.runFile(context.Background(), &buf, filepath.Join(dir, "tests.yml"))
+			if success != c.success {
+				t.Errorf("unexpected success %v: %s", success, buf.String())
+			}
+			if !strings.Contains(buf.String(), c.output) {
+				t.Errorf("output does not contain %q:\n%s", c.output, buf.String())
+			}
+		})
+	}
+}
+
+func TestReaderQueryable(t *testing.T) {
+	reader := &mockReader{series: []prompb.TimeSeries{requests("api", 180), requests("batch", 30)}}
+	r := newRunner(&config{lookbackDelta: 5 * time.Minute, queryTimeout: time.Minute, maxSamples: 1000000}, readerQueryable{reader: reader})
+
+	q, err := r.engine.NewInstantQuery(r.queryable, `http_requests_total{job=~"b.*"}`, start.Add(90*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := q.Exec(context.Background())
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	// The last sample is an hour after start, out of the lookback delta.
+	if res.String() != "" {
+		t.Errorf("unexpected result: %s", res)
+	}
+
+	q, err = r.engine.NewInstantQuery(r.queryable, `http_requests_total{job=~"b.*"}`, start.Add(62*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res = q.Exec(context.Background())
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	if exp := `{__name__="http_requests_total", job="batch"} => 1800 @[1588294920000]`; res.String() != exp {
+		t.Errorf("unexpected result: got %s, expected %s", res, exp)
+	}
+	if reader.queries != 2 {
+		t.Errorf("unexpected number of read requests: %d", reader.queries)
+	}
+}
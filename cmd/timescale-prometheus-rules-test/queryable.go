@@ -0,0 +1,132 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"context"
+	"sort"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// readerQueryable exposes the stored series to the PromQL engine, reading
+// them with the same read requests Prometheus sends the connector.
+type readerQueryable struct {
+	reader pgmodel.Reader
+}
+
+func (q readerQueryable) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+	return &readerQuerier{ctx: ctx, reader: q.reader, mint: mint, maxt: maxt}, nil
+}
+
+type readerQuerier struct {
+	ctx        context.Context
+	reader     pgmodel.Reader
+	mint, maxt int64
+}
+
+func (q *readerQuerier) Select(sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) (storage.SeriesSet, storage.Warnings, error) {
+	query := &prompb.Query{StartTimestampMs: q.mint, EndTimestampMs: q.maxt, Matchers: toLabelMatchers(matchers)}
+	if hints != nil {
+		query.StartTimestampMs, query.EndTimestampMs = hints.Start, hints.End
+	}
+	resp, err := q.reader.Read(q.ctx, &prompb.ReadRequest{Queries: []*prompb.Query{query}})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	set := &seriesSet{cur: -1}
+	for _, res := range resp.Results {
+		for _, ts := range res.Timeseries {
+			lset := make(labels.Labels, 0, len(ts.Labels))
+			for _, l := range ts.Labels {
+				lset = append(lset, labels.Label{Name: l.Name, Value: l.Value})
+			}
+			sort.Sort(lset)
+			points := make([]promql.Point, 0, len(ts.Samples))
+			for _, s := range ts.Samples {
+				points = append(points, promql.Point{T: s.Timestamp, V: s.Value})
+			}
+			set.series = append(set.series, promql.Series{Metric: lset, Points: points})
+		}
+	}
+	if sortSeries {
+		sort.Slice(set.series, func(i, j int) bool {
+			return labels.Compare(set.series[i].Metric, set.series[j].Metric) < 0
+		})
+	}
+	return set, nil, nil
+}
+
+// LabelValues is not needed to evaluate expressions.
+func (q *readerQuerier) LabelValues(string) ([]string, storage.Warnings, error) {
+	return nil, nil, nil
+}
+
+// LabelNames is not needed to evaluate expressions.
+func (q *readerQuerier) LabelNames() ([]string, storage.Warnings, error) {
+	return nil, nil, nil
+}
+
+func (q *readerQuerier) Close() error {
+	return nil
+}
+
+func toLabelMatchers(matchers []*labels.Matcher) []*prompb.LabelMatcher {
+	result := make([]*prompb.LabelMatcher, 0, len(matchers))
+	for _, m := range matchers {
+		var mtype prompb.LabelMatcher_Type
+		switch m.Type {
+		case labels.MatchEqual:
+			mtype = prompb.LabelMatcher_EQ
+		case labels.MatchNotEqual:
+			mtype = prompb.LabelMatcher_NEQ
+		case labels.MatchRegexp:
+			mtype = prompb.LabelMatcher_RE
+		case labels.MatchNotRegexp:
+			mtype = prompb.LabelMatcher_NRE
+		}
+		result = append(result, &prompb.LabelMatcher{Type: mtype, Name: m.Name, Value: m.Value})
+	}
+	return result
+}
+
+type seriesSet struct {
+	cur    int
+	series []promql.Series
+}
+
+func (s *seriesSet) Next() bool {
+	s.cur++
+	return s.cur < len(s.series)
+}
+
+func (s *seriesSet) At() storage.Series {
+	return promql.NewStorageSeries(s.series[s.cur])
+}
+
+func (s *seriesSet) Err() error {
+	return nil
+}
+
+// discardAppendable drops the samples of the recording rules evaluated
+// while stepping through the alerting rules: the tests read, never write,
+// the database.
+type discardAppendable struct{}
+
+func (discardAppendable) Appender() storage.Appender {
+	return discardAppender{}
+}
+
+type discardAppender struct{}
+
+func (discardAppender) Add(labels.Labels, int64, float64) (uint64, error) { return 0, nil }
+func (discardAppender) AddFast(uint64, int64, float64) error              { return nil }
+func (discardAppender) Commit() error                                     { return nil }
+func (discardAppender) Rollback() error                                   { return nil }
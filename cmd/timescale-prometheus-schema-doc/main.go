@@ -0,0 +1,215 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+// timescale-prometheus-schema-doc introspects the schema installed by the
+// connector and prints a Markdown report of its tables, views, functions,
+// relationships and per-metric objects.
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jamiealquiza/envy"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgclient"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/util"
+)
+
+type config struct {
+	pgmodelCfg pgclient.Config
+	output     string
+}
+
+func main() {
+	cfg := parseFlags()
+
+	pool, err := pgxpool.Connect(context.Background(), cfg.pgmodelCfg.GetConnectionStr())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot connect to the database:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+	defer pool.Close()
+
+	desc, err := pgmodel.NewSchemaDocReader(pool).Describe()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot introspect the schema:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+
+	out := os.Stdout
+	if cfg.output != "" {
+		out, err = os.Create(cfg.output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Cannot create the output file:", err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+
+	w := bufio.NewWriter(out)
+	render(w, desc)
+	if err := w.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot write the report:", err)
+		os.Exit(1)
+	}
+}
+
+func parseFlags() *config {
+	cfg := &config{}
+
+	pgclient.ParseFlags(&cfg.pgmodelCfg)
+
+	flag.StringVar(&cfg.output, "output", "", "File to write the Markdown report to. Defaults to standard output.")
+	envy.Parse("TS_PROM")
+	flag.Parse()
+
+	return cfg
+}
+
+func render(w io.Writer, desc *pgmodel.SchemaDescription) {
+	fmt.Fprintln(w, "# Timescale-Prometheus Schema Reference")
+	fmt.Fprintln(w)
+	version := desc.Version
+	if version == "" {
+		version = "unknown"
+	}
+	fmt.Fprintf(w, "Connector version: %s, migration version: %d", version, desc.MigrationVersion)
+	if desc.Dirty {
+		fmt.Fprint(w, " (dirty: the last migration did not complete)")
+	}
+	fmt.Fprintln(w)
+
+	renderRelationships(w, desc.Schemas)
+
+	for _, s := range desc.Schemas {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "## Schema `%s`\n\n", s.Name)
+		fmt.Fprintf(w, "The %s.\n", s.Purpose)
+
+		renderRelations(w, "Tables", s.Tables)
+		renderRelations(w, "Views", s.Views)
+		renderFunctions(w, s.Functions)
+	}
+
+	renderMetrics(w, desc.Metrics)
+}
+
+// renderRelationships lists the foreign keys between tables, the edges of
+// the entity-relationship diagram.
+func renderRelationships(w io.Writer, schemas []pgmodel.SchemaObjects) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "## Relationships")
+	fmt.Fprintln(w)
+
+	found := false
+	for _, s := range schemas {
+		for _, t := range s.Tables {
+			for _, c := range t.Constraints {
+				if !c.ForeignKey {
+					continue
+				}
+				found = true
+				fmt.Fprintf(w, "- `%s.%s`: %s\n", s.Name, t.Name, c.Definition)
+			}
+		}
+	}
+	if !found {
+		fmt.Fprintln(w, "No foreign keys are defined. Tables are related through the ids described below.")
+	}
+}
+
+func renderRelations(w io.Writer, title string, relations []pgmodel.RelationDoc) {
+	if len(relations) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "### %s\n", title)
+
+	for _, r := range relations {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "#### `%s`\n\n", r.Name)
+		if r.Comment != "" {
+			fmt.Fprintln(w, r.Comment)
+			fmt.Fprintln(w)
+		}
+
+		fmt.Fprintln(w, "| Column | Type | Nullable | Description |")
+		fmt.Fprintln(w, "|--------|------|----------|-------------|")
+		for _, c := range r.Columns {
+			nullable := "no"
+			if c.Nullable {
+				nullable = "yes"
+			}
+			fmt.Fprintf(w, "| %s | %s | %s | %s |\n", c.Name, escapeCell(c.Type), nullable, escapeCell(c.Comment))
+		}
+
+		if len(r.Constraints) > 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "Constraints:")
+			for _, c := range r.Constraints {
+				fmt.Fprintf(w, "- `%s`: %s\n", c.Name, c.Definition)
+			}
+		}
+	}
+}
+
+func renderFunctions(w io.Writer, functions []pgmodel.FunctionDoc) {
+	if len(functions) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "### Functions")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Name | Kind | Arguments | Returns | Description |")
+	fmt.Fprintln(w, "|------|------|-----------|---------|-------------|")
+	for _, f := range functions {
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n", f.Name, f.Kind, escapeCell(f.Arguments), escapeCell(f.Result), escapeCell(f.Comment))
+	}
+}
+
+func renderMetrics(w io.Writer, metrics []pgmodel.MetricObjects) {
+	schemas := pgmodel.PerMetricSchemas()
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "## Per-metric objects")
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Every metric gets a data hypertable in `%s`, a series partition in `%s`, "+
+		"a metric view in `%s` and a series view in `%s`, all named after the metric's table name.\n",
+		schemas[0], schemas[1], schemas[2], schemas[3])
+
+	if len(metrics) == 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "No metrics have been ingested yet.")
+		return
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "| Metric | Table name | %s | %s | %s | %s |\n", schemas[0], schemas[1], schemas[2], schemas[3])
+	fmt.Fprintln(w, "|--------|------------|---|---|---|---|")
+	for _, m := range metrics {
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s |\n", escapeCell(m.Metric), escapeCell(m.TableName),
+			present(m.DataTable), present(m.SeriesTable), present(m.MetricView), present(m.SeriesView))
+	}
+}
+
+func present(ok bool) string {
+	if ok {
+		return "yes"
+	}
+	return "**missing**"
+}
+
+// escapeCell makes a value safe to use inside a Markdown table cell.
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,84 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+func TestRender(t *testing.T) {
+	desc := &pgmodel.SchemaDescription{
+		MigrationVersion: 3,
+		Dirty:            true,
+		Version:          "0.1.0",
+		Schemas: []pgmodel.SchemaObjects{
+			{
+				Name:    "_prom_catalog",
+				Purpose: "catalog schema",
+				Tables: []pgmodel.RelationDoc{
+					{
+						Name:    "series",
+						Comment: "All the series",
+						Columns: []pgmodel.ColumnDoc{
+							{Name: "id", Type: "bigint"},
+							{Name: "labels", Type: "prom_api.label_array", Nullable: true, Comment: "label\nids | sorted"},
+						},
+						Constraints: []pgmodel.ConstraintDoc{
+							{Name: "series_pkey", Definition: "PRIMARY KEY (id)"},
+							{Name: "series_metric_fkey", ForeignKey: true, Definition: "FOREIGN KEY (metric_id) REFERENCES _prom_catalog.metric(id)"},
+						},
+					},
+				},
+				Functions: []pgmodel.FunctionDoc{
+					{Name: "get_metric_table_name_if_exists", Kind: "function", Arguments: "metric_name text", Result: "TABLE(id integer, table_name name)"},
+				},
+			},
+		},
+		Metrics: []pgmodel.MetricObjects{
+			{Metric: "cpu_usage", TableName: "cpu_usage", DataTable: true, SeriesTable: true, MetricView: true},
+		},
+	}
+
+	var buf bytes.Buffer
+	render(&buf, desc)
+	out := buf.String()
+
+	expected := []string{
+		"Connector version: 0.1.0, migration version: 3 (dirty",
+		"## Relationships",
+		"- `_prom_catalog.series`: FOREIGN KEY (metric_id) REFERENCES _prom_catalog.metric(id)",
+		"## Schema `_prom_catalog`",
+		"The catalog schema.",
+		"#### `series`\n\nAll the series\n",
+		"| id | bigint | no |  |",
+		`| labels | prom_api.label_array | yes | label ids \| sorted |`,
+		"- `series_pkey`: PRIMARY KEY (id)",
+		"| get_metric_table_name_if_exists | function | metric_name text | TABLE(id integer, table_name name) |  |",
+		"| cpu_usage | cpu_usage | yes | yes | yes | **missing** |",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Errorf("missing %q in report:\n%s", e, out)
+		}
+	}
+	if strings.Contains(out, "### Views") {
+		t.Errorf("unexpected empty views section in report:\n%s", out)
+	}
+}
+
+func TestRenderEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	render(&buf, &pgmodel.SchemaDescription{})
+	out := buf.String()
+
+	for _, e := range []string{"Connector version: unknown, migration version: 0", "No foreign keys are defined", "No metrics have been ingested yet."} {
+		if !strings.Contains(out, e) {
+			t.Errorf("missing %q in report:\n%s", e, out)
+		}
+	}
+}
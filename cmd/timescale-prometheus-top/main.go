@@ -0,0 +1,316 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+// timescale-prometheus-top shows a live-updating table of the top metrics by
+// ingest rate, series count and storage size. Series counts and sizes are read
+// from the database, ingest rates from the /ingest-stats endpoint of one or
+// more connectors.
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jamiealquiza/envy"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgclient"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/util"
+)
+
+const (
+	sortByRate   = "rate"
+	sortBySeries = "series"
+	sortBySize   = "size"
+
+	clearScreen = "\033[H\033[2J"
+)
+
+type config struct {
+	pgmodelCfg      pgclient.Config
+	connectorURLs   string
+	bearerTokenFile string
+	interval        time.Duration
+	limit           int
+	sortBy          string
+	once            bool
+	// token is the bearer token read from bearerTokenFile.
+	token string
+}
+
+// metricRow is a single line of the table.
+type metricRow struct {
+	name    string
+	rate    float64
+	hasRate bool
+	series  int64
+	bytes   int64
+}
+
+func main() {
+	cfg := parseFlags()
+	if cfg.bearerTokenFile != "" {
+		data, err := ioutil.ReadFile(cfg.bearerTokenFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Cannot read the bearer token:", err)
+			os.Exit(1)
+		}
+		cfg.token = strings.TrimSpace(string(data))
+	}
+
+	pool, err := pgxpool.Connect(context.Background(), cfg.pgmodelCfg.GetConnectionStr())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot connect to the database:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+	defer pool.Close()
+
+	if err := run(cfg, pgmodel.NewStatsReader(pool), &http.Client{Timeout: cfg.interval}, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+}
+
+func parseFlags() *config {
+	cfg := &config{}
+
+	pgclient.ParseFlags(&cfg.pgmodelCfg)
+
+	flag.StringVar(&cfg.connectorURLs, "connector-url", "http://localhost:9201", "Comma-separated base URLs of the connectors to read ingest rates from. Leave empty to show database statistics only.")
+	flag.StringVar(&cfg.bearerTokenFile, "bearer-token-file", "", "File holding the bearer token sent to the connectors, for connectors with -auth-bearer-tokens-file. "+
+		"Basic authentication credentials can be given in -connector-url instead.")
+	flag.DurationVar(&cfg.interval, "refresh-interval", 2*time.Second, "Interval at which the table is refreshed.")
+	flag.IntVar(&cfg.limit, "limit", 20, "Number of metrics to show (0 means all).")
+	flag.StringVar(&cfg.sortBy, "sort", sortByRate, "Column to sort by [ \""+sortByRate+"\", \""+sortBySeries+"\", \""+sortBySize+"\" ].")
+	flag.BoolVar(&cfg.once, "once", false, "Print the table once and exit. Ingest rates are measured over a single refresh interval.")
+	envy.Parse("TS_PROM")
+	flag.Parse()
+
+	return cfg
+}
+
+type statsReader interface {
+	MetricStats() ([]pgmodel.MetricStats, error)
+}
+
+func run(cfg *config, reader statsReader, client *http.Client, out io.Writer) error {
+	if err := validateSort(cfg.sortBy); err != nil {
+		return err
+	}
+	urls := splitURLs(cfg.connectorURLs)
+
+	ticker := time.NewTicker(cfg.interval)
+	defer ticker.Stop()
+
+	// Rates need two snapshots, so the first refresh shows none unless we
+	// wait for a full interval before printing.
+	var (
+		prev     map[string]uint64
+		prevTime time.Time
+	)
+	if cfg.once {
+		var err error
+		if prev, err = fetchIngestStats(client, urls, cfg.token); err != nil {
+			return err
+		}
+		prevTime = time.Now()
+		<-ticker.C
+	}
+
+	for {
+		stats, err := reader.MetricStats()
+		if err != nil {
+			return err
+		}
+		cur, err := fetchIngestStats(client, urls, cfg.token)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+
+		rows := buildRows(stats, prev, cur, now.Sub(prevTime))
+		sortRows(rows, cfg.sortBy)
+
+		if !cfg.once {
+			fmt.Fprint(out, clearScreen)
+		}
+		if err := render(out, rows, cfg.limit, now); err != nil {
+			return err
+		}
+		if cfg.once {
+			return nil
+		}
+
+		prev, prevTime = cur, now
+		<-ticker.C
+	}
+}
+
+func validateSort(sortBy string) error {
+	switch sortBy {
+	case sortByRate, sortBySeries, sortBySize:
+		return nil
+	}
+	return fmt.Errorf("invalid sort column %q", sortBy)
+}
+
+func splitURLs(s string) []string {
+	urls := make([]string, 0)
+	for _, u := range strings.Split(s, ",") {
+		u = strings.TrimSpace(u)
+		if u != "" {
+			urls = append(urls, strings.TrimSuffix(u, "/"))
+		}
+	}
+	return urls
+}
+
+// fetchIngestStats sums the per-metric sample counters of all the connectors,
+// authenticating with token if not empty. It returns nil if no connector is
+// configured.
+func fetchIngestStats(client *http.Client, urls []string, token string) (map[string]uint64, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	total := make(map[string]uint64)
+	for _, u := range urls {
+		req, err := http.NewRequest(http.MethodGet, u+"/ingest-stats", nil)
+		if err != nil {
+			return nil, err
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var samples map[string]uint64
+		err = func() error {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("unexpected status %s from %s", resp.Status, u)
+			}
+			return json.NewDecoder(resp.Body).Decode(&samples)
+		}()
+		if err != nil {
+			return nil, err
+		}
+
+		for metric, n := range samples {
+			total[metric] += n
+		}
+	}
+	return total, nil
+}
+
+// buildRows merges the database statistics with the ingest rates computed
+// from two consecutive counter snapshots. Metrics that were ingested but are
+// not in the database yet are included as well.
+func buildRows(stats []pgmodel.MetricStats, prev, cur map[string]uint64, elapsed time.Duration) []metricRow {
+	rows := make([]metricRow, 0, len(stats))
+	seen := make(map[string]bool, len(stats))
+
+	rate := func(name string) (float64, bool) {
+		if prev == nil || cur == nil || elapsed <= 0 {
+			return 0, false
+		}
+		c := cur[name]
+		p := prev[name]
+		// A connector restart resets the counters.
+		if c < p {
+			return 0, false
+		}
+		return float64(c-p) / elapsed.Seconds(), true
+	}
+
+	for _, s := range stats {
+		seen[s.Name] = true
+		r, ok := rate(s.Name)
+		rows = append(rows, metricRow{name: s.Name, rate: r, hasRate: ok, series: s.SeriesCount, bytes: s.TotalBytes})
+	}
+	for name := range cur {
+		if seen[name] {
+			continue
+		}
+		r, ok := rate(name)
+		rows = append(rows, metricRow{name: name, rate: r, hasRate: ok})
+	}
+	return rows
+}
+
+func sortRows(rows []metricRow, sortBy string) {
+	less := func(i, j int) bool {
+		switch sortBy {
+		case sortBySeries:
+			if rows[i].series != rows[j].series {
+				return rows[i].series > rows[j].series
+			}
+		case sortBySize:
+			if rows[i].bytes != rows[j].bytes {
+				return rows[i].bytes > rows[j].bytes
+			}
+		default:
+			if rows[i].rate != rows[j].rate {
+				return rows[i].rate > rows[j].rate
+			}
+		}
+		return rows[i].name < rows[j].name
+	}
+	sort.Slice(rows, less)
+}
+
+func render(out io.Writer, rows []metricRow, limit int, now time.Time) error {
+	var totalRate float64
+	var totalSeries, totalBytes int64
+	for _, r := range rows {
+		totalRate += r.rate
+		totalSeries += r.series
+		totalBytes += r.bytes
+	}
+
+	fmt.Fprintf(out, "timescale-prometheus-top - %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(out, "Metrics: %d  Ingest: %.1f samples/s  Series: %d  Size: %s\n\n", len(rows), totalRate, totalSeries, formatBytes(totalBytes))
+
+	if limit > 0 && len(rows) > limit {
+		rows = rows[:limit]
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "METRIC\tSAMPLES/S\tSERIES\tSIZE\t")
+	for _, r := range rows {
+		rate := "-"
+		if r.hasRate {
+			rate = fmt.Sprintf("%.1f", r.rate)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t\n", r.name, rate, r.series, formatBytes(r.bytes))
+	}
+	return tw.Flush()
+}
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,158 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+type mockStatsReader struct {
+	stats []pgmodel.MetricStats
+	err   error
+}
+
+func (m *mockStatsReader) MetricStats() ([]pgmodel.MetricStats, error) {
+	return m.stats, m.err
+}
+
+func TestBuildRows(t *testing.T) {
+	stats := []pgmodel.MetricStats{
+		{Name: "first", SeriesCount: 10, TotalBytes: 2048},
+		{Name: "second", SeriesCount: 5, TotalBytes: 4096},
+	}
+	testCases := []struct {
+		name     string
+		prev     map[string]uint64
+		cur      map[string]uint64
+		sortBy   string
+		expected []metricRow
+	}{
+		{
+			name:   "no previous snapshot",
+			cur:    map[string]uint64{"first": 10},
+			sortBy: sortBySeries,
+			expected: []metricRow{
+				{name: "first", series: 10, bytes: 2048},
+				{name: "second", series: 5, bytes: 4096},
+			},
+		},
+		{
+			name:   "rates",
+			prev:   map[string]uint64{"first": 10, "second": 10},
+			cur:    map[string]uint64{"first": 30, "second": 50, "third": 4},
+			sortBy: sortByRate,
+			expected: []metricRow{
+				{name: "second", rate: 20, hasRate: true, series: 5, bytes: 4096},
+				{name: "first", rate: 10, hasRate: true, series: 10, bytes: 2048},
+				{name: "third", rate: 2, hasRate: true},
+			},
+		},
+		{
+			name:   "counter reset",
+			prev:   map[string]uint64{"first": 10},
+			cur:    map[string]uint64{"first": 2},
+			sortBy: sortBySize,
+			expected: []metricRow{
+				{name: "second", hasRate: true, series: 5, bytes: 4096},
+				{name: "first", series: 10, bytes: 2048},
+			},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			rows := buildRows(stats, c.prev, c.cur, 2*time.Second)
+			sortRows(rows, c.sortBy)
+			if !reflect.DeepEqual(rows, c.expected) {
+				t.Errorf("unexpected rows:\ngot\n%+v\nwanted\n%+v", rows, c.expected)
+			}
+		})
+	}
+}
+
+func TestFetchIngestStats(t *testing.T) {
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ingest-stats" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"first": 1, "second": 2}`)
+	}))
+	defer first.Close()
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"first": 3}`)
+	}))
+	defer second.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	got, err := fetchIngestStats(http.DefaultClient, splitURLs(first.URL+"/, "+second.URL), "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]uint64{"first": 4, "second": 2}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected ingest stats: got %v, wanted %v", got, expected)
+	}
+
+	if _, err := fetchIngestStats(http.DefaultClient, []string{broken.URL}, ""); err == nil {
+		t.Error("expected an error for a failing connector")
+	}
+	if _, err := fetchIngestStats(http.DefaultClient, []string{first.URL}, ""); err == nil {
+		t.Error("expected an error without the bearer token")
+	}
+
+	if got, err := fetchIngestStats(http.DefaultClient, splitURLs(""), ""); err != nil || got != nil {
+		t.Errorf("unexpected result without connectors: %v, %v", got, err)
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	cfg := &config{interval: time.Millisecond, limit: 1, sortBy: sortBySize, once: true}
+	reader := &mockStatsReader{stats: []pgmodel.MetricStats{
+		{Name: "small", SeriesCount: 1, TotalBytes: 10},
+		{Name: "large", SeriesCount: 2, TotalBytes: 3 * 1024 * 1024},
+	}}
+
+	var out bytes.Buffer
+	if err := run(cfg, reader, http.DefaultClient, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	s := out.String()
+	if !strings.Contains(s, "Metrics: 2") || !strings.Contains(s, "3.0 MiB") {
+		t.Errorf("unexpected output:\n%s", s)
+	}
+	if strings.Contains(s, "small") {
+		t.Errorf("limit not applied:\n%s", s)
+	}
+	if strings.Contains(s, clearScreen) {
+		t.Errorf("screen cleared in once mode:\n%s", s)
+	}
+
+	reader.err = fmt.Errorf("some error")
+	if err := run(cfg, reader, http.DefaultClient, &out); err != reader.err {
+		t.Errorf("unexpected error: got %v, wanted %v", err, reader.err)
+	}
+
+	cfg.sortBy = "nope"
+	if err := run(cfg, reader, http.DefaultClient, &out); err == nil {
+		t.Error("expected an error for an invalid sort column")
+	}
+}
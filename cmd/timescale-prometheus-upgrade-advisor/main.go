@@ -0,0 +1,118 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+// timescale-prometheus-upgrade-advisor inspects the database before an
+// upgrade: the installed PostgreSQL, TimescaleDB, extension and schema
+// versions, the migrations this binary would apply, their breaking changes
+// and an estimate of how long they take.
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jamiealquiza/envy"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgclient"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/util"
+)
+
+func main() {
+	cfg := parseFlags()
+
+	pool, err := pgxpool.Connect(context.Background(), cfg.GetConnectionStr())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot connect to the database:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+	defer pool.Close()
+
+	advice, err := pgmodel.NewUpgradeAdvisor(pool).Advise()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot inspect the database:", util.MaskPassword(err.Error()))
+		os.Exit(1)
+	}
+
+	render(os.Stdout, advice)
+}
+
+func parseFlags() *pgclient.Config {
+	cfg := &pgclient.Config{}
+
+	pgclient.ParseFlags(cfg)
+	envy.Parse("TS_PROM")
+	flag.Parse()
+
+	return cfg
+}
+
+func render(w io.Writer, advice *pgmodel.UpgradeAdvice) {
+	fmt.Fprintln(w, "Installed versions:")
+	fmt.Fprintf(w, "  PostgreSQL:                 %s\n", advice.PostgresVersion)
+	fmt.Fprintf(w, "  TimescaleDB:                %s\n", orNone(advice.TimescaleDBVersion))
+	fmt.Fprintf(w, "  timescale_prometheus_extra: %s\n", orNone(advice.ExtraExtensionVersion))
+	fmt.Fprintf(w, "  Connector:                  %s\n", orNone(advice.ConnectorVersion))
+	schema := "not installed"
+	if advice.Installed {
+		schema = fmt.Sprintf("%d", advice.InstalledVersion)
+		if advice.Dirty {
+			schema += " (dirty)"
+		}
+	}
+	fmt.Fprintf(w, "  Schema:                     %s, this binary migrates to %d\n", schema, advice.TargetVersion)
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Catalog size: %s, data size: %s, running connectors: %d\n",
+		formatBytes(advice.CatalogBytes), formatBytes(advice.DataBytes), advice.ActiveConnectors)
+
+	fmt.Fprintln(w)
+	if len(advice.Pending) == 0 {
+		fmt.Fprintln(w, "The schema is up to date, no migration is needed.")
+	} else {
+		fmt.Fprintf(w, "Pending migrations (estimated duration %s):\n", advice.EstimatedDuration.Round(time.Second))
+		for _, m := range advice.Pending {
+			fmt.Fprintf(w, "  %d %s (~%s)\n", m.Version, m.Name, m.EstimatedDuration.Round(time.Second))
+			if m.Summary != "" {
+				fmt.Fprintf(w, "      %s\n", m.Summary)
+			}
+			for _, b := range m.BreakingChanges {
+				fmt.Fprintf(w, "      BREAKING: %s\n", b)
+			}
+		}
+	}
+
+	if len(advice.Warnings) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Warnings:")
+		for _, warning := range advice.Warnings {
+			fmt.Fprintf(w, "  - %s\n", warning)
+		}
+	}
+}
+
+func orNone(version string) string {
+	if version == "" {
+		return "not installed"
+	}
+	return version
+}
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,73 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+func TestRender(t *testing.T) {
+	advice := &pgmodel.UpgradeAdvice{
+		PostgresVersion:    "12.3",
+		TimescaleDBVersion: "1.7.1",
+		ConnectorVersion:   "0.1.0-beta.1",
+		Installed:          true,
+		InstalledVersion:   2,
+		TargetVersion:      3,
+		CatalogBytes:       10 << 20,
+		DataBytes:          3 << 30,
+		ActiveConnectors:   2,
+		Pending: []pgmodel.PendingMigration{
+			{
+				Version:           3,
+				Name:              "bulk_series_ids",
+				Summary:           "Adds a function.",
+				BreakingChanges:   []string{"Old connectors break."},
+				EstimatedDuration: time.Second,
+			},
+		},
+		EstimatedDuration: time.Second,
+		Warnings:          []string{"The timescale_prometheus_extra extension is not installed."},
+	}
+
+	var buf bytes.Buffer
+	render(&buf, advice)
+	out := buf.String()
+
+	expected := []string{
+		"TimescaleDB:                1.7.1",
+		"timescale_prometheus_extra: not installed",
+		"Schema:                     2, this binary migrates to 3",
+		"Catalog size: 10.0 MiB, data size: 3.0 GiB, running connectors: 2",
+		"Pending migrations (estimated duration 1s):",
+		"  3 bulk_series_ids (~1s)",
+		"      BREAKING: Old connectors break.",
+		"  - The timescale_prometheus_extra extension is not installed.",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Errorf("missing %q in report:\n%s", e, out)
+		}
+	}
+}
+
+func TestRenderUpToDate(t *testing.T) {
+	var buf bytes.Buffer
+	render(&buf, &pgmodel.UpgradeAdvice{Installed: true, InstalledVersion: 3, Dirty: true, TargetVersion: 3})
+	out := buf.String()
+
+	for _, e := range []string{"Schema:                     3 (dirty)", "no migration is needed"} {
+		if !strings.Contains(out, e) {
+			t.Errorf("missing %q in report:\n%s", e, out)
+		}
+	}
+	if strings.Contains(out, "Warnings:") {
+		t.Errorf("unexpected warnings in report:\n%s", out)
+	}
+}
@@ -0,0 +1,282 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+const (
+	authFailureMissing   = "missing"
+	authFailureInvalid   = "invalid"
+	authFailureForbidden = "forbidden"
+)
+
+// authConfig configures the authentication of the write and read endpoints.
+type authConfig struct {
+	bearerTokensFile string
+	htpasswdFile     string
+	adminUsers       string
+	adminTokensFile  string
+}
+
+// authenticator verifies the credentials of the requests to the write and
+// read endpoints: a static bearer token, or a user and password checked
+// against an htpasswd file.
+type authenticator struct {
+	tokens [][]byte
+	// adminTokens are the bearer tokens of the admins.
+	adminTokens [][]byte
+	users       map[string][]byte
+	// admins are the users allowed on the admin API, whose reads are not
+	// redacted.
+	admins map[string]bool
+	// dummyHash is compared against the password of unknown users, so that
+	// they take as long to reject as known users with a wrong password.
+	dummyHash []byte
+}
+
+// newAuthenticator loads the configured credentials. It returns nil when no
+// authentication is configured.
+func newAuthenticator(cfg authConfig) (*authenticator, error) {
+	if cfg.adminUsers != "" && cfg.htpasswdFile == "" {
+		return nil, fmt.Errorf("admin users require -auth-htpasswd-file to authenticate them")
+	}
+	if cfg.bearerTokensFile == "" && cfg.htpasswdFile == "" && cfg.adminTokensFile == "" {
+		return nil, nil
+	}
+
+	a := &authenticator{}
+	var err error
+	if cfg.bearerTokensFile != "" {
+		if a.tokens, err = readTokens(cfg.bearerTokensFile); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.adminTokensFile != "" {
+		if a.adminTokens, err = readTokens(cfg.adminTokensFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.htpasswdFile != "" {
+		lines, err := readCredentialLines(cfg.htpasswdFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the htpasswd file: %w", err)
+		}
+		a.users = make(map[string][]byte, len(lines))
+		for i, l := range lines {
+			parts := strings.SplitN(l, ":", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return nil, fmt.Errorf("%s:%d: expected user:hash", cfg.htpasswdFile, i+1)
+			}
+			hash := []byte(parts[1])
+			if _, err := bcrypt.Cost(hash); err != nil {
+				return nil, fmt.Errorf("%s:%d: only bcrypt hashes are supported (htpasswd -B): %w", cfg.htpasswdFile, i+1, err)
+			}
+			a.users[parts[0]] = hash
+		}
+		if len(a.users) == 0 {
+			return nil, fmt.Errorf("no user found in %s", cfg.htpasswdFile)
+		}
+		for _, hash := range a.users {
+			cost, _ := bcrypt.Cost(hash)
+			if a.dummyHash, err = bcrypt.GenerateFromPassword([]byte("dummy"), cost); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	for _, user := range strings.Split(cfg.adminUsers, ",") {
+		user = strings.TrimSpace(user)
+		if user == "" {
+			continue
+		}
+		if _, known := a.users[user]; !known {
+			return nil, fmt.Errorf("admin user %q not found in %s", user, cfg.htpasswdFile)
+		}
+		if a.admins == nil {
+			a.admins = make(map[string]bool)
+		}
+		a.admins[user] = true
+	}
+	return a, nil
+}
+
+// adminAPI registers the admin endpoints, which report or change the stored
+// data and the connector. They are only served once enabled with
+// -web-enable-admin-api, behind the authentication if configured.
+type adminAPI struct {
+	enabled bool
+	auth    *authenticator
+}
+
+// handle registers handler on path of mux if the admin API is enabled. With
+// authentication configured, only the admins are allowed.
+func (a adminAPI) handle(mux *http.ServeMux, path, name string, handler http.Handler) {
+	if !a.enabled {
+		return
+	}
+	mux.Handle(path, a.auth.wrap(name, a.auth.requireAdmin(name, handler)))
+}
+
+// readTokens returns the bearer tokens listed in a file.
+func readTokens(path string) ([][]byte, error) {
+	lines, err := readCredentialLines(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the bearer tokens: %w", err)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no bearer token found in %s", path)
+	}
+	tokens := make([][]byte, 0, len(lines))
+	for _, l := range lines {
+		tokens = append(tokens, []byte(l))
+	}
+	return tokens, nil
+}
+
+// readCredentialLines returns the non-empty lines of a file, ignoring
+// comments starting with #.
+func readCredentialLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		l := strings.TrimSpace(scanner.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return lines, scanner.Err()
+}
+
+// verify checks the credentials of the request, returning the user they
+// authenticate, if any, and whether they are those of an admin, or the
+// reason of the failure if they are not accepted.
+func (a *authenticator) verify(r *http.Request) (user string, admin, ok bool, reason string) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", false, false, authFailureMissing
+	}
+
+	if token := strings.TrimPrefix(header, "Bearer "); token != header && (a.tokens != nil || a.adminTokens != nil) {
+		// Compare against every token, so that the time taken does not
+		// depend on which one matched.
+		match, adminMatch := 0, 0
+		for _, t := range a.tokens {
+			match |= subtle.ConstantTimeCompare(t, []byte(token))
+		}
+		for _, t := range a.adminTokens {
+			adminMatch |= subtle.ConstantTimeCompare(t, []byte(token))
+		}
+		return "", adminMatch == 1, match|adminMatch == 1, authFailureInvalid
+	}
+
+	if user, password, isBasic := r.BasicAuth(); isBasic && a.users != nil {
+		hash, known := a.users[user]
+		if !known {
+			_ = bcrypt.CompareHashAndPassword(a.dummyHash, []byte(password))
+			return "", false, false, authFailureInvalid
+		}
+		if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+			return "", false, false, authFailureInvalid
+		}
+		return user, a.admins[user], true, ""
+	}
+	return "", false, false, authFailureInvalid
+}
+
+// wrap rejects the requests to handler without valid credentials, counting
+// the failures by path and reason. The user authenticated with basic
+// authentication is the tenant of the request. The requests of the admins
+// read without redaction. A nil authenticator accepts all requests.
+func (a *authenticator) wrap(path string, handler http.Handler) http.Handler {
+	if a == nil {
+		return handler
+	}
+	challenge := make([]string, 0, 2)
+	if a.tokens != nil || a.adminTokens != nil {
+		challenge = append(challenge, `Bearer realm="timescale-prometheus"`)
+	}
+	if a.users != nil {
+		challenge = append(challenge, `Basic realm="timescale-prometheus"`)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, admin, ok, reason := a.verify(r)
+		if !ok {
+			authFailures.WithLabelValues(path, reason).Inc()
+			for _, c := range challenge {
+				w.Header().Add("WWW-Authenticate", c)
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		ctx := r.Context()
+		if user != "" {
+			ctx = pgmodel.WithTenant(ctx, user)
+		}
+		if admin {
+			ctx = pgmodel.WithAdmin(ctx)
+		}
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireAdmin rejects with a 403 response the requests to handler that wrap
+// did not authenticate as those of an admin, counting them by path. A nil
+// authenticator accepts all requests.
+func (a *authenticator) requireAdmin(path string, handler http.Handler) http.Handler {
+	if a == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !pgmodel.IsAdmin(r.Context()) {
+			authFailures.WithLabelValues(path, authFailureForbidden).Inc()
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// verifyGRPC checks the credentials of a gRPC call, sent in its authorization
+// metadata as in the Authorization header of the HTTP requests, counting the
+// failures by path and reason. A nil authenticator accepts all calls.
+func (a *authenticator) verifyGRPC(ctx context.Context, path string) error {
+	if a == nil {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	r := &http.Request{Header: make(http.Header)}
+	for _, v := range md.Get("authorization") {
+		r.Header.Add("Authorization", v)
+	}
+	if _, _, ok, reason := a.verify(r); !ok {
+		authFailures.WithLabelValues(path, reason).Inc()
+		return status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	return nil
+}
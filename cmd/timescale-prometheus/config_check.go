@@ -0,0 +1,95 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+// configProblems returns the settings of cfg which are valid, but likely to
+// silently degrade the connector, each with an explanation.
+func configProblems(cfg *config) []string {
+	problems := make([]string, 0)
+	if cfg.pgmodelCfg.AcksBeforeWrite() && cfg.pgmodelCfg.ReportInterval <= 0 {
+		problems = append(problems, "-async-acks acknowledges writes before they are stored, so Prometheus never retries the failed ones. "+
+			"Set -tput-report to report how many samples are actually stored, or use -ack-mode=flush.")
+	}
+	if (cfg.auth.bearerTokensFile != "" || cfg.auth.htpasswdFile != "") && !cfg.tls.enabled() {
+		problems = append(problems, "Authentication is enabled without TLS, so the credentials are sent in clear text. "+
+			"Set -web-tls-cert-file and -web-tls-key-file.")
+	}
+	if cfg.limits.maxSeries > int64(cfg.pgmodelCfg.SeriesCacheSize) {
+		problems = append(problems, fmt.Sprintf("-write-max-series allows %d series, more than the %d of -series-cache-size. "+
+			"Inserts will look the evicted series up in the database again.", cfg.limits.maxSeries, cfg.pgmodelCfg.SeriesCacheSize))
+	}
+	if cfg.pgmodelCfg.HADedup && (cfg.haGroupLockID != 0 || cfg.restElection) {
+		problems = append(problems, "-ha-dedup and leader election both pick the HA Prometheus replica whose samples are stored, "+
+			"and a connector which is not the leader drops the samples -ha-dedup would keep. Use only one of them.")
+	}
+	priorities := cfg.pgmodelCfg.Priorities
+	if (priorities.Critical != "" || priorities.Low != "") && cfg.pgmodelCfg.MaxInFlightSamples <= 0 {
+		problems = append(problems, "Insert priorities only apply under -max-in-flight-samples backpressure, which is disabled. "+
+			"Set -max-in-flight-samples.")
+	}
+	if cfg.pgmodelCfg.FastIngest && cfg.pgmodelCfg.DedupSamples {
+		problems = append(problems, "-dedup-samples inserts samples without COPY, so -fast-ingest has no effect. Use only one of them.")
+	}
+	if maxAge := cfg.pgmodelCfg.SampleBounds.MaxAge; maxAge > 0 && cfg.pgmodelCfg.SpillDir != "" &&
+		(cfg.pgmodelCfg.SpillMaxAge == 0 || cfg.pgmodelCfg.SpillMaxAge > maxAge) {
+		problems = append(problems, fmt.Sprintf("-spill-max-age keeps write requests longer than the %v of -ingest-max-sample-age, "+
+			"so their samples may be rejected when they are replayed. Lower -spill-max-age.", maxAge))
+	}
+	if cfg.pgmodelCfg.StripExternalLabels && cfg.pgmodelCfg.ExternalLabels == "" {
+		problems = append(problems, "-read-strip-external-labels has no effect without -external-labels.")
+	}
+	authEnabled := cfg.auth.bearerTokensFile != "" || cfg.auth.htpasswdFile != "" || cfg.auth.adminTokensFile != ""
+	if cfg.enableAdminAPI && !authEnabled {
+		problems = append(problems, "-web-enable-admin-api serves the admin endpoints without authentication, so anyone reaching the connector can delete series or change the log level. "+
+			"Set -auth-admin-bearer-tokens-file, or -auth-htpasswd-file and -auth-admin-users.")
+	}
+	if cfg.enableAdminAPI && authEnabled && cfg.auth.adminUsers == "" && cfg.auth.adminTokensFile == "" {
+		problems = append(problems, "-web-enable-admin-api has no admin allowed on the admin endpoints, which reject every request. "+
+			"Set -auth-admin-users or -auth-admin-bearer-tokens-file.")
+	}
+	if cfg.auth.adminUsers != "" && cfg.pgmodelCfg.RedactionRulesFile == "" && !cfg.enableAdminAPI {
+		problems = append(problems, "-auth-admin-users has no effect without -read-redaction-rules-file or -web-enable-admin-api.")
+	}
+	if batch := cfg.pgmodelCfg.Batch; batch.TargetLatency > 0 && batch.Timeout >= batch.TargetLatency {
+		problems = append(problems, fmt.Sprintf("-ingest-batch-timeout makes the batches wait up to %v, at least the %v of -ingest-batch-target-latency, "+
+			"so adaptive batching shrinks the batches to -ingest-batch-min-series without meeting the target. Lower -ingest-batch-timeout.", batch.Timeout, batch.TargetLatency))
+	}
+	return problems
+}
+
+// seriesCacheProblem reports a series cache too small to hold the series
+// stored in the database.
+func seriesCacheProblem(cacheSize int, counter seriesCounter) (string, error) {
+	count, err := counter.SeriesCount()
+	if err != nil {
+		return "", err
+	}
+	if count <= int64(cacheSize) {
+		return "", nil
+	}
+	return fmt.Sprintf("The database holds %d series, more than the %d of -series-cache-size. "+
+		"Inserts will look the evicted series up in the database again.", count, cacheSize), nil
+}
+
+// checkConfig warns about the configuration problems found, or fails with
+// all of them in strict mode.
+func checkConfig(problems []string, strict bool) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("suspicious configuration (disable -strict to start anyway):\n- %s", strings.Join(problems, "\n- "))
+	}
+	for _, p := range problems {
+		log.Warn("msg", "Suspicious configuration", "problem", p)
+	}
+	return nil
+}
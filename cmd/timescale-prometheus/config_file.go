@@ -0,0 +1,249 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+const configFileFlag = "config-file"
+
+// reloadableFlags are the settings of the configuration file applied again
+// when it is reloaded on SIGHUP. The others need a restart.
+var reloadableFlags = map[string]bool{
+	"log-level":                    true,
+	"log-component-levels":         true,
+	"write-max-samples-per-second": true,
+	"write-samples-burst":          true,
+	"write-max-series":             true,
+	"write-max-body-bytes":         true,
+	"query-max-series":             true,
+	"query-max-samples":            true,
+	"query-statement-timeout":      true,
+	"query-limits-truncate":        true,
+}
+
+var configReloads = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "config_reloads_total",
+		Help:      "Total number of reloads of the configuration on SIGHUP, by result.",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(configReloads)
+}
+
+// readConfigFile reads a YAML file mapping the names of the flags, without
+// their leading dash, to their values. A list is taken as the
+// comma-separated values of the flags taking several ones.
+func readConfigFile(fs *flag.FlagSet, path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the configuration file: %w", err)
+	}
+	var raw map[string]interface{}
+	if err = yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing the configuration file %s: %w", path, err)
+	}
+	settings := make(map[string]string, len(raw))
+	for name, value := range raw {
+		if fs.Lookup(name) == nil || name == configFileFlag {
+			return nil, fmt.Errorf("configuration file %s: unknown setting %q", path, name)
+		}
+		if settings[name], err = configValue(value); err != nil {
+			return nil, fmt.Errorf("configuration file %s: setting %q: %w", path, name, err)
+		}
+	}
+	return settings, nil
+}
+
+func configValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, item := range v {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			values[i] = s
+		}
+		return strings.Join(values, ","), nil
+	case map[string]interface{}:
+		return "", fmt.Errorf("expected a value or a list, not a mapping")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// explicitFlags returns the names of the flags set on the command line or by
+// the environment, which take precedence over the configuration file.
+func explicitFlags(fs *flag.FlagSet) map[string]bool {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	return explicit
+}
+
+// applyConfigFile sets the flags of the configuration file at path, except
+// the explicit ones, and returns its settings.
+func applyConfigFile(fs *flag.FlagSet, path string, explicit map[string]bool) (map[string]string, error) {
+	settings, err := readConfigFile(fs, path)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sortedKeys(settings) {
+		if explicit[name] {
+			continue
+		}
+		if err = fs.Set(name, settings[name]); err != nil {
+			return nil, fmt.Errorf("configuration file %s: setting %q: %w", path, name, err)
+		}
+	}
+	return settings, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// relabelReloader reads the relabel configs again.
+type relabelReloader interface {
+	ReloadRelabelConfig() error
+}
+
+// configReloader applies the reloadable settings of the configuration file
+// again, and reads the relabel configs again, on SIGHUP.
+type configReloader struct {
+	fs       *flag.FlagSet
+	cfg      *config
+	explicit map[string]bool
+	// applied are the settings of the configuration file last applied.
+	applied map[string]string
+
+	writeLimits *writeLimiter
+	queryLimits *globalQueryLimits
+	// relabel is nil without relabel configs.
+	relabel relabelReloader
+}
+
+// reloadOnSignal reloads the configuration on every SIGHUP.
+func (r *configReloader) reloadOnSignal() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		r.reload()
+	}
+}
+
+func (r *configReloader) reload() {
+	result := "success"
+	if err := r.reloadConfigFile(); err != nil {
+		log.Error("msg", "Reloading the configuration file failed, keeping the previous settings", "err", err)
+		result = "failure"
+	}
+	if r.relabel != nil {
+		if err := r.relabel.ReloadRelabelConfig(); err != nil {
+			log.Error("msg", "Reloading the relabel configs failed, keeping the previous ones", "err", err)
+			result = "failure"
+		}
+	}
+	configReloads.WithLabelValues(result).Inc()
+}
+
+// reloadConfigFile applies the reloadable settings changed in the
+// configuration file, the settings removed from it going back to their
+// default. It applies none of them if any is invalid.
+func (r *configReloader) reloadConfigFile() error {
+	path := r.cfg.configFile
+	if path == "" {
+		return nil
+	}
+	settings, err := readConfigFile(r.fs, path)
+	if err != nil {
+		return err
+	}
+
+	changed := make(map[string]string)
+	for _, m := range []map[string]string{r.applied, settings} {
+		for name := range m {
+			value, ok := settings[name]
+			if !ok {
+				value = r.fs.Lookup(name).DefValue
+			}
+			prev, applied := r.applied[name]
+			if r.explicit[name] || (applied && prev == value) || (!applied && value == r.fs.Lookup(name).DefValue) {
+				continue
+			}
+			changed[name] = value
+		}
+	}
+
+	var restart []string
+	// The reloadable settings are restored if any of them is invalid.
+	logLevel, logComponents, writeLimits, queryLimits := r.cfg.logLevel, r.cfg.logComponents, r.cfg.limits, r.cfg.queryLimits
+	restore := func() {
+		r.cfg.logLevel, r.cfg.logComponents, r.cfg.limits, r.cfg.queryLimits = logLevel, logComponents, writeLimits, queryLimits
+	}
+	for _, name := range sortedKeys(changed) {
+		if !reloadableFlags[name] {
+			restart = append(restart, name)
+			continue
+		}
+		if err = r.fs.Set(name, changed[name]); err != nil {
+			restore()
+			return fmt.Errorf("configuration file %s: setting %q: %w", path, name, err)
+		}
+	}
+	if err = r.cfg.limits.validate(); err != nil {
+		restore()
+		return err
+	}
+	if err = log.SetLevel(r.cfg.logLevel); err != nil {
+		restore()
+		return err
+	}
+	if r.cfg.logComponents != logComponents {
+		if err = log.SetComponentLevels(r.cfg.logComponents); err != nil {
+			restore()
+			_ = log.SetLevel(logLevel)
+			return err
+		}
+	}
+	if r.writeLimits != nil {
+		// Validated above.
+		_ = r.writeLimits.update(r.cfg.limits)
+	}
+	r.queryLimits.set(r.cfg.queryLimits)
+
+	// The settings needing a restart are remembered as applied, so that
+	// they are only warned about once.
+	r.applied = settings
+	if len(restart) > 0 {
+		log.Warn("msg", "Settings of the configuration file changed, restart to apply them", "settings", strings.Join(restart, ","))
+	}
+	log.Info("msg", "Reloaded the configuration file", "path", path)
+	return nil
+}
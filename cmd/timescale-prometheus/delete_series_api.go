@@ -0,0 +1,229 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+var (
+	// deleteSeriesMinTime and deleteSeriesMaxTime bound the time range when
+	// start or end are not given. Both fit in a PostgreSQL timestamp.
+	deleteSeriesMinTime = time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+	deleteSeriesMaxTime = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+)
+
+// seriesDeleter deletes the samples of the series matching selectors.
+type seriesDeleter interface {
+	DeleteSeries(ctx context.Context, selectors [][]*prompb.LabelMatcher, start, end time.Time, dryRun bool) ([]pgmodel.SeriesDeletion, error)
+}
+
+// tombstoneStore lists and removes the tombstones of the series deleted with
+// a grace period.
+type tombstoneStore interface {
+	Tombstones(ctx context.Context) ([]pgmodel.SeriesTombstone, error)
+	Undelete(ctx context.Context, ids []int64) ([]pgmodel.SeriesTombstone, error)
+}
+
+type deleteSeriesResponse struct {
+	Status string                   `json:"status"`
+	Data   []pgmodel.SeriesDeletion `json:"data"`
+	Error  string                   `json:"error,omitempty"`
+}
+
+// deleteSeries serves the delete series API of Prometheus: the samples
+// between start and end of the series matching any of the match[]
+// selectors are deleted, along with the series left without samples. Unlike
+// Prometheus, the deletions are reported per metric, and dry_run=true only
+// reports what would be deleted. With a grace period, the deleter tombstones
+// the samples instead.
+func deleteSeries(deleter seriesDeleter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			w.Header().Set("Allow", "POST, PUT")
+			writeDeleteSeriesResponse(w, http.StatusMethodNotAllowed, deleteSeriesResponse{Error: "method not allowed"})
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			writeDeleteSeriesResponse(w, http.StatusBadRequest, deleteSeriesResponse{Error: err.Error()})
+			return
+		}
+		if len(r.Form["match[]"]) == 0 {
+			writeDeleteSeriesResponse(w, http.StatusBadRequest, deleteSeriesResponse{Error: "no match[] parameter provided"})
+			return
+		}
+		selectors := make([][]*prompb.LabelMatcher, 0, len(r.Form["match[]"]))
+		for _, s := range r.Form["match[]"] {
+			matchers, err := parser.ParseMetricSelector(s)
+			if err != nil {
+				writeDeleteSeriesResponse(w, http.StatusBadRequest, deleteSeriesResponse{Error: err.Error()})
+				return
+			}
+			selectors = append(selectors, toLabelMatchers(matchers))
+		}
+
+		start, err := parseTimeParam(r, "start", deleteSeriesMinTime)
+		if err != nil {
+			writeDeleteSeriesResponse(w, http.StatusBadRequest, deleteSeriesResponse{Error: err.Error()})
+			return
+		}
+		end, err := parseTimeParam(r, "end", deleteSeriesMaxTime)
+		if err != nil {
+			writeDeleteSeriesResponse(w, http.StatusBadRequest, deleteSeriesResponse{Error: err.Error()})
+			return
+		}
+		if end.Before(start) {
+			writeDeleteSeriesResponse(w, http.StatusBadRequest, deleteSeriesResponse{Error: "end timestamp must not be before start time"})
+			return
+		}
+		dryRun := false
+		if d := r.FormValue("dry_run"); d != "" {
+			if dryRun, err = strconv.ParseBool(d); err != nil {
+				writeDeleteSeriesResponse(w, http.StatusBadRequest, deleteSeriesResponse{Error: fmt.Sprintf("invalid dry_run %q", d)})
+				return
+			}
+		}
+
+		deletions, err := deleter.DeleteSeries(r.Context(), selectors, start, end, dryRun)
+		if err != nil {
+			log.Error("msg", "Deleting series failed", "err", err, "dry_run", dryRun)
+			writeDeleteSeriesResponse(w, http.StatusInternalServerError, deleteSeriesResponse{Data: deletions, Error: err.Error()})
+			return
+		}
+		writeDeleteSeriesResponse(w, http.StatusOK, deleteSeriesResponse{Data: deletions})
+	})
+}
+
+type tombstonesResponse struct {
+	Status string                    `json:"status"`
+	Data   []pgmodel.SeriesTombstone `json:"data"`
+	Error  string                    `json:"error,omitempty"`
+}
+
+// listTombstones reports the tombstones of the series deleted with a grace
+// period and not purged yet.
+func listTombstones(store tombstoneStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeTombstonesResponse(w, http.StatusMethodNotAllowed, tombstonesResponse{Error: "method not allowed"})
+			return
+		}
+		tombstones, err := store.Tombstones(r.Context())
+		if err != nil {
+			log.Error("msg", "Listing tombstones failed", "err", err)
+			writeTombstonesResponse(w, http.StatusInternalServerError, tombstonesResponse{Error: err.Error()})
+			return
+		}
+		writeTombstonesResponse(w, http.StatusOK, tombstonesResponse{Data: tombstones})
+	})
+}
+
+// undeleteSeries removes the tombstones of the id parameters, restoring the
+// samples they hide unless they are due to be purged, and reports the
+// tombstones removed.
+func undeleteSeries(store tombstoneStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			w.Header().Set("Allow", "POST, PUT")
+			writeTombstonesResponse(w, http.StatusMethodNotAllowed, tombstonesResponse{Error: "method not allowed"})
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			writeTombstonesResponse(w, http.StatusBadRequest, tombstonesResponse{Error: err.Error()})
+			return
+		}
+		if len(r.Form["id"]) == 0 {
+			writeTombstonesResponse(w, http.StatusBadRequest, tombstonesResponse{Error: "no id parameter provided"})
+			return
+		}
+		ids := make([]int64, 0, len(r.Form["id"]))
+		for _, v := range r.Form["id"] {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				writeTombstonesResponse(w, http.StatusBadRequest, tombstonesResponse{Error: fmt.Sprintf("invalid id %q", v)})
+				return
+			}
+			ids = append(ids, id)
+		}
+
+		restored, err := store.Undelete(r.Context(), ids)
+		if err != nil {
+			log.Error("msg", "Undeleting series failed", "err", err)
+			writeTombstonesResponse(w, http.StatusInternalServerError, tombstonesResponse{Error: err.Error()})
+			return
+		}
+		writeTombstonesResponse(w, http.StatusOK, tombstonesResponse{Data: restored})
+	})
+}
+
+func writeTombstonesResponse(w http.ResponseWriter, status int, resp tombstonesResponse) {
+	resp.Status = "success"
+	if resp.Error != "" {
+		resp.Status = "error"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func writeDeleteSeriesResponse(w http.ResponseWriter, status int, resp deleteSeriesResponse) {
+	resp.Status = "success"
+	if resp.Error != "" {
+		resp.Status = "error"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// parseTimeParam parses a timestamp parameter the way the Prometheus HTTP
+// API does: as RFC 3339 or as a Unix timestamp in seconds.
+func parseTimeParam(r *http.Request, name string, defaultTime time.Time) (time.Time, error) {
+	v := r.FormValue(name)
+	if v == "" {
+		return defaultTime, nil
+	}
+	if t, err := strconv.ParseFloat(v, 64); err == nil {
+		s, ns := math.Modf(t)
+		ns = math.Round(ns*1000) / 1000
+		return time.Unix(int64(s), int64(ns*float64(time.Second))), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s timestamp %q", name, v)
+}
+
+func toLabelMatchers(matchers []*labels.Matcher) []*prompb.LabelMatcher {
+	result := make([]*prompb.LabelMatcher, 0, len(matchers))
+	for _, m := range matchers {
+		var mtype prompb.LabelMatcher_Type
+		switch m.Type {
+		case labels.MatchEqual:
+			mtype = prompb.LabelMatcher_EQ
+		case labels.MatchNotEqual:
+			mtype = prompb.LabelMatcher_NEQ
+		case labels.MatchRegexp:
+			mtype = prompb.LabelMatcher_RE
+		case labels.MatchNotRegexp:
+			mtype = prompb.LabelMatcher_NRE
+		}
+		result = append(result, &prompb.LabelMatcher{Type: mtype, Name: m.Name, Value: m.Value})
+	}
+	return result
+}
@@ -0,0 +1,78 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"sync"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/timescale/timescale-prometheus/pkg/events"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+// eventsConfig selects the sinks of the operational events.
+type eventsConfig struct {
+	log                bool
+	webhookURL         string
+	table              bool
+	dataLossWebhookURL string
+	webhookRetries     int
+}
+
+func (c eventsConfig) enabled() bool {
+	return c.log || c.webhookURL != "" || c.table || c.dataLossWebhookURL != ""
+}
+
+// startEvents starts the event bus with the sinks available before the
+// database is migrated. It returns nil when no sink is configured.
+func startEvents(cfg eventsConfig, instanceID string) *events.Bus {
+	if !cfg.enabled() {
+		return nil
+	}
+	sinks := make([]events.Sink, 0, 3)
+	if cfg.log {
+		sinks = append(sinks, events.LogSink{})
+	}
+	if cfg.webhookURL != "" {
+		sinks = append(sinks, events.NewWebhookSink(cfg.webhookURL, events.DefaultWebhookTimeout, cfg.webhookRetries))
+	}
+	if cfg.dataLossWebhookURL != "" {
+		webhook := events.NewWebhookSink(cfg.dataLossWebhookURL, events.DefaultWebhookTimeout, cfg.webhookRetries)
+		sinks = append(sinks, events.NewFilterSink("data_loss_webhook", webhook, events.DataLost))
+	}
+	return events.Init(instanceID, events.DefaultBufferSize, sinks...)
+}
+
+// addEventTable records the events in the event table from now on. The
+// table only exists once the database is migrated.
+func addEventTable(bus *events.Bus, cfg eventsConfig, pool *pgxpool.Pool) {
+	if cfg.table {
+		bus.AddSink(pgmodel.NewEventTableSink(pool))
+	}
+}
+
+var (
+	leaderMu sync.Mutex
+	// isLeader is nil until the leadership of the instance is first known.
+	isLeader *bool
+)
+
+// setLeader records whether this instance is the leader, emitting an event
+// when that changes.
+func setLeader(leader bool) {
+	if leader {
+		leaderGauge.Set(1)
+	} else {
+		leaderGauge.Set(0)
+	}
+
+	leaderMu.Lock()
+	defer leaderMu.Unlock()
+	if isLeader != nil && *isLeader == leader {
+		return
+	}
+	isLeader = &leader
+	events.Emit(events.LeadershipChanged, "leader", leader)
+}
@@ -0,0 +1,226 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	graphiteLinesBuffer   = 10000
+	graphiteMaxDatagram   = 65536
+	graphiteResultIngest  = "ingested"
+	graphiteResultInvalid = "invalid"
+	graphiteResultLeader  = "not_leader"
+	graphiteResultFailed  = "failed"
+)
+
+var graphiteSamples = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "graphite_samples_total",
+		Help:      "Total number of samples received by the Graphite listener, by result.",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(graphiteSamples)
+}
+
+// graphiteConfig configures the Graphite plaintext protocol listener.
+type graphiteConfig struct {
+	tcpAddr       string
+	udpAddr       string
+	mappingFile   string
+	batchSize     int
+	flushInterval time.Duration
+}
+
+func (c graphiteConfig) enabled() bool {
+	return c.tcpAddr != "" || c.udpAddr != ""
+}
+
+// graphiteListener accepts the lines of the Graphite plaintext protocol over
+// TCP and UDP, and ingests their samples in batches of batchSize samples, or
+// every flushInterval.
+type graphiteListener struct {
+	mapper        *pgmodel.GraphiteMapper
+	writer        pgmodel.DBInserter
+	batchSize     int
+	flushInterval time.Duration
+	lines         chan string
+	tcp           net.Listener
+	udp           net.PacketConn
+}
+
+// startGraphite listens on the addresses of cfg. It returns nil when the
+// listener is disabled.
+func startGraphite(cfg graphiteConfig, writer pgmodel.DBInserter) (*graphiteListener, error) {
+	if !cfg.enabled() {
+		return nil, nil
+	}
+	if cfg.batchSize <= 0 || cfg.flushInterval <= 0 {
+		return nil, fmt.Errorf("graphite batch size and flush interval must be positive")
+	}
+	mapper, err := pgmodel.LoadGraphiteMapper(cfg.mappingFile)
+	if err != nil {
+		return nil, err
+	}
+	l := &graphiteListener{
+		mapper:        mapper,
+		writer:        writer,
+		batchSize:     cfg.batchSize,
+		flushInterval: cfg.flushInterval,
+		lines:         make(chan string, graphiteLinesBuffer),
+	}
+	if cfg.tcpAddr != "" {
+		if l.tcp, err = net.Listen("tcp", cfg.tcpAddr); err != nil {
+			return nil, fmt.Errorf("graphite TCP listener: %w", err)
+		}
+		go l.serveTCP()
+	}
+	if cfg.udpAddr != "" {
+		if l.udp, err = net.ListenPacket("udp", cfg.udpAddr); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("graphite UDP listener: %w", err)
+		}
+		go l.serveUDP()
+	}
+	go l.run()
+	ingestLogger.Info("msg", "Listening for Graphite plaintext protocol", "tcp", cfg.tcpAddr, "udp", cfg.udpAddr)
+	return l, nil
+}
+
+// Close stops accepting lines. The lines already received are ingested by
+// the next flush.
+func (l *graphiteListener) Close() {
+	if l == nil {
+		return
+	}
+	if l.tcp != nil {
+		_ = l.tcp.Close()
+	}
+	if l.udp != nil {
+		_ = l.udp.Close()
+	}
+}
+
+func (l *graphiteListener) serveTCP() {
+	for {
+		conn, err := l.tcp.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		go l.handleConn(conn)
+	}
+}
+
+func (l *graphiteListener) handleConn(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		l.lines <- scanner.Text()
+	}
+	if err := scanner.Err(); err != nil {
+		ingestLogger.Debug("msg", "Graphite connection closed", "remote", conn.RemoteAddr().String(), "err", err)
+	}
+}
+
+func (l *graphiteListener) serveUDP() {
+	buf := make([]byte, graphiteMaxDatagram)
+	for {
+		n, _, err := l.udp.ReadFrom(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+			l.lines <- string(line)
+		}
+	}
+}
+
+// run gathers the received lines into batches and ingests them.
+func (l *graphiteListener) run() {
+	ticker := time.NewTicker(l.flushInterval)
+	defer ticker.Stop()
+
+	req := pgmodel.NewWriteRequest()
+	samples := 0
+	flush := func() {
+		if samples > 0 {
+			l.flush(req, samples)
+			req = pgmodel.NewWriteRequest()
+			samples = 0
+		}
+	}
+	for {
+		select {
+		case line := <-l.lines:
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			lset, sample, err := l.mapper.ParseLine(line, time.Now())
+			if err != nil {
+				graphiteSamples.WithLabelValues(graphiteResultInvalid).Inc()
+				ingestLogger.Debug("msg", "Invalid Graphite line", "err", err)
+				continue
+			}
+			pbLabels := make([]prompb.Label, 0, len(lset))
+			for _, lbl := range lset {
+				pbLabels = append(pbLabels, prompb.Label{Name: lbl.Name, Value: lbl.Value})
+			}
+			req.Timeseries = append(req.Timeseries, prompb.TimeSeries{Labels: pbLabels, Samples: []prompb.Sample{sample}})
+			samples++
+			if samples >= l.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// flush ingests a batch of samples, unless this instance is not the leader,
+// since every instance of an HA group receives the same lines from the relay.
+func (l *graphiteListener) flush(req *prompb.WriteRequest, samples int) {
+	shouldWrite, err := isWriter()
+	if err != nil || !shouldWrite {
+		setLeader(false)
+		pgmodel.FinishWriteRequest(req)
+		graphiteSamples.WithLabelValues(graphiteResultLeader).Add(float64(samples))
+		return
+	}
+	setLeader(true)
+
+	receivedSamples.Add(float64(samples))
+	numSamples, err := l.writer.Ingest(context.Background(), req.GetTimeseries(), req)
+	sentSamples.Add(float64(numSamples))
+	graphiteSamples.WithLabelValues(graphiteResultIngest).Add(float64(numSamples))
+	if err != nil {
+		ingestLogger.Warn("msg", "Error ingesting Graphite samples", "err", err, "num_samples", numSamples)
+		if received := uint64(samples); received > numSamples {
+			failedSamples.Add(float64(received - numSamples))
+			graphiteSamples.WithLabelValues(graphiteResultFailed).Add(float64(received - numSamples))
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding"
+	// Registers the gzip compressor of gRPC.
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// remoteWriteServiceName is the gRPC service of the writes:
+//
+//	service RemoteWrite {
+//	  rpc Write(prometheus.WriteRequest) returns (google.protobuf.Empty);
+//	  rpc WriteStream(stream prometheus.WriteRequest) returns (stream google.protobuf.Empty);
+//	}
+//
+// WriteStream acknowledges each request, in order, once it is ingested. The
+// stream ends with the error of the first request failing, and the requests
+// not acknowledged are to be sent again.
+const remoteWriteServiceName = "timescale.prometheus.RemoteWrite"
+
+var grpcWriteRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "grpc_write_requests_total",
+		Help:      "Total number of gRPC write calls, by method and status code.",
+	},
+	[]string{"method", "code"},
+)
+
+func init() {
+	prometheus.MustRegister(grpcWriteRequests)
+	encoding.RegisterCompressor(snappyCompressor{})
+}
+
+// snappyCompressor lets the gRPC clients compress their writes with snappy,
+// in its framed format, besides gzip.
+type snappyCompressor struct{}
+
+func (snappyCompressor) Name() string {
+	return "snappy"
+}
+
+func (snappyCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return snappy.NewBufferedWriter(w), nil
+}
+
+func (snappyCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	return snappy.NewReader(r), nil
+}
+
+type grpcConfig struct {
+	listenAddr string
+}
+
+// remoteWriteService is implemented by remoteWriteServer, and checked by
+// grpc.Server.RegisterService.
+type remoteWriteService interface {
+	Write(context.Context, *prompb.WriteRequest) (*types.Empty, error)
+	WriteStream(grpc.ServerStream) error
+}
+
+var remoteWriteServiceDesc = grpc.ServiceDesc{
+	ServiceName: remoteWriteServiceName,
+	HandlerType: (*remoteWriteService)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Write", Handler: remoteWriteHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WriteStream",
+			Handler:       remoteWriteStreamHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
+
+func remoteWriteHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := pgmodel.NewWriteRequest()
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	write := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(remoteWriteService).Write(ctx, req.(*prompb.WriteRequest))
+	}
+	if interceptor == nil {
+		return write(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + remoteWriteServiceName + "/Write"}
+	return interceptor(ctx, req, info, write)
+}
+
+func remoteWriteStreamHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(remoteWriteService).WriteStream(stream)
+}
+
+// remoteWriteServer ingests the writes received over gRPC like those of the
+// remote-write endpoint, with the same leader election, limits and retries.
+type remoteWriteServer struct {
+	writer pgmodel.DBInserter
+	limits *writeLimiter
+}
+
+func (s *remoteWriteServer) Write(ctx context.Context, req *prompb.WriteRequest) (*types.Empty, error) {
+	if err := s.write(ctx, req); err != nil {
+		return nil, err
+	}
+	return &types.Empty{}, nil
+}
+
+func (s *remoteWriteServer) WriteStream(stream grpc.ServerStream) error {
+	for {
+		req := pgmodel.NewWriteRequest()
+		err := stream.RecvMsg(req)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.write(stream.Context(), req); err != nil {
+			return err
+		}
+		if err := stream.SendMsg(&types.Empty{}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *remoteWriteServer) write(ctx context.Context, req *prompb.WriteRequest) error {
+	if !acceptWrites() {
+		return nil
+	}
+	if !s.limits.checkSeries() {
+		writeLimitedRequests.WithLabelValues(limitReasonSeries).Inc()
+		return status.Error(codes.ResourceExhausted, (&writeLimitError{reason: limitReasonSeries}).Error())
+	}
+	atomic.StoreInt64(&lastRequestUnixNano, time.Now().UnixNano())
+
+	// The encoded request identifies its retries, as the body of the
+	// remote-write requests does.
+	data, err := req.Marshal()
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return grpcWriteError(ingestSamples(ctx, s.writer, s.limits, req, data))
+}
+
+// grpcWriteError converts the errors of ingestSamples to the gRPC status
+// matching the HTTP status of the remote-write endpoint. The failures
+// Prometheus would retry are Unavailable, which the gRPC clients retry.
+func grpcWriteError(err error) error {
+	var (
+		limited     *writeLimitError
+		outOfBounds *pgmodel.SamplesOutOfBoundsError
+		invalid     *pgmodel.InvalidSeriesError
+	)
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &limited):
+		writeLimitedRequests.WithLabelValues(limited.reason).Inc()
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.As(err, &outOfBounds), errors.As(err, &invalid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, pgmodel.ErrInFlightLimitExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+}
+
+// grpcWriteServer serves the gRPC writes until closed.
+type grpcWriteServer struct {
+	server *grpc.Server
+}
+
+// startGRPCWrites serves the gRPC write service on cfg.listenAddr, over TLS
+// and with the authentication of the web endpoints. It returns nil when no
+// address is configured.
+func startGRPCWrites(cfg grpcConfig, tlsCfg *webTLSConfig, auth *authenticator, writer pgmodel.DBInserter, limits *writeLimiter) (*grpcWriteServer, error) {
+	if cfg.listenAddr == "" {
+		return nil, nil
+	}
+
+	creds, err := tlsCfg.grpcCredentials()
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", cfg.listenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &grpcWriteServer{server: newGRPCWriteServer(creds, auth, writer, limits)}
+	go func() {
+		if err := s.server.Serve(listener); err != nil {
+			ingestLogger.Error("msg", "gRPC server failure", "err", err)
+		}
+	}()
+	ingestLogger.Info("msg", "Listening for gRPC writes", "addr", listener.Addr().String(), "tls", creds != nil)
+	return s, nil
+}
+
+// newGRPCWriteServer returns a gRPC server of the write service. Messages
+// are limited to the write body limit, if any, once decompressed.
+func newGRPCWriteServer(creds credentials.TransportCredentials, auth *authenticator, writer pgmodel.DBInserter, limits *writeLimiter) *grpc.Server {
+	maxMessageSize := math.MaxInt32
+	if n := limits.bodyLimit(); n > 0 && n < math.MaxInt32 {
+		maxMessageSize = int(n)
+	}
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(maxMessageSize),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := auth.verifyGRPC(ctx, info.FullMethod); err != nil {
+				grpcWriteRequests.WithLabelValues(info.FullMethod, codes.Unauthenticated.String()).Inc()
+				return nil, err
+			}
+			resp, err := handler(ctx, req)
+			grpcWriteRequests.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+			return resp, err
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := auth.verifyGRPC(stream.Context(), info.FullMethod); err != nil {
+				grpcWriteRequests.WithLabelValues(info.FullMethod, codes.Unauthenticated.String()).Inc()
+				return err
+			}
+			err := handler(srv, stream)
+			grpcWriteRequests.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+			return err
+		}),
+	}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	server := grpc.NewServer(opts...)
+	server.RegisterService(&remoteWriteServiceDesc, &remoteWriteServer{writer: writer, limits: limits})
+	return server
+}
+
+// Close stops the server, ending the ongoing calls. The clients send the
+// writes not acknowledged again.
+func (s *grpcWriteServer) Close() {
+	if s == nil {
+		return
+	}
+	s.server.Stop()
+}
@@ -0,0 +1,215 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	limitReasonBodySize    = "body_size"
+	limitReasonSamplesRate = "samples_rate"
+	limitReasonSeries      = "series"
+
+	// seriesCountInterval is how often the series count is read from the
+	// database to enforce the series limit.
+	seriesCountInterval = 30 * time.Second
+)
+
+// writeLimitsConfig configures the limits enforced on the write endpoint.
+// A zero value disables the corresponding limit.
+type writeLimitsConfig struct {
+	samplesPerSecond float64
+	samplesBurst     int64
+	maxSeries        int64
+	maxBodyBytes     int64
+}
+
+func (c *writeLimitsConfig) enabled() bool {
+	return c.samplesPerSecond > 0 || c.maxSeries > 0 || c.maxBodyBytes > 0
+}
+
+// seriesCounter counts the series stored in the database.
+type seriesCounter interface {
+	SeriesCount() (int64, error)
+}
+
+// writeLimiter enforces the write limits. A nil writeLimiter accepts all
+// requests.
+type writeLimiter struct {
+	// series is the series count as of the last refresh.
+	series int64
+	// limits holds the current *writeLimits, replaced when the
+	// configuration is reloaded.
+	limits atomic.Value
+}
+
+type writeLimits struct {
+	cfg     writeLimitsConfig
+	samples *tokenBucket
+}
+
+// newWriteLimiter returns nil when no limit is configured, unless the limits
+// are reloadable, in which case they may be set later by update.
+func newWriteLimiter(cfg writeLimitsConfig, reloadable bool) (*writeLimiter, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if !cfg.enabled() && !reloadable {
+		return nil, nil
+	}
+	l := &writeLimiter{}
+	l.limits.Store(newWriteLimits(cfg, nil))
+	return l, nil
+}
+
+func (c *writeLimitsConfig) validate() error {
+	if c.samplesPerSecond < 0 || c.samplesBurst < 0 || c.maxSeries < 0 || c.maxBodyBytes < 0 {
+		return fmt.Errorf("write limits cannot be negative")
+	}
+	return nil
+}
+
+// newWriteLimits returns the limits of cfg, keeping the tokens of the samples
+// rate limit of prev if it is unchanged.
+func newWriteLimits(cfg writeLimitsConfig, prev *writeLimits) *writeLimits {
+	l := &writeLimits{cfg: cfg}
+	if cfg.samplesPerSecond <= 0 {
+		return l
+	}
+	if prev != nil && prev.samples != nil && prev.cfg.samplesPerSecond == cfg.samplesPerSecond && prev.cfg.samplesBurst == cfg.samplesBurst {
+		l.samples = prev.samples
+		return l
+	}
+	burst := float64(cfg.samplesBurst)
+	if burst == 0 {
+		burst = cfg.samplesPerSecond
+	}
+	l.samples = newTokenBucket(cfg.samplesPerSecond, burst, time.Now())
+	return l
+}
+
+// update replaces the limits, such as when the configuration is reloaded.
+func (l *writeLimiter) update(cfg writeLimitsConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	l.limits.Store(newWriteLimits(cfg, l.current()))
+	return nil
+}
+
+func (l *writeLimiter) current() *writeLimits {
+	return l.limits.Load().(*writeLimits)
+}
+
+// checkBodySize reports whether a request body of the given size is accepted.
+func (l *writeLimiter) checkBodySize(size int64) bool {
+	if l == nil {
+		return true
+	}
+	max := l.current().cfg.maxBodyBytes
+	return max == 0 || size <= max
+}
+
+// bodyLimit returns how many bytes of the body to read: one more than the
+// limit, so that bodies over it can be detected, or -1 without a limit.
+func (l *writeLimiter) bodyLimit() int64 {
+	if l == nil || l.current().cfg.maxBodyBytes == 0 {
+		return -1
+	}
+	return l.current().cfg.maxBodyBytes + 1
+}
+
+// checkSeries reports whether the series limit still allows writes.
+func (l *writeLimiter) checkSeries() bool {
+	if l == nil {
+		return true
+	}
+	max := l.current().cfg.maxSeries
+	return max == 0 || atomic.LoadInt64(&l.series) < max
+}
+
+// admitSamples takes n samples from the rate limit, returning how long to
+// wait before retrying if they are not admitted.
+func (l *writeLimiter) admitSamples(n int64, now time.Time) (time.Duration, bool) {
+	if l == nil {
+		return 0, true
+	}
+	samples := l.current().samples
+	if samples == nil {
+		return 0, true
+	}
+	return samples.take(float64(n), now)
+}
+
+// limitsSeries reports whether the number of series is limited.
+func (l *writeLimiter) limitsSeries() bool {
+	return l != nil && l.current().cfg.maxSeries > 0
+}
+
+// refreshSeriesCount refreshes the series count enforcing the series limit.
+// It is run every seriesCountInterval by the maintenance scheduler, and does
+// nothing while the number of series is not limited.
+func (l *writeLimiter) refreshSeriesCount(counter seriesCounter) error {
+	if !l.limitsSeries() {
+		return nil
+	}
+	count, err := counter.SeriesCount()
+	if err != nil {
+		return fmt.Errorf("counting the series: %w", err)
+	}
+	atomic.StoreInt64(&l.series, count)
+	return nil
+}
+
+// rejectWrite answers a request over a limit, counting it by reason. A
+// positive retryAfter is sent in the Retry-After header.
+func rejectWrite(w http.ResponseWriter, reason string, status int, retryAfter time.Duration) {
+	writeLimitedRequests.WithLabelValues(reason).Inc()
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(retryAfter.Seconds())), 10))
+	}
+	http.Error(w, fmt.Sprintf("write limit exceeded: %s", reason), status)
+}
+
+// tokenBucket is a rate limiter refilling at rate tokens per second, up to
+// burst tokens. A request is admitted as long as tokens are left, even when
+// it takes more than the remaining ones, so that requests larger than the
+// burst are not rejected forever; the debt delays the next requests.
+type tokenBucket struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+func (b *tokenBucket) take(n float64, now time.Time) (time.Duration, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+	if b.tokens <= 0 {
+		wait := time.Duration(-b.tokens / b.rate * float64(time.Second))
+		if wait < time.Second {
+			wait = time.Second
+		}
+		return wait, false
+	}
+	b.tokens -= n
+	return 0, true
+}
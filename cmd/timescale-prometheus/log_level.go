@@ -0,0 +1,69 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+// The loggers of the subsystems, whose log levels can be set independently.
+var (
+	ingestLogger  = log.Component("ingest")
+	queryLogger   = log.Component("query")
+	migrateLogger = log.Component("migrate")
+)
+
+type logLevelResponse struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// logLevel reports the log levels on GET, and sets them on PUT or POST: the
+// global one, or the one of the component parameter if given. An empty level
+// makes the component follow the global level again. The levels set stay
+// until the restart, or until the configuration file reload changes them.
+func logLevel() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			if err := r.ParseForm(); err != nil {
+				writeLogLevelResponse(w, http.StatusBadRequest, err)
+				return
+			}
+			level, component := r.FormValue("level"), r.FormValue("component")
+			var err error
+			if component == "" {
+				err = log.SetLevel(level)
+			} else {
+				err = log.SetComponentLevel(component, level)
+			}
+			if err != nil {
+				writeLogLevelResponse(w, http.StatusBadRequest, err)
+				return
+			}
+			log.Info("msg", "Log level changed", "log_component", component, "level", level)
+		default:
+			w.Header().Set("Allow", "GET, POST, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeLogLevelResponse(w, http.StatusOK, nil)
+	})
+}
+
+func writeLogLevelResponse(w http.ResponseWriter, status int, err error) {
+	var resp logLevelResponse
+	resp.Level, resp.Components = log.Levels()
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
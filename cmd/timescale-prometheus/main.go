@@ -7,21 +7,33 @@ package main
 // documentation/examples/remote_storage/remote_storage_adapter/main.go
 
 import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	_ "github.com/jackc/pgx/v4/stdlib"
 
+	"github.com/timescale/timescale-prometheus/pkg/events"
 	"github.com/timescale/timescale-prometheus/pkg/log"
 	"github.com/timescale/timescale-prometheus/pkg/pgclient"
 	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/tracing"
 	"github.com/timescale/timescale-prometheus/pkg/util"
 
 	"github.com/gogo/protobuf/proto"
@@ -39,17 +51,47 @@ type config struct {
 	telemetryPath     string
 	pgmodelCfg        pgclient.Config
 	logLevel          string
+	logFormat         string
+	logComponents     string
 	haGroupLockID     int
 	restElection      bool
 	prometheusTimeout time.Duration
 	electionInterval  time.Duration
-	migrate           bool
+	migrate           migrateMode
+	schemaMismatch    schemaMismatchMode
+	migrateRepair     string
+	migrateDownTo     int
+	migrateDownDB     string
+	migrateDownDryRun bool
+	extensions        pgmodel.ExtensionUpdateConfig
+	selfTelemetry     time.Duration
+	lifecycleInterval time.Duration
+	seriesVacuum      time.Duration
+	seriesVacuumGrace time.Duration
+	deleteSeriesGrace time.Duration
+	chunkTuning       time.Duration
+	chunkIntervals    pgmodel.ChunkIntervalConfig
+	maintenance       maintenanceConfig
+	tls               webTLSConfig
+	auth              authConfig
+	enableAdminAPI    bool
+	limits            writeLimitsConfig
+	capture           pgmodel.CaptureConfig
+	queryLimits       pgmodel.QueryLimits
+	serviceMonitor    serviceMonitorConfig
+	traces            tracing.Config
+	events            eventsConfig
+	graphite          graphiteConfig
+	grpc              grpcConfig
+	strict            bool
+	configFile        string
 }
 
 const (
 	tickInterval      = time.Second
 	promLivenessCheck = time.Second
 	promNamespace     = "ts_prom"
+	heartbeatInterval = 10 * time.Second
 )
 
 var (
@@ -111,6 +153,14 @@ var (
 			Buckets:   prometheus.DefBuckets,
 		},
 	)
+	instanceInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "instance_info",
+			Help:      "Information about this connector instance.",
+		},
+		[]string{"instance_id", "version", "commit_hash"},
+	)
 	httpRequestDuration = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace: promNamespace,
@@ -120,6 +170,22 @@ var (
 		},
 		[]string{"path"},
 	)
+	authFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "auth_failures_total",
+			Help:      "Total number of requests rejected because of missing or invalid credentials, or of credentials not allowed on the admin API.",
+		},
+		[]string{"path", "reason"},
+	)
+	writeLimitedRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "write_limited_requests_total",
+			Help:      "Total number of write requests rejected for exceeding a write limit, by limit.",
+		},
+		[]string{"reason"},
+	)
 	writeThroughput     = util.NewThroughputCalc(tickInterval)
 	elector             *util.Elector
 	lastRequestUnixNano = time.Now().UnixNano()
@@ -135,23 +201,114 @@ func init() {
 	prometheus.MustRegister(sentBatchDuration)
 	prometheus.MustRegister(queryBatchDuration)
 	prometheus.MustRegister(httpRequestDuration)
+	prometheus.MustRegister(instanceInfo)
+	prometheus.MustRegister(authFailures)
+	prometheus.MustRegister(writeLimitedRequests)
 	writeThroughput.Start()
 }
 
 var reportTput = true
 
 func main() {
-	cfg := parseFlags()
-	err := log.Init(cfg.logLevel)
+	cfg, reloader, err := parseFlags()
+	if err != nil {
+		fmt.Println("Version: ", Version, "Commit Hash: ", CommitHash)
+		fmt.Println("Fatal error: invalid configuration", err)
+		os.Exit(1)
+	}
+	err = log.InitWithFormat(cfg.logLevel, cfg.logFormat)
+	if err == nil {
+		err = log.SetComponentLevels(cfg.logComponents)
+	}
 	if err != nil {
 		fmt.Println("Version: ", Version, "Commit Hash: ", CommitHash)
 		fmt.Println("Fatal error: cannot start logger", err)
 		os.Exit(1)
 	}
+	log.With("instance_id", cfg.pgmodelCfg.InstanceID)
 	log.Info("msg", "Version:"+Version+"; Commit Hash: "+CommitHash)
+	instanceInfo.WithLabelValues(cfg.pgmodelCfg.InstanceID, Version, CommitHash).Set(1)
 	log.Info("config", util.MaskPassword(fmt.Sprintf("%+v", cfg)))
 	http.Handle(cfg.telemetryPath, promhttp.Handler())
 
+	if _, err = cfg.tls.tlsConfig(); err != nil {
+		log.Error("msg", "Aborting startup because of invalid TLS configuration", "err", err)
+		os.Exit(1)
+	}
+
+	if err = checkConfig(configProblems(cfg), cfg.strict); err != nil {
+		log.Error("msg", "Aborting startup because of strict mode", "err", err)
+		os.Exit(1)
+	}
+
+	if err = tracing.Init(cfg.traces); err != nil {
+		log.Error("msg", "Aborting startup because of invalid tracing configuration", "err", err)
+		os.Exit(1)
+	}
+	defer tracing.Shutdown()
+
+	eventBus := startEvents(cfg.events, cfg.pgmodelCfg.InstanceID)
+	defer eventBus.Close()
+
+	auth, err := newAuthenticator(cfg.auth)
+	if err != nil {
+		log.Error("msg", "Aborting startup because of invalid authentication configuration", "err", err)
+		os.Exit(1)
+	}
+	if cfg.pgmodelCfg.TenantRoles && cfg.auth.htpasswdFile == "" {
+		log.Error("msg", "Aborting startup because -query-tenant-roles requires -auth-htpasswd-file to authenticate the tenants")
+		os.Exit(1)
+	}
+
+	if err := cfg.maintenance.validate(); err != nil {
+		log.Error("msg", "Aborting startup because of invalid maintenance configuration", "err", err)
+		os.Exit(1)
+	}
+
+	limits, err := newWriteLimiter(cfg.limits, cfg.configFile != "")
+	if err != nil {
+		log.Error("msg", "Aborting startup because of invalid write limits", "err", err)
+		os.Exit(1)
+	}
+
+	if cfg.serviceMonitor.enabled {
+		kube, namespace, err := inClusterKubeClient()
+		if err != nil {
+			log.Error("msg", "Aborting startup because of ServiceMonitor registration error", "err", err)
+			os.Exit(1)
+		}
+		sm, err := buildServiceMonitor(cfg.serviceMonitor, namespace, cfg.telemetryPath, cfg.tls.enabled())
+		if err != nil {
+			log.Error("msg", "Aborting startup because of invalid ServiceMonitor configuration", "err", err)
+			os.Exit(1)
+		}
+		go runServiceMonitorRegistration(kube, sm)
+	}
+
+	if cfg.migrateDownTo >= 0 {
+		var prompt io.Reader
+		if cfg.migrateDownDB == "" && !cfg.migrateDownDryRun && isTerminal(os.Stdin) {
+			prompt = os.Stdin
+		}
+		err = migrateDown(&cfg.pgmodelCfg, uint(cfg.migrateDownTo), cfg.migrateDownDB, cfg.migrateDownDryRun, prompt)
+		if err != nil {
+			migrateLogger.Error("msg", fmt.Sprintf("Down migration aborted: %s", util.MaskPassword(err.Error())))
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if cfg.migrate == migrateOnly {
+		// Without an elector every migration job migrates, the migrator
+		// lock making them take turns.
+		if err = migrate(&cfg.pgmodelCfg, cfg.migrateRepair, cfg.extensions); err != nil {
+			migrateLogger.Error("msg", fmt.Sprintf("Migration aborted: %s", util.MaskPassword(err.Error())))
+			os.Exit(1)
+		}
+		migrateLogger.Info("msg", "Schema migrated, exiting")
+		os.Exit(0)
+	}
+
 	elector, err = initElector(cfg)
 
 	if err != nil {
@@ -169,16 +326,16 @@ func main() {
 	}
 
 	// migrate has to happen after elector started
-	if cfg.migrate {
-		err = migrate(&cfg.pgmodelCfg)
+	if cfg.migrate == migrateInstall {
+		err = migrate(&cfg.pgmodelCfg, cfg.migrateRepair, cfg.extensions)
 
 		if err != nil {
-			log.Error("msg", fmt.Sprintf("Aborting startup because of migration error: %s", util.MaskPassword(err.Error())))
+			migrateLogger.Error("msg", fmt.Sprintf("Aborting startup because of migration error: %s", util.MaskPassword(err.Error())))
 			os.Exit(1)
 		}
 	}
 
-	if cfg.pgmodelCfg.AsyncAcks && cfg.pgmodelCfg.ReportInterval > 0 {
+	if cfg.pgmodelCfg.AcksBeforeWrite() && cfg.pgmodelCfg.ReportInterval > 0 {
 		reportTput = false
 	}
 
@@ -191,14 +348,166 @@ func main() {
 	}
 	defer client.Close()
 
-	http.Handle("/write", timeHandler(httpRequestDuration, "write", write(client)))
-	http.Handle("/read", timeHandler(httpRequestDuration, "read", read(client)))
+	dbInfo, dbErr := pgmodel.ReadDatabaseInfo(client.Connection)
+	var writer pgmodel.DBInserter = client
+	if dbErr != nil {
+		log.Warn("msg", "Reading the database info failed, not checking the schema version", "err", dbErr)
+	} else if readOnly, err := checkSchemaVersion(dbInfo, cfg.schemaMismatch); err != nil {
+		migrateLogger.Error("msg", "Aborting startup because of an unsupported schema version", "err", err)
+		os.Exit(1)
+	} else if readOnly {
+		migrateLogger.Warn("msg", "Starting read-only because of an unsupported schema version", "err", dbInfo.CheckSchemaVersion())
+		writer = readOnlyWriter{}
+	}
+	addEventTable(eventBus, cfg.events, client.Connection)
+
+	hostname, _ := os.Hostname()
+	registry := pgmodel.NewInstanceRegistry(client.Connection, pgmodel.InstanceInfo{
+		ID:         cfg.pgmodelCfg.InstanceID,
+		Hostname:   hostname,
+		Version:    Version,
+		CommitHash: CommitHash,
+		StartedAt:  time.Now(),
+	})
+	go runHeartbeat(registry)
+
+	problem, err := seriesCacheProblem(cfg.pgmodelCfg.SeriesCacheSize, pgmodel.NewStatsReader(client.Connection))
+	if err != nil {
+		log.Warn("msg", "Counting the series failed", "err", err)
+	} else if problem != "" {
+		if err = checkConfig([]string{problem}, cfg.strict); err != nil {
+			log.Error("msg", "Aborting startup because of strict mode", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	writePool, readPool := client.Pools()
+	report := newStartupReport(cfg, flag.CommandLine, dbInfo, dbErr, writePool, readPool)
+	log.Info("msg", "Startup report", "report", report)
+
+	// Validated above.
+	maintenance, _ := newMaintenanceScheduler(cfg.maintenance, isWriter, time.Now())
+	if limits != nil {
+		stats := pgmodel.NewStatsReader(client.Connection)
+		maintenance.add(maintenanceJob{
+			name: "series_count", interval: seriesCountInterval, anytime: true, atStart: true,
+			run: func(context.Context) error { return limits.refreshSeriesCount(stats) },
+		}, time.Now())
+	}
+	maintenance.add(maintenanceJob{
+		name: "metric_finalization", interval: metricFinalizationInterval, anytime: true,
+		run: func(context.Context) error { return client.CompleteMetricCreation() },
+	}, time.Now())
+
+	queryLimits := newGlobalQueryLimits(cfg.queryLimits)
+	reloader.writeLimits = limits
+	reloader.queryLimits = queryLimits
+	if cfg.pgmodelCfg.RelabelConfigFile != "" {
+		reloader.relabel = client
+	}
+	if cfg.configFile != "" || reloader.relabel != nil {
+		go reloader.reloadOnSignal()
+	}
+
+	var capture *pgmodel.RequestCapture
+	if cfg.capture.Dir != "" {
+		capture, err = pgmodel.NewRequestCapture(cfg.capture)
+		if err != nil {
+			log.Error("msg", "Aborting startup because of request capture error", "err", err)
+			os.Exit(1)
+		}
+		defer capture.Close()
+		log.Warn("msg", "Capturing write requests to disk", "dir", cfg.capture.Dir)
+	}
+
+	http.Handle("/write", timeHandler(httpRequestDuration, "write", tracing.Handler("/write", auth.wrap("write", write(writer, limits, capture)))))
+	pushHandler := timeHandler(httpRequestDuration, "push", auth.wrap("write", push(writer, limits)))
+	http.Handle(pushPath, pushHandler)
+	http.Handle(pushPath+"/", pushHandler)
+	http.Handle(otlpMetricsPath, timeHandler(httpRequestDuration, "otlp", auth.wrap("write", otlpMetrics(writer, limits))))
+	http.Handle("/read", timeHandler(httpRequestDuration, "read", tracing.Handler("/read", auth.wrap("read", read(client, queryLimits)))))
 	http.Handle("/healthz", health(client))
+	http.Handle("/ready", ready(client))
+	http.Handle("/instances", auth.wrap("instances", instances(registry)))
+	http.Handle("/admin/election/status", auth.wrap("election_status", electionStatus(elector)))
+	http.Handle("/ingest-stats", auth.wrap("ingest_stats", ingestStats(client)))
+
+	admin := adminAPI{enabled: cfg.enableAdminAPI, auth: auth}
+	if admin.enabled {
+		rates := pgmodel.NewIngestRateTracker(client.IngestedSamples)
+		go runIngestRateTracker(rates)
+		admin.handle(http.DefaultServeMux, "/api/v1/admin/stats", "stats", storageStats(pgmodel.NewStatsReader(client.Connection), rates.Rates))
+	}
+	deleter := pgmodel.NewSeriesDeleter(client.Connection, client.EvictSeries, cfg.deleteSeriesGrace)
+	admin.handle(http.DefaultServeMux, "/api/v1/admin/tsdb/delete_series", "delete_series", deleteSeries(deleter))
+	admin.handle(http.DefaultServeMux, "/api/v1/admin/tsdb/tombstones", "tombstones", listTombstones(deleter))
+	admin.handle(http.DefaultServeMux, "/api/v1/admin/tsdb/undelete_series", "undelete_series", undeleteSeries(deleter))
+	http.Handle("/startup-report", auth.wrap("startup_report", startupReportHandler(report)))
+	admin.handle(http.DefaultServeMux, "/admin/loglevel", "loglevel", logLevel())
+
+	graphite, err := startGraphite(cfg.graphite, writer)
+	if err != nil {
+		log.Error("msg", "Aborting startup because of Graphite listener error", "err", err)
+		os.Exit(1)
+	}
+	defer graphite.Close()
+
+	grpcWrites, err := startGRPCWrites(cfg.grpc, &cfg.tls, auth, writer, limits)
+	if err != nil {
+		log.Error("msg", "Aborting startup because of gRPC server error", "err", err)
+		os.Exit(1)
+	}
+	defer grpcWrites.Close()
+
+	if cfg.selfTelemetry > 0 {
+		go runSelfTelemetry(prometheus.DefaultGatherer, writer, cfg.pgmodelCfg.InstanceID, cfg.selfTelemetry)
+	}
+
+	// Only the leader runs the jobs changing the data, so that HA pairs do
+	// not roll up twice nor all scan the series tables.
+	lifecycle := pgmodel.NewLifecycleManager(client.Connection)
+	http.Handle("/lifecycle-policies", auth.wrap("lifecycle_policies", lifecyclePolicies(lifecycle)))
+	maintenance.add(maintenanceJob{
+		name: "lifecycle_policies", interval: cfg.lifecycleInterval, leaderOnly: true,
+		run: func(context.Context) error { return lifecycle.Run() },
+	}, time.Now())
+
+	vacuum := pgmodel.NewSeriesVacuum(client.Connection, client.EvictSeries, cfg.seriesVacuumGrace)
+	admin.handle(http.DefaultServeMux, "/api/v1/admin/series_vacuum", "series_vacuum", vacuumSeries(vacuum))
+	maintenance.add(maintenanceJob{
+		name: "series_vacuum", interval: cfg.seriesVacuum, leaderOnly: true,
+		run: func(ctx context.Context) error {
+			_, err := vacuum.Run(ctx)
+			return err
+		},
+	}, time.Now())
+
+	// The tombstones left by a grace period set before are purged too.
+	maintenance.add(maintenanceJob{
+		name: "tombstone_purge", interval: tombstonePurgeInterval, leaderOnly: true,
+		run: func(ctx context.Context) error {
+			_, err := deleter.PurgeTombstones(ctx)
+			return err
+		},
+	}, time.Now())
+
+	if cfg.chunkIntervals.TargetSizeMB > 0 {
+		tuner := pgmodel.NewChunkIntervalTuner(client.Connection, cfg.chunkIntervals, client.IngestedSamples)
+		maintenance.add(maintenanceJob{
+			name: "chunk_interval_tuning", interval: cfg.chunkTuning, leaderOnly: true,
+			run: func(context.Context) error { return tuner.Run() },
+		}, time.Now())
+	}
+
+	http.Handle("/maintenance", auth.wrap("maintenance", maintenanceStatusHandler(maintenance)))
+	maintenanceCtx, stopMaintenance := context.WithCancel(context.Background())
+	defer stopMaintenance()
+	go maintenance.run(maintenanceCtx)
 
 	log.Info("msg", "Starting up...")
-	log.Info("msg", "Listening", "addr", cfg.listenAddr)
+	log.Info("msg", "Listening", "addr", cfg.listenAddr, "tls", cfg.tls.enabled())
 
-	err = http.ListenAndServe(cfg.listenAddr, nil)
+	err = cfg.tls.listenAndServe(cfg.listenAddr, http.DefaultServeMux)
 
 	if err != nil {
 		log.Error("msg", "Listen failure", "err", err)
@@ -206,7 +515,10 @@ func main() {
 	}
 }
 
-func parseFlags() *config {
+// parseFlags parses the flags, then applies the configuration file to the
+// flags not set on the command line nor by the environment. It returns the
+// reloader of the configuration, to be completed by the caller.
+func parseFlags() (*config, *configReloader, error) {
 
 	cfg := &config{}
 
@@ -215,16 +527,127 @@ func parseFlags() *config {
 	flag.StringVar(&cfg.listenAddr, "web-listen-address", ":9201", "Address to listen on for web endpoints.")
 	flag.StringVar(&cfg.telemetryPath, "web-telemetry-path", "/metrics", "Address to listen on for web endpoints.")
 	flag.StringVar(&cfg.logLevel, "log-level", "debug", "The log level to use [ \"error\", \"warn\", \"info\", \"debug\" ].")
+	flag.StringVar(&cfg.logFormat, "log-format", log.FormatJSON, "The format of the logs [ \"json\", \"logfmt\" ].")
+	flag.StringVar(&cfg.logComponents, "log-component-levels", "", "Comma-separated component=level pairs overriding the log level of the ingest, query and migrate components, such as ingest=warn,query=debug.")
 	flag.IntVar(&cfg.haGroupLockID, "leader-election-pg-advisory-lock-id", 0, "Unique advisory lock id per adapter high-availability group. Set it if you want to use leader election implementation based on PostgreSQL advisory lock.")
 	flag.DurationVar(&cfg.prometheusTimeout, "leader-election-pg-advisory-lock-prometheus-timeout", -1, "Adapter will resign if there are no requests from Prometheus within a given timeout (0 means no timeout). "+
 		"Note: make sure that only one Prometheus instance talks to the adapter. Timeout value should be co-related with Prometheus scrape interval but add enough `slack` to prevent random flips.")
 	flag.BoolVar(&cfg.restElection, "leader-election-rest", false, "Enable REST interface for the leader election")
 	flag.DurationVar(&cfg.electionInterval, "scheduled-election-interval", 5*time.Second, "Interval at which scheduled election runs. This is used to select a leader and confirm that we still holding the advisory lock.")
-	flag.BoolVar(&cfg.migrate, "migrate", true, "Update the Prometheus SQL to the latest version")
+	cfg.migrate = migrateInstall
+	cfg.schemaMismatch = schemaMismatchFail
+	flag.Var(&cfg.schemaMismatch, "schema-version-mismatch", "What to do when the installed Prometheus SQL is not the version this connector supports: refuse to start (fail), "+
+		"or start without accepting writes (read-only). A schema not installed or partially migrated always fails.")
+	flag.Var(&cfg.migrate, "migrate", "Update the Prometheus SQL to the latest version at startup (install), and exit (only), or not at all (skip). "+
+		"true and false are the same as install and skip.")
+	flag.StringVar(&cfg.migrateRepair, "migrate-repair", "", "Repair a partially applied or modified schema migration before migrating. "+
+		"Only pass the repair token printed by the failed migration after inspecting the database.")
+	flag.IntVar(&cfg.migrateDownTo, "migrate-down-to", -1, "Revert the Prometheus SQL to the given version and exit, 0 dropping it along with all the stored data (-1 disables it). "+
+		"Requires -migrate-down-confirm, or typing the name of the database when prompted.")
+	flag.StringVar(&cfg.migrateDownDB, "migrate-down-confirm", "", "Name of the database to revert with -migrate-down-to, confirming the down migration.")
+	flag.BoolVar(&cfg.extensions.Disabled, "extension-update-disabled", false, "Do not update the timescaledb and timescale_prometheus_extra extensions when migrating, only logging the updates available. "+
+		"By default they are updated to their newest version installed on the server, or to -timescaledb-version and -extra-extension-version.")
+	flag.StringVar(&cfg.extensions.TimescaleDBVersion, "timescaledb-version", "", "Version the timescaledb extension is updated to when migrating, instead of the newest one installed on the server.")
+	flag.StringVar(&cfg.extensions.ExtraVersion, "extra-extension-version", "", "Version the timescale_prometheus_extra extension is updated to when migrating, instead of the newest one installed on the server.")
+	flag.BoolVar(&cfg.migrateDownDryRun, "migrate-down-dry-run", false, "Print the data -migrate-down-to would destroy and the SQL it would run, and exit without changing anything.")
+	flag.DurationVar(&cfg.selfTelemetry, "self-telemetry-interval", 0, "Interval at which the connector writes its own metrics into the database under job=\""+selfTelemetryJob+"\" (0 disables it).")
+	flag.DurationVar(&cfg.lifecycleInterval, "lifecycle-interval", 5*time.Minute, "Interval at which the leader rolls up the metrics with a lifecycle policy and drops their expired rollups (0 disables it).")
+	flag.DurationVar(&cfg.seriesVacuum, "series-vacuum-interval", time.Hour, "Interval at which the leader deletes the series left without samples from the catalog (0 disables it).")
+	flag.DurationVar(&cfg.seriesVacuumGrace, "series-vacuum-grace-period", pgmodel.DefaultSeriesVacuumGracePeriod, "How long the series vacuum keeps the series after their creation, while their first samples may still be in flight.")
+	flag.DurationVar(&cfg.deleteSeriesGrace, "delete-series-grace-period", 0, "How long the samples deleted through /api/v1/admin/tsdb/delete_series are tombstoned, hidden from the reads but still able to be undeleted, before the leader purges them (0 deletes them at once).")
+	flag.IntVar(&cfg.chunkIntervals.TargetSizeMB, "chunk-interval-target-size-mb", 0, "Uncompressed size of the chunks the leader adapts the chunk interval of each metric to, from its ingest rate (0 disables it). "+
+		"Metrics whose interval was set with prom_api.set_metric_chunk_interval are left alone.")
+	flag.DurationVar(&cfg.chunkIntervals.Min, "chunk-interval-min", 30*time.Minute, "Minimum chunk interval set by chunk-interval-target-size-mb.")
+	flag.DurationVar(&cfg.chunkIntervals.Max, "chunk-interval-max", 7*24*time.Hour, "Maximum chunk interval set by chunk-interval-target-size-mb.")
+	flag.DurationVar(&cfg.chunkTuning, "chunk-interval-tuning-interval", time.Hour, "Interval over which the ingest rates adapting the chunk intervals are measured.")
+	flag.IntVar(&cfg.maintenance.maxConcurrent, "maintenance-max-concurrent-jobs", 1, "Number of background maintenance jobs, such as the lifecycle policies and the series vacuum, running at once.")
+	flag.StringVar(&cfg.maintenance.windows, "maintenance-windows", "", "Comma-separated daily UTC time ranges, such as 22:00-06:00, in which the background maintenance jobs start. Empty means any time. The metric finalization and the series count of -write-max-series start any time.")
+	flag.Float64Var(&cfg.maintenance.rate, "maintenance-job-rate", 0, "Number of background maintenance job runs started per hour (0 means unlimited).")
+	flag.IntVar(&cfg.maintenance.burst, "maintenance-job-burst", 3, "Number of background maintenance job runs started at once above -maintenance-job-rate.")
+	flag.StringVar(&cfg.tls.certFile, "web-tls-cert-file", "", "Certificate file serving the web endpoints over HTTPS. Requires -web-tls-key-file.")
+	flag.StringVar(&cfg.tls.keyFile, "web-tls-key-file", "", "Private key file of -web-tls-cert-file.")
+	flag.StringVar(&cfg.tls.clientCAFile, "web-tls-client-ca-file", "", "CA certificates file verifying client certificates. When set, requests without a client certificate signed by it are rejected, except to -web-tls-client-auth-exempt-paths.")
+	flag.StringVar(&cfg.tls.exemptPaths, "web-tls-client-auth-exempt-paths", "/healthz,/ready", "Comma-separated paths served without a client certificate. A path ending with a slash exempts everything below it.")
+	flag.StringVar(&cfg.auth.bearerTokensFile, "auth-bearer-tokens-file", "", "File of bearer tokens accepted on /write and /read, one per line.")
+	flag.StringVar(&cfg.auth.htpasswdFile, "auth-htpasswd-file", "", "htpasswd file of the users accepted on /write and /read with basic authentication. Only bcrypt hashes are supported.")
+	flag.StringVar(&cfg.auth.adminUsers, "auth-admin-users", "", "Comma-separated users of -auth-htpasswd-file allowed on the admin API, whose remote reads are not redacted by -read-redaction-rules-file.")
+	flag.StringVar(&cfg.auth.adminTokensFile, "auth-admin-bearer-tokens-file", "", "File of bearer tokens allowed on the admin API, and on /write and /read without redaction, one per line.")
+	flag.BoolVar(&cfg.enableAdminAPI, "web-enable-admin-api", false, "Serve the admin endpoints reporting or changing the stored data and the connector, such as /api/v1/admin/stats, /api/v1/admin/tsdb/delete_series or /admin/loglevel, "+
+		"only to the admins of -auth-admin-users and -auth-admin-bearer-tokens-file if authentication is configured.")
+	flag.Float64Var(&cfg.limits.samplesPerSecond, "write-max-samples-per-second", 0, "Maximum rate of samples accepted on /write (0 means unlimited). Writes over it are rejected with 429 Too Many Requests and a Retry-After header.")
+	flag.Int64Var(&cfg.limits.samplesBurst, "write-samples-burst", 0, "Number of samples accepted at once above write-max-samples-per-second (0 means one second worth of samples).")
+	flag.Int64Var(&cfg.limits.maxSeries, "write-max-series", 0, "Maximum number of series stored in the database (0 means unlimited). Once reached, writes are rejected with 429 Too Many Requests. The count is refreshed every "+seriesCountInterval.String()+".")
+	flag.Int64Var(&cfg.limits.maxBodyBytes, "write-max-body-bytes", 0, "Maximum size of a compressed write request body (0 means unlimited). Larger requests are rejected with 413 Request Entity Too Large.")
+	flag.Int64Var(&cfg.queryLimits.MaxSeries, "query-max-series", 0, "Maximum number of series returned by a remote read (0 means unlimited). Reads over it fail with 422 Unprocessable Entity.")
+	flag.Int64Var(&cfg.queryLimits.MaxSamples, "query-max-samples", 0, "Maximum number of samples returned by a remote read (0 means unlimited). Reads over it fail with 422 Unprocessable Entity.")
+	flag.DurationVar(&cfg.queryLimits.StatementTimeout, "query-statement-timeout", 0, "statement_timeout of the SQL queries run by remote reads (0 means the database default).")
+	flag.BoolVar(&cfg.queryLimits.Truncate, "query-limits-truncate", false, "Return the series within -query-max-series and -query-max-samples with a warning instead of failing the reads over them.")
+	flag.BoolVar(&cfg.serviceMonitor.enabled, "kubernetes-service-monitor", false, "When running in Kubernetes, create or update a Prometheus Operator ServiceMonitor scraping the metrics of the connector. Requires RBAC permissions to patch servicemonitors in the namespace of the pod.")
+	flag.StringVar(&cfg.serviceMonitor.name, "kubernetes-service-monitor-name", "timescale-prometheus-connector", "Name of the ServiceMonitor created with -kubernetes-service-monitor.")
+	flag.StringVar(&cfg.serviceMonitor.selector, "kubernetes-service-monitor-selector", "", "Labels of the Service exposing the connector, as comma-separated key=value pairs, which the ServiceMonitor selects.")
+	flag.StringVar(&cfg.serviceMonitor.labels, "kubernetes-service-monitor-labels", "", "Labels of the ServiceMonitor, as comma-separated key=value pairs, matching the serviceMonitorSelector of the Prometheus resource.")
+	flag.StringVar(&cfg.serviceMonitor.port, "kubernetes-service-monitor-port", "connector-port", "Name of the Service port serving the metrics.")
+	flag.DurationVar(&cfg.serviceMonitor.interval, "kubernetes-service-monitor-interval", 0, "Scrape interval set in the ServiceMonitor (0 means the Prometheus default).")
+	flag.StringVar(&cfg.capture.Dir, "write-capture-dir", "", "Directory where the bodies of the write requests are recorded, to be replayed with timescale-prometheus-replay. Empty disables capturing.")
+	flag.Int64Var(&cfg.capture.MaxSize, "write-capture-max-size", pgmodel.DefaultCaptureMaxSize, "Maximum number of bytes recorded in write-capture-dir. The oldest requests are deleted once it is reached.")
+	flag.StringVar(&cfg.traces.Endpoint, "tracing-otlp-endpoint", "", "Base URL of the OTLP/HTTP receiver the OpenTelemetry spans of the writes and reads are exported to, such as http://localhost:4318. Empty disables tracing.")
+	flag.StringVar(&cfg.traces.ServiceName, "tracing-service-name", tracing.DefaultServiceName, "service.name of the exported spans.")
+	flag.Float64Var(&cfg.traces.SampleRatio, "tracing-sample-ratio", 1, "Fraction of the traces started by the connector that are exported. Requests carrying a traceparent header follow the sampling decision of the caller.")
+	flag.BoolVar(&cfg.events.log, "events-log", false, "Log the operational events, such as metric tables created, lifecycle policies run, leadership changes or the database becoming unreachable.")
+	flag.StringVar(&cfg.events.webhookURL, "events-webhook-url", "", "URL the operational events are posted to as JSON. Empty disables it.")
+	flag.BoolVar(&cfg.events.table, "events-table", false, "Record the operational events in the _prom_catalog.event table.")
+	flag.StringVar(&cfg.events.dataLossWebhookURL, "data-loss-webhook-url", "", "URL the data_lost events are posted to as JSON, whenever the connector drops samples, to page on data loss independently of the scraped metrics. Empty disables it.")
+	flag.IntVar(&cfg.events.webhookRetries, "events-webhook-retries", events.DefaultWebhookRetries, "Number of times the delivery of an event to a webhook is retried, with an exponential backoff, on connection errors and 5xx or 429 responses.")
+	flag.StringVar(&cfg.graphite.tcpAddr, "graphite-listen-address", "", "TCP address to listen on for the Graphite plaintext protocol, such as :2003. Empty disables it.")
+	flag.StringVar(&cfg.graphite.udpAddr, "graphite-udp-listen-address", "", "UDP address to listen on for the Graphite plaintext protocol. Empty disables it.")
+	flag.StringVar(&cfg.graphite.mappingFile, "graphite-mapping-file", "", "YAML file of the rules mapping the dot-separated Graphite paths to metric names and labels. The paths matching no rule are stored as the metric named after the path.")
+	flag.IntVar(&cfg.graphite.batchSize, "graphite-batch-size", 1000, "Number of Graphite samples ingested at once.")
+	flag.DurationVar(&cfg.graphite.flushInterval, "graphite-flush-interval", time.Second, "Maximum time the Graphite samples received are held before being ingested.")
+	flag.StringVar(&cfg.grpc.listenAddr, "grpc-listen-address", "", "Address to serve the gRPC write service on, such as :9202, with the TLS and authentication of the web endpoints. Empty disables it.")
+	flag.BoolVar(&cfg.strict, "strict", false, "Fail at startup on suspicious configurations, such as authentication without TLS or a series cache smaller than the stored series, instead of warning about them.")
+	flag.StringVar(&cfg.configFile, configFileFlag, "", "YAML file of settings keyed by flag name, such as \"db-host: localhost\", applied to the flags not set on the command line nor by the environment. "+
+		"On SIGHUP, the log level and the write and query limits are reloaded from it, along with the relabel configs.")
 	envy.Parse("TS_PROM")
 	flag.Parse()
 
-	return cfg
+	reloader := &configReloader{fs: flag.CommandLine, cfg: cfg}
+	if cfg.configFile != "" {
+		var err error
+		reloader.explicit = explicitFlags(flag.CommandLine)
+		if reloader.applied, err = applyConfigFile(flag.CommandLine, cfg.configFile, reloader.explicit); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if cfg.pgmodelCfg.InstanceID == "" {
+		cfg.pgmodelCfg.InstanceID = generateInstanceID()
+	}
+
+	return cfg, reloader, nil
+}
+
+// generateInstanceID returns an identifier made of the hostname and a random
+// suffix, so that multiple instances on the same host remain distinguishable.
+func generateInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return hostname
+	}
+	return hostname + "-" + hex.EncodeToString(suffix)
+}
+
+func runHeartbeat(registry *pgmodel.InstanceRegistry) {
+	ticker := time.NewTicker(heartbeatInterval)
+	for {
+		if err := registry.Heartbeat(); err != nil {
+			log.Warn("msg", "Instance heartbeat failed", "err", err)
+		}
+		<-ticker.C
+	}
 }
 
 func initElector(cfg *config) (*util.Elector, error) {
@@ -240,7 +663,13 @@ func initElector(cfg *config) (*util.Elector, error) {
 	if cfg.prometheusTimeout == -1 {
 		return nil, fmt.Errorf("Prometheus timeout configuration must be set when using PG advisory lock")
 	}
-	lock, err := util.NewPgAdvisoryLock(cfg.haGroupLockID, cfg.pgmodelCfg.GetConnectionStr())
+	// The advisory lock is held by the session, so it cannot go through a
+	// transaction pooler.
+	connStr, err := cfg.pgmodelCfg.GetSessionConnectionStr()
+	if err != nil {
+		return nil, err
+	}
+	lock, err := util.NewPgAdvisoryLock(cfg.haGroupLockID, connStr)
 	if err != nil {
 		return nil, fmt.Errorf("Error creating advisory lock\nhaGroupLockId: %d\nerr: %s\n", cfg.haGroupLockID, err)
 	}
@@ -258,32 +687,47 @@ func initElector(cfg *config) (*util.Elector, error) {
 	return &scheduledElector.Elector, nil
 }
 
-func migrate(cfg *pgclient.Config) error {
+func migrate(cfg *pgclient.Config, repairToken string, extensions pgmodel.ExtensionUpdateConfig) error {
 	shouldWrite, err := isWriter()
 	if err != nil {
-		leaderGauge.Set(0)
+		setLeader(false)
 		return fmt.Errorf("isWriter check failed: %w", err)
 	}
 	if !shouldWrite {
-		leaderGauge.Set(0)
-		log.Debug("msg", fmt.Sprintf("Election id %v: Instance is not a leader. Won't update", elector.ID()))
+		setLeader(false)
+		migrateLogger.Debug("msg", fmt.Sprintf("Election id %v: Instance is not a leader. Won't update", elector.ID()))
 		return nil
 	}
 
-	leaderGauge.Set(1)
-	dbStd, err := sql.Open("pgx", cfg.GetConnectionStr())
+	setLeader(true)
+	// The migrations hold advisory locks across transactions.
+	connStr, err := cfg.GetSessionConnectionStr()
+	if err != nil {
+		return err
+	}
+	dbStd, err := sql.Open("pgx", connStr)
 	if err != nil {
 		return fmt.Errorf("Error while trying to open DB connection: %w", err)
 	}
 	defer func() {
 		err := dbStd.Close()
 		if err != nil {
-			log.Error("msg", "Error while trying to close DB connection: %s", err)
+			migrateLogger.Error("msg", "Error while trying to close DB connection: %s", err)
 		}
 	}()
 
-	err = pgmodel.Migrate(dbStd, pgmodel.VersionInfo{Version: Version, CommitHash: CommitHash})
+	if repairToken != "" {
+		if err = pgmodel.RepairMigrations(dbStd, repairToken); err != nil {
+			return fmt.Errorf("Error while trying to repair DB migrations: %w", err)
+		}
+	}
 
+	err = pgmodel.MigrateWithOptions(dbStd, pgmodel.VersionInfo{Version: Version, CommitHash: CommitHash}, pgmodel.MigrateOptions{Extensions: extensions})
+
+	var repairErr *pgmodel.MigrationRepairError
+	if errors.As(err, &repairErr) {
+		return fmt.Errorf("Error while trying to migrate DB: %w. After inspecting the database, restart with -migrate-repair=%s to repair it", err, repairErr.RepairToken())
+	}
 	if err != nil {
 		return fmt.Errorf("Error while trying to migrate DB: %w", err)
 	}
@@ -291,79 +735,300 @@ func migrate(cfg *pgclient.Config) error {
 	return nil
 }
 
-func write(writer pgmodel.DBInserter) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		shouldWrite, err := isWriter()
+// migrateDown reverts the schema after printing the data about to be
+// destroyed. It refuses to run unless confirmDatabase, or the answer read from
+// prompt if not nil, names the database the connector is configured to use.
+// A dry run prints the SQL of the down migrations instead of running them.
+func migrateDown(cfg *pgclient.Config, target uint, confirmDatabase string, dryRun bool, prompt io.Reader) error {
+	connStr, err := cfg.GetSessionConnectionStr()
+	if err != nil {
+		return err
+	}
+	dbStd, err := sql.Open("pgx", connStr)
+	if err != nil {
+		return fmt.Errorf("Error while trying to open DB connection: %w", err)
+	}
+	defer func() {
+		err := dbStd.Close()
 		if err != nil {
-			leaderGauge.Set(0)
-			log.Error("msg", "IsLeader check failed", "err", err)
-			return
+			migrateLogger.Error("msg", "Error while trying to close DB connection: %s", err)
 		}
-		if !shouldWrite {
-			leaderGauge.Set(0)
-			log.Debug("msg", fmt.Sprintf("Election id %v: Instance is not a leader. Can't write data", elector.ID()))
-			return
+	}()
+
+	printed := false
+	if dryRun || prompt != nil {
+		plan, err := pgmodel.DownMigrationPlan(dbStd, target)
+		if err != nil {
+			return err
+		}
+		fmt.Print(plan)
+		if dryRun {
+			fmt.Print(plan.SQL())
+			return nil
 		}
+		confirmDatabase = promptDatabaseName(plan, prompt, os.Stdout)
+		printed = true
+	}
 
-		leaderGauge.Set(1)
+	report, err := pgmodel.MigrateDown(dbStd, target, confirmDatabase)
+	if report != nil && !printed {
+		fmt.Print(report)
+	}
+	return err
+}
 
-		compressed, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			log.Error("msg", "Read error", "err", err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+// promptDatabaseName asks the operator to type the name of the database
+// about to be migrated down, and returns the answer.
+func promptDatabaseName(plan *pgmodel.DownMigrationReport, in io.Reader, out io.Writer) string {
+	fmt.Fprintf(out, "Type the name of the database, %q, to confirm the down migration: ", plan.Database)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	return strings.TrimSpace(answer)
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func write(writer pgmodel.DBInserter, limits *writeLimiter, capture *pgmodel.RequestCapture) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := readWriteBody(w, r, limits)
+		if !ok {
 			return
 		}
+		encoding := writeEncoding(r)
+		reqBuf, err := decodeWriteBody(encoding, body)
+		var (
+			req *prompb.WriteRequest
+			v2  *pgmodel.RemoteWriteV2Stats
+		)
+		if err == nil {
+			req, v2, err = parseWriteRequest(r.Header.Get("Content-Type"), reqBuf)
+		}
+
+		if capture != nil {
+			// The captures are replayed as snappy compressed 1.0 requests, as
+			// Prometheus sends them.
+			captured := body
+			switch {
+			case v2 != nil:
+				data, _ := proto.Marshal(req)
+				captured = snappy.Encode(nil, data)
+			case req != nil && encoding != "snappy":
+				captured = snappy.Encode(nil, reqBuf)
+			}
+			if err := capture.Capture(captured); err != nil {
+				ingestLogger.Warn("msg", "Capturing write request failed", "err", err)
+			}
+		}
 
 		atomic.StoreInt64(&lastRequestUnixNano, time.Now().UnixNano())
 
-		reqBuf, err := snappy.Decode(nil, compressed)
 		if err != nil {
-			log.Error("msg", "Decode error", "err", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			ingestLogger.Error("msg", "Decode error", "encoding", encoding, "err", err.Error())
+			writeDecodeError(w, err)
 			return
 		}
 
-		req := pgmodel.NewWriteRequest()
-		if err := proto.Unmarshal(reqBuf, req); err != nil {
-			log.Error("msg", "Unmarshal error", "err", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if v2 != nil {
+			ingestWriteV2(w, r, writer, limits, req, *v2, body)
 			return
 		}
+		writeProtocolRequests.WithLabelValues(writeProtocolV1).Inc()
+		ingestWrite(w, r, writer, limits, req, body)
+	})
+}
 
-		ts := req.GetTimeseries()
-		receivedBatchCount := 0
+// readWriteBody reads the body of a write, once this instance is known to be
+// the leader and the write within the limits. Otherwise, it responds and
+// returns false.
+func readWriteBody(w http.ResponseWriter, r *http.Request, limits *writeLimiter) ([]byte, bool) {
+	if !acceptWrites() {
+		return nil, false
+	}
 
-		for _, t := range ts {
-			receivedBatchCount = receivedBatchCount + len(t.Samples)
-		}
+	if !limits.checkSeries() {
+		rejectWrite(w, limitReasonSeries, http.StatusTooManyRequests, seriesCountInterval)
+		return nil, false
+	}
+	if !limits.checkBodySize(r.ContentLength) {
+		rejectWrite(w, limitReasonBodySize, http.StatusRequestEntityTooLarge, 0)
+		return nil, false
+	}
 
-		receivedSamples.Add(float64(receivedBatchCount))
-		begin := time.Now()
+	body := r.Body
+	if n := limits.bodyLimit(); n > 0 {
+		body = ioutil.NopCloser(io.LimitReader(r.Body, n))
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		ingestLogger.Error("msg", "Read error", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if !limits.checkBodySize(int64(len(data))) {
+		rejectWrite(w, limitReasonBodySize, http.StatusRequestEntityTooLarge, 0)
+		return nil, false
+	}
+	return data, true
+}
 
-		numSamples, err := writer.Ingest(req.GetTimeseries(), req)
-		if err != nil {
-			log.Warn("msg", "Error sending samples to remote storage", "err", err, "num_samples", numSamples)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			failedSamples.Add(float64(receivedBatchCount))
-			return
-		}
+// acceptWrites reports whether this instance writes, as the leader of the
+// election, if any. The other instances drop the writes they receive
+// without an error.
+func acceptWrites() bool {
+	shouldWrite, err := isWriter()
+	if err != nil {
+		setLeader(false)
+		ingestLogger.Error("msg", "IsLeader check failed", "err", err)
+		return false
+	}
+	if !shouldWrite {
+		setLeader(false)
+		ingestLogger.Debug("msg", fmt.Sprintf("Election id %v: Instance is not a leader. Can't write data", elector.ID()))
+		return false
+	}
 
-		duration := time.Since(begin).Seconds()
+	setLeader(true)
+	return true
+}
 
+// ingestWrite ingests the samples of a write and responds with the outcome.
+// body identifies the retries of the write.
+func ingestWrite(w http.ResponseWriter, r *http.Request, writer pgmodel.DBInserter, limits *writeLimiter, req *prompb.WriteRequest, body []byte) {
+	respondIngest(w, ingestSamples(r.Context(), writer, limits, req, body))
+}
+
+// respondIngest responds with the outcome of ingestSamples.
+func respondIngest(w http.ResponseWriter, err error) {
+	var (
+		limited     *writeLimitError
+		partial     *pgmodel.PartialWriteError
+		outOfBounds *pgmodel.SamplesOutOfBoundsError
+		invalid     *pgmodel.InvalidSeriesError
+	)
+	switch {
+	case err == nil:
+	case errors.As(err, &limited):
+		rejectWrite(w, limited.reason, http.StatusTooManyRequests, limited.retryAfter)
+	case errors.As(err, &partial):
+		writePartialFailure(w, partial)
+	case errors.As(err, &outOfBounds), errors.As(err, &invalid), errors.Is(err, pgmodel.ErrInvalidHistogram):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, pgmodel.ErrInFlightLimitExceeded):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, errReadOnly):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeLimitError is returned by ingestSamples for the writes rejected by
+// the write limits.
+type writeLimitError struct {
+	reason     string
+	retryAfter time.Duration
+}
+
+func (e *writeLimitError) Error() string {
+	return fmt.Sprintf("write limit exceeded: %s", e.reason)
+}
+
+// ingestSamples ingests the samples of a write, whichever protocol it was
+// received with, and counts the outcome. body identifies the retries of the
+// write.
+func ingestSamples(ctx context.Context, writer pgmodel.DBInserter, limits *writeLimiter, req *prompb.WriteRequest, body []byte) error {
+	ts := req.GetTimeseries()
+	receivedBatchCount := 0
+
+	for _, t := range ts {
+		receivedBatchCount = receivedBatchCount + len(t.Samples)
+	}
+
+	receivedSamples.Add(float64(receivedBatchCount))
+	tracing.FromContext(ctx).SetAttributes(
+		tracing.Int("series", int64(len(ts))),
+		tracing.Int("samples", int64(receivedBatchCount)),
+	)
+	if retryAfter, ok := limits.admitSamples(int64(receivedBatchCount), time.Now()); !ok {
+		return &writeLimitError{reason: limitReasonSamplesRate, retryAfter: retryAfter}
+	}
+	begin := time.Now()
+
+	// Prometheus retries a failed request with the same body, so its hash
+	// identifies the retries of a partially failed write.
+	bodyHash := sha256.Sum256(body)
+	ctx = pgmodel.WithWriteID(ctx, hex.EncodeToString(bodyHash[:]))
+	numSamples, err := writer.Ingest(ctx, req.GetTimeseries(), req)
+	var partial *pgmodel.PartialWriteError
+	if errors.As(err, &partial) {
+		ingestLogger.Warn("msg", "Some metrics failed to be sent to remote storage", "err", err, "num_samples", numSamples)
+		if received := uint64(receivedBatchCount); received > numSamples {
+			failedSamples.Add(float64(received - numSamples))
+		}
+		sentSamples.Add(float64(numSamples))
+		return err
+	}
+	var outOfBounds *pgmodel.SamplesOutOfBoundsError
+	if errors.As(err, &outOfBounds) {
+		ingestLogger.Warn("msg", "Samples out of bounds rejected", "err", err, "num_samples", numSamples)
+		failedSamples.Add(float64(outOfBounds.Rejected()))
+		sentSamples.Add(float64(numSamples))
+		return err
+	}
+	var invalid *pgmodel.InvalidSeriesError
+	if errors.As(err, &invalid) {
+		ingestLogger.Warn("msg", "Series with invalid labels rejected", "err", err, "num_samples", numSamples)
+		failedSamples.Add(float64(invalid.Samples))
 		sentSamples.Add(float64(numSamples))
-		sentBatchDuration.Observe(duration)
+		return err
+	}
+	if err != nil {
+		ingestLogger.Warn("msg", "Error sending samples to remote storage", "err", err, "num_samples", numSamples)
+		failedSamples.Add(float64(receivedBatchCount))
+		return err
+	}
 
-		writeThroughput.SetCurrent(getCounterValue(sentSamples))
+	duration := time.Since(begin).Seconds()
 
-		select {
-		case d := <-writeThroughput.Values:
-			if reportTput {
-				log.Info("msg", "Samples write throughput", "samples/sec", d)
-			}
-		default:
+	sentSamples.Add(float64(numSamples))
+	sentBatchDuration.Observe(duration)
+
+	writeThroughput.SetCurrent(getCounterValue(sentSamples))
+
+	select {
+	case d := <-writeThroughput.Values:
+		if reportTput {
+			ingestLogger.Info("msg", "Samples write throughput", "samples/sec", d)
 		}
+	default:
+	}
+	return nil
+}
 
-	})
+// partialWriteResponse lists the metrics of a write which failed. The
+// response keeps a 500 status so that Prometheus retries the write, which
+// then only inserts the failed metrics.
+type partialWriteResponse struct {
+	InsertedSamples uint64            `json:"inserted_samples"`
+	FailedMetrics   map[string]string `json:"failed_metrics"`
+}
+
+func writePartialFailure(w http.ResponseWriter, partial *pgmodel.PartialWriteError) {
+	resp := partialWriteResponse{
+		InsertedSamples: partial.Inserted,
+		FailedMetrics:   make(map[string]string, len(partial.Failed)),
+	}
+	for metric, err := range partial.Failed {
+		resp.FailedMetrics[metric] = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		ingestLogger.Warn("msg", "Writing the partial write response failed", "err", err)
+	}
 }
 
 func isWriter() (bool, error) {
@@ -377,43 +1042,101 @@ func isWriter() (bool, error) {
 func getCounterValue(counter prometheus.Counter) float64 {
 	dtoMetric := &io_prometheus_client.Metric{}
 	if err := counter.Write(dtoMetric); err != nil {
-		log.Warn("msg", "Error reading counter value", "err", err, "sentSamples", sentSamples)
+		ingestLogger.Warn("msg", "Error reading counter value", "err", err, "sentSamples", sentSamples)
 	}
 	return dtoMetric.GetCounter().GetValue()
 }
 
-func read(reader pgmodel.Reader) http.Handler {
+func read(reader pgmodel.Reader, limits *globalQueryLimits) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqLimits, err := requestQueryLimits(r.Header, limits.get())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx := pgmodel.WithQueryLimits(r.Context(), reqLimits)
+		ctx, warnings := pgmodel.WithQueryWarnings(ctx)
+		if v := r.Header.Get(querySnapshotHeader); v != "" {
+			snapshot, err := strconv.ParseBool(v)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid %s header %q", querySnapshotHeader, v), http.StatusBadRequest)
+				return
+			}
+			ctx = pgmodel.WithSnapshotReads(ctx, snapshot)
+		}
+		switch precision := r.Header.Get(timestampPrecisionHeader); precision {
+		case "", timestampPrecisionMillis:
+		case timestampPrecisionMicros:
+			ctx = pgmodel.WithMicrosecondPrecision(ctx)
+			w.Header().Set(timestampPrecisionHeader, timestampPrecisionMicros)
+		default:
+			http.Error(w, fmt.Sprintf("invalid %s header %q, expected %q or %q", timestampPrecisionHeader, precision, timestampPrecisionMillis, timestampPrecisionMicros), http.StatusBadRequest)
+			return
+		}
+
 		compressed, err := ioutil.ReadAll(r.Body)
 		if err != nil {
-			log.Error("msg", "Read error", "err", err.Error())
+			queryLogger.Error("msg", "Read error", "err", err.Error())
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		reqBuf, err := snappy.Decode(nil, compressed)
 		if err != nil {
-			log.Error("msg", "Decode error", "err", err.Error())
+			queryLogger.Error("msg", "Decode error", "err", err.Error())
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		var req prompb.ReadRequest
 		if err := proto.Unmarshal(reqBuf, &req); err != nil {
-			log.Error("msg", "Unmarshal error", "err", err.Error())
+			queryLogger.Error("msg", "Unmarshal error", "err", err.Error())
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		queryCount := float64(len(req.Queries))
 		receivedQueries.Add(queryCount)
+		tracing.FromContext(r.Context()).SetAttributes(tracing.Int("queries", int64(len(req.Queries))))
 		begin := time.Now()
 
+		if sr, ok := reader.(pgmodel.StreamReader); ok && negotiateResponseType(req.AcceptedResponseTypes) == prompb.ReadRequest_STREAMED_XOR_CHUNKS {
+			f, ok := w.(http.Flusher)
+			if !ok {
+				http.Error(w, "internal http.ResponseWriter does not implement http.Flusher interface", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/x-streamed-protobuf; proto=prometheus.ChunkedReadResponse")
+			// The warnings are only known once the results are streamed.
+			w.Header().Set("Trailer", queryWarningsHeader)
+
+			chunked := pgmodel.NewChunkedWriter(w, f)
+			err = sr.ReadStreamed(ctx, &req, chunked)
+			if err != nil {
+				failedQueries.Add(queryCount)
+				if chunked.Written() {
+					// The status and the first frames are sent already, so
+					// the error cannot be reported. Abort the response, so
+					// that the client sees it truncated instead of complete.
+					queryLogger.Error("msg", "Aborting streamed query after error", "query", req, "storage", "PostgreSQL", "err", err)
+					panic(http.ErrAbortHandler)
+				}
+				queryLogger.Warn("msg", "Error executing streamed query", "query", req, "storage", "PostgreSQL", "err", err)
+				queryError(w, err)
+				return
+			}
+			setQueryWarnings(w.Header(), warnings)
+
+			queryBatchDuration.Observe(time.Since(begin).Seconds())
+			return
+		}
+
 		var resp *prompb.ReadResponse
-		resp, err = reader.Read(&req)
+		resp, err = reader.Read(ctx, &req)
 		if err != nil {
-			log.Warn("msg", "Error executing query", "query", req, "storage", "PostgreSQL", "err", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			queryLogger.Warn("msg", "Error executing query", "query", req, "storage", "PostgreSQL", "err", err)
+			queryError(w, err)
 			failedQueries.Add(queryCount)
 			return
 		}
@@ -429,6 +1152,7 @@ func read(reader pgmodel.Reader) http.Handler {
 
 		w.Header().Set("Content-Type", "application/x-protobuf")
 		w.Header().Set("Content-Encoding", "snappy")
+		setQueryWarnings(w.Header(), warnings)
 
 		compressed = snappy.Encode(nil, data)
 		if _, err := w.Write(compressed); err != nil {
@@ -438,6 +1162,19 @@ func read(reader pgmodel.Reader) http.Handler {
 	})
 }
 
+// negotiateResponseType returns the first response type accepted by the
+// client, in the client's order of preference. Clients which don't send any
+// accepted types only understand SAMPLES.
+func negotiateResponseType(accepted []prompb.ReadRequest_ResponseType) prompb.ReadRequest_ResponseType {
+	for _, t := range accepted {
+		switch t {
+		case prompb.ReadRequest_SAMPLES, prompb.ReadRequest_STREAMED_XOR_CHUNKS:
+			return t
+		}
+	}
+	return prompb.ReadRequest_SAMPLES
+}
+
 func health(hc pgmodel.HealthChecker) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		err := hc.HealthCheck()
@@ -450,6 +1187,121 @@ func health(hc pgmodel.HealthChecker) http.Handler {
 	})
 }
 
+// queryError replies with the error of a failed query. Queries failing
+// during a schema migration get a 503, telling Prometheus to retry later
+// instead of reporting the SQL error.
+func queryError(w http.ResponseWriter, err error) {
+	if errors.Is(err, pgmodel.ErrMigrationInProgress) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(pgmodel.DefaultMigrationWait.Seconds())))
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		return
+	}
+	if errors.Is(err, pgmodel.ErrQueryLimitExceeded) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, pgmodel.ErrTenantRequired) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// ready reports every health check as JSON, and fails with 503 Service
+// Unavailable when the connector should not receive traffic.
+func ready(rc pgmodel.ReadinessChecker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report, err := rc.ReadinessCheck()
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			log.Warn("msg", "Readiness check failed", "err", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			log.Warn("msg", "Writing readiness report failed", "err", err)
+		}
+	})
+}
+
+// electionState is the leadership of this instance as reported by
+// /admin/election/status.
+type electionState struct {
+	Enabled bool   `json:"enabled"`
+	ID      string `json:"id,omitempty"`
+	Leader  bool   `json:"leader"`
+}
+
+// electionStatus reports whether this instance is the one writing for its
+// high-availability group. Without leader election every instance writes.
+func electionStatus(e *util.Elector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := electionState{Leader: true}
+		if e != nil {
+			leader, err := e.IsLeader()
+			if err != nil {
+				log.Warn("msg", "Leader check failed", "err", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			state = electionState{Enabled: true, ID: e.ID(), Leader: leader}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(state); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+func instances(lister pgmodel.InstanceLister) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		list, err := lister.Instances()
+		if err != nil {
+			log.Warn("msg", "Listing instances failed", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+func lifecyclePolicies(lister pgmodel.LifecyclePolicyLister) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		list, err := lister.LifecyclePolicies()
+		if err != nil {
+			log.Warn("msg", "Listing lifecycle policies failed", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+func ingestStats(reporter pgmodel.IngestStatsReporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(reporter.IngestedSamples()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
 // timeHandler uses Prometheus histogram to track request time
 func timeHandler(histogramVec prometheus.ObserverVec, path string, handler http.Handler) http.Handler {
 	f := func(w http.ResponseWriter, r *http.Request) {
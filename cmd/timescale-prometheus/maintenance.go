@@ -0,0 +1,387 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+const (
+	// maintenanceTick is how often the scheduler checks for due jobs.
+	maintenanceTick = time.Second
+	// metricFinalizationInterval is how often the metrics whose creation
+	// was not finalized, such as by a connector stopped in between, are
+	// finalized.
+	metricFinalizationInterval = 10 * time.Minute
+	// tombstonePurgeInterval is how often the samples of the tombstones
+	// whose grace period is over are purged.
+	tombstonePurgeInterval = 5 * time.Minute
+
+	jobStateScheduled     = "scheduled"
+	jobStateRunning       = "running"
+	jobStateWaitingWindow = "waiting_window"
+	jobStateWaitingSlot   = "waiting_slot"
+	jobStateWaitingTokens = "waiting_tokens"
+	jobStateNotLeader     = "not_leader"
+)
+
+var (
+	maintenanceJobRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ts_prom",
+			Name:      "maintenance_job_runs_total",
+			Help:      "Total number of runs of the background maintenance jobs, by job and result.",
+		},
+		[]string{"job", "result"},
+	)
+	maintenanceJobDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "ts_prom",
+			Name:      "maintenance_job_duration_seconds",
+			Help:      "Duration of the runs of the background maintenance jobs.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+		},
+		[]string{"job"},
+	)
+	maintenanceJobDelayed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ts_prom",
+			Name:      "maintenance_job_delays_total",
+			Help:      "Total number of checks finding a maintenance job due but delayed, by job and reason: waiting_window, waiting_slot or waiting_tokens.",
+		},
+		[]string{"job", "reason"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(maintenanceJobRuns)
+	prometheus.MustRegister(maintenanceJobDuration)
+	prometheus.MustRegister(maintenanceJobDelayed)
+}
+
+// maintenanceConfig sets how the background maintenance jobs share the
+// database with the ingestion.
+type maintenanceConfig struct {
+	// maxConcurrent is the number of jobs running at once.
+	maxConcurrent int
+	// windows are the daily UTC time ranges, such as 22:00-06:00, in which
+	// the jobs start, any time if empty. The jobs the ingestion depends on
+	// start any time.
+	windows string
+	// rate is the number of job runs started per hour, refilling a token
+	// bucket of burst runs. 0 does not limit them.
+	rate  float64
+	burst int
+}
+
+func (c maintenanceConfig) validate() error {
+	if c.maxConcurrent < 1 {
+		return fmt.Errorf("invalid number of concurrent maintenance jobs %d, expected at least 1", c.maxConcurrent)
+	}
+	if c.rate < 0 || (c.rate > 0 && c.burst < 1) {
+		return fmt.Errorf("invalid maintenance job rate %v and burst %d", c.rate, c.burst)
+	}
+	_, err := parseMaintenanceWindows(c.windows)
+	return err
+}
+
+// maintenanceWindow is a daily UTC time range, as offsets from midnight. It
+// wraps around midnight when end is before start.
+type maintenanceWindow struct {
+	start, end time.Duration
+}
+
+// parseMaintenanceWindows parses comma-separated HH:MM-HH:MM ranges.
+func parseMaintenanceWindows(s string) ([]maintenanceWindow, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var windows []maintenanceWindow
+	for _, r := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(r), "-")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid maintenance window %q, expected HH:MM-HH:MM", r)
+		}
+		var w maintenanceWindow
+		for i, p := range parts {
+			t, err := time.Parse("15:04", p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid maintenance window %q, expected HH:MM-HH:MM", r)
+			}
+			offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+			if i == 0 {
+				w.start = offset
+			} else {
+				w.end = offset
+			}
+		}
+		if w.start == w.end {
+			return nil, fmt.Errorf("empty maintenance window %q", r)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+func (w maintenanceWindow) contains(t time.Time) bool {
+	t = t.UTC()
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.start < w.end {
+		return offset >= w.start && offset < w.end
+	}
+	return offset >= w.start || offset < w.end
+}
+
+// maintenanceJob is a background job run periodically by the scheduler.
+type maintenanceJob struct {
+	name     string
+	interval time.Duration
+	// leaderOnly jobs only run on the connector writing the samples.
+	leaderOnly bool
+	// anytime jobs start outside of the maintenance windows too, since the
+	// ingestion depends on them.
+	anytime bool
+	// atStart jobs run as soon as the scheduler starts rather than after
+	// their first interval.
+	atStart bool
+	run     func(ctx context.Context) error
+}
+
+// maintenanceJobStatus is the state of a job reported by the status
+// endpoint.
+type maintenanceJobStatus struct {
+	Name         string     `json:"name"`
+	Interval     string     `json:"interval"`
+	LeaderOnly   bool       `json:"leader_only"`
+	Anytime      bool       `json:"anytime"`
+	State        string     `json:"state"`
+	NextRun      time.Time  `json:"next_run"`
+	LastStart    *time.Time `json:"last_start,omitempty"`
+	LastDuration float64    `json:"last_duration_seconds,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	Runs         int64      `json:"runs"`
+	Failures     int64      `json:"failures"`
+}
+
+type scheduledJob struct {
+	maintenanceJob
+	status maintenanceJobStatus
+}
+
+// maintenanceScheduler runs the background maintenance jobs, such as the
+// rollups and the series vacuum, so that the housekeeping does not compete
+// with the ingestion at its peak: at most maxConcurrent jobs run at once,
+// they start within the maintenance windows only, and their starts are
+// rate limited by a token bucket.
+type maintenanceScheduler struct {
+	cfg      maintenanceConfig
+	windows  []maintenanceWindow
+	tokens   *tokenBucket
+	isLeader func() (bool, error)
+
+	lock    sync.Mutex
+	jobs    []*scheduledJob
+	running int
+	wg      sync.WaitGroup
+}
+
+func newMaintenanceScheduler(cfg maintenanceConfig, isLeader func() (bool, error), now time.Time) (*maintenanceScheduler, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	windows, _ := parseMaintenanceWindows(cfg.windows)
+	s := &maintenanceScheduler{cfg: cfg, windows: windows, isLeader: isLeader}
+	if cfg.rate > 0 {
+		s.tokens = newTokenBucket(cfg.rate/3600, float64(cfg.burst), now)
+	}
+	return s, nil
+}
+
+// add schedules job. Jobs without a positive interval are disabled.
+func (s *maintenanceScheduler) add(job maintenanceJob, now time.Time) {
+	if job.interval <= 0 {
+		return
+	}
+	next := now.Add(job.interval)
+	if job.atStart {
+		next = now
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.jobs = append(s.jobs, &scheduledJob{
+		maintenanceJob: job,
+		status: maintenanceJobStatus{
+			Name:       job.name,
+			Interval:   job.interval.String(),
+			LeaderOnly: job.leaderOnly,
+			Anytime:    job.anytime,
+			State:      jobStateScheduled,
+			NextRun:    next,
+		},
+	})
+}
+
+// run starts the due jobs every maintenanceTick until ctx is canceled, then
+// waits for the running ones.
+func (s *maintenanceScheduler) run(ctx context.Context) {
+	ticker := time.NewTicker(maintenanceTick)
+	defer ticker.Stop()
+	for {
+		s.startDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// inWindow reports whether t is within a maintenance window.
+func (s *maintenanceScheduler) inWindow(t time.Time) bool {
+	if len(s.windows) == 0 {
+		return true
+	}
+	for _, w := range s.windows {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// startDue starts the jobs due at now, the most overdue first.
+func (s *maintenanceScheduler) startDue(ctx context.Context, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	due := make([]*scheduledJob, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		if j.status.State != jobStateRunning && !now.Before(j.status.NextRun) {
+			due = append(due, j)
+		}
+	}
+	sort.SliceStable(due, func(i, k int) bool { return due[i].status.NextRun.Before(due[k].status.NextRun) })
+
+	inWindow := s.inWindow(now)
+	for _, j := range due {
+		if j.leaderOnly {
+			leader, err := s.isLeader()
+			if err != nil || !leader {
+				// Like on the leader, the next check is an interval later.
+				j.status.State = jobStateNotLeader
+				j.status.NextRun = now.Add(j.interval)
+				continue
+			}
+		}
+		switch {
+		case !inWindow && !j.anytime:
+			s.delay(j, jobStateWaitingWindow)
+			continue
+		case s.running >= s.cfg.maxConcurrent:
+			s.delay(j, jobStateWaitingSlot)
+			continue
+		}
+		if s.tokens != nil {
+			if _, ok := s.tokens.take(1, now); !ok {
+				s.delay(j, jobStateWaitingTokens)
+				continue
+			}
+		}
+		s.start(ctx, j, now)
+	}
+}
+
+func (s *maintenanceScheduler) delay(j *scheduledJob, state string) {
+	if j.status.State != state {
+		maintenanceJobDelayed.WithLabelValues(j.name, state).Inc()
+	}
+	j.status.State = state
+}
+
+func (s *maintenanceScheduler) start(ctx context.Context, j *scheduledJob, now time.Time) {
+	s.running++
+	j.status.State = jobStateRunning
+	start := now
+	j.status.LastStart = &start
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		begin := time.Now()
+		err := j.run(ctx)
+		elapsed := time.Since(begin)
+
+		result := "success"
+		if err != nil {
+			result = "error"
+			log.Warn("msg", "Maintenance job failed", "job", j.name, "err", err)
+		}
+		maintenanceJobRuns.WithLabelValues(j.name, result).Inc()
+		maintenanceJobDuration.WithLabelValues(j.name).Observe(elapsed.Seconds())
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.running--
+		j.status.State = jobStateScheduled
+		j.status.Runs++
+		j.status.LastDuration = elapsed.Seconds()
+		j.status.LastError = ""
+		if err != nil {
+			j.status.Failures++
+			j.status.LastError = err.Error()
+		}
+		// A run longer than the interval is not caught up.
+		j.status.NextRun = start.Add(j.interval)
+		if finished := start.Add(elapsed); j.status.NextRun.Before(finished) {
+			j.status.NextRun = finished
+		}
+	}()
+}
+
+// maintenanceStatus is the response of the status endpoint.
+type maintenanceStatus struct {
+	MaxConcurrent int                    `json:"max_concurrent"`
+	Windows       string                 `json:"windows,omitempty"`
+	InWindow      bool                   `json:"in_window"`
+	Running       int                    `json:"running"`
+	Jobs          []maintenanceJobStatus `json:"jobs"`
+}
+
+func (s *maintenanceScheduler) status(now time.Time) maintenanceStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	res := maintenanceStatus{
+		MaxConcurrent: s.cfg.maxConcurrent,
+		Windows:       s.cfg.windows,
+		InWindow:      s.inWindow(now),
+		Running:       s.running,
+		Jobs:          make([]maintenanceJobStatus, 0, len(s.jobs)),
+	}
+	for _, j := range s.jobs {
+		res.Jobs = append(res.Jobs, j.status)
+	}
+	return res
+}
+
+// maintenanceStatusHandler reports the state of the maintenance jobs.
+func maintenanceStatusHandler(s *maintenanceScheduler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.status(time.Now())); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import "fmt"
+
+// migrateMode tells whether the connector migrates the schema at startup.
+const (
+	// migrateInstall migrates the schema before serving.
+	migrateInstall migrateMode = "install"
+	// migrateOnly migrates the schema and exits, such as in an init
+	// container or a job.
+	migrateOnly migrateMode = "only"
+	// migrateSkip serves without migrating, the schema being migrated by
+	// another connector or a job.
+	migrateSkip migrateMode = "skip"
+)
+
+// migrateMode is the value of the -migrate flag. It is a boolean flag for
+// compatibility: -migrate and -migrate=true install, -migrate=false skips.
+type migrateMode string
+
+func (m *migrateMode) String() string {
+	return string(*m)
+}
+
+func (m *migrateMode) Set(value string) error {
+	switch value {
+	case "true":
+		*m = migrateInstall
+	case "false":
+		*m = migrateSkip
+	case string(migrateInstall), string(migrateOnly), string(migrateSkip):
+		*m = migrateMode(value)
+	default:
+		return fmt.Errorf("invalid migrate mode %q, expected only, install or skip", value)
+	}
+	return nil
+}
+
+// IsBoolFlag lets -migrate be given without a value.
+func (m *migrateMode) IsBoolFlag() bool {
+	return true
+}
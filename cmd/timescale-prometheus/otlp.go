@@ -0,0 +1,68 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+// otlpMetricsPath is the path of the metrics of the OTLP/HTTP exporters.
+const otlpMetricsPath = "/v1/metrics"
+
+var otlpDroppedPoints = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: promNamespace,
+		Name:      "otlp_dropped_data_points_total",
+		Help:      "Total number of OTLP data points dropped for having no Prometheus equivalent, such as delta sums and exponential histograms.",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(otlpDroppedPoints)
+}
+
+// otlpMetrics receives the metrics exported by OpenTelemetry SDKs and
+// collectors over OTLP/HTTP, in protobuf or in JSON, and ingests them as
+// Prometheus series.
+func otlpMetrics(writer pgmodel.DBInserter, limits *writeLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		data, ok := readWriteBody(w, r, limits)
+		if !ok {
+			return
+		}
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(bytes.NewReader(data))
+			if err == nil {
+				data, err = ioutil.ReadAll(gz)
+			}
+			if err != nil {
+				ingestLogger.Error("msg", "OTLP decompression error", "err", err.Error())
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		req, dropped, err := pgmodel.ParseOTLPMetrics(data, r.Header.Get("Content-Type"))
+		if err != nil {
+			ingestLogger.Error("msg", "OTLP parse error", "err", err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		otlpDroppedPoints.Add(float64(dropped))
+		ingestWrite(w, r, writer, limits, req, data)
+	})
+}
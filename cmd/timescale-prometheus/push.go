@@ -0,0 +1,72 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+const pushPath = "/metrics/push"
+
+// push accepts metrics pushed in the Prometheus text exposition format, or
+// in OpenMetrics, like the Pushgateway does, for the batch jobs that cannot
+// be scraped. The labels of the path after pushPath, such as
+// /metrics/push/job/backup/instance/db1, are set on every pushed series.
+// Unlike the Pushgateway, the samples are stored right away, and a PUT does
+// not delete the series previously pushed.
+func push(writer pgmodel.DBInserter, limits *writeLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			w.Header().Set("Allow", "POST, PUT")
+			http.Error(w, "only POST and PUT are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		grouping, err := parseGroupingPath(strings.TrimPrefix(r.URL.Path, pushPath))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		data, ok := readWriteBody(w, r, limits)
+		if !ok {
+			return
+		}
+		req, err := pgmodel.ParseExposition(data, r.Header.Get("Content-Type"), time.Now(), grouping)
+		if err != nil {
+			ingestLogger.Error("msg", "Push parse error", "err", err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ingestWrite(w, r, writer, limits, req, data)
+	})
+}
+
+// parseGroupingPath parses the label names and values alternating in path.
+func parseGroupingPath(path string) (labels.Labels, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil, nil
+	}
+	parts := strings.Split(path, "/")
+	if len(parts)%2 != 0 {
+		return nil, fmt.Errorf("grouping label %q has no value", parts[len(parts)-1])
+	}
+	grouping := make(labels.Labels, 0, len(parts)/2)
+	for i := 0; i < len(parts); i += 2 {
+		name := parts[i]
+		if !model.LabelName(name).IsValid() || name == labels.MetricName {
+			return nil, fmt.Errorf("invalid grouping label name %q", name)
+		}
+		grouping = append(grouping, labels.Label{Name: name, Value: parts[i+1]})
+	}
+	return grouping, nil
+}
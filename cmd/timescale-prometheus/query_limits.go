@@ -0,0 +1,96 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+// Headers lowering the query limits of a single read request.
+const (
+	queryMaxSeriesHeader        = "X-Query-Max-Series"
+	queryMaxSamplesHeader       = "X-Query-Max-Samples"
+	queryStatementTimeoutHeader = "X-Query-Statement-Timeout"
+	// queryTruncateHeader, set to true, truncates the results of a read
+	// request at its limits instead of failing it.
+	queryTruncateHeader = "X-Query-Truncate"
+
+	// querySnapshotHeader, set to true or false, pins the SELECTs of the
+	// queries across several metrics to a single snapshot, or not, whatever
+	// -query-snapshot-reads says.
+	querySnapshotHeader = "X-Query-Snapshot"
+
+	// timestampPrecisionHeader selects the unit of the timestamps of a read,
+	// for clients reading the metrics stored with microsecond timestamps.
+	timestampPrecisionHeader = "X-Timestamp-Precision"
+	timestampPrecisionMillis = "ms"
+	timestampPrecisionMicros = "us"
+
+	// queryWarningsHeader carries a warning about the results of a read,
+	// such as a metric which does not exist, once per warning. It is sent
+	// as a trailer by streamed reads.
+	queryWarningsHeader = "X-Query-Warnings"
+)
+
+// globalQueryLimits holds the limits of all the reads, replaced when the
+// configuration is reloaded.
+type globalQueryLimits struct {
+	limits atomic.Value
+}
+
+func newGlobalQueryLimits(limits pgmodel.QueryLimits) *globalQueryLimits {
+	g := &globalQueryLimits{}
+	g.set(limits)
+	return g
+}
+
+func (g *globalQueryLimits) get() pgmodel.QueryLimits {
+	return g.limits.Load().(pgmodel.QueryLimits)
+}
+
+func (g *globalQueryLimits) set(limits pgmodel.QueryLimits) {
+	g.limits.Store(limits)
+}
+
+// requestQueryLimits returns the limits of a read request: the global ones,
+// lowered by the limits set in its headers. Headers cannot raise the global
+// limits, but can ask for truncated results instead of a failure.
+func requestQueryLimits(h http.Header, global pgmodel.QueryLimits) (pgmodel.QueryLimits, error) {
+	var requested pgmodel.QueryLimits
+	var err error
+	if v := h.Get(queryMaxSeriesHeader); v != "" {
+		if requested.MaxSeries, err = strconv.ParseInt(v, 10, 64); err != nil || requested.MaxSeries <= 0 {
+			return requested, fmt.Errorf("invalid %s header %q", queryMaxSeriesHeader, v)
+		}
+	}
+	if v := h.Get(queryMaxSamplesHeader); v != "" {
+		if requested.MaxSamples, err = strconv.ParseInt(v, 10, 64); err != nil || requested.MaxSamples <= 0 {
+			return requested, fmt.Errorf("invalid %s header %q", queryMaxSamplesHeader, v)
+		}
+	}
+	if v := h.Get(queryStatementTimeoutHeader); v != "" {
+		if requested.StatementTimeout, err = time.ParseDuration(v); err != nil || requested.StatementTimeout <= 0 {
+			return requested, fmt.Errorf("invalid %s header %q", queryStatementTimeoutHeader, v)
+		}
+	}
+	if v := h.Get(queryTruncateHeader); v != "" {
+		if requested.Truncate, err = strconv.ParseBool(v); err != nil {
+			return requested, fmt.Errorf("invalid %s header %q", queryTruncateHeader, v)
+		}
+	}
+	return global.Tighten(requested), nil
+}
+
+// setQueryWarnings adds the warnings of a read to the headers.
+func setQueryWarnings(h http.Header, warnings *pgmodel.QueryWarnings) {
+	for _, w := range warnings.Warnings() {
+		h.Add(queryWarningsHeader, w.Error())
+	}
+}
@@ -0,0 +1,111 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	writeProtocolV1 = "1.0"
+	writeProtocolV2 = "2.0"
+)
+
+var (
+	writeProtocolRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "write_protocol_requests_total",
+			Help:      "Total number of decoded write requests, by remote-write protocol version.",
+		},
+		[]string{"version"},
+	)
+	droppedExemplars = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "dropped_exemplars_total",
+			Help:      "Total number of exemplars received in remote-write 2.0 requests, which are not stored.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(writeProtocolRequests)
+	prometheus.MustRegister(droppedExemplars)
+}
+
+type unsupportedProtocolError struct {
+	message string
+}
+
+func (e *unsupportedProtocolError) Error() string {
+	return fmt.Sprintf("unsupported remote-write message %q, expected %s or %s",
+		e.message, pgmodel.RemoteWriteV1Message, pgmodel.RemoteWriteV2Message)
+}
+
+// writeProtocol returns the remote-write message named by the proto
+// parameter of the Content-Type of a write. The Content-Type was not checked
+// before remote-write 2.0, so any other type is taken as a 1.0 request.
+func writeProtocol(contentType string) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "application/x-protobuf" {
+		return pgmodel.RemoteWriteV1Message, nil
+	}
+	switch message := params["proto"]; message {
+	case "", pgmodel.RemoteWriteV1Message:
+		return pgmodel.RemoteWriteV1Message, nil
+	case pgmodel.RemoteWriteV2Message:
+		return pgmodel.RemoteWriteV2Message, nil
+	default:
+		return "", &unsupportedProtocolError{message: message}
+	}
+}
+
+// parseWriteRequest unmarshals the protobuf of a write in the protocol of
+// its Content-Type. The stats are only returned for 2.0 requests.
+func parseWriteRequest(contentType string, data []byte) (*prompb.WriteRequest, *pgmodel.RemoteWriteV2Stats, error) {
+	message, err := writeProtocol(contentType)
+	if err != nil {
+		return nil, nil, err
+	}
+	if message == pgmodel.RemoteWriteV2Message {
+		req, stats, err := pgmodel.ParseRemoteWriteV2(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		return req, &stats, nil
+	}
+
+	req := pgmodel.NewWriteRequest()
+	if err := proto.Unmarshal(data, req); err != nil {
+		pgmodel.FinishWriteRequest(req)
+		return nil, nil, err
+	}
+	return req, nil, nil
+}
+
+// ingestWriteV2 ingests a remote-write 2.0 request, reporting what was
+// written in the response headers the protocol defines.
+func ingestWriteV2(w http.ResponseWriter, r *http.Request, writer pgmodel.DBInserter, limits *writeLimiter, req *prompb.WriteRequest, stats pgmodel.RemoteWriteV2Stats, body []byte) {
+	writeProtocolRequests.WithLabelValues(writeProtocolV2).Inc()
+	droppedExemplars.Add(float64(stats.Exemplars))
+
+	err := ingestSamples(r.Context(), writer, limits, req, body)
+	if err == nil {
+		h := w.Header()
+		h.Set("X-Prometheus-Remote-Write-Samples-Written", strconv.Itoa(stats.Samples))
+		h.Set("X-Prometheus-Remote-Write-Histograms-Written", strconv.Itoa(stats.Histograms))
+		h.Set("X-Prometheus-Remote-Write-Exemplars-Written", "0")
+	}
+	respondIngest(w, err)
+}
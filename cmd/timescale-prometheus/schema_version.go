@@ -0,0 +1,71 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	// schemaMismatchFail refuses to start on an unsupported schema version.
+	schemaMismatchFail schemaMismatchMode = "fail"
+	// schemaMismatchReadOnly starts without accepting writes, so that the
+	// reads keep working while the connector or the schema is upgraded.
+	schemaMismatchReadOnly schemaMismatchMode = "read-only"
+)
+
+// errReadOnly fails the writes to a connector started read-only.
+var errReadOnly = errors.New("writes are disabled because the connector does not support the installed schema version")
+
+// schemaMismatchMode is the value of the -schema-version-mismatch flag.
+type schemaMismatchMode string
+
+func (m *schemaMismatchMode) String() string {
+	return string(*m)
+}
+
+func (m *schemaMismatchMode) Set(value string) error {
+	switch schemaMismatchMode(value) {
+	case schemaMismatchFail, schemaMismatchReadOnly:
+		*m = schemaMismatchMode(value)
+		return nil
+	default:
+		return fmt.Errorf("invalid schema version mismatch mode %q, expected fail or read-only", value)
+	}
+}
+
+// checkSchemaVersion returns whether the connector must start read-only, or
+// an error if it must not start, given the schema installed in the database.
+// A dirty schema always fails, as neither the writes nor the reads can be
+// trusted to work.
+func checkSchemaVersion(info *pgmodel.DatabaseInfo, mode schemaMismatchMode) (bool, error) {
+	err := info.CheckSchemaVersion()
+	var versionErr *pgmodel.SchemaVersionError
+	if !errors.As(err, &versionErr) {
+		return false, err
+	}
+	hint := "start the connector with -migrate=install, or run it with -migrate=only"
+	if versionErr.Newer() {
+		hint = "upgrade the connector to the version which migrated the schema"
+	}
+	if versionErr.Dirty {
+		hint = "inspect the database and restart with -migrate=install to complete the migration"
+	}
+	if mode == schemaMismatchReadOnly && !versionErr.Dirty && versionErr.Installed != 0 {
+		return true, nil
+	}
+	return false, fmt.Errorf("%w: %s", err, hint)
+}
+
+// readOnlyWriter rejects the writes of a connector started read-only.
+type readOnlyWriter struct{}
+
+func (readOnlyWriter) Ingest(context.Context, []prompb.TimeSeries, *prompb.WriteRequest) (uint64, error) {
+	return 0, errReadOnly
+}
@@ -0,0 +1,132 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	// selfTelemetryJob is the reserved job label value of the connector's own
+	// metrics when they are written into the database.
+	selfTelemetryJob = "timescale-prometheus"
+)
+
+// runSelfTelemetry periodically writes the connector's own metrics into the
+// database, so that its health is visible to users who only look at the
+// stored data.
+func runSelfTelemetry(g prometheus.Gatherer, writer pgmodel.DBInserter, instanceID string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for now := range ticker.C {
+		writeSelfTelemetry(g, writer, instanceID, isWriter, now)
+	}
+}
+
+// writeSelfTelemetry writes the connector's metrics once, if this instance
+// is the writer. The cycle is skipped when the leader cannot be checked.
+func writeSelfTelemetry(g prometheus.Gatherer, writer pgmodel.DBInserter, instanceID string, isWriter func() (bool, error), now time.Time) {
+	shouldWrite, err := isWriter()
+	if err != nil {
+		log.Warn("msg", "Error checking the leader, skipping self-telemetry", "err", err)
+		return
+	}
+	if !shouldWrite {
+		return
+	}
+
+	tts, err := gatherSelfTelemetry(g, instanceID, now)
+	if err != nil {
+		log.Warn("msg", "Error gathering self-telemetry", "err", err)
+		return
+	}
+
+	req := pgmodel.NewWriteRequest()
+	req.Timeseries = append(req.Timeseries, tts...)
+	if _, err = writer.Ingest(context.Background(), req.Timeseries, req); err != nil {
+		log.Warn("msg", "Error writing self-telemetry", "err", err)
+	}
+}
+
+// gatherSelfTelemetry converts the connector's metrics into timeseries
+// labeled with the reserved job and the instance id, which replace the job
+// and instance labels of the metrics.
+func gatherSelfTelemetry(g prometheus.Gatherer, instanceID string, now time.Time) ([]prompb.TimeSeries, error) {
+	families, err := g.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	ts := now.UnixNano() / int64(time.Millisecond)
+	result := make([]prompb.TimeSeries, 0)
+	for _, mf := range families {
+		name := mf.GetName()
+		if !strings.HasPrefix(name, promNamespace+"_") {
+			continue
+		}
+
+		for _, m := range mf.GetMetric() {
+			add := func(suffix string, value float64, extra ...string) {
+				labels := make([]prompb.Label, 0, len(m.GetLabel())+3+len(extra)/2)
+				labels = append(labels,
+					prompb.Label{Name: pgmodel.MetricNameLabelName, Value: name + suffix},
+					prompb.Label{Name: "job", Value: selfTelemetryJob},
+					prompb.Label{Name: "instance", Value: instanceID},
+				)
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "job" || l.GetName() == "instance" {
+						continue
+					}
+					labels = append(labels, prompb.Label{Name: l.GetName(), Value: l.GetValue()})
+				}
+				for i := 0; i+1 < len(extra); i += 2 {
+					labels = append(labels, prompb.Label{Name: extra[i], Value: extra[i+1]})
+				}
+				result = append(result, prompb.TimeSeries{
+					Labels:  labels,
+					Samples: []prompb.Sample{{Timestamp: ts, Value: value}},
+				})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add("", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add("", m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add("", m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add("", q.GetValue(), "quantile", formatFloat(q.GetQuantile()))
+				}
+				add("_sum", s.GetSampleSum())
+				add("_count", float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					add("_bucket", float64(b.GetCumulativeCount()), "le", formatFloat(b.GetUpperBound()))
+				}
+				add("_bucket", float64(h.GetSampleCount()), "le", formatFloat(math.Inf(1)))
+				add("_sum", h.GetSampleSum())
+				add("_count", float64(h.GetSampleCount()))
+			}
+		}
+	}
+	return result, nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
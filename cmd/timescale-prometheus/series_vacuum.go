@@ -0,0 +1,53 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+// seriesVacuumer deletes the series left without samples.
+type seriesVacuumer interface {
+	Run(ctx context.Context) ([]pgmodel.VacuumedSeries, error)
+}
+
+type vacuumSeriesResponse struct {
+	Status string                   `json:"status"`
+	Data   []pgmodel.VacuumedSeries `json:"data"`
+	Error  string                   `json:"error,omitempty"`
+}
+
+// vacuumSeries runs the series vacuum on demand and reports the series it
+// deleted per metric.
+func vacuumSeries(vacuum seriesVacuumer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeVacuumSeriesResponse(w, http.StatusMethodNotAllowed, vacuumSeriesResponse{Error: "method not allowed"})
+			return
+		}
+		vacuumed, err := vacuum.Run(r.Context())
+		if err != nil {
+			log.Error("msg", "Vacuuming series failed", "err", err)
+			writeVacuumSeriesResponse(w, http.StatusInternalServerError, vacuumSeriesResponse{Data: vacuumed, Error: err.Error()})
+			return
+		}
+		writeVacuumSeriesResponse(w, http.StatusOK, vacuumSeriesResponse{Data: vacuumed})
+	})
+}
+
+func writeVacuumSeriesResponse(w http.ResponseWriter, status int, resp vacuumSeriesResponse) {
+	resp.Status = "success"
+	if resp.Error != "" {
+		resp.Status = "error"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
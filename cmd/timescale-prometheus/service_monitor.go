@@ -0,0 +1,235 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+const (
+	// serviceAccountDir holds the credentials Kubernetes mounts in every pod.
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// serviceMonitorFieldManager owns the ServiceMonitor fields applied by
+	// the connector.
+	serviceMonitorFieldManager = "timescale-prometheus"
+	serviceMonitorRetry        = time.Minute
+)
+
+// serviceMonitorConfig configures the registration of the connector with the
+// Prometheus Operator.
+type serviceMonitorConfig struct {
+	enabled bool
+	name    string
+	// selector lists the labels of the Service exposing the connector, as
+	// comma-separated key=value pairs.
+	selector string
+	// labels lists the labels of the ServiceMonitor, which the Prometheus
+	// resources select it with.
+	labels   string
+	port     string
+	interval time.Duration
+}
+
+// serviceMonitor is the subset of the monitoring.coreos.com/v1
+// ServiceMonitor resource set by the connector.
+type serviceMonitor struct {
+	APIVersion string                 `json:"apiVersion"`
+	Kind       string                 `json:"kind"`
+	Metadata   serviceMonitorMetadata `json:"metadata"`
+	Spec       serviceMonitorSpec     `json:"spec"`
+}
+
+type serviceMonitorMetadata struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type serviceMonitorSpec struct {
+	Selector  labelSelector            `json:"selector"`
+	Endpoints []serviceMonitorEndpoint `json:"endpoints"`
+}
+
+type labelSelector struct {
+	MatchLabels map[string]string `json:"matchLabels"`
+}
+
+type serviceMonitorEndpoint struct {
+	Port      string                   `json:"port"`
+	Path      string                   `json:"path"`
+	Scheme    string                   `json:"scheme"`
+	Interval  string                   `json:"interval,omitempty"`
+	TLSConfig *serviceMonitorTLSConfig `json:"tlsConfig,omitempty"`
+}
+
+type serviceMonitorTLSConfig struct {
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+}
+
+// parseLabels parses comma-separated key=value pairs, returning nil if there
+// are none.
+func parseLabels(s string) (map[string]string, error) {
+	var labels map[string]string
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", pair)
+		}
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[kv[0]] = kv[1]
+	}
+	return labels, nil
+}
+
+// buildServiceMonitor returns the ServiceMonitor scraping the metrics of the
+// connector at telemetryPath, over HTTPS when the web endpoints are served
+// with TLS.
+func buildServiceMonitor(cfg serviceMonitorConfig, namespace, telemetryPath string, https bool) (*serviceMonitor, error) {
+	if cfg.name == "" {
+		return nil, fmt.Errorf("the ServiceMonitor name is required")
+	}
+	selector, err := parseLabels(cfg.selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Service selector: %w", err)
+	}
+	if len(selector) == 0 {
+		return nil, fmt.Errorf("the Service selector is required")
+	}
+	labels, err := parseLabels(cfg.labels)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ServiceMonitor labels: %w", err)
+	}
+
+	endpoint := serviceMonitorEndpoint{
+		Port:   cfg.port,
+		Path:   telemetryPath,
+		Scheme: "http",
+	}
+	if cfg.interval > 0 {
+		endpoint.Interval = cfg.interval.String()
+	}
+	if https {
+		endpoint.Scheme = "https"
+		// The certificate is issued for the Service name, not the pod IPs
+		// Prometheus scrapes.
+		endpoint.TLSConfig = &serviceMonitorTLSConfig{InsecureSkipVerify: true}
+	}
+
+	return &serviceMonitor{
+		APIVersion: "monitoring.coreos.com/v1",
+		Kind:       "ServiceMonitor",
+		Metadata: serviceMonitorMetadata{
+			Name:      cfg.name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: serviceMonitorSpec{
+			Selector:  labelSelector{MatchLabels: selector},
+			Endpoints: []serviceMonitorEndpoint{endpoint},
+		},
+	}, nil
+}
+
+// kubeClient talks to the Kubernetes API with the credentials of the pod.
+type kubeClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// inClusterKubeClient returns a client using the service account of the pod,
+// along with the namespace the pod runs in.
+func inClusterKubeClient() (*kubeClient, string, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, "", fmt.Errorf("not running in Kubernetes")
+	}
+	token, err := ioutil.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot read the service account token: %w", err)
+	}
+	namespace, err := ioutil.ReadFile(serviceAccountDir + "/namespace")
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot read the namespace: %w", err)
+	}
+	ca, err := ioutil.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot read the cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, "", fmt.Errorf("no certificate found in the cluster CA")
+	}
+
+	return &kubeClient{
+		baseURL: "https://" + net.JoinHostPort(host, port),
+		token:   strings.TrimSpace(string(token)),
+		client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+		},
+	}, strings.TrimSpace(string(namespace)), nil
+}
+
+// applyServiceMonitor creates or updates the ServiceMonitor with a server-side
+// apply, so that fields set by others are kept.
+func (c *kubeClient) applyServiceMonitor(sm *serviceMonitor) error {
+	body, err := json.Marshal(sm)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/apis/monitoring.coreos.com/v1/namespaces/%s/servicemonitors/%s?fieldManager=%s&force=true",
+		c.baseURL, sm.Metadata.Namespace, sm.Metadata.Name, serviceMonitorFieldManager)
+	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	// JSON is valid YAML.
+	req.Header.Set("Content-Type", "application/apply-patch+yaml")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("applying the ServiceMonitor failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// runServiceMonitorRegistration applies the ServiceMonitor, retrying until it
+// succeeds. Every replica applies the same object, so they do not conflict.
+func runServiceMonitorRegistration(client *kubeClient, sm *serviceMonitor) {
+	for {
+		err := client.applyServiceMonitor(sm)
+		if err == nil {
+			log.Info("msg", "Registered the connector with the Prometheus Operator", "service_monitor", sm.Metadata.Namespace+"/"+sm.Metadata.Name)
+			return
+		}
+		log.Warn("msg", "Registering the connector with the Prometheus Operator failed", "err", err, "retry_in", serviceMonitorRetry)
+		time.Sleep(serviceMonitorRetry)
+	}
+}
@@ -0,0 +1,181 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgclient"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+// secretFlags are masked in the startup report. The flags holding URLs,
+// such as -shared-cache-url or -events-webhook-url, only get the credentials
+// of their URLs masked, by maskURLs.
+var secretFlags = map[string]bool{
+	"db-password":          true,
+	"db-session-url":       true,
+	"db-read-replica-urls": true,
+}
+
+// startupReport summarizes how the connector was started, to be attached to
+// bug reports.
+type startupReport struct {
+	Version       string                `json:"version"`
+	CommitHash    string                `json:"commit_hash"`
+	InstanceID    string                `json:"instance_id"`
+	StartedAt     time.Time             `json:"started_at"`
+	Config        map[string]string     `json:"config"`
+	Features      []string              `json:"features"`
+	WritePool     pgclient.PoolConfig   `json:"write_pool"`
+	ReadPool      pgclient.PoolConfig   `json:"read_pool"`
+	Database      *pgmodel.DatabaseInfo `json:"database,omitempty"`
+	DatabaseError string                `json:"database_error,omitempty"`
+}
+
+// resolvedFlags returns the value of every flag, after the environment and
+// the command line were parsed.
+func resolvedFlags(fs *flag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if secretFlags[f.Name] && value != "" {
+			value = "****"
+		}
+		values[f.Name] = maskURLs(value)
+	})
+	return values
+}
+
+// maskURLs masks the userinfo and the query values of the URLs of a flag
+// value, possibly a comma-separated list, which may carry passwords or
+// tokens. Other values are returned as is.
+func maskURLs(value string) string {
+	parts := strings.Split(value, ",")
+	for i, part := range parts {
+		u, err := url.Parse(strings.TrimSpace(part))
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.User == nil && u.RawQuery == "") {
+			continue
+		}
+		user := u.User
+		u.User = nil
+		if u.RawQuery != "" {
+			query := u.Query()
+			params := make([]string, 0, len(query))
+			for name := range query {
+				params = append(params, url.QueryEscape(name)+"=****")
+			}
+			sort.Strings(params)
+			u.RawQuery = strings.Join(params, "&")
+		}
+		parts[i] = u.String()
+		if user != nil {
+			parts[i] = u.Scheme + "://****@" + strings.TrimPrefix(parts[i], u.Scheme+"://")
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// enabledFeatures lists the optional features enabled in cfg.
+func enabledFeatures(cfg *config) []string {
+	features := make([]string, 0)
+	add := func(name string, enabled bool) {
+		if enabled {
+			features = append(features, name)
+		}
+	}
+	add("strict", cfg.strict)
+	add("migrate", cfg.migrate != migrateSkip)
+	add("tls", cfg.tls.enabled())
+	add("tls_client_auth", cfg.tls.clientCAFile != "")
+	add("auth_bearer_tokens", cfg.auth.bearerTokensFile != "")
+	add("auth_htpasswd", cfg.auth.htpasswdFile != "")
+	add("auth_admin_users", cfg.auth.adminUsers != "")
+	add("auth_admin_bearer_tokens", cfg.auth.adminTokensFile != "")
+	add("admin_api", cfg.enableAdminAPI)
+	add("leader_election_pg_advisory_lock", cfg.haGroupLockID != 0)
+	add("leader_election_rest", cfg.restElection)
+	add("db_transaction_pooler", cfg.pgmodelCfg.Conn.TransactionPooler)
+	add("cache_invalidation", cfg.pgmodelCfg.CacheInvalidation)
+	add("shared_cache", cfg.pgmodelCfg.SharedCacheURL != "")
+	add("ha_dedup", cfg.pgmodelCfg.HADedup)
+	add("async_acks", cfg.pgmodelCfg.AcksBeforeWrite())
+	add("flush_acks", cfg.pgmodelCfg.AckMode == string(pgmodel.AckModeFlush))
+	add("adaptive_batching", cfg.pgmodelCfg.Batch.TargetLatency > 0)
+	add("dedup_samples", cfg.pgmodelCfg.DedupSamples)
+	add("fast_ingest", cfg.pgmodelCfg.FastIngest)
+	add("drop_stale_markers", cfg.pgmodelCfg.StaleMarkers == string(pgmodel.StaleMarkersDrop))
+	add("series_limit_per_metric", cfg.pgmodelCfg.SeriesLimit.MaxSeriesPerMetric > 0)
+	add("ingest_relabeling", cfg.pgmodelCfg.RelabelConfigFile != "")
+	add("microsecond_metrics", cfg.pgmodelCfg.MicrosecondMetrics != "")
+	add("sanitize_labels", cfg.pgmodelCfg.LabelValidation == string(pgmodel.LabelValidationSanitize))
+	add("ingest_label_limits", cfg.pgmodelCfg.LabelLimits != pgmodel.LabelLimits{})
+	add("ingest_sample_bounds", cfg.pgmodelCfg.SampleBounds != pgmodel.SampleBoundsConfig{})
+	add("stale_marker_liveness", cfg.pgmodelCfg.StaleMarkers == string(pgmodel.StaleMarkersLiveness))
+	add("ingest_shards", cfg.pgmodelCfg.IngestShardFile != "")
+	add("spill_buffer", cfg.pgmodelCfg.SpillDir != "")
+	add("write_rate_limit", cfg.limits.samplesPerSecond > 0)
+	add("insert_priorities", cfg.pgmodelCfg.Priorities.Critical != "" || cfg.pgmodelCfg.Priorities.Low != "")
+	add("write_max_series", cfg.limits.maxSeries > 0)
+	add("write_max_body_bytes", cfg.limits.maxBodyBytes > 0)
+	add("write_capture", cfg.capture.Dir != "")
+	add("query_timeout", cfg.pgmodelCfg.QueryTimeout > 0)
+	add("query_limits", cfg.queryLimits != pgmodel.QueryLimits{})
+	add("query_cache", cfg.pgmodelCfg.QueryCache.MaxSizeMB > 0)
+	add("query_log", cfg.pgmodelCfg.QueryLog.Enabled)
+	add("slow_query_log", cfg.pgmodelCfg.QueryLog.SlowThreshold > 0)
+	add("snapshot_reads", cfg.pgmodelCfg.SnapshotReads)
+	add("external_labels", cfg.pgmodelCfg.ExternalLabels != "")
+	add("read_redaction", cfg.pgmodelCfg.RedactionRulesFile != "")
+	add("read_replicas", cfg.pgmodelCfg.ReadReplicaURLs != "")
+	add("read_federation", cfg.pgmodelCfg.FederationFile != "")
+	add("tenant_roles", cfg.pgmodelCfg.TenantRoles)
+	add("self_telemetry", cfg.selfTelemetry > 0)
+	add("lifecycle_policies", cfg.lifecycleInterval > 0)
+	add("series_vacuum", cfg.seriesVacuum > 0)
+	add("delete_series_grace_period", cfg.deleteSeriesGrace > 0)
+	add("chunk_interval_tuning", cfg.chunkIntervals.TargetSizeMB > 0 && cfg.chunkTuning > 0)
+	add("kubernetes_service_monitor", cfg.serviceMonitor.enabled)
+	add("tracing", cfg.traces.Endpoint != "")
+	add("events", cfg.events.enabled())
+	add("graphite", cfg.graphite.enabled())
+	add("grpc_write", cfg.grpc.listenAddr != "")
+	return features
+}
+
+// newStartupReport builds the startup report. A failure to read the database
+// information is reported instead of it.
+func newStartupReport(cfg *config, fs *flag.FlagSet, db *pgmodel.DatabaseInfo, dbErr error, writePool, readPool pgclient.PoolConfig) *startupReport {
+	report := &startupReport{
+		Version:    Version,
+		CommitHash: CommitHash,
+		InstanceID: cfg.pgmodelCfg.InstanceID,
+		StartedAt:  time.Now(),
+		Config:     resolvedFlags(fs),
+		Features:   enabledFeatures(cfg),
+		WritePool:  writePool,
+		ReadPool:   readPool,
+		Database:   db,
+	}
+	if dbErr != nil {
+		report.DatabaseError = dbErr.Error()
+	}
+	return report
+}
+
+func startupReportHandler(report *startupReport) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
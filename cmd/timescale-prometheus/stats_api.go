@@ -0,0 +1,100 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+// ingestRateInterval is how often the ingest rates reported by the stats
+// API are computed.
+const ingestRateInterval = time.Minute
+
+// storageStatsReader reads the storage statistics of every metric.
+type storageStatsReader interface {
+	StorageStats() ([]pgmodel.MetricStorageStats, error)
+}
+
+// statsSortKeys are the values of the sort parameter of the stats API,
+// which sort the metrics by decreasing value.
+var statsSortKeys = map[string]func(s *pgmodel.MetricStorageStats) float64{
+	"series_count":             func(s *pgmodel.MetricStorageStats) float64 { return float64(s.SeriesCount) },
+	"total_bytes":              func(s *pgmodel.MetricStorageStats) float64 { return float64(s.TotalBytes) },
+	"chunks":                   func(s *pgmodel.MetricStorageStats) float64 { return float64(s.Chunks) },
+	"compressed_chunks":        func(s *pgmodel.MetricStorageStats) float64 { return float64(s.CompressedChunks) },
+	"before_compression_bytes": func(s *pgmodel.MetricStorageStats) float64 { return float64(s.BeforeCompressionBytes) },
+	"after_compression_bytes":  func(s *pgmodel.MetricStorageStats) float64 { return float64(s.AfterCompressionBytes) },
+	"ingest_rate":              func(s *pgmodel.MetricStorageStats) float64 { return s.IngestRate },
+}
+
+type statsResponse struct {
+	Status string                       `json:"status"`
+	Data   []pgmodel.MetricStorageStats `json:"data"`
+	Error  string                       `json:"error,omitempty"`
+}
+
+// storageStats serves the storage statistics and ingest rates of the
+// metrics, in the envelope of the Prometheus HTTP API. The metrics are
+// ordered by name, or by decreasing value of the sort parameter, and limit
+// keeps the first ones.
+func storageStats(reader storageStatsReader, rates func() map[string]float64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := statsSortKeys[r.FormValue("sort")]
+		if key == nil && r.FormValue("sort") != "" && r.FormValue("sort") != "name" {
+			writeStatsResponse(w, http.StatusBadRequest, statsResponse{Error: fmt.Sprintf("unknown sort key %q", r.FormValue("sort"))})
+			return
+		}
+		limit := 0
+		if l := r.FormValue("limit"); l != "" {
+			var err error
+			if limit, err = strconv.Atoi(l); err != nil || limit < 0 {
+				writeStatsResponse(w, http.StatusBadRequest, statsResponse{Error: fmt.Sprintf("invalid limit %q", l)})
+				return
+			}
+		}
+
+		stats, err := reader.StorageStats()
+		if err != nil {
+			writeStatsResponse(w, http.StatusInternalServerError, statsResponse{Error: err.Error()})
+			return
+		}
+		metricRates := rates()
+		for i := range stats {
+			stats[i].IngestRate = metricRates[stats[i].Name]
+		}
+		if key != nil {
+			sort.SliceStable(stats, func(i, j int) bool { return key(&stats[i]) > key(&stats[j]) })
+		}
+		if limit > 0 && limit < len(stats) {
+			stats = stats[:limit]
+		}
+		writeStatsResponse(w, http.StatusOK, statsResponse{Data: stats})
+	})
+}
+
+func writeStatsResponse(w http.ResponseWriter, status int, resp statsResponse) {
+	resp.Status = "success"
+	if resp.Error != "" {
+		resp.Status = "error"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// runIngestRateTracker computes the ingest rates every ingestRateInterval.
+func runIngestRateTracker(tracker *pgmodel.IngestRateTracker) {
+	ticker := time.NewTicker(ingestRateInterval)
+	for {
+		tracker.Observe(time.Now())
+		<-ticker.C
+	}
+}
@@ -0,0 +1,134 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// webTLSConfig configures HTTPS and client certificate authentication of the
+// web endpoints.
+type webTLSConfig struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	// exemptPaths lists the paths served without a client certificate.
+	// A path ending with a slash exempts everything below it.
+	exemptPaths string
+}
+
+func (c *webTLSConfig) enabled() bool {
+	return c.certFile != "" || c.keyFile != ""
+}
+
+// tlsConfig returns the TLS configuration of the web server, nil if HTTPS is
+// disabled. With a client CA, clients may present a certificate signed by it,
+// and clientAuth rejects the requests to non-exempt paths without one.
+func (c *webTLSConfig) tlsConfig() (*tls.Config, error) {
+	if !c.enabled() {
+		if c.clientCAFile != "" {
+			return nil, fmt.Errorf("client certificate authentication requires a server certificate and key")
+		}
+		return nil, nil
+	}
+	if c.certFile == "" || c.keyFile == "" {
+		return nil, fmt.Errorf("both the server certificate and key are required")
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.clientCAFile == "" {
+		return cfg, nil
+	}
+
+	pem, err := ioutil.ReadFile(c.clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in the client CA file %s", c.clientCAFile)
+	}
+	cfg.ClientCAs = pool
+	// Exempt paths are served without a certificate, so they are enforced
+	// per request instead of during the handshake.
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	return cfg, nil
+}
+
+// clientAuth wraps handler to reject the requests without a verified client
+// certificate, except to the exempt paths. It returns handler as is when
+// client certificates are not configured.
+func (c *webTLSConfig) clientAuth(handler http.Handler) http.Handler {
+	if c.clientCAFile == "" {
+		return handler
+	}
+
+	exempt := make([]string, 0)
+	for _, p := range strings.Split(c.exemptPaths, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			exempt = append(exempt, p)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 || isExemptPath(r.URL.Path, exempt) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, "client certificate required", http.StatusUnauthorized)
+	})
+}
+
+func isExemptPath(path string, exempt []string) bool {
+	for _, e := range exempt {
+		if path == e || strings.HasSuffix(e, "/") && strings.HasPrefix(path, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// listenAndServe serves handler over HTTPS when a certificate is configured,
+// and over plain HTTP otherwise.
+func (c *webTLSConfig) listenAndServe(addr string, handler http.Handler) error {
+	tlsCfg, err := c.tlsConfig()
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   c.clientAuth(handler),
+		TLSConfig: tlsCfg,
+	}
+	if tlsCfg == nil {
+		return server.ListenAndServe()
+	}
+	return server.ListenAndServeTLS(c.certFile, c.keyFile)
+}
+
+// grpcCredentials returns the TLS credentials of the gRPC server, nil if
+// HTTPS is disabled. gRPC has no exempt paths, so a client CA makes client
+// certificates required during the handshake.
+func (c *webTLSConfig) grpcCredentials() (credentials.TransportCredentials, error) {
+	tlsCfg, err := c.tlsConfig()
+	if err != nil || tlsCfg == nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the server certificate: %w", err)
+	}
+	tlsCfg.Certificates = []tls.Certificate{cert}
+	if tlsCfg.ClientCAs != nil {
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return credentials.NewTLS(tlsCfg), nil
+}
@@ -0,0 +1,107 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// maxDecodedWriteBytes bounds the decoded size of a write request, so that a
+// small body decompressing to a huge one is rejected before it is allocated.
+const maxDecodedWriteBytes = 256 << 20
+
+// writeEncodings are the values of Content-Encoding accepted on /write.
+// Prometheus compresses with snappy, which is assumed without the header.
+const writeEncodings = "snappy, zstd, gzip, identity"
+
+var errDecodedWriteTooLarge = fmt.Errorf("decoded write request larger than %d bytes", maxDecodedWriteBytes)
+
+// zstdDecoder decodes the zstd write bodies. DecodeAll is safe for concurrent
+// use and rejects the frames decoding to more than maxDecodedWriteBytes.
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecodedWriteBytes))
+
+type unsupportedEncodingError struct {
+	encoding string
+}
+
+func (e *unsupportedEncodingError) Error() string {
+	return fmt.Sprintf("unsupported Content-Encoding %q, expected one of %s", e.encoding, writeEncodings)
+}
+
+// writeEncoding returns the normalized Content-Encoding of a write.
+func writeEncoding(r *http.Request) string {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding == "" {
+		return "snappy"
+	}
+	return encoding
+}
+
+// decodeWriteBody returns the protobuf of a write body in encoding.
+func decodeWriteBody(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case "snappy":
+		n, err := snappy.DecodedLen(body)
+		if err != nil {
+			return nil, err
+		}
+		if n > maxDecodedWriteBytes {
+			return nil, errDecodedWriteTooLarge
+		}
+		return snappy.Decode(nil, body)
+	case "zstd":
+		data, err := zstdDecoder.DecodeAll(body, nil)
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+			return nil, errDecodedWriteTooLarge
+		}
+		return data, err
+	case "gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(gz, maxDecodedWriteBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxDecodedWriteBytes {
+			return nil, errDecodedWriteTooLarge
+		}
+		return data, nil
+	case "identity":
+		return body, nil
+	default:
+		return nil, &unsupportedEncodingError{encoding: encoding}
+	}
+}
+
+// writeDecodeError responds with the status of an error of decodeWriteBody
+// or parseWriteRequest.
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var (
+		unsupported         *unsupportedEncodingError
+		unsupportedProtocol *unsupportedProtocolError
+	)
+	switch {
+	case errors.As(err, &unsupported):
+		w.Header().Set("Accept-Encoding", writeEncodings)
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.As(err, &unsupportedProtocol):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, errDecodedWriteTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
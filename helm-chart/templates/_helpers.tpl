@@ -21,4 +21,15 @@ Create chart name and version as used by the chart label.
 */}}
 {{- define "connector.chart" -}}
 {{- printf "%s-%s" .Chart.Name .Chart.Version | replace "+" "_" | trunc 63 | trimSuffix "-" -}}
-{{- end -}}
\ No newline at end of file
+{{- end -}}
+
+{{/*
+Labels of the ServiceMonitor as comma-separated key=value pairs.
+*/}}
+{{- define "connector.serviceMonitorLabels" -}}
+{{- $labels := list -}}
+{{- range $key, $value := .Values.serviceMonitor.labels -}}
+{{- $labels = append $labels (printf "%s=%s" $key $value) -}}
+{{- end -}}
+{{- join "," $labels -}}
+{{- end -}}
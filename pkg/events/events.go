@@ -0,0 +1,216 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+// Package events records the operational events of the connector, such as a
+// metric table created or a leadership change, and delivers them to sinks
+// in the background, to give operators a timeline of what the connector did.
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+// Type identifies the kind of an event.
+type Type string
+
+// The events emitted by the connector.
+const (
+	// MetricTableCreated is emitted when the connector creates the table
+	// of a new metric.
+	MetricTableCreated Type = "metric_table_created"
+	// RetentionApplied is emitted after the lifecycle policy of a metric
+	// rolled up its samples and dropped its expired rollups.
+	RetentionApplied Type = "retention_applied"
+	// LeadershipChanged is emitted when the connector becomes the leader
+	// writing to the database, or stops being it.
+	LeadershipChanged Type = "leadership_changed"
+	// HALeaderChanged is emitted when another replica of an HA Prometheus
+	// cluster becomes the one whose samples are stored.
+	HALeaderChanged Type = "ha_leader_changed"
+	// CircuitBreakerTripped is emitted when the database becomes
+	// unreachable and writes are spilled to disk instead.
+	CircuitBreakerTripped Type = "circuit_breaker_tripped"
+	// CircuitBreakerReset is emitted when the database is reachable again.
+	CircuitBreakerReset Type = "circuit_breaker_reset"
+	// SeriesDeleted is emitted for every metric whose series were deleted
+	// through the admin API.
+	SeriesDeleted Type = "series_deleted"
+	// SeriesUndeleted is emitted for every tombstone of deleted series
+	// removed through the admin API before being purged.
+	SeriesUndeleted Type = "series_undeleted"
+	// SeriesVacuumed is emitted for every metric whose series without
+	// samples were vacuumed.
+	SeriesVacuumed Type = "series_vacuumed"
+	// DataLost is emitted when the connector drops samples it acknowledged,
+	// or is about to acknowledge, to Prometheus.
+	DataLost Type = "data_lost"
+)
+
+const (
+	promNamespace = "ts_prom"
+
+	// DefaultBufferSize is the number of events waiting for delivery
+	// beyond which new events are dropped.
+	DefaultBufferSize = 1024
+)
+
+var (
+	emitted = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "events_total",
+			Help:      "Total number of operational events emitted, by type.",
+		},
+		[]string{"type"},
+	)
+	dropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "events_dropped_total",
+			Help:      "Total number of operational events dropped because the sinks could not keep up.",
+		},
+	)
+	sinkErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "event_sink_errors_total",
+			Help:      "Total number of operational events a sink failed to deliver.",
+		},
+		[]string{"sink"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(emitted, dropped, sinkErrors)
+}
+
+// Event is an operational event.
+type Event struct {
+	Time       time.Time         `json:"time"`
+	Type       Type              `json:"type"`
+	Instance   string            `json:"instance,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Sink delivers events somewhere, one at a time.
+type Sink interface {
+	// Name identifies the sink in logs and metrics.
+	Name() string
+	Send(Event) error
+}
+
+// Bus delivers the emitted events to its sinks, in order, from a background
+// routine, so that emitting never waits on a sink.
+type Bus struct {
+	instance string
+	queue    chan Event
+	done     chan struct{}
+	close    sync.Once
+
+	mu    sync.RWMutex
+	sinks []Sink
+}
+
+var (
+	// bus is the bus Emit sends to, nil until Init.
+	bus   *Bus
+	busMu sync.RWMutex
+)
+
+// Init starts delivering the events emitted from now on to the sinks,
+// stamped with the instance id. Until then, events are only counted.
+func Init(instance string, bufferSize int, sinks ...Sink) *Bus {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	b := &Bus{
+		instance: instance,
+		sinks:    sinks,
+		queue:    make(chan Event, bufferSize),
+		done:     make(chan struct{}),
+	}
+	go b.run()
+	busMu.Lock()
+	bus = b
+	busMu.Unlock()
+	return b
+}
+
+// Emit records an event of type t, with attributes given as alternating keys
+// and values, like the log functions.
+func Emit(t Type, keyvals ...interface{}) {
+	// Holding the lock keeps Close from closing the queue meanwhile.
+	busMu.RLock()
+	defer busMu.RUnlock()
+	bus.emit(t, keyvals...)
+}
+
+func (b *Bus) emit(t Type, keyvals ...interface{}) {
+	emitted.WithLabelValues(string(t)).Inc()
+	if b == nil {
+		return
+	}
+	e := Event{Time: time.Now().UTC(), Type: t, Instance: b.instance}
+	if len(keyvals) > 0 {
+		e.Attributes = make(map[string]string, len(keyvals)/2)
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			e.Attributes[fmt.Sprint(keyvals[i])] = fmt.Sprint(keyvals[i+1])
+		}
+	}
+	select {
+	case b.queue <- e:
+	default:
+		dropped.Inc()
+	}
+}
+
+// AddSink delivers the events not delivered yet to s too. It adds the sinks
+// that can only be created once the connector is up, such as the table sink
+// that needs the migrated schema.
+func (b *Bus) AddSink(s Sink) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.sinks = append(b.sinks, s)
+	b.mu.Unlock()
+}
+
+func (b *Bus) run() {
+	defer close(b.done)
+	for e := range b.queue {
+		b.mu.RLock()
+		sinks := b.sinks
+		b.mu.RUnlock()
+		for _, s := range sinks {
+			if err := s.Send(e); err != nil {
+				sinkErrors.WithLabelValues(s.Name()).Inc()
+				log.Warn("msg", "Cannot deliver operational event", "sink", s.Name(), "event", e.Type, "err", err)
+			}
+		}
+	}
+}
+
+// Close delivers the events already emitted and stops the bus. The events
+// emitted afterwards are discarded.
+func (b *Bus) Close() {
+	if b == nil {
+		return
+	}
+	b.close.Do(func() {
+		busMu.Lock()
+		if bus == b {
+			bus = nil
+		}
+		busMu.Unlock()
+		close(b.queue)
+		<-b.done
+	})
+}
@@ -0,0 +1,189 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+func init() {
+	err := log.Init("debug")
+	if err != nil {
+		panic(err)
+	}
+}
+
+// recordingSink records the events sent, failing with err.
+type recordingSink struct {
+	mu     sync.Mutex
+	events []Event
+	err    error
+}
+
+func (s *recordingSink) Name() string {
+	return "recording"
+}
+
+func (s *recordingSink) Send(e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return s.err
+}
+
+func TestBus(t *testing.T) {
+	Emit(MetricTableCreated, "metric", "ignored")
+
+	first := &recordingSink{}
+	failing := &recordingSink{err: fmt.Errorf("some error")}
+	b := Init("instance", 10, first)
+	b.AddSink(failing)
+	errorsBefore := testutil.ToFloat64(sinkErrors.WithLabelValues("recording"))
+
+	Emit(MetricTableCreated, "metric", "up", "table", "up")
+	Emit(LeadershipChanged, "leader", true)
+	b.Close()
+	Emit(LeadershipChanged, "leader", false)
+
+	if len(first.events) != 2 || !reflect.DeepEqual(first.events, failing.events) {
+		t.Fatalf("unexpected events: %v and %v", first.events, failing.events)
+	}
+	e := first.events[0]
+	if e.Type != MetricTableCreated || e.Instance != "instance" || e.Time.IsZero() ||
+		!reflect.DeepEqual(e.Attributes, map[string]string{"metric": "up", "table": "up"}) {
+		t.Errorf("unexpected event: %+v", e)
+	}
+	if first.events[1].Attributes["leader"] != "true" {
+		t.Errorf("unexpected event: %+v", first.events[1])
+	}
+	if errors := testutil.ToFloat64(sinkErrors.WithLabelValues("recording")) - errorsBefore; errors != 2 {
+		t.Errorf("unexpected sink errors: %v", errors)
+	}
+}
+
+// blockingSink blocks until released.
+type blockingSink struct {
+	release chan struct{}
+}
+
+func (s blockingSink) Name() string {
+	return "blocking"
+}
+
+func (s blockingSink) Send(Event) error {
+	<-s.release
+	return nil
+}
+
+func TestBusDropsWhenFull(t *testing.T) {
+	sink := blockingSink{release: make(chan struct{})}
+	b := Init("instance", 1, sink)
+	droppedBefore := testutil.ToFloat64(dropped)
+
+	// The first event may be taken off the queue before the second is
+	// emitted, so at least one of the three is dropped.
+	for i := 0; i < 3; i++ {
+		Emit(SeriesVacuumed, "series", i)
+	}
+	if testutil.ToFloat64(dropped)-droppedBefore < 1 {
+		t.Error("expected dropped events")
+	}
+	close(sink.release)
+	b.Close()
+}
+
+func TestWebhookSink(t *testing.T) {
+	webhookRetryBackoff = time.Millisecond
+	defer func() {
+		webhookRetryBackoff = time.Second
+	}()
+
+	e := Event{Time: time.Unix(1000, 0).UTC(), Type: CircuitBreakerTripped, Instance: "instance", Attributes: map[string]string{"err": "timeout"}}
+	testCases := []struct {
+		name     string
+		statuses []int
+		retries  int
+		attempts int
+		err      bool
+	}{
+		{
+			name:     "delivered",
+			statuses: []int{http.StatusOK},
+			retries:  2,
+			attempts: 1,
+		},
+		{
+			name:     "retried",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusNoContent},
+			retries:  2,
+			attempts: 3,
+		},
+		{
+			name:     "retries exhausted",
+			statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK},
+			retries:  1,
+			attempts: 2,
+			err:      true,
+		},
+		{
+			name:     "not retried",
+			statuses: []int{http.StatusBadRequest, http.StatusOK},
+			retries:  2,
+			attempts: 1,
+			err:      true,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			received := make([]Event, 0)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("unexpected content type: %v", r.Header)
+				}
+				var got Event
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Error(err)
+				}
+				received = append(received, got)
+				w.WriteHeader(c.statuses[len(received)-1])
+			}))
+			defer server.Close()
+
+			err := NewWebhookSink(server.URL, time.Second, c.retries).Send(e)
+			if c.err != (err != nil) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(received) != c.attempts {
+				t.Errorf("unexpected attempts: got %d wanted %d", len(received), c.attempts)
+			}
+			if !reflect.DeepEqual(received[0], e) {
+				t.Errorf("unexpected event: got %v wanted %v", received[0], e)
+			}
+		})
+	}
+}
+
+func TestFilterSink(t *testing.T) {
+	recorder := &recordingSink{}
+	f := NewFilterSink("filter", recorder, DataLost)
+	for _, typ := range []Type{MetricTableCreated, DataLost, SeriesDeleted} {
+		if err := f.Send(Event{Type: typ}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(recorder.events) != 1 || recorder.events[0].Type != DataLost {
+		t.Errorf("unexpected events: %v", recorder.events)
+	}
+}
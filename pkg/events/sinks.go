@@ -0,0 +1,138 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+const (
+	// DefaultWebhookTimeout bounds every attempt to deliver an event to a
+	// webhook.
+	DefaultWebhookTimeout = 10 * time.Second
+	// DefaultWebhookRetries is the number of times the delivery of an event
+	// to a webhook is retried.
+	DefaultWebhookRetries = 3
+)
+
+// webhookRetryBackoff is the wait before the first retry of a webhook, which
+// doubles with every retry.
+var webhookRetryBackoff = time.Second
+
+// LogSink logs the events at info level.
+type LogSink struct{}
+
+// Name implements Sink.
+func (LogSink) Name() string {
+	return "log"
+}
+
+// Send implements Sink.
+func (LogSink) Send(e Event) error {
+	keyvals := []interface{}{"msg", "Operational event", "event", e.Type}
+	keys := make([]string, 0, len(e.Attributes))
+	for k := range e.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		keyvals = append(keyvals, k, e.Attributes[k])
+	}
+	log.Info(keyvals...)
+	return nil
+}
+
+// WebhookSink posts every event, as JSON, to a URL.
+type WebhookSink struct {
+	url     string
+	client  *http.Client
+	retries int
+}
+
+// NewWebhookSink returns a sink posting to url, with the given timeout for
+// each attempt, and retrying up to retries times.
+func NewWebhookSink(url string, timeout time.Duration, retries int) *WebhookSink {
+	return &WebhookSink{url: url, client: &http.Client{Timeout: timeout}, retries: retries}
+}
+
+// Name implements Sink.
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+// Send implements Sink. Connection errors, 5xx and 429 responses are
+// retried with an exponential backoff, the other responses than 2xx fail
+// the delivery right away.
+func (s *WebhookSink) Send(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	backoff := webhookRetryBackoff
+	for attempt := 0; ; attempt++ {
+		var retryable bool
+		retryable, err = s.post(data)
+		if err == nil || !retryable || attempt >= s.retries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (s *WebhookSink) post(data []byte) (bool, error) {
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		retryable := resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests
+		return retryable, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return false, nil
+}
+
+// FilterSink passes the events of some types only to another sink.
+type FilterSink struct {
+	name  string
+	sink  Sink
+	types map[Type]bool
+}
+
+// NewFilterSink returns a sink named name, sending the events of the given
+// types to sink.
+func NewFilterSink(name string, sink Sink, types ...Type) *FilterSink {
+	f := &FilterSink{name: name, sink: sink, types: make(map[Type]bool, len(types))}
+	for _, t := range types {
+		f.types[t] = true
+	}
+	return f
+}
+
+// Name implements Sink.
+func (f *FilterSink) Name() string {
+	return f.name
+}
+
+// Send implements Sink.
+func (f *FilterSink) Send(e Event) error {
+	if !f.types[e.Type] {
+		return nil
+	}
+	return f.sink.Send(e)
+}
@@ -8,6 +8,10 @@ package log
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -18,6 +22,16 @@ var (
 	// Application wide logger
 	logger log.Logger
 
+	// minLevel is the rank of the minimum level logged, set by Init and
+	// SetLevel.
+	minLevel int32
+	// componentLevels holds the map of the components to the rank of their
+	// minimum level, overriding minLevel. It is replaced, never modified.
+	componentLevels atomic.Value
+	componentLock   sync.Mutex
+
+	levelNames = []string{"debug", "info", "warn", "error"}
+
 	// logger timestamp format
 	timestampFormat = log.TimestampFormat(
 		func() time.Time { return time.Now().UTC() },
@@ -25,20 +39,195 @@ var (
 	)
 )
 
+const (
+	// FormatJSON logs a JSON object per line.
+	FormatJSON = "json"
+	// FormatLogfmt logs key=value pairs.
+	FormatLogfmt = "logfmt"
+
+	componentKey = "component"
+)
+
 // Init starts logging given the minimum log level
 func Init(logLevel string) error {
+	return InitWithFormat(logLevel, FormatJSON)
+}
+
+// InitWithFormat starts logging given the minimum log level, in the given
+// format.
+func InitWithFormat(logLevel, format string) error {
+	if err := SetLevel(logLevel); err != nil {
+		return err
+	}
 	var l log.Logger
-	l = log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
-	logLevelOption, err := parseLogLevel(logLevel)
+	switch format {
+	case FormatJSON:
+		l = log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+	case FormatLogfmt:
+		l = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	default:
+		return fmt.Errorf("unrecognized log format %q", format)
+	}
+	l = levelFilter{next: l}
+	logger = log.With(l, "ts", timestampFormat, "caller", log.Caller(4))
+	return nil
+}
+
+// SetLevel changes the minimum log level, such as when the configuration is
+// reloaded.
+func SetLevel(logLevel string) error {
+	rank, err := parseLogLevel(logLevel)
 	if err != nil {
 		return err
 	}
+	atomic.StoreInt32(&minLevel, rank)
+	return nil
+}
 
-	l = level.NewFilter(l, logLevelOption)
-	logger = log.With(l, "ts", timestampFormat, "caller", log.Caller(4))
+// SetComponentLevel sets the minimum log level of a component, overriding
+// the global one. An empty level makes the component follow the global level
+// again.
+func SetComponentLevel(component, logLevel string) error {
+	var rank int32
+	if logLevel != "" {
+		var err error
+		if rank, err = parseLogLevel(logLevel); err != nil {
+			return err
+		}
+	}
+	componentLock.Lock()
+	defer componentLock.Unlock()
+	levels := make(map[string]int32)
+	for c, r := range loadComponentLevels() {
+		levels[c] = r
+	}
+	if logLevel == "" {
+		delete(levels, component)
+	} else {
+		levels[component] = rank
+	}
+	componentLevels.Store(levels)
 	return nil
 }
 
+// SetComponentLevels replaces the levels of all the components by those of
+// spec, comma-separated component=level pairs such as "ingest=warn,query=debug".
+func SetComponentLevels(spec string) error {
+	levels := make(map[string]int32)
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid component log level %q, expected component=level", pair)
+		}
+		rank, err := parseLogLevel(parts[1])
+		if err != nil {
+			return err
+		}
+		levels[parts[0]] = rank
+	}
+	componentLock.Lock()
+	defer componentLock.Unlock()
+	componentLevels.Store(levels)
+	return nil
+}
+
+// Levels returns the global log level and the levels set for the components.
+func Levels() (string, map[string]string) {
+	components := make(map[string]string)
+	for c, r := range loadComponentLevels() {
+		components[c] = levelNames[r]
+	}
+	return levelNames[atomic.LoadInt32(&minLevel)], components
+}
+
+// ComponentLevels returns the levels set for the components in the format of
+// SetComponentLevels.
+func ComponentLevels() string {
+	_, levels := Levels()
+	pairs := make([]string, 0, len(levels))
+	for c, l := range levels {
+		pairs = append(pairs, c+"="+l)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func loadComponentLevels() map[string]int32 {
+	levels, _ := componentLevels.Load().(map[string]int32)
+	return levels
+}
+
+// levelFilter drops the log lines below the minimum level of their
+// component, or the global one. Unlike the filter of go-kit, the levels can
+// change at runtime.
+type levelFilter struct {
+	next log.Logger
+}
+
+func (f levelFilter) Log(keyvals ...interface{}) error {
+	var (
+		rank      int32 = -1
+		component string
+	)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if v, ok := keyvals[i+1].(level.Value); ok {
+			rank, _ = parseLogLevel(v.String())
+		} else if keyvals[i] == componentKey {
+			component, _ = keyvals[i+1].(string)
+		}
+	}
+	if rank < 0 {
+		return f.next.Log(keyvals...)
+	}
+	min, ok := loadComponentLevels()[component]
+	if !ok {
+		min = atomic.LoadInt32(&minLevel)
+	}
+	if rank < min {
+		return nil
+	}
+	return f.next.Log(keyvals...)
+}
+
+// Logger logs the messages of a component, such as a subsystem of the
+// connector, whose level can be set independently with SetComponentLevel.
+type Logger struct {
+	component string
+}
+
+// Component returns the logger of a component.
+func Component(name string) Logger {
+	return Logger{component: name}
+}
+
+// Debug logs a DEBUG level message of the component, ignoring logging errors
+func (l Logger) Debug(keyvals ...interface{}) {
+	_ = level.Debug(logger).Log(append([]interface{}{componentKey, l.component}, keyvals...)...)
+}
+
+// Info logs an INFO level message of the component, ignoring logging errors
+func (l Logger) Info(keyvals ...interface{}) {
+	_ = level.Info(logger).Log(append([]interface{}{componentKey, l.component}, keyvals...)...)
+}
+
+// Warn logs a WARN level message of the component, ignoring logging errors
+func (l Logger) Warn(keyvals ...interface{}) {
+	_ = level.Warn(logger).Log(append([]interface{}{componentKey, l.component}, keyvals...)...)
+}
+
+// Error logs an ERROR level message of the component, ignoring logging errors
+func (l Logger) Error(keyvals ...interface{}) {
+	_ = level.Error(logger).Log(append([]interface{}{componentKey, l.component}, keyvals...)...)
+}
+
+// With adds the given key-value pairs to every subsequent log line
+func With(keyvals ...interface{}) {
+	logger = log.With(logger, keyvals...)
+}
+
 // Debug logs a DEBUG level message, ignoring logging errors
 func Debug(keyvals ...interface{}) {
 	_ = level.Debug(logger).Log(keyvals...)
@@ -75,17 +264,19 @@ func (c *CustomCacheLogger) Printf(format string, v ...interface{}) {
 	_ = level.Debug(logger).Log("msg", fmt.Sprintf(format, v...))
 }
 
-func parseLogLevel(logLevel string) (level.Option, error) {
+// parseLogLevel returns the rank of a log level, higher for the more severe
+// levels.
+func parseLogLevel(logLevel string) (int32, error) {
 	switch logLevel {
 	case "debug":
-		return level.AllowDebug(), nil
+		return 0, nil
 	case "info":
-		return level.AllowInfo(), nil
+		return 1, nil
 	case "warn":
-		return level.AllowWarn(), nil
+		return 2, nil
 	case "error":
-		return level.AllowError(), nil
+		return 3, nil
 	default:
-		return nil, fmt.Errorf("unrecognized log level %q", logLevel)
+		return 0, fmt.Errorf("unrecognized log level %q", logLevel)
 	}
 }
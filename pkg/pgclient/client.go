@@ -4,9 +4,12 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"runtime"
+	"io"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/allegro/bigcache"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4/pgxpool"
 
 	"github.com/timescale/timescale-prometheus/pkg/prompb"
@@ -18,15 +21,65 @@ import (
 
 // Config for the database
 type Config struct {
-	host             string
-	port             int
-	user             string
-	password         string
-	database         string
-	sslMode          string
-	dbConnectRetries int
-	AsyncAcks        bool
-	ReportInterval   int
+	host                string
+	port                int
+	user                string
+	password            string
+	database            string
+	sslMode             string
+	dbConnectRetries    int
+	AsyncAcks           bool
+	AckMode             string
+	ReportInterval      int
+	MaxInFlightSamples  int64
+	MaxInFlightBytes    int64
+	InFlightWaitTimeout time.Duration
+	Priorities          pgmodel.PriorityConfig
+	Retry               pgmodel.RetryConfig
+	Batch               pgmodel.BatchConfig
+	DedupSamples        bool
+	FastIngest          bool
+	StaleMarkers        string
+	SampleBounds        pgmodel.SampleBoundsConfig
+	LabelValidation     string
+	LabelLimits         pgmodel.LabelLimits
+	SeriesLimit         pgmodel.SeriesLimitConfig
+	MicrosecondMetrics  string
+	RelabelConfigFile   string
+	ExternalLabels      string
+	StripExternalLabels bool
+	RedactionRulesFile  string
+	FederationFile      string
+	IngestShardFile     string
+	ReadReplicaURLs     string
+	ReadReplicas        pgmodel.ReadReplicaConfig
+	InstanceID          string
+	InsertersPerMetric  int
+	SeriesCacheSize     int
+	SeriesCacheTTL      time.Duration
+	MetricCache         pgmodel.MetricCacheConfig
+	CacheInvalidation   bool
+	MissingMetricTTL    time.Duration
+	SharedCacheURL      string
+	SharedCacheTTL      time.Duration
+	SharedCachePrefix   string
+	SpillDir            string
+	SpillMaxSize        int64
+	SpillMaxAge         time.Duration
+	HADedup             bool
+	HAClusterLabel      string
+	HAReplicaLabel      string
+	HALeaseTimeout      time.Duration
+	QueryTimeout        time.Duration
+	QueryCache          pgmodel.QueryCacheConfig
+	QueryLog            pgmodel.QueryLogConfig
+	SnapshotReads       bool
+	TenantRoles         bool
+	TenantRolePrefix    string
+	SessionURL          string
+	WritePool           PoolConfig
+	ReadPool            PoolConfig
+	Conn                ConnConfig
 }
 
 // ParseFlags parses the configuration flags specific to PostgreSQL and TimescaleDB
@@ -38,8 +91,89 @@ func ParseFlags(cfg *Config) *Config {
 	flag.StringVar(&cfg.database, "db-name", "timescale", "The TimescaleDB database")
 	flag.StringVar(&cfg.sslMode, "db-ssl-mode", "disable", "The TimescaleDB connection ssl mode")
 	flag.IntVar(&cfg.dbConnectRetries, "db-connect-retries", 0, "How many times to retry connecting to the database")
-	flag.BoolVar(&cfg.AsyncAcks, "async-acks", false, "Ack before data is written to DB")
+	flag.IntVar(&cfg.InsertersPerMetric, "inserters-per-metric", 1, "Number of concurrent insert routines per metric. Raise it when a few very hot metrics bottleneck ingestion.")
+	flag.IntVar(&cfg.SeriesCacheSize, "series-cache-size", pgmodel.DefaultSeriesCacheSize, "Maximum number of series ids kept in the in-memory cache shared by all insert routines. Least recently used series are evicted when it is full.")
+	flag.DurationVar(&cfg.SeriesCacheTTL, "series-cache-ttl", 0, "How long series ids are cached before being looked up in the database again (0 caches them until they are evicted). Bounds how long a series deleted without -cache-invalidation keeps being written to with its old id.")
+	flag.IntVar(&cfg.MetricCache.MaxSizeMB, "metric-cache-size-mb", 0, "Maximum size in megabytes of the in-memory cache of the metric table names (0 means unlimited). The oldest table names are evicted past it.")
+	flag.IntVar(&cfg.MetricCache.Shards, "metric-cache-shards", pgmodel.DefaultMetricCacheShards, "Number of shards of the metric cache, a power of two. More shards make concurrent lookups contend less.")
+	flag.DurationVar(&cfg.MetricCache.TTL, "metric-cache-ttl", pgmodel.DefaultMetricCacheTTL, "How long the metric table names are cached before being looked up in the catalog again.")
+	flag.DurationVar(&cfg.MissingMetricTTL, "missing-metric-cache-ttl", pgmodel.DefaultMissingMetricTTL, "How long the remote reads remember that a metric has no table, so that the reads of a metric not written yet do not look it up in the catalog every time (0 disables it).")
+	flag.StringVar(&cfg.SharedCacheURL, "shared-cache-url", "", "URL of a Redis (redis://[:password@]host:port[/db]) or memcached (memcached://host:port) server sharing the metric and series caches between the connectors, so that a new replica does not warm its own caches from the database. Requires -cache-invalidation.")
+	flag.DurationVar(&cfg.SharedCacheTTL, "shared-cache-ttl", pgmodel.DefaultSharedCacheTTL, "How long the entries of the shared caches are kept.")
+	flag.StringVar(&cfg.SharedCachePrefix, "shared-cache-prefix", pgmodel.DefaultSharedCachePrefix, "Prefix of the keys of the shared caches, telling apart the connectors of different databases using the same cache server.")
+	flag.BoolVar(&cfg.CacheInvalidation, "cache-invalidation", true, "Listen to the notifications of the metrics created, changed or dropped and of the series deleted by the other connectors and the retention, and invalidate them in the metric and series caches. Behind -db-transaction-pooler the notifications are listened to through -db-session-url.")
+	flag.StringVar(&cfg.InstanceID, "instance-id", "", "Stable identifier of this connector instance, used in logs, metrics and the database application_name. Generated from the hostname if empty.")
+	flag.BoolVar(&cfg.AsyncAcks, "async-acks", false, "Ack before data is written to DB. Same as -ack-mode=async.")
+	flag.StringVar(&cfg.AckMode, "ack-mode", string(pgmodel.AckModeSync), "When the writes are acknowledged: sync once their COPY transactions commit, flush once these commits are flushed to the WAL whatever synchronous_commit, grouping the flushes of the batches committed meanwhile, or async before they are written, losing the samples failing to be written or in flight when the connector stops.")
 	flag.IntVar(&cfg.ReportInterval, "tput-report", 0, "interval in seconds at which throughput should be reported")
+	flag.Int64Var(&cfg.MaxInFlightSamples, "max-in-flight-samples", 0, "Maximum number of samples accepted but not yet written to the database (0 means unlimited). Writes over the limit wait for space.")
+	flag.Int64Var(&cfg.MaxInFlightBytes, "max-in-flight-bytes", 0, "Maximum memory held by the samples accepted but not yet written to the database, estimated as 16 bytes a sample plus the size of the labels of their series (0 means unlimited). "+
+		"Writes over the limit wait for space, like under max-in-flight-samples.")
+	flag.StringVar(&cfg.Priorities.Critical, "insert-priority-critical", "", "Regular expression of the critical metric names, which keep flowing under max-in-flight-samples backpressure.")
+	flag.StringVar(&cfg.Priorities.Low, "insert-priority-low", "", "Regular expression of the low priority metric names, which are dropped first under max-in-flight-samples backpressure.")
+	flag.Float64Var(&cfg.Priorities.LowShare, "insert-priority-low-share", pgmodel.DefaultLowPriorityShare, "Share of max-in-flight-samples low priority metrics may use before being dropped.")
+	flag.Float64Var(&cfg.Priorities.CriticalReserve, "insert-priority-critical-reserve", pgmodel.DefaultCriticalReserve, "Share of max-in-flight-samples reserved for the critical metrics.")
+	flag.IntVar(&cfg.Retry.MaxAttempts, "db-write-retries", pgmodel.DefaultRetryAttempts, "Number of attempts of a database write failing with a transient error, such as a serialization failure or a lost connection, before the write fails (1 disables retries).")
+	flag.DurationVar(&cfg.Retry.Backoff, "db-write-retry-backoff", pgmodel.DefaultRetryBackoff, "Wait before the first retry of a failed database write, doubled after each retry.")
+	flag.DurationVar(&cfg.Retry.MaxBackoff, "db-write-retry-max-backoff", pgmodel.DefaultRetryMaxBackoff, "Maximum wait between the retries of a failed database write.")
+	flag.IntVar(&cfg.Batch.MaxSeries, "ingest-batch-max-series", pgmodel.DefaultBatchMaxSeries, "Maximum number of series of a metric copied in one batch. Larger writes are split between several batches.")
+	flag.Int64Var(&cfg.Batch.MaxBytes, "ingest-batch-max-bytes", 0, "Maximum size in bytes of the samples of a batch in the COPY format, 38 bytes a sample (0 means no limit but -ingest-batch-max-series).")
+	flag.DurationVar(&cfg.Batch.Timeout, "ingest-batch-timeout", 0, "How long a batch which is not full waits for more writes of its metric before being copied (0 copies it as soon as no write is queued).")
+	flag.DurationVar(&cfg.Batch.TargetLatency, "ingest-batch-target-latency", 0, "Target time from the first write of a batch to the end of its COPY. When set, the number of series of the batches shrinks while their latency is over the target, and grows back up to -ingest-batch-max-series while it is under half of it (0 disables adaptive batching).")
+	flag.IntVar(&cfg.Batch.MinSeries, "ingest-batch-min-series", pgmodel.DefaultBatchMinSeries, "Number of series adaptive batching does not shrink the batches under.")
+	flag.BoolVar(&cfg.DedupSamples, "dedup-samples", false, "Skip the samples already stored for the same series and time, such as those re-sent by Prometheus after a failed write, instead of storing duplicate rows. Inserts are slower than with COPY.")
+	flag.BoolVar(&cfg.FastIngest, "fast-ingest", false, "Copy samples without firing the triggers added to the data tables, when the database user may set session_replication_role and no foreign keys would go unchecked.")
+	flag.StringVar(&cfg.StaleMarkers, "stale-markers", string(pgmodel.StaleMarkersStore), "What is done with the staleness markers Prometheus sends when a series disappears: store them as samples, drop them, or record when each series went stale in the catalog (liveness), in which case reads emit the markers back.")
+	flag.DurationVar(&cfg.SampleBounds.MaxAge, "ingest-max-sample-age", 0, "Reject the samples older than this when received, like Prometheus rejects the samples out of the bounds of its head block (0 disables it). The other samples of the write are stored, and the write fails with 400 Bad Request so that Prometheus does not retry it.")
+	flag.StringVar(&cfg.LabelValidation, "ingest-label-validation", string(pgmodel.LabelValidationReject), "What is done with the ingested series with invalid label names, or label values not valid UTF-8 or holding NUL characters: reject them, or sanitize them by replacing the invalid characters. The series without a metric name, with duplicate label names or too long are always rejected. The other series of the write are stored, and a write with rejected series fails with 400 Bad Request.")
+	flag.IntVar(&cfg.LabelLimits.MaxLabels, "ingest-max-labels-per-series", 0, "Reject the ingested series with more labels than this, including the metric name (0 disables it).")
+	flag.IntVar(&cfg.LabelLimits.MaxNameLength, "ingest-max-label-name-length", 0, "Reject the ingested series with a label name longer than this many bytes (0 disables it).")
+	flag.IntVar(&cfg.LabelLimits.MaxValueLength, "ingest-max-label-value-length", 0, "Reject the ingested series with a label value longer than this many bytes (0 disables it).")
+	flag.DurationVar(&cfg.SampleBounds.MaxFuture, "ingest-max-sample-future", 0, "Reject the samples further in the future than this when received, usually sent by a host with a wrong clock (0 disables it).")
+	flag.IntVar(&cfg.SeriesLimit.MaxSeriesPerMetric, "max-series-per-metric", 0, "Maximum number of active series of a metric. The samples of the new series over it are dropped and counted in ts_prom_dropped_series_over_limit_total (0 disables the limit).")
+	flag.DurationVar(&cfg.SeriesLimit.ActiveWindow, "series-active-window", pgmodel.DefaultSeriesActiveWindow, "How long a series counts against -max-series-per-metric after its last sample.")
+	flag.StringVar(&cfg.RelabelConfigFile, "relabel-config-file", "", "YAML file listing relabel configs, in the format of the write_relabel_configs of Prometheus, applied to the ingested series before they are stored. Reloaded on SIGHUP.")
+	flag.StringVar(&cfg.ExternalLabels, "external-labels", "", "Comma-separated name=value labels, such as region=eu,cluster=a, added to the ingested series lacking them before relabeling, like the external_labels of Prometheus.")
+	flag.BoolVar(&cfg.StripExternalLabels, "read-strip-external-labels", false, "Remove the -external-labels from the series returned by remote reads, where they have the configured values, for a Prometheus with the same external_labels to read them back.")
+	flag.StringVar(&cfg.RedactionRulesFile, "read-redaction-rules-file", "", "YAML file listing rules that drop or mask label values, such as user IDs, in the series returned by remote reads to the callers other than the -auth-admin-users.")
+	flag.StringVar(&cfg.MicrosecondMetrics, "microsecond-metrics", "", "Regular expression of the metric names whose sample timestamps are sent in microseconds instead of milliseconds, by senders with sub-millisecond timestamps. Reads return them in microseconds with the X-Timestamp-Precision: us header.")
+	flag.DurationVar(&cfg.InFlightWaitTimeout, "in-flight-wait-timeout", 0, "How long a write waits for space under max-in-flight-samples or max-in-flight-bytes before being rejected with 429 Too Many Requests (0 means wait forever).")
+	flag.StringVar(&cfg.SpillDir, "spill-dir", "", "Directory where write requests are buffered while the database is unreachable, and replayed from once it is back. Empty disables spilling.")
+	flag.Int64Var(&cfg.SpillMaxSize, "spill-max-size", pgmodel.DefaultSpillMaxSize, "Maximum number of bytes buffered in spill-dir. Writes are rejected once it is reached.")
+	flag.DurationVar(&cfg.SpillMaxAge, "spill-max-age", pgmodel.DefaultSpillMaxAge, "How long write requests are kept in spill-dir before being dropped (0 means forever).")
+	flag.BoolVar(&cfg.HADedup, "ha-dedup", false, "Store the samples of a single replica of every cluster of HA Prometheus servers, identified by the ha-cluster-label and ha-replica-label external labels, and drop the others.")
+	flag.StringVar(&cfg.HAClusterLabel, "ha-cluster-label", pgmodel.DefaultHAClusterLabel, "External label naming the cluster of HA Prometheus replicas.")
+	flag.StringVar(&cfg.HAReplicaLabel, "ha-replica-label", pgmodel.DefaultHAReplicaLabel, "External label naming the HA Prometheus replica. It is removed from the stored series.")
+	flag.DurationVar(&cfg.QueryTimeout, "db-query-timeout", 0, "Maximum time a remote read may spend querying the database before its SQL is canceled (0 means no timeout).")
+	flag.StringVar(&cfg.FederationFile, "read-federation-config-file", "", "YAML file listing other databases whose metrics the remote reads return along with those of the connector's database, under a label naming the database of each series.")
+	flag.StringVar(&cfg.IngestShardFile, "ingest-shard-config-file", "", "YAML file listing other databases the written metrics are spread across along with the connector's database, every metric being written to the database its name hashes to. Without -read-federation-config-file, the remote reads merge the series of all the databases.")
+	flag.StringVar(&cfg.ReadReplicaURLs, "db-read-replica-urls", "", "Comma-separated connection URLs of streaming replicas of the database. The remote reads are balanced across the replicas lagging less than -db-read-replica-max-lag behind, and run on the primary while none does. The writes always run on the primary.")
+	flag.DurationVar(&cfg.ReadReplicas.MaxLag, "db-read-replica-max-lag", pgmodel.DefaultReplicaMaxLag, "Replication lag above which a read replica stops being read, until it catches up.")
+	flag.DurationVar(&cfg.ReadReplicas.CheckInterval, "db-read-replica-check-interval", pgmodel.DefaultReplicaCheckInterval, "How often the health and replication lag of the read replicas are checked.")
+	flag.IntVar(&cfg.QueryCache.MaxSizeMB, "query-cache-size-mb", 0, "Size in megabytes of the in-memory cache of remote read results (0 disables it).")
+	flag.DurationVar(&cfg.QueryCache.TTL, "query-cache-ttl", pgmodel.DefaultQueryCacheTTL, "How long remote read results are cached.")
+	flag.DurationVar(&cfg.QueryCache.Alignment, "query-cache-alignment", pgmodel.DefaultQueryCacheAlignment, "Step the time ranges of cached remote read results are aligned to, so that repeated queries over slightly different ranges share them.")
+	flag.DurationVar(&cfg.QueryCache.RecentWindow, "query-cache-recent-window", pgmodel.DefaultQueryCacheRecentWindow, "Window before now that remote reads always read from the database, since samples may still be written in it.")
+	flag.BoolVar(&cfg.QueryLog.Enabled, "query-log", false, "Log every remote read query with its matchers, time range, returned series and samples, and duration.")
+	flag.DurationVar(&cfg.QueryLog.SlowThreshold, "query-log-slow-threshold", 0, "Log the remote read queries taking longer at warn level, even if -query-log is disabled (0 disables it).")
+	flag.BoolVar(&cfg.SnapshotReads, "query-snapshot-reads", false, "Run the SELECTs of a remote read query matching several metrics in a single REPEATABLE READ transaction, so that they read a consistent snapshot while samples are being written. Reads can override it with the X-Query-Snapshot header.")
+	flag.BoolVar(&cfg.TenantRoles, "query-tenant-roles", false, "Run the SELECTs of a remote read as the database role of the authenticated user, with app.tenant set to the user, so that row-level security policies isolate the tenants. Requires -auth-htpasswd-file, reads without a user being rejected.")
+	flag.StringVar(&cfg.TenantRolePrefix, "query-tenant-role-prefix", "", "Prefix of the database role of a user with -query-tenant-roles, such as tenant_ for the role tenant_alice of the user alice.")
+	writePool, readPool := defaultPools()
+	flag.IntVar(&cfg.WritePool.MaxConns, "db-max-connections", writePool.MaxConns, "Maximum number of connections of the pool used to write samples, by default "+strconv.Itoa(pgmodel.ConnectionsPerProc)+" per CPU.")
+	flag.IntVar(&cfg.WritePool.MinConns, "db-min-connections", writePool.MinConns, "Number of connections the write pool keeps open, by default one per CPU.")
+	flag.IntVar(&cfg.ReadPool.MaxConns, "db-read-max-connections", readPool.MaxConns, "Maximum number of connections of the separate pool used by remote reads, by default "+strconv.Itoa(readConnectionsPerProc)+" per CPU. Reads never use the connections of the write pool.")
+	flag.IntVar(&cfg.ReadPool.MinConns, "db-read-min-connections", readPool.MinConns, "Number of connections the read pool keeps open.")
+	flag.DurationVar(&cfg.Conn.MaxLifetime, "db-connection-max-lifetime", defaultConnMaxLifetime, "How long a database connection is used before being replaced.")
+	flag.DurationVar(&cfg.Conn.MaxIdleTime, "db-connection-max-idle-time", defaultConnMaxIdleTime, "How long a database connection stays idle before being closed, down to the minimum number of connections of its pool.")
+	flag.DurationVar(&cfg.Conn.HealthCheckPeriod, "db-connection-health-check-period", defaultHealthCheckPeriod, "How often the connection pools close the connections past their max lifetime or idle time, and open those missing to their minimum number of connections.")
+	flag.DurationVar(&cfg.Conn.ProbeInterval, "db-connection-probe-interval", defaultConnProbeInterval, "How often the idle database connections are pinged, the failing ones being closed so that a connection dropped while idle, such as by a firewall, does not fail the next query (0 disables the probes).")
+	flag.IntVar(&cfg.Conn.StatementCacheSize, "db-statement-cache-size", defaultStatementCacheSize, "Number of prepared statements cached per database connection (0 disables the cache).")
+	flag.StringVar(&cfg.Conn.StatementCacheMode, "db-statement-cache-mode", defaultStatementCacheMode, "How statements are cached: \"prepare\" prepares them on the server, \"describe\" only caches their description, which works behind poolers such as PgBouncer in transaction mode.")
+	flag.BoolVar(&cfg.Conn.TransactionPooler, "db-transaction-pooler", false, "Connect through a pooler in transaction pooling mode, such as PgBouncer with pool_mode=transaction. The statement cache only describes the statements, whatever -db-statement-cache-mode, and the session-level work, the migrations and the leader election by advisory lock, goes through -db-session-url.")
+	flag.StringVar(&cfg.SessionURL, "db-session-url", "", "Connection URL of the session-level work with -db-transaction-pooler, direct to the database or through a pooler in session pooling mode.")
+	flag.StringVar(&cfg.Conn.StatementProtocols, "db-statement-protocols", "", "Comma-separated class=protocol pairs, such as series=prepare,metric=simple, overriding -db-statement-cache-mode for the hot statements of a class: series (series id lookups), metric (metric table lookups) or histogram (native histogram inserts). \"prepare\" prepares them on every connection when it is opened, \"simple\" sends them with the simple protocol without any prepare or describe round trip, and \"default\" follows -db-statement-cache-mode.")
+	flag.DurationVar(&cfg.HALeaseTimeout, "ha-lease-timeout", pgmodel.DefaultHALeaseTimeout, "How long an HA replica stays the leader of its cluster after it stops writing. Another replica takes over once it expires.")
 	return cfg
 }
 
@@ -47,23 +181,54 @@ func ParseFlags(cfg *Config) *Config {
 type Client struct {
 	Connection    *pgxpool.Pool
 	ingestor      *pgmodel.DBIngestor
+	inserter      pgmodel.DBInserter
+	spill         *pgmodel.SpillBuffer
 	reader        *pgmodel.DBReader
+	health        *pgmodel.HealthReporter
 	cfg           *Config
 	ConnectionStr string
+	// ReadConnection is the pool used by remote reads, separate from
+	// Connection so that a burst of reads cannot starve the writes.
+	ReadConnection *pgxpool.Pool
+	// probers ping the idle connections of the pools.
+	probers []*connProber
+	// invalidator is nil unless cache invalidation is enabled.
+	invalidator *pgmodel.CacheInvalidator
+	// sharedCache is nil unless the caches are shared with the other
+	// connectors.
+	sharedCache *pgmodel.SharedCache
+	// federatedPools are the pools of the other databases read.
+	federatedPools []*pgxpool.Pool
+	// shardPools are the write pools of the ingest shards.
+	shardPools []*pgxpool.Pool
+	// replicaPools are the pools of the read replicas, and replicas routes
+	// the reads to them. replicas is nil without read replicas.
+	replicaPools []*pgxpool.Pool
+	replicas     *pgmodel.ReadReplicas
 }
 
 // NewClient creates a new PostgreSQL client
 func NewClient(cfg *Config) (*Client, error) {
 	connectionStr := cfg.GetConnectionStr()
 
-	maxProcs := runtime.GOMAXPROCS(-1)
-	if maxProcs <= 0 {
-		maxProcs = runtime.NumCPU()
+	var sharedCache *pgmodel.SharedCache
+	if cfg.SharedCacheURL != "" {
+		// The entries of the shared caches outlive the connectors, and are
+		// only kept consistent with the catalog by the invalidations.
+		if !cfg.CacheInvalidation {
+			return nil, fmt.Errorf("-shared-cache-url requires -cache-invalidation")
+		}
+		if _, err := cfg.GetSessionConnectionStr(); err != nil {
+			return nil, fmt.Errorf("-shared-cache-url requires cache invalidation: %w", err)
+		}
+		var err error
+		if sharedCache, err = pgmodel.NewSharedCache(cfg.SharedCacheURL, cfg.SharedCachePrefix, cfg.SharedCacheTTL); err != nil {
+			log.Error("err creating shared cache", util.MaskPassword(err.Error()))
+			return nil, err
+		}
 	}
-	if maxProcs <= 0 {
-		maxProcs = 1
-	}
-	connectionPool, err := pgxpool.Connect(context.Background(), connectionStr+fmt.Sprintf(" pool_max_conns=%d pool_min_conns=%d", maxProcs*pgmodel.ConnectionsPerProc, maxProcs))
+
+	connectionPool, writeProber, err := connectPool(connectionStr, "write", cfg.WritePool, cfg.Conn)
 
 	log.Info("msg", util.MaskPassword(connectionStr))
 
@@ -72,42 +237,405 @@ func NewClient(cfg *Config) (*Client, error) {
 		return nil, err
 	}
 
-	metrics, _ := bigcache.NewBigCache(pgmodel.DefaultCacheConfig())
-	cache := &pgmodel.MetricNameCache{Metrics: metrics}
+	readPool, readProber, err := connectPool(connectionStr, "read", cfg.ReadPool, cfg.Conn)
+	if err != nil {
+		log.Error("err creating read connection pool for new client", util.MaskPassword(err.Error()))
+		writeProber.close()
+		connectionPool.Close()
+		return nil, err
+	}
+
+	cache, err := pgmodel.NewMetricNameCache(cfg.MetricCache)
+	if err != nil {
+		log.Error("err creating metric cache", err)
+		writeProber.close()
+		readProber.close()
+		connectionPool.Close()
+		readPool.Close()
+		return nil, err
+	}
+	cache.MissingTTL = cfg.MissingMetricTTL
+	if sharedCache != nil {
+		cache.Metrics = pgmodel.NewTieredCache(cache.Metrics, sharedCache)
+	}
+
+	// Validated by connectPool.
+	statementProtocols, _ := pgmodel.ParseStatementProtocols(cfg.Conn.StatementProtocols)
+
+	externalLabels, err := pgmodel.ParseExternalLabels(cfg.ExternalLabels)
+	if err != nil {
+		log.Error("err parsing external labels", err)
+		return nil, err
+	}
+
+	shardCfg, shards, shardProbers, err := connectShards(cfg)
+	if err != nil {
+		log.Error("err creating connection pool for ingest shard", util.MaskPassword(err.Error()))
+		return nil, err
+	}
 
-	c := pgmodel.Cfg{AsyncAcks: cfg.AsyncAcks, ReportInterval: cfg.ReportInterval}
+	c := pgmodel.Cfg{
+		AsyncAcks:           cfg.AsyncAcks,
+		AckMode:             pgmodel.AckMode(cfg.AckMode),
+		ReportInterval:      cfg.ReportInterval,
+		MaxInFlightSamples:  cfg.MaxInFlightSamples,
+		MaxInFlightBytes:    cfg.MaxInFlightBytes,
+		InFlightWaitTimeout: cfg.InFlightWaitTimeout,
+		Priorities:          cfg.Priorities,
+		Retry:               cfg.Retry,
+		Batch:               cfg.Batch,
+		DedupSamples:        cfg.DedupSamples,
+		BypassTriggers:      cfg.FastIngest,
+		StaleMarkers:        pgmodel.StaleMarkerPolicy(cfg.StaleMarkers),
+		SampleBounds:        cfg.SampleBounds,
+		LabelValidation:     pgmodel.LabelValidation(cfg.LabelValidation),
+		LabelLimits:         cfg.LabelLimits,
+		SeriesLimit:         cfg.SeriesLimit,
+		MicrosecondMetrics:  cfg.MicrosecondMetrics,
+		RelabelConfigFile:   cfg.RelabelConfigFile,
+		ExternalLabels:      externalLabels,
+		InsertersPerMetric:  cfg.InsertersPerMetric,
+		SeriesCacheSize:     cfg.SeriesCacheSize,
+		SeriesCacheTTL:      cfg.SeriesCacheTTL,
+		SharedCache:         sharedCache,
+		StatementProtocols:  statementProtocols,
+		Shards:              shards,
+	}
+	if shardCfg != nil {
+		c.LocalShard = shardCfg.LocalShard
+		c.ShardVirtualNodes = shardCfg.VirtualNodes
+	}
 	ingestor, err := pgmodel.NewPgxIngestorWithMetricCache(connectionPool, cache, &c)
 	if err != nil {
 		log.Error("err starting ingestor", err)
 		return nil, err
 	}
-	reader := pgmodel.NewPgxReaderWithMetricCache(connectionPool, cache)
+	configureQueries := func(r *pgmodel.DBReader) {
+		r.EnableStatementProtocols(statementProtocols)
+		r.EnableTombstones()
+		if cfg.SnapshotReads {
+			r.EnableSnapshotReads()
+		}
+		if cfg.TenantRoles {
+			r.EnableTenantRoles(cfg.TenantRolePrefix)
+		}
+		if c.StaleMarkers == pgmodel.StaleMarkersLiveness {
+			r.EnableLivenessMarkers()
+		}
+	}
+	reader := pgmodel.NewPgxReaderWithMetricCache(readPool, cache)
+	reader.EnableQueryLog(cfg.QueryLog)
+	configureQueries(reader)
+	replicas, replicaPools, replicaProbers, err := connectReplicas(cfg)
+	if err != nil {
+		log.Error("err creating connection pool for read replica", util.MaskPassword(err.Error()))
+		ingestor.Close()
+		return nil, err
+	}
+	readReplicas := reader.EnableReadReplicas(replicas, cfg.ReadReplicas)
+	var (
+		federatedPools   []*pgxpool.Pool
+		federatedProbers []*connProber
+	)
+	var federation *pgmodel.FederationConfig
+	switch {
+	case cfg.FederationFile != "":
+		if federation, err = pgmodel.LoadFederationConfig(cfg.FederationFile); err != nil {
+			log.Error("err loading federation config", err)
+			ingestor.Close()
+			return nil, err
+		}
+	case shardCfg != nil:
+		federation = shardCfg.FederationConfig()
+	}
+	if federation != nil {
+		sources := make(map[string]*pgmodel.DBReader, len(federation.Sources))
+		for _, s := range federation.Sources {
+			pool, prober, err := connectPool(s.DBURL, "read-"+s.Name, cfg.ReadPool, cfg.Conn)
+			if err != nil {
+				log.Error("err creating connection pool for federated source", s.Name, "err", util.MaskPassword(err.Error()))
+				for i, p := range federatedPools {
+					federatedProbers[i].close()
+					p.Close()
+				}
+				ingestor.Close()
+				return nil, err
+			}
+			federatedPools = append(federatedPools, pool)
+			federatedProbers = append(federatedProbers, prober)
+			sources[s.Name] = pgmodel.NewPgxReader(pool)
+			configureQueries(sources[s.Name])
+		}
+		reader.EnableFederation(federation, sources)
+	}
+	if cfg.StripExternalLabels {
+		reader.EnableExternalLabelStripping(externalLabels)
+	}
+	if cfg.RedactionRulesFile != "" {
+		rules, err := pgmodel.LoadRedactionRules(cfg.RedactionRulesFile)
+		if err != nil {
+			log.Error("err loading redaction rules", err)
+			ingestor.Close()
+			return nil, err
+		}
+		reader.EnableRedaction(rules)
+	}
+	if cfg.QueryCache.MaxSizeMB > 0 {
+		if err = reader.EnableQueryCache(cfg.QueryCache); err != nil {
+			log.Error("err starting query cache", err)
+			ingestor.Close()
+			return nil, err
+		}
+	}
+
+	health, err := pgmodel.NewHealthReporter(connectionPool)
+	if err != nil {
+		log.Error("err starting health reporter", err)
+		return nil, err
+	}
+
+	client := &Client{Connection: connectionPool, ingestor: ingestor, inserter: ingestor, reader: reader, health: health, cfg: cfg, ReadConnection: readPool}
+	client.probers = append(append(append([]*connProber{writeProber, readProber}, shardProbers...), replicaProbers...), federatedProbers...)
+	for _, shard := range shards {
+		client.shardPools = append(client.shardPools, shard.Pool)
+	}
+	client.federatedPools = federatedPools
+	client.replicaPools = replicaPools
+	client.replicas = readReplicas
+	client.sharedCache = sharedCache
+
+	if cfg.CacheInvalidation {
+		listenConnStr, err := cfg.GetSessionConnectionStr()
+		if err != nil {
+			log.Warn("msg", "Cache invalidation disabled", "err", err)
+		} else {
+			client.invalidator = pgmodel.NewCacheInvalidator(connectionPool, listenConnStr, cache, ingestor)
+		}
+	}
+
+	if cfg.SpillDir != "" {
+		client.spill, err = pgmodel.NewSpillBuffer(ingestor, reader.HealthCheck, pgmodel.SpillConfig{
+			Dir:     cfg.SpillDir,
+			MaxSize: cfg.SpillMaxSize,
+			MaxAge:  cfg.SpillMaxAge,
+		})
+		if err != nil {
+			log.Error("err starting spill buffer", err)
+			ingestor.Close()
+			return nil, err
+		}
+		client.inserter = client.spill
+	}
+
+	if cfg.HADedup {
+		client.inserter = pgmodel.NewHADeduplicator(connectionPool, client.inserter, pgmodel.HAConfig{
+			ClusterLabel: cfg.HAClusterLabel,
+			ReplicaLabel: cfg.HAReplicaLabel,
+			LeaseTimeout: cfg.HALeaseTimeout,
+		})
+	}
+
+	return client, nil
+}
 
-	return &Client{Connection: connectionPool, ingestor: ingestor, reader: reader, cfg: cfg}, nil
+// AcksBeforeWrite tells whether the writes are acknowledged before being
+// stored, with -async-acks or -ack-mode=async.
+func (cfg *Config) AcksBeforeWrite() bool {
+	return cfg.AsyncAcks || cfg.AckMode == string(pgmodel.AckModeAsync)
 }
 
 // GetConnectionStr returns a Postgres connection string
 func (cfg *Config) GetConnectionStr() string {
-	return fmt.Sprintf("host=%v port=%v user=%v dbname=%v password='%v' sslmode=%v connect_timeout=10",
+	connStr := fmt.Sprintf("host=%v port=%v user=%v dbname=%v password='%v' sslmode=%v connect_timeout=10",
 		cfg.host, cfg.port, cfg.user, cfg.database, cfg.password, cfg.sslMode)
+	if cfg.InstanceID != "" {
+		connStr += fmt.Sprintf(" application_name='%v'", applicationName(cfg.InstanceID))
+	}
+	if cfg.Conn.TransactionPooler {
+		connStr += " statement_cache_mode=" + statementCacheModeDescribe
+	}
+	return connStr
+}
+
+// GetSessionConnectionStr returns the connection string of the work relying
+// on session-level features, such as advisory locks held across
+// transactions. It is -db-session-url behind a transaction pooler, which
+// must then be set, and the connection string otherwise.
+func (cfg *Config) GetSessionConnectionStr() (string, error) {
+	if !cfg.Conn.TransactionPooler {
+		return cfg.GetConnectionStr(), nil
+	}
+	if cfg.SessionURL == "" {
+		return "", fmt.Errorf("-db-session-url is required for the session-level work behind a transaction pooler")
+	}
+	return cfg.SessionURL, nil
+}
+
+// applicationName returns the application_name reported to PostgreSQL.
+// PostgreSQL truncates application names longer than 63 bytes.
+func applicationName(instanceID string) string {
+	name := "timescale-prometheus@" + instanceID
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(name)
 }
 
 // Close closes the client and performs cleanup
 func (c *Client) Close() {
+	c.invalidator.Close()
+	for _, p := range c.probers {
+		p.close()
+	}
+	if c.spill != nil {
+		if err := c.spill.Close(); err != nil {
+			log.Error("msg", "Closing the spill buffer failed", "err", err)
+		}
+	}
 	c.ingestor.Close()
+	for _, p := range c.shardPools {
+		p.Close()
+	}
+	if c.sharedCache != nil {
+		c.sharedCache.Close()
+	}
+	for _, p := range c.federatedPools {
+		p.Close()
+	}
+	c.replicas.Close()
+	for _, p := range c.replicaPools {
+		p.Close()
+	}
+}
+
+// connectShards opens the write pools of the ingest shards, each with its own
+// metric cache.
+func connectShards(cfg *Config) (*pgmodel.IngestShardConfig, []pgmodel.ShardPool, []*connProber, error) {
+	if cfg.IngestShardFile == "" {
+		return nil, nil, nil, nil
+	}
+	shardCfg, err := pgmodel.LoadIngestShardConfig(cfg.IngestShardFile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var (
+		shards  []pgmodel.ShardPool
+		probers []*connProber
+	)
+	closeShards := func() {
+		for i, s := range shards {
+			probers[i].close()
+			s.Pool.Close()
+		}
+	}
+	for _, s := range shardCfg.Shards {
+		pool, prober, err := connectPool(s.DBURL, "write-"+s.Name, cfg.WritePool, cfg.Conn)
+		if err != nil {
+			closeShards()
+			return nil, nil, nil, fmt.Errorf("shard %s: %w", s.Name, err)
+		}
+		shards = append(shards, pgmodel.ShardPool{Name: s.Name, Pool: pool})
+		probers = append(probers, prober)
+		metrics, err := pgmodel.NewMetricNameCache(cfg.MetricCache)
+		if err != nil {
+			closeShards()
+			return nil, nil, nil, err
+		}
+		shards[len(shards)-1].Metrics = metrics
+	}
+	return shardCfg, shards, probers, nil
+}
+
+// connectReplicas opens the pools of the read replicas, named after their
+// host and port.
+func connectReplicas(cfg *Config) ([]pgmodel.ReadReplica, []*pgxpool.Pool, []*connProber, error) {
+	var (
+		replicas []pgmodel.ReadReplica
+		pools    []*pgxpool.Pool
+		probers  []*connProber
+	)
+	for i, url := range strings.Split(cfg.ReadReplicaURLs, ",") {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			continue
+		}
+		pool, prober, err := connectPool(url, fmt.Sprintf("read-replica-%d", i), cfg.ReadPool, cfg.Conn)
+		if err != nil {
+			for i, p := range pools {
+				probers[i].close()
+				p.Close()
+			}
+			return nil, nil, nil, err
+		}
+		// Already parsed by connectPool.
+		conn, _ := pgconn.ParseConfig(url)
+		replicas = append(replicas, pgmodel.ReadReplica{Name: fmt.Sprintf("%s:%d", conn.Host, conn.Port), Pool: pool})
+		pools = append(pools, pool)
+		probers = append(probers, prober)
+	}
+	return replicas, pools, probers, nil
 }
 
 // Ingest writes the timeseries object into the DB
-func (c *Client) Ingest(tts []prompb.TimeSeries, req *prompb.WriteRequest) (uint64, error) {
-	return c.ingestor.Ingest(tts, req)
+func (c *Client) Ingest(ctx context.Context, tts []prompb.TimeSeries, req *prompb.WriteRequest) (uint64, error) {
+	return c.inserter.Ingest(ctx, tts, req)
 }
 
 // Read returns the promQL query results
-func (c *Client) Read(req *prompb.ReadRequest) (*prompb.ReadResponse, error) {
-	return c.reader.Read(req)
+func (c *Client) Read(ctx context.Context, req *prompb.ReadRequest) (*prompb.ReadResponse, error) {
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	return c.reader.Read(ctx, req)
+}
+
+// ReadStreamed streams the promQL query results as chunk-encoded frames
+func (c *Client) ReadStreamed(ctx context.Context, req *prompb.ReadRequest, w io.Writer) error {
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	return c.reader.ReadStreamed(ctx, req, w)
 }
 
-// HealthCheck checks that the client is properly connected
+// queryContext bounds ctx by the configured query timeout, if any.
+func (c *Client) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.cfg.QueryTimeout > 0 {
+		return context.WithTimeout(ctx, c.cfg.QueryTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// HealthCheck checks that the client is properly connected and that the
+// database has the expected schema and extensions
 func (c *Client) HealthCheck() error {
-	return c.reader.HealthCheck()
+	return c.health.HealthCheck()
+}
+
+// ReadinessCheck checks that the client can serve requests
+func (c *Client) ReadinessCheck() (pgmodel.HealthReport, error) {
+	return c.health.ReadinessCheck()
+}
+
+// Pools returns the sizes of the write and read connection pools
+func (c *Client) Pools() (write, read PoolConfig) {
+	return c.cfg.WritePool, c.cfg.ReadPool
+}
+
+// ReloadRelabelConfig reads the relabel config file again
+func (c *Client) ReloadRelabelConfig() error {
+	return c.ingestor.ReloadRelabelConfig()
+}
+
+// EvictSeries removes deleted series from the series cache
+func (c *Client) EvictSeries(ids []pgmodel.SeriesID) {
+	c.ingestor.EvictSeries(ids)
+}
+
+// CompleteMetricCreation finalizes the creation of the metrics left
+// unfinalized.
+func (c *Client) CompleteMetricCreation() error {
+	return c.ingestor.CompleteMetricCreation()
+}
+
+// IngestedSamples returns the number of samples accepted per metric since startup
+func (c *Client) IngestedSamples() map[string]uint64 {
+	return c.ingestor.IngestedSamples()
 }
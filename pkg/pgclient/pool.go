@@ -0,0 +1,293 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgclient
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+const (
+	// readConnectionsPerProc sizes the read pool by default. Remote reads
+	// run one query at a time each, so they need fewer connections than
+	// the insert routines.
+	readConnectionsPerProc = 2
+
+	defaultConnMaxLifetime     = time.Hour
+	defaultConnMaxIdleTime     = 30 * time.Minute
+	defaultHealthCheckPeriod   = time.Minute
+	defaultConnProbeInterval   = time.Minute
+	connProbeTimeout           = 5 * time.Second
+	defaultStatementCacheSize  = 512
+	defaultStatementCacheMode  = "prepare"
+	statementCacheModeDescribe = "describe"
+)
+
+// PoolConfig sizes a connection pool.
+type PoolConfig struct {
+	MaxConns int `json:"max_conns"`
+	MinConns int `json:"min_conns"`
+}
+
+var connProbes = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ts_prom",
+		Name:      "db_connection_probes_total",
+		Help:      "Total number of idle database connections probed, by pool and result: ok, failed, or idle_closed for those idle longer than the max idle time.",
+	},
+	[]string{"pool", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(connProbes)
+}
+
+// ConnConfig configures the connections of both pools.
+type ConnConfig struct {
+	MaxLifetime time.Duration
+	MaxIdleTime time.Duration
+	// HealthCheckPeriod is how often the pools close their expired
+	// connections and open those missing to their minimum.
+	HealthCheckPeriod time.Duration
+	// ProbeInterval is how often the idle connections are pinged, 0
+	// disabling the probes.
+	ProbeInterval      time.Duration
+	StatementCacheSize int
+	StatementCacheMode string
+	// StatementProtocols sets the protocol of the hot statements, as
+	// comma-separated class=protocol pairs.
+	StatementProtocols string
+	// TransactionPooler is set when the connections go through a pooler in
+	// transaction pooling mode, which may run each transaction on another
+	// server connection: the statement cache then only describes the
+	// statements.
+	TransactionPooler bool
+}
+
+func maxProcs() int {
+	procs := runtime.GOMAXPROCS(-1)
+	if procs <= 0 {
+		procs = runtime.NumCPU()
+	}
+	if procs <= 0 {
+		procs = 1
+	}
+	return procs
+}
+
+// defaultPools returns the default sizes of the write and read pools.
+func defaultPools() (write, read PoolConfig) {
+	procs := maxProcs()
+	write = PoolConfig{MaxConns: procs * pgmodel.ConnectionsPerProc, MinConns: procs}
+	read = PoolConfig{MaxConns: procs * readConnectionsPerProc}
+	return write, read
+}
+
+func (c ConnConfig) validate() error {
+	if c.StatementCacheMode != defaultStatementCacheMode && c.StatementCacheMode != statementCacheModeDescribe {
+		return fmt.Errorf("invalid statement cache mode %q, expected %q or %q", c.StatementCacheMode, defaultStatementCacheMode, statementCacheModeDescribe)
+	}
+	if c.MaxLifetime <= 0 || c.MaxIdleTime <= 0 {
+		return fmt.Errorf("the connection max lifetime and idle time must be positive")
+	}
+	if c.HealthCheckPeriod <= 0 || c.ProbeInterval < 0 {
+		return fmt.Errorf("the connection health check period must be positive and the probe interval not negative")
+	}
+	if c.StatementCacheSize < 0 {
+		return fmt.Errorf("invalid statement cache size %d", c.StatementCacheSize)
+	}
+	if _, err := pgmodel.ParseStatementProtocols(c.StatementProtocols); err != nil {
+		return err
+	}
+	return nil
+}
+
+// connectPool opens a pool of the given size to connectionStr, with the
+// prober of its idle connections unless the probes are disabled.
+func connectPool(connectionStr, name string, size PoolConfig, conn ConnConfig) (*pgxpool.Pool, *connProber, error) {
+	if size.MaxConns < 1 || size.MinConns < 0 || size.MinConns > size.MaxConns {
+		return nil, nil, fmt.Errorf("invalid pool size: min %d, max %d", size.MinConns, size.MaxConns)
+	}
+	if err := conn.validate(); err != nil {
+		return nil, nil, err
+	}
+	cacheMode := conn.StatementCacheMode
+	if conn.TransactionPooler {
+		cacheMode = statementCacheModeDescribe
+	}
+	cfg, err := pgxpool.ParseConfig(withConnSettings(connectionStr,
+		"statement_cache_capacity", strconv.Itoa(conn.StatementCacheSize),
+		"statement_cache_mode", cacheMode))
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.MaxConns = int32(size.MaxConns)
+	cfg.MinConns = int32(size.MinConns)
+	cfg.MaxConnLifetime = conn.MaxLifetime
+	cfg.MaxConnIdleTime = conn.MaxIdleTime
+	cfg.HealthCheckPeriod = conn.HealthCheckPeriod
+	if protocols, _ := pgmodel.ParseStatementProtocols(conn.StatementProtocols); protocols.HasPrepared() {
+		cfg.AfterConnect = protocols.Prepare
+	}
+
+	var prober *connProber
+	if conn.ProbeInterval > 0 {
+		prober = newConnProber(name, conn.ProbeInterval, conn.MaxIdleTime)
+		cfg.BeforeAcquire = prober.beforeAcquire
+	}
+	pool, err := pgxpool.ConnectConfig(context.Background(), cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if prober != nil {
+		prober.start(pool)
+	}
+	return pool, prober, nil
+}
+
+// withConnSettings adds the key and value pairs of settings to a connection
+// string given either as a URL or as key=value pairs.
+func withConnSettings(connectionStr string, settings ...string) string {
+	isURL := strings.HasPrefix(connectionStr, "postgres://") || strings.HasPrefix(connectionStr, "postgresql://")
+	for i := 0; i+1 < len(settings); i += 2 {
+		switch {
+		case !isURL:
+			connectionStr += fmt.Sprintf(" %s=%s", settings[i], settings[i+1])
+		case strings.Contains(connectionStr, "?"):
+			connectionStr += "&" + settings[i] + "=" + url.QueryEscape(settings[i+1])
+		default:
+			connectionStr += "?" + settings[i] + "=" + url.QueryEscape(settings[i+1])
+		}
+	}
+	return connectionStr
+}
+
+type probeContextKey struct{}
+
+// connProber pings the idle connections of a pool, closing those failing,
+// so that the connections dropped while idle, such as by a firewall, fail
+// the probe rather than the next query.
+//
+// Probing a connection resets its idle time in the pool, so the prober
+// tracks when each connection was last acquired by a query, and closes
+// itself those idle for longer than the max idle time.
+type connProber struct {
+	name        string
+	interval    time.Duration
+	maxIdleTime time.Duration
+
+	mu       sync.Mutex
+	lastUsed map[*pgx.Conn]time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newConnProber(name string, interval, maxIdleTime time.Duration) *connProber {
+	return &connProber{
+		name:        name,
+		interval:    interval,
+		maxIdleTime: maxIdleTime,
+		lastUsed:    make(map[*pgx.Conn]time.Time),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// beforeAcquire records when conn is acquired by a query.
+func (p *connProber) beforeAcquire(ctx context.Context, conn *pgx.Conn) bool {
+	if ctx.Value(probeContextKey{}) == nil {
+		p.mu.Lock()
+		p.lastUsed[conn] = time.Now()
+		p.mu.Unlock()
+	}
+	return true
+}
+
+// idleSince returns when conn was last acquired by a query, or now for the
+// connections never acquired yet.
+func (p *connProber) idleSince(conn *pgx.Conn, now time.Time) time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t, ok := p.lastUsed[conn]
+	if !ok {
+		p.lastUsed[conn] = now
+		return now
+	}
+	return t
+}
+
+func (p *connProber) start(pool *pgxpool.Pool) {
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.probe(pool)
+			}
+		}
+	}()
+}
+
+// probe pings the idle connections of pool.
+func (p *connProber) probe(pool *pgxpool.Pool) {
+	ctx := context.WithValue(context.Background(), probeContextKey{}, true)
+	now := time.Now()
+	for _, c := range pool.AcquireAllIdle(ctx) {
+		conn := c.Conn()
+		if now.Sub(p.idleSince(conn, now)) > p.maxIdleTime {
+			connProbes.WithLabelValues(p.name, "idle_closed").Inc()
+			_ = conn.Close(ctx)
+			c.Release()
+			continue
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, connProbeTimeout)
+		err := conn.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			connProbes.WithLabelValues(p.name, "failed").Inc()
+			log.Warn("msg", "Closing a database connection failing its health probe", "pool", p.name, "err", err)
+			_ = conn.Close(ctx)
+		} else {
+			connProbes.WithLabelValues(p.name, "ok").Inc()
+		}
+		// The pool destroys the closed connections.
+		c.Release()
+	}
+
+	p.mu.Lock()
+	for conn := range p.lastUsed {
+		if conn.IsClosed() {
+			delete(p.lastUsed, conn)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// close stops probing. The prober may be nil.
+func (p *connProber) close() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+}
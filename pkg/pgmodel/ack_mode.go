@@ -0,0 +1,128 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// AckMode is when the writes are acknowledged.
+type AckMode string
+
+const (
+	// AckModeSync acknowledges a write once its COPY transactions commit,
+	// durably or not depending on the synchronous_commit setting of the
+	// database.
+	AckModeSync AckMode = "sync"
+	// AckModeFlush acknowledges a write once the commits of its batches are
+	// flushed to the WAL, whatever synchronous_commit. The batches committed
+	// meanwhile wait in a queue and are flushed together by a single durable
+	// commit, so that the COPY transactions can commit with
+	// synchronous_commit off without losing acknowledged samples on a crash.
+	AckModeFlush AckMode = "flush"
+	// AckModeAsync acknowledges a write before it is stored. The samples
+	// failing to be written, or in flight when the connector stops, are
+	// lost.
+	AckModeAsync AckMode = "async"
+)
+
+const (
+	inFlightAcked   = "true"
+	inFlightUnacked = "false"
+)
+
+// flushWALSQL commits a transaction with an id, whose commit record makes
+// the database flush the WAL up to it, with synchronous_commit on for this
+// transaction only.
+const flushWALSQL = "SELECT set_config('synchronous_commit', 'on', true), txid_current()"
+
+func (m AckMode) validate() error {
+	switch m {
+	case "", AckModeSync, AckModeFlush, AckModeAsync:
+		return nil
+	}
+	return fmt.Errorf("invalid ack mode %q: must be %s, %s or %s", m, AckModeSync, AckModeFlush, AckModeAsync)
+}
+
+// ackMode returns the ack mode of cfg, AsyncAcks selecting async acks.
+func (cfg *Cfg) ackMode() (AckMode, error) {
+	if err := cfg.AckMode.validate(); err != nil {
+		return "", err
+	}
+	switch {
+	case cfg.AsyncAcks && cfg.AckMode != "" && cfg.AckMode != AckModeAsync:
+		return "", fmt.Errorf("async acks conflict with ack mode %s", cfg.AckMode)
+	case cfg.AsyncAcks:
+		return AckModeAsync, nil
+	case cfg.AckMode == "":
+		return AckModeSync, nil
+	}
+	return cfg.AckMode, nil
+}
+
+// walFlusher reports the results of the batches copied in flush ack mode
+// once the WAL holding their commits is flushed.
+type walFlusher struct {
+	conn    pgxConn
+	retry   *retryPolicy
+	pending chan *pendingBuffer
+}
+
+func newWALFlusher(conn pgxConn, retry *retryPolicy, queueSize int) *walFlusher {
+	f := &walFlusher{
+		conn:    conn,
+		retry:   retry,
+		pending: make(chan *pendingBuffer, queueSize),
+	}
+	go f.run()
+	return f
+}
+
+// enqueue queues a batch whose COPY committed, to be reported once flushed.
+func (f *walFlusher) enqueue(pending *pendingBuffer) {
+	f.pending <- pending
+}
+
+// close stops the flusher once the queued batches are flushed. No batch may
+// be enqueued afterwards.
+func (f *walFlusher) close() {
+	close(f.pending)
+}
+
+func (f *walFlusher) run() {
+	for first := range f.pending {
+		batches := []*pendingBuffer{first}
+	drain:
+		for {
+			select {
+			case pending, ok := <-f.pending:
+				if !ok {
+					break drain
+				}
+				batches = append(batches, pending)
+			default:
+				break drain
+			}
+		}
+
+		start := time.Now()
+		err := f.retry.do("flush", func() error {
+			_, err := f.conn.Exec(context.Background(), flushWALSQL)
+			return err
+		})
+		if err == nil {
+			walFlushDuration.Observe(time.Since(start).Seconds())
+			walFlushBatches.Observe(float64(len(batches)))
+		} else {
+			err = fmt.Errorf("flushing the WAL: %w", err)
+		}
+		for _, pending := range batches {
+			pending.reportResults(err)
+			pendingBuffers.Put(pending)
+		}
+	}
+}
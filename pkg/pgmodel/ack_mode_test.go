@@ -0,0 +1,83 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAckMode(t *testing.T) {
+	testCases := []struct {
+		cfg     Cfg
+		mode    AckMode
+		invalid bool
+	}{
+		{cfg: Cfg{}, mode: AckModeSync},
+		{cfg: Cfg{AckMode: AckModeFlush}, mode: AckModeFlush},
+		{cfg: Cfg{AsyncAcks: true}, mode: AckModeAsync},
+		{cfg: Cfg{AsyncAcks: true, AckMode: AckModeAsync}, mode: AckModeAsync},
+		{cfg: Cfg{AsyncAcks: true, AckMode: AckModeFlush}, invalid: true},
+		{cfg: Cfg{AckMode: "later"}, invalid: true},
+	}
+	for _, c := range testCases {
+		mode, err := c.cfg.ackMode()
+		if c.invalid {
+			if err == nil {
+				t.Errorf("%+v: expected an error", c.cfg)
+			}
+			continue
+		}
+		if err != nil || mode != c.mode {
+			t.Errorf("%+v: unexpected ack mode %q, %v", c.cfg, mode, err)
+		}
+	}
+}
+
+func TestFlushAcks(t *testing.T) {
+	rows := make(map[string][]samplesInfo)
+	tables := make(map[string]string)
+	for i := 0; i < 3; i++ {
+		metric := fmt.Sprintf("metric_%d", i)
+		rows[metric] = []samplesInfo{{}}
+		tables[metric] = "table_" + metric
+	}
+
+	mock := &mockPGXConn{}
+	inserter, err := newPgxInserter(mock, &mockMetricCache{metricCache: tables}, &Cfg{AckMode: AckModeFlush})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inserter.Close()
+	unackedBefore := testutil.ToFloat64(inFlightSamples.WithLabelValues(inFlightUnacked))
+
+	if _, err = inserter.InsertNewData(context.Background(), rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.CopyFromTableName) != len(rows) {
+		t.Errorf("unexpected number of copies: %d", len(mock.CopyFromTableName))
+	}
+	flushes := 0
+	for _, sql := range mock.ExecSQLs {
+		if sql == flushWALSQL {
+			flushes++
+		}
+	}
+	if flushes == 0 || flushes > len(rows) {
+		t.Errorf("unexpected number of WAL flushes: %d", flushes)
+	}
+	if n := testutil.ToFloat64(inFlightSamples.WithLabelValues(inFlightUnacked)); n != unackedBefore {
+		t.Errorf("unexpected unacked samples: got %v wanted %v", n, unackedBefore)
+	}
+
+	// A write whose WAL cannot be flushed fails, though copied.
+	mock.ExecErr = fmt.Errorf("connection lost")
+	if _, err = inserter.InsertNewData(context.Background(), rows); err == nil {
+		t.Error("expected the write to fail without a WAL flush")
+	}
+}
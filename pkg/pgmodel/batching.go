@@ -0,0 +1,151 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultBatchMaxSeries is the default number of series a batch holds
+	// at most. Larger requests are split between several batches, which
+	// bounds the memory of every insert routine.
+	DefaultBatchMaxSeries = 2000
+	// DefaultBatchMinSeries is the default number of series adaptive
+	// batching does not shrink the batches under.
+	DefaultBatchMinSeries = 50
+
+	// copyRowBytes is the size of a sample in the binary COPY format: the
+	// field count, then the length and value of the time, value and series
+	// id.
+	copyRowBytes = 2 + 3*(4+8)
+)
+
+// BatchConfig decides how many series and samples the insert routines of
+// the metrics gather before copying them.
+type BatchConfig struct {
+	// MaxSeries is the number of series of a batch. 0 selects
+	// DefaultBatchMaxSeries.
+	MaxSeries int
+	// MaxBytes bounds the size of the samples of a batch in the COPY
+	// format. 0 means no bound but MaxSeries.
+	MaxBytes int64
+	// Timeout is how long a batch waits for more requests once none is
+	// queued, unless full. 0 copies it as soon as no request is queued.
+	Timeout time.Duration
+	// TargetLatency enables adaptive batching: the number of series of a
+	// batch is shrunk while the time from its first request to the end of
+	// its COPY is over TargetLatency, and grown back up to MaxSeries while
+	// it is under half of it.
+	TargetLatency time.Duration
+	// MinSeries is the number of series adaptive batching does not shrink
+	// the batches under. 0 selects DefaultBatchMinSeries.
+	MinSeries int
+}
+
+func (cfg BatchConfig) validate() error {
+	if cfg.MaxSeries < 0 || cfg.MinSeries < 0 || cfg.MaxBytes < 0 || cfg.Timeout < 0 || cfg.TargetLatency < 0 {
+		return fmt.Errorf("invalid batch config: negative setting in %+v", cfg)
+	}
+	if cfg.MaxBytes > 0 && cfg.MaxBytes < copyRowBytes {
+		return fmt.Errorf("invalid batch config: max bytes %d under the size of a sample, %d", cfg.MaxBytes, copyRowBytes)
+	}
+	if cfg.TargetLatency > 0 && cfg.MaxSeries > 0 && cfg.MinSeries > cfg.MaxSeries {
+		return fmt.Errorf("invalid batch config: min series %d over max series %d", cfg.MinSeries, cfg.MaxSeries)
+	}
+	return nil
+}
+
+// batchSizer tells the insert routines when their batches are full, and
+// adapts their number of series to the observed latency with adaptive
+// batching. A nil batchSizer uses the defaults.
+type batchSizer struct {
+	cfg BatchConfig
+	// maxSeries is the current number of series of a batch.
+	maxSeries int64
+	// maxSamples is the number of samples of a batch, 0 if unbounded.
+	maxSamples int
+	mu         sync.Mutex
+}
+
+func newBatchSizer(cfg BatchConfig) (*batchSizer, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.MaxSeries == 0 {
+		cfg.MaxSeries = DefaultBatchMaxSeries
+	}
+	if cfg.MinSeries == 0 {
+		cfg.MinSeries = DefaultBatchMinSeries
+	}
+	if cfg.MinSeries > cfg.MaxSeries {
+		cfg.MinSeries = cfg.MaxSeries
+	}
+	s := &batchSizer{
+		cfg:        cfg,
+		maxSeries:  int64(cfg.MaxSeries),
+		maxSamples: int(cfg.MaxBytes / copyRowBytes),
+	}
+	batchMaxSeries.Set(float64(cfg.MaxSeries))
+	return s, nil
+}
+
+// limits returns the number of series and samples of a batch, the latter 0
+// if unbounded.
+func (s *batchSizer) limits() (int, int) {
+	if s == nil {
+		return DefaultBatchMaxSeries, 0
+	}
+	return int(atomic.LoadInt64(&s.maxSeries)), s.maxSamples
+}
+
+// full tells whether the batch of p is full.
+func (s *batchSizer) full(p *pendingBuffer) bool {
+	maxSeries, maxSamples := s.limits()
+	return len(p.batch.sampleInfos) >= maxSeries || (maxSamples > 0 && p.samples >= maxSamples)
+}
+
+// timeout returns how long a batch waits for more requests.
+func (s *batchSizer) timeout() time.Duration {
+	if s == nil {
+		return 0
+	}
+	return s.cfg.Timeout
+}
+
+// observe records the latency of a batch of series series, from its first
+// request to the end of its COPY, adapting the size of the next batches.
+func (s *batchSizer) observe(latency time.Duration, series int) {
+	batchLatency.Observe(latency.Seconds())
+	if s == nil || s.cfg.TargetLatency <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := atomic.LoadInt64(&s.maxSeries)
+	next := current
+	switch {
+	case latency > s.cfg.TargetLatency:
+		// Shrink multiplicatively, to get back under the target quickly.
+		next = current * 3 / 4
+		if next < int64(s.cfg.MinSeries) {
+			next = int64(s.cfg.MinSeries)
+		}
+	case latency < s.cfg.TargetLatency/2 && int64(series) >= current:
+		// Grow additively while the batches are full, since growing
+		// batches which are not changes nothing.
+		next = current + current/8 + 1
+		if next > int64(s.cfg.MaxSeries) {
+			next = int64(s.cfg.MaxSeries)
+		}
+	}
+	if next != current {
+		atomic.StoreInt64(&s.maxSeries, next)
+		batchMaxSeries.Set(float64(next))
+	}
+}
@@ -0,0 +1,156 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func batchRequest(series, samples int) insertDataRequest {
+	data := make([]samplesInfo, series)
+	for i := range data {
+		data[i] = samplesInfo{seriesID: SeriesID(i), samples: make([]prompb.Sample, samples)}
+	}
+	finished := &sync.WaitGroup{}
+	finished.Add(1)
+	return insertDataRequest{data: data, finished: finished, errChan: make(chan error, 1)}
+}
+
+func TestBatchSizerAdapts(t *testing.T) {
+	s, err := newBatchSizer(BatchConfig{MaxSeries: 1000, MinSeries: 100, TargetLatency: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxSeries := func() int {
+		n, _ := s.limits()
+		return n
+	}
+
+	for i := 0; i < 20; i++ {
+		s.observe(time.Second, maxSeries())
+	}
+	if n := maxSeries(); n != 100 {
+		t.Errorf("batches not shrunk to the minimum: %d series", n)
+	}
+
+	// Batches which are not full do not grow.
+	s.observe(time.Millisecond, 10)
+	if n := maxSeries(); n != 100 {
+		t.Errorf("batches grown while not full: %d series", n)
+	}
+	s.observe(80*time.Millisecond, maxSeries())
+	if n := maxSeries(); n != 100 {
+		t.Errorf("batches resized within the target: %d series", n)
+	}
+
+	for i := 0; i < 50; i++ {
+		s.observe(time.Millisecond, maxSeries())
+	}
+	if n := maxSeries(); n != 1000 {
+		t.Errorf("batches not grown back to the maximum: %d series", n)
+	}
+
+	invalid := []BatchConfig{
+		{MaxSeries: -1},
+		{MaxBytes: 10},
+		{MaxSeries: 10, MinSeries: 20, TargetLatency: time.Second},
+	}
+	for _, cfg := range invalid {
+		if _, err := newBatchSizer(cfg); err == nil {
+			t.Errorf("%+v: expected an error", cfg)
+		}
+	}
+}
+
+func TestInsertHandlerMaxBytes(t *testing.T) {
+	batches, err := newBatchSizer(BatchConfig{MaxBytes: 25 * copyRowBytes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	toCopiers := make(chan copyRequest, 10)
+	handler := insertHandler{
+		conn:        &mockPGXConn{},
+		pending:     pendingBuffers.Get().(*pendingBuffer),
+		seriesCache: NewSeriesCache(0),
+		toCopiers:   toCopiers,
+		retry:       newRetryPolicy(RetryConfig{}),
+		batches:     batches,
+	}
+
+	// 100 samples in series of 10 make four batches of 20 samples and one
+	// of the remaining 20.
+	handler.handleReq(batchRequest(10, 10))
+	handler.flush()
+	close(toCopiers)
+	batchesCopied := 0
+	for req := range toCopiers {
+		batchesCopied++
+		if n := req.data.batch.numSamples(); n != 20 {
+			t.Errorf("unexpected batch of %d samples", n)
+		}
+		req.data.reportResults(nil)
+	}
+	if batchesCopied != 5 {
+		t.Errorf("unexpected number of batches: %d", batchesCopied)
+	}
+
+	// A series larger than the limit gets a batch of its own.
+	if rest := handler.pending.addReq(batchRequest(2, 30), batches); len(rest) != 1 || handler.pending.samples != 30 {
+		t.Errorf("unexpected batch of %d samples, %d series left", handler.pending.samples, len(rest))
+	}
+}
+
+func TestInsertHandlerBatchTimeout(t *testing.T) {
+	batches, err := newBatchSizer(BatchConfig{MaxSeries: 20, Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := make(chan insertDataRequest)
+	toCopiers := make(chan copyRequest, 1)
+	handler := insertHandler{
+		conn:        &mockPGXConn{},
+		input:       input,
+		pending:     pendingBuffers.Get().(*pendingBuffer),
+		seriesCache: NewSeriesCache(0),
+		toCopiers:   toCopiers,
+		retry:       newRetryPolicy(RetryConfig{}),
+		batches:     batches,
+	}
+
+	handler.handleReq(batchRequest(5, 1))
+	go func() {
+		input <- batchRequest(10, 1)
+		input <- batchRequest(5, 1)
+	}()
+	start := time.Now()
+	if !handler.waitForReqs() {
+		t.Fatal("input reported closed")
+	}
+	if time.Since(start) >= time.Second || len(toCopiers) != 1 {
+		t.Fatal("full batch not flushed before the timeout")
+	}
+	if req := <-toCopiers; len(req.data.batch.sampleInfos) != 20 {
+		t.Errorf("unexpected batch of %d series", len(req.data.batch.sampleInfos))
+	}
+
+	handler.batches.cfg.Timeout = 20 * time.Millisecond
+	handler.handleReq(batchRequest(5, 1))
+	if !handler.waitForReqs() {
+		t.Fatal("input reported closed")
+	}
+	if n := len(handler.pending.batch.sampleInfos); n != 5 {
+		t.Errorf("unexpected batch of %d series after the timeout", n)
+	}
+
+	handler.batches.cfg.Timeout = time.Second
+	close(input)
+	if handler.waitForReqs() {
+		t.Error("closed input not reported")
+	}
+}
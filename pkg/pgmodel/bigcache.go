@@ -5,9 +5,10 @@
 package pgmodel
 
 import (
-	"encoding/binary"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/allegro/bigcache"
@@ -16,6 +17,23 @@ import (
 
 const (
 	defaultEvictionDuration = 10 * time.Minute
+
+	// DefaultMetricCacheTTL is the default time the metric table names are
+	// cached for.
+	DefaultMetricCacheTTL = defaultEvictionDuration
+	// DefaultMetricCacheShards is the default number of shards of the metric
+	// cache.
+	DefaultMetricCacheShards = 1024
+	// maxMetricCacheCleanWindow bounds the interval between the removals of
+	// the expired table names.
+	maxMetricCacheCleanWindow = time.Minute
+
+	// DefaultMissingMetricTTL is the default time the reads remember that a
+	// metric has no table.
+	DefaultMissingMetricTTL = 30 * time.Second
+	// maxMissingMetrics bounds the number of metrics remembered as missing,
+	// since they are named by the callers.
+	maxMissingMetrics = 10000
 )
 
 var (
@@ -23,41 +41,112 @@ var (
 	ErrEntryNotFound = fmt.Errorf("entry not found")
 )
 
-type bCache struct {
-	series *bigcache.BigCache
+// MetricCacheConfig sizes the cache of the metric table names.
+type MetricCacheConfig struct {
+	// MaxSizeMB is the maximum size of the cache in megabytes, 0 meaning
+	// unlimited. The oldest table names are evicted past it.
+	MaxSizeMB int
+	// Shards is the number of shards of the cache, a power of two. More
+	// shards make the concurrent lookups contend less.
+	Shards int
+	// TTL is how long the table names are cached for.
+	TTL time.Duration
+}
+
+// DefaultMetricCacheConfig returns the default size of the metric cache.
+func DefaultMetricCacheConfig() MetricCacheConfig {
+	return MetricCacheConfig{Shards: DefaultMetricCacheShards, TTL: DefaultMetricCacheTTL}
+}
+
+// MetricNameCache stores and retrieves metric table names in a in-memory cache,
+// or in a cache shared between the connectors.
+type MetricNameCache struct {
+	Metrics CacheBackend
+	// MissingTTL is how long the metrics without a table are remembered as
+	// missing, so that the reads of a metric not written yet do not look up
+	// its table every time. 0 disables it.
+	MissingTTL time.Duration
+
+	missingLock sync.Mutex
+	missing     map[string]time.Time
+
+	// size reports the number of entries of the in-memory cache created by
+	// NewMetricNameCache, nil otherwise.
+	size *metricCacheSize
 }
 
-func (b *bCache) GetSeries(lset Labels) (SeriesID, error) {
-	result, err := b.series.Get(lset.String())
+// NewMetricNameCache returns an in-memory metric cache sized by cfg,
+// reporting its evictions and its number of entries.
+func NewMetricNameCache(cfg MetricCacheConfig) (*MetricNameCache, error) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultMetricCacheTTL
+	}
+	if cfg.Shards <= 0 {
+		cfg.Shards = DefaultMetricCacheShards
+	}
+	size := &metricCacheSize{}
+	config := DefaultCacheConfig()
+	config.Shards = cfg.Shards
+	config.LifeWindow = cfg.TTL
+	// bigcache only drops the expired entries when cleaning up, or when
+	// the oldest one is overwritten.
+	config.CleanWindow = cfg.TTL
+	if config.CleanWindow > maxMetricCacheCleanWindow {
+		config.CleanWindow = maxMetricCacheCleanWindow
+	}
+	if config.CleanWindow < time.Second {
+		config.CleanWindow = time.Second
+	}
+	config.HardMaxCacheSize = cfg.MaxSizeMB
+	config.OnRemoveWithReason = size.removed
+	metrics, err := bigcache.NewBigCache(config)
 	if err != nil {
-		if err == bigcache.ErrEntryNotFound {
-			return 0, ErrEntryNotFound
-		}
-		return 0, err
+		return nil, fmt.Errorf("creating the metric cache: %w", err)
 	}
-	return SeriesID(binary.LittleEndian.Uint64(result)), nil
+	size.cache = metrics
+	return &MetricNameCache{Metrics: metrics, size: size}, nil
 }
 
-func (b *bCache) SetSeries(lset Labels, id SeriesID) error {
-	byteID := make([]byte, 8)
-	binary.LittleEndian.PutUint64(byteID, uint64(id))
-	return b.series.Set(lset.String(), byteID)
+// metricCacheSize keeps the metricCacheEntries gauge in line with the
+// entries of a cache.
+type metricCacheSize struct {
+	cache    *bigcache.BigCache
+	reported int64
 }
 
-// MetricNameCache stores and retrieves metric table names in a in-memory cache.
-type MetricNameCache struct {
-	Metrics *bigcache.BigCache
+// update reports the number of entries after they were added or removed.
+func (s *metricCacheSize) update() {
+	if s == nil {
+		return
+	}
+	n := int64(s.cache.Len())
+	metricCacheEntries.Add(float64(n - atomic.SwapInt64(&s.reported, n)))
+}
+
+// removed is called by bigcache with the lock of the shard of the entry held,
+// so it must not call update.
+func (s *metricCacheSize) removed(_ string, _ []byte, reason bigcache.RemoveReason) {
+	switch reason {
+	case bigcache.Expired:
+		metricCacheEvictions.WithLabelValues("expired").Inc()
+	case bigcache.NoSpace:
+		metricCacheEvictions.WithLabelValues("no_space").Inc()
+	}
+	atomic.AddInt64(&s.reported, -1)
+	metricCacheEntries.Dec()
 }
 
 // Get fetches the table name for specified metric.
 func (m *MetricNameCache) Get(metric string) (string, error) {
 	result, err := m.Metrics.Get(metric)
 	if err != nil {
-		if err == bigcache.ErrEntryNotFound {
+		if isEntryNotFound(err) {
+			metricCacheMisses.Inc()
 			return "", ErrEntryNotFound
 		}
 		return "", err
 	}
+	metricCacheHits.Inc()
 	return string(result), nil
 }
 
@@ -69,7 +158,75 @@ func (m *MetricNameCache) Set(metric string, tableName string) error {
 	metricBuilder.WriteString(metric)
 	table := make([]byte, len(tableName))
 	copy(table, tableName)
-	return m.Metrics.Set(metricBuilder.String(), table)
+	m.clearMissing(metric)
+	err := m.Metrics.Set(metricBuilder.String(), table)
+	m.size.update()
+	return err
+}
+
+// IsMissing reports whether the metric was found without a table less than
+// MissingTTL ago.
+func (m *MetricNameCache) IsMissing(metric string) bool {
+	m.missingLock.Lock()
+	defer m.missingLock.Unlock()
+	expiry, ok := m.missing[metric]
+	if ok && time.Now().After(expiry) {
+		delete(m.missing, metric)
+		return false
+	}
+	return ok
+}
+
+// SetMissing remembers that the metric has no table for MissingTTL. The
+// metrics past maxMissingMetrics are not remembered.
+func (m *MetricNameCache) SetMissing(metric string) {
+	if m.MissingTTL <= 0 {
+		return
+	}
+	m.missingLock.Lock()
+	defer m.missingLock.Unlock()
+	now := time.Now()
+	if m.missing == nil {
+		m.missing = make(map[string]time.Time)
+	}
+	if len(m.missing) >= maxMissingMetrics {
+		for name, expiry := range m.missing {
+			if now.After(expiry) {
+				delete(m.missing, name)
+			}
+		}
+		if len(m.missing) >= maxMissingMetrics {
+			return
+		}
+	}
+	m.missing[metric] = now.Add(m.MissingTTL)
+}
+
+func (m *MetricNameCache) clearMissing(metric string) {
+	m.missingLock.Lock()
+	defer m.missingLock.Unlock()
+	delete(m.missing, metric)
+}
+
+// Delete removes the table name of the metric.
+func (m *MetricNameCache) Delete(metric string) error {
+	m.clearMissing(metric)
+	err := m.Metrics.Delete(metric)
+	m.size.update()
+	if isEntryNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Reset removes all the table names.
+func (m *MetricNameCache) Reset() error {
+	m.missingLock.Lock()
+	m.missing = nil
+	m.missingLock.Unlock()
+	err := m.Metrics.Reset()
+	m.size.update()
+	return err
 }
 
 func DefaultCacheConfig() bigcache.Config {
@@ -10,92 +10,10 @@ import (
 	"time"
 
 	"github.com/allegro/bigcache"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/prometheus/pkg/labels"
 )
 
-func TestBigCache(t *testing.T) {
-	config := bigcache.DefaultConfig(10 * time.Minute)
-	series, err := bigcache.NewBigCache(config)
-	if err != nil {
-		t.Fatal("unable to run test, unable to create labels cache")
-	}
-	cache := bCache{
-		series: series,
-	}
-
-	label := labels.Labels{
-		labels.Label{
-			Name:  "name1",
-			Value: "val1",
-		},
-	}
-
-	l, err := LabelsFromSlice(label)
-	if err != nil {
-		t.Errorf("invalid labels %+v: %v", l, err)
-	}
-	if _, err := cache.GetSeries(*l); err == nil {
-		t.Errorf("found cache for a series that was not stored")
-	}
-
-	testLabels := []labels.Labels{
-		{
-			labels.Label{
-				Name:  "name1",
-				Value: "val1",
-			},
-		},
-		{
-			labels.Label{
-				Name:  "name1",
-				Value: "val2",
-			},
-		},
-		{
-			labels.Label{
-				Name:  "name2",
-				Value: "val2",
-			},
-		},
-		{
-			labels.Label{
-				Name:  "name1",
-				Value: "val1",
-			},
-			labels.Label{
-				Name:  "name2",
-				Value: "val2",
-			},
-		},
-	}
-
-	for i, series := range testLabels {
-		ls, err := LabelsFromSlice(series)
-		if err != nil {
-			t.Errorf("invalid series %+v, %v", ls, err)
-		}
-		if err := cache.SetSeries(*ls, SeriesID(i)); err != nil {
-			t.Errorf("got unexpected error while storing series: %d", i)
-
-		}
-	}
-
-	for i, series := range testLabels {
-		var res SeriesID
-		ls, err := LabelsFromSlice(series)
-		if err != nil {
-			t.Errorf("invalid series %+v, %v", ls, err)
-		}
-		if res, err = cache.GetSeries(*ls); err != nil {
-			t.Errorf("got unexpected error while getting series: %v", series)
-		}
-		if res != SeriesID(i) {
-			t.Errorf("wrong id returned: got %v expected %v", res, i)
-		}
-	}
-
-}
-
 func TestBigLables(t *testing.T) {
 	builder := strings.Builder{}
 	builder.Grow(int(^uint16(0)) + 1) // one greater than uint16 max
@@ -186,3 +104,80 @@ func TestMetricTableNameCache(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricNameCacheMissing(t *testing.T) {
+	metrics, err := bigcache.NewBigCache(DefaultCacheConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := &MetricNameCache{Metrics: metrics}
+	cache.SetMissing("foo")
+	if cache.IsMissing("foo") {
+		t.Error("missing metric remembered without a TTL")
+	}
+
+	cache.MissingTTL = time.Minute
+	cache.SetMissing("foo")
+	cache.SetMissing("bar")
+	if !cache.IsMissing("foo") || !cache.IsMissing("bar") || cache.IsMissing("baz") {
+		t.Error("unexpected missing metrics")
+	}
+	if err = cache.Set("foo", "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err = cache.Delete("bar"); err != nil {
+		t.Fatal(err)
+	}
+	if cache.IsMissing("foo") || cache.IsMissing("bar") {
+		t.Error("missing metrics not cleared by Set and Delete")
+	}
+
+	cache.missing["expired"] = time.Now().Add(-time.Second)
+	if cache.IsMissing("expired") {
+		t.Error("expired missing metric")
+	}
+}
+
+func TestNewMetricNameCache(t *testing.T) {
+	if _, err := NewMetricNameCache(MetricCacheConfig{Shards: 3}); err == nil {
+		t.Error("expected an error for a number of shards not a power of two")
+	}
+
+	cache, err := NewMetricNameCache(MetricCacheConfig{Shards: 16, TTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := testutil.ToFloat64(metricCacheEntries)
+	hits := testutil.ToFloat64(metricCacheHits)
+	misses := testutil.ToFloat64(metricCacheMisses)
+	for _, metric := range []string{"foo", "bar", "foo"} {
+		if err = cache.Set(metric, metric+"_table"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := testutil.ToFloat64(metricCacheEntries) - entries; n != 2 {
+		t.Errorf("unexpected number of entries reported: %v", n)
+	}
+	if _, err = cache.Get("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cache.Get("baz"); err != ErrEntryNotFound {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if testutil.ToFloat64(metricCacheHits) != hits+1 || testutil.ToFloat64(metricCacheMisses) != misses+1 {
+		t.Error("unexpected hits and misses reported")
+	}
+
+	if err = cache.Delete("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if n := testutil.ToFloat64(metricCacheEntries) - entries; n != 1 {
+		t.Errorf("unexpected number of entries reported after the delete: %v", n)
+	}
+	if err = cache.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if n := testutil.ToFloat64(metricCacheEntries) - entries; n != 0 {
+		t.Errorf("unexpected number of entries reported after the reset: %v", n)
+	}
+}
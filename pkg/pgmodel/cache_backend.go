@@ -0,0 +1,399 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/allegro/bigcache"
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+const (
+	// DefaultSharedCacheTTL is the default time the entries of the shared
+	// caches are kept.
+	DefaultSharedCacheTTL = defaultEvictionDuration
+	// DefaultSharedCachePrefix is the default prefix of the keys of the
+	// shared caches.
+	DefaultSharedCachePrefix = "ts_prom:"
+
+	sharedCacheTimeout = time.Second
+	// sharedCacheMaxIdleConns is the number of idle connections kept open to
+	// the shared cache server.
+	sharedCacheMaxIdleConns = 16
+	// generationRefresh is how often the generations of the shared entries
+	// are read again, so that the connectors converge on the latest one.
+	generationRefresh = time.Minute
+
+	metricGeneration = "metric"
+	seriesGeneration = "series"
+)
+
+// CacheBackend stores the entries of the metric cache. It is implemented by
+// *bigcache.BigCache in memory, and by SharedCache for the caches shared
+// between the connectors. Get returns ErrEntryNotFound or
+// bigcache.ErrEntryNotFound for the missing entries.
+type CacheBackend interface {
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte) error
+	Delete(key string) error
+	Reset() error
+}
+
+func isEntryNotFound(err error) bool {
+	return err == ErrEntryNotFound || err == bigcache.ErrEntryNotFound
+}
+
+// TieredCache looks up the entries in a local cache before a shared one,
+// keeping the entries found in the shared cache in the local one.
+type TieredCache struct {
+	local, shared CacheBackend
+}
+
+// NewTieredCache returns a TieredCache in front of shared.
+func NewTieredCache(local, shared CacheBackend) *TieredCache {
+	return &TieredCache{local: local, shared: shared}
+}
+
+func (c *TieredCache) Get(key string) ([]byte, error) {
+	value, err := c.local.Get(key)
+	if !isEntryNotFound(err) {
+		return value, err
+	}
+	value, err = c.shared.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return value, c.local.Set(key, value)
+}
+
+func (c *TieredCache) Set(key string, value []byte) error {
+	if err := c.local.Set(key, value); err != nil {
+		return err
+	}
+	return c.shared.Set(key, value)
+}
+
+func (c *TieredCache) Delete(key string) error {
+	if err := c.local.Delete(key); err != nil && !isEntryNotFound(err) {
+		return err
+	}
+	return c.shared.Delete(key)
+}
+
+func (c *TieredCache) Reset() error {
+	if err := c.local.Reset(); err != nil {
+		return err
+	}
+	return c.shared.Reset()
+}
+
+// sharedCacheClient is the protocol of a shared cache server.
+type sharedCacheClient interface {
+	// get returns the value of key, reporting whether it exists.
+	get(key string) ([]byte, bool, error)
+	// set stores the value of key for ttl, forever if ttl is 0.
+	set(key string, value []byte, ttl time.Duration) error
+	delete(key string) error
+	// incr increments the integer value of key, created at 0 if missing,
+	// and returns it.
+	incr(key string) (int64, error)
+	close()
+}
+
+// SharedCache stores the entries of the metric and series caches in a Redis
+// or memcached server, so that the connectors share them instead of each
+// warming its own.
+//
+// The entries are kept for the TTL and namespaced by a generation,
+// incremented to invalidate them all at once: the generation of the metric
+// cache, and for the series, the generation of all the series and that of
+// their metric. The keys are hashed so that any label set makes a valid key.
+//
+// The cache fails open: its errors are counted and logged, and the entries
+// are then looked up in the database.
+type SharedCache struct {
+	client sharedCacheClient
+	prefix string
+	ttl    time.Duration
+
+	lock        sync.Mutex
+	generations map[string]cachedGeneration
+}
+
+type cachedGeneration struct {
+	value int64
+	read  time.Time
+}
+
+// NewSharedCache returns the shared cache of the server at rawURL, such as
+// redis://:password@host:6379/0 or memcached://host:11211.
+func NewSharedCache(rawURL, prefix string, ttl time.Duration) (*SharedCache, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shared cache URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid shared cache URL %q: missing host", rawURL)
+	}
+	var client sharedCacheClient
+	switch u.Scheme {
+	case "redis":
+		c := &redisClient{conns: newCacheConnPool(u.Host)}
+		if u.User != nil {
+			c.password, _ = u.User.Password()
+		}
+		if db := u.Path; len(db) > 1 {
+			if c.db, err = strconv.Atoi(db[1:]); err != nil {
+				return nil, fmt.Errorf("invalid Redis database %q", db[1:])
+			}
+		}
+		client = c
+	case "memcached":
+		client = &memcachedClient{conns: newCacheConnPool(u.Host)}
+	default:
+		return nil, fmt.Errorf("unsupported shared cache %q, expected redis or memcached", u.Scheme)
+	}
+	return &SharedCache{client: client, prefix: prefix, ttl: ttl, generations: make(map[string]cachedGeneration)}, nil
+}
+
+// Close closes the connections to the server.
+func (c *SharedCache) Close() {
+	c.client.close()
+}
+
+func (c *SharedCache) fail(op string, err error) {
+	sharedCacheErrors.WithLabelValues(op).Inc()
+	log.Debug("msg", "Shared cache request failed", "op", op, "err", err)
+}
+
+func (c *SharedCache) hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *SharedCache) generationKey(name string) string {
+	return c.prefix + "gen:" + c.hash(name)
+}
+
+// generation returns the generation name, read from the server at most every
+// generationRefresh.
+func (c *SharedCache) generation(name string) (int64, error) {
+	c.lock.Lock()
+	g, ok := c.generations[name]
+	c.lock.Unlock()
+	if ok && time.Since(g.read) < generationRefresh {
+		return g.value, nil
+	}
+
+	var value int64
+	data, found, err := c.client.get(c.generationKey(name))
+	if err != nil {
+		return 0, err
+	}
+	if found {
+		if value, err = strconv.ParseInt(string(data), 10, 64); err != nil {
+			return 0, fmt.Errorf("invalid generation %q: %w", data, err)
+		}
+	}
+	c.lock.Lock()
+	c.generations[name] = cachedGeneration{value: value, read: time.Now()}
+	c.lock.Unlock()
+	return value, nil
+}
+
+// bumpGeneration invalidates the entries of the generation name.
+func (c *SharedCache) bumpGeneration(name string) {
+	value, err := c.client.incr(c.generationKey(name))
+	if err != nil {
+		c.fail("incr", err)
+		// Read it again next time, since another connector may have
+		// incremented it.
+		c.lock.Lock()
+		delete(c.generations, name)
+		c.lock.Unlock()
+		return
+	}
+	c.lock.Lock()
+	c.generations[name] = cachedGeneration{value: value, read: time.Now()}
+	c.lock.Unlock()
+}
+
+func (c *SharedCache) metricKey(metric string) (string, error) {
+	gen, err := c.generation(metricGeneration)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%smetric:%d:%s", c.prefix, gen, c.hash(metric)), nil
+}
+
+// Get returns the table name of the metric.
+func (c *SharedCache) Get(metric string) ([]byte, error) {
+	key, err := c.metricKey(metric)
+	if err != nil {
+		c.fail("get", err)
+		return nil, ErrEntryNotFound
+	}
+	value, found, err := c.client.get(key)
+	switch {
+	case err != nil:
+		c.fail("get", err)
+		return nil, ErrEntryNotFound
+	case !found:
+		return nil, ErrEntryNotFound
+	}
+	return value, nil
+}
+
+// Set stores the table name of the metric.
+func (c *SharedCache) Set(metric string, table []byte) error {
+	key, err := c.metricKey(metric)
+	if err == nil {
+		err = c.client.set(key, table, c.ttl)
+	}
+	if err != nil {
+		c.fail("set", err)
+	}
+	return nil
+}
+
+// Delete removes the table name of the metric.
+func (c *SharedCache) Delete(metric string) error {
+	key, err := c.metricKey(metric)
+	if err == nil {
+		err = c.client.delete(key)
+	}
+	if err != nil {
+		c.fail("delete", err)
+	}
+	return nil
+}
+
+// Reset invalidates the table names of all the metrics.
+func (c *SharedCache) Reset() error {
+	c.bumpGeneration(metricGeneration)
+	return nil
+}
+
+func (c *SharedCache) seriesKey(lset Labels) (string, error) {
+	all, err := c.generation(seriesGeneration)
+	if err != nil {
+		return "", err
+	}
+	metric, err := c.generation(seriesGeneration + ":" + lset.metricName)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%sseries:%d.%d:%s", c.prefix, all, metric, c.hash(lset.str)), nil
+}
+
+// getSeries returns the id of the series, reporting whether it was found.
+func (c *SharedCache) getSeries(lset Labels) (SeriesID, bool) {
+	key, err := c.seriesKey(lset)
+	if err != nil {
+		c.fail("get", err)
+		return 0, false
+	}
+	value, found, err := c.client.get(key)
+	if err != nil {
+		c.fail("get", err)
+		return 0, false
+	}
+	if !found {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		c.fail("get", fmt.Errorf("invalid series id %q", value))
+		return 0, false
+	}
+	return SeriesID(id), true
+}
+
+func (c *SharedCache) setSeries(lset Labels, id SeriesID) {
+	key, err := c.seriesKey(lset)
+	if err == nil {
+		err = c.client.set(key, []byte(strconv.FormatInt(int64(id), 10)), c.ttl)
+	}
+	if err != nil {
+		c.fail("set", err)
+	}
+}
+
+// evictMetricSeries invalidates the series of metric, or all of them if
+// metric is empty.
+func (c *SharedCache) evictMetricSeries(metric string) {
+	if metric == "" {
+		c.bumpGeneration(seriesGeneration)
+		return
+	}
+	c.bumpGeneration(seriesGeneration + ":" + metric)
+}
+
+// cacheConnPool keeps the idle connections to a cache server.
+type cacheConnPool struct {
+	addr string
+	idle chan *cacheConn
+}
+
+type cacheConn struct {
+	net.Conn
+	rw *bufio.ReadWriter
+	// ready is set once the connection is authenticated, for the protocols
+	// requiring it.
+	ready bool
+}
+
+func newCacheConnPool(addr string) *cacheConnPool {
+	return &cacheConnPool{addr: addr, idle: make(chan *cacheConn, sharedCacheMaxIdleConns)}
+}
+
+// do runs f on an idle connection, or a new one. The connection is closed if
+// f fails, since its state is then unknown.
+func (p *cacheConnPool) do(f func(c *cacheConn) error) error {
+	var c *cacheConn
+	select {
+	case c = <-p.idle:
+	default:
+		conn, err := net.DialTimeout("tcp", p.addr, sharedCacheTimeout)
+		if err != nil {
+			return err
+		}
+		c = &cacheConn{Conn: conn, rw: bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))}
+	}
+	if err := c.SetDeadline(time.Now().Add(sharedCacheTimeout)); err != nil {
+		c.Close()
+		return err
+	}
+	if err := f(c); err != nil {
+		c.Close()
+		return err
+	}
+	select {
+	case p.idle <- c:
+	default:
+		c.Close()
+	}
+	return nil
+}
+
+func (p *cacheConnPool) close() {
+	for {
+		select {
+		case c := <-p.idle:
+			c.Close()
+		default:
+			return
+		}
+	}
+}
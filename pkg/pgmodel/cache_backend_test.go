@@ -0,0 +1,349 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/allegro/bigcache"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+// fakeCacheServer is an in-memory Redis or memcached server, ignoring the
+// TTLs.
+type fakeCacheServer struct {
+	listener net.Listener
+	password string
+
+	lock   sync.Mutex
+	values map[string]string
+}
+
+func newFakeCacheServer(t *testing.T, memcached bool, password string) *fakeCacheServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeCacheServer{listener: l, password: password, values: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			if memcached {
+				go s.serveMemcached(conn)
+			} else {
+				go s.serveRedis(conn)
+			}
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeCacheServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeCacheServer) serveRedis(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			if line, err = r.ReadString('\n'); err != nil {
+				return
+			}
+			size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			buf := make([]byte, size+2)
+			if _, err = io.ReadFull(r, buf); err != nil {
+				return
+			}
+			args[i] = string(buf[:size])
+		}
+
+		s.lock.Lock()
+		var reply string
+		switch {
+		case args[0] == "AUTH":
+			authenticated = args[1] == s.password
+			reply = "+OK\r\n"
+			if !authenticated {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "SELECT":
+			reply = "+OK\r\n"
+		case args[0] == "GET":
+			v, ok := s.values[args[1]]
+			reply = "$-1\r\n"
+			if ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			}
+		case args[0] == "SET":
+			s.values[args[1]] = args[2]
+			reply = "+OK\r\n"
+		case args[0] == "DEL":
+			delete(s.values, args[1])
+			reply = ":1\r\n"
+		case args[0] == "INCR":
+			v, _ := strconv.Atoi(s.values[args[1]])
+			s.values[args[1]] = strconv.Itoa(v + 1)
+			reply = fmt.Sprintf(":%d\r\n", v+1)
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		s.lock.Unlock()
+		if _, err = conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeCacheServer) serveMemcached(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		var data string
+		if fields[0] == "set" || fields[0] == "add" {
+			size, _ := strconv.Atoi(fields[4])
+			buf := make([]byte, size+2)
+			if _, err = io.ReadFull(r, buf); err != nil {
+				return
+			}
+			data = string(buf[:size])
+		}
+
+		s.lock.Lock()
+		var reply string
+		v, ok := s.values[fields[1]]
+		switch fields[0] {
+		case "get":
+			reply = "END\r\n"
+			if ok {
+				reply = fmt.Sprintf("VALUE %s 0 %d\r\n%s\r\nEND\r\n", fields[1], len(v), v)
+			}
+		case "set":
+			s.values[fields[1]] = data
+			reply = "STORED\r\n"
+		case "add":
+			reply = "NOT_STORED\r\n"
+			if !ok {
+				s.values[fields[1]] = data
+				reply = "STORED\r\n"
+			}
+		case "delete":
+			delete(s.values, fields[1])
+			reply = "NOT_FOUND\r\n"
+			if ok {
+				reply = "DELETED\r\n"
+			}
+		case "incr":
+			reply = "NOT_FOUND\r\n"
+			if ok {
+				n, _ := strconv.Atoi(v)
+				s.values[fields[1]] = strconv.Itoa(n + 1)
+				reply = strconv.Itoa(n+1) + "\r\n"
+			}
+		default:
+			reply = "ERROR\r\n"
+		}
+		s.lock.Unlock()
+		if _, err = conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func testSeriesLabels(t *testing.T, metric, instance string) Labels {
+	l, err := LabelsFromSlice(labels.Labels{{Name: MetricNameLabelName, Value: metric}, {Name: "instance", Value: instance}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *l
+}
+
+func TestSharedCache(t *testing.T) {
+	log.Init("debug")
+	redis := newFakeCacheServer(t, false, "secret")
+	memcached := newFakeCacheServer(t, true, "")
+
+	for name, url := range map[string]string{
+		"redis":     "redis://:secret@" + redis.addr() + "/2",
+		"memcached": "memcached://" + memcached.addr(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Two connectors sharing the cache server.
+			first, err := NewSharedCache(url, "test:", time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer first.Close()
+			second, err := NewSharedCache(url, "test:", time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer second.Close()
+
+			if _, err = first.Get("foo"); err != ErrEntryNotFound {
+				t.Fatalf("unexpected error %v, expected ErrEntryNotFound", err)
+			}
+			if err = first.Set("foo", []byte("foo_table")); err != nil {
+				t.Fatal(err)
+			}
+			if value, err := second.Get("foo"); err != nil || string(value) != "foo_table" {
+				t.Fatalf("unexpected table %q, %v", value, err)
+			}
+			if err = second.Delete("foo"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err = first.Get("foo"); err != ErrEntryNotFound {
+				t.Fatalf("unexpected error %v after the delete", err)
+			}
+			if err = first.Set("bar", []byte("bar_table")); err != nil {
+				t.Fatal(err)
+			}
+			if err = first.Reset(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err = first.Get("bar"); err != ErrEntryNotFound {
+				t.Fatalf("unexpected error %v after the reset", err)
+			}
+
+			foo := testSeriesLabels(t, "foo", "a")
+			bar := testSeriesLabels(t, "bar", "a")
+			firstSeries := NewSharedSeriesCache(NewSeriesCache(0), first)
+			secondSeries := NewSharedSeriesCache(NewSeriesCache(0), second)
+			for i, l := range []Labels{foo, bar} {
+				if err = firstSeries.SetSeries(l, SeriesID(i+1)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if id, err := secondSeries.GetSeries(foo); err != nil || id != 1 {
+				t.Fatalf("unexpected series id %d, %v", id, err)
+			}
+			if secondSeries.local.Len() != 1 {
+				t.Fatalf("the series found in the shared cache were not cached locally")
+			}
+
+			// Each connector evicts the series when notified.
+			firstSeries.EvictMetricSeries("foo")
+			secondSeries.EvictMetricSeries("foo")
+			if _, err = secondSeries.GetSeries(foo); err != ErrEntryNotFound {
+				t.Fatalf("unexpected error %v after evicting the series of the metric", err)
+			}
+			if id, err := secondSeries.GetSeries(bar); err != nil || id != 2 {
+				t.Fatalf("the series of another metric were evicted: %d, %v", id, err)
+			}
+			firstSeries.Reset()
+			secondSeries.Reset()
+			if _, err = secondSeries.GetSeries(bar); err != ErrEntryNotFound {
+				t.Fatalf("unexpected error %v after the reset", err)
+			}
+		})
+	}
+}
+
+func TestSharedCacheFailsOpen(t *testing.T) {
+	log.Init("debug")
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	c, err := NewSharedCache("redis://"+addr, "test:", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Get("foo"); err != ErrEntryNotFound {
+		t.Fatalf("unexpected error %v, expected ErrEntryNotFound", err)
+	}
+	if err = c.Set("foo", []byte("foo_table")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err = c.Reset(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	series := NewSharedSeriesCache(NewSeriesCache(0), c)
+	foo := testSeriesLabels(t, "foo", "a")
+	if err = series.SetSeries(foo, 1); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := series.GetSeries(foo); err != nil || id != 1 {
+		t.Fatalf("the local cache was not used: %d, %v", id, err)
+	}
+}
+
+func TestNewSharedCacheInvalidURL(t *testing.T) {
+	for _, url := range []string{
+		"localhost:6379",
+		"http://localhost:6379",
+		"redis://localhost:6379/db",
+		"memcached://",
+	} {
+		if _, err := NewSharedCache(url, "", 0); err == nil {
+			t.Errorf("expected an error for %q", url)
+		}
+	}
+}
+
+func TestTieredCache(t *testing.T) {
+	log.Init("debug")
+	server := newFakeCacheServer(t, false, "")
+	shared, err := NewSharedCache("redis://"+server.addr(), "test:", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shared.Close()
+	local, err := bigcache.NewBigCache(DefaultCacheConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := &MetricNameCache{Metrics: NewTieredCache(local, shared)}
+
+	if err = shared.Set("foo", []byte("foo_table")); err != nil {
+		t.Fatal(err)
+	}
+	if table, err := cache.Get("foo"); err != nil || table != "foo_table" {
+		t.Fatalf("unexpected table %q, %v", table, err)
+	}
+	if table, err := local.Get("foo"); err != nil || string(table) != "foo_table" {
+		t.Fatalf("the table found in the shared cache was not cached locally: %q, %v", table, err)
+	}
+	if err = cache.Delete("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cache.Get("foo"); err != ErrEntryNotFound {
+		t.Fatalf("unexpected error %v after the delete", err)
+	}
+	if err = cache.Delete("missing"); err != nil {
+		t.Fatalf("unexpected error %v deleting a missing metric", err)
+	}
+}
@@ -0,0 +1,212 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+const (
+	// cacheInvalidationChannel is the channel notified by the catalog
+	// triggers, with payloads metric:<metric name> and series:<metric id>.
+	cacheInvalidationChannel = "prom_cache_invalidation"
+	metricIDNameSQL          = "SELECT metric_name FROM " + catalogSchema + ".metric WHERE id = $1"
+
+	cacheInvalidationRetryDelay = 5 * time.Second
+)
+
+// notificationConn is the connection listening to the notifications,
+// implemented by *pgx.Conn.
+type notificationConn interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+	Close(ctx context.Context) error
+}
+
+// metricCacheDeleter is implemented by the metric caches which can forget
+// metrics.
+type metricCacheDeleter interface {
+	Delete(metric string) error
+	Reset() error
+}
+
+// CacheInvalidator keeps the metric and series caches of the connector
+// consistent with the changes made by the other connectors and by the
+// retention: it listens to the notifications of the catalog triggers and
+// invalidates the entries of the metrics created, changed or dropped, and of
+// the metrics whose series were deleted.
+//
+// The notifications sent while it is reconnecting are lost, so both caches
+// are flushed on every reconnection.
+type CacheInvalidator struct {
+	conn     pgxConn
+	connect  func(ctx context.Context) (notificationConn, error)
+	metrics  MetricCache
+	ingestor *DBIngestor
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewCacheInvalidator starts listening to the notifications on a connection
+// opened with listenConnStr, which must not go through a pooler in
+// transaction pooling mode, and invalidates the entries of the metric cache
+// and of the series cache of the ingestor.
+func NewCacheInvalidator(pool *pgxpool.Pool, listenConnStr string, metrics MetricCache, ingestor *DBIngestor) *CacheInvalidator {
+	inv := newCacheInvalidator(&pgxConnImpl{conn: pool}, func(ctx context.Context) (notificationConn, error) {
+		return pgx.Connect(ctx, listenConnStr)
+	}, metrics, ingestor)
+	inv.start()
+	return inv
+}
+
+func newCacheInvalidator(conn pgxConn, connect func(context.Context) (notificationConn, error), metrics MetricCache, ingestor *DBIngestor) *CacheInvalidator {
+	return &CacheInvalidator{
+		conn:     conn,
+		connect:  connect,
+		metrics:  metrics,
+		ingestor: ingestor,
+		done:     make(chan struct{}),
+	}
+}
+
+func (c *CacheInvalidator) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	go c.run(ctx)
+}
+
+func (c *CacheInvalidator) run(ctx context.Context) {
+	defer close(c.done)
+	listened := false
+	for {
+		err := c.listen(ctx, &listened)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn("msg", "Listening to the cache invalidations failed, retrying", "err", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cacheInvalidationRetryDelay):
+		}
+	}
+}
+
+// listen handles the notifications until the connection fails. listened is
+// set once a LISTEN succeeded, after which the caches are flushed when
+// listening again.
+func (c *CacheInvalidator) listen(ctx context.Context, listened *bool) error {
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err = conn.Exec(ctx, "LISTEN "+cacheInvalidationChannel); err != nil {
+		return err
+	}
+	if *listened {
+		c.flush()
+	}
+	*listened = true
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		c.handle(ctx, n.Payload)
+	}
+}
+
+// handle invalidates the cache entries named by the payload of a
+// notification.
+func (c *CacheInvalidator) handle(ctx context.Context, payload string) {
+	parts := strings.SplitN(payload, ":", 2)
+	if len(parts) != 2 {
+		log.Warn("msg", "Ignoring invalid cache invalidation", "payload", payload)
+		return
+	}
+	switch parts[0] {
+	case "metric":
+		c.invalidateMetric(parts[1])
+		cacheInvalidations.WithLabelValues("metric").Inc()
+	case "series":
+		id, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			log.Warn("msg", "Ignoring invalid cache invalidation", "payload", payload)
+			return
+		}
+		metric, found, err := c.metricName(ctx, id)
+		if err != nil {
+			log.Warn("msg", "Looking up the metric of deleted series failed, flushing the caches", "err", err)
+			c.flush()
+			return
+		}
+		if found {
+			c.invalidateMetric(metric)
+		}
+		cacheInvalidations.WithLabelValues("series").Inc()
+	default:
+		log.Warn("msg", "Ignoring invalid cache invalidation", "payload", payload)
+	}
+}
+
+func (c *CacheInvalidator) invalidateMetric(metric string) {
+	if m, ok := c.metrics.(metricCacheDeleter); ok {
+		if err := m.Delete(metric); err != nil {
+			log.Warn("msg", "Invalidating the metric cache failed", "metric", metric, "err", err)
+		}
+	}
+	if c.ingestor != nil {
+		c.ingestor.EvictMetricSeries(metric)
+	}
+}
+
+// flush empties both caches.
+func (c *CacheInvalidator) flush() {
+	if m, ok := c.metrics.(metricCacheDeleter); ok {
+		if err := m.Reset(); err != nil {
+			log.Warn("msg", "Flushing the metric cache failed", "err", err)
+		}
+	}
+	if c.ingestor != nil {
+		c.ingestor.EvictMetricSeries("")
+	}
+	cacheInvalidations.WithLabelValues("flush").Inc()
+}
+
+// metricName returns the name of the metric with id, reporting whether it
+// exists.
+func (c *CacheInvalidator) metricName(ctx context.Context, id int64) (string, bool, error) {
+	rows, err := c.conn.Query(ctx, metricIDNameSQL, id)
+	if err != nil {
+		return "", false, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", false, rows.Err()
+	}
+	var name string
+	if err = rows.Scan(&name); err != nil {
+		return "", false, err
+	}
+	return name, true, nil
+}
+
+// Close stops listening to the notifications.
+func (c *CacheInvalidator) Close() {
+	if c == nil || c.cancel == nil {
+		return
+	}
+	c.cancel()
+	<-c.done
+}
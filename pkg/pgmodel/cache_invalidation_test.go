@@ -0,0 +1,134 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/allegro/bigcache"
+	"github.com/jackc/pgconn"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+type mockNotificationConn struct {
+	payloads []string
+	execSQLs []string
+	closed   bool
+}
+
+func (m *mockNotificationConn) Exec(_ context.Context, sql string, _ ...interface{}) (pgconn.CommandTag, error) {
+	m.execSQLs = append(m.execSQLs, sql)
+	return nil, nil
+}
+
+func (m *mockNotificationConn) WaitForNotification(context.Context) (*pgconn.Notification, error) {
+	if len(m.payloads) == 0 {
+		return nil, fmt.Errorf("connection closed")
+	}
+	p := m.payloads[0]
+	m.payloads = m.payloads[1:]
+	return &pgconn.Notification{Channel: cacheInvalidationChannel, Payload: p}, nil
+}
+
+func (m *mockNotificationConn) Close(context.Context) error {
+	m.closed = true
+	return nil
+}
+
+func newTestInvalidator(t *testing.T, conn pgxConn) (*CacheInvalidator, *MetricNameCache, *SeriesCache, map[string]*Labels) {
+	log.Init("debug")
+	bc, err := bigcache.NewBigCache(DefaultCacheConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := &MetricNameCache{Metrics: bc}
+	series := NewSeriesCache(0)
+	lsets := make(map[string]*Labels)
+	for i, metric := range []string{"foo", "bar"} {
+		if err = metrics.Set(metric, metric+"_table"); err != nil {
+			t.Fatal(err)
+		}
+		l, err := LabelsFromSlice(labels.Labels{{Name: MetricNameLabelName, Value: metric}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = series.SetSeries(*l, SeriesID(i)); err != nil {
+			t.Fatal(err)
+		}
+		lsets[metric] = l
+	}
+	inv := newCacheInvalidator(conn, nil, metrics, &DBIngestor{cache: series})
+	return inv, metrics, series, lsets
+}
+
+func TestCacheInvalidatorHandle(t *testing.T) {
+	testCases := []struct {
+		name     string
+		payload  string
+		results  []rowResults
+		queryErr error
+		expected map[string]bool
+	}{
+		{name: "metric", payload: "metric:foo", expected: map[string]bool{"foo": true}},
+		{name: "series", payload: "series:2", results: []rowResults{{{"bar"}}}, expected: map[string]bool{"bar": true}},
+		{name: "series of a dropped metric", payload: "series:3", results: []rowResults{{}}, expected: map[string]bool{}},
+		{name: "invalid series id", payload: "series:x", expected: map[string]bool{}},
+		{name: "invalid payload", payload: "foo", expected: map[string]bool{}},
+		{name: "series lookup error", payload: "series:2", queryErr: fmt.Errorf("some error"), expected: map[string]bool{"foo": true, "bar": true}},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			conn := &mockPGXConn{QueryResults: c.results, QueryErr: map[int]error{0: c.queryErr}}
+			inv, metrics, series, lsets := newTestInvalidator(t, conn)
+			inv.handle(context.Background(), c.payload)
+
+			for metric, l := range lsets {
+				_, err := metrics.Get(metric)
+				if (err == ErrEntryNotFound) != c.expected[metric] {
+					t.Errorf("metric %s: unexpected invalidation of the metric cache: %v", metric, err)
+				}
+				_, err = series.GetSeries(*l)
+				if (err == ErrEntryNotFound) != c.expected[metric] {
+					t.Errorf("metric %s: unexpected invalidation of the series cache: %v", metric, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheInvalidatorListen(t *testing.T) {
+	inv, metrics, _, _ := newTestInvalidator(t, &mockPGXConn{})
+	conn := &mockNotificationConn{payloads: []string{"metric:foo"}}
+	inv.connect = func(context.Context) (notificationConn, error) { return conn, nil }
+
+	listened := false
+	if err := inv.listen(context.Background(), &listened); err == nil {
+		t.Fatal("expected the connection error")
+	}
+	if !listened || !conn.closed || len(conn.execSQLs) != 1 || conn.execSQLs[0] != "LISTEN "+cacheInvalidationChannel {
+		t.Fatalf("unexpected listen: listened %v, closed %v, %v", listened, conn.closed, conn.execSQLs)
+	}
+	if _, err := metrics.Get("foo"); err != ErrEntryNotFound {
+		t.Errorf("metric not invalidated: %v", err)
+	}
+	if _, err := metrics.Get("bar"); err != nil {
+		t.Errorf("unexpected invalidation: %v", err)
+	}
+
+	// The notifications may have been missed while reconnecting.
+	conn = &mockNotificationConn{}
+	_ = inv.listen(context.Background(), &listened)
+	if _, err := metrics.Get("bar"); err != ErrEntryNotFound {
+		t.Errorf("caches not flushed on reconnection: %v", err)
+	}
+}
+
+func TestCacheInvalidatorCloseNil(t *testing.T) {
+	var inv *CacheInvalidator
+	inv.Close()
+}
@@ -0,0 +1,122 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+const (
+	// DefaultCaptureMaxSize is the default maximum size of the captured
+	// write requests.
+	DefaultCaptureMaxSize = 1 << 30
+
+	captureSegmentSize = 16 << 20
+)
+
+// CaptureConfig configures the write request capture.
+type CaptureConfig struct {
+	// Dir holds the segment files.
+	Dir string
+	// MaxSize is the maximum number of bytes captured. The oldest segments
+	// are deleted once it is reached.
+	MaxSize int64
+}
+
+// RequestCapture records the bodies of the write requests on disk, exactly as
+// they were received, so that real traffic can be replayed against another
+// connector or database with timescale-prometheus-replay. A nil
+// RequestCapture captures nothing.
+type RequestCapture struct {
+	// lock serializes appending a request with rotating the segments.
+	lock    sync.Mutex
+	queue   *segmentQueue
+	maxSize int64
+}
+
+// NewRequestCapture returns a RequestCapture appending to the segments in
+// cfg.Dir.
+func NewRequestCapture(cfg CaptureConfig) (*RequestCapture, error) {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultCaptureMaxSize
+	}
+	// At least two segments fit in the maximum size, so that rotating
+	// keeps part of the captured requests.
+	segmentSize := int64(captureSegmentSize)
+	if segmentSize > cfg.MaxSize/2 {
+		segmentSize = cfg.MaxSize / 2
+	}
+	queue, err := openSegmentQueue(cfg.Dir, segmentSize)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open the request capture: %w", err)
+	}
+	return &RequestCapture{queue: queue, maxSize: cfg.MaxSize}, nil
+}
+
+// Capture records the body of a write request, deleting the oldest segments
+// if the maximum size is exceeded.
+func (c *RequestCapture) Capture(body []byte) error {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.queue.append(body); err != nil {
+		return err
+	}
+	for c.queue.size() > c.maxSize {
+		s, ok := c.queue.oldest()
+		if !ok {
+			break
+		}
+		if err := c.queue.remove(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close seals the current segment.
+func (c *RequestCapture) Close() error {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.queue.close()
+}
+
+// ReadCapture calls process with the body of every write request captured in
+// dir, oldest first, stopping at the first error. It can read the capture of
+// a running connector: the segment being written is read up to its last
+// complete request.
+func ReadCapture(dir string, process func(body []byte) error) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	queue, err := openSegmentQueue(dir, captureSegmentSize)
+	if err != nil {
+		return err
+	}
+	for _, s := range queue.sealed {
+		bodies, err := queue.read(s)
+		if err != nil {
+			// Rotated away since the directory was listed.
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		for _, body := range bodies {
+			if err := process(body); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
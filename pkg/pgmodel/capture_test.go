@@ -0,0 +1,106 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func readCaptured(t *testing.T, dir string) []string {
+	bodies := make([]string, 0)
+	err := ReadCapture(dir, func(body []byte) error {
+		bodies = append(bodies, string(body))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bodies
+}
+
+func TestRequestCapture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := NewRequestCapture(CaptureConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Capture([]byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Capture([]byte("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The segment being written is readable by a running replay.
+	if got := readCaptured(t, dir); !reflect.DeepEqual(got, []string{"first", "second"}) {
+		t.Errorf("unexpected captured requests: got %v", got)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Captures of a previous run are kept.
+	c, err = NewRequestCapture(CaptureConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Capture([]byte("third")); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readCaptured(t, dir); !reflect.DeepEqual(got, []string{"first", "second", "third"}) {
+		t.Errorf("unexpected captured requests: got %v", got)
+	}
+
+	stop := fmt.Errorf("stop")
+	if err = ReadCapture(dir, func([]byte) error { return stop }); err != stop {
+		t.Errorf("unexpected error: got %v wanted %v", err, stop)
+	}
+	if err = ReadCapture(dir+"-missing", func([]byte) error { return nil }); err == nil {
+		t.Error("expected an error reading a missing directory")
+	}
+}
+
+func TestRequestCaptureRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Segments of 50 bytes hold 5 requests of 10 bytes with their header.
+	c, err := NewRequestCapture(CaptureConfig{Dir: dir, MaxSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	expected := make([]string, 0)
+	for i := 0; i < 30; i++ {
+		body := fmt.Sprintf("request%03d", i)
+		if err = c.Capture([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, body)
+	}
+
+	if size := c.queue.size(); size > 100 {
+		t.Errorf("capture over its maximum size: %d bytes", size)
+	}
+	got := readCaptured(t, dir)
+	if len(got) == 0 || !reflect.DeepEqual(got, expected[len(expected)-len(got):]) {
+		t.Errorf("unexpected captured requests after rotation: got %v", got)
+	}
+}
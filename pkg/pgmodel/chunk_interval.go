@@ -0,0 +1,148 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+const (
+	// Metrics whose chunk interval was set with set_metric_chunk_interval
+	// are left alone.
+	listChunkIntervalsSQL = `SELECT m.metric_name, d.interval_length
+	FROM ` + catalogSchema + `.metric m
+	INNER JOIN _timescaledb_catalog.hypertable h ON (h.schema_name = '` + dataSchema + `' AND h.table_name = m.table_name)
+	INNER JOIN _timescaledb_catalog.dimension d ON (d.hypertable_id = h.id AND d.column_name = 'time')
+	WHERE m.default_chunk_interval`
+	setChunkIntervalSQL = "SELECT " + catalogSchema + ".set_chunk_interval_on_metric_table($1, $2 * INTERVAL '1 microsecond')"
+
+	// bytesPerSample estimates the size of an uncompressed sample, with its
+	// share of the index.
+	bytesPerSample = 70
+	// chunkIntervalTolerance is how far, as a ratio, the ideal chunk
+	// interval may drift from the current one before it is changed, so that
+	// noisy rates do not change it on every run.
+	chunkIntervalTolerance = 1.5
+)
+
+// ChunkIntervalConfig configures the adaptation of the chunk intervals.
+type ChunkIntervalConfig struct {
+	// TargetSizeMB is the uncompressed size of the chunks aimed for.
+	TargetSizeMB int
+	// Min and Max bound the chunk intervals set.
+	Min time.Duration
+	Max time.Duration
+}
+
+// ChunkIntervalTuner adapts the chunk interval of each metric to its ingest
+// rate, so that its chunks reach the target size: chunks too small make
+// queries open many of them, while chunks too large compress late and prune
+// poorly. The rates are those of the samples ingested by this connector
+// between two runs. The new interval applies to the chunks created next.
+type ChunkIntervalTuner struct {
+	conn     pgxConn
+	cfg      ChunkIntervalConfig
+	ingested func() map[string]uint64
+
+	lastIngested map[string]uint64
+	lastRun      time.Time
+}
+
+// NewChunkIntervalTuner returns a new ChunkIntervalTuner using the given
+// connection pool, reading the samples ingested per metric since startup
+// from ingested.
+func NewChunkIntervalTuner(c *pgxpool.Pool, cfg ChunkIntervalConfig, ingested func() map[string]uint64) *ChunkIntervalTuner {
+	return &ChunkIntervalTuner{
+		conn: &pgxConnImpl{
+			conn: c,
+		},
+		cfg:      cfg,
+		ingested: ingested,
+	}
+}
+
+// Run adapts the chunk intervals to the rates observed since the previous
+// run. The first run only starts the observation.
+func (t *ChunkIntervalTuner) Run() error {
+	return t.tune(time.Now())
+}
+
+func (t *ChunkIntervalTuner) tune(now time.Time) error {
+	ingested := t.ingested()
+	last, elapsed := t.lastIngested, now.Sub(t.lastRun)
+	t.lastIngested, t.lastRun = ingested, now
+	if last == nil || elapsed <= 0 {
+		return nil
+	}
+
+	intervals, err := t.chunkIntervals()
+	if err != nil {
+		return err
+	}
+	for metric, current := range intervals {
+		samples := ingested[metric] - last[metric]
+		if samples == 0 {
+			continue
+		}
+		rate := float64(samples) / elapsed.Seconds()
+		ideal := t.idealInterval(rate)
+		if ratio := float64(ideal) / float64(current); ratio < chunkIntervalTolerance && ratio > 1/chunkIntervalTolerance {
+			continue
+		}
+
+		if _, err := t.conn.Exec(context.Background(), setChunkIntervalSQL, metric, ideal.Microseconds()); err != nil {
+			return err
+		}
+		chunkIntervalChanges.Inc()
+		log.Info("msg", "Adapted the chunk interval to the ingest rate", "metric", metric,
+			"samples/sec", rate, "old", current, "new", ideal)
+	}
+	return nil
+}
+
+// idealInterval returns the chunk interval filling chunks of the target size
+// at rate samples per second.
+func (t *ChunkIntervalTuner) idealInterval(rate float64) time.Duration {
+	seconds := float64(t.cfg.TargetSizeMB) * (1 << 20) / bytesPerSample / rate
+	ideal := time.Duration(seconds * float64(time.Second)).Truncate(time.Minute)
+	if ideal < t.cfg.Min {
+		ideal = t.cfg.Min
+	}
+	if ideal < time.Minute {
+		ideal = time.Minute
+	}
+	if t.cfg.Max > 0 && ideal > t.cfg.Max {
+		ideal = t.cfg.Max
+	}
+	return ideal
+}
+
+// chunkIntervals returns the chunk intervals of the metrics which may be
+// adapted.
+func (t *ChunkIntervalTuner) chunkIntervals() (map[string]time.Duration, error) {
+	rows, err := t.conn.Query(context.Background(), listChunkIntervalsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	intervals := make(map[string]time.Duration)
+	for rows.Next() {
+		var (
+			metric string
+			micros int64
+		)
+		if err := rows.Scan(&metric, &micros); err != nil {
+			return nil, err
+		}
+		intervals[metric] = time.Duration(micros) * time.Microsecond
+	}
+	return intervals, rows.Err()
+}
@@ -0,0 +1,68 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+func TestChunkIntervalTuner(t *testing.T) {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	eightHours := (8 * time.Hour).Microseconds()
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{{
+			{"busy", eightHours},
+			{"steady", eightHours},
+			{"quiet", eightHours},
+			{"idle", eightHours},
+		}},
+	}
+	ingested := map[string]uint64{"busy": 1, "steady": 1, "quiet": 1, "idle": 1, "manual": 1}
+	tuner := &ChunkIntervalTuner{
+		conn:     mock,
+		cfg:      ChunkIntervalConfig{TargetSizeMB: bytesPerSample, Min: 30 * time.Minute, Max: 7 * 24 * time.Hour},
+		ingested: func() map[string]uint64 { return ingested },
+	}
+
+	// The first run only records the samples ingested so far.
+	start := time.Now()
+	if err := tuner.tune(start); err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.QuerySQLs) != 0 {
+		t.Fatalf("unexpected queries: %v", mock.QuerySQLs)
+	}
+
+	// The target is 2^20 samples per chunk, measured over 1024 seconds.
+	ingested = map[string]uint64{"busy": 1 + 131072, "steady": 1 + 32768, "quiet": 2, "idle": 1, "manual": 1000000}
+	changesBefore := testutil.ToFloat64(chunkIntervalChanges)
+	if err := tuner.tune(start.Add(1024 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(map[string]interface{})
+	for i, sql := range mock.ExecSQLs {
+		if sql != setChunkIntervalSQL {
+			t.Fatalf("unexpected statement: %s", sql)
+		}
+		changed[mock.ExecArgs[i][0].(string)] = mock.ExecArgs[i][1]
+	}
+	expected := map[string]interface{}{
+		"busy":  (2*time.Hour + 16*time.Minute).Microseconds(),
+		"quiet": (7 * 24 * time.Hour).Microseconds(),
+	}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Errorf("unexpected chunk intervals:\ngot\n%v\nwanted\n%v", changed, expected)
+	}
+	if changes := testutil.ToFloat64(chunkIntervalChanges) - changesBefore; changes != 2 {
+		t.Errorf("unexpected changes: got %v wanted 2", changes)
+	}
+}
@@ -0,0 +1,158 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	// maxSamplesInChunk is the number of samples after which a XOR chunk is cut.
+	maxSamplesInChunk = 120
+	// maxBytesInFrame is the approximate size after which a series is split
+	// into multiple frames.
+	maxBytesInFrame = 1024 * 1024
+)
+
+// castagnoliTable is initialized up-front to avoid racing with other users of
+// the crc32 package.
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ChunkedWriter is an io.Writer wrapper that allows streaming by adding a
+// uvarint length delimiter and a CRC-32 checksum before each write. This is
+// the framing expected by Prometheus for STREAMED_XOR_CHUNKS responses.
+type ChunkedWriter struct {
+	writer  io.Writer
+	flusher http.Flusher
+	crc32   hash.Hash32
+	written bool
+}
+
+// NewChunkedWriter constructs a ChunkedWriter.
+func NewChunkedWriter(w io.Writer, f http.Flusher) *ChunkedWriter {
+	return &ChunkedWriter{writer: w, flusher: f, crc32: crc32.New(castagnoliTable)}
+}
+
+// Write writes a single frame to the stream and flushes it. The returned
+// byte count does not include the delimiter and checksum.
+func (w *ChunkedWriter) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	var buf [binary.MaxVarintLen64]byte
+	v := binary.PutUvarint(buf[:], uint64(len(b)))
+	w.written = true
+	if _, err := w.writer.Write(buf[:v]); err != nil {
+		return 0, err
+	}
+
+	w.crc32.Reset()
+	if _, err := w.crc32.Write(b); err != nil {
+		return 0, err
+	}
+
+	if err := binary.Write(w.writer, binary.BigEndian, w.crc32.Sum32()); err != nil {
+		return 0, err
+	}
+
+	n, err := w.writer.Write(b)
+	if err != nil {
+		return n, err
+	}
+
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+	return n, nil
+}
+
+// Written reports whether any part of a frame was written, after which the
+// response can no longer be replaced by an error.
+func (w *ChunkedWriter) Written() bool {
+	return w.written
+}
+
+// writeChunkedSeries encodes the samples of a series as XOR chunks and writes
+// them to the stream as one or more ChunkedReadResponse frames.
+func writeChunkedSeries(w io.Writer, queryIndex int64, ts *prompb.TimeSeries) error {
+	lblsSize := 0
+	for _, l := range ts.Labels {
+		lblsSize += l.Size()
+	}
+
+	samples := ts.Samples
+	for len(samples) > 0 {
+		var (
+			chks []prompb.Chunk
+			err  error
+		)
+		chks, samples, err = encodeChunks(samples, maxBytesInFrame-lblsSize)
+		if err != nil {
+			return err
+		}
+
+		b, err := proto.Marshal(&prompb.ChunkedReadResponse{
+			ChunkedSeries: []*prompb.ChunkedSeries{
+				{
+					Labels: ts.Labels,
+					Chunks: chks,
+				},
+			},
+			QueryIndex: queryIndex,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeChunks cuts the samples into XOR chunks until the frame is full. It
+// returns the encoded chunks and the samples which did not fit in the frame.
+func encodeChunks(samples []prompb.Sample, frameBytesLeft int) ([]prompb.Chunk, []prompb.Sample, error) {
+	chks := make([]prompb.Chunk, 0, len(samples)/maxSamplesInChunk+1)
+
+	for len(samples) > 0 && frameBytesLeft > 0 {
+		n := maxSamplesInChunk
+		if n > len(samples) {
+			n = len(samples)
+		}
+
+		chk := chunkenc.NewXORChunk()
+		app, err := chk.Appender()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, s := range samples[:n] {
+			app.Append(s.Timestamp, s.Value)
+		}
+
+		chks = append(chks, prompb.Chunk{
+			MinTimeMs: samples[0].Timestamp,
+			MaxTimeMs: samples[n-1].Timestamp,
+			Type:      prompb.Chunk_Encoding(chk.Encoding()),
+			Data:      chk.Bytes(),
+		})
+		// We are fine with a minor inaccuracy of the max bytes per frame,
+		// the inaccuracy will be at most the size of a full chunk.
+		frameBytesLeft -= chks[len(chks)-1].Size()
+		samples = samples[n:]
+	}
+
+	return chks, samples, nil
+}
@@ -0,0 +1,43 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/timescale/timescale-prometheus/pkg/events"
+)
+
+// The reasons of the data loss events.
+const (
+	dataLossAsyncAck    = "async_ack_failure"
+	dataLossShed        = "shed"
+	dataLossSeriesLimit = "series_limit"
+	dataLossSpillExpiry = "spill_expired"
+)
+
+// maxDataLossMetrics bounds the number of metrics named in a data loss
+// event, so that a large write does not make a huge event.
+const maxDataLossMetrics = 20
+
+// emitDataLoss emits a data loss event for the samples of metrics dropped
+// for reason.
+func emitDataLoss(reason string, samples uint64, metrics []string) {
+	if samples == 0 {
+		return
+	}
+	events.Emit(events.DataLost, "reason", reason, "samples", samples, "metrics", formatDataLossMetrics(metrics))
+}
+
+func formatDataLossMetrics(metrics []string) string {
+	sorted := append([]string(nil), metrics...)
+	sort.Strings(sorted)
+	if len(sorted) <= maxDataLossMetrics {
+		return strings.Join(sorted, ",")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(sorted[:maxDataLossMetrics], ","), len(sorted)-maxDataLossMetrics)
+}
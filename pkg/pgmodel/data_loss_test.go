@@ -0,0 +1,70 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/events"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+type eventRecorder struct {
+	events []events.Event
+}
+
+func (r *eventRecorder) Name() string {
+	return "recorder"
+}
+
+func (r *eventRecorder) Send(e events.Event) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+func TestFormatDataLossMetrics(t *testing.T) {
+	if got := formatDataLossMetrics([]string{"b", "a"}); got != "a,b" {
+		t.Errorf("unexpected metrics: %s", got)
+	}
+	many := make([]string, maxDataLossMetrics+3)
+	for i := range many {
+		many[i] = fmt.Sprintf("m%02d", i)
+	}
+	got := formatDataLossMetrics(many)
+	if !strings.HasPrefix(got, "m00,m01,") || !strings.HasSuffix(got, "m19 and 3 more") {
+		t.Errorf("unexpected metrics: %s", got)
+	}
+}
+
+func TestSeriesLimitEmitsDataLoss(t *testing.T) {
+	recorder := &eventRecorder{}
+	bus := events.Init("instance", 10, recorder)
+
+	limiter, err := newSeriesLimiter(SeriesLimitConfig{MaxSeriesPerMetric: 1, ActiveWindow: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := func(fingerprint uint64) samplesInfo {
+		return samplesInfo{labels: &Labels{fingerprint: fingerprint}, samples: []prompb.Sample{{}, {}}}
+	}
+	limiter.enforce(map[string][]samplesInfo{"kept": {series(1)}})
+	limiter.enforce(map[string][]samplesInfo{"limited": {series(1), series(2), series(3)}, "kept": {series(1)}})
+	bus.Close()
+
+	expected := []map[string]string{{"reason": dataLossSeriesLimit, "samples": "4", "metrics": "limited"}}
+	attributes := make([]map[string]string, 0, len(recorder.events))
+	for _, e := range recorder.events {
+		if e.Type != events.DataLost {
+			t.Errorf("unexpected event: %+v", e)
+		}
+		attributes = append(attributes, e.Attributes)
+	}
+	if !reflect.DeepEqual(attributes, expected) {
+		t.Errorf("unexpected events: got %v wanted %v", attributes, expected)
+	}
+}
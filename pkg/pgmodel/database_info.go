@@ -0,0 +1,95 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const databaseInfoSQL = `SELECT
+	current_setting('server_version'),
+	COALESCE((SELECT extversion FROM pg_extension WHERE extname = 'timescaledb'), ''),
+	COALESCE((SELECT extversion FROM pg_extension WHERE extname = 'timescale_prometheus_extra'), ''),
+	COALESCE((SELECT version FROM public.prom_schema_migrations LIMIT 1), 0),
+	COALESCE((SELECT dirty FROM public.prom_schema_migrations LIMIT 1), false)`
+
+// DatabaseInfo describes the database the connector runs against.
+type DatabaseInfo struct {
+	PostgresVersion       string `json:"postgres_version"`
+	TimescaleDBVersion    string `json:"timescaledb_version"`
+	ExtraExtensionVersion string `json:"extra_extension_version"`
+	SchemaVersion         int64  `json:"schema_version"`
+	SchemaDirty           bool   `json:"schema_dirty"`
+	ExpectedSchemaVersion uint   `json:"expected_schema_version"`
+}
+
+// ReadDatabaseInfo returns the versions of PostgreSQL, of the extensions and
+// of the schema installed in the database. Missing extensions have an empty
+// version.
+func ReadDatabaseInfo(c *pgxpool.Pool) (*DatabaseInfo, error) {
+	return readDatabaseInfo(&pgxConnImpl{conn: c})
+}
+
+func readDatabaseInfo(conn pgxConn) (*DatabaseInfo, error) {
+	expected, err := LatestMigrationVersion()
+	if err != nil {
+		return nil, err
+	}
+	info := &DatabaseInfo{ExpectedSchemaVersion: expected}
+
+	rows, err := conn.Query(context.Background(), databaseInfoSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, fmt.Errorf("database info query returned no rows")
+	}
+	err = rows.Scan(&info.PostgresVersion, &info.TimescaleDBVersion, &info.ExtraExtensionVersion, &info.SchemaVersion, &info.SchemaDirty)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// SchemaVersionError reports an installed schema the connector does not
+// support, which would otherwise fail the writes and reads with SQL errors.
+type SchemaVersionError struct {
+	Installed int64
+	Dirty     bool
+	Expected  uint
+}
+
+func (e *SchemaVersionError) Error() string {
+	switch {
+	case e.Dirty:
+		return fmt.Sprintf("the migration to schema version %d did not complete", e.Installed)
+	case e.Installed == 0:
+		return fmt.Sprintf("the schema is not installed, this connector requires schema version %d", e.Expected)
+	case e.Newer():
+		return fmt.Sprintf("the schema version %d is newer than the version %d supported by this connector, upgrade the connector", e.Installed, e.Expected)
+	default:
+		return fmt.Sprintf("the schema version %d is older than the version %d required by this connector, migrate the schema", e.Installed, e.Expected)
+	}
+}
+
+// Newer reports whether the installed schema was migrated by a newer
+// connector.
+func (e *SchemaVersionError) Newer() bool {
+	return e.Installed > int64(e.Expected)
+}
+
+// CheckSchemaVersion returns a *SchemaVersionError unless the installed
+// schema is the version the connector expects.
+func (i *DatabaseInfo) CheckSchemaVersion() error {
+	if i.SchemaDirty || i.SchemaVersion != int64(i.ExpectedSchemaVersion) {
+		return &SchemaVersionError{Installed: i.SchemaVersion, Dirty: i.SchemaDirty, Expected: i.ExpectedSchemaVersion}
+	}
+	return nil
+}
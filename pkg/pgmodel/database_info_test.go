@@ -0,0 +1,70 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadDatabaseInfo(t *testing.T) {
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{{{"12.3", "1.7.1", "", int64(5), false}}},
+	}
+	info, err := readDatabaseInfo(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedVersion, err := LatestMigrationVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &DatabaseInfo{
+		PostgresVersion:       "12.3",
+		TimescaleDBVersion:    "1.7.1",
+		SchemaVersion:         5,
+		ExpectedSchemaVersion: expectedVersion,
+	}
+	if !reflect.DeepEqual(info, expected) {
+		t.Errorf("unexpected database info: got %+v wanted %+v", info, expected)
+	}
+	if !reflect.DeepEqual(mock.QuerySQLs, []string{databaseInfoSQL}) {
+		t.Errorf("unexpected queries: %v", mock.QuerySQLs)
+	}
+}
+
+func TestCheckSchemaVersion(t *testing.T) {
+	testCases := []struct {
+		name      string
+		installed int64
+		dirty     bool
+		newer     bool
+		ok        bool
+	}{
+		{name: "expected", installed: 10, ok: true},
+		{name: "not installed", installed: 0},
+		{name: "older", installed: 9},
+		{name: "newer", installed: 11, newer: true},
+		{name: "dirty", installed: 10, dirty: true},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			info := &DatabaseInfo{SchemaVersion: c.installed, SchemaDirty: c.dirty, ExpectedSchemaVersion: 10}
+			err := info.CheckSchemaVersion()
+			if c.ok {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			versionErr, ok := err.(*SchemaVersionError)
+			if !ok {
+				t.Fatalf("unexpected error %v, expected a *SchemaVersionError", err)
+			}
+			if versionErr.Newer() != c.newer {
+				t.Errorf("unexpected Newer() for %v", versionErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// dedupInsertStatement inserts the samples passed as arrays into a metric
+// table, skipping those already stored for the same series and time, as well
+// as the duplicates within the batch. The lookups use the (series_id, time)
+// index of the table. Duplicates are only detected between batches inserted
+// one after the other: each series is inserted by a single routine of the
+// connector, but two connectors inserting the same sample at once may both
+// store it.
+func dedupInsertStatement(table string) string {
+	t := pgx.Identifier{dataSchema, table}.Sanitize()
+	return fmt.Sprintf(`INSERT INTO %[1]s (time, value, series_id)
+	SELECT DISTINCT ON (s.series_id, s.time) s.time, s.value, s.series_id
+	FROM unnest($1::timestamptz[], $2::float8[], $3::bigint[]) AS s(time, value, series_id)
+	WHERE NOT EXISTS (SELECT 1 FROM %[1]s d WHERE d.series_id = s.series_id AND d.time = s.time)`, t)
+}
+
+// insertDeduplicated inserts the samples of req with dedupInsertStatement,
+// returning the number of samples actually inserted.
+func insertDeduplicated(conn pgxConn, req copyRequest) (int64, error) {
+	n := req.data.batch.numSamples()
+	var (
+		times     = make([]time.Time, 0, n)
+		values    = make([]float64, 0, n)
+		seriesIDs = make([]int64, 0, n)
+	)
+	for req.data.batch.Next() {
+		row, err := req.data.batch.Values()
+		if err != nil {
+			return 0, err
+		}
+		times = append(times, row[0].(time.Time))
+		values = append(values, row[1].(float64))
+		seriesIDs = append(seriesIDs, int64(row[2].(SeriesID)))
+	}
+
+	tag, err := conn.Exec(context.Background(), dedupInsertStatement(req.table), times, values, seriesIDs)
+	if err != nil {
+		return 0, err
+	}
+	inserted := tag.RowsAffected()
+	duplicateSamples.Add(float64(int64(n) - inserted))
+	return inserted, nil
+}
@@ -0,0 +1,47 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestCopyFromDedup(t *testing.T) {
+	mock := &mockPGXConn{ExecResult: pgconn.CommandTag("INSERT 0 2")}
+	data := pendingBuffers.Get().(*pendingBuffer)
+	data.batch.Append(samplesInfo{seriesID: 1, samples: []prompb.Sample{{Timestamp: 1, Value: 0.5}, {Timestamp: 2, Value: 1}}})
+	data.batch.Append(samplesInfo{seriesID: 2, samples: []prompb.Sample{{Timestamp: 1, Value: 2}}})
+	data.batch.ResetPosition()
+
+	duplicatesBefore := testutil.ToFloat64(duplicateSamples)
+	inserted, err := copyFrom(mock, copyRequest{data: data, table: "metric"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 2 {
+		t.Errorf("unexpected number of samples inserted: got %d wanted 2", inserted)
+	}
+	if duplicates := testutil.ToFloat64(duplicateSamples) - duplicatesBefore; duplicates != 1 {
+		t.Errorf("unexpected duplicates: got %v wanted 1", duplicates)
+	}
+
+	if len(mock.CopyFromTableName) != 0 || !reflect.DeepEqual(mock.ExecSQLs, []string{dedupInsertStatement("metric")}) {
+		t.Fatalf("samples not inserted with the dedup statement: %v", mock.ExecSQLs)
+	}
+	expected := []interface{}{
+		[]time.Time{model.Time(1).Time(), model.Time(2).Time(), model.Time(1).Time()},
+		[]float64{0.5, 1, 2},
+		[]int64{1, 1, 2},
+	}
+	if !reflect.DeepEqual(mock.ExecArgs[0], expected) {
+		t.Errorf("unexpected arguments:\ngot\n%v\nwanted\n%v", mock.ExecArgs[0], expected)
+	}
+}
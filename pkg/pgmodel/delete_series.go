@@ -0,0 +1,323 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/timescale/timescale-prometheus/pkg/events"
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	countSeriesSamplesSQLFormat = `SELECT count(*) FROM %s
+	WHERE series_id = ANY($1) AND time >= $2 AND time <= $3`
+
+	deleteSeriesSamplesSQLFormat = `DELETE FROM %s
+	WHERE series_id = ANY($1) AND time >= $2 AND time <= $3`
+
+	// A series is orphaned once it has no sample left. Series still holding
+	// rolled up samples are kept, as the lifecycle policies do. The formats
+	// take the series table, the data table, a filter on the remaining
+	// samples and a filter on the rolled up ones.
+	orphanedSeriesFilterSQLFormat = `NOT EXISTS (
+		SELECT 1 FROM %[2]s d
+		WHERE d.series_id = s.id
+		%[3]s
+		LIMIT 1
+	) %[4]s`
+	rollupSeriesFilterSQLFormat = `AND NOT EXISTS (
+		SELECT 1 FROM %s r
+		WHERE r.series_id = s.id
+		LIMIT 1
+	)`
+
+	// The dry run counts the series having no sample outside of the range.
+	countOrphanedSeriesSQLFormat = `SELECT count(*) FROM %[1]s s
+	WHERE s.id = ANY($1) AND ` + orphanedSeriesFilterSQLFormat
+	deleteOrphanedSeriesSQLFormat = `WITH deleted_series AS (
+		DELETE FROM %[1]s s
+		WHERE s.id = ANY($1) AND ` + orphanedSeriesFilterSQLFormat + `
+		RETURNING s.id
+	), deleted_liveness AS (
+		DELETE FROM ` + catalogSchema + `.series_liveness l
+		WHERE l.series_id IN (SELECT id FROM deleted_series)
+	)
+	SELECT coalesce(array_agg(id), '{}') FROM deleted_series`
+
+	rollupTableExistsSQL = "SELECT to_regclass($1) IS NOT NULL"
+)
+
+// SeriesDeletion reports the samples and series deleted from a metric.
+type SeriesDeletion struct {
+	Metric string `json:"metric"`
+	// Series is the number of series matched.
+	Series int `json:"series"`
+	// Samples is the number of samples deleted in the time range.
+	Samples int64 `json:"samples"`
+	// OrphanedSeries is the number of series left without samples, which
+	// are deleted from the catalog and evicted from the series cache.
+	OrphanedSeries int64 `json:"orphaned_series"`
+	// Tombstone is the id of the tombstone hiding the samples until
+	// PurgeAfter, when deleted with a grace period. The samples and series
+	// are then those to be deleted by the purge.
+	Tombstone  int64      `json:"tombstone,omitempty"`
+	PurgeAfter *time.Time `json:"purge_after,omitempty"`
+}
+
+// SeriesDeleter deletes the samples of the series matching label matchers,
+// the way the delete series API of the Prometheus TSDB does.
+type SeriesDeleter struct {
+	conn  pgxConn
+	evict func([]SeriesID)
+	// grace is how long the deleted samples are only hidden by a tombstone
+	// before being purged. They are deleted at once if 0.
+	grace time.Duration
+	now   func() time.Time
+}
+
+// NewSeriesDeleter returns a SeriesDeleter using the connection pool. evict
+// is called with the ids of the series deleted from the catalog, so that the
+// series written again get a new id. With a grace period, the deleted samples
+// are tombstoned, and purged by PurgeTombstones once it is over.
+func NewSeriesDeleter(c *pgxpool.Pool, evict func([]SeriesID), grace time.Duration) *SeriesDeleter {
+	return &SeriesDeleter{
+		conn: &pgxConnImpl{
+			conn: c,
+		},
+		evict: evict,
+		grace: grace,
+		now:   time.Now,
+	}
+}
+
+// DeleteSeries deletes the samples between start and end, inclusive, of the
+// series matching any of the selectors, then removes the series left without
+// samples from the catalog. With a grace period, the samples are tombstoned
+// instead, up to now at the latest. A dry run only counts what would be
+// deleted. The deletions are reported per metric, ordered by metric name.
+func (d *SeriesDeleter) DeleteSeries(ctx context.Context, selectors [][]*prompb.LabelMatcher, start, end time.Time, dryRun bool) ([]SeriesDeletion, error) {
+	seriesPerMetric, err := matchingSeries(ctx, d.conn, selectors)
+	if err != nil {
+		return nil, err
+	}
+	metrics := make([]string, 0, len(seriesPerMetric))
+	for metric := range seriesPerMetric {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	deletions := make([]SeriesDeletion, 0, len(metrics))
+	for _, metric := range metrics {
+		ids := make([]int64, 0, len(seriesPerMetric[metric]))
+		for id := range seriesPerMetric[metric] {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		deletion, err := d.deleteMetricSeries(ctx, metric, ids, start, end, dryRun)
+		if err != nil {
+			return deletions, fmt.Errorf("deleting series of metric %s: %w", metric, err)
+		}
+		if !dryRun && deletion.Tombstone != 0 {
+			log.Info("msg", "Tombstoned series", "metric", metric, "series", deletion.Series,
+				"samples", deletion.Samples, "tombstone", deletion.Tombstone, "purge_after", *deletion.PurgeAfter)
+			events.Emit(events.SeriesDeleted, "metric", metric, "series", deletion.Series, "samples", deletion.Samples, "tombstone", deletion.Tombstone)
+		} else if !dryRun {
+			log.Info("msg", "Deleted series", "metric", metric, "series", deletion.Series,
+				"samples", deletion.Samples, "orphaned_series", deletion.OrphanedSeries)
+			events.Emit(events.SeriesDeleted, "metric", metric, "series", deletion.Series, "samples", deletion.Samples)
+		}
+		deletions = append(deletions, deletion)
+	}
+	return deletions, nil
+}
+
+// matchingSeries returns the ids of the series matching any of the
+// selectors, per metric.
+func matchingSeries(ctx context.Context, conn pgxConn, selectors [][]*prompb.LabelMatcher) (map[string]map[int64]struct{}, error) {
+	seriesPerMetric := make(map[string]map[int64]struct{})
+	for _, matchers := range selectors {
+		_, clauses, values, err := buildSubQueries(&prompb.Query{Matchers: matchers})
+		if err != nil {
+			return nil, err
+		}
+		rows, err := conn.Query(ctx, buildMetricNameSeriesIDQuery(clauses), values...)
+		if err != nil {
+			return nil, err
+		}
+		metrics, series, err := getSeriesPerMetric(rows)
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		for i, metric := range metrics {
+			ids, ok := seriesPerMetric[metric]
+			if !ok {
+				ids = make(map[int64]struct{}, len(series[i]))
+				seriesPerMetric[metric] = ids
+			}
+			for _, id := range series[i] {
+				ids[int64(id)] = struct{}{}
+			}
+		}
+	}
+	return seriesPerMetric, nil
+}
+
+func (d *SeriesDeleter) deleteMetricSeries(ctx context.Context, metric string, ids []int64, start, end time.Time, dryRun bool) (SeriesDeletion, error) {
+	deletion := SeriesDeletion{Metric: metric, Series: len(ids)}
+	table, err := metricTableName(ctx, d.conn, metric)
+	if err != nil {
+		return deletion, err
+	}
+	if !dryRun && d.grace <= 0 {
+		return d.purgeSeries(ctx, deletion, table, ids, start, end)
+	}
+	if d.grace > 0 {
+		// The samples written after the tombstone are not hidden by it.
+		if now := d.now(); end.After(now) {
+			end = now
+		}
+	}
+	dataTable := pgx.Identifier{dataSchema, table}.Sanitize()
+	seriesTable := pgx.Identifier{dataSeriesSchema, table}.Sanitize()
+	rollupFilter, err := rollupSeriesFilter(ctx, d.conn, table)
+	if err != nil {
+		return deletion, err
+	}
+
+	if err = queryCount(ctx, d.conn, &deletion.Samples, fmt.Sprintf(countSeriesSamplesSQLFormat, dataTable), ids, start, end); err != nil {
+		return deletion, err
+	}
+	err = queryCount(ctx, d.conn, &deletion.OrphanedSeries,
+		fmt.Sprintf(countOrphanedSeriesSQLFormat, seriesTable, dataTable, "AND (d.time < $2 OR d.time > $3)", rollupFilter),
+		ids, start, end)
+	if err != nil || dryRun {
+		return deletion, err
+	}
+	return d.tombstoneSeries(ctx, deletion, table, ids, start, end)
+}
+
+// purgeSeries deletes the samples between start and end of the series of the
+// metric stored in table, then the series left without samples.
+func (d *SeriesDeleter) purgeSeries(ctx context.Context, deletion SeriesDeletion, table string, ids []int64, start, end time.Time) (SeriesDeletion, error) {
+	dataTable := pgx.Identifier{dataSchema, table}.Sanitize()
+	seriesTable := pgx.Identifier{dataSeriesSchema, table}.Sanitize()
+	rollupFilter, err := rollupSeriesFilter(ctx, d.conn, table)
+	if err != nil {
+		return deletion, err
+	}
+
+	deleteSamples := fmt.Sprintf(deleteSeriesSamplesSQLFormat, dataTable)
+	tag, err := d.conn.Exec(ctx, deleteSamples, ids, start, end)
+	if pgErr, ok := err.(*pgconn.PgError); ok && strings.Contains(pgErr.Message, "insert/update/delete not permitted") {
+		// The range covers compressed chunks, decompress and try again.
+		log.Warn("msg", fmt.Sprintf("Table %s was compressed, decompressing", table), "table", table)
+		if _, decompressErr := d.conn.Exec(ctx, "CALL "+catalogSchema+".decompress_chunks_after($1, $2);", table, start); decompressErr != nil {
+			return deletion, err
+		}
+		tag, err = d.conn.Exec(ctx, deleteSamples, ids, start, end)
+	}
+	if err != nil {
+		return deletion, err
+	}
+	deletion.Samples = tag.RowsAffected()
+
+	orphaned, err := querySeriesIDs(ctx, d.conn,
+		fmt.Sprintf(deleteOrphanedSeriesSQLFormat, seriesTable, dataTable, "", rollupFilter),
+		ids)
+	if err != nil {
+		return deletion, err
+	}
+	deletion.OrphanedSeries = int64(len(orphaned))
+	if len(orphaned) > 0 && d.evict != nil {
+		d.evict(orphaned)
+	}
+	return deletion, nil
+}
+
+func metricTableName(ctx context.Context, conn pgxConn, metric string) (string, error) {
+	rows, err := conn.Query(ctx, getMetricsTableSQL, metric)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", &MissingMetricError{Metric: metric}
+	}
+	var table string
+	err = rows.Scan(&table)
+	return table, err
+}
+
+// rollupSeriesFilter keeps the series referenced by the rollup table of the
+// metric, if it has one.
+func rollupSeriesFilter(ctx context.Context, conn pgxConn, table string) (string, error) {
+	exists, err := rollupTableExists(ctx, conn, table)
+	if err != nil || !exists {
+		return "", err
+	}
+	return fmt.Sprintf(rollupSeriesFilterSQLFormat, pgx.Identifier{rollupSchema, table}.Sanitize()), nil
+}
+
+// rollupTableExists reports whether the metric stored in table has a rollup
+// table.
+func rollupTableExists(ctx context.Context, conn pgxConn, table string) (bool, error) {
+	var exists bool
+	rows, err := conn.Query(ctx, rollupTableExistsSQL, pgx.Identifier{rollupSchema, table}.Sanitize())
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		if err = rows.Scan(&exists); err != nil {
+			return false, err
+		}
+	}
+	return exists, rows.Err()
+}
+
+// querySeriesIDs returns the array of series ids selected by sql.
+func querySeriesIDs(ctx context.Context, conn pgxConn, sql string, args ...interface{}) ([]SeriesID, error) {
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	if rows.Next() {
+		if err = rows.Scan(&ids); err != nil {
+			return nil, err
+		}
+	}
+	seriesIDs := make([]SeriesID, 0, len(ids))
+	for _, id := range ids {
+		seriesIDs = append(seriesIDs, SeriesID(id))
+	}
+	return seriesIDs, rows.Err()
+}
+
+func queryCount(ctx context.Context, conn pgxConn, count *int64, sql string, args ...interface{}) error {
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		if err = rows.Scan(count); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,121 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestDeleteSeries(t *testing.T) {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	selectors := [][]*prompb.LabelMatcher{
+		{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "cpu"}, {Type: prompb.LabelMatcher_EQ, Name: "job", Value: "a"}},
+		{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "cpu"}, {Type: prompb.LabelMatcher_EQ, Name: "mode", Value: "idle"}},
+	}
+	start, end := time.Unix(100, 0), time.Unix(200, 0)
+	seriesResults := []rowResults{
+		{{"cpu", []int64{2, 1}}},
+		{{"cpu", []int64{3, 2}}},
+		{{"cpu_table"}},
+		{{true}},
+	}
+
+	testCases := []struct {
+		name          string
+		dryRun        bool
+		results       []rowResults
+		execResult    pgconn.CommandTag
+		expected      SeriesDeletion
+		countedSQLs   []string
+		expectedExecs int
+		evicted       []SeriesID
+	}{
+		{
+			name:     "dry run",
+			dryRun:   true,
+			results:  append(seriesResults, rowResults{{int64(7)}}, rowResults{{int64(1)}}),
+			expected: SeriesDeletion{Metric: "cpu", Series: 3, Samples: 7, OrphanedSeries: 1},
+			countedSQLs: []string{
+				`SELECT count(*) FROM "prom_data"."cpu_table"`,
+				`SELECT count(*) FROM "prom_data_series"."cpu_table" s`,
+			},
+		},
+		{
+			name:          "delete",
+			results:       append(seriesResults, rowResults{{[]int64{1, 3}}}),
+			execResult:    pgconn.CommandTag("DELETE 7"),
+			expected:      SeriesDeletion{Metric: "cpu", Series: 3, Samples: 7, OrphanedSeries: 2},
+			countedSQLs:   []string{`DELETE FROM "prom_data_series"."cpu_table" s`},
+			expectedExecs: 1,
+			evicted:       []SeriesID{1, 3},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &mockPGXConn{QueryResults: c.results, ExecResult: c.execResult}
+			var evicted []SeriesID
+			deleter := &SeriesDeleter{conn: mock, evict: func(ids []SeriesID) { evicted = append(evicted, ids...) }}
+			deletions, err := deleter.DeleteSeries(context.Background(), selectors, start, end, c.dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(deletions, []SeriesDeletion{c.expected}) {
+				t.Errorf("unexpected deletions: got %+v wanted %+v", deletions, c.expected)
+			}
+			if !reflect.DeepEqual(evicted, c.evicted) {
+				t.Errorf("unexpected evicted series: got %v wanted %v", evicted, c.evicted)
+			}
+			if len(mock.ExecSQLs) != c.expectedExecs {
+				t.Fatalf("unexpected exec statements: %v", mock.ExecSQLs)
+			}
+			if c.expectedExecs > 0 && !reflect.DeepEqual(mock.ExecArgs[0], []interface{}{[]int64{1, 2, 3}, start, end}) {
+				t.Errorf("unexpected delete args: %v", mock.ExecArgs[0])
+			}
+			if !reflect.DeepEqual(mock.QueryArgs[3], []interface{}{`"prom_rollup"."cpu_table"`}) {
+				t.Errorf("unexpected rollup check: %v", mock.QueryArgs[3])
+			}
+			counted := mock.QuerySQLs[4:]
+			if len(counted) != len(c.countedSQLs) {
+				t.Fatalf("unexpected count queries: %v", counted)
+			}
+			for i, sql := range counted {
+				if !strings.HasPrefix(strings.TrimPrefix(sql, "WITH deleted_series AS (\n\t\t"), c.countedSQLs[i]) {
+					t.Errorf("unexpected query: got %q wanted prefix %q", sql, c.countedSQLs[i])
+				}
+				if !reflect.DeepEqual(mock.QueryArgs[4+i][0], []int64{1, 2, 3}) {
+					t.Errorf("unexpected series ids: %v", mock.QueryArgs[4+i][0])
+				}
+				if strings.Contains(sql, "prom_data_series") && !strings.Contains(sql, `"prom_rollup"."cpu_table" r`) {
+					t.Errorf("series with rolled up samples not kept: %q", sql)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteSeriesNoMatch(t *testing.T) {
+	mock := &mockPGXConn{}
+	deleter := &SeriesDeleter{conn: mock}
+	deletions, err := deleter.DeleteSeries(context.Background(), [][]*prompb.LabelMatcher{
+		{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "missing"}},
+	}, time.Unix(0, 0), time.Unix(1, 0), false)
+	if err != nil || len(deletions) != 0 {
+		t.Errorf("unexpected deletions: %v, %v", deletions, err)
+	}
+	if len(mock.ExecSQLs) != 0 {
+		t.Errorf("unexpected statements: %v", mock.ExecSQLs)
+	}
+}
@@ -225,7 +225,7 @@ func testConcurrentInsertSimple(t testing.TB, db *pgxpool.Pool, metric string) {
 		t.Fatal(err)
 	}
 	defer ingestor.Close()
-	_, err = ingestor.Ingest(metrics, NewWriteRequest())
+	_, err = ingestor.Ingest(context.Background(), metrics, NewWriteRequest())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -284,7 +284,7 @@ func testConcurrentInsertAdvanced(t testing.TB, db *pgxpool.Pool) {
 		t.Fatal(err)
 	}
 	defer ingestor.Close()
-	_, err = ingestor.Ingest(metrics, NewWriteRequest())
+	_, err = ingestor.Ingest(context.Background(), metrics, NewWriteRequest())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -5,6 +5,7 @@ package end_to_end_tests
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -158,7 +159,7 @@ func TestSQLChunkInterval(t *testing.T) {
 			t.Fatal(err)
 		}
 		defer ingestor.Close()
-		_, err = ingestor.Ingest(ts, NewWriteRequest())
+		_, err = ingestor.Ingest(context.Background(), ts, NewWriteRequest())
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -398,8 +399,8 @@ func TestSQLIngest(t *testing.T) {
 					t.Fatal(err)
 				}
 				defer ingestor.Close()
-				cnt, err := ingestor.Ingest(tcase.metrics, NewWriteRequest())
-				if err != nil && err != tcase.expectErr {
+				cnt, err := ingestor.Ingest(context.Background(), tcase.metrics, NewWriteRequest())
+				if err != nil && !errors.Is(err, tcase.expectErr) {
 					t.Fatalf("got an unexpected error %v", err)
 				}
 
@@ -472,7 +473,7 @@ func TestInsertCompressed(t *testing.T) {
 			t.Fatal(err)
 		}
 		defer ingestor.Close()
-		_, err = ingestor.Ingest(ts, NewWriteRequest())
+		_, err = ingestor.Ingest(context.Background(), ts, NewWriteRequest())
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -490,7 +491,7 @@ func TestInsertCompressed(t *testing.T) {
 			}
 		}
 		//ingest after compression
-		_, err = ingestor.Ingest(ts, NewWriteRequest())
+		_, err = ingestor.Ingest(context.Background(), ts, NewWriteRequest())
 		if err != nil {
 			t.Fatal(err)
 		}
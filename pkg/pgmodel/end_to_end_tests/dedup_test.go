@@ -0,0 +1,53 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package end_to_end_tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/allegro/bigcache"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestDedupSamples(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		metrics, _ := bigcache.NewBigCache(DefaultCacheConfig())
+		ingestor, err := NewPgxIngestorWithMetricCache(db, &MetricNameCache{Metrics: metrics}, &Cfg{DedupSamples: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ingestor.Close()
+
+		write := func(samples ...prompb.Sample) {
+			req := NewWriteRequest()
+			req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "dedup_test"}, {Name: "job", Value: "a"}},
+				Samples: samples,
+			})
+			if _, err := ingestor.Ingest(context.Background(), req.Timeseries, req); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		write(prompb.Sample{Timestamp: 1, Value: 1}, prompb.Sample{Timestamp: 2, Value: 2}, prompb.Sample{Timestamp: 2, Value: 2})
+		// A retry of the same samples, with a new one.
+		write(prompb.Sample{Timestamp: 1, Value: 1}, prompb.Sample{Timestamp: 2, Value: 2}, prompb.Sample{Timestamp: 3, Value: 3})
+
+		var samples int
+		err = db.QueryRow(context.Background(), "SELECT count(*) FROM prom_data.dedup_test").Scan(&samples)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if samples != 3 {
+			t.Errorf("unexpected number of samples: got %d wanted 3", samples)
+		}
+	})
+}
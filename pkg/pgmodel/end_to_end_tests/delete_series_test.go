@@ -0,0 +1,216 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package end_to_end_tests
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestDeleteSeries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ts := []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "first"}, {Name: "foo", Value: "bar"}},
+				Samples: []prompb.Sample{{Timestamp: 1000, Value: 0.1}, {Timestamp: 2000, Value: 0.2}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "first"}, {Name: "foo", Value: "baz"}},
+				Samples: []prompb.Sample{{Timestamp: 1000, Value: 0.3}, {Timestamp: 5000, Value: 0.4}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "second"}, {Name: "foo", Value: "bar"}},
+				Samples: []prompb.Sample{{Timestamp: 1000, Value: 0.5}},
+			},
+		}
+		ingestQueryTestDataset(db, t, ts)
+
+		deleter := NewSeriesDeleter(db, nil, 0)
+		selectors := [][]*prompb.LabelMatcher{{{Type: prompb.LabelMatcher_RE, Name: MetricNameLabelName, Value: "first|second"}}}
+		start, end := time.Unix(0, 0), time.Unix(3, 0)
+		expected := []SeriesDeletion{
+			{Metric: "first", Series: 2, Samples: 3, OrphanedSeries: 1},
+			{Metric: "second", Series: 1, Samples: 1, OrphanedSeries: 1},
+		}
+
+		dryRun, err := deleter.DeleteSeries(context.Background(), selectors, start, end, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(dryRun, expected) {
+			t.Errorf("unexpected dry run: got %+v wanted %+v", dryRun, expected)
+		}
+
+		deleted, err := deleter.DeleteSeries(context.Background(), selectors, start, end, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(deleted, expected) {
+			t.Errorf("unexpected deletions: got %+v wanted %+v", deleted, expected)
+		}
+
+		var samples, series int
+		if err = db.QueryRow(context.Background(), `SELECT count(*) FROM prom_data."first"`).Scan(&samples); err != nil {
+			t.Fatal(err)
+		}
+		if err = db.QueryRow(context.Background(), `SELECT count(*) FROM _prom_catalog.series`).Scan(&series); err != nil {
+			t.Fatal(err)
+		}
+		if samples != 1 || series != 1 {
+			t.Errorf("unexpected data left: %d samples, %d series", samples, series)
+		}
+	})
+}
+
+func TestDeleteSeriesWriteAgain(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		series := func(ts int64) []prompb.TimeSeries {
+			return []prompb.TimeSeries{{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "first"}, {Name: "foo", Value: "bar"}},
+				Samples: []prompb.Sample{{Timestamp: ts, Value: 0.1}},
+			}}
+		}
+		ingestor, err := NewPgxIngestor(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ingestor.Close()
+		if _, err = ingestor.Ingest(context.Background(), series(1000), NewWriteRequest()); err != nil {
+			t.Fatal(err)
+		}
+
+		deleter := NewSeriesDeleter(db, ingestor.EvictSeries, 0)
+		selectors := [][]*prompb.LabelMatcher{{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "first"}}}
+		if _, err = deleter.DeleteSeries(context.Background(), selectors, time.Unix(0, 0), time.Unix(3, 0), false); err != nil {
+			t.Fatal(err)
+		}
+
+		// The series deleted from the catalog is created again instead of
+		// its cached id being reused.
+		if _, err = ingestor.Ingest(context.Background(), series(5000), NewWriteRequest()); err != nil {
+			t.Fatal(err)
+		}
+		var samples int
+		err = db.QueryRow(context.Background(), `SELECT count(*) FROM prom_data."first" d
+			JOIN prom_data_series."first" s ON s.id = d.series_id`).Scan(&samples)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if samples != 1 {
+			t.Errorf("unexpected samples of the series written again: %d", samples)
+		}
+	})
+}
+
+func TestDeleteSeriesGracePeriod(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ts := []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "first"}, {Name: "foo", Value: "bar"}},
+				Samples: []prompb.Sample{{Timestamp: 1000, Value: 0.1}, {Timestamp: 5000, Value: 0.2}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "first"}, {Name: "foo", Value: "baz"}},
+				Samples: []prompb.Sample{{Timestamp: 1000, Value: 0.3}},
+			},
+		}
+		ingestQueryTestDataset(db, t, ts)
+		countSamples := func() int {
+			var samples int
+			if err := db.QueryRow(context.Background(), `SELECT count(*) FROM prom_data."first"`).Scan(&samples); err != nil {
+				t.Fatal(err)
+			}
+			return samples
+		}
+
+		deleter := NewSeriesDeleter(db, nil, time.Hour)
+		selectors := [][]*prompb.LabelMatcher{{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "first"}}}
+		deleted, err := deleter.DeleteSeries(context.Background(), selectors, time.Unix(0, 0), time.Unix(3, 0), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deleted) != 1 || deleted[0].Samples != 2 || deleted[0].Tombstone == 0 || deleted[0].PurgeAfter == nil {
+			t.Fatalf("unexpected deletions: %+v", deleted)
+		}
+		if samples := countSamples(); samples != 3 {
+			t.Errorf("samples deleted within the grace period: %d left", samples)
+		}
+		reader := NewPgxReader(db)
+		reader.EnableTombstones()
+		readSamples := func() int {
+			resp, err := reader.Read(context.Background(), &prompb.ReadRequest{Queries: []*prompb.Query{{
+				StartTimestampMs: 0,
+				EndTimestampMs:   10000,
+				Matchers:         selectors[0],
+			}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			samples := 0
+			for _, ts := range resp.Results[0].Timeseries {
+				samples += len(ts.Samples)
+			}
+			return samples
+		}
+		if samples := readSamples(); samples != 1 {
+			t.Errorf("tombstoned samples read: %d samples", samples)
+		}
+
+		// An undeleted tombstone is not purged.
+		restored, err := deleter.Undelete(context.Background(), []int64{deleted[0].Tombstone})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(restored) != 1 || restored[0].Metric != "first" || restored[0].Series != 2 {
+			t.Fatalf("unexpected undeleted tombstones: %+v", restored)
+		}
+		if samples := readSamples(); samples != 3 {
+			t.Errorf("undeleted samples not read: %d samples", samples)
+		}
+		if deleted, err = deleter.DeleteSeries(context.Background(), selectors, time.Unix(0, 0), time.Unix(3, 0), false); err != nil {
+			t.Fatal(err)
+		}
+		tombstones, err := deleter.Tombstones(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tombstones) != 1 || tombstones[0].ID != deleted[0].Tombstone {
+			t.Fatalf("unexpected tombstones: %+v", tombstones)
+		}
+
+		if _, err = db.Exec(context.Background(), `UPDATE _prom_catalog.series_tombstone SET purge_after = now()`); err != nil {
+			t.Fatal(err)
+		}
+		purged, err := deleter.PurgeTombstones(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []SeriesDeletion{{Metric: "first", Series: 2, Samples: 2, OrphanedSeries: 1, Tombstone: deleted[0].Tombstone}}
+		if !reflect.DeepEqual(purged, expected) {
+			t.Errorf("unexpected purge: got %+v wanted %+v", purged, expected)
+		}
+		if samples := countSamples(); samples != 1 {
+			t.Errorf("unexpected samples left: %d", samples)
+		}
+		if tombstones, err = deleter.Tombstones(context.Background()); err != nil || len(tombstones) != 0 {
+			t.Errorf("unexpected tombstones left: %+v, %v", tombstones, err)
+		}
+	})
+}
@@ -49,7 +49,7 @@ func TestSQLRetentionPeriod(t *testing.T) {
 			t.Fatal(err)
 		}
 		defer ingestor.Close()
-		_, err = ingestor.Ingest(ts, NewWriteRequest())
+		_, err = ingestor.Ingest(context.Background(), ts, NewWriteRequest())
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -143,7 +143,7 @@ func TestSQLDropChunk(t *testing.T) {
 			t.Fatal(err)
 		}
 		defer ingestor.Close()
-		_, err = ingestor.Ingest(ts, NewWriteRequest())
+		_, err = ingestor.Ingest(context.Background(), ts, NewWriteRequest())
 		if err != nil {
 			t.Error(err)
 		}
@@ -232,7 +232,7 @@ func TestSQLDropMetricChunk(t *testing.T) {
 			t.Fatal(err)
 		}
 		defer ingestor.Close()
-		_, err = ingestor.Ingest(ts, NewWriteRequest())
+		_, err = ingestor.Ingest(context.Background(), ts, NewWriteRequest())
 		if err != nil {
 			t.Error(err)
 		}
@@ -285,3 +285,104 @@ func TestSQLDropMetricChunk(t *testing.T) {
 
 	})
 }
+
+func TestSQLLabelRetentionPeriod(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		now := time.Now()
+		old := now.Add(-10 * 24 * time.Hour)
+
+		ts := make([]prompb.TimeSeries, 0)
+		for _, env := range []string{"prod", "dev", "other"} {
+			ts = append(ts, prompb.TimeSeries{
+				Labels: []prompb.Label{
+					{Name: MetricNameLabelName, Value: "test"},
+					{Name: "env", Value: env},
+				},
+				Samples: []prompb.Sample{
+					{Timestamp: int64(model.TimeFromUnixNano(old.UnixNano())), Value: 0.1},
+					{Timestamp: int64(model.TimeFromUnixNano(now.UnixNano())), Value: 0.2},
+				},
+			})
+		}
+		ingestor, err := NewPgxIngestor(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ingestor.Close()
+		if _, err = ingestor.Ingest(context.Background(), ts, NewWriteRequest()); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, q := range []string{
+			"SELECT prom_api.set_metric_retention_period('test', INTERVAL '5 days')",
+			"SELECT prom_api.set_label_retention_period('env', 'prod', INTERVAL '100 days')",
+			"SELECT prom_api.set_label_retention_period('env', 'dev', INTERVAL '7 days')",
+			"SELECT prom_api.set_label_retention_period('env', 'unused', INTERVAL '1 day')",
+		} {
+			if _, err := db.Exec(context.Background(), q); err != nil {
+				t.Fatal(err)
+			}
+		}
+		verifyRetentionPeriod(t, db, "test", time.Duration(5*24*time.Hour))
+
+		var metrics []string
+		err = db.QueryRow(context.Background(), "SELECT metrics FROM prom_info.label_retention WHERE key = 'env' AND value = 'prod'").Scan(&metrics)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(metrics) != 1 || metrics[0] != "test" {
+			t.Errorf("unexpected metrics of the override: %v", metrics)
+		}
+
+		if _, err = db.Exec(context.Background(), "CALL prom_api.drop_chunks()"); err != nil {
+			t.Fatal(err)
+		}
+
+		// The old chunk is kept for prod, and the old samples of the
+		// other series are deleted from it.
+		rows, err := db.Query(context.Background(), `SELECT s.env, count(*)
+		FROM prom_series.test s
+		INNER JOIN prom_data.test d ON (d.series_id = s.series_id)
+		GROUP BY 1
+		ORDER BY 1`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		counts := make(map[string]int)
+		for rows.Next() {
+			var (
+				env   string
+				count int
+			)
+			if err := rows.Scan(&env, &count); err != nil {
+				t.Fatal(err)
+			}
+			counts[env] = count
+		}
+		if counts["prod"] != 2 || counts["dev"] != 1 || counts["other"] != 1 {
+			t.Errorf("unexpected sample counts after drop_chunks: %v", counts)
+		}
+
+		// Without the overrides, the metric retention applies again.
+		for _, q := range []string{
+			"SELECT prom_api.reset_label_retention_period('env', 'prod')",
+			"SELECT prom_api.reset_label_retention_period('env', 'dev')",
+			"CALL prom_api.drop_chunks()",
+		} {
+			if _, err := db.Exec(context.Background(), q); err != nil {
+				t.Fatal(err)
+			}
+		}
+		count := 0
+		if err = db.QueryRow(context.Background(), "SELECT count(*) FROM prom_data.test").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 3 {
+			t.Errorf("unexpected row count without overrides: %v", count)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package end_to_end_tests
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestExportOpenMetrics(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ingestQueryTestDataset(db, t, []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "first"}, {Name: "foo", Value: "bar"}},
+				Samples: []prompb.Sample{{Timestamp: 1000, Value: 0.1}, {Timestamp: 2000, Value: 0.2}, {Timestamp: 3000, Value: 0.3}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "second"}, {Name: "foo", Value: "baz"}},
+				Samples: []prompb.Sample{{Timestamp: 1000, Value: 1}},
+			},
+		})
+
+		var out bytes.Buffer
+		w := NewOpenMetricsWriter(&out)
+		selectors := [][]*prompb.LabelMatcher{{{Type: prompb.LabelMatcher_EQ, Name: "foo", Value: "bar"}}}
+		stats, err := NewPgxReader(db).Export(context.Background(), selectors, time.Unix(2, 0), time.Unix(3, 0), time.Second, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if stats != (ExportStats{Windows: 2, Series: 2, Samples: 2}) {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+		expected := "first{foo=\"bar\"} 0.2 2\nfirst{foo=\"bar\"} 0.3 3\n# EOF\n"
+		if out.String() != expected {
+			t.Errorf("unexpected export: got %q wanted %q", out.String(), expected)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package end_to_end_tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/allegro/bigcache"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const fastIngestMetric = "fast_ingest_test"
+
+// createCountingTrigger adds a trigger counting the rows inserted into the
+// data table of metric.
+func createCountingTrigger(db *pgxpool.Pool, metric string) error {
+	_, err := db.Exec(context.Background(), fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS public.trigger_rows (n BIGINT);
+		INSERT INTO public.trigger_rows VALUES (0);
+		CREATE OR REPLACE FUNCTION public.count_trigger_rows() RETURNS TRIGGER AS $$
+		BEGIN
+			UPDATE public.trigger_rows SET n = n + 1;
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql;
+		CREATE TRIGGER count_rows BEFORE INSERT ON prom_data.%[1]s
+			FOR EACH ROW EXECUTE PROCEDURE public.count_trigger_rows();`, metric))
+	return err
+}
+
+func newFastIngestor(t testing.TB, db *pgxpool.Pool, fastIngest bool) *DBIngestor {
+	metrics, _ := bigcache.NewBigCache(DefaultCacheConfig())
+	ingestor, err := NewPgxIngestorWithMetricCache(db, &MetricNameCache{Metrics: metrics}, &Cfg{BypassTriggers: fastIngest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ingestor
+}
+
+func fastIngestRequest(from, count int) *prompb.WriteRequest {
+	req := NewWriteRequest()
+	samples := make([]prompb.Sample, count)
+	for i := range samples {
+		samples[i] = prompb.Sample{Timestamp: int64(from + i), Value: float64(i)}
+	}
+	req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+		Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: fastIngestMetric}},
+		Samples: samples,
+	})
+	return req
+}
+
+func TestFastIngestBypassesTriggers(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		if err := createMetricTableName(db, fastIngestMetric); err != nil {
+			t.Fatal(err)
+		}
+		if err := createCountingTrigger(db, fastIngestMetric); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, fastIngest := range []bool{false, true} {
+			ingestor := newFastIngestor(t, db, fastIngest)
+			req := fastIngestRequest(0, 10)
+			if _, err := ingestor.Ingest(context.Background(), req.Timeseries, req); err != nil {
+				t.Fatal(err)
+			}
+			ingestor.Close()
+		}
+
+		var samples, triggered int
+		err := db.QueryRow(context.Background(),
+			"SELECT (SELECT count(*) FROM prom_data."+fastIngestMetric+"), (SELECT n FROM public.trigger_rows)").Scan(&samples, &triggered)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if samples != 20 {
+			t.Errorf("unexpected number of samples: got %d wanted 20", samples)
+		}
+		if triggered != 10 {
+			t.Errorf("unexpected number of triggered rows: got %d wanted 10", triggered)
+		}
+	})
+}
+
+func benchmarkFastIngest(b *testing.B, fastIngest bool) {
+	b.StopTimer()
+	withDB(b, "bench_fast_ingest", func(db *pgxpool.Pool, t testing.TB) {
+		if err := createMetricTableName(db, fastIngestMetric); err != nil {
+			t.Fatal(err)
+		}
+		if err := createCountingTrigger(db, fastIngestMetric); err != nil {
+			t.Fatal(err)
+		}
+		ingestor := newFastIngestor(t, db, fastIngest)
+		defer ingestor.Close()
+
+		const samplesPerRequest = 1000
+		b.ResetTimer()
+		b.StartTimer()
+		for n := 0; n < b.N; n++ {
+			req := fastIngestRequest(n*samplesPerRequest, samplesPerRequest)
+			if _, err := ingestor.Ingest(context.Background(), req.Timeseries, req); err != nil {
+				t.Fatal(err)
+			}
+		}
+		b.StopTimer()
+	})
+}
+
+func BenchmarkIngestWithTriggers(b *testing.B) {
+	benchmarkFastIngest(b, false)
+}
+
+func BenchmarkIngestBypassingTriggers(b *testing.B) {
+	benchmarkFastIngest(b, true)
+}
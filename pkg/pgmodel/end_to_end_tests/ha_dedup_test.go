@@ -0,0 +1,79 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package end_to_end_tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestHADeduplication(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ingestor, err := NewPgxIngestor(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ingestor.Close()
+
+		// Every replica writes through its own connector.
+		cfg := HAConfig{LeaseTimeout: time.Second}
+		connectors := map[string]*HADeduplicator{
+			"a": NewHADeduplicator(db, ingestor, cfg),
+			"b": NewHADeduplicator(db, ingestor, cfg),
+		}
+		write := func(replica string, ts int64) uint64 {
+			req := NewWriteRequest()
+			req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+				Labels: []prompb.Label{
+					{Name: MetricNameLabelName, Value: "ha_test"},
+					{Name: DefaultHAReplicaLabel, Value: replica},
+					{Name: DefaultHAClusterLabel, Value: "c"},
+				},
+				Samples: []prompb.Sample{{Timestamp: ts, Value: 1}},
+			})
+			n, err := connectors[replica].Ingest(context.Background(), req.Timeseries, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return n
+		}
+
+		if write("a", 1) != 1 || write("b", 1) != 0 {
+			t.Fatal("the first replica did not take the lease")
+		}
+		if write("a", 2) != 1 {
+			t.Fatal("the leader lost its lease")
+		}
+
+		time.Sleep(1500 * time.Millisecond)
+		if write("b", 3) != 1 || write("a", 3) != 0 {
+			t.Fatal("the other replica did not take over the expired lease")
+		}
+
+		var samples, replicaLabels int
+		err = db.QueryRow(context.Background(), "SELECT count(*) FROM prom_data.ha_test").Scan(&samples)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if samples != 3 {
+			t.Errorf("unexpected number of samples: got %d wanted 3", samples)
+		}
+		err = db.QueryRow(context.Background(), "SELECT count(*) FROM _prom_catalog.label WHERE key = $1", DefaultHAReplicaLabel).Scan(&replicaLabels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if replicaLabels != 0 {
+			t.Errorf("replica label was stored")
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package end_to_end_tests
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+func TestSQLNativeHistograms(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		labels := []prompb.Label{
+			{Name: MetricNameLabelName, Value: "rpc_seconds"},
+			{Name: "job", Value: "api"},
+		}
+		histograms := []prompb.Histogram{
+			{
+				Count:          &prompb.Histogram_CountInt{CountInt: 5},
+				Sum:            3.5,
+				Schema:         2,
+				ZeroThreshold:  0.001,
+				ZeroCount:      &prompb.Histogram_ZeroCountInt{ZeroCountInt: 1},
+				NegativeSpans:  []prompb.BucketSpan{{Offset: -1, Length: 1}},
+				NegativeDeltas: []int64{1},
+				PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 2}, {Offset: 2, Length: 1}},
+				PositiveDeltas: []int64{1, 1, -1},
+				Timestamp:      10,
+			},
+			{
+				Count:          &prompb.Histogram_CountFloat{CountFloat: 2.5},
+				Sum:            1,
+				Schema:         -53,
+				ZeroCount:      &prompb.Histogram_ZeroCountFloat{},
+				PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 2}},
+				PositiveCounts: []float64{2, 0.5},
+				ResetHint:      prompb.Histogram_GAUGE,
+				Timestamp:      20,
+				CustomValues:   []float64{0.5},
+			},
+		}
+
+		ingestor, err := NewPgxIngestor(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ingestor.Close()
+		ts := []prompb.TimeSeries{{Labels: labels, Histograms: histograms}}
+		if _, err = ingestor.Ingest(context.Background(), ts, NewWriteRequest()); err != nil {
+			t.Fatalf("unexpected error while ingesting test dataset: %s", err)
+		}
+
+		r := NewPgxReader(db)
+		resp, err := r.Read(context.Background(), &prompb.ReadRequest{
+			Queries: []*prompb.Query{
+				{
+					Matchers: []*prompb.LabelMatcher{
+						{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "rpc_seconds"},
+					},
+					StartTimestampMs: 0,
+					EndTimestampMs:   30,
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []*prompb.TimeSeries{{Labels: labels, Histograms: histograms}}
+		if got := resp.Results[0].Timeseries; !reflect.DeepEqual(got, expected) {
+			t.Errorf("unexpected histograms:\ngot\n%v\nwanted\n%v", got, expected)
+		}
+	})
+}
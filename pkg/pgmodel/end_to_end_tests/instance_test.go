@@ -0,0 +1,46 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package end_to_end_tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
+)
+
+func TestInstanceHeartbeat(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		a := NewInstanceRegistry(db, InstanceInfo{ID: "a", Hostname: "host-a", Version: "v1", StartedAt: time.Now()})
+		b := NewInstanceRegistry(db, InstanceInfo{ID: "b", Hostname: "host-b", Version: "v2", StartedAt: time.Now()})
+
+		for _, r := range []*InstanceRegistry{a, b, a} {
+			if err := r.Heartbeat(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		instances, err := a.Instances()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(instances) != 2 {
+			t.Fatalf("unexpected number of instances: got %d wanted 2", len(instances))
+		}
+
+		if instances[0].ID != "a" || instances[0].Hostname != "host-a" || instances[1].ID != "b" || instances[1].Version != "v2" {
+			t.Errorf("unexpected instances: %+v", instances)
+		}
+
+		if instances[0].LastHeartbeat.Before(instances[0].StartedAt) {
+			t.Errorf("last heartbeat before start: %+v", instances[0])
+		}
+	})
+}
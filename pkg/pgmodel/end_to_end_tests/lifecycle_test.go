@@ -0,0 +1,119 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package end_to_end_tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/common/model"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestLifecyclePolicy(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		now := time.Now()
+		// Both old samples fall into the same 5 minute bucket.
+		old := now.Add(-10 * 24 * time.Hour).Truncate(5 * time.Minute)
+
+		ts := make([]prompb.TimeSeries, 0)
+		for _, instance := range []string{"a", "b"} {
+			ts = append(ts, prompb.TimeSeries{
+				Labels: []prompb.Label{
+					{Name: MetricNameLabelName, Value: "test"},
+					{Name: "instance", Value: instance},
+				},
+				Samples: []prompb.Sample{
+					{Timestamp: int64(model.TimeFromUnixNano(old.UnixNano())), Value: 1},
+					{Timestamp: int64(model.TimeFromUnixNano(old.Add(time.Second).UnixNano())), Value: 3},
+					{Timestamp: int64(model.TimeFromUnixNano(now.UnixNano())), Value: 5},
+				},
+			})
+		}
+		ingestor, err := NewPgxIngestor(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ingestor.Close()
+		if _, err = ingestor.Ingest(context.Background(), ts, NewWriteRequest()); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = db.Exec(context.Background(), "SELECT prom_api.set_metric_lifecycle_policy('test', INTERVAL '5 days', INTERVAL '5 minutes', INTERVAL '30 days')")
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifyRetentionPeriod(t, db, "test", time.Duration(5*24*time.Hour))
+
+		count := func(query string) int {
+			var c int
+			if err := db.QueryRow(context.Background(), query).Scan(&c); err != nil {
+				t.Fatal(err)
+			}
+			return c
+		}
+
+		// The raw samples are kept until they are rolled up.
+		if _, err = db.Exec(context.Background(), "CALL prom_api.drop_chunks()"); err != nil {
+			t.Fatal(err)
+		}
+		if c := count("SELECT count(*) FROM prom_data.test"); c != 6 {
+			t.Fatalf("samples dropped before being rolled up: got %d samples wanted 6", c)
+		}
+
+		lifecycle := NewLifecycleManager(db)
+		if err = lifecycle.Run(); err != nil {
+			t.Fatal(err)
+		}
+		policies, err := lifecycle.LifecyclePolicies()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(policies) != 1 || policies[0].RolledUpUntil == nil || policies[0].LastError != "" {
+			t.Fatalf("unexpected policies: %+v", policies)
+		}
+
+		// The samples of the current bucket are not rolled up yet.
+		if c := count("SELECT count(*) FROM prom_rollup.test WHERE count = 2 AND min = 1 AND max = 3 AND avg = 2"); c != 2 {
+			t.Errorf("unexpected rollups: got %d wanted 2", c)
+		}
+
+		if _, err = db.Exec(context.Background(), "CALL prom_api.drop_chunks()"); err != nil {
+			t.Fatal(err)
+		}
+		if c := count("SELECT count(*) FROM prom_data.test"); c != 2 {
+			t.Errorf("rolled up samples were not dropped: got %d samples wanted 2", c)
+		}
+		if c := count("SELECT count(*) FROM _prom_catalog.series"); c != 2 {
+			t.Errorf("series of the rollups were deleted: got %d series wanted 2", c)
+		}
+
+		var lag time.Duration
+		if err = db.QueryRow(context.Background(), "SELECT rollup_lag FROM prom_info.lifecycle_policy WHERE metric_name = 'test'").Scan(&lag); err != nil {
+			t.Fatal(err)
+		}
+		if lag <= 0 || lag > 15*time.Minute {
+			t.Errorf("unexpected rollup lag: %v", lag)
+		}
+
+		if _, err = db.Exec(context.Background(), "SELECT prom_api.set_metric_lifecycle_policy('test', INTERVAL '5 days', INTERVAL '1 hour', INTERVAL '30 days')"); err == nil {
+			t.Error("expected an error changing the rollup interval")
+		}
+
+		if _, err = db.Exec(context.Background(), "SELECT prom_api.reset_metric_lifecycle_policy('test')"); err != nil {
+			t.Fatal(err)
+		}
+		verifyRetentionPeriod(t, db, "test", time.Duration(90*24*time.Hour))
+		if c := count("SELECT count(*) FROM prom_info.lifecycle_policy"); c != 0 {
+			t.Errorf("policy was not removed")
+		}
+	})
+}
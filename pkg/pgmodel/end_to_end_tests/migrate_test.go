@@ -5,14 +5,20 @@ package end_to_end_tests
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/timescale/timescale-prometheus/pkg/internal/testhelpers"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
 )
 
 const (
-	expectedVersion = 1
+	expectedVersion = 11
 )
 
 func TestMigrate(t *testing.T) {
@@ -45,3 +51,231 @@ func TestMigrateTwice(t *testing.T) {
 		performMigrate(t, *testDatabase, connectURL)
 	})
 }
+
+func TestMigrateExtensionVersions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	testhelpers.WithDB(t, *testDatabase, testhelpers.NoSuperuser, func(db *pgxpool.Pool, t testing.TB, connectURL string) {
+		performMigrate(t, *testDatabase, connectURL)
+		var installed string
+		err := db.QueryRow(context.Background(), "SELECT extversion FROM pg_extension WHERE extname = 'timescaledb'").Scan(&installed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		migrateWith := func(cfg ExtensionUpdateConfig) error {
+			dbStd, err := sql.Open("pgx", connectURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dbStd.Close()
+			return MigrateWithOptions(dbStd, VersionInfo{Version: "testing-v0.0.1", CommitHash: "azxtestcommit"}, MigrateOptions{Extensions: cfg})
+		}
+
+		if err = migrateWith(ExtensionUpdateConfig{TimescaleDBVersion: installed}); err != nil {
+			t.Fatal(err)
+		}
+		if err = migrateWith(ExtensionUpdateConfig{TimescaleDBVersion: "0.0.0-missing"}); err == nil {
+			t.Error("expected an error for a pinned version not available")
+		}
+		if err = migrateWith(ExtensionUpdateConfig{Disabled: true}); err != nil {
+			t.Fatal(err)
+		}
+
+		var version string
+		err = db.QueryRow(context.Background(), "SELECT extversion FROM pg_extension WHERE extname = 'timescaledb'").Scan(&version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != installed {
+			t.Errorf("unexpected timescaledb version %s, expected %s", version, installed)
+		}
+	})
+}
+
+func TestMigrateConcurrently(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	testhelpers.WithDB(t, *testDatabase, testhelpers.NoSuperuser, func(db *pgxpool.Pool, t testing.TB, connectURL string) {
+		// Replicas starting at the same time take turns migrating.
+		const replicas = 3
+		errs := make(chan error, replicas)
+		for i := 0; i < replicas; i++ {
+			go func() {
+				dbStd, err := sql.Open("pgx", connectURL)
+				if err != nil {
+					errs <- err
+					return
+				}
+				defer dbStd.Close()
+				errs <- Migrate(dbStd, VersionInfo{Version: "testing-v0.0.1", CommitHash: "azxtestcommit"})
+			}()
+		}
+		for i := 0; i < replicas; i++ {
+			if err := <-errs; err != nil {
+				t.Error(err)
+			}
+		}
+
+		var version int64
+		var dirty bool
+		err := db.QueryRow(context.Background(), "SELECT version, dirty FROM prom_schema_migrations").Scan(&version, &dirty)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != expectedVersion || dirty {
+			t.Errorf("unexpected version %d, dirty %v", version, dirty)
+		}
+	})
+}
+
+func TestMigrateRepair(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	testhelpers.WithDB(t, *testDatabase, testhelpers.NoSuperuser, func(db *pgxpool.Pool, t testing.TB, connectURL string) {
+		performMigrate(t, *testDatabase, connectURL)
+
+		// Migrate closes the database it is given, so every call gets its own.
+		migrateErr := func() error {
+			dbStd, err := sql.Open("pgx", connectURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dbStd.Close()
+			return Migrate(dbStd, VersionInfo{Version: "testing-v0.0.1", CommitHash: "azxtestcommit"})
+		}
+		repair := func(token string) error {
+			dbStd, err := sql.Open("pgx", connectURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dbStd.Close()
+			return RepairMigrations(dbStd, token)
+		}
+		expectRepair := func(token string) {
+			var repairErr *MigrationRepairError
+			if err := migrateErr(); !errors.As(err, &repairErr) {
+				t.Fatalf("expected a repair error, got %v", err)
+			}
+			if repairErr.RepairToken() != token {
+				t.Fatalf("unexpected repair token: got %s wanted %s", repairErr.RepairToken(), token)
+			}
+			if err := repair("wrong"); err == nil {
+				t.Fatal("repair with the wrong token succeeded")
+			}
+			if err := repair(token); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err := db.Exec(context.Background(), "UPDATE prom_schema_migration_checksums SET checksum = 'modified' WHERE version = 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectRepair("checksums-1")
+		if err := migrateErr(); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = db.Exec(context.Background(), "UPDATE prom_schema_migrations SET dirty = true")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectRepair(fmt.Sprintf("dirty-%d", expectedVersion))
+
+		var version int64
+		var dirty bool
+		err = db.QueryRow(context.Background(), "SELECT version, dirty FROM prom_schema_migrations").Scan(&version, &dirty)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != expectedVersion-1 || dirty {
+			t.Errorf("unexpected version after repair: %d dirty %v", version, dirty)
+		}
+
+		if err := migrateErr(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestMigrateDown(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	testhelpers.WithDB(t, *testDatabase, testhelpers.NoSuperuser, func(db *pgxpool.Pool, t testing.TB, connectURL string) {
+		performMigrate(t, *testDatabase, connectURL)
+
+		ts := []prompb.TimeSeries{
+			{
+				Labels: []prompb.Label{
+					{Name: MetricNameLabelName, Value: "test"},
+					{Name: "test", Value: "test"},
+				},
+				Samples: []prompb.Sample{
+					{Timestamp: 1, Value: 0.1},
+					{Timestamp: 2, Value: 0.2},
+				},
+			},
+		}
+		ingestor, err := NewPgxIngestor(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ingestor.Ingest(context.Background(), ts, NewWriteRequest())
+		ingestor.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.Exec(context.Background(), "ANALYZE")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// MigrateDown closes the database it is given, so every call gets its own.
+		migrateDown := func(confirm string) (*DownMigrationReport, error) {
+			dbStd, err := sql.Open("pgx", connectURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dbStd.Close()
+			return MigrateDown(dbStd, 0, confirm)
+		}
+
+		report, err := migrateDown("wrong")
+		if !errors.Is(err, ErrDownMigrationNotConfirmed) {
+			t.Fatalf("expected ErrDownMigrationNotConfirmed, got %v", err)
+		}
+		if report.Database != *testDatabase || report.FromVersion != expectedVersion || report.SeriesCount != 1 {
+			t.Errorf("unexpected report: %+v", report)
+		}
+		if len(report.Metrics) != 1 || report.Metrics[0].Metric != "test" {
+			t.Errorf("unexpected metrics in report: %+v", report.Metrics)
+		}
+		if len(report.Steps) != expectedVersion || report.Steps[0].Version != expectedVersion {
+			t.Errorf("unexpected down migrations in report: %d", len(report.Steps))
+		}
+
+		var exists bool
+		err = db.QueryRow(context.Background(), "SELECT to_regclass('_prom_catalog.metric') IS NOT NULL").Scan(&exists)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !exists {
+			t.Fatal("unconfirmed down migration dropped the catalog")
+		}
+
+		if _, err = migrateDown(*testDatabase); err != nil {
+			t.Fatal(err)
+		}
+		err = db.QueryRow(context.Background(), "SELECT to_regclass('_prom_catalog.metric') IS NOT NULL").Scan(&exists)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists {
+			t.Error("confirmed down migration did not drop the catalog")
+		}
+	})
+}
@@ -2,6 +2,7 @@ package end_to_end_tests
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -526,7 +527,7 @@ func TestSQLQuery(t *testing.T) {
 		r := NewPgxReader(readOnly)
 		for _, c := range testCases {
 			tester.Run(c.name, func(t *testing.T) {
-				resp, err := r.Read(&c.readRequest)
+				resp, err := r.Read(context.Background(), &c.readRequest)
 
 				if err != nil && err != c.expectErr {
 					t.Fatalf("unexpected error returned:\ngot\n%s\nwanted\n%s", err, c.expectErr)
@@ -554,7 +555,7 @@ func ingestQueryTestDataset(db *pgxpool.Pool, t testing.TB, metrics []prompb.Tim
 	if err != nil {
 		t.Fatal(err)
 	}
-	cnt, err := ingestor.Ingest(metrics, NewWriteRequest())
+	cnt, err := ingestor.Ingest(context.Background(), metrics, NewWriteRequest())
 
 	if err != nil {
 		t.Fatalf("unexpected error while ingesting test dataset: %s", err)
@@ -735,6 +736,78 @@ func TestPromQL(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Test match empty on RE alternation",
+			readRequest: &prompb.ReadRequest{
+				Queries: []*prompb.Query{
+					{
+						Matchers: []*prompb.LabelMatcher{
+							{
+								Type:  prompb.LabelMatcher_RE,
+								Name:  "foo",
+								Value: "bar|",
+							},
+						},
+						StartTimestampMs: 90000,
+						EndTimestampMs:   160000,
+					},
+				},
+			},
+		},
+		{
+			name: "Test match empty on NRE alternation",
+			readRequest: &prompb.ReadRequest{
+				Queries: []*prompb.Query{
+					{
+						Matchers: []*prompb.LabelMatcher{
+							{
+								Type:  prompb.LabelMatcher_NRE,
+								Name:  "foo",
+								Value: "bar|",
+							},
+						},
+						StartTimestampMs: 90000,
+						EndTimestampMs:   160000,
+					},
+				},
+			},
+		},
+		{
+			name: "Test match non-empty on RE",
+			readRequest: &prompb.ReadRequest{
+				Queries: []*prompb.Query{
+					{
+						Matchers: []*prompb.LabelMatcher{
+							{
+								Type:  prompb.LabelMatcher_RE,
+								Name:  "foo",
+								Value: ".+",
+							},
+						},
+						StartTimestampMs: 90000,
+						EndTimestampMs:   160000,
+					},
+				},
+			},
+		},
+		{
+			name: "Test match non-empty on NEQ",
+			readRequest: &prompb.ReadRequest{
+				Queries: []*prompb.Query{
+					{
+						Matchers: []*prompb.LabelMatcher{
+							{
+								Type:  prompb.LabelMatcher_NEQ,
+								Name:  "foo",
+								Value: "",
+							},
+						},
+						StartTimestampMs: 90000,
+						EndTimestampMs:   160000,
+					},
+				},
+			},
+		},
 		{
 			name: "Test error regex matcher",
 			readRequest: &prompb.ReadRequest{
@@ -907,7 +980,7 @@ func TestPromQL(t *testing.T) {
 		r := NewPgxReader(readOnly)
 		for _, c := range testCases {
 			tester.Run(c.name, func(t *testing.T) {
-				connResp, connErr := r.Read(c.readRequest)
+				connResp, connErr := r.Read(context.Background(), c.readRequest)
 				promResp, promErr := promClient.Read(c.readRequest)
 
 				// If a query returns an error on both sides, its considered an
@@ -0,0 +1,50 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package end_to_end_tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestReadMissingMetricWarning(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ingestQueryTestDataset(db, t, generateSmallTimeseries())
+
+		ctx, warnings := WithQueryWarnings(context.Background())
+		resp, err := NewPgxReader(db).Read(ctx, &prompb.ReadRequest{Queries: []*prompb.Query{
+			{
+				Matchers:         []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "never_written"}},
+				StartTimestampMs: 0,
+				EndTimestampMs:   10000,
+			},
+			{
+				Matchers:         []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "firstMetric"}},
+				StartTimestampMs: 0,
+				EndTimestampMs:   10000,
+			},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Results[0].Timeseries) != 0 || len(resp.Results[1].Timeseries) == 0 {
+			t.Errorf("unexpected results: %v", resp.Results)
+		}
+
+		got := warnings.Warnings()
+		var missing *MissingMetricError
+		if len(got) != 1 || !errors.As(got[0], &missing) || missing.Metric != "never_written" {
+			t.Errorf("unexpected warnings: %v", got)
+		}
+	})
+}
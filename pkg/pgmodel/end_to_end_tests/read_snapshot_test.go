@@ -0,0 +1,57 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package end_to_end_tests
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestSnapshotReads(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ingestQueryTestDataset(db, t, []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "first"}, {Name: "job", Value: "api"}},
+				Samples: []prompb.Sample{{Timestamp: 1000, Value: 1}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "second"}, {Name: "job", Value: "api"}},
+				Samples: []prompb.Sample{{Timestamp: 1000, Value: 2}},
+			},
+		})
+
+		query := &prompb.Query{
+			StartTimestampMs: 0,
+			EndTimestampMs:   2000,
+			Matchers:         []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: "job", Value: "api"}},
+		}
+		reader := NewPgxReader(db)
+		expected, err := reader.Read(context.Background(), &prompb.ReadRequest{Queries: []*prompb.Query{query}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Statement timeouts are set with a batch, which must not keep
+		// the connection of the snapshot busy.
+		ctx := WithQueryLimits(context.Background(), QueryLimits{StatementTimeout: time.Minute})
+		reader.EnableSnapshotReads()
+		got, err := reader.Read(ctx, &prompb.ReadRequest{Queries: []*prompb.Query{query}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Results[0].Timeseries) != 2 || !reflect.DeepEqual(got, expected) {
+			t.Errorf("unexpected snapshot read: got %v wanted %v", got, expected)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package end_to_end_tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestSchemaDocDescribe(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ingestor, err := NewPgxIngestor(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ingestor.Close()
+
+		ts := []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "cpu_usage"}},
+				Samples: []prompb.Sample{{Timestamp: 1, Value: 0.1}},
+			},
+		}
+		if _, err := ingestor.Ingest(context.Background(), ts, NewWriteRequest()); err != nil {
+			t.Fatal(err)
+		}
+		if err := ingestor.CompleteMetricCreation(); err != nil {
+			t.Fatal(err)
+		}
+
+		desc, err := NewSchemaDocReader(db).Describe()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if desc.MigrationVersion != expectedVersion || desc.Dirty {
+			t.Errorf("unexpected migration version: %d dirty %v", desc.MigrationVersion, desc.Dirty)
+		}
+
+		var catalog *SchemaObjects
+		for i := range desc.Schemas {
+			if desc.Schemas[i].Name == "_prom_catalog" {
+				catalog = &desc.Schemas[i]
+			}
+			if desc.Schemas[i].Name == "prom_data" {
+				t.Errorf("per-metric schema listed with the other schemas")
+			}
+		}
+		if catalog == nil {
+			t.Fatalf("catalog schema not described: %+v", desc.Schemas)
+		}
+
+		foundSeries := false
+		for _, table := range catalog.Tables {
+			if table.Name == "series" {
+				foundSeries = len(table.Columns) == 3
+			}
+		}
+		if !foundSeries {
+			t.Errorf("series table not described: %+v", catalog.Tables)
+		}
+		if len(catalog.Functions) == 0 {
+			t.Errorf("catalog functions not described")
+		}
+
+		if len(desc.Metrics) != 1 {
+			t.Fatalf("unexpected metrics: %+v", desc.Metrics)
+		}
+		m := desc.Metrics[0]
+		if m.Metric != "cpu_usage" || !m.DataTable || !m.SeriesTable || !m.MetricView || !m.SeriesView {
+			t.Errorf("unexpected metric objects: %+v", m)
+		}
+	})
+}
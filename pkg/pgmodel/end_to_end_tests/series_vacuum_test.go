@@ -0,0 +1,69 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package end_to_end_tests
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestSeriesVacuum(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ingestQueryTestDataset(db, t, []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "first"}, {Name: "foo", Value: "bar"}},
+				Samples: []prompb.Sample{{Timestamp: 1000, Value: 0.1}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "first"}, {Name: "foo", Value: "baz"}},
+				Samples: []prompb.Sample{{Timestamp: 2000, Value: 0.2}},
+			},
+		})
+		// The samples of the first series expired.
+		if _, err := db.Exec(context.Background(), `DELETE FROM prom_data."first" WHERE time < to_timestamp(2)`); err != nil {
+			t.Fatal(err)
+		}
+
+		var evicted []SeriesID
+		vacuum := NewSeriesVacuum(db, func(ids []SeriesID) { evicted = append(evicted, ids...) }, time.Nanosecond)
+		// The first run only records the series to vacuum from then on.
+		vacuumed, err := vacuum.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(vacuumed) != 0 {
+			t.Errorf("first run vacuumed series: %v", vacuumed)
+		}
+
+		time.Sleep(time.Millisecond)
+		vacuumed, err = vacuum.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := []VacuumedSeries{{Metric: "first", Series: 1}}; !reflect.DeepEqual(vacuumed, expected) {
+			t.Errorf("unexpected vacuumed series: got %v wanted %v", vacuumed, expected)
+		}
+		if len(evicted) != 1 {
+			t.Errorf("unexpected evicted series: %v", evicted)
+		}
+
+		var series int
+		if err = db.QueryRow(context.Background(), `SELECT count(*) FROM _prom_catalog.series`).Scan(&series); err != nil {
+			t.Fatal(err)
+		}
+		if series != 1 {
+			t.Errorf("unexpected series left: %d", series)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package end_to_end_tests
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/allegro/bigcache"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/prometheus/pkg/value"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestStaleMarkerLiveness(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		metrics, _ := bigcache.NewBigCache(DefaultCacheConfig())
+		ingestor, err := NewPgxIngestorWithMetricCache(db, &MetricNameCache{Metrics: metrics}, &Cfg{StaleMarkers: StaleMarkersLiveness})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ingestor.Close()
+
+		stale := math.Float64frombits(value.StaleNaN)
+		req := NewWriteRequest()
+		req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+			Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "liveness_test"}},
+			Samples: []prompb.Sample{{Timestamp: 10, Value: 1}, {Timestamp: 20, Value: stale}, {Timestamp: 30, Value: 3}, {Timestamp: 40, Value: stale}},
+		})
+		if _, err = ingestor.Ingest(context.Background(), req.Timeseries, req); err != nil {
+			t.Fatal(err)
+		}
+
+		var stored, recorded int
+		err = db.QueryRow(context.Background(),
+			"SELECT (SELECT count(*) FROM prom_data.liveness_test), (SELECT count(*) FROM _prom_catalog.series_liveness WHERE stale_at = 'epoch'::timestamptz + INTERVAL '40 ms')").
+			Scan(&stored, &recorded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored != 2 || recorded != 1 {
+			t.Fatalf("unexpected storage: %d samples, %d liveness records", stored, recorded)
+		}
+
+		reader := NewPgxReader(db)
+		reader.EnableLivenessMarkers()
+		query := func(start, end int64) []prompb.Sample {
+			resp, err := reader.Read(context.Background(), &prompb.ReadRequest{Queries: []*prompb.Query{{
+				StartTimestampMs: start,
+				EndTimestampMs:   end,
+				Matchers:         []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "liveness_test"}},
+			}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Results[0].Timeseries) != 1 {
+				t.Fatalf("unexpected series: %v", resp.Results[0].Timeseries)
+			}
+			return resp.Results[0].Timeseries[0].Samples
+		}
+
+		samples := query(0, 100)
+		if len(samples) != 3 || samples[2].Timestamp != 40 || !value.IsStaleNaN(samples[2].Value) {
+			t.Errorf("stale marker not read back: %v", samples)
+		}
+		if samples = query(0, 35); len(samples) != 2 {
+			t.Errorf("stale marker read outside of the range: %v", samples)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package end_to_end_tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestMetricStats(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ingestor, err := NewPgxIngestor(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ingestor.Close()
+
+		ts := []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "first"}, {Name: "foo", Value: "bar"}},
+				Samples: []prompb.Sample{{Timestamp: 1, Value: 0.1}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "first"}, {Name: "foo", Value: "baz"}},
+				Samples: []prompb.Sample{{Timestamp: 1, Value: 0.2}, {Timestamp: 2, Value: 0.3}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "second"}},
+				Samples: []prompb.Sample{{Timestamp: 1, Value: 0.4}},
+			},
+		}
+		if _, err := ingestor.Ingest(context.Background(), ts, NewWriteRequest()); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := ingestor.IngestedSamples(); got["first"] != 3 || got["second"] != 1 {
+			t.Errorf("unexpected ingested samples: %v", got)
+		}
+
+		stats, err := NewStatsReader(db).MetricStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(stats) != 2 {
+			t.Fatalf("unexpected number of metrics: got %d wanted 2", len(stats))
+		}
+		if stats[0].Name != "first" || stats[0].SeriesCount != 2 || stats[1].Name != "second" || stats[1].SeriesCount != 1 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+		if stats[0].TotalBytes <= 0 {
+			t.Errorf("expected a non-zero size: %+v", stats[0])
+		}
+
+		storage, err := NewStatsReader(db).StorageStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(storage) != 2 || storage[0].SeriesCount != 2 || storage[0].Chunks != 1 || storage[0].CompressedChunks != 0 {
+			t.Errorf("unexpected storage stats: %+v", storage)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package end_to_end_tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	. "github.com/timescale/timescale-prometheus/pkg/pgmodel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestUpgradeAdvisor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ingestor, err := NewPgxIngestor(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ingestor.Close()
+
+		ts := []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "cpu_usage"}},
+				Samples: []prompb.Sample{{Timestamp: 1, Value: 0.1}},
+			},
+		}
+		if _, err := ingestor.Ingest(context.Background(), ts, NewWriteRequest()); err != nil {
+			t.Fatal(err)
+		}
+
+		advice, err := NewUpgradeAdvisor(db).Advise()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !advice.Installed || advice.InstalledVersion != expectedVersion || advice.TargetVersion != expectedVersion {
+			t.Errorf("unexpected versions: %+v", advice)
+		}
+		if advice.PostgresVersion == "" || advice.TimescaleDBVersion == "" {
+			t.Errorf("missing PostgreSQL or TimescaleDB version: %+v", advice)
+		}
+		if advice.CatalogBytes == 0 || advice.DataBytes == 0 {
+			t.Errorf("missing catalog or data size: %+v", advice)
+		}
+		if len(advice.Pending) != 0 {
+			t.Errorf("unexpected pending migrations: %+v", advice.Pending)
+		}
+	})
+}
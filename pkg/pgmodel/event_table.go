@@ -0,0 +1,50 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/timescale/timescale-prometheus/pkg/events"
+)
+
+const insertEventSQL = `INSERT INTO ` + catalogSchema + `.event(time, type, instance_id, attributes) VALUES ($1, $2, $3, $4)`
+
+// EventTableSink records the operational events in the event table of the
+// catalog.
+type EventTableSink struct {
+	conn pgxConn
+}
+
+// NewEventTableSink returns a sink inserting the events with c.
+func NewEventTableSink(c *pgxpool.Pool) *EventTableSink {
+	return &EventTableSink{
+		conn: &pgxConnImpl{
+			conn: c,
+		},
+	}
+}
+
+// Name implements events.Sink.
+func (s *EventTableSink) Name() string {
+	return "table"
+}
+
+// Send implements events.Sink.
+func (s *EventTableSink) Send(e events.Event) error {
+	attributes := e.Attributes
+	if attributes == nil {
+		attributes = map[string]string{}
+	}
+	data, err := json.Marshal(attributes)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn.Exec(context.Background(), insertEventSQL, e.Time, string(e.Type), e.Instance, string(data))
+	return err
+}
@@ -0,0 +1,37 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/events"
+)
+
+func TestEventTableSink(t *testing.T) {
+	now := time.Unix(1000, 0)
+	mock := &mockPGXConn{}
+	s := &EventTableSink{conn: mock}
+
+	err := s.Send(events.Event{Time: now, Type: events.MetricTableCreated, Instance: "id", Attributes: map[string]string{"metric": "up"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Send(events.Event{Time: now, Type: events.CircuitBreakerReset}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mock.ExecSQLs) != 2 || mock.ExecSQLs[0] != insertEventSQL {
+		t.Fatalf("unexpected SQL: %v", mock.ExecSQLs)
+	}
+	expected := [][]interface{}{
+		{now, "metric_table_created", "id", `{"metric":"up"}`},
+		{now, "circuit_breaker_reset", "", `{}`},
+	}
+	if !reflect.DeepEqual(mock.ExecArgs, expected) {
+		t.Errorf("unexpected args:\ngot\n%v\nwanted\n%v", mock.ExecArgs, expected)
+	}
+}
@@ -0,0 +1,160 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/value"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// ExportWriter writes the series exported by DBReader.Export.
+type ExportWriter interface {
+	// WriteSeries writes the samples a series has in the current window.
+	WriteSeries(*prompb.TimeSeries) error
+	// FlushWindow is called once all the series of the window from mint to
+	// maxt, exclusive, were written.
+	FlushWindow(mint, maxt int64) error
+}
+
+// ExportStats counts what an export wrote.
+type ExportStats struct {
+	Windows int
+	Series  int
+	Samples int
+}
+
+// Export reads the series matching any of the selectors between start and
+// end, inclusive, and writes them to w. The range is split in windows aligned
+// on multiples of window, read and flushed one after the other so that a
+// single window has to fit in memory. A window of 0 exports the whole range
+// at once. A series matching several selectors is written once per selector.
+func (r *DBReader) Export(ctx context.Context, selectors [][]*prompb.LabelMatcher, start, end time.Time, window time.Duration, w ExportWriter) (ExportStats, error) {
+	var stats ExportStats
+	mint, maxt := timestamp(start), timestamp(end)
+	if maxt < mint {
+		return stats, fmt.Errorf("end timestamp must not be before start time")
+	}
+	step := window.Milliseconds()
+
+	for windowStart := mint; windowStart <= maxt; {
+		windowEnd := maxt + 1
+		if step > 0 {
+			windowEnd = windowStart - windowStart%step + step
+			if windowEnd > maxt+1 {
+				windowEnd = maxt + 1
+			}
+		}
+
+		for _, matchers := range selectors {
+			query := &prompb.Query{
+				StartTimestampMs: windowStart,
+				EndTimestampMs:   windowEnd - 1,
+				Matchers:         matchers,
+			}
+			err := r.db.QueryStreamed(ctx, query, func(ts *prompb.TimeSeries) error {
+				if len(ts.Samples) == 0 {
+					return nil
+				}
+				stats.Series++
+				stats.Samples += len(ts.Samples)
+				return w.WriteSeries(ts)
+			})
+			if err != nil {
+				return stats, err
+			}
+		}
+		if err := w.FlushWindow(windowStart, windowEnd); err != nil {
+			return stats, err
+		}
+		stats.Windows++
+		windowStart = windowEnd
+	}
+	return stats, nil
+}
+
+func timestamp(t time.Time) int64 {
+	return t.Unix()*1000 + int64(t.Nanosecond())/int64(time.Millisecond)
+}
+
+var openMetricsEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// OpenMetricsWriter writes the exported series in the OpenMetrics text
+// format, one sample per line. Staleness markers cannot be represented in
+// the format and are left out.
+type OpenMetricsWriter struct {
+	w *bufio.Writer
+}
+
+// NewOpenMetricsWriter returns an OpenMetricsWriter writing to w. Close must
+// be called once the export is done to terminate the exposition.
+func NewOpenMetricsWriter(w io.Writer) *OpenMetricsWriter {
+	return &OpenMetricsWriter{w: bufio.NewWriter(w)}
+}
+
+// WriteSeries implements ExportWriter.
+func (o *OpenMetricsWriter) WriteSeries(ts *prompb.TimeSeries) error {
+	name := ""
+	labels := make([]prompb.Label, 0, len(ts.Labels))
+	for _, l := range ts.Labels {
+		if l.Name == MetricNameLabelName {
+			name = l.Value
+			continue
+		}
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+
+	var series strings.Builder
+	series.WriteString(name)
+	if len(labels) > 0 {
+		series.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				series.WriteByte(',')
+			}
+			series.WriteString(l.Name)
+			series.WriteString(`="`)
+			series.WriteString(openMetricsEscaper.Replace(l.Value))
+			series.WriteByte('"')
+		}
+		series.WriteByte('}')
+	}
+	prefix := series.String()
+
+	for _, s := range ts.Samples {
+		if value.IsStaleNaN(s.Value) {
+			continue
+		}
+		_, err := fmt.Fprintf(o.w, "%s %s %s\n", prefix,
+			strconv.FormatFloat(s.Value, 'g', -1, 64),
+			strconv.FormatFloat(float64(s.Timestamp)/1000, 'f', -1, 64))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FlushWindow implements ExportWriter.
+func (o *OpenMetricsWriter) FlushWindow(_, _ int64) error {
+	return o.w.Flush()
+}
+
+// Close terminates the exposition.
+func (o *OpenMetricsWriter) Close() error {
+	if _, err := o.w.WriteString("# EOF\n"); err != nil {
+		return err
+	}
+	return o.w.Flush()
+}
@@ -0,0 +1,145 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/value"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// exportQuerier returns the samples of its series within the queried range.
+type exportQuerier struct {
+	series []prompb.TimeSeries
+}
+
+func (q *exportQuerier) Query(context.Context, *prompb.Query) ([]*prompb.TimeSeries, error) {
+	panic("not implemented")
+}
+
+func (q *exportQuerier) QueryStreamed(_ context.Context, query *prompb.Query, process func(*prompb.TimeSeries) error) error {
+	for _, s := range q.series {
+		ts := &prompb.TimeSeries{Labels: s.Labels}
+		for _, sample := range s.Samples {
+			if sample.Timestamp >= query.StartTimestampMs && sample.Timestamp <= query.EndTimestampMs {
+				ts.Samples = append(ts.Samples, sample)
+			}
+		}
+		if err := process(ts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *exportQuerier) HealthCheck() error {
+	return nil
+}
+
+type windowRecorder struct {
+	windows []string
+}
+
+func (r *windowRecorder) WriteSeries(*prompb.TimeSeries) error {
+	return nil
+}
+
+func (r *windowRecorder) FlushWindow(mint, maxt int64) error {
+	r.windows = append(r.windows, time.Unix(0, mint*int64(time.Millisecond)).UTC().Format("15:04")+"-"+
+		time.Unix(0, maxt*int64(time.Millisecond)).UTC().Format("15:04"))
+	return nil
+}
+
+func TestExportWindows(t *testing.T) {
+	querier := &exportQuerier{series: []prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "first"}},
+		Samples: []prompb.Sample{{Timestamp: 1800000, Value: 1}, {Timestamp: 7200000, Value: 2}, {Timestamp: 9000000, Value: 3}},
+	}}}
+	reader := &DBReader{db: querier}
+	selectors := [][]*prompb.LabelMatcher{{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "first"}}}
+
+	testCases := []struct {
+		name    string
+		window  time.Duration
+		windows []string
+		stats   ExportStats
+	}{
+		{
+			name:    "aligned windows",
+			window:  2 * time.Hour,
+			windows: []string{"00:30-02:00", "02:00-02:30"},
+			stats:   ExportStats{Windows: 2, Series: 2, Samples: 2},
+		},
+		{
+			name:    "single window",
+			windows: []string{"00:30-02:30"},
+			stats:   ExportStats{Windows: 1, Series: 1, Samples: 2},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			var recorder windowRecorder
+			// The end is inclusive.
+			stats, err := reader.Export(context.Background(), selectors, time.Unix(1800, 0), time.Unix(8999, 999000000), c.window, &recorder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats != c.stats {
+				t.Errorf("unexpected stats: got %+v wanted %+v", stats, c.stats)
+			}
+			if !reflect.DeepEqual(recorder.windows, c.windows) {
+				t.Errorf("unexpected windows: got %v wanted %v", recorder.windows, c.windows)
+			}
+		})
+	}
+
+	if _, err := reader.Export(context.Background(), selectors, time.Unix(10, 0), time.Unix(0, 0), 0, &windowRecorder{}); err == nil {
+		t.Error("expected an error for an end before the start")
+	}
+}
+
+func TestOpenMetricsWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewOpenMetricsWriter(&out)
+	series := []*prompb.TimeSeries{
+		{
+			Labels: []prompb.Label{{Name: MetricNameLabelName, Value: "first"}, {Name: "path", Value: "C:\\tmp \"x\"\n"}, {Name: "job", Value: "api"}},
+			Samples: []prompb.Sample{
+				{Timestamp: 1000, Value: 0.1},
+				{Timestamp: 1500, Value: math.Float64frombits(value.StaleNaN)},
+				{Timestamp: 2001, Value: math.Inf(1)},
+			},
+		},
+		{
+			Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "second"}},
+			Samples: []prompb.Sample{{Timestamp: 3000, Value: 3}},
+		},
+	}
+	for _, ts := range series {
+		if err := w.WriteSeries(ts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.FlushWindow(0, 4000); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `first{job="api",path="C:\\tmp \"x\"\n"} 0.1 1
+first{job="api",path="C:\\tmp \"x\"\n"} +Inf 2.001
+second 3 3
+# EOF
+`
+	if out.String() != expected {
+		t.Errorf("unexpected exposition:\ngot\n%s\nwanted\n%s", out.String(), expected)
+	}
+}
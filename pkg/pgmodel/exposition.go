@@ -0,0 +1,73 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/textparse"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// ParseExposition parses metrics pushed in the Prometheus text exposition
+// format, or in OpenMetrics when contentType says so, into a write request
+// for Ingest. The samples without a timestamp are stamped with now, and the
+// grouping labels override the labels of every series. The samples of the
+// same series are gathered into a single time series.
+func ParseExposition(data []byte, contentType string, now time.Time, grouping labels.Labels) (*prompb.WriteRequest, error) {
+	var (
+		p         = textparse.New(data, contentType)
+		req       = NewWriteRequest()
+		series    = make(map[string]int)
+		defaultTs = now.UnixNano() / int64(time.Millisecond)
+		lset      labels.Labels
+	)
+	for {
+		entry, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			FinishWriteRequest(req)
+			return nil, fmt.Errorf("parsing pushed metrics: %w", err)
+		}
+		if entry != textparse.EntrySeries {
+			continue
+		}
+
+		_, ts, v := p.Series()
+		t := defaultTs
+		if ts != nil {
+			t = *ts
+		}
+		lset = lset[:0]
+		p.Metric(&lset)
+		if len(grouping) > 0 {
+			b := labels.NewBuilder(lset)
+			for _, l := range grouping {
+				b.Set(l.Name, l.Value)
+			}
+			lset = b.Labels()
+		}
+
+		key := lset.String()
+		i, ok := series[key]
+		if !ok {
+			i = len(req.Timeseries)
+			series[key] = i
+			pbLabels := make([]prompb.Label, 0, len(lset))
+			for _, l := range lset {
+				pbLabels = append(pbLabels, prompb.Label{Name: l.Name, Value: l.Value})
+			}
+			req.Timeseries = append(req.Timeseries, prompb.TimeSeries{Labels: pbLabels})
+		}
+		req.Timeseries[i].Samples = append(req.Timeseries[i].Samples, prompb.Sample{Timestamp: t, Value: v})
+	}
+	return req, nil
+}
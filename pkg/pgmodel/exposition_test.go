@@ -0,0 +1,98 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestParseExposition(t *testing.T) {
+	now := time.Unix(100, 0)
+	testCases := []struct {
+		name        string
+		data        string
+		contentType string
+		grouping    labels.Labels
+		expected    []prompb.TimeSeries
+		err         bool
+	}{
+		{
+			name: "text format",
+			data: `# HELP job_duration_seconds Duration of the job.
+# TYPE job_duration_seconds gauge
+job_duration_seconds{step="load"} 12.5
+job_duration_seconds{step="load"} 13 200000
+job_last_success 1.5e9 150000
+`,
+			contentType: "text/plain; version=0.0.4",
+			expected: []prompb.TimeSeries{
+				{
+					Labels:  []prompb.Label{{Name: "__name__", Value: "job_duration_seconds"}, {Name: "step", Value: "load"}},
+					Samples: []prompb.Sample{{Timestamp: 100000, Value: 12.5}, {Timestamp: 200000, Value: 13}},
+				},
+				{
+					Labels:  []prompb.Label{{Name: "__name__", Value: "job_last_success"}},
+					Samples: []prompb.Sample{{Timestamp: 150000, Value: 1.5e9}},
+				},
+			},
+		},
+		{
+			name:        "grouping labels override",
+			data:        "backup_size_bytes{job=\"other\",disk=\"a\"} 42\n",
+			contentType: "text/plain",
+			grouping:    labels.FromStrings("job", "backup", "instance", "db1"),
+			expected: []prompb.TimeSeries{
+				{
+					Labels: []prompb.Label{
+						{Name: "__name__", Value: "backup_size_bytes"},
+						{Name: "disk", Value: "a"},
+						{Name: "instance", Value: "db1"},
+						{Name: "job", Value: "backup"},
+					},
+					Samples: []prompb.Sample{{Timestamp: 100000, Value: 42}},
+				},
+			},
+		},
+		{
+			name:        "OpenMetrics",
+			data:        "# TYPE jobs counter\njobs_total 3 150.5\n# EOF\n",
+			contentType: "application/openmetrics-text; version=0.0.1",
+			expected: []prompb.TimeSeries{
+				{
+					Labels:  []prompb.Label{{Name: "__name__", Value: "jobs_total"}},
+					Samples: []prompb.Sample{{Timestamp: 150500, Value: 3}},
+				},
+			},
+		},
+		{
+			name:        "invalid",
+			data:        "job_duration_seconds{step=load} 1\n",
+			contentType: "text/plain",
+			err:         true,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			req, err := ParseExposition([]byte(c.data), c.contentType, now, c.grouping)
+			if c.err {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(req.Timeseries, c.expected) {
+				t.Errorf("unexpected series:\ngot\n%v\nwanted\n%v", req.Timeseries, c.expected)
+			}
+		})
+	}
+}
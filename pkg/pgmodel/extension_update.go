@@ -0,0 +1,123 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const (
+	timescaleExtension = "timescaledb"
+	extraExtension     = "timescale_prometheus_extra"
+
+	extensionVersionsSQL = `SELECT COALESCE(e.installed_version, ''), e.default_version,
+		EXISTS (SELECT 1 FROM pg_available_extension_versions v WHERE v.name = e.name AND v.version = $2)
+	FROM pg_available_extensions e WHERE e.name = $1`
+	extensionUpdateSQL = "ALTER EXTENSION %s UPDATE TO '%s'"
+
+	// defaultMaxIdleConns is the default of database/sql, restored after
+	// the idle connections are closed.
+	defaultMaxIdleConns = 2
+)
+
+// ExtensionUpdateConfig tells Migrate how to update the timescaledb and
+// timescale_prometheus_extra extensions already installed.
+type ExtensionUpdateConfig struct {
+	// Disabled forbids the updates: the available ones are only logged.
+	Disabled bool
+	// TimescaleDBVersion and ExtraVersion pin the versions of the
+	// extensions. Empty updates them to their default version, the newest
+	// one installed on the server.
+	TimescaleDBVersion string
+	ExtraVersion       string
+}
+
+// MigrateOptions are the options of MigrateWithOptions.
+type MigrateOptions struct {
+	Extensions ExtensionUpdateConfig
+}
+
+// extensionVersions are the versions of an extension on the server.
+type extensionVersions struct {
+	// installed is empty if the extension is not installed.
+	installed string
+	// available is the default version of the extension.
+	available string
+	// pinnedAvailable tells whether the pinned version is installed on the
+	// server.
+	pinnedAvailable bool
+}
+
+// extensionUpdateTarget returns the version an extension must be updated to,
+// or an empty string if it must not be updated.
+func extensionUpdateTarget(name string, v extensionVersions, pinned string, cfg ExtensionUpdateConfig) (string, error) {
+	if v.installed == "" {
+		return "", nil
+	}
+	target := v.available
+	if pinned != "" {
+		if !v.pinnedAvailable {
+			return "", fmt.Errorf("the pinned version %s of the %s extension is not available on the server", pinned, name)
+		}
+		target = pinned
+	}
+	if target == v.installed {
+		return "", nil
+	}
+	if cfg.Disabled {
+		migrateLogger.Info("msg", "Extension update available but updates are disabled", "extension", name, "installed", v.installed, "available", target)
+		return "", nil
+	}
+	return target, nil
+}
+
+// updateExtension updates an installed extension to its default or pinned
+// version. conn must be a new session for timescaledb, which cannot be
+// updated once its library is loaded in the session.
+func updateExtension(conn *sql.Conn, name, pinned string, cfg ExtensionUpdateConfig) error {
+	var v extensionVersions
+	err := conn.QueryRowContext(context.Background(), extensionVersionsSQL, name, pinned).Scan(&v.installed, &v.available, &v.pinnedAvailable)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read the versions of the %s extension: %w", name, err)
+	}
+	target, err := extensionUpdateTarget(name, v, pinned, cfg)
+	if err != nil || target == "" {
+		return err
+	}
+	if _, err = conn.ExecContext(context.Background(), fmt.Sprintf(extensionUpdateSQL, name, target)); err != nil {
+		return fmt.Errorf("cannot update the %s extension from version %s to %s: %w", name, v.installed, target, err)
+	}
+	migrateLogger.Info("msg", "Updated extension", "extension", name, "from", v.installed, "to", target)
+	return nil
+}
+
+// updateTimescaleDB updates the timescaledb extension in a new session, its
+// update having to be the first statement of the session.
+func updateTimescaleDB(db *sql.DB, cfg ExtensionUpdateConfig) error {
+	// Closing the idle connections makes the pool open a new one.
+	db.SetMaxIdleConns(0)
+	db.SetMaxIdleConns(defaultMaxIdleConns)
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return updateExtension(conn, timescaleExtension, cfg.TimescaleDBVersion, cfg)
+}
+
+// updateExtra updates the timescale_prometheus_extra extension.
+func updateExtra(db *sql.DB, cfg ExtensionUpdateConfig) error {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return updateExtension(conn, extraExtension, cfg.ExtraVersion, cfg)
+}
@@ -0,0 +1,42 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"testing"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+func TestExtensionUpdateTarget(t *testing.T) {
+	log.Init("debug")
+	testCases := []struct {
+		name     string
+		versions extensionVersions
+		pinned   string
+		disabled bool
+		target   string
+		fails    bool
+	}{
+		{name: "not installed", versions: extensionVersions{available: "1.7.4"}},
+		{name: "up to date", versions: extensionVersions{installed: "1.7.4", available: "1.7.4"}},
+		{name: "update", versions: extensionVersions{installed: "1.7.1", available: "1.7.4"}, target: "1.7.4"},
+		{name: "update disabled", versions: extensionVersions{installed: "1.7.1", available: "1.7.4"}, disabled: true},
+		{name: "pinned", versions: extensionVersions{installed: "1.7.1", available: "1.7.4", pinnedAvailable: true}, pinned: "1.7.2", target: "1.7.2"},
+		{name: "pinned installed", versions: extensionVersions{installed: "1.7.2", available: "1.7.4", pinnedAvailable: true}, pinned: "1.7.2"},
+		{name: "pinned disabled", versions: extensionVersions{installed: "1.7.1", available: "1.7.4", pinnedAvailable: true}, pinned: "1.7.2", disabled: true},
+		{name: "pinned unavailable", versions: extensionVersions{installed: "1.7.1", available: "1.7.4"}, pinned: "1.8.0", fails: true},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			target, err := extensionUpdateTarget("timescaledb", c.versions, c.pinned, ExtensionUpdateConfig{Disabled: c.disabled})
+			if (err != nil) != c.fails {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if target != c.target {
+				t.Errorf("unexpected target %q, expected %q", target, c.target)
+			}
+		})
+	}
+}
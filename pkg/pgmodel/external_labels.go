@@ -0,0 +1,96 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// ParseExternalLabels parses a comma-separated list of name=value labels,
+// such as "region=eu,cluster=a". An empty list has no labels.
+func ParseExternalLabels(s string) (labels.Labels, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var res labels.Labels
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("invalid external label %q: expected name=value", pair)
+		}
+		name := kv[0]
+		if !model.LabelName(name).IsValid() || name == MetricNameLabelName {
+			return nil, fmt.Errorf("invalid external label name %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate external label %q", name)
+		}
+		seen[name] = true
+		res = append(res, labels.Label{Name: name, Value: kv[1]})
+	}
+	return labels.New(res...), nil
+}
+
+// addExternalLabels returns tts with the external labels added to the series
+// lacking them, like the external_labels of Prometheus: the labels of the
+// series take precedence. tts is left untouched since it belongs to a pooled
+// write request.
+func addExternalLabels(tts []prompb.TimeSeries, external labels.Labels) []prompb.TimeSeries {
+	if len(external) == 0 {
+		return tts
+	}
+	res := make([]prompb.TimeSeries, len(tts))
+	for i, t := range tts {
+		ls := make([]prompb.Label, len(t.Labels), len(t.Labels)+len(external))
+		copy(ls, t.Labels)
+		for _, e := range external {
+			if !hasLabel(t.Labels, e.Name) {
+				ls = append(ls, prompb.Label{Name: e.Name, Value: e.Value})
+			}
+		}
+		t.Labels = ls
+		res[i] = t
+	}
+	return res
+}
+
+func hasLabel(ls []prompb.Label, name string) bool {
+	for _, l := range ls {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// stripExternalLabels removes the external labels from the labels of ts,
+// when they still have their configured value, as Prometheus removes its own
+// external labels from the series it reads remotely.
+func stripExternalLabels(ts *prompb.TimeSeries, external labels.Labels) {
+	if len(external) == 0 {
+		return
+	}
+	kept := make([]prompb.Label, 0, len(ts.Labels))
+	for _, l := range ts.Labels {
+		if external.Get(l.Name) != l.Value {
+			kept = append(kept, l)
+		}
+	}
+	ts.Labels = kept
+}
+
+// EnableExternalLabelStripping removes the external labels from the series
+// read, where they have the given values, so that a Prometheus with the same
+// external_labels federating the stored series does not see them twice.
+func (r *DBReader) EnableExternalLabelStripping(external labels.Labels) {
+	r.stripLabels = external
+}
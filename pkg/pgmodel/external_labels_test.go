@@ -0,0 +1,90 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestParseExternalLabels(t *testing.T) {
+	if ls, err := ParseExternalLabels(" "); ls != nil || err != nil {
+		t.Errorf("unexpected labels of an empty list: %v %v", ls, err)
+	}
+	ls, err := ParseExternalLabels("region=eu, cluster=a=b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := labels.FromStrings("cluster", "a=b", "region", "eu"); !labels.Equal(ls, expected) {
+		t.Errorf("unexpected labels: got %v wanted %v", ls, expected)
+	}
+	for _, invalid := range []string{"region", "region=", "1region=eu", "__name__=up", "region=eu,region=us"} {
+		if _, err := ParseExternalLabels(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestExternalLabels(t *testing.T) {
+	external := labels.FromStrings("cluster", "a", "region", "eu")
+	tts := []prompb.TimeSeries{
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "up"}},
+			Samples: []prompb.Sample{{Timestamp: 1, Value: 1}},
+		},
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "region", Value: "us"}},
+			Samples: []prompb.Sample{{Timestamp: 1, Value: 1}},
+		},
+	}
+	original := []prompb.Label{{Name: "__name__", Value: "up"}}
+
+	res := addExternalLabels(tts, external)
+	expected := [][]prompb.Label{
+		{{Name: "__name__", Value: "up"}, {Name: "cluster", Value: "a"}, {Name: "region", Value: "eu"}},
+		{{Name: "__name__", Value: "up"}, {Name: "region", Value: "us"}, {Name: "cluster", Value: "a"}},
+	}
+	for i := range res {
+		if !reflect.DeepEqual(res[i].Labels, expected[i]) {
+			t.Errorf("unexpected labels: got %v wanted %v", res[i].Labels, expected[i])
+		}
+	}
+	if !reflect.DeepEqual(tts[0].Labels, original) {
+		t.Errorf("pooled series modified: %v", tts[0].Labels)
+	}
+
+	// The external labels are only removed where they have their value.
+	for i := range res {
+		stripExternalLabels(&res[i], external)
+	}
+	if !reflect.DeepEqual(res[0].Labels, original) {
+		t.Errorf("external labels not removed: %v", res[0].Labels)
+	}
+	if expected := []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "region", Value: "us"}}; !reflect.DeepEqual(res[1].Labels, expected) {
+		t.Errorf("unexpected labels: got %v wanted %v", res[1].Labels, expected)
+	}
+}
+
+func TestReadStripsExternalLabels(t *testing.T) {
+	ts := &prompb.TimeSeries{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "region", Value: "eu"}},
+		Samples: []prompb.Sample{{Timestamp: 1, Value: 1}},
+	}
+	r := &DBReader{db: &mockQuerier{tts: []*prompb.TimeSeries{ts}}}
+	r.EnableExternalLabelStripping(labels.FromStrings("region", "eu"))
+
+	resp, err := r.Read(context.Background(), &prompb.ReadRequest{Queries: []*prompb.Query{{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Results[0].Timeseries[0].Labels; len(got) != 1 || got[0].Value != "up" {
+		t.Errorf("external label not removed: %v", got)
+	}
+}
@@ -0,0 +1,122 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	// triggerBypassCapabilitiesSQL lists what decides whether the COPYs into
+	// the data tables may skip their triggers: TimescaleDB routes the rows
+	// to the chunks itself, foreign keys are enforced by internal triggers
+	// that would be skipped too, and the user triggers are what is bypassed.
+	// ts_insert_blocker only guards the hypertable root, which COPY never
+	// writes to.
+	triggerBypassCapabilitiesSQL = `SELECT
+		EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb'),
+		EXISTS (
+			SELECT 1 FROM pg_constraint con
+			INNER JOIN pg_namespace n ON (n.oid = con.connamespace)
+			WHERE n.nspname = '` + dataSchema + `' AND con.contype = 'f'),
+		ARRAY(
+			SELECT format('%I.%I', c.relname, t.tgname) FROM pg_trigger t
+			INNER JOIN pg_class c ON (c.oid = t.tgrelid)
+			INNER JOIN pg_namespace n ON (n.oid = c.relnamespace)
+			WHERE n.nspname = '` + dataSchema + `' AND NOT t.tgisinternal AND t.tgname <> 'ts_insert_blocker'
+			ORDER BY 1)`
+
+	setReplicaRoleSQL = "SET LOCAL session_replication_role = replica"
+)
+
+// triggerBypassCapabilities is what the database reports about skipping the
+// triggers of the data tables.
+type triggerBypassCapabilities struct {
+	timescaleDB  bool
+	foreignKeys  bool
+	canSetRole   bool
+	userTriggers []string
+}
+
+// unsafeReason returns why the triggers must not be bypassed, or an empty
+// string if bypassing them is safe and worth it.
+func (c triggerBypassCapabilities) unsafeReason() string {
+	switch {
+	case !c.timescaleDB:
+		return "the timescaledb extension is not installed"
+	case c.foreignKeys:
+		return "data tables have foreign keys, which would not be checked"
+	case !c.canSetRole:
+		return "the database user may not set session_replication_role"
+	case len(c.userTriggers) == 0:
+		return "data tables have no triggers to bypass"
+	}
+	return ""
+}
+
+// detectTriggerBypass queries the capabilities of the database the pool
+// connects to.
+func detectTriggerBypass(pool *pgxpool.Pool) (triggerBypassCapabilities, error) {
+	var c triggerBypassCapabilities
+	ctx := context.Background()
+	err := pool.QueryRow(ctx, triggerBypassCapabilitiesSQL).Scan(&c.timescaleDB, &c.foreignKeys, &c.userTriggers)
+	if err != nil {
+		return c, fmt.Errorf("detecting trigger bypass capabilities: %w", err)
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return c, fmt.Errorf("detecting trigger bypass capabilities: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	_, err = tx.Exec(ctx, setReplicaRoleSQL)
+	c.canSetRole = err == nil
+	return c, nil
+}
+
+// triggerBypassConn runs every COPY in a transaction that does not fire
+// the ordinary triggers of the target table.
+type triggerBypassConn struct {
+	pgxConn
+	pool *pgxpool.Pool
+}
+
+// withTriggerBypass wraps conn so that its COPYs skip the triggers of the
+// data tables, if the database makes it safe. Otherwise conn is returned as
+// is and the reason logged.
+func withTriggerBypass(pool *pgxpool.Pool, conn pgxConn) (pgxConn, error) {
+	capabilities, err := detectTriggerBypass(pool)
+	if err != nil {
+		return nil, err
+	}
+	if reason := capabilities.unsafeReason(); reason != "" {
+		ingestLogger.Warn("msg", "Fast ingest disabled, data table triggers still run", "reason", reason)
+		return conn, nil
+	}
+	ingestLogger.Info("msg", "Fast ingest enabled, COPYs bypass the data table triggers", "triggers", strings.Join(capabilities.userTriggers, ","))
+	return &triggerBypassConn{pgxConn: conn, pool: pool}, nil
+}
+
+func (c *triggerBypassConn) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err = tx.Exec(ctx, setReplicaRoleSQL); err != nil {
+		return 0, err
+	}
+	copied, err := tx.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	if err != nil {
+		return 0, err
+	}
+	return copied, tx.Commit(ctx)
+}
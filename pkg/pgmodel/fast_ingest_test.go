@@ -0,0 +1,41 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTriggerBypassUnsafeReason(t *testing.T) {
+	safe := triggerBypassCapabilities{
+		timescaleDB:  true,
+		canSetRole:   true,
+		userTriggers: []string{"metric.audit"},
+	}
+	testCases := []struct {
+		name   string
+		modify func(c *triggerBypassCapabilities)
+		reason string
+	}{
+		{name: "safe", modify: func(c *triggerBypassCapabilities) {}},
+		{name: "no timescaledb", modify: func(c *triggerBypassCapabilities) { c.timescaleDB = false }, reason: "timescaledb"},
+		{name: "foreign keys", modify: func(c *triggerBypassCapabilities) { c.foreignKeys = true }, reason: "foreign keys"},
+		{name: "no permission", modify: func(c *triggerBypassCapabilities) { c.canSetRole = false }, reason: "session_replication_role"},
+		{name: "no triggers", modify: func(c *triggerBypassCapabilities) { c.userTriggers = nil }, reason: "no triggers"},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			capabilities := safe
+			c.modify(&capabilities)
+			reason := capabilities.unsafeReason()
+			if c.reason == "" && reason != "" {
+				t.Errorf("unexpected unsafe reason: %s", reason)
+			}
+			if !strings.Contains(reason, c.reason) {
+				t.Errorf("unexpected unsafe reason: got %q wanted %q", reason, c.reason)
+			}
+		})
+	}
+}
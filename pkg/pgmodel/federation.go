@@ -0,0 +1,307 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"gopkg.in/yaml.v3"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// FederationConfig presents the metrics of several databases, such as those
+// of the business units of an organization, under a single namespace: the
+// series read from each database carry a label naming it. Without a source
+// label, the databases are the shards of a single deployment, such as one
+// per region, and the series of the same labels read from several of them
+// are merged.
+type FederationConfig struct {
+	// SourceLabel is the label naming the database of each series read.
+	// Empty merges the series of the databases instead.
+	SourceLabel string `yaml:"source_label"`
+	// LocalSource is the source name of the database of the connector.
+	LocalSource string `yaml:"local_source"`
+	// Sources are the other databases read.
+	Sources []FederatedSource `yaml:"sources"`
+}
+
+// FederatedSource is a database read along with the database of the
+// connector.
+type FederatedSource struct {
+	Name string `yaml:"name"`
+	// DBURL is the connection string of the database, as a URL or as
+	// key=value pairs.
+	DBURL string `yaml:"db_url"`
+}
+
+// LoadFederationConfig reads a YAML file holding a FederationConfig.
+func LoadFederationConfig(path string) (*FederationConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading federation config: %w", err)
+	}
+	var cfg FederationConfig
+	if err = yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing federation config %s: %w", path, err)
+	}
+	if err = cfg.validate(); err != nil {
+		return nil, fmt.Errorf("parsing federation config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func (cfg *FederationConfig) validate() error {
+	if cfg.SourceLabel != "" && (!model.LabelName(cfg.SourceLabel).IsValid() || cfg.SourceLabel == MetricNameLabelName) {
+		return fmt.Errorf("invalid source label %q", cfg.SourceLabel)
+	}
+	if cfg.LocalSource == "" {
+		return fmt.Errorf("missing local source name")
+	}
+	names := map[string]bool{cfg.LocalSource: true}
+	for i, s := range cfg.Sources {
+		switch {
+		case s.Name == "":
+			return fmt.Errorf("source %d: missing name", i)
+		case names[s.Name]:
+			return fmt.Errorf("source %d: duplicate name %q", i, s.Name)
+		case s.DBURL == "":
+			return fmt.Errorf("source %s: missing db_url", s.Name)
+		}
+		names[s.Name] = true
+	}
+	return nil
+}
+
+// federatedQuerier queries the sources selected by the matchers of the
+// source label, and labels the series read with their source. Without a
+// source label, it queries all the sources and merges their series.
+//
+// The sources other than the local one are best effort: their errors are
+// reported as query warnings along with the series of the other sources.
+type federatedQuerier struct {
+	label   string
+	sources []federatedSource
+}
+
+type federatedSource struct {
+	name  string
+	db    QueryHealthChecker
+	local bool
+}
+
+// EnableFederation reads the sources along with the database of the
+// connector, as set by cfg, by their name. It must be enabled after the
+// other options of the queries of r and of the sources.
+func (r *DBReader) EnableFederation(cfg *FederationConfig, sources map[string]*DBReader) {
+	q := &federatedQuerier{
+		label:   cfg.SourceLabel,
+		sources: []federatedSource{{name: cfg.LocalSource, db: r.db, local: true}},
+	}
+	for _, s := range cfg.Sources {
+		q.sources = append(q.sources, federatedSource{name: s.Name, db: sources[s.Name].db})
+	}
+	r.db = q
+}
+
+// split returns the sources selected by the matchers of the source label of
+// query, and the query without them.
+func (f *federatedQuerier) split(query *prompb.Query) ([]federatedSource, *prompb.Query, error) {
+	var (
+		sourceMatchers []*labels.Matcher
+		sub            = *query
+	)
+	sub.Matchers = make([]*prompb.LabelMatcher, 0, len(query.Matchers))
+	for _, m := range query.Matchers {
+		if m.Name != f.label {
+			sub.Matchers = append(sub.Matchers, m)
+			continue
+		}
+		lm, err := labels.NewMatcher(labels.MatchType(m.Type), m.Name, m.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		sourceMatchers = append(sourceMatchers, lm)
+	}
+
+	selected := make([]federatedSource, 0, len(f.sources))
+	for _, s := range f.sources {
+		matches := true
+		for _, m := range sourceMatchers {
+			matches = matches && m.Matches(s.name)
+		}
+		if matches {
+			selected = append(selected, s)
+		}
+	}
+	return selected, &sub, nil
+}
+
+func (f *federatedQuerier) Query(ctx context.Context, query *prompb.Query) ([]*prompb.TimeSeries, error) {
+	sources, sub, err := f.split(query)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([][]*prompb.TimeSeries, len(sources))
+	errs := make([]error, len(sources))
+	var wg sync.WaitGroup
+	for i := range sources {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = sources[i].db.Query(ctx, sub)
+		}(i)
+	}
+	wg.Wait()
+
+	var tts []*prompb.TimeSeries
+	for i, s := range sources {
+		if err = f.sourceError(ctx, s, errs[i]); err != nil {
+			return nil, err
+		}
+		if f.label == "" {
+			continue
+		}
+		for _, ts := range results[i] {
+			setSourceLabel(ts, f.label, s.name)
+		}
+		tts = append(tts, results[i]...)
+	}
+	if f.label == "" {
+		return mergeShardSeries(results), nil
+	}
+	return tts, nil
+}
+
+// QueryStreamed streams the series of the sources one after the other. The
+// merged series of shards are only complete once all the shards are read,
+// and so are streamed once read.
+func (f *federatedQuerier) QueryStreamed(ctx context.Context, query *prompb.Query, process func(*prompb.TimeSeries) error) error {
+	if f.label == "" {
+		tts, err := f.Query(ctx, query)
+		if err != nil {
+			return err
+		}
+		for _, ts := range tts {
+			if err = process(ts); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	sources, sub, err := f.split(query)
+	if err != nil {
+		return err
+	}
+	for _, s := range sources {
+		var processErr error
+		err = s.db.QueryStreamed(ctx, sub, func(ts *prompb.TimeSeries) error {
+			setSourceLabel(ts, f.label, s.name)
+			processErr = process(ts)
+			return processErr
+		})
+		if processErr != nil {
+			return processErr
+		}
+		if err = f.sourceError(ctx, s, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sourceError returns the error of the query of a source failing the read:
+// those of the local source, and those of the canceled reads. The others are
+// reported as warnings.
+func (f *federatedQuerier) sourceError(ctx context.Context, s federatedSource, err error) error {
+	if err == nil {
+		return nil
+	}
+	if s.local || ctx.Err() != nil {
+		return err
+	}
+	federatedSourceErrors.WithLabelValues(s.name).Inc()
+	QueryWarningsFrom(ctx).Add(fmt.Errorf("source %s: %w", s.name, err))
+	return nil
+}
+
+// HealthCheck checks the database of the connector only, so that an outage
+// of another source does not make the connector unhealthy.
+func (f *federatedQuerier) HealthCheck() error {
+	return f.sources[0].db.HealthCheck()
+}
+
+// setSourceLabel sets the source label of ts, replacing the label of the
+// same name stored with the series and keeping the labels sorted.
+func setSourceLabel(ts *prompb.TimeSeries, label, source string) {
+	i := sort.Search(len(ts.Labels), func(i int) bool { return ts.Labels[i].Name >= label })
+	if i < len(ts.Labels) && ts.Labels[i].Name == label {
+		ts.Labels[i].Value = source
+		return
+	}
+	ts.Labels = append(ts.Labels, prompb.Label{})
+	copy(ts.Labels[i+1:], ts.Labels[i:])
+	ts.Labels[i] = prompb.Label{Name: label, Value: source}
+}
+
+// mergeShardSeries merges the series of the same labels read from several
+// shards, in the order they are first read. Their samples are sorted by
+// time, a sample of a time read from several shards being kept once, as
+// read from the first of them.
+func mergeShardSeries(results [][]*prompb.TimeSeries) []*prompb.TimeSeries {
+	var (
+		tts    []*prompb.TimeSeries
+		index  = make(map[string]*prompb.TimeSeries)
+		merged = make(map[*prompb.TimeSeries]bool)
+	)
+	for _, shard := range results {
+		for _, ts := range shard {
+			key := labelsKey(ts.Labels)
+			e, ok := index[key]
+			if !ok {
+				index[key] = ts
+				tts = append(tts, ts)
+				continue
+			}
+			e.Samples = append(e.Samples, ts.Samples...)
+			e.Histograms = append(e.Histograms, ts.Histograms...)
+			merged[e] = true
+		}
+	}
+	for ts := range merged {
+		sort.SliceStable(ts.Samples, func(i, j int) bool { return ts.Samples[i].Timestamp < ts.Samples[j].Timestamp })
+		sort.SliceStable(ts.Histograms, func(i, j int) bool { return ts.Histograms[i].Timestamp < ts.Histograms[j].Timestamp })
+		ts.Samples = dedupShardSamples(ts.Samples)
+		ts.Histograms = dedupShardHistograms(ts.Histograms)
+	}
+	return tts
+}
+
+func dedupShardSamples(samples []prompb.Sample) []prompb.Sample {
+	out := samples[:0]
+	for _, s := range samples {
+		if len(out) == 0 || s.Timestamp != out[len(out)-1].Timestamp {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func dedupShardHistograms(histograms []prompb.Histogram) []prompb.Histogram {
+	out := histograms[:0]
+	for _, h := range histograms {
+		if len(out) == 0 || h.Timestamp != out[len(out)-1].Timestamp {
+			out = append(out, h)
+		}
+	}
+	return out
+}
@@ -0,0 +1,215 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const testFederationConfig = `
+source_label: unit
+local_source: core
+sources:
+  - name: payments
+    db_url: postgres://payments-db/timescale
+  - name: search
+    db_url: host=search-db dbname=timescale
+`
+
+func writeFederationConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "federation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "federation.yaml")
+	if err = ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFederationConfig(t *testing.T) {
+	cfg, err := LoadFederationConfig(writeFederationConfig(t, testFederationConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SourceLabel != "unit" || cfg.LocalSource != "core" || len(cfg.Sources) != 2 || cfg.Sources[1].Name != "search" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	invalid := map[string]string{
+		"metric name label": "source_label: __name__\nlocal_source: core\n",
+		"invalid label":     "source_label: business-unit\nlocal_source: core\n",
+		"no local source":   "source_label: unit\n",
+		"unnamed source":    "source_label: unit\nlocal_source: core\nsources:\n  - db_url: x\n",
+		"duplicate source":  "source_label: unit\nlocal_source: core\nsources:\n  - name: core\n    db_url: x\n",
+		"no db_url":         "source_label: unit\nlocal_source: core\nsources:\n  - name: payments\n",
+		"not a map":         "- unit\n",
+	}
+	for name, content := range invalid {
+		if _, err := LoadFederationConfig(writeFederationConfig(t, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// recordingQuerier records the queries it runs.
+type recordingQuerier struct {
+	mockQuerier
+	queries []*prompb.Query
+}
+
+func (q *recordingQuerier) Query(ctx context.Context, query *prompb.Query) ([]*prompb.TimeSeries, error) {
+	q.queries = append(q.queries, query)
+	return q.mockQuerier.Query(ctx, query)
+}
+
+func (q *recordingQuerier) QueryStreamed(ctx context.Context, query *prompb.Query, process func(*prompb.TimeSeries) error) error {
+	q.queries = append(q.queries, query)
+	return q.mockQuerier.QueryStreamed(ctx, query, process)
+}
+
+func federatedTestSeries(job string) []*prompb.TimeSeries {
+	return []*prompb.TimeSeries{{
+		Labels: []prompb.Label{{Name: MetricNameLabelName, Value: "up"}, {Name: "job", Value: job}, {Name: "zone", Value: "a"}},
+	}}
+}
+
+func newTestFederatedReader() (*DBReader, map[string]*recordingQuerier) {
+	queriers := map[string]*recordingQuerier{
+		"core":     {mockQuerier: mockQuerier{tts: federatedTestSeries("core")}},
+		"payments": {mockQuerier: mockQuerier{tts: federatedTestSeries("payments")}},
+		"search":   {mockQuerier: mockQuerier{err: fmt.Errorf("connection refused")}},
+	}
+	r := &DBReader{db: queriers["core"]}
+	r.EnableFederation(&FederationConfig{
+		SourceLabel: "unit",
+		LocalSource: "core",
+		Sources:     []FederatedSource{{Name: "payments"}, {Name: "search"}},
+	}, map[string]*DBReader{"payments": {db: queriers["payments"]}, "search": {db: queriers["search"]}})
+	return r, queriers
+}
+
+func TestFederatedRead(t *testing.T) {
+	r, queriers := newTestFederatedReader()
+	ctx, warnings := WithQueryWarnings(context.Background())
+	query := &prompb.Query{Matchers: []*prompb.LabelMatcher{
+		{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "up"},
+		{Type: prompb.LabelMatcher_NEQ, Name: "unit", Value: "payments"},
+	}}
+	resp, err := r.Read(ctx, &prompb.ReadRequest{Queries: []*prompb.Query{query}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*prompb.TimeSeries{{
+		Labels: []prompb.Label{{Name: MetricNameLabelName, Value: "up"}, {Name: "job", Value: "core"}, {Name: "unit", Value: "core"}, {Name: "zone", Value: "a"}},
+	}}
+	if got := resp.Results[0].Timeseries; !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected series:\ngot\n%v\nwanted\n%v", got, expected)
+	}
+	if len(queriers["payments"].queries) != 0 {
+		t.Error("unselected source queried")
+	}
+	if q := queriers["core"].queries; len(q) != 1 || len(q[0].Matchers) != 1 || q[0].Matchers[0].Name != MetricNameLabelName {
+		t.Errorf("source matcher not removed: %v", q)
+	}
+	if w := warnings.Warnings(); len(w) != 1 || w[0].Error() != "source search: connection refused" {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+
+	queriers["core"].err = fmt.Errorf("some error")
+	if _, err = r.Read(ctx, &prompb.ReadRequest{Queries: []*prompb.Query{query}}); err == nil {
+		t.Error("expected the error of the local source")
+	}
+}
+
+func TestFederatedReadStreamed(t *testing.T) {
+	r, _ := newTestFederatedReader()
+	var got []string
+	err := r.db.QueryStreamed(context.Background(), &prompb.Query{}, func(ts *prompb.TimeSeries) error {
+		got = append(got, ts.Labels[2].Name+"="+ts.Labels[2].Value)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"unit=core", "unit=payments"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected series: %v", got)
+	}
+	if err := r.HealthCheck(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetSourceLabel(t *testing.T) {
+	ts := &prompb.TimeSeries{Labels: []prompb.Label{{Name: "a", Value: "1"}, {Name: "unit", Value: "stored"}}}
+	setSourceLabel(ts, "unit", "core")
+	expected := []prompb.Label{{Name: "a", Value: "1"}, {Name: "unit", Value: "core"}}
+	if !reflect.DeepEqual(ts.Labels, expected) {
+		t.Errorf("unexpected labels: %v", ts.Labels)
+	}
+	setSourceLabel(ts, "b", "x")
+	if ts.Labels[1].Name != "b" || len(ts.Labels) != 3 {
+		t.Errorf("unexpected labels: %v", ts.Labels)
+	}
+}
+
+func TestShardedRead(t *testing.T) {
+	cfg, err := LoadFederationConfig(writeFederationConfig(t, "local_source: eu\nsources:\n  - name: us\n    db_url: postgres://us-db/timescale\n  - name: ap\n    db_url: postgres://ap-db/timescale\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	up := func(job string, samples ...prompb.Sample) *prompb.TimeSeries {
+		return &prompb.TimeSeries{Labels: []prompb.Label{{Name: MetricNameLabelName, Value: "up"}, {Name: "job", Value: job}}, Samples: samples}
+	}
+	queriers := map[string]*recordingQuerier{
+		"eu": {mockQuerier: mockQuerier{tts: []*prompb.TimeSeries{up("a", prompb.Sample{Timestamp: 1, Value: 1}, prompb.Sample{Timestamp: 3, Value: 1})}}},
+		"us": {mockQuerier: mockQuerier{tts: []*prompb.TimeSeries{up("b", prompb.Sample{Timestamp: 1, Value: 2}), up("a", prompb.Sample{Timestamp: 2, Value: 2}, prompb.Sample{Timestamp: 3, Value: 2})}}},
+		"ap": {mockQuerier: mockQuerier{err: fmt.Errorf("connection refused")}},
+	}
+	r := &DBReader{db: queriers["eu"]}
+	r.EnableFederation(cfg, map[string]*DBReader{"us": {db: queriers["us"]}, "ap": {db: queriers["ap"]}})
+
+	ctx, warnings := WithQueryWarnings(context.Background())
+	query := &prompb.Query{Matchers: []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "up"}}}
+	resp, err := r.Read(ctx, &prompb.ReadRequest{Queries: []*prompb.Query{query}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*prompb.TimeSeries{
+		up("a", prompb.Sample{Timestamp: 1, Value: 1}, prompb.Sample{Timestamp: 2, Value: 2}, prompb.Sample{Timestamp: 3, Value: 1}),
+		up("b", prompb.Sample{Timestamp: 1, Value: 2}),
+	}
+	if got := resp.Results[0].Timeseries; !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected series:\ngot\n%v\nwanted\n%v", got, expected)
+	}
+	for name, q := range queriers {
+		if len(q.queries) != 1 || !reflect.DeepEqual(q.queries[0].Matchers, query.Matchers) {
+			t.Errorf("source %s: unexpected queries %v", name, q.queries)
+		}
+	}
+	if w := warnings.Warnings(); len(w) != 1 || w[0].Error() != "source ap: connection refused" {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+
+	var streamed int
+	err = r.db.QueryStreamed(context.Background(), query, func(ts *prompb.TimeSeries) error {
+		streamed++
+		return nil
+	})
+	if err != nil || streamed != 2 {
+		t.Errorf("unexpected streamed series: %d, %v", streamed, err)
+	}
+}
@@ -0,0 +1,157 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"gopkg.in/yaml.v3"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// GraphiteMappingRule maps the Graphite paths matching Match, such as
+// servers.*.cpu.*, to the metric Name and Labels. Every * of Match matches
+// one dot-separated element of the path, which ${1}, ${2}... of Name and of
+// the label values are replaced with.
+type GraphiteMappingRule struct {
+	Match  string            `yaml:"match"`
+	Name   string            `yaml:"name"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+type graphiteRule struct {
+	match  *regexp.Regexp
+	name   string
+	labels labels.Labels
+}
+
+// GraphiteMapper converts the lines of the Graphite plaintext protocol into
+// samples, naming their series after the first mapping rule matching their
+// path. The paths matching no rule are stored as the metric named after the
+// path, with its dots and other invalid characters replaced with _.
+type GraphiteMapper struct {
+	rules []graphiteRule
+}
+
+// NewGraphiteMapper returns a mapper applying rules in order.
+func NewGraphiteMapper(rules []GraphiteMappingRule) (*GraphiteMapper, error) {
+	m := &GraphiteMapper{rules: make([]graphiteRule, 0, len(rules))}
+	for _, r := range rules {
+		if r.Match == "" || r.Name == "" {
+			return nil, fmt.Errorf("graphite mapping rule %q: match and name are required", r.Match)
+		}
+		parts := strings.Split(r.Match, ".")
+		for i, part := range parts {
+			parts[i] = strings.Replace(regexp.QuoteMeta(part), `\*`, `([^.]*)`, -1)
+		}
+		match, err := regexp.Compile("^" + strings.Join(parts, `\.`) + "$")
+		if err != nil {
+			return nil, fmt.Errorf("graphite mapping rule %q: %w", r.Match, err)
+		}
+		ls := make(labels.Labels, 0, len(r.Labels))
+		for name, value := range r.Labels {
+			if !model.LabelName(name).IsValid() || name == labels.MetricName {
+				return nil, fmt.Errorf("graphite mapping rule %q: invalid label name %q", r.Match, name)
+			}
+			ls = append(ls, labels.Label{Name: name, Value: value})
+		}
+		m.rules = append(m.rules, graphiteRule{match: match, name: r.Name, labels: labels.New(ls...)})
+	}
+	return m, nil
+}
+
+// LoadGraphiteMapper returns the mapper of the rules listed in the YAML file
+// at path, under mappings. An empty path returns a mapper without rules.
+func LoadGraphiteMapper(path string) (*GraphiteMapper, error) {
+	if path == "" {
+		return NewGraphiteMapper(nil)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading graphite mapping file: %w", err)
+	}
+	var file struct {
+		Mappings []GraphiteMappingRule `yaml:"mappings"`
+	}
+	if err = yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing graphite mapping file: %w", err)
+	}
+	return NewGraphiteMapper(file.Mappings)
+}
+
+// Map returns the labels of the series of path.
+func (m *GraphiteMapper) Map(path string) labels.Labels {
+	for _, r := range m.rules {
+		groups := r.match.FindStringSubmatchIndex(path)
+		if groups == nil {
+			continue
+		}
+		expand := func(template string) string {
+			return string(r.match.ExpandString(nil, template, path, groups))
+		}
+		b := labels.NewBuilder(nil)
+		b.Set(labels.MetricName, sanitizeName(expand(r.name), true))
+		for _, l := range r.labels {
+			b.Set(l.Name, expand(l.Value))
+		}
+		return b.Labels()
+	}
+	return labels.Labels{{Name: labels.MetricName, Value: sanitizeName(path, true)}}
+}
+
+// ParseLine parses a line of the Graphite plaintext protocol,
+// "path value timestamp", where the path may carry tags as in
+// "path;tag=value;tag2=value2". The timestamp is in seconds, and the samples
+// without one, or with -1, are stamped with now. The tags are added to the
+// labels of the series, but do not override the labels of the mapping rule.
+func (m *GraphiteMapper) ParseLine(line string, now time.Time) (labels.Labels, prompb.Sample, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 && len(fields) != 3 {
+		return nil, prompb.Sample{}, fmt.Errorf("invalid graphite line %q: expected path, value and timestamp", line)
+	}
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return nil, prompb.Sample{}, fmt.Errorf("invalid graphite line %q: invalid value: %w", line, err)
+	}
+	ts := now.UnixNano() / int64(time.Millisecond)
+	if len(fields) == 3 && fields[2] != "-1" {
+		seconds, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+			return nil, prompb.Sample{}, fmt.Errorf("invalid graphite line %q: invalid timestamp", line)
+		}
+		ts = int64(math.Round(seconds * 1000))
+	}
+
+	tags := strings.Split(fields[0], ";")
+	if tags[0] == "" {
+		return nil, prompb.Sample{}, fmt.Errorf("invalid graphite line %q: empty path", line)
+	}
+	lset := m.Map(tags[0])
+	if len(tags) > 1 {
+		b := labels.NewBuilder(lset)
+		for _, tag := range tags[1:] {
+			eq := strings.IndexByte(tag, '=')
+			if eq <= 0 {
+				return nil, prompb.Sample{}, fmt.Errorf("invalid graphite line %q: invalid tag %q", line, tag)
+			}
+			name := sanitizeName(tag[:eq], false)
+			if name == labels.MetricName || lset.Has(name) {
+				continue
+			}
+			b.Set(name, tag[eq+1:])
+		}
+		lset = b.Labels()
+	}
+	return lset, prompb.Sample{Timestamp: ts, Value: value}, nil
+}
@@ -0,0 +1,144 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestGraphiteParseLine(t *testing.T) {
+	mapper, err := NewGraphiteMapper([]GraphiteMappingRule{
+		{
+			Match:  "servers.*.cpu.*",
+			Name:   "server_cpu_${2}",
+			Labels: map[string]string{"host": "${1}", "job": "graphite"},
+		},
+		{
+			Match:  "*.requests",
+			Name:   "requests_total",
+			Labels: map[string]string{"service": "${1}"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(100, 0)
+	testCases := []struct {
+		name   string
+		line   string
+		labels labels.Labels
+		sample prompb.Sample
+		err    bool
+	}{
+		{
+			name:   "mapped",
+			line:   "servers.db1.cpu.idle 97.5 1600000000",
+			labels: labels.FromStrings("__name__", "server_cpu_idle", "host", "db1", "job", "graphite"),
+			sample: prompb.Sample{Timestamp: 1600000000000, Value: 97.5},
+		},
+		{
+			name:   "first matching rule",
+			line:   "api.requests 3 1600000000.5",
+			labels: labels.FromStrings("__name__", "requests_total", "service", "api"),
+			sample: prompb.Sample{Timestamp: 1600000000500, Value: 3},
+		},
+		{
+			name:   "unmapped",
+			line:   "disk.sda-1.used 42",
+			labels: labels.FromStrings("__name__", "disk_sda_1_used"),
+			sample: prompb.Sample{Timestamp: 100000, Value: 42},
+		},
+		{
+			name:   "unmapped leading digit",
+			line:   "1min.load 0.5 -1",
+			labels: labels.FromStrings("__name__", "_1min_load"),
+			sample: prompb.Sample{Timestamp: 100000, Value: 0.5},
+		},
+		{
+			name:   "tags",
+			line:   "api.requests;service=ignored;dc=eu-1 7 1600000000",
+			labels: labels.FromStrings("__name__", "requests_total", "dc", "eu-1", "service", "api"),
+			sample: prompb.Sample{Timestamp: 1600000000000, Value: 7},
+		},
+		{
+			name: "missing value",
+			line: "servers.db1.cpu.idle",
+			err:  true,
+		},
+		{
+			name: "invalid value",
+			line: "servers.db1.cpu.idle high 1600000000",
+			err:  true,
+		},
+		{
+			name: "invalid timestamp",
+			line: "servers.db1.cpu.idle 1 yesterday",
+			err:  true,
+		},
+		{
+			name: "invalid tag",
+			line: "api.requests;dc 1",
+			err:  true,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			lset, sample, err := mapper.ParseLine(c.line, now)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", lset)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(lset, c.labels) {
+				t.Errorf("unexpected labels: got %v wanted %v", lset, c.labels)
+			}
+			if !reflect.DeepEqual(sample, c.sample) {
+				t.Errorf("unexpected sample: got %v wanted %v", sample, c.sample)
+			}
+		})
+	}
+}
+
+func TestLoadGraphiteMapper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "graphite_mapping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "mapping.yml")
+	data := "mappings:\n- match: app.*.latency\n  name: app_latency_ms\n  labels:\n    app: ${1}\n"
+	if err = ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mapper, err := LoadGraphiteMapper(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := labels.FromStrings("__name__", "app_latency_ms", "app", "web")
+	if got := mapper.Map("app.web.latency"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected labels: got %v wanted %v", got, expected)
+	}
+
+	invalid := "mappings:\n- match: app.*\n  name: app\n  labels:\n    bad-name: ${1}\n"
+	if err = ioutil.WriteFile(path, []byte(invalid), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadGraphiteMapper(path); err == nil {
+		t.Error("expected an error for an invalid label name")
+	}
+}
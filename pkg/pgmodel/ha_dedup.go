@@ -0,0 +1,207 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/timescale/timescale-prometheus/pkg/events"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	// DefaultHAClusterLabel is the default external label naming the cluster
+	// of HA Prometheus replicas a write request comes from.
+	DefaultHAClusterLabel = "cluster"
+	// DefaultHAReplicaLabel is the default external label naming the replica
+	// a write request comes from.
+	DefaultHAReplicaLabel = "__replica__"
+	// DefaultHALeaseTimeout is the default duration of a lease. Samples
+	// are lost for up to this long when the leader stops writing.
+	DefaultHALeaseTimeout = time.Minute
+
+	updateHALeaseSQL = "SELECT leader_name, lease_remaining_ms FROM " + catalogSchema + ".update_ha_lease($1, $2, $3)"
+)
+
+// HAConfig configures the deduplication of HA Prometheus replicas.
+type HAConfig struct {
+	// ClusterLabel is the label naming the cluster of replicas.
+	ClusterLabel string
+	// ReplicaLabel is the label naming the replica. It is removed from
+	// the stored series.
+	ReplicaLabel string
+	// LeaseTimeout is how long a replica stays the leader of its cluster
+	// after its last write.
+	LeaseTimeout time.Duration
+}
+
+// haLease is the lease of a cluster as last seen in the database.
+type haLease struct {
+	leader    string
+	expires   time.Time
+	refreshAt time.Time
+}
+
+// HADeduplicator stores the samples of a single replica of every cluster of
+// HA Prometheus servers and drops the samples of the others.
+//
+// The replica writing is elected through a lease stored in the database,
+// so that all the connectors agree on it. The leader renews the lease as
+// it writes, and another replica takes over once it expires. Write requests
+// without the cluster and replica labels are written as is.
+type HADeduplicator struct {
+	conn     pgxConn
+	inserter DBInserter
+	cfg      HAConfig
+
+	lock   sync.Mutex
+	leases map[string]*haLease
+}
+
+// NewHADeduplicator returns an HADeduplicator writing the samples of the
+// leading replicas to inserter.
+func NewHADeduplicator(c *pgxpool.Pool, inserter DBInserter, cfg HAConfig) *HADeduplicator {
+	return newHADeduplicator(&pgxConnImpl{conn: c}, inserter, cfg)
+}
+
+func newHADeduplicator(conn pgxConn, inserter DBInserter, cfg HAConfig) *HADeduplicator {
+	if cfg.ClusterLabel == "" {
+		cfg.ClusterLabel = DefaultHAClusterLabel
+	}
+	if cfg.ReplicaLabel == "" {
+		cfg.ReplicaLabel = DefaultHAReplicaLabel
+	}
+	if cfg.LeaseTimeout <= 0 {
+		cfg.LeaseTimeout = DefaultHALeaseTimeout
+	}
+	return &HADeduplicator{
+		conn:     conn,
+		inserter: inserter,
+		cfg:      cfg,
+		leases:   make(map[string]*haLease),
+	}
+}
+
+// Ingest implements DBInserter. Prometheus adds the same external labels to
+// every series of a write request, so the first series identifies the
+// replica.
+func (d *HADeduplicator) Ingest(ctx context.Context, tts []prompb.TimeSeries, req *prompb.WriteRequest) (uint64, error) {
+	if len(tts) == 0 {
+		return d.inserter.Ingest(ctx, tts, req)
+	}
+	cluster, replica := d.replicaOf(tts[0].Labels)
+	if cluster == "" || replica == "" {
+		return d.inserter.Ingest(ctx, tts, req)
+	}
+
+	leader, err := d.leader(cluster, replica)
+	if err != nil {
+		return 0, err
+	}
+
+	if leader != replica {
+		var samples int
+		for _, ts := range tts {
+			samples += len(ts.Samples)
+		}
+		haSamplesDropped.Add(float64(samples))
+		FinishWriteRequest(req)
+		return 0, nil
+	}
+
+	for i := range tts {
+		tts[i].Labels = removeLabel(tts[i].Labels, d.cfg.ReplicaLabel)
+	}
+	return d.inserter.Ingest(ctx, tts, req)
+}
+
+func (d *HADeduplicator) replicaOf(labels []prompb.Label) (cluster, replica string) {
+	for _, l := range labels {
+		switch l.Name {
+		case d.cfg.ClusterLabel:
+			cluster = l.Value
+		case d.cfg.ReplicaLabel:
+			replica = l.Value
+		}
+	}
+	return cluster, replica
+}
+
+func removeLabel(labels []prompb.Label, name string) []prompb.Label {
+	for i, l := range labels {
+		if l.Name == name {
+			return append(labels[:i], labels[i+1:]...)
+		}
+	}
+	return labels
+}
+
+// leader returns the leader of the cluster, trying to take the lease for
+// replica if it expired. The database is only asked when the cached lease
+// expires, or, for the leader, halfway through the lease to renew it.
+func (d *HADeduplicator) leader(cluster, replica string) (string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	now := time.Now()
+	lease, ok := d.leases[cluster]
+	if ok && now.Before(lease.refreshAt) {
+		return lease.leader, nil
+	}
+
+	leader, remaining, err := d.updateLease(cluster, replica)
+	if err != nil {
+		// The leader keeps writing until its lease runs out, no other
+		// replica can take over before.
+		if ok && lease.leader == replica && now.Before(lease.expires) {
+			ingestLogger.Warn("msg", "Cannot renew the HA lease, writing until it expires", "cluster", cluster, "replica", replica, "err", err)
+			return replica, nil
+		}
+		return "", err
+	}
+
+	updated := &haLease{
+		leader:    leader,
+		expires:   now.Add(remaining),
+		refreshAt: now.Add(remaining),
+	}
+	if leader == replica {
+		updated.refreshAt = now.Add(remaining - d.cfg.LeaseTimeout/2)
+	}
+	d.leases[cluster] = updated
+
+	if !ok || lease.leader != leader {
+		if ok {
+			haLeaseOwner.DeleteLabelValues(cluster, lease.leader)
+		}
+		haLeaseOwner.WithLabelValues(cluster, leader).Set(1)
+		ingestLogger.Info("msg", "HA cluster leader changed", "cluster", cluster, "leader", leader)
+		events.Emit(events.HALeaderChanged, "cluster", cluster, "leader", leader)
+	}
+	return leader, nil
+}
+
+func (d *HADeduplicator) updateLease(cluster, replica string) (string, time.Duration, error) {
+	rows, err := d.conn.Query(context.Background(), updateHALeaseSQL, cluster, replica, d.cfg.LeaseTimeout)
+	if err != nil {
+		return "", 0, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", 0, errNoRows
+	}
+	var (
+		leader      string
+		remainingMs int64
+	)
+	if err := rows.Scan(&leader, &remainingMs); err != nil {
+		return "", 0, err
+	}
+	return leader, time.Duration(remainingMs) * time.Millisecond, nil
+}
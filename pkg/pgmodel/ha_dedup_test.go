@@ -0,0 +1,136 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func haWriteRequest(cluster, replica string) *prompb.WriteRequest {
+	req := NewWriteRequest()
+	labels := []prompb.Label{{Name: MetricNameLabelName, Value: "metric"}}
+	if replica != "" {
+		labels = append(labels, prompb.Label{Name: DefaultHAReplicaLabel, Value: replica})
+	}
+	if cluster != "" {
+		labels = append(labels, prompb.Label{Name: DefaultHAClusterLabel, Value: cluster})
+	}
+	req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+		Labels:  labels,
+		Samples: []prompb.Sample{{Timestamp: 1, Value: 1}, {Timestamp: 2, Value: 2}},
+	})
+	return req
+}
+
+func TestHADeduplicator(t *testing.T) {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{
+			{{"a", int64(60000)}},
+			{{"b", int64(60000)}},
+		},
+	}
+	inserter := &mockSpillInserter{}
+	d := newHADeduplicator(mock, inserter, HAConfig{})
+
+	ingest := func(cluster, replica string) (uint64, []prompb.Label, error) {
+		req := haWriteRequest(cluster, replica)
+		tts := req.Timeseries
+		n, err := d.Ingest(context.Background(), tts, req)
+		return n, tts[0].Labels, err
+	}
+
+	// Requests without the HA labels are written as is.
+	if n, _, err := ingest("", "a"); err != nil || n != 2 {
+		t.Fatalf("unexpected result without cluster label: %d %v", n, err)
+	}
+	if len(mock.QuerySQLs) != 0 {
+		t.Fatalf("unexpected lease query: %v", mock.QuerySQLs)
+	}
+
+	// The first replica takes the lease, and its replica label is removed.
+	n, labels, err := ingest("c", "a")
+	if err != nil || n != 2 {
+		t.Fatalf("unexpected result for the leader: %d %v", n, err)
+	}
+	expected := []prompb.Label{{Name: MetricNameLabelName, Value: "metric"}, {Name: DefaultHAClusterLabel, Value: "c"}}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Errorf("unexpected labels:\ngot\n%v\nwanted\n%v", labels, expected)
+	}
+	if !reflect.DeepEqual(mock.QueryArgs[0], []interface{}{"c", "a", DefaultHALeaseTimeout}) {
+		t.Errorf("unexpected lease query args: %v", mock.QueryArgs[0])
+	}
+	if testutil.ToFloat64(haLeaseOwner.WithLabelValues("c", "a")) != 1 {
+		t.Error("lease owner metric not set")
+	}
+
+	// The other replica is dropped while the lease is cached.
+	droppedBefore := testutil.ToFloat64(haSamplesDropped)
+	if n, _, err := ingest("c", "b"); err != nil || n != 0 {
+		t.Fatalf("unexpected result for the follower: %d %v", n, err)
+	}
+	if dropped := testutil.ToFloat64(haSamplesDropped) - droppedBefore; dropped != 2 {
+		t.Errorf("unexpected number of dropped samples: %v", dropped)
+	}
+	if len(mock.QuerySQLs) != 1 {
+		t.Fatalf("unexpected lease queries: %v", mock.QuerySQLs)
+	}
+
+	// Once the lease expires, the other replica takes over.
+	d.leases["c"].refreshAt = time.Now().Add(-time.Second)
+	if n, _, err := ingest("c", "b"); err != nil || n != 2 {
+		t.Fatalf("unexpected result for the new leader: %d %v", n, err)
+	}
+	if n, _, err := ingest("c", "a"); err != nil || n != 0 {
+		t.Fatalf("unexpected result for the old leader: %d %v", n, err)
+	}
+	if testutil.ToFloat64(haLeaseOwner.WithLabelValues("c", "b")) != 1 {
+		t.Error("lease owner metric not updated")
+	}
+
+	got := inserter.metrics()
+	if len(got) != 3 {
+		t.Errorf("unexpected number of written requests: %v", got)
+	}
+}
+
+func TestHADeduplicatorDatabaseError(t *testing.T) {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{{{"a", int64(60000)}}},
+		QueryErr:     map[int]error{1: fmt.Errorf("connection refused"), 2: fmt.Errorf("connection refused")},
+	}
+	inserter := &mockSpillInserter{}
+	d := newHADeduplicator(mock, inserter, HAConfig{})
+
+	req := haWriteRequest("c", "a")
+	if _, err := d.Ingest(context.Background(), req.Timeseries, req); err != nil {
+		t.Fatal(err)
+	}
+
+	// The leader keeps writing until its lease expires.
+	d.leases["c"].refreshAt = time.Now().Add(-time.Second)
+	req = haWriteRequest("c", "a")
+	if _, err := d.Ingest(context.Background(), req.Timeseries, req); err != nil {
+		t.Errorf("unexpected error for the leader: %v", err)
+	}
+
+	// A replica without the lease cannot tell whether to take over.
+	req = haWriteRequest("c", "b")
+	if _, err := d.Ingest(context.Background(), req.Timeseries, req); err == nil {
+		t.Error("expected an error for the follower")
+	}
+}
@@ -0,0 +1,164 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	healthCheckSQL = `SELECT
+		COALESCE((SELECT version FROM public.prom_schema_migrations LIMIT 1), 0),
+		COALESCE((SELECT dirty FROM public.prom_schema_migrations LIMIT 1), false),
+		EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb'),
+		EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescale_prometheus_extra'),
+		pg_is_in_recovery()`
+
+	HealthCheckConnection    = "connection"
+	HealthCheckSchemaVersion = "schema_version"
+	HealthCheckExtension     = "extension"
+	HealthCheckStandby       = "standby"
+	HealthCheckPool          = "connection_pool"
+)
+
+// ReadinessChecker allows checking whether the connector can serve requests.
+type ReadinessChecker interface {
+	ReadinessCheck() (HealthReport, error)
+}
+
+// HealthReport holds the result of every health check.
+type HealthReport struct {
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+// HealthCheckResult is the result of a single health check. Checks marked
+// ReadinessOnly report conditions which should stop traffic from being sent
+// to the connector, but which a restart would not fix.
+type HealthCheckResult struct {
+	Name          string `json:"name"`
+	Healthy       bool   `json:"healthy"`
+	ReadinessOnly bool   `json:"readiness_only,omitempty"`
+	Message       string `json:"message,omitempty"`
+}
+
+// HealthReporter checks the database the connector depends on: that it is
+// reachable, has the expected schema and extensions, is writable, and that
+// the connection pool is not saturated.
+type HealthReporter struct {
+	conn            pgxConn
+	poolStat        func() (acquired int32, max int32)
+	expectedVersion int64
+}
+
+// NewHealthReporter returns a HealthReporter for the given connection pool.
+func NewHealthReporter(c *pgxpool.Pool) (*HealthReporter, error) {
+	version, err := LatestMigrationVersion()
+	if err != nil {
+		return nil, err
+	}
+	return &HealthReporter{
+		conn: &pgxConnImpl{
+			conn: c,
+		},
+		poolStat: func() (int32, int32) {
+			stat := c.Stat()
+			return stat.AcquiredConns(), stat.MaxConns()
+		},
+		expectedVersion: int64(version),
+	}, nil
+}
+
+// Report runs all the health checks.
+func (h *HealthReporter) Report() HealthReport {
+	report := HealthReport{Checks: make([]HealthCheckResult, 0, 5)}
+	add := func(r HealthCheckResult) {
+		report.Checks = append(report.Checks, r)
+	}
+
+	var (
+		version                 int64
+		dirty, timescale, extra bool
+		inRecovery              bool
+	)
+	err := h.queryStatus(&version, &dirty, &timescale, &extra, &inRecovery)
+	if err != nil {
+		add(HealthCheckResult{Name: HealthCheckConnection, Message: err.Error()})
+	} else {
+		add(HealthCheckResult{Name: HealthCheckConnection, Healthy: true})
+
+		switch {
+		case dirty:
+			add(HealthCheckResult{Name: HealthCheckSchemaVersion, Message: fmt.Sprintf("migration %d did not complete", version)})
+		case version != h.expectedVersion:
+			add(HealthCheckResult{Name: HealthCheckSchemaVersion, Message: fmt.Sprintf("schema version %d, expected %d", version, h.expectedVersion)})
+		default:
+			add(HealthCheckResult{Name: HealthCheckSchemaVersion, Healthy: true})
+		}
+
+		switch {
+		case !timescale:
+			add(HealthCheckResult{Name: HealthCheckExtension, Message: "timescaledb extension is not installed"})
+		case !extra:
+			add(HealthCheckResult{Name: HealthCheckExtension, Healthy: true, Message: "timescale_prometheus_extra extension is not installed, queries will be slower"})
+		default:
+			add(HealthCheckResult{Name: HealthCheckExtension, Healthy: true})
+		}
+
+		if inRecovery {
+			add(HealthCheckResult{Name: HealthCheckStandby, ReadinessOnly: true, Message: "database is a read-only standby"})
+		} else {
+			add(HealthCheckResult{Name: HealthCheckStandby, ReadinessOnly: true, Healthy: true})
+		}
+	}
+
+	if h.poolStat != nil {
+		acquired, max := h.poolStat()
+		pool := HealthCheckResult{Name: HealthCheckPool, ReadinessOnly: true, Healthy: acquired < max}
+		if !pool.Healthy {
+			pool.Message = fmt.Sprintf("all %d connections are in use", max)
+		}
+		add(pool)
+	}
+
+	return report
+}
+
+func (h *HealthReporter) queryStatus(dest ...interface{}) error {
+	rows, err := h.conn.Query(context.Background(), healthCheckSQL)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return fmt.Errorf("health check query returned no rows")
+	}
+	return rows.Scan(dest...)
+}
+
+// HealthCheck reports the first failing liveness check as an error.
+func (h *HealthReporter) HealthCheck() error {
+	return h.Report().err(false)
+}
+
+// ReadinessCheck runs all the checks, returning the report and an error
+// describing the first failing one.
+func (h *HealthReporter) ReadinessCheck() (HealthReport, error) {
+	report := h.Report()
+	return report, report.err(true)
+}
+
+func (r HealthReport) err(readiness bool) error {
+	for _, c := range r.Checks {
+		if c.Healthy || (c.ReadinessOnly && !readiness) {
+			continue
+		}
+		return fmt.Errorf("%s check failed: %s", c.Name, c.Message)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestLatestMigrationVersion(t *testing.T) {
+	version, err := LatestMigrationVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version == 0 {
+		t.Errorf("no migrations found")
+	}
+}
+
+func TestHealthReporter(t *testing.T) {
+	testCases := []struct {
+		name         string
+		queryResults []rowResults
+		queryErr     map[int]error
+		acquired     int32
+		expected     []HealthCheckResult
+		liveErr      error
+		readyErr     error
+	}{
+		{
+			name:         "Healthy",
+			queryResults: []rowResults{{{int64(3), false, true, true, false}}},
+			expected: []HealthCheckResult{
+				{Name: HealthCheckConnection, Healthy: true},
+				{Name: HealthCheckSchemaVersion, Healthy: true},
+				{Name: HealthCheckExtension, Healthy: true},
+				{Name: HealthCheckStandby, ReadinessOnly: true, Healthy: true},
+				{Name: HealthCheckPool, ReadinessOnly: true, Healthy: true},
+			},
+		},
+		{
+			name:         "Connection error",
+			queryErr:     map[int]error{0: fmt.Errorf("some error")},
+			queryResults: []rowResults{{}},
+			expected: []HealthCheckResult{
+				{Name: HealthCheckConnection, Message: "some error"},
+				{Name: HealthCheckPool, ReadinessOnly: true, Healthy: true},
+			},
+			liveErr:  fmt.Errorf("connection check failed: some error"),
+			readyErr: fmt.Errorf("connection check failed: some error"),
+		},
+		{
+			name:         "Old schema and missing extensions",
+			queryResults: []rowResults{{{int64(2), false, false, false, false}}},
+			expected: []HealthCheckResult{
+				{Name: HealthCheckConnection, Healthy: true},
+				{Name: HealthCheckSchemaVersion, Message: "schema version 2, expected 3"},
+				{Name: HealthCheckExtension, Message: "timescaledb extension is not installed"},
+				{Name: HealthCheckStandby, ReadinessOnly: true, Healthy: true},
+				{Name: HealthCheckPool, ReadinessOnly: true, Healthy: true},
+			},
+			liveErr:  fmt.Errorf("schema_version check failed: schema version 2, expected 3"),
+			readyErr: fmt.Errorf("schema_version check failed: schema version 2, expected 3"),
+		},
+		{
+			name:         "Dirty schema, no extra extension",
+			queryResults: []rowResults{{{int64(3), true, true, false, false}}},
+			expected: []HealthCheckResult{
+				{Name: HealthCheckConnection, Healthy: true},
+				{Name: HealthCheckSchemaVersion, Message: "migration 3 did not complete"},
+				{Name: HealthCheckExtension, Healthy: true, Message: "timescale_prometheus_extra extension is not installed, queries will be slower"},
+				{Name: HealthCheckStandby, ReadinessOnly: true, Healthy: true},
+				{Name: HealthCheckPool, ReadinessOnly: true, Healthy: true},
+			},
+			liveErr:  fmt.Errorf("schema_version check failed: migration 3 did not complete"),
+			readyErr: fmt.Errorf("schema_version check failed: migration 3 did not complete"),
+		},
+		{
+			name:         "Standby and saturated pool are readiness only",
+			queryResults: []rowResults{{{int64(3), false, true, true, true}}},
+			acquired:     10,
+			expected: []HealthCheckResult{
+				{Name: HealthCheckConnection, Healthy: true},
+				{Name: HealthCheckSchemaVersion, Healthy: true},
+				{Name: HealthCheckExtension, Healthy: true},
+				{Name: HealthCheckStandby, ReadinessOnly: true, Message: "database is a read-only standby"},
+				{Name: HealthCheckPool, ReadinessOnly: true, Message: "all 10 connections are in use"},
+			},
+			readyErr: fmt.Errorf("standby check failed: database is a read-only standby"),
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &mockPGXConn{
+				QueryResults: c.queryResults,
+				QueryErr:     c.queryErr,
+			}
+			h := &HealthReporter{
+				conn:            mock,
+				poolStat:        func() (int32, int32) { return c.acquired, 10 },
+				expectedVersion: 3,
+			}
+
+			report := h.Report()
+			if !reflect.DeepEqual(report.Checks, c.expected) {
+				t.Errorf("unexpected checks:\ngot\n%+v\nwanted\n%+v", report.Checks, c.expected)
+			}
+
+			mock.QueryResultsIndex = 0
+			if err := h.HealthCheck(); fmt.Sprint(err) != fmt.Sprint(c.liveErr) {
+				t.Errorf("unexpected liveness error:\ngot\n%v\nwanted\n%v", err, c.liveErr)
+			}
+
+			mock.QueryResultsIndex = 0
+			if _, err := h.ReadinessCheck(); fmt.Sprint(err) != fmt.Sprint(c.readyErr) {
+				t.Errorf("unexpected readiness error:\ngot\n%v\nwanted\n%v", err, c.readyErr)
+			}
+		})
+	}
+}
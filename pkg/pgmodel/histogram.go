@@ -0,0 +1,442 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	minExponentialSchema = -4
+	maxExponentialSchema = 8
+	customBucketsSchema  = -53
+
+	insertHistogramSQL = `INSERT INTO ` + catalogSchema + `.histogram(time, series_id, count, sum, schema, zero_threshold, zero_count,
+	negative_span_offsets, negative_span_lengths, negative_counts, positive_span_offsets, positive_span_lengths, positive_counts,
+	custom_values, reset_hint, float_counts)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	ON CONFLICT DO NOTHING`
+
+	histogramsSQLFormat = `SELECT (key_value_array(s.labels)).*, h.time, h.count, h.sum, h.schema, h.zero_threshold, h.zero_count,
+	h.negative_span_offsets, h.negative_span_lengths, h.negative_counts, h.positive_span_offsets, h.positive_span_lengths, h.positive_counts,
+	h.custom_values, h.reset_hint, h.float_counts
+	FROM _prom_catalog.histogram h
+	INNER JOIN _prom_catalog.series s
+	ON h.series_id = s.id
+	WHERE %[1]s
+	AND h.time >= $%[2]d
+	AND h.time <= $%[3]d
+	ORDER BY h.series_id, h.time`
+)
+
+// ErrInvalidHistogram is returned by Ingest for the native histograms which
+// cannot be stored, such as those whose counts do not match their spans.
+var ErrInvalidHistogram = errors.New("invalid native histogram")
+
+// nativeHistogram is a native histogram sample as stored in the histogram
+// table: the spans are split into offsets and lengths, and the counts of
+// the buckets are absolute, also for the integer histograms.
+type nativeHistogram struct {
+	timestamp       int64
+	count           float64
+	sum             float64
+	schema          int32
+	zeroThreshold   float64
+	zeroCount       float64
+	negativeOffsets []int32
+	negativeLengths []int32
+	negativeCounts  []float64
+	positiveOffsets []int32
+	positiveLengths []int32
+	positiveCounts  []float64
+	customValues    []float64
+	resetHint       int16
+	// floatCounts is set for float histograms, whose counts are sent as
+	// floats rather than delta encoded integers.
+	floatCounts bool
+}
+
+// newNativeHistogram converts a histogram of a write request into its stored
+// form.
+func newNativeHistogram(h *prompb.Histogram) (nativeHistogram, error) {
+	n := nativeHistogram{
+		timestamp:     h.Timestamp,
+		sum:           h.Sum,
+		schema:        h.Schema,
+		zeroThreshold: h.ZeroThreshold,
+		resetHint:     int16(h.ResetHint),
+		floatCounts:   len(h.PositiveCounts) > 0 || len(h.NegativeCounts) > 0,
+	}
+	switch c := h.Count.(type) {
+	case *prompb.Histogram_CountInt:
+		n.count = float64(c.CountInt)
+	case *prompb.Histogram_CountFloat:
+		n.count = c.CountFloat
+		n.floatCounts = true
+	}
+	switch c := h.ZeroCount.(type) {
+	case *prompb.Histogram_ZeroCountInt:
+		n.zeroCount = float64(c.ZeroCountInt)
+	case *prompb.Histogram_ZeroCountFloat:
+		n.zeroCount = c.ZeroCountFloat
+	}
+
+	switch {
+	case h.Schema == customBucketsSchema:
+		if len(h.NegativeSpans) > 0 {
+			return n, fmt.Errorf("%w: negative buckets with custom buckets", ErrInvalidHistogram)
+		}
+		if !sort.Float64sAreSorted(h.CustomValues) {
+			return n, fmt.Errorf("%w: custom bucket bounds not sorted", ErrInvalidHistogram)
+		}
+		n.customValues = append([]float64{}, h.CustomValues...)
+	case h.Schema >= minExponentialSchema && h.Schema <= maxExponentialSchema:
+		n.customValues = []float64{}
+	default:
+		return n, fmt.Errorf("%w: unsupported schema %d", ErrInvalidHistogram, h.Schema)
+	}
+
+	var err error
+	n.negativeOffsets, n.negativeLengths = splitSpans(h.NegativeSpans)
+	if n.negativeCounts, err = absoluteCounts(h.NegativeSpans, h.NegativeDeltas, h.NegativeCounts, n.floatCounts); err != nil {
+		return n, fmt.Errorf("%w: negative buckets: %v", ErrInvalidHistogram, err)
+	}
+	n.positiveOffsets, n.positiveLengths = splitSpans(h.PositiveSpans)
+	if n.positiveCounts, err = absoluteCounts(h.PositiveSpans, h.PositiveDeltas, h.PositiveCounts, n.floatCounts); err != nil {
+		return n, fmt.Errorf("%w: positive buckets: %v", ErrInvalidHistogram, err)
+	}
+	return n, nil
+}
+
+func splitSpans(spans []prompb.BucketSpan) (offsets, lengths []int32) {
+	offsets = make([]int32, 0, len(spans))
+	lengths = make([]int32, 0, len(spans))
+	for _, s := range spans {
+		offsets = append(offsets, s.Offset)
+		lengths = append(lengths, int32(s.Length))
+	}
+	return offsets, lengths
+}
+
+// absoluteCounts returns the counts of the buckets of spans, from the delta
+// encoded counts of an integer histogram or the counts of a float one.
+func absoluteCounts(spans []prompb.BucketSpan, deltas []int64, counts []float64, floatCounts bool) ([]float64, error) {
+	var n int64
+	for _, s := range spans {
+		if s.Length > 1<<31-1 {
+			return nil, errors.New("invalid span length")
+		}
+		n += int64(s.Length)
+	}
+	if floatCounts {
+		if len(deltas) > 0 || int64(len(counts)) != n {
+			return nil, fmt.Errorf("%d buckets in the spans, %d float counts and %d deltas", n, len(counts), len(deltas))
+		}
+		return append([]float64{}, counts...), nil
+	}
+	if int64(len(deltas)) != n {
+		return nil, fmt.Errorf("%d buckets in the spans, %d deltas", n, len(deltas))
+	}
+	res := make([]float64, 0, len(deltas))
+	var current int64
+	for _, d := range deltas {
+		current += d
+		if current < 0 {
+			return nil, errors.New("negative bucket count")
+		}
+		res = append(res, float64(current))
+	}
+	return res, nil
+}
+
+// toProto converts n back into the histogram written, with the timestamp
+// ts.
+func (n *nativeHistogram) toProto(ts int64) prompb.Histogram {
+	h := prompb.Histogram{
+		Sum:           n.sum,
+		Schema:        n.schema,
+		ZeroThreshold: n.zeroThreshold,
+		NegativeSpans: joinSpans(n.negativeOffsets, n.negativeLengths),
+		PositiveSpans: joinSpans(n.positiveOffsets, n.positiveLengths),
+		ResetHint:     prompb.Histogram_ResetHint(n.resetHint),
+		Timestamp:     ts,
+	}
+	if len(n.customValues) > 0 {
+		h.CustomValues = n.customValues
+	}
+	if n.floatCounts {
+		h.Count = &prompb.Histogram_CountFloat{CountFloat: n.count}
+		h.ZeroCount = &prompb.Histogram_ZeroCountFloat{ZeroCountFloat: n.zeroCount}
+		if len(n.negativeCounts) > 0 {
+			h.NegativeCounts = n.negativeCounts
+		}
+		if len(n.positiveCounts) > 0 {
+			h.PositiveCounts = n.positiveCounts
+		}
+		return h
+	}
+	h.Count = &prompb.Histogram_CountInt{CountInt: uint64(n.count)}
+	h.ZeroCount = &prompb.Histogram_ZeroCountInt{ZeroCountInt: uint64(n.zeroCount)}
+	h.NegativeDeltas = deltaCounts(n.negativeCounts)
+	h.PositiveDeltas = deltaCounts(n.positiveCounts)
+	return h
+}
+
+func joinSpans(offsets, lengths []int32) []prompb.BucketSpan {
+	if len(offsets) == 0 {
+		return nil
+	}
+	spans := make([]prompb.BucketSpan, 0, len(offsets))
+	for i := range offsets {
+		spans = append(spans, prompb.BucketSpan{Offset: offsets[i], Length: uint32(lengths[i])})
+	}
+	return spans
+}
+
+func deltaCounts(counts []float64) []int64 {
+	if len(counts) == 0 {
+		return nil
+	}
+	deltas := make([]int64, 0, len(counts))
+	var prev int64
+	for _, c := range counts {
+		deltas = append(deltas, int64(c)-prev)
+		prev = int64(c)
+	}
+	return deltas
+}
+
+// histogramSeries are the native histograms of a series.
+type histogramSeries struct {
+	labels       *Labels
+	seriesID     SeriesID
+	microseconds bool
+	histograms   []nativeHistogram
+}
+
+// parseHistograms converts the native histograms of tts. It must run before
+// parseData, which releases the write request.
+func (i *DBIngestor) parseHistograms(tts []prompb.TimeSeries) ([]histogramSeries, error) {
+	var res []histogramSeries
+	for _, t := range tts {
+		if len(t.Histograms) == 0 {
+			continue
+		}
+		seriesLabels, metricName, err := labelProtosToLabels(t.Labels)
+		if err != nil {
+			return nil, err
+		}
+		if metricName == "" {
+			return nil, ErrNoMetricName
+		}
+		s := histogramSeries{
+			labels:       seriesLabels,
+			seriesID:     -1,
+			microseconds: i.microsecondMetrics != nil && i.microsecondMetrics.MatchString(metricName),
+			histograms:   make([]nativeHistogram, 0, len(t.Histograms)),
+		}
+		for j := range t.Histograms {
+			h, err := newNativeHistogram(&t.Histograms[j])
+			if err != nil {
+				return nil, fmt.Errorf("series %s: %w", seriesLabels, err)
+			}
+			s.histograms = append(s.histograms, h)
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// histogramInserter writes the native histograms of the write requests.
+type histogramInserter interface {
+	insert(ctx context.Context, series []histogramSeries) (uint64, error)
+}
+
+// histogramWriter inserts the native histograms into the histogram table.
+// The histograms are far fewer than the float samples, so they are inserted
+// with a batch of INSERTs rather than through the COPY pipeline of the
+// metric tables.
+type histogramWriter struct {
+	conn  pgxConn
+	cache Cache
+}
+
+// insert writes the histograms of series, returning how many were written.
+// The histograms already stored for the same series and time are skipped.
+func (w *histogramWriter) insert(ctx context.Context, series []histogramSeries) (uint64, error) {
+	if w == nil || len(series) == 0 {
+		return 0, nil
+	}
+	if err := w.setSeriesIDs(ctx, series); err != nil {
+		return 0, err
+	}
+
+	batch := w.conn.NewBatch()
+	var n uint64
+	for _, s := range series {
+		for _, h := range s.histograms {
+			batch.Queue(insertHistogramSQL,
+				sampleTime(h.timestamp, s.microseconds), s.seriesID, h.count, h.sum, h.schema, h.zeroThreshold, h.zeroCount,
+				h.negativeOffsets, h.negativeLengths, h.negativeCounts, h.positiveOffsets, h.positiveLengths, h.positiveCounts,
+				h.customValues, h.resetHint, h.floatCounts)
+			n++
+		}
+	}
+	br, err := w.conn.SendBatch(ctx, batch)
+	if err != nil {
+		return 0, err
+	}
+	defer br.Close()
+	for i := uint64(0); i < n; i++ {
+		if _, err = br.Exec(); err != nil {
+			return 0, err
+		}
+	}
+	histogramsWritten.Add(float64(n))
+	return n, nil
+}
+
+// setSeriesIDs sets the ids of the series, from the series cache or else
+// fetching, or creating, them metric by metric.
+func (w *histogramWriter) setSeriesIDs(ctx context.Context, series []histogramSeries) error {
+	missing := make(map[string][]*histogramSeries)
+	for i := range series {
+		s := &series[i]
+		if id, err := w.cache.GetSeries(*s.labels); err == nil {
+			s.seriesID = id
+			continue
+		}
+		missing[s.labels.metricName] = append(missing[s.labels.metricName], s)
+	}
+
+	for metricName, ss := range missing {
+		// The same series may appear several times in a request.
+		unique := make(map[string]int)
+		var keys, values []string
+		var counts []int32
+		for _, s := range ss {
+			if _, ok := unique[s.labels.str]; ok {
+				continue
+			}
+			unique[s.labels.str] = len(counts)
+			keys = append(keys, s.labels.names...)
+			values = append(values, s.labels.values...)
+			counts = append(counts, int32(len(s.labels.names)))
+		}
+
+		rows, err := w.conn.Query(ctx, getSeriesIDsForLabelsSQL, metricName, keys, values, counts)
+		if err != nil {
+			return err
+		}
+		ids := make([]SeriesID, 0, len(counts))
+		for rows.Next() {
+			var (
+				tableName string
+				id        SeriesID
+			)
+			if err = rows.Scan(&tableName, &id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if len(ids) != len(counts) {
+			return fmt.Errorf("got %d series ids for the %d series of metric %s", len(ids), len(counts), metricName)
+		}
+
+		for _, s := range ss {
+			s.seriesID = ids[unique[s.labels.str]]
+			//ignore error since this is just an optimization
+			_ = w.cache.SetSeries(*s.labels, s.seriesID)
+		}
+	}
+	return nil
+}
+
+// queryHistograms reads the native histograms of the series matching cases
+// in the time range of filter.
+func (q *pgxQuerier) queryHistograms(ctx context.Context, filter metricTimeRangeFilter, cases matcherClauses, values []interface{}, process func(*prompb.TimeSeries) error) error {
+	if !q.nativeHistograms {
+		return nil
+	}
+	sql := sqlTemplates.get("histograms\xff"+cases.shape, func() string {
+		return fmt.Sprintf(histogramsSQLFormat, cases, len(values)+1, len(values)+2)
+	})
+	args := make([]interface{}, 0, len(values)+2)
+	args = append(args, values...)
+	args = append(args, filter.startTime, filter.endTime)
+
+	rows, err := q.query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current *prompb.TimeSeries
+	for rows.Next() {
+		var (
+			keys, vals []string
+			t          time.Time
+			h          nativeHistogram
+		)
+		err = rows.Scan(&keys, &vals, &t, &h.count, &h.sum, &h.schema, &h.zeroThreshold, &h.zeroCount,
+			&h.negativeOffsets, &h.negativeLengths, &h.negativeCounts, &h.positiveOffsets, &h.positiveLengths, &h.positiveCounts,
+			&h.customValues, &h.resetHint, &h.floatCounts)
+		if err != nil {
+			return err
+		}
+		queryRowsScanned.Inc()
+
+		if current == nil || !sameLabelValues(current.Labels, keys, vals) {
+			if current != nil {
+				if err = process(current); err != nil {
+					return err
+				}
+			}
+			current = &prompb.TimeSeries{Labels: sortedLabels(keys, vals)}
+		}
+		current.Histograms = append(current.Histograms, h.toProto(toTimestamp(t, filter.microseconds)))
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if current != nil {
+		return process(current)
+	}
+	return nil
+}
+
+func sortedLabels(keys, vals []string) []prompb.Label {
+	ls := make([]prompb.Label, 0, len(keys))
+	for i, k := range keys {
+		ls = append(ls, prompb.Label{Name: k, Value: vals[i]})
+	}
+	sort.Slice(ls, func(i, j int) bool {
+		return ls[i].Name < ls[j].Name
+	})
+	return ls
+}
+
+// sameLabelValues reports whether the sorted labels ls are those of keys and
+// vals.
+func sameLabelValues(ls []prompb.Label, keys, vals []string) bool {
+	if len(ls) != len(keys) {
+		return false
+	}
+	for i, k := range keys {
+		j := sort.Search(len(ls), func(j int) bool { return ls[j].Name >= k })
+		if j == len(ls) || ls[j].Name != k || ls[j].Value != vals[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,230 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestNativeHistogramRoundTrip(t *testing.T) {
+	testCases := map[string]prompb.Histogram{
+		"integer": {
+			Count:          &prompb.Histogram_CountInt{CountInt: 8},
+			Sum:            10,
+			Schema:         3,
+			ZeroThreshold:  0.001,
+			ZeroCount:      &prompb.Histogram_ZeroCountInt{ZeroCountInt: 1},
+			NegativeSpans:  []prompb.BucketSpan{{Offset: -2, Length: 1}},
+			NegativeDeltas: []int64{1},
+			PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 2}, {Offset: 3, Length: 1}},
+			PositiveDeltas: []int64{2, 1, -2},
+			ResetHint:      prompb.Histogram_NO,
+			Timestamp:      1000,
+		},
+		"float": {
+			Count:          &prompb.Histogram_CountFloat{CountFloat: 2.5},
+			Sum:            -1,
+			ZeroCount:      &prompb.Histogram_ZeroCountFloat{ZeroCountFloat: 0.5},
+			PositiveSpans:  []prompb.BucketSpan{{Offset: 1, Length: 2}},
+			PositiveCounts: []float64{1.5, 0.5},
+			ResetHint:      prompb.Histogram_GAUGE,
+			Timestamp:      2000,
+		},
+		"custom buckets": {
+			Count:          &prompb.Histogram_CountInt{CountInt: 6},
+			Sum:            2.5,
+			Schema:         customBucketsSchema,
+			ZeroCount:      &prompb.Histogram_ZeroCountInt{},
+			PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 3}},
+			PositiveDeltas: []int64{1, 1, 1},
+			CustomValues:   []float64{0.1, 0.5},
+			Timestamp:      3000,
+		},
+	}
+	for name, h := range testCases {
+		n, err := newNativeHistogram(&h)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if got := n.toProto(h.Timestamp); !reflect.DeepEqual(got, h) {
+			t.Errorf("%s: unexpected histogram:\ngot    %v\nwanted %v", name, got, h)
+		}
+	}
+}
+
+func TestNativeHistogramInvalid(t *testing.T) {
+	testCases := map[string]prompb.Histogram{
+		"unknown schema": {Schema: 9},
+		"negative custom buckets": {
+			Schema:         customBucketsSchema,
+			NegativeSpans:  []prompb.BucketSpan{{Length: 1}},
+			NegativeDeltas: []int64{1},
+		},
+		"unsorted custom buckets": {Schema: customBucketsSchema, CustomValues: []float64{1, 0.5}},
+		"span mismatch": {
+			PositiveSpans:  []prompb.BucketSpan{{Length: 3}},
+			PositiveDeltas: []int64{1},
+		},
+		"negative count": {
+			PositiveSpans:  []prompb.BucketSpan{{Length: 2}},
+			PositiveDeltas: []int64{1, -2},
+		},
+		"deltas and counts": {
+			PositiveSpans:  []prompb.BucketSpan{{Length: 1}},
+			PositiveDeltas: []int64{1},
+			PositiveCounts: []float64{1},
+		},
+		"huge span": {
+			PositiveSpans: []prompb.BucketSpan{{Length: 1 << 31}},
+		},
+	}
+	for name, h := range testCases {
+		if _, err := newNativeHistogram(&h); !errors.Is(err, ErrInvalidHistogram) {
+			t.Errorf("%s: expected an invalid histogram error, got %v", name, err)
+		}
+	}
+}
+
+func TestHistogramWriterInsert(t *testing.T) {
+	cached, _, err := labelProtosToLabels([]prompb.Label{{Name: "__name__", Value: "rpc_seconds"}, {Name: "job", Value: "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing, _, err := labelProtosToLabels([]prompb.Label{{Name: "__name__", Value: "rpc_seconds"}, {Name: "job", Value: "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := newNativeHistogram(&prompb.Histogram{
+		Count:          &prompb.Histogram_CountInt{CountInt: 1},
+		PositiveSpans:  []prompb.BucketSpan{{Length: 1}},
+		PositiveDeltas: []int64{1},
+		Timestamp:      1000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock := &mockPGXConn{QueryResults: []rowResults{{{"rpc_seconds", int64(7)}}}}
+	cache := &mockCache{seriesCache: map[string]SeriesID{cached.String(): 3}}
+	w := &histogramWriter{conn: mock, cache: cache}
+	n, err := w.insert(context.Background(), []histogramSeries{
+		{labels: cached, seriesID: -1, histograms: []nativeHistogram{h}},
+		{labels: missing, seriesID: -1, histograms: []nativeHistogram{h}},
+		{labels: missing, seriesID: -1, histograms: []nativeHistogram{h}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("unexpected histograms written: got %d wanted 3", n)
+	}
+
+	expectedArgs := []interface{}{"rpc_seconds", []string{"__name__", "job"}, []string{"rpc_seconds", "b"}, []int32{2}}
+	if len(mock.QueryArgs) != 1 || !reflect.DeepEqual(mock.QueryArgs[0], expectedArgs) {
+		t.Errorf("unexpected series query arguments:\ngot    %v\nwanted %v", mock.QueryArgs, expectedArgs)
+	}
+	if id, err := cache.GetSeries(*missing); err != nil || id != 7 {
+		t.Errorf("series id not cached: got %d, %v", id, err)
+	}
+	if len(mock.Batch) != 1 || len(mock.Batch[0].items) != 3 {
+		t.Fatalf("unexpected batches: %v", mock.Batch)
+	}
+	for i, id := range []SeriesID{3, 7, 7} {
+		args := mock.Batch[0].items[i].arguments
+		if args[1] != id || !args[0].(time.Time).Equal(time.Unix(1, 0)) {
+			t.Errorf("unexpected insert %d arguments: %v", i, args)
+		}
+	}
+}
+
+func TestHistogramWriterNil(t *testing.T) {
+	var w *histogramWriter
+	if n, err := w.insert(context.Background(), []histogramSeries{{}}); n != 0 || err != nil {
+		t.Errorf("unexpected result: %d, %v", n, err)
+	}
+}
+
+func TestQueryHistograms(t *testing.T) {
+	row := func(job string, t int64, counts []float64) []interface{} {
+		return []interface{}{
+			[]string{"__name__", "job"}, []string{"rpc_seconds", job}, time.Unix(t, 0),
+			float64(2), float64(1), int32(0), float64(0), float64(0),
+			[]int32{}, []int32{}, []float64{}, []int32{0}, []int32{int32(len(counts))}, counts,
+			[]float64{}, int16(0), false,
+		}
+	}
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{
+			nil,
+			{row("a", 1, []float64{2}), row("a", 2, []float64{1, 3}), row("b", 1, []float64{2})},
+		},
+	}
+	querier := pgxQuerier{conn: mock, metricTableNames: &mockMetricCache{metricCache: map[string]string{}}, nativeHistograms: true}
+	result, err := querier.Query(context.Background(), &prompb.Query{
+		StartTimestampMs: 1000,
+		EndTimestampMs:   2000,
+		Matchers:         []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "rpc_seconds"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	histogram := func(t int64, deltas ...int64) prompb.Histogram {
+		return prompb.Histogram{
+			Count:          &prompb.Histogram_CountInt{CountInt: 2},
+			Sum:            1,
+			ZeroCount:      &prompb.Histogram_ZeroCountInt{},
+			PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: uint32(len(deltas))}},
+			PositiveDeltas: deltas,
+			Timestamp:      t,
+		}
+	}
+	expected := []*prompb.TimeSeries{
+		{
+			Labels:     []prompb.Label{{Name: "__name__", Value: "rpc_seconds"}, {Name: "job", Value: "a"}},
+			Histograms: []prompb.Histogram{histogram(1000, 2), histogram(2000, 1, 2)},
+		},
+		{
+			Labels:     []prompb.Label{{Name: "__name__", Value: "rpc_seconds"}, {Name: "job", Value: "b"}},
+			Histograms: []prompb.Histogram{histogram(1000, 2)},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result:\ngot    %v\nwanted %v", result, expected)
+	}
+	if len(mock.QuerySQLs) != 2 {
+		t.Errorf("unexpected queries: %v", mock.QuerySQLs)
+	}
+}
+
+func TestParseHistograms(t *testing.T) {
+	valid := prompb.Histogram{Count: &prompb.Histogram_CountInt{CountInt: 1}, Timestamp: 1000}
+	i := &DBIngestor{}
+	series, err := i.parseHistograms([]prompb.TimeSeries{
+		{Labels: []prompb.Label{{Name: "__name__", Value: "up"}}, Samples: []prompb.Sample{{Value: 1}}},
+		{Labels: []prompb.Label{{Name: "__name__", Value: "rpc_seconds"}}, Histograms: []prompb.Histogram{valid, valid}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || len(series[0].histograms) != 2 || series[0].labels.metricName != "rpc_seconds" {
+		t.Errorf("unexpected series: %v", series)
+	}
+
+	_, err = i.parseHistograms([]prompb.TimeSeries{{Labels: []prompb.Label{{Name: "job", Value: "a"}}, Histograms: []prompb.Histogram{valid}}})
+	if err != ErrNoMetricName {
+		t.Errorf("unexpected error: got %v wanted %v", err, ErrNoMetricName)
+	}
+	_, err = i.parseHistograms([]prompb.TimeSeries{{Labels: []prompb.Label{{Name: "__name__", Value: "rpc_seconds"}}, Histograms: []prompb.Histogram{{Schema: 9}}}})
+	if !errors.Is(err, ErrInvalidHistogram) {
+		t.Errorf("unexpected error: got %v wanted %v", err, ErrInvalidHistogram)
+	}
+}
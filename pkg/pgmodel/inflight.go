@@ -0,0 +1,132 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInFlightLimitExceeded is returned when the samples of a request could
+	// not be admitted into the ingest pipeline before the wait timeout expired.
+	ErrInFlightLimitExceeded = fmt.Errorf("too many samples in flight")
+)
+
+// inMemorySampleBytes is the size of a sample held in memory, a timestamp
+// and a value.
+const inMemorySampleBytes = 16
+
+// inFlightBytes estimates the memory held by the samples of rows: their
+// values and the labels of their series.
+func inFlightBytes(rows map[string][]samplesInfo) int64 {
+	var n int64
+	for _, data := range rows {
+		for _, si := range data {
+			n += int64(len(si.samples)) * inMemorySampleBytes
+			if si.labels != nil {
+				n += int64(len(si.labels.str))
+			}
+		}
+	}
+	return n
+}
+
+// inFlightBudget bounds the number of samples, or their bytes, that have been
+// accepted but not yet written to the database, so that a slow database
+// cannot make the connector buffer an unbounded amount of data.
+type inFlightBudget struct {
+	lock  sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+}
+
+func newInFlightBudget(limit int64) *inFlightBudget {
+	b := &inFlightBudget{limit: limit}
+	b.cond = sync.NewCond(&b.lock)
+	return b
+}
+
+// acquire reserves n samples of the budget, waiting for other requests to
+// finish if needed. A timeout of 0 means wait forever. Waiting stops with the
+// context error once ctx is done. A single request larger than the whole
+// budget is admitted once nothing else is in flight.
+func (b *inFlightBudget) acquire(ctx context.Context, n int64, timeout time.Duration) error {
+	return b.acquireUpTo(ctx, n, b.limit, timeout)
+}
+
+// acquireUpTo reserves n samples like acquire, as long as the samples in
+// flight stay within max, a share of the budget.
+func (b *inFlightBudget) acquireUpTo(ctx context.Context, n, max int64, timeout time.Duration) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if done := ctx.Done(); done != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-done:
+				b.lock.Lock()
+				b.cond.Broadcast()
+				b.lock.Unlock()
+			case <-stop:
+			}
+		}()
+	}
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+		timer := time.AfterFunc(timeout, func() {
+			b.lock.Lock()
+			b.cond.Broadcast()
+			b.lock.Unlock()
+		})
+		defer timer.Stop()
+	}
+
+	for b.used > 0 && b.used+n > max {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if timeout > 0 && !time.Now().Before(deadline) {
+			return ErrInFlightLimitExceeded
+		}
+		b.cond.Wait()
+	}
+	b.used += n
+	return nil
+}
+
+// tryAcquire reserves n samples if the samples in flight stay within max,
+// without waiting.
+func (b *inFlightBudget) tryAcquire(n, max int64) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.used > 0 && b.used+n > max {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+// release returns n samples to the budget.
+func (b *inFlightBudget) release(n int64) {
+	b.lock.Lock()
+	b.used -= n
+	b.lock.Unlock()
+	b.cond.Broadcast()
+}
+
+// inUse returns the number of samples currently in flight.
+func (b *inFlightBudget) inUse() int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.used
+}
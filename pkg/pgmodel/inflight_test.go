@@ -0,0 +1,153 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestInFlightBudget(t *testing.T) {
+	b := newInFlightBudget(10)
+
+	if err := b.acquire(context.Background(), 6, time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := b.acquire(context.Background(), 6, 10*time.Millisecond); err != ErrInFlightLimitExceeded {
+		t.Fatalf("unexpected error:\ngot\n%v\nwanted\n%v", err, ErrInFlightLimitExceeded)
+	}
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- b.acquire(context.Background(), 6, 0)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquired budget while over the limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	b.release(6)
+
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter not woken up on release")
+	}
+
+	if b.inUse() != 6 {
+		t.Errorf("unexpected in-flight samples: got %d wanted 6", b.inUse())
+	}
+	b.release(6)
+
+	// A request larger than the whole budget is admitted when nothing else is in flight.
+	if err := b.acquire(context.Background(), 20, time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b.release(20)
+
+	if b.inUse() != 0 {
+		t.Errorf("unexpected in-flight samples: got %d wanted 0", b.inUse())
+	}
+}
+
+func TestInFlightBudgetCanceled(t *testing.T) {
+	b := newInFlightBudget(10)
+	if err := b.acquire(context.Background(), 6, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	acquired := make(chan error)
+	go func() {
+		acquired <- b.acquire(ctx, 6, 0)
+	}()
+	cancel()
+
+	select {
+	case err := <-acquired:
+		if err != context.Canceled {
+			t.Fatalf("unexpected error:\ngot\n%v\nwanted\n%v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter not woken up on cancel")
+	}
+
+	if b.inUse() != 6 {
+		t.Errorf("unexpected in-flight samples: got %d wanted 6", b.inUse())
+	}
+}
+
+func TestPGXInserterInFlightRelease(t *testing.T) {
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{{{"metricTableName_0", true}}},
+	}
+	mockMetrics := &mockMetricCache{metricCache: map[string]string{}}
+	inserter, err := newPgxInserter(mock, mockMetrics, &Cfg{MaxInFlightSamples: 1, MaxInFlightBytes: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inserter.Close()
+
+	rows := createRows(3)
+	for i := range rows["metric_0"] {
+		rows["metric_0"][i].seriesID = SeriesID(i + 1)
+		rows["metric_0"][i].samples = []prompb.Sample{{Timestamp: 1, Value: 1}}
+	}
+	inserted, err := inserter.InsertData(context.Background(), rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 3 {
+		t.Errorf("unexpected number of samples inserted: got %d wanted 3", inserted)
+	}
+
+	if inserter.inFlight.inUse() != 0 {
+		t.Errorf("in-flight budget not released: got %d", inserter.inFlight.inUse())
+	}
+	if inserter.inFlightBytes.inUse() != 0 {
+		t.Errorf("in-flight bytes not released: got %d", inserter.inFlightBytes.inUse())
+	}
+}
+
+func TestPGXInserterInFlightBytes(t *testing.T) {
+	mockMetrics := &mockMetricCache{metricCache: map[string]string{}}
+	inserter, err := newPgxInserter(&mockPGXConn{}, mockMetrics, &Cfg{MaxInFlightBytes: 100, InFlightWaitTimeout: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inserter.Close()
+
+	lset, err := LabelsFromSlice(labels.Labels{{Name: MetricNameLabelName, Value: "metric_0"}, {Name: "pod", Value: "a-long-pod-name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := map[string][]samplesInfo{"metric_0": {{labels: lset, samples: make([]prompb.Sample, 2)}}}
+	expected := int64(len(lset.str) + 2*inMemorySampleBytes)
+	if n := inFlightBytes(rows); n != expected {
+		t.Errorf("unexpected in-flight bytes: got %d wanted %d", n, expected)
+	}
+
+	// A write over the bytes left fails after the wait timeout, without
+	// keeping any of them.
+	if err = inserter.inFlightBytes.acquire(context.Background(), 90, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = inserter.InsertData(context.Background(), rows); err != ErrInFlightLimitExceeded {
+		t.Errorf("unexpected error over the in-flight bytes: %v", err)
+	}
+	if n := inserter.inFlightBytes.inUse(); n != 90 {
+		t.Errorf("bytes of the rejected write kept in flight: %d", n)
+	}
+}
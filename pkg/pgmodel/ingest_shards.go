@@ -0,0 +1,288 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/allegro/bigcache"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultShardVirtualNodes is the default number of points of every shard on
+// the hash ring. More points spread the metrics more evenly.
+const DefaultShardVirtualNodes = 128
+
+// IngestShardConfig spreads the metrics written across several databases:
+// every metric is written to the database its name hashes to on a
+// consistent hash ring, so that adding a shard only moves the metrics the
+// new shard takes over.
+type IngestShardConfig struct {
+	// LocalShard is the shard name of the database of the connector.
+	LocalShard string `yaml:"local_shard"`
+	// VirtualNodes is the number of points of every shard on the ring. 0
+	// selects DefaultShardVirtualNodes.
+	VirtualNodes int `yaml:"virtual_nodes"`
+	// Shards are the other databases written to.
+	Shards []IngestShard `yaml:"shards"`
+}
+
+// IngestShard is a database written to along with the database of the
+// connector.
+type IngestShard struct {
+	Name string `yaml:"name"`
+	// DBURL is the connection string of the database, as a URL or as
+	// key=value pairs.
+	DBURL string `yaml:"db_url"`
+}
+
+// LoadIngestShardConfig reads a YAML file holding an IngestShardConfig.
+func LoadIngestShardConfig(path string) (*IngestShardConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading ingest shard config: %w", err)
+	}
+	var cfg IngestShardConfig
+	if err = yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing ingest shard config %s: %w", path, err)
+	}
+	if err = cfg.validate(); err != nil {
+		return nil, fmt.Errorf("parsing ingest shard config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func (cfg *IngestShardConfig) validate() error {
+	if cfg.LocalShard == "" {
+		return fmt.Errorf("missing local shard name")
+	}
+	if cfg.VirtualNodes < 0 {
+		return fmt.Errorf("invalid number of virtual nodes %d", cfg.VirtualNodes)
+	}
+	names := map[string]bool{cfg.LocalShard: true}
+	for i, s := range cfg.Shards {
+		switch {
+		case s.Name == "":
+			return fmt.Errorf("shard %d: missing name", i)
+		case names[s.Name]:
+			return fmt.Errorf("shard %d: duplicate name %q", i, s.Name)
+		case s.DBURL == "":
+			return fmt.Errorf("shard %s: missing db_url", s.Name)
+		}
+		names[s.Name] = true
+	}
+	return nil
+}
+
+// FederationConfig returns the configuration reading the shards as a single
+// database, their series being merged.
+func (cfg *IngestShardConfig) FederationConfig() *FederationConfig {
+	f := &FederationConfig{LocalSource: cfg.LocalShard}
+	for _, s := range cfg.Shards {
+		f.Sources = append(f.Sources, FederatedSource{Name: s.Name, DBURL: s.DBURL})
+	}
+	return f
+}
+
+// ShardPool is the connection pool of a shard other than the database of the
+// connector, with the metric cache of its tables.
+type ShardPool struct {
+	Name string
+	Pool *pgxpool.Pool
+	// Metrics caches the table names of the metrics of the shard. A default
+	// cache is used if nil.
+	Metrics MetricCache
+}
+
+// shardRing maps the metric names to the shards by consistent hashing.
+type shardRing struct {
+	// hashes are the sorted points of the shards on the ring, and shards
+	// the index of the shard of every point.
+	hashes []uint64
+	shards []int
+}
+
+func newShardRing(names []string, virtualNodes int) *shardRing {
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultShardVirtualNodes
+	}
+	r := &shardRing{}
+	type point struct {
+		hash  uint64
+		shard int
+	}
+	points := make([]point, 0, len(names)*virtualNodes)
+	for i, name := range names {
+		for v := 0; v < virtualNodes; v++ {
+			points = append(points, point{hash: shardHash(name + "#" + strconv.Itoa(v)), shard: i})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+	for _, p := range points {
+		r.hashes = append(r.hashes, p.hash)
+		r.shards = append(r.shards, p.shard)
+	}
+	return r
+}
+
+// shard returns the index of the shard of the metric: the shard of the first
+// point on the ring at or after the hash of the metric name.
+func (r *shardRing) shard(metric string) int {
+	h := shardHash(metric)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.shards[i]
+}
+
+// shardHash hashes s with FNV-1a, whose high bits barely change between
+// names differing in their last bytes, such as metric_1 and metric_2, mixed
+// with the finalizer of MurmurHash3 to spread them on the ring.
+func shardHash(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// shardedInserter writes every metric to the inserter of its shard. The
+// first shard is the database of the connector.
+type shardedInserter struct {
+	ring    *shardRing
+	names   []string
+	shards  []*pgxInserter
+	writers []*histogramWriter
+}
+
+// newShardedInserter returns the inserter spreading the metrics across the
+// database of local and the shards of cfg, with the histogram writer doing
+// the same. The shards share the in-flight budget of local.
+func newShardedInserter(local *pgxInserter, localWriter *histogramWriter, cfg *Cfg) (*shardedInserter, error) {
+	s := &shardedInserter{
+		names:   []string{cfg.LocalShard},
+		shards:  []*pgxInserter{local},
+		writers: []*histogramWriter{localWriter},
+	}
+	for _, shard := range cfg.Shards {
+		var conn pgxConn = &pgxConnImpl{
+			conn:   shard.Pool,
+			simple: cfg.StatementProtocols.simpleStatements(),
+		}
+		if cfg.BypassTriggers {
+			var err error
+			if conn, err = withTriggerBypass(shard.Pool, conn); err != nil {
+				s.Close()
+				return nil, fmt.Errorf("shard %s: %w", shard.Name, err)
+			}
+		}
+		metrics := shard.Metrics
+		if metrics == nil {
+			cache, _ := bigcache.NewBigCache(DefaultCacheConfig())
+			metrics = &MetricNameCache{Metrics: cache}
+		}
+		pi, err := newPgxInserter(conn, metrics, cfg)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("shard %s: %w", shard.Name, err)
+		}
+		pi.inFlight = local.inFlight
+		pi.priorities = local.priorities
+		s.names = append(s.names, shard.Name)
+		s.shards = append(s.shards, pi)
+		s.writers = append(s.writers, &histogramWriter{conn: conn, cache: pi.seriesCache})
+	}
+	s.ring = newShardRing(s.names, cfg.ShardVirtualNodes)
+	return s, nil
+}
+
+// InsertNewData writes the metrics of rows to their shards concurrently.
+func (s *shardedInserter) InsertNewData(ctx context.Context, rows map[string][]samplesInfo) (uint64, error) {
+	split := make([]map[string][]samplesInfo, len(s.shards))
+	for metric, data := range rows {
+		i := s.ring.shard(metric)
+		if split[i] == nil {
+			split[i] = make(map[string][]samplesInfo)
+		}
+		split[i][metric] = data
+	}
+	return s.each(func(i int) bool { return len(split[i]) > 0 }, func(i int) (uint64, error) {
+		return s.shards[i].InsertNewData(ctx, split[i])
+	})
+}
+
+// insert writes the histograms of series to the shards of their metrics.
+func (s *shardedInserter) insert(ctx context.Context, series []histogramSeries) (uint64, error) {
+	if len(series) == 0 {
+		return 0, nil
+	}
+	split := make([][]histogramSeries, len(s.shards))
+	for _, hs := range series {
+		i := s.ring.shard(hs.labels.metricName)
+		split[i] = append(split[i], hs)
+	}
+	return s.each(func(i int) bool { return len(split[i]) > 0 }, func(i int) (uint64, error) {
+		return s.writers[i].insert(ctx, split[i])
+	})
+}
+
+// each runs write concurrently for the shards having data, returning the
+// number of rows written and the first error.
+func (s *shardedInserter) each(hasData func(i int) bool, write func(i int) (uint64, error)) (uint64, error) {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		total uint64
+		first error
+	)
+	for i := range s.shards {
+		if !hasData(i) {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n, err := write(i)
+			shardWrittenRows.WithLabelValues(s.names[i]).Add(float64(n))
+			mu.Lock()
+			defer mu.Unlock()
+			total += n
+			if err != nil && first == nil {
+				first = fmt.Errorf("shard %s: %w", s.names[i], err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	return total, first
+}
+
+// CompleteMetricCreation completes the creation of the metrics of all the
+// shards.
+func (s *shardedInserter) CompleteMetricCreation() error {
+	for i, shard := range s.shards {
+		if err := shard.CompleteMetricCreation(); err != nil {
+			return fmt.Errorf("shard %s: %w", s.names[i], err)
+		}
+	}
+	return nil
+}
+
+func (s *shardedInserter) Close() {
+	for _, shard := range s.shards {
+		shard.Close()
+	}
+}
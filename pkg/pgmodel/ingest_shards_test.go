@@ -0,0 +1,127 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+const testIngestShardConfig = `
+local_shard: db0
+virtual_nodes: 64
+shards:
+  - name: db1
+    db_url: postgres://db1/timescale
+  - name: db2
+    db_url: host=db2 dbname=timescale
+`
+
+func TestLoadIngestShardConfig(t *testing.T) {
+	cfg, err := LoadIngestShardConfig(writeFederationConfig(t, testIngestShardConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LocalShard != "db0" || cfg.VirtualNodes != 64 || len(cfg.Shards) != 2 || cfg.Shards[1].DBURL != "host=db2 dbname=timescale" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	federation := cfg.FederationConfig()
+	if federation.SourceLabel != "" || federation.LocalSource != "db0" || len(federation.Sources) != 2 || federation.Sources[0].Name != "db1" {
+		t.Errorf("unexpected federation config: %+v", federation)
+	}
+
+	invalid := map[string]string{
+		"no local shard":  "shards:\n  - name: db1\n    db_url: x\n",
+		"unnamed shard":   "local_shard: db0\nshards:\n  - db_url: x\n",
+		"duplicate shard": "local_shard: db0\nshards:\n  - name: db0\n    db_url: x\n",
+		"no db_url":       "local_shard: db0\nshards:\n  - name: db1\n",
+		"negative vnodes": "local_shard: db0\nvirtual_nodes: -1\n",
+		"not a map":       "- db0\n",
+	}
+	for name, content := range invalid {
+		if _, err := LoadIngestShardConfig(writeFederationConfig(t, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestShardRing(t *testing.T) {
+	three := newShardRing([]string{"db0", "db1", "db2"}, 0)
+	four := newShardRing([]string{"db0", "db1", "db2", "db3"}, 0)
+
+	counts := make([]int, 3)
+	moved := 0
+	const metrics = 3000
+	for i := 0; i < metrics; i++ {
+		metric := fmt.Sprintf("metric_%d", i)
+		shard := three.shard(metric)
+		if three.shard(metric) != shard {
+			t.Fatalf("metric %s: shard not stable", metric)
+		}
+		counts[shard]++
+		if after := four.shard(metric); after != shard {
+			if after != 3 {
+				t.Fatalf("metric %s moved from shard %d to shard %d instead of the new shard", metric, shard, after)
+			}
+			moved++
+		}
+	}
+	for i, n := range counts {
+		if n < metrics/6 {
+			t.Errorf("shard %d: only %d metrics of %d", i, n, metrics)
+		}
+	}
+	if moved == 0 || moved > metrics/2 {
+		t.Errorf("unexpected number of metrics moved to the new shard: %d", moved)
+	}
+}
+
+func TestShardedInserter(t *testing.T) {
+	rows := make(map[string][]samplesInfo)
+	tables := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		metric := fmt.Sprintf("metric_%d", i)
+		rows[metric] = []samplesInfo{{}}
+		tables[metric] = "table_" + metric
+	}
+
+	newShard := func() (*pgxInserter, *mockPGXConn) {
+		mock := &mockPGXConn{}
+		pi, err := newPgxInserter(mock, &mockMetricCache{metricCache: tables}, &Cfg{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pi, mock
+	}
+	local, localConn := newShard()
+	other, otherConn := newShard()
+	s := &shardedInserter{
+		ring:    newShardRing([]string{"db0", "db1"}, 0),
+		names:   []string{"db0", "db1"},
+		shards:  []*pgxInserter{local, other},
+		writers: []*histogramWriter{nil, nil},
+	}
+	defer s.Close()
+
+	if _, err := s.InsertNewData(context.Background(), rows); err != nil {
+		t.Fatal(err)
+	}
+	conns := []*mockPGXConn{localConn, otherConn}
+	for i, conn := range conns {
+		for _, table := range conn.CopyFromTableName {
+			metric := table[1][len("table_"):]
+			if shard := s.ring.shard(metric); shard != i {
+				t.Errorf("metric %s written to shard %d instead of %d", metric, i, shard)
+			}
+		}
+	}
+	if n := len(localConn.CopyFromTableName) + len(otherConn.CopyFromTableName); n != len(rows) {
+		t.Errorf("unexpected number of metrics written: got %d wanted %d", n, len(rows))
+	}
+	if len(localConn.CopyFromTableName) == 0 || len(otherConn.CopyFromTableName) == 0 {
+		t.Errorf("expected the metrics to be spread across both shards")
+	}
+}
@@ -5,7 +5,13 @@
 package pgmodel
 
 import (
+	"context"
 	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/prometheus/pkg/labels"
 
 	"github.com/timescale/timescale-prometheus/pkg/prompb"
 )
@@ -24,7 +30,7 @@ type SeriesID int64
 
 // inserter is responsible for inserting label, series and data into the storage.
 type inserter interface {
-	InsertNewData(rows map[string][]samplesInfo) (uint64, error)
+	InsertNewData(ctx context.Context, rows map[string][]samplesInfo) (uint64, error)
 	CompleteMetricCreation() error
 	Close()
 }
@@ -40,31 +46,163 @@ type Cache interface {
 	SetSeries(lset Labels, id SeriesID) error
 }
 
+// seriesEvicter is implemented by the caches which can forget series.
+type seriesEvicter interface {
+	EvictSeries(ids []SeriesID)
+}
+
+// metricSeriesEvicter is implemented by the caches which can forget all the
+// series of a metric, or all the series.
+type metricSeriesEvicter interface {
+	EvictMetricSeries(metric string)
+	Reset()
+}
+
 type samplesInfo struct {
 	labels   *Labels
 	seriesID SeriesID
 	samples  []prompb.Sample
+	// microseconds is set when the timestamps of the samples are in
+	// microseconds rather than milliseconds.
+	microseconds bool
 }
 
 // DBIngestor ingest the TimeSeries data into Timescale database.
 type DBIngestor struct {
 	cache Cache
 	db    inserter
+	// ingested maps a metric name to a *uint64 counting the samples
+	// accepted for it since startup.
+	ingested sync.Map
+	// staleMarkers is what is done with the staleness markers.
+	staleMarkers StaleMarkerPolicy
+	// bounds rejects the samples too old or too far in the future.
+	bounds *sampleBounds
+	// microsecondMetrics matches the metrics whose timestamps are in
+	// microseconds.
+	microsecondMetrics *regexp.Regexp
+	// labelValidation is what is done with the series with invalid labels,
+	// once relabeled.
+	labelValidation LabelValidation
+	// labelLimits rejects the series with too many or too long labels.
+	labelLimits LabelLimits
+	// relabeler drops or rewrites the series before anything else, but for
+	// the external labels.
+	relabeler *Relabeler
+	// externalLabels are added to the series lacking them, before relabeling
+	// as in Prometheus.
+	externalLabels labels.Labels
+	// seriesLimit drops the series over the limit of their metric.
+	seriesLimit *seriesLimiter
+	// histograms writes the native histograms.
+	histograms histogramInserter
 }
 
 // Ingest transforms and ingests the timeseries data into Timescale database.
-func (i *DBIngestor) Ingest(tts []prompb.TimeSeries, req *prompb.WriteRequest) (uint64, error) {
+func (i *DBIngestor) Ingest(ctx context.Context, tts []prompb.TimeSeries, req *prompb.WriteRequest) (uint64, error) {
+	tts = addExternalLabels(tts, i.externalLabels)
+	tts, relabelDropped := i.relabeler.apply(tts)
+	tts, invalid := validateLabels(tts, i.labelValidation, i.labelLimits)
+	histograms, err := i.parseHistograms(tts)
+	if err != nil {
+		FinishWriteRequest(req)
+		return 0, err
+	}
 	data, totalRows, err := i.parseData(tts, req)
 
 	if err != nil {
 		return 0, err
 	}
 
-	rowsInserted, err := i.db.InsertNewData(data)
+	outOfBounds := i.bounds.enforce(data)
+	if outOfBounds != nil {
+		totalRows -= outOfBounds.Rejected()
+	}
+	overLimit := i.seriesLimit.enforce(data)
+	totalRows -= overLimit
+	i.countIngested(data)
+
+	dropped := relabelDropped + overLimit
+	switch i.staleMarkers {
+	case StaleMarkersDrop:
+		markers := dropStaleMarkers(data)
+		totalRows -= markers
+		dropped += markers
+	case StaleMarkersLiveness:
+		// The insert routines extract the markers once the series ids are known.
+	default:
+		countStoredStaleMarkers(data)
+	}
+
+	rowsInserted, err := i.db.InsertNewData(ctx, data)
 	if err == nil && int(rowsInserted) != totalRows {
 		return rowsInserted, fmt.Errorf("Failed to insert all the data! Expected: %d, Got: %d", totalRows, rowsInserted)
 	}
-	return rowsInserted, err
+	if err == nil && i.histograms != nil {
+		var histogramsInserted uint64
+		histogramsInserted, err = i.histograms.insert(ctx, histograms)
+		rowsInserted += histogramsInserted
+	}
+	if err == nil && invalid != nil {
+		err = invalid
+	}
+	if err == nil && outOfBounds != nil {
+		err = outOfBounds
+	}
+	// Dropped series and markers are acknowledged like the inserted samples.
+	return rowsInserted + uint64(dropped), err
+}
+
+func (i *DBIngestor) countIngested(data map[string][]samplesInfo) {
+	for metricName, series := range data {
+		n := 0
+		for _, s := range series {
+			n += len(s.samples)
+		}
+		counter, ok := i.ingested.Load(metricName)
+		if !ok {
+			counter, _ = i.ingested.LoadOrStore(metricName, new(uint64))
+		}
+		atomic.AddUint64(counter.(*uint64), uint64(n))
+	}
+}
+
+// IngestedSamples returns the number of samples accepted per metric since
+// startup. Callers compute ingest rates from the difference between two
+// snapshots.
+func (i *DBIngestor) IngestedSamples() map[string]uint64 {
+	res := make(map[string]uint64)
+	i.ingested.Range(func(key, value interface{}) bool {
+		res[key.(string)] = atomic.LoadUint64(value.(*uint64))
+		return true
+	})
+	return res
+}
+
+// ReloadRelabelConfig reads the relabel configs again, if relabeling is
+// enabled.
+func (i *DBIngestor) ReloadRelabelConfig() error {
+	return i.relabeler.Reload()
+}
+
+// EvictSeries removes deleted series from the series cache.
+func (i *DBIngestor) EvictSeries(ids []SeriesID) {
+	if c, ok := i.cache.(seriesEvicter); ok {
+		c.EvictSeries(ids)
+	}
+}
+
+// EvictMetricSeries removes the series of the metric from the series cache,
+// or all of them if metric is empty.
+func (i *DBIngestor) EvictMetricSeries(metric string) {
+	c, ok := i.cache.(metricSeriesEvicter)
+	switch {
+	case !ok:
+	case metric == "":
+		c.Reset()
+	default:
+		c.EvictMetricSeries(metric)
+	}
 }
 
 func (i *DBIngestor) CompleteMetricCreation() error {
@@ -88,9 +226,10 @@ func (i *DBIngestor) parseData(tts []prompb.TimeSeries, req *prompb.WriteRequest
 			return nil, rows, ErrNoMetricName
 		}
 		sample := samplesInfo{
-			seriesLabels,
-			-1, //sentinel marking the seriesId as unset
-			t.Samples,
+			labels:       seriesLabels,
+			seriesID:     -1, //sentinel marking the seriesId as unset
+			samples:      t.Samples,
+			microseconds: i.microsecondMetrics != nil && i.microsecondMetrics.MatchString(metricName),
 		}
 		rows += len(t.Samples)
 
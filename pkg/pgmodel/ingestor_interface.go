@@ -4,12 +4,25 @@
 
 package pgmodel
 
-import "github.com/timescale/timescale-prometheus/pkg/prompb"
+import (
+	"context"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
 
 // DBInserter is responsible for ingesting the TimeSeries protobuf structs and
 // storing them in the database.
 type DBInserter interface {
 	// Ingest takes an array of TimeSeries and attepts to store it into the database.
 	// Returns the number of metrics ingested and any error encountered before finishing.
-	Ingest([]prompb.TimeSeries, *prompb.WriteRequest) (uint64, error)
+	// Canceling the context stops waiting for room in the ingest pipeline; samples
+	// already handed to the insert routines are still written.
+	Ingest(context.Context, []prompb.TimeSeries, *prompb.WriteRequest) (uint64, error)
+}
+
+// IngestStatsReporter reports how many samples were accepted per metric.
+type IngestStatsReporter interface {
+	// IngestedSamples returns the number of samples accepted per metric
+	// since startup.
+	IngestedSamples() map[string]uint64
 }
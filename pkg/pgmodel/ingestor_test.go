@@ -4,7 +4,9 @@
 package pgmodel
 
 import (
+	"context"
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/timescale/timescale-prometheus/pkg/prompb"
@@ -45,15 +47,15 @@ func (m *mockInserter) Close() {
 
 }
 
-func (m *mockInserter) InsertNewData(rows map[string][]samplesInfo) (uint64, error) {
-	return m.InsertData(rows)
+func (m *mockInserter) InsertNewData(ctx context.Context, rows map[string][]samplesInfo) (uint64, error) {
+	return m.InsertData(ctx, rows)
 }
 
 func (m *mockInserter) CompleteMetricCreation() error {
 	return nil
 }
 
-func (m *mockInserter) InsertData(rows map[string][]samplesInfo) (uint64, error) {
+func (m *mockInserter) InsertData(_ context.Context, rows map[string][]samplesInfo) (uint64, error) {
 	for _, v := range rows {
 		for i, si := range v {
 			id, ok := m.insertedSeries[si.labels.String()]
@@ -254,7 +256,7 @@ func TestDBIngestorIngest(t *testing.T) {
 				db:    &inserter,
 			}
 
-			count, err := i.Ingest(c.metrics, NewWriteRequest())
+			count, err := i.Ingest(context.Background(), c.metrics, NewWriteRequest())
 
 			if err != nil {
 				if c.insertSeriesErr != nil && err != c.insertSeriesErr {
@@ -294,3 +296,28 @@ func TestDBIngestorIngest(t *testing.T) {
 		})
 	}
 }
+
+func TestDBIngestorIngestedSamples(t *testing.T) {
+	i := DBIngestor{
+		cache: &mockCache{seriesCache: make(map[string]SeriesID)},
+		db:    &mockInserter{insertedSeries: make(map[string]SeriesID)},
+	}
+
+	ingest := func(metric string, samples int) {
+		ts := prompb.TimeSeries{
+			Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: metric}},
+			Samples: make([]prompb.Sample, samples),
+		}
+		if _, err := i.Ingest(context.Background(), []prompb.TimeSeries{ts}, NewWriteRequest()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ingest("first", 2)
+	ingest("second", 1)
+	ingest("first", 3)
+
+	expected := map[string]uint64{"first": 5, "second": 1}
+	if got := i.IngestedSamples(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected ingested samples: got %v, want %v", got, expected)
+	}
+}
@@ -0,0 +1,92 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	instanceHeartbeatSQL = `INSERT INTO ` + catalogSchema + `.connector_instance(instance_id, hostname, version, commit_hash, started_at, last_heartbeat)
+	VALUES ($1, $2, $3, $4, $5, now())
+	ON CONFLICT (instance_id) DO UPDATE SET
+		hostname = EXCLUDED.hostname,
+		version = EXCLUDED.version,
+		commit_hash = EXCLUDED.commit_hash,
+		started_at = EXCLUDED.started_at,
+		last_heartbeat = EXCLUDED.last_heartbeat`
+	listInstancesSQL = `SELECT instance_id, hostname, version, commit_hash, started_at, last_heartbeat
+	FROM ` + catalogSchema + `.connector_instance
+	ORDER BY instance_id`
+)
+
+// InstanceInfo describes a single running connector.
+type InstanceInfo struct {
+	ID            string    `json:"id"`
+	Hostname      string    `json:"hostname"`
+	Version       string    `json:"version"`
+	CommitHash    string    `json:"commit_hash"`
+	StartedAt     time.Time `json:"started_at"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+}
+
+// InstanceLister lists the connector instances known to the database.
+type InstanceLister interface {
+	Instances() ([]InstanceInfo, error)
+}
+
+// InstanceRegistry records the heartbeat of this connector instance in the
+// database and lists all registered instances.
+type InstanceRegistry struct {
+	conn pgxConn
+	self InstanceInfo
+}
+
+// NewInstanceRegistry returns a registry for the instance described by self.
+func NewInstanceRegistry(c *pgxpool.Pool, self InstanceInfo) *InstanceRegistry {
+	return &InstanceRegistry{
+		conn: &pgxConnImpl{
+			conn: c,
+		},
+		self: self,
+	}
+}
+
+// Heartbeat registers the instance or refreshes its last heartbeat.
+func (r *InstanceRegistry) Heartbeat() error {
+	_, err := r.conn.Exec(
+		context.Background(),
+		instanceHeartbeatSQL,
+		r.self.ID,
+		r.self.Hostname,
+		r.self.Version,
+		r.self.CommitHash,
+		r.self.StartedAt,
+	)
+	return err
+}
+
+// Instances returns all the instances that have ever sent a heartbeat.
+func (r *InstanceRegistry) Instances() ([]InstanceInfo, error) {
+	rows, err := r.conn.Query(context.Background(), listInstancesSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	instances := make([]InstanceInfo, 0)
+	for rows.Next() {
+		var i InstanceInfo
+		if err := rows.Scan(&i.ID, &i.Hostname, &i.Version, &i.CommitHash, &i.StartedAt, &i.LastHeartbeat); err != nil {
+			return nil, err
+		}
+		instances = append(instances, i)
+	}
+
+	return instances, rows.Err()
+}
@@ -0,0 +1,127 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// failingRowsConn returns rows failing with err once read.
+type failingRowsConn struct {
+	*mockPGXConn
+	err error
+}
+
+func (c failingRowsConn) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := c.mockPGXConn.Query(ctx, sql, args...)
+	return failingRows{Rows: rows, err: c.err}, err
+}
+
+type failingRows struct {
+	pgx.Rows
+	err error
+}
+
+func (r failingRows) Err() error {
+	return r.err
+}
+
+func TestInstanceRegistryHeartbeat(t *testing.T) {
+	started := time.Unix(1000, 0)
+	mock := &mockPGXConn{}
+	r := &InstanceRegistry{
+		conn: mock,
+		self: InstanceInfo{ID: "id", Hostname: "host", Version: "v", CommitHash: "c", StartedAt: started},
+	}
+
+	if err := r.Heartbeat(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mock.ExecSQLs) != 1 || mock.ExecSQLs[0] != instanceHeartbeatSQL {
+		t.Fatalf("unexpected heartbeat SQL: %v", mock.ExecSQLs)
+	}
+
+	expected := []interface{}{"id", "host", "v", "c", started}
+	if !reflect.DeepEqual(mock.ExecArgs[0], expected) {
+		t.Errorf("unexpected heartbeat args:\ngot\n%v\nwanted\n%v", mock.ExecArgs[0], expected)
+	}
+
+	mock.ExecErr = fmt.Errorf("some error")
+	if err := r.Heartbeat(); err != mock.ExecErr {
+		t.Errorf("unexpected error:\ngot\n%v\nwanted\n%v", err, mock.ExecErr)
+	}
+}
+
+func TestInstanceRegistryInstances(t *testing.T) {
+	started := time.Unix(1000, 0)
+	heartbeat := time.Unix(2000, 0)
+	testCases := []struct {
+		name         string
+		queryResults []rowResults
+		queryErr     map[int]error
+		rowsErr      error
+		expected     []InstanceInfo
+	}{
+		{
+			name:     "No instances",
+			expected: []InstanceInfo{},
+		},
+		{
+			name: "Two instances",
+			queryResults: []rowResults{
+				{
+					{"a", "host-a", "v1", "c1", started, heartbeat},
+					{"b", "host-b", "v2", "c2", started, heartbeat},
+				},
+			},
+			expected: []InstanceInfo{
+				{ID: "a", Hostname: "host-a", Version: "v1", CommitHash: "c1", StartedAt: started, LastHeartbeat: heartbeat},
+				{ID: "b", Hostname: "host-b", Version: "v2", CommitHash: "c2", StartedAt: started, LastHeartbeat: heartbeat},
+			},
+		},
+		{
+			name:     "Query error",
+			queryErr: map[int]error{0: fmt.Errorf("some error")},
+		},
+		{
+			name: "Error while reading",
+			queryResults: []rowResults{
+				{{"a", "host-a", "v1", "c1", started, heartbeat}},
+			},
+			rowsErr: fmt.Errorf("connection lost"),
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &mockPGXConn{
+				QueryResults: c.queryResults,
+				QueryErr:     c.queryErr,
+			}
+			r := &InstanceRegistry{conn: failingRowsConn{mockPGXConn: mock, err: c.rowsErr}}
+
+			instances, err := r.Instances()
+			if err != nil {
+				if err != c.queryErr[0] && err != c.rowsErr {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if c.queryErr != nil || c.rowsErr != nil {
+				t.Fatalf("expected an error, got instances %v", instances)
+			}
+
+			if !reflect.DeepEqual(instances, c.expected) {
+				t.Errorf("unexpected instances:\ngot\n%v\nwanted\n%v", instances, c.expected)
+			}
+		})
+	}
+}
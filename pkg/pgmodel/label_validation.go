@@ -0,0 +1,239 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	invalidReasonNoMetricName   = "empty_metric_name"
+	invalidReasonLabelName      = "invalid_label_name"
+	invalidReasonLabelValue     = "invalid_label_value"
+	invalidReasonDuplicateLabel = "duplicate_label_name"
+	invalidReasonTooLong        = "series_too_long"
+	invalidReasonTooManyLabels  = "too_many_labels"
+	invalidReasonNameTooLong    = "label_name_too_long"
+	invalidReasonValueTooLong   = "label_value_too_long"
+
+	invalidActionRejected  = "rejected"
+	invalidActionSanitized = "sanitized"
+)
+
+// LabelValidation is what is done with the series whose labels cannot be
+// stored as they are: invalid label names, and label values which are not
+// valid UTF-8 or hold NUL characters. The series without a metric name, with
+// duplicate label names or too long are always rejected.
+type LabelValidation string
+
+const (
+	// LabelValidationReject rejects the series with invalid labels.
+	LabelValidationReject LabelValidation = "reject"
+	// LabelValidationSanitize replaces the characters of the label names
+	// other than letters, digits and underscores with underscores, and the
+	// invalid UTF-8 sequences of the label values with U+FFFD, dropping
+	// their NUL characters.
+	LabelValidationSanitize LabelValidation = "sanitize"
+)
+
+func (v LabelValidation) validate() error {
+	switch v {
+	case "", LabelValidationReject, LabelValidationSanitize:
+		return nil
+	}
+	return fmt.Errorf("invalid label validation %q: must be %s or %s", v, LabelValidationReject, LabelValidationSanitize)
+}
+
+// LabelLimits bounds the labels of the ingested series, protecting the
+// catalog from pathological scrape targets. A zero limit is disabled. The
+// series over a limit are rejected, whatever the LabelValidation.
+type LabelLimits struct {
+	// MaxLabels is the maximum number of labels of a series, including its
+	// metric name.
+	MaxLabels int
+	// MaxNameLength and MaxValueLength are the maximum lengths in bytes of
+	// the label names and values.
+	MaxNameLength  int
+	MaxValueLength int
+}
+
+func (l LabelLimits) validate() error {
+	if l.MaxLabels < 0 || l.MaxNameLength < 0 || l.MaxValueLength < 0 {
+		return fmt.Errorf("invalid label limits: max labels %d, max name length %d, max value length %d", l.MaxLabels, l.MaxNameLength, l.MaxValueLength)
+	}
+	return nil
+}
+
+// InvalidSeriesError reports the series of a write rejected because of
+// their labels, before any SQL is run. The other series of the write are
+// stored.
+type InvalidSeriesError struct {
+	// Reasons counts the rejected series by reason.
+	Reasons map[string]int
+	// Samples is the number of samples and histograms of the rejected
+	// series.
+	Samples int
+	// First describes the first series rejected.
+	First string
+}
+
+func (e *InvalidSeriesError) Error() string {
+	reasons := make([]string, 0, len(e.Reasons))
+	for reason, n := range e.Reasons {
+		reasons = append(reasons, fmt.Sprintf("%d %s", n, reason))
+	}
+	sort.Strings(reasons)
+	return fmt.Sprintf("%d invalid series rejected (%s), first: %s", e.Series(), strings.Join(reasons, ", "), e.First)
+}
+
+// Is tells that a write rejecting series without a metric name failed with
+// ErrNoMetricName, as it did before the labels were validated.
+func (e *InvalidSeriesError) Is(target error) bool {
+	return target == ErrNoMetricName && e.Reasons[invalidReasonNoMetricName] > 0
+}
+
+// Series returns the number of rejected series.
+func (e *InvalidSeriesError) Series() int {
+	n := 0
+	for _, c := range e.Reasons {
+		n += c
+	}
+	return n
+}
+
+// validateLabels removes the series of tts with invalid labels, or over the
+// limits, or sanitizes them, sorting the labels of every series. It returns
+// the series kept, and an error counting the rejected ones or nil.
+func validateLabels(tts []prompb.TimeSeries, policy LabelValidation, limits LabelLimits) ([]prompb.TimeSeries, *InvalidSeriesError) {
+	var rejected *InvalidSeriesError
+	kept := tts[:0]
+	for _, t := range tts {
+		if len(t.Samples) == 0 && len(t.Histograms) == 0 {
+			continue
+		}
+		reason, detail := checkLabelLimits(&t, limits)
+		if reason == "" {
+			reason, detail = checkLabels(&t, policy == LabelValidationSanitize)
+		}
+		if reason == "" {
+			kept = append(kept, t)
+			continue
+		}
+		invalidSeries.WithLabelValues(reason, invalidActionRejected).Inc()
+		if rejected == nil {
+			rejected = &InvalidSeriesError{Reasons: make(map[string]int), First: detail}
+		}
+		rejected.Reasons[reason]++
+		rejected.Samples += len(t.Samples) + len(t.Histograms)
+	}
+	return kept, rejected
+}
+
+// checkLabelLimits returns the limit the labels of t are over, along with a
+// description of the series, or an empty reason.
+func checkLabelLimits(t *prompb.TimeSeries, limits LabelLimits) (string, string) {
+	if limits.MaxLabels > 0 && len(t.Labels) > limits.MaxLabels {
+		return invalidReasonTooManyLabels, fmt.Sprintf("series %s with %d labels, max %d", seriesMetricName(t.Labels), len(t.Labels), limits.MaxLabels)
+	}
+	for _, l := range t.Labels {
+		if limits.MaxNameLength > 0 && len(l.Name) > limits.MaxNameLength {
+			return invalidReasonNameTooLong, fmt.Sprintf("label name %.32q of %d bytes in series %s, max %d", l.Name, len(l.Name), seriesMetricName(t.Labels), limits.MaxNameLength)
+		}
+		if limits.MaxValueLength > 0 && len(l.Value) > limits.MaxValueLength {
+			return invalidReasonValueTooLong, fmt.Sprintf("value of label %.32q of %d bytes in series %s, max %d", l.Name, len(l.Value), seriesMetricName(t.Labels), limits.MaxValueLength)
+		}
+	}
+	return "", ""
+}
+
+// seriesMetricName returns the metric name of a series, for the errors.
+func seriesMetricName(ls []prompb.Label) string {
+	for _, l := range ls {
+		if l.Name == MetricNameLabelName {
+			return fmt.Sprintf("%.64q", l.Value)
+		}
+	}
+	return "without a metric name"
+}
+
+// checkLabels returns the reason the labels of t are invalid, along with a
+// description of the series, or an empty reason if they are valid, possibly
+// once sanitized.
+func checkLabels(t *prompb.TimeSeries, sanitize bool) (string, string) {
+	metricName := ""
+	length := len(t.Labels) * 4
+	for i := range t.Labels {
+		l := &t.Labels[i]
+		if !model.LabelName(l.Name).IsValid() {
+			if !sanitize || l.Name == "" {
+				return invalidReasonLabelName, fmt.Sprintf("invalid label name %q", l.Name)
+			}
+			l.Name = sanitizeLabelName(l.Name)
+			invalidSeries.WithLabelValues(invalidReasonLabelName, invalidActionSanitized).Inc()
+		}
+		if !utf8.ValidString(l.Value) || strings.IndexByte(l.Value, 0) >= 0 {
+			if !sanitize {
+				return invalidReasonLabelValue, fmt.Sprintf("invalid value %q of label %s", l.Value, l.Name)
+			}
+			l.Value = strings.ReplaceAll(strings.ToValidUTF8(l.Value, "\uFFFD"), "\x00", "")
+			invalidSeries.WithLabelValues(invalidReasonLabelValue, invalidActionSanitized).Inc()
+		}
+		if l.Name == MetricNameLabelName {
+			metricName = l.Value
+		}
+		length += len(l.Name) + len(l.Value)
+	}
+	if metricName == "" {
+		return invalidReasonNoMetricName, fmt.Sprintf("series %s without a metric name", formatLabels(t.Labels))
+	}
+	if length > math.MaxUint16 {
+		return invalidReasonTooLong, fmt.Sprintf("series of metric %s of length %d, max length %d", metricName, length, math.MaxUint16)
+	}
+
+	sort.Slice(t.Labels, func(i, j int) bool { return t.Labels[i].Name < t.Labels[j].Name })
+	for i := 1; i < len(t.Labels); i++ {
+		if t.Labels[i].Name == t.Labels[i-1].Name {
+			return invalidReasonDuplicateLabel, fmt.Sprintf("duplicate label %s in series of metric %s", t.Labels[i].Name, metricName)
+		}
+	}
+	return "", ""
+}
+
+// sanitizeLabelName replaces the characters of name other than letters,
+// digits and underscores with underscores, prefixing it with an underscore
+// if it starts with a digit.
+func sanitizeLabelName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func formatLabels(ls []prompb.Label) string {
+	pairs := make([]string, len(ls))
+	for i, l := range ls {
+		pairs[i] = fmt.Sprintf("%s=%q", l.Name, l.Value)
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
@@ -0,0 +1,189 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestValidateLabels(t *testing.T) {
+	series := func(labels ...string) prompb.TimeSeries {
+		ts := prompb.TimeSeries{Samples: []prompb.Sample{{Timestamp: 1}}}
+		for i := 0; i < len(labels); i += 2 {
+			ts.Labels = append(ts.Labels, prompb.Label{Name: labels[i], Value: labels[i+1]})
+		}
+		return ts
+	}
+
+	testCases := []struct {
+		name      string
+		series    prompb.TimeSeries
+		reason    string
+		sanitized []prompb.Label
+	}{
+		{
+			name:      "valid",
+			series:    series("job", "a", MetricNameLabelName, "up"),
+			sanitized: []prompb.Label{{Name: MetricNameLabelName, Value: "up"}, {Name: "job", Value: "a"}},
+		},
+		{
+			name:   "no metric name",
+			series: series("job", "a"),
+			reason: invalidReasonNoMetricName,
+		},
+		{
+			name:   "empty metric name",
+			series: series(MetricNameLabelName, ""),
+			reason: invalidReasonNoMetricName,
+		},
+		{
+			name:      "invalid label name",
+			series:    series(MetricNameLabelName, "up", "http.status", "200", "1st", "x"),
+			reason:    invalidReasonLabelName,
+			sanitized: []prompb.Label{{Name: "_1st", Value: "x"}, {Name: MetricNameLabelName, Value: "up"}, {Name: "http_status", Value: "200"}},
+		},
+		{
+			name:   "empty label name",
+			series: series(MetricNameLabelName, "up", "", "x"),
+			reason: invalidReasonLabelName,
+		},
+		{
+			name:      "invalid UTF-8",
+			series:    series(MetricNameLabelName, "up", "path", "/a\xff\x00b"),
+			reason:    invalidReasonLabelValue,
+			sanitized: []prompb.Label{{Name: MetricNameLabelName, Value: "up"}, {Name: "path", Value: "/a\uFFFDb"}},
+		},
+		{
+			name:   "duplicate label name",
+			series: series(MetricNameLabelName, "up", "job", "a", "job", "b"),
+			reason: invalidReasonDuplicateLabel,
+		},
+		{
+			name:   "too long",
+			series: series(MetricNameLabelName, "up", "blob", strings.Repeat("x", 1<<16)),
+			reason: invalidReasonTooLong,
+		},
+	}
+	for _, c := range testCases {
+		for _, policy := range []LabelValidation{LabelValidationReject, LabelValidationSanitize} {
+			ts := c.series
+			ts.Labels = append([]prompb.Label(nil), c.series.Labels...)
+			kept, err := validateLabels([]prompb.TimeSeries{ts}, policy, LabelLimits{})
+
+			reason := c.reason
+			if policy == LabelValidationSanitize && c.sanitized != nil {
+				reason = ""
+			}
+			if reason == "" {
+				if err != nil || len(kept) != 1 || !reflect.DeepEqual(kept[0].Labels, c.sanitized) {
+					t.Errorf("%s, %s: unexpected result %v, %v", c.name, policy, kept, err)
+				}
+				continue
+			}
+			if len(kept) != 0 || err == nil || err.Reasons[reason] != 1 || err.Samples != 1 || err.First == "" {
+				t.Errorf("%s, %s: unexpected result %v, %+v", c.name, policy, kept, err)
+			}
+		}
+	}
+}
+
+func TestIngestInvalidSeries(t *testing.T) {
+	inserter := &mockInserter{insertedSeries: make(map[string]SeriesID)}
+	i := DBIngestor{db: inserter, cache: &mockCache{seriesCache: make(map[string]SeriesID)}}
+
+	tts := []prompb.TimeSeries{
+		{
+			Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "up"}},
+			Samples: []prompb.Sample{{Timestamp: 1}, {Timestamp: 2}},
+		},
+		{
+			Labels:  []prompb.Label{{Name: "job", Value: "a"}},
+			Samples: []prompb.Sample{{Timestamp: 1}},
+		},
+		{
+			Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "up"}, {Name: "job-name", Value: "a"}},
+			Samples: []prompb.Sample{{Timestamp: 1}},
+		},
+	}
+	rejectedBefore := testutil.ToFloat64(invalidSeries.WithLabelValues(invalidReasonLabelName, invalidActionRejected))
+	count, err := i.Ingest(context.Background(), tts, NewWriteRequest())
+	var invalid *InvalidSeriesError
+	if !errors.As(err, &invalid) || invalid.Series() != 2 || invalid.Samples != 2 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !errors.Is(err, ErrNoMetricName) {
+		t.Errorf("expected the error to match ErrNoMetricName")
+	}
+	if count != 2 {
+		t.Errorf("unexpected number of inserted samples: got %d wanted 2", count)
+	}
+	if n := testutil.ToFloat64(invalidSeries.WithLabelValues(invalidReasonLabelName, invalidActionRejected)) - rejectedBefore; n != 1 {
+		t.Errorf("unexpected invalid label names counted: %v", n)
+	}
+	if data := inserter.insertedData[0]; len(data) != 1 || len(data["up"]) != 1 {
+		t.Errorf("unexpected inserted data: %+v", data)
+	}
+
+	if err := LabelValidation("fix").validate(); err == nil {
+		t.Error("expected an error for an unknown label validation")
+	}
+}
+
+func TestLabelLimits(t *testing.T) {
+	limits := LabelLimits{MaxLabels: 3, MaxNameLength: 10, MaxValueLength: 8}
+	testCases := []struct {
+		name   string
+		labels []prompb.Label
+		reason string
+	}{
+		{
+			name:   "within the limits",
+			labels: []prompb.Label{{Name: MetricNameLabelName, Value: "up"}, {Name: "job", Value: "12345678"}, {Name: "instance", Value: "a"}},
+		},
+		{
+			name:   "too many labels",
+			labels: []prompb.Label{{Name: MetricNameLabelName, Value: "up"}, {Name: "a", Value: "1"}, {Name: "b", Value: "2"}, {Name: "c", Value: "3"}},
+			reason: invalidReasonTooManyLabels,
+		},
+		{
+			name:   "label name too long",
+			labels: []prompb.Label{{Name: MetricNameLabelName, Value: "up"}, {Name: "very_long_name", Value: "1"}},
+			reason: invalidReasonNameTooLong,
+		},
+		{
+			name:   "label value too long",
+			labels: []prompb.Label{{Name: MetricNameLabelName, Value: "up"}, {Name: "job", Value: "123456789"}},
+			reason: invalidReasonValueTooLong,
+		},
+	}
+	for _, c := range testCases {
+		// The limits apply whatever the label validation.
+		for _, policy := range []LabelValidation{LabelValidationReject, LabelValidationSanitize} {
+			tts := []prompb.TimeSeries{{Labels: append([]prompb.Label(nil), c.labels...), Samples: []prompb.Sample{{Timestamp: 1}}}}
+			kept, err := validateLabels(tts, policy, limits)
+			if c.reason == "" {
+				if err != nil || len(kept) != 1 {
+					t.Errorf("%s, %s: unexpected result %v, %v", c.name, policy, kept, err)
+				}
+				continue
+			}
+			if len(kept) != 0 || err == nil || err.Reasons[c.reason] != 1 || !strings.Contains(err.First, `"up"`) {
+				t.Errorf("%s, %s: unexpected result %v, %+v", c.name, policy, kept, err)
+			}
+		}
+	}
+
+	if err := (LabelLimits{MaxLabels: -1}).validate(); err == nil {
+		t.Error("expected an error for a negative limit")
+	}
+}
@@ -7,6 +7,7 @@ package pgmodel
 import (
 	"encoding/binary"
 	"fmt"
+	"hash/fnv"
 	"math"
 	"sort"
 	"strings"
@@ -22,22 +23,90 @@ type Labels struct {
 	values     []string
 	metricName string
 	str        string
+	// fingerprint is a hash of str. Label sets of pods can make str several
+	// KB long, so the hot paths key on the fingerprint and only compare str
+	// to rule out collisions.
+	fingerprint uint64
 }
 
-var LabelsInterner = sync.Map{}
+// labelsInterner shares a single Labels between the writes and reads of a
+// series. It is bounded by keeping two generations of at most size labels:
+// once the current one is full, it becomes the previous one, whose labels
+// are moved back to the current generation when used again and dropped
+// along with it otherwise.
+type labelsInterner struct {
+	lock     sync.RWMutex
+	current  map[string]*Labels
+	previous map[string]*Labels
+	size     int
+}
+
+func newLabelsInterner(size int) *labelsInterner {
+	return &labelsInterner{
+		current:  make(map[string]*Labels),
+		previous: make(map[string]*Labels),
+		size:     size,
+	}
+}
 
-func GetLabels(str string) (l *Labels) {
-	val, ok := LabelsInterner.Load(str)
+// interner is sized after the largest series cache, so that the labels of
+// the cached series stay interned.
+var interner = newLabelsInterner(DefaultSeriesCacheSize)
+
+func (i *labelsInterner) get(str string) *Labels {
+	i.lock.RLock()
+	l, ok := i.current[str]
 	if !ok {
-		return
+		l = i.previous[str]
+	}
+	i.lock.RUnlock()
+	if ok || l == nil {
+		return l
+	}
+	return i.set(str, l)
+}
+
+// set interns lset, unless labels with the same string are interned
+// already, which are returned instead.
+func (i *labelsInterner) set(str string, lset *Labels) *Labels {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if l, ok := i.current[str]; ok {
+		return l
+	}
+	if l, ok := i.previous[str]; ok {
+		lset = l
+		delete(i.previous, str)
+	}
+	if len(i.current) >= i.size {
+		i.previous = i.current
+		i.current = make(map[string]*Labels, i.size)
+	}
+	i.current[str] = lset
+	return lset
+}
+
+// grow raises the number of labels per generation to size.
+func (i *labelsInterner) grow(size int) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if size > i.size {
+		i.size = size
 	}
-	l = val.(*Labels)
-	return
+}
+
+func (i *labelsInterner) entries() int {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return len(i.current) + len(i.previous)
+}
+
+func GetLabels(str string) *Labels {
+	return interner.get(str)
 }
 
 func SetLabels(str string, lset *Labels) *Labels {
-	val, _ := LabelsInterner.LoadOrStore(str, lset)
-	return val.(*Labels)
+	return interner.set(str, lset)
 }
 
 // LabelsFromSlice converts a labels.Labels to a Labels object
@@ -120,6 +189,7 @@ func labelProtosToLabels(labelPairs []prompb.Label) (*Labels, string, error) {
 	if labels == nil {
 		labels = new(Labels)
 		labels.str = str
+		labels.fingerprint = fingerprint(str)
 		labels.names = make([]string, len(labelPairs))
 		labels.values = make([]string, len(labelPairs))
 		for i, l := range labelPairs {
@@ -128,25 +198,48 @@ func labelProtosToLabels(labelPairs []prompb.Label) (*Labels, string, error) {
 			if l.Name == MetricNameLabelName {
 				labels.metricName = l.Value
 			}
-			labels = SetLabels(str, labels)
 		}
+		labels = SetLabels(str, labels)
 	}
 
 	return labels, labels.metricName, err
 }
 
+// fingerprint hashes the string representation of a label set.
+func fingerprint(str string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(str))
+	return h.Sum64()
+}
+
 func (l *Labels) String() string {
 	return l.str
 }
 
-// Compare returns a comparison int between two Labels
+// Fingerprint returns a fixed-size hash of the labels. Different labels may
+// share a fingerprint, Equal tells them apart.
+func (l *Labels) Fingerprint() uint64 {
+	return l.fingerprint
+}
+
+// Compare returns a comparison int between two Labels. Labels are ordered by
+// fingerprint first, so that comparing different labels rarely reads their
+// strings, and interned labels are equal without reading them at all.
 func (l *Labels) Compare(b *Labels) int {
+	switch {
+	case l == b:
+		return 0
+	case l.fingerprint < b.fingerprint:
+		return -1
+	case l.fingerprint > b.fingerprint:
+		return 1
+	}
 	return strings.Compare(l.str, b.str)
 }
 
 // Equal returns true if two Labels are equal
 func (l *Labels) Equal(b *Labels) bool {
-	return l.str == b.str
+	return l == b || (l.fingerprint == b.fingerprint && l.str == b.str)
 }
 
 // Labels implements sort.Interface
@@ -168,3 +261,19 @@ func (l *Labels) Swap(i, j int) {
 	l.values[j] = l.values[i]
 	l.values[i] = tmp
 }
+
+// sanitizeName replaces the characters not allowed in metric names, or in
+// label names when metric is false, with _, such as the dots of the Graphite
+// paths or of the OpenTelemetry names.
+func sanitizeName(name string, metric bool) string {
+	name = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || (metric && r == ':') {
+			return r
+		}
+		return '_'
+	}, name)
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,48 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLabelsInterner(t *testing.T) {
+	i := newLabelsInterner(2)
+	labels := make([]*Labels, 5)
+	for n := range labels {
+		labels[n] = &Labels{str: fmt.Sprint(n)}
+	}
+
+	if l := i.set("0", labels[0]); l != labels[0] {
+		t.Errorf("unexpected labels interned: %v", l)
+	}
+	if l := i.set("0", &Labels{str: "0"}); l != labels[0] {
+		t.Errorf("interned labels not returned: %v", l)
+	}
+	i.set("1", labels[1])
+	// The full generation becomes the previous one.
+	i.set("2", labels[2])
+	if l := i.get("0"); l != labels[0] {
+		t.Errorf("labels of the previous generation not found: %v", l)
+	}
+	// 0 was moved back to the current generation, so only 1 is dropped.
+	i.set("3", labels[3])
+	i.set("4", labels[4])
+	if i.get("1") != nil {
+		t.Error("labels unused for two generations not dropped")
+	}
+	if l := i.get("0"); l != labels[0] {
+		t.Errorf("labels used again dropped: %v", l)
+	}
+	if n := i.entries(); n > 4 {
+		t.Errorf("interner not bounded: %d labels", n)
+	}
+
+	i.grow(10)
+	i.grow(1)
+	if i.size != 10 {
+		t.Errorf("unexpected size: %d", i.size)
+	}
+}
@@ -0,0 +1,149 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/timescale/timescale-prometheus/pkg/events"
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+const (
+	listLifecyclePoliciesSQL = `SELECT metric_name, raw_retention::text, rollup_interval::text, rollup_retention::text,
+		rolled_up_until, last_run, last_error
+	FROM ` + catalogSchema + `.lifecycle_policy
+	ORDER BY metric_name`
+	runLifecyclePolicySQL = "SELECT " + catalogSchema + ".run_lifecycle_policy($1)"
+)
+
+// LifecyclePolicy describes the lifecycle policy of a metric and the state of
+// its rollups.
+type LifecyclePolicy struct {
+	Metric          string     `json:"metric"`
+	RawRetention    string     `json:"raw_retention"`
+	RollupInterval  string     `json:"rollup_interval"`
+	RollupRetention string     `json:"rollup_retention"`
+	RolledUpUntil   *time.Time `json:"rolled_up_until,omitempty"`
+	LastRun         *time.Time `json:"last_run,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+// LifecyclePolicyLister lists the lifecycle policies of the metrics.
+type LifecyclePolicyLister interface {
+	LifecyclePolicies() ([]LifecyclePolicy, error)
+}
+
+// LifecycleManager runs the lifecycle policies set with
+// set_metric_lifecycle_policy: it rolls up the raw samples of the metrics
+// and drops the expired rollups. The raw samples themselves are dropped by
+// the drop_chunks procedure once they are rolled up.
+type LifecycleManager struct {
+	conn pgxConn
+}
+
+// NewLifecycleManager returns a new LifecycleManager using the given connection pool.
+func NewLifecycleManager(c *pgxpool.Pool) *LifecycleManager {
+	return &LifecycleManager{
+		conn: &pgxConnImpl{
+			conn: c,
+		},
+	}
+}
+
+// LifecyclePolicies returns the policies of all metrics.
+func (m *LifecycleManager) LifecyclePolicies() ([]LifecyclePolicy, error) {
+	rows, err := m.conn.Query(context.Background(), listLifecyclePoliciesSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := make([]LifecyclePolicy, 0)
+	for rows.Next() {
+		var (
+			p                      LifecyclePolicy
+			rolledUpUntil, lastRun sql.NullTime
+			lastError              sql.NullString
+		)
+		if err := rows.Scan(&p.Metric, &p.RawRetention, &p.RollupInterval, &p.RollupRetention, &rolledUpUntil, &lastRun, &lastError); err != nil {
+			return nil, err
+		}
+		if rolledUpUntil.Valid {
+			p.RolledUpUntil = &rolledUpUntil.Time
+		}
+		if lastRun.Valid {
+			p.LastRun = &lastRun.Time
+		}
+		p.LastError = lastError.String
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+// Run runs every policy once and updates the lifecycle metrics. A failing
+// policy does not prevent the others from running; its error is recorded in
+// the policy.
+func (m *LifecycleManager) Run() error {
+	policies, err := m.LifecyclePolicies()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range policies {
+		var runErr sql.NullString
+		if err := m.queryRow(runLifecyclePolicySQL, []interface{}{p.Metric}, &runErr); err != nil {
+			return err
+		}
+		if runErr.Valid {
+			lifecycleRuns.WithLabelValues("failure").Inc()
+			log.Warn("msg", "Lifecycle policy failed", "metric", p.Metric, "err", runErr.String)
+			events.Emit(events.RetentionApplied, "metric", p.Metric, "err", runErr.String)
+			continue
+		}
+		lifecycleRuns.WithLabelValues("success").Inc()
+		events.Emit(events.RetentionApplied, "metric", p.Metric)
+	}
+
+	policies, err = m.LifecyclePolicies()
+	if err != nil {
+		return err
+	}
+	updateLifecycleMetrics(policies, time.Now())
+	return nil
+}
+
+func (m *LifecycleManager) queryRow(query string, args []interface{}, dest ...interface{}) error {
+	rows, err := m.conn.Query(context.Background(), query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return errNoRows
+	}
+	return rows.Scan(dest...)
+}
+
+func updateLifecycleMetrics(policies []LifecyclePolicy, now time.Time) {
+	// Reset first so that removed policies disappear.
+	lifecycleRollupLag.Reset()
+	lifecyclePolicyFailing.Reset()
+	for _, p := range policies {
+		if p.RolledUpUntil != nil {
+			lifecycleRollupLag.WithLabelValues(p.Metric).Set(now.Sub(*p.RolledUpUntil).Seconds())
+		}
+		failing := 0.0
+		if p.LastError != "" {
+			failing = 1
+		}
+		lifecyclePolicyFailing.WithLabelValues(p.Metric).Set(failing)
+	}
+}
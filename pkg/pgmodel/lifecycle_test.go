@@ -0,0 +1,146 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+func TestLifecycleManagerPolicies(t *testing.T) {
+	rolledUpUntil := time.Unix(1000, 0)
+	lastRun := time.Unix(2000, 0)
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{
+			{
+				{"a", "7 days", "00:05:00", "1 year", rolledUpUntil, lastRun, nil},
+				{"b", "7 days", "01:00:00", "1 year", nil, lastRun, "some error"},
+			},
+		},
+	}
+	m := &LifecycleManager{conn: mock}
+
+	policies, err := m.LifecyclePolicies()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []LifecyclePolicy{
+		{Metric: "a", RawRetention: "7 days", RollupInterval: "00:05:00", RollupRetention: "1 year", RolledUpUntil: &rolledUpUntil, LastRun: &lastRun},
+		{Metric: "b", RawRetention: "7 days", RollupInterval: "01:00:00", RollupRetention: "1 year", LastRun: &lastRun, LastError: "some error"},
+	}
+	if !reflect.DeepEqual(policies, expected) {
+		t.Errorf("unexpected policies:\ngot\n%v\nwanted\n%v", policies, expected)
+	}
+}
+
+func TestLifecycleManagerRun(t *testing.T) {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	rolledUpUntil := now.Add(-10 * time.Minute)
+	policies := rowResults{
+		{"a", "7 days", "00:05:00", "1 year", rolledUpUntil, now, nil},
+		{"b", "7 days", "01:00:00", "1 year", nil, now, "some error"},
+	}
+
+	testCases := []struct {
+		name         string
+		queryResults []rowResults
+		queryErr     map[int]error
+		runs         []string
+		successes    float64
+		failures     float64
+		err          bool
+	}{
+		{
+			name: "No policies",
+		},
+		{
+			name: "Runs every policy",
+			queryResults: []rowResults{
+				policies,
+				{{nil}},
+				{{"some error"}},
+				policies,
+			},
+			runs:      []string{"a", "b"},
+			successes: 1,
+			failures:  1,
+		},
+		{
+			name: "Run error",
+			queryResults: []rowResults{
+				policies,
+			},
+			queryErr: map[int]error{1: fmt.Errorf("some error")},
+			runs:     []string{"a"},
+			err:      true,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &mockPGXConn{
+				QueryResults: c.queryResults,
+				QueryErr:     c.queryErr,
+			}
+			m := &LifecycleManager{conn: mock}
+			successesBefore := testutil.ToFloat64(lifecycleRuns.WithLabelValues("success"))
+			failuresBefore := testutil.ToFloat64(lifecycleRuns.WithLabelValues("failure"))
+
+			err := m.Run()
+			if (err != nil) != c.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			runs := make([]string, 0)
+			for i, sql := range mock.QuerySQLs {
+				if sql == runLifecyclePolicySQL {
+					runs = append(runs, mock.QueryArgs[i][0].(string))
+				}
+			}
+			if len(runs) != len(c.runs) || len(runs) > 0 && !reflect.DeepEqual(runs, c.runs) {
+				t.Errorf("unexpected policies run: got %v wanted %v", runs, c.runs)
+			}
+
+			if got := testutil.ToFloat64(lifecycleRuns.WithLabelValues("success")) - successesBefore; got != c.successes {
+				t.Errorf("unexpected successful runs: got %v wanted %v", got, c.successes)
+			}
+			if got := testutil.ToFloat64(lifecycleRuns.WithLabelValues("failure")) - failuresBefore; got != c.failures {
+				t.Errorf("unexpected failed runs: got %v wanted %v", got, c.failures)
+			}
+		})
+	}
+}
+
+func TestUpdateLifecycleMetrics(t *testing.T) {
+	now := time.Unix(1000, 0)
+	rolledUpUntil := now.Add(-10 * time.Minute)
+	updateLifecycleMetrics([]LifecyclePolicy{
+		{Metric: "a", RolledUpUntil: &rolledUpUntil},
+		{Metric: "b", LastError: "some error"},
+	}, now)
+
+	if got := testutil.ToFloat64(lifecycleRollupLag.WithLabelValues("a")); got != 600 {
+		t.Errorf("unexpected rollup lag: got %v wanted 600", got)
+	}
+	if got := testutil.ToFloat64(lifecyclePolicyFailing.WithLabelValues("a")); got != 0 {
+		t.Errorf("unexpected failing state of a: got %v wanted 0", got)
+	}
+	if got := testutil.ToFloat64(lifecyclePolicyFailing.WithLabelValues("b")); got != 1 {
+		t.Errorf("unexpected failing state of b: got %v wanted 1", got)
+	}
+
+	updateLifecycleMetrics(nil, now)
+	if got := testutil.CollectAndCount(lifecyclePolicyFailing); got != 0 {
+		t.Errorf("removed policies are still reported: got %d series", got)
+	}
+}
@@ -0,0 +1,14 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import "github.com/timescale/timescale-prometheus/pkg/log"
+
+// The loggers of the subsystems, whose log levels can be set independently.
+var (
+	ingestLogger  = log.Component("ingest")
+	queryLogger   = log.Component("query")
+	migrateLogger = log.Component("migrate")
+)
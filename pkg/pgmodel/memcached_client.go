@@ -0,0 +1,149 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// memcachedMaxRelativeTTL is the longest expiration memcached takes as a
+// duration, longer ones being taken as Unix times.
+const memcachedMaxRelativeTTL = 30 * 24 * time.Hour
+
+// memcachedClient speaks the subset of the memcached text protocol used by
+// the shared caches.
+type memcachedClient struct {
+	conns *cacheConnPool
+}
+
+// roundTrip sends a command line, followed by data unless it is nil, and
+// returns the first line of the reply.
+func memcachedRoundTrip(c *cacheConn, line string, data []byte) (string, error) {
+	if _, err := c.rw.WriteString(line + "\r\n"); err != nil {
+		return "", err
+	}
+	if data != nil {
+		if _, err := c.rw.Write(append(data, '\r', '\n')); err != nil {
+			return "", err
+		}
+	}
+	if err := c.rw.Flush(); err != nil {
+		return "", err
+	}
+	return readMemcachedLine(c)
+}
+
+func readMemcachedLine(c *cacheConn) (string, error) {
+	reply, err := c.rw.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	reply = strings.TrimSuffix(reply, "\r\n")
+	if reply == "ERROR" || strings.HasPrefix(reply, "CLIENT_ERROR") || strings.HasPrefix(reply, "SERVER_ERROR") {
+		return "", fmt.Errorf("memcached: %s", reply)
+	}
+	return reply, nil
+}
+
+func (m *memcachedClient) get(key string) ([]byte, bool, error) {
+	var (
+		value []byte
+		found bool
+	)
+	err := m.conns.do(func(c *cacheConn) error {
+		reply, err := memcachedRoundTrip(c, "get "+key, nil)
+		if err != nil {
+			return err
+		}
+		if reply == "END" {
+			return nil
+		}
+		// VALUE <key> <flags> <bytes>
+		fields := strings.Fields(reply)
+		if len(fields) != 4 || fields[0] != "VALUE" {
+			return fmt.Errorf("unexpected memcached reply %q to get", reply)
+		}
+		n, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return fmt.Errorf("unexpected memcached reply %q to get", reply)
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(c.rw, buf); err != nil {
+			return err
+		}
+		if reply, err = readMemcachedLine(c); err != nil {
+			return err
+		}
+		if reply != "END" {
+			return fmt.Errorf("unexpected memcached reply %q to get", reply)
+		}
+		value, found = buf[:n], true
+		return nil
+	})
+	return value, found, err
+}
+
+func (m *memcachedClient) set(key string, value []byte, ttl time.Duration) error {
+	if ttl > memcachedMaxRelativeTTL {
+		ttl = memcachedMaxRelativeTTL
+	}
+	seconds := int64(ttl / time.Second)
+	if ttl > 0 && seconds == 0 {
+		seconds = 1
+	}
+	return m.conns.do(func(c *cacheConn) error {
+		reply, err := memcachedRoundTrip(c, fmt.Sprintf("set %s 0 %d %d", key, seconds, len(value)), value)
+		if err == nil && reply != "STORED" {
+			err = fmt.Errorf("unexpected memcached reply %q to set", reply)
+		}
+		return err
+	})
+}
+
+func (m *memcachedClient) delete(key string) error {
+	return m.conns.do(func(c *cacheConn) error {
+		reply, err := memcachedRoundTrip(c, "delete "+key, nil)
+		if err == nil && reply != "DELETED" && reply != "NOT_FOUND" {
+			err = fmt.Errorf("unexpected memcached reply %q to delete", reply)
+		}
+		return err
+	})
+}
+
+// incr increments key, adding it first if it is missing. Another client
+// adding it at the same time makes the add fail, and the incr is retried.
+func (m *memcachedClient) incr(key string) (int64, error) {
+	var value int64
+	err := m.conns.do(func(c *cacheConn) error {
+		for attempt := 0; attempt < 2; attempt++ {
+			reply, err := memcachedRoundTrip(c, "incr "+key+" 1", nil)
+			if err != nil {
+				return err
+			}
+			if reply != "NOT_FOUND" {
+				value, err = strconv.ParseInt(reply, 10, 64)
+				return err
+			}
+			reply, err = memcachedRoundTrip(c, "add "+key+" 0 0 1", []byte("1"))
+			if err != nil {
+				return err
+			}
+			if reply == "STORED" {
+				value = 1
+				return nil
+			}
+		}
+		return fmt.Errorf("incrementing %s: added concurrently and missing", key)
+	})
+	return value, err
+}
+
+func (m *memcachedClient) close() {
+	m.conns.close()
+}
@@ -0,0 +1,162 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	getCreateMetricsTablesSQL = "SELECT m.metric_name, t.table_name, t.possibly_new FROM unnest($1::text[]) AS m(metric_name), " +
+		"LATERAL " + catalogSchema + ".get_or_create_metric_table_name(m.metric_name) t"
+
+	// maxMetricCreationBatch bounds the metrics whose tables are fetched, or
+	// created, in a single statement.
+	maxMetricCreationBatch = 500
+)
+
+var errInserterClosed = fmt.Errorf("the inserter is closed")
+
+// metricTableCreator gets, or creates, the tables of the metrics missing from
+// the metric cache. The requests queued while a statement runs are served by
+// the next one, so that the thousands of metrics appearing at once when a
+// cluster bootstraps are created in a few round trips rather than one at a
+// time, while a lone new metric is not delayed. Meanwhile, the samples of the
+// new metrics wait in the queues of their insert routines.
+type metricTableCreator struct {
+	conn     pgxConn
+	requests chan metricTableRequest
+	done     chan struct{}
+}
+
+type metricTableRequest struct {
+	metric string
+	result chan metricTableResult
+}
+
+type metricTableResult struct {
+	tableName   string
+	possiblyNew bool
+	err         error
+}
+
+func newMetricTableCreator(conn pgxConn) *metricTableCreator {
+	c := &metricTableCreator{
+		conn:     conn,
+		requests: make(chan metricTableRequest, maxMetricCreationBatch),
+		done:     make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// get returns the table of metric, and whether it was possibly just created.
+func (c *metricTableCreator) get(metric string) (string, bool, error) {
+	req := metricTableRequest{metric: metric, result: make(chan metricTableResult, 1)}
+	select {
+	case c.requests <- req:
+	case <-c.done:
+		return "", true, errInserterClosed
+	}
+	select {
+	case res := <-req.result:
+		return res.tableName, res.possiblyNew, res.err
+	case <-c.done:
+		return "", true, errInserterClosed
+	}
+}
+
+func (c *metricTableCreator) close() {
+	close(c.done)
+}
+
+func (c *metricTableCreator) run() {
+	for {
+		var batch []metricTableRequest
+		select {
+		case req := <-c.requests:
+			batch = append(batch, req)
+		case <-c.done:
+			return
+		}
+
+	drain:
+		for len(batch) < maxMetricCreationBatch {
+			select {
+			case req := <-c.requests:
+				batch = append(batch, req)
+			default:
+				break drain
+			}
+		}
+
+		start := time.Now()
+		c.serve(batch)
+		metricTableCreationDuration.Observe(time.Since(start).Seconds())
+		metricCreationBatchSize.Observe(float64(len(batch)))
+	}
+}
+
+// serve answers a batch of requests. A single metric uses the plain lookup.
+// If the batched statement fails, for instance on a deadlock with another
+// connector creating some of the same metrics, each metric is retried alone
+// so that one failure does not fail them all.
+func (c *metricTableCreator) serve(batch []metricTableRequest) {
+	metrics := make([]string, 0, len(batch))
+	seen := make(map[string]bool, len(batch))
+	for _, req := range batch {
+		if !seen[req.metric] {
+			seen[req.metric] = true
+			metrics = append(metrics, req.metric)
+		}
+	}
+
+	var results map[string]metricTableResult
+	if len(metrics) > 1 {
+		var err error
+		if results, err = getMetricTableNames(c.conn, metrics); err != nil {
+			results = nil
+		}
+	}
+	if results == nil {
+		results = make(map[string]metricTableResult, len(metrics))
+		for _, metric := range metrics {
+			var res metricTableResult
+			res.tableName, res.possiblyNew, res.err = getMetricTableName(c.conn, metric)
+			results[metric] = res
+		}
+	}
+
+	for _, req := range batch {
+		res, ok := results[req.metric]
+		if !ok {
+			res = metricTableResult{possiblyNew: true, err: errMissingTableName}
+		}
+		req.result <- res
+	}
+}
+
+// getMetricTableNames gets, or creates, the tables of metrics in a single
+// statement.
+func getMetricTableNames(conn pgxConn, metrics []string) (map[string]metricTableResult, error) {
+	res, err := conn.Query(context.Background(), getCreateMetricsTablesSQL, metrics)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	results := make(map[string]metricTableResult, len(metrics))
+	for res.Next() {
+		var metric string
+		var r metricTableResult
+		if err := res.Scan(&metric, &r.tableName, &r.possiblyNew); err != nil {
+			return nil, err
+		}
+		results[metric] = r
+	}
+	return results, res.Err()
+}
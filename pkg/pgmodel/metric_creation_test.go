@@ -0,0 +1,82 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func serveMetricTables(conn pgxConn, metrics ...string) []metricTableResult {
+	batch := make([]metricTableRequest, 0, len(metrics))
+	for _, metric := range metrics {
+		batch = append(batch, metricTableRequest{metric: metric, result: make(chan metricTableResult, 1)})
+	}
+	(&metricTableCreator{conn: conn}).serve(batch)
+
+	results := make([]metricTableResult, 0, len(batch))
+	for _, req := range batch {
+		results = append(results, <-req.result)
+	}
+	return results
+}
+
+func TestMetricTableCreatorBatch(t *testing.T) {
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{{{"a", "a_table", true}, {"b", "b_table", false}}},
+	}
+	results := serveMetricTables(mock, "a", "b", "a")
+
+	expected := []metricTableResult{{"a_table", true, nil}, {"b_table", false, nil}, {"a_table", true, nil}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("unexpected results:\ngot\n%+v\nwanted\n%+v", results, expected)
+	}
+	if !reflect.DeepEqual(mock.QuerySQLs, []string{getCreateMetricsTablesSQL}) {
+		t.Errorf("unexpected queries: %v", mock.QuerySQLs)
+	}
+	if !reflect.DeepEqual(mock.QueryArgs[0], []interface{}{[]string{"a", "b"}}) {
+		t.Errorf("unexpected arguments: %v", mock.QueryArgs[0])
+	}
+}
+
+func TestMetricTableCreatorFallback(t *testing.T) {
+	err := fmt.Errorf("deadlock detected")
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{nil, {{"a_table", true}}, nil},
+		QueryErr:     map[int]error{0: err, 2: err},
+	}
+	results := serveMetricTables(mock, "a", "b")
+
+	if results[0] != (metricTableResult{"a_table", true, nil}) {
+		t.Errorf("unexpected result of a: %+v", results[0])
+	}
+	if results[1].err != err {
+		t.Errorf("unexpected error of b: %v", results[1].err)
+	}
+	expected := []string{getCreateMetricsTablesSQL, getCreateMetricsTableWithNewSQL, getCreateMetricsTableWithNewSQL}
+	if !reflect.DeepEqual(mock.QuerySQLs, expected) {
+		t.Errorf("unexpected queries: %v", mock.QuerySQLs)
+	}
+}
+
+func TestMetricTableCreatorSingle(t *testing.T) {
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{{{"a_table", false}}},
+	}
+	c := newMetricTableCreator(mock)
+
+	table, possiblyNew, err := c.get("a")
+	if err != nil || table != "a_table" || possiblyNew {
+		t.Errorf("unexpected result: %s %v %v", table, possiblyNew, err)
+	}
+	if !reflect.DeepEqual(mock.QuerySQLs, []string{getCreateMetricsTableWithNewSQL}) {
+		t.Errorf("unexpected queries: %v", mock.QuerySQLs)
+	}
+
+	c.close()
+	if _, _, err = c.get("b"); err != errInserterClosed {
+		t.Errorf("unexpected error:\ngot\n%v\nwanted\n%v", err, errInserterClosed)
+	}
+}
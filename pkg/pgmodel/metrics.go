@@ -0,0 +1,501 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const promNamespace = "ts_prom"
+
+var (
+	seriesCacheHits = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "series_cache_hits_total",
+			Help:      "Total number of series id lookups answered by the series cache.",
+		},
+	)
+	seriesCacheMisses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "series_cache_misses_total",
+			Help:      "Total number of series id lookups not found in the series cache.",
+		},
+	)
+	seriesCacheEvictions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "series_cache_evictions_total",
+			Help:      "Total number of series evicted from the series cache to make room for new ones.",
+		},
+	)
+	seriesCacheCollisions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "series_cache_collisions_total",
+			Help:      "Total number of series whose fingerprint matched a cached series with different labels.",
+		},
+	)
+	seriesCacheEntries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "series_cache_entries",
+			Help:      "Number of series currently stored in the series cache.",
+		},
+	)
+	seriesCacheCapacity = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "series_cache_capacity",
+			Help:      "Maximum number of series the series cache can hold.",
+		},
+	)
+	seriesCacheExpirations = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "series_cache_expirations_total",
+			Help:      "Total number of series id lookups finding a series cached for longer than the series cache TTL, looked up again in the database.",
+		},
+	)
+	metricCacheHits = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "metric_cache_hits_total",
+			Help:      "Total number of metric table name lookups answered by the metric cache.",
+		},
+	)
+	metricCacheMisses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "metric_cache_misses_total",
+			Help:      "Total number of metric table name lookups not found in the metric cache.",
+		},
+	)
+	metricCacheEvictions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "metric_cache_evictions_total",
+			Help:      "Total number of table names evicted from the metric cache, by reason: expired past the TTL, or no_space to make room for new ones.",
+		},
+		[]string{"reason"},
+	)
+	metricCacheEntries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "metric_cache_entries",
+			Help:      "Number of table names currently stored in the in-memory metric cache.",
+		},
+	)
+	samplesCopied = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "copied_samples_total",
+			Help:      "Total number of samples written to the database with COPY.",
+		},
+	)
+	copyDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: promNamespace,
+			Name:      "copy_duration_seconds",
+			Help:      "Duration of the COPY of a batch of samples into a metric table.",
+			Buckets:   prometheus.DefBuckets,
+		},
+	)
+	inserterQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "inserter_queue_depth",
+			Help:      "Number of requests waiting in the insert routine channels of a metric, as of the last write to it.",
+		},
+		[]string{"metric"},
+	)
+	metricTableCreationDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: promNamespace,
+			Name:      "metric_table_creation_duration_seconds",
+			Help:      "Time spent getting or creating the tables of a batch of metrics missing from the metric cache.",
+			Buckets:   prometheus.DefBuckets,
+		},
+	)
+	metricCreationBatchSize = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: promNamespace,
+			Name:      "metric_creation_batch_size",
+			Help:      "Number of metrics missing from the metric cache whose tables are fetched, or created, together.",
+			Buckets:   []float64{1, 2, 5, 10, 20, 50, 100, 200, 500},
+		},
+	)
+	chunkIntervalChanges = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "chunk_interval_changes_total",
+			Help:      "Total number of chunk intervals adapted to the ingest rate of their metric.",
+		},
+	)
+	partialWrites = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "partial_writes_total",
+			Help:      "Total number of write requests of which only some metrics were stored.",
+		},
+	)
+	retrySkippedSamples = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "retry_skipped_samples_total",
+			Help:      "Total number of samples skipped in retried write requests because an earlier attempt already stored them.",
+		},
+	)
+	duplicateSamples = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "duplicate_samples_total",
+			Help:      "Total number of samples skipped in dedup mode because they were already stored.",
+		},
+	)
+	staleMarkers = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "stale_markers_total",
+			Help:      "Total number of Prometheus staleness markers received, by what was done with them: stored, dropped or recorded as series liveness.",
+		},
+		[]string{"action"},
+	)
+	outOfBoundsSamples = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "out_of_bounds_samples_total",
+			Help:      "Total number of samples rejected because their timestamp is too old or too far in the future, by reason.",
+		},
+		[]string{"reason"},
+	)
+	droppedSeriesOverLimit = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "dropped_series_over_limit_total",
+			Help:      "Total number of times the samples of a series were dropped because its metric had the maximum number of active series.",
+		},
+		[]string{"metric"},
+	)
+	relabeledSeries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "relabeled_series_total",
+			Help:      "Total number of ingested series dropped or modified by the relabel configs.",
+		},
+		[]string{"result"},
+	)
+	redactedLabels = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "read_redacted_labels_total",
+			Help:      "Total number of labels dropped or masked by the redaction rules in the series read.",
+		},
+		[]string{"action"},
+	)
+	relabelConfigReloadSuccess = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "relabel_config_last_reload_successful",
+			Help:      "Whether the last reload of the relabel configs succeeded.",
+		},
+	)
+	vacuumedSeries = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "vacuumed_series_total",
+			Help:      "Total number of series without samples deleted from the catalog by the series vacuum.",
+		},
+	)
+	queryRowsScanned = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "query_rows_scanned_total",
+			Help:      "Total number of series rows read from the database by remote read queries.",
+		},
+	)
+	spillBufferBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "spill_buffer_bytes",
+			Help:      "Number of bytes of write requests buffered on disk while the database is unreachable.",
+		},
+	)
+	samplesSpilled = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "spilled_samples_total",
+			Help:      "Total number of samples buffered on disk while the database was unreachable.",
+		},
+	)
+	samplesReplayed = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "replayed_samples_total",
+			Help:      "Total number of samples written to the database from the spill buffer.",
+		},
+	)
+	spillBytesDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "spill_dropped_bytes_total",
+			Help:      "Total number of bytes dropped from the spill buffer because they exceeded the maximum age.",
+		},
+	)
+	haLeaseOwner = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "ha_lease_owner",
+			Help:      "Set to 1 for the replica currently owning the lease of each HA cluster, whose samples are stored.",
+		},
+		[]string{"cluster", "replica"},
+	)
+	haSamplesDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "ha_dropped_samples_total",
+			Help:      "Total number of samples dropped because they came from an HA replica not owning the lease of its cluster.",
+		},
+	)
+	lifecycleRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "lifecycle_policy_runs_total",
+			Help:      "Total number of lifecycle policy runs, by result.",
+		},
+		[]string{"result"},
+	)
+	lifecycleRollupLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "lifecycle_rollup_lag_seconds",
+			Help:      "Age of the newest samples rolled up by the lifecycle policy of each metric, as of the last run.",
+		},
+		[]string{"metric"},
+	)
+	lifecyclePolicyFailing = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "lifecycle_policy_failing",
+			Help:      "Set to 1 when the last run of the lifecycle policy of a metric failed.",
+		},
+		[]string{"metric"},
+	)
+	queryCacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "query_cache_requests_total",
+			Help:      "Total number of queries looked up in the query results cache, by result: hit, miss, or uncacheable for queries within the recent window.",
+		},
+		[]string{"result"},
+	)
+	sqlTemplateCacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "sql_template_cache_requests_total",
+			Help:      "Total number of read SQL texts looked up in the cache by query shape, by result: hit or miss.",
+		},
+		[]string{"result"},
+	)
+	shedSamples = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "shed_samples_total",
+			Help:      "Total number of samples dropped under backpressure to make room for the metrics of higher priority classes, by priority class.",
+		},
+		[]string{"class"},
+	)
+	writeAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "write_attempts_total",
+			Help:      "Total number of attempts of the database writes, by operation and result (success, retry after a transient error, or failure).",
+		},
+		[]string{"op", "result"},
+	)
+	snapshotReads = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "snapshot_reads_total",
+			Help:      "Total number of remote read queries across several metrics pinned to a single database snapshot.",
+		},
+	)
+	histogramsWritten = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "written_histograms_total",
+			Help:      "Total number of native histogram samples written to the histogram table.",
+		},
+	)
+	cacheInvalidations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "cache_invalidations_total",
+			Help:      "Total number of cache invalidations notified by the database, by kind: metric, series, or flush when the notifications may have been missed while reconnecting.",
+		},
+		[]string{"kind"},
+	)
+	federatedSourceErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "federated_source_errors_total",
+			Help:      "Total number of federated read queries which failed on a source other than the database of the connector, and returned without its series.",
+		},
+		[]string{"source"},
+	)
+	missingMetricCacheHits = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "missing_metric_cache_hits_total",
+			Help:      "Total number of reads of a metric without a table answered by the metric cache, without looking up its table.",
+		},
+	)
+	sharedCacheErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "shared_cache_errors_total",
+			Help:      "Total number of failed requests to the shared cache server, by operation. The entries are then looked up in the database.",
+		},
+		[]string{"op"},
+	)
+	readReplicaHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "read_replica_healthy",
+			Help:      "Whether the read replica is read from (1) or not (0), being unreachable, not a standby or lagging too far behind.",
+		},
+		[]string{"replica"},
+	)
+	readReplicaLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "read_replica_lag_seconds",
+			Help:      "Replication lag of the read replica when last checked.",
+		},
+		[]string{"replica"},
+	)
+	readReplicaStatements = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "read_replica_statements_total",
+			Help:      "Total number of read statements by the server they ran on, a replica or the primary when no replica is healthy.",
+		},
+		[]string{"replica"},
+	)
+	shardWrittenRows = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "shard_written_rows_total",
+			Help:      "Total number of samples and native histograms written to each ingest shard.",
+		},
+		[]string{"shard"},
+	)
+	invalidSeries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "invalid_series_total",
+			Help:      "Total number of ingested series with invalid labels, by reason and by what was done with them: rejected or sanitized.",
+		},
+		[]string{"reason", "action"},
+	)
+	inFlightSamples = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "in_flight_samples",
+			Help:      "Number of samples accepted but not yet stored, by whether their write was acknowledged. The acknowledged ones, with async acks, are lost if the connector stops.",
+		},
+		[]string{"acked"},
+	)
+	batchLatency = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: promNamespace,
+			Name:      "batch_latency_seconds",
+			Help:      "Time from the first request of a batch of samples to the end of its COPY.",
+			Buckets:   prometheus.DefBuckets,
+		},
+	)
+	batchMaxSeries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "batch_max_series",
+			Help:      "Number of series of a batch of samples, adapted to the latency of the batches with adaptive batching.",
+		},
+	)
+	walFlushDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: promNamespace,
+			Name:      "wal_flush_duration_seconds",
+			Help:      "Duration of the durable commits flushing the WAL of the batches copied with flush acks.",
+			Buckets:   prometheus.DefBuckets,
+		},
+	)
+	walFlushBatches = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: promNamespace,
+			Name:      "wal_flush_batch_count",
+			Help:      "Number of copied batches acknowledged by every WAL flush with flush acks.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(seriesCacheHits)
+	prometheus.MustRegister(seriesCacheMisses)
+	prometheus.MustRegister(seriesCacheEvictions)
+	prometheus.MustRegister(seriesCacheCollisions)
+	prometheus.MustRegister(seriesCacheEntries)
+	prometheus.MustRegister(seriesCacheCapacity)
+	prometheus.MustRegister(seriesCacheExpirations)
+	prometheus.MustRegister(metricCacheHits)
+	prometheus.MustRegister(metricCacheMisses)
+	prometheus.MustRegister(metricCacheEvictions)
+	prometheus.MustRegister(metricCacheEntries)
+	prometheus.MustRegister(samplesCopied)
+	prometheus.MustRegister(copyDuration)
+	prometheus.MustRegister(inserterQueueDepth)
+	prometheus.MustRegister(metricTableCreationDuration)
+	prometheus.MustRegister(metricCreationBatchSize)
+	prometheus.MustRegister(chunkIntervalChanges)
+	prometheus.MustRegister(partialWrites)
+	prometheus.MustRegister(retrySkippedSamples)
+	prometheus.MustRegister(duplicateSamples)
+	prometheus.MustRegister(staleMarkers)
+	prometheus.MustRegister(outOfBoundsSamples)
+	prometheus.MustRegister(queryRowsScanned)
+	prometheus.MustRegister(spillBufferBytes)
+	prometheus.MustRegister(samplesSpilled)
+	prometheus.MustRegister(samplesReplayed)
+	prometheus.MustRegister(spillBytesDropped)
+	prometheus.MustRegister(haLeaseOwner)
+	prometheus.MustRegister(haSamplesDropped)
+	prometheus.MustRegister(lifecycleRuns)
+	prometheus.MustRegister(lifecycleRollupLag)
+	prometheus.MustRegister(lifecyclePolicyFailing)
+	prometheus.MustRegister(queryCacheRequests)
+	prometheus.MustRegister(sqlTemplateCacheRequests)
+	prometheus.MustRegister(shedSamples)
+	prometheus.MustRegister(writeAttempts)
+	prometheus.MustRegister(relabeledSeries)
+	prometheus.MustRegister(droppedSeriesOverLimit)
+	prometheus.MustRegister(relabelConfigReloadSuccess)
+	prometheus.MustRegister(vacuumedSeries)
+	prometheus.MustRegister(snapshotReads)
+	prometheus.MustRegister(redactedLabels)
+	prometheus.MustRegister(histogramsWritten)
+	prometheus.MustRegister(cacheInvalidations)
+	prometheus.MustRegister(federatedSourceErrors)
+	prometheus.MustRegister(missingMetricCacheHits)
+	prometheus.MustRegister(sharedCacheErrors)
+	prometheus.MustRegister(readReplicaHealthy)
+	prometheus.MustRegister(readReplicaLag)
+	prometheus.MustRegister(readReplicaStatements)
+	prometheus.MustRegister(shardWrittenRows)
+	prometheus.MustRegister(invalidSeries)
+	prometheus.MustRegister(inFlightSamples)
+	prometheus.MustRegister(walFlushDuration)
+	prometheus.MustRegister(walFlushBatches)
+	prometheus.MustRegister(batchLatency)
+	prometheus.MustRegister(batchMaxSeries)
+}
@@ -0,0 +1,84 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func histogramCount(t *testing.T, h prometheus.Histogram) uint64 {
+	var m dto.Metric
+	if err := h.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestInsertMetrics(t *testing.T) {
+	mock := &mockPGXConn{
+		QueryResults:   []rowResults{{{"metricTableName_1", true}}},
+		CopyFromResult: 2,
+	}
+	mockMetrics := &mockMetricCache{metricCache: make(map[string]string)}
+	inserter, err := newPgxInserter(mock, mockMetrics, &Cfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inserter.Close()
+
+	copiedBefore := testutil.ToFloat64(samplesCopied)
+	copiesBefore := histogramCount(t, copyDuration)
+	creationsBefore := histogramCount(t, metricTableCreationDuration)
+
+	ls, err := LabelsFromSlice(labels.Labels{{Name: MetricNameLabelName, Value: "metric_1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []samplesInfo{{
+		labels:   ls,
+		seriesID: 1,
+		samples:  []prompb.Sample{{Timestamp: 1, Value: 0.1}, {Timestamp: 2, Value: 0.2}},
+	}}
+	if _, err := inserter.InsertData(context.Background(), map[string][]samplesInfo{"metric_1": data}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := testutil.ToFloat64(samplesCopied) - copiedBefore; got != 2 {
+		t.Errorf("unexpected copied samples: got %v wanted 2", got)
+	}
+	if got := histogramCount(t, copyDuration) - copiesBefore; got != 1 {
+		t.Errorf("unexpected number of copies observed: got %v wanted 1", got)
+	}
+	if got := histogramCount(t, metricTableCreationDuration) - creationsBefore; got != 1 {
+		t.Errorf("unexpected number of table creations observed: got %v wanted 1", got)
+	}
+	if got := testutil.ToFloat64(inserterQueueDepth.WithLabelValues("metric_1")); got < 0 || got > 1 {
+		t.Errorf("unexpected queue depth: %v", got)
+	}
+}
+
+func TestQueryRowsScanned(t *testing.T) {
+	rows := &mockRows{results: rowResults{
+		{[]string{MetricNameLabelName}, []string{"foo"}, []time.Time{time.Unix(1, 0)}, []float64{1}},
+		{[]string{MetricNameLabelName}, []string{"bar"}, []time.Time{time.Unix(1, 0)}, []float64{2}},
+	}}
+
+	before := testutil.ToFloat64(queryRowsScanned)
+	err := streamTimeSeries(rows, metricTimeRangeFilter{}, func(*prompb.TimeSeries) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := testutil.ToFloat64(queryRowsScanned) - before; got != 2 {
+		t.Errorf("unexpected rows scanned: got %v wanted 2", got)
+	}
+}
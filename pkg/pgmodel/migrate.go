@@ -7,16 +7,17 @@ package pgmodel
 import (
 	"bytes"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/golang-migrate/migrate/v4/source"
 	"github.com/golang-migrate/migrate/v4/source/httpfs"
-	"github.com/timescale/timescale-prometheus/pkg/log"
 	"github.com/timescale/timescale-prometheus/pkg/pgmodel/migrations"
 )
 
@@ -55,6 +56,7 @@ func (t *mySrc) replaceSchemaNames(r io.ReadCloser) (io.ReadCloser, error) {
 	s = strings.ReplaceAll(s, "SCHEMA_DATA", dataSchema)
 	s = strings.ReplaceAll(s, "SCHEMA_DATA_SERIES", dataSeriesSchema)
 	s = strings.ReplaceAll(s, "SCHEMA_INFO", infoSchema)
+	s = strings.ReplaceAll(s, "SCHEMA_ROLLUP", rollupSchema)
 	r = ioutil.NopCloser(strings.NewReader(s))
 	return r, err
 }
@@ -86,8 +88,68 @@ func metadataUpdate(db *sql.DB, withExtension bool, key string, value string) {
 	}
 }
 
-// Migrate performs a database migration to the latest version
-func Migrate(db *sql.DB, versionInfo VersionInfo) (err error) {
+// LatestMigrationVersion returns the version of the newest migration
+// embedded in the binary, which is the schema version Migrate installs.
+func LatestMigrationVersion() (uint, error) {
+	src, err := httpfs.New(migrations.SqlFiles, "/")
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// migrationPending reports whether Migrate has any migration to apply.
+func migrationPending(db *sql.DB) (bool, error) {
+	version, dirty, err := migrationVersion(db)
+	if err != nil {
+		return false, err
+	}
+	latest, err := LatestMigrationVersion()
+	if err != nil {
+		return false, err
+	}
+	return dirty || version < int(latest), nil
+}
+
+// Migrate performs a database migration to the latest version, holding the
+// migration lock while the schema changes so that failing queries can tell a
+// migration is in progress. The connectors migrating at the same time take
+// turns, the others finding the schema up to date. It returns a *MigrationRepairError, without
+// migrating, if the installed schema does not match the checksums recorded
+// when it was migrated. The extensions already installed are updated to
+// their newest version available on the server.
+func Migrate(db *sql.DB, versionInfo VersionInfo) error {
+	return MigrateWithOptions(db, versionInfo, MigrateOptions{})
+}
+
+// MigrateWithOptions is Migrate, updating the extensions as configured in
+// options.
+func MigrateWithOptions(db *sql.DB, versionInfo VersionInfo, options MigrateOptions) (err error) {
+	unlockMigrators, err := lockMigrators(db)
+	if err != nil {
+		return fmt.Errorf("cannot take the migrator lock: %w", err)
+	}
+	defer unlockMigrators()
+
+	if err = updateTimescaleDB(db, options.Extensions); err != nil {
+		return err
+	}
+
 	// The migration table will be put in the public schema not in any of our schema because we never want to drop it and
 	// our scripts and our last down script drops our shemas
 	driver, err := postgres.WithInstance(db, &postgres.Config{MigrationsTable: "prom_schema_migrations"})
@@ -100,6 +162,16 @@ func Migrate(db *sql.DB, versionInfo VersionInfo) (err error) {
 		return fmt.Errorf("timescaledb failed to install due to %w", err)
 	}
 
+	embedded, err := embeddedChecksums()
+	if err == nil {
+		err = verifyMigrations(db, embedded)
+	}
+	if err != nil {
+		// the driver is otherwise closed along with the migrate instance
+		_ = driver.Close()
+		return err
+	}
+
 	src, err := httpfs.New(migrations.SqlFiles, "/")
 	if err != nil {
 		return err
@@ -126,6 +198,18 @@ func Migrate(db *sql.DB, versionInfo VersionInfo) (err error) {
 		}
 	}()
 
+	pending, err := migrationPending(db)
+	if err != nil {
+		return err
+	}
+	if pending {
+		unlock, err := lockMigration(db)
+		if err != nil {
+			return fmt.Errorf("cannot take the migration lock: %w", err)
+		}
+		defer unlock()
+	}
+
 	err = m.Up()
 	//ignore no change errors as we want this idempotent. Being up to date is not a bad thing.
 	if err == migrate.ErrNoChange {
@@ -135,9 +219,15 @@ func Migrate(db *sql.DB, versionInfo VersionInfo) (err error) {
 		return err
 	}
 
+	if err = recordChecksums(db, embedded); err != nil {
+		return fmt.Errorf("cannot record migration checksums: %w", err)
+	}
+
 	_, extErr := db.Exec(fmt.Sprintf(extensionInstall, extSchema))
 	if extErr != nil {
-		log.Warn("msg", "timescale_prometheus_extra extension not installed", "cause", extErr)
+		migrateLogger.Warn("msg", "timescale_prometheus_extra extension not installed", "cause", extErr)
+	} else if err = updateExtra(db, options.Extensions); err != nil {
+		return err
 	}
 
 	// Insert metadata.
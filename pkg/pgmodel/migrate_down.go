@@ -0,0 +1,267 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/httpfs"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel/migrations"
+)
+
+const (
+	currentDatabaseSQL = "SELECT current_database()"
+	catalogExistsSQL   = "SELECT to_regclass('" + catalogSchema + ".metric') IS NOT NULL"
+	seriesRowCountSQL  = "SELECT count(*) FROM " + catalogSchema + ".series"
+	metricRowCountsSQL = `SELECT m.metric_name, COALESCE(approximate_row_count(format('%I.%I', '` + dataSchema + `', m.table_name)::regclass), 0)
+	FROM ` + catalogSchema + `.metric m
+	WHERE to_regclass(format('%I.%I', '` + dataSchema + `', m.table_name)) IS NOT NULL
+	ORDER BY m.metric_name`
+	deleteChecksumsAboveSQL = "DELETE FROM public.prom_schema_migration_checksums WHERE version > $1"
+)
+
+// ErrDownMigrationNotConfirmed is returned by MigrateDown when the
+// confirmation does not name the database being migrated.
+var ErrDownMigrationNotConfirmed = errors.New("down migration not confirmed")
+
+// DownMigrationReport describes the data a down migration is about to
+// destroy.
+type DownMigrationReport struct {
+	Database    string
+	FromVersion int
+	ToVersion   uint
+	// CatalogInstalled is false if the catalog is already gone, in which
+	// case no row counts are reported.
+	CatalogInstalled bool
+	SeriesCount      int64
+	Metrics          []MetricRowCount
+	// Steps are the down migrations run, newest first.
+	Steps []DownMigrationStep
+}
+
+// DownMigrationStep is a down migration file, with the schema names
+// replaced as they are run.
+type DownMigrationStep struct {
+	Version    uint
+	Identifier string
+	SQL        string
+}
+
+// MetricRowCount is the approximate number of samples stored for a metric.
+type MetricRowCount struct {
+	Metric string
+	Rows   int64
+}
+
+// TotalRows returns the approximate number of samples across all metrics.
+func (r *DownMigrationReport) TotalRows() int64 {
+	var total int64
+	for _, m := range r.Metrics {
+		total += m.Rows
+	}
+	return total
+}
+
+func (r *DownMigrationReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Down migration of database %q from version %d to version %d.\n", r.Database, r.FromVersion, r.ToVersion)
+	if len(r.Steps) > 0 {
+		names := make([]string, len(r.Steps))
+		for i, s := range r.Steps {
+			names[i] = fmt.Sprintf("%d_%s", s.Version, s.Identifier)
+		}
+		fmt.Fprintf(&b, "Migrations reverted: %s.\n", strings.Join(names, ", "))
+	}
+	if !r.CatalogInstalled {
+		b.WriteString("The Prometheus catalog is not installed, no metric data is stored.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%d series and approximately %d samples in %d metrics are stored:\n", r.SeriesCount, r.TotalRows(), len(r.Metrics))
+	for _, m := range r.Metrics {
+		fmt.Fprintf(&b, "  %s: ~%d samples\n", m.Metric, m.Rows)
+	}
+	return b.String()
+}
+
+// SQL returns the statements of the down migrations, in the order they are
+// run, each preceded by a comment naming its migration.
+func (r *DownMigrationReport) SQL() string {
+	var b strings.Builder
+	for _, s := range r.Steps {
+		fmt.Fprintf(&b, "-- %d_%s.down.sql\n%s\n", s.Version, s.Identifier, strings.TrimRight(s.SQL, "\n"))
+	}
+	return b.String()
+}
+
+// checkConfirmation makes sure the operator named the database they meant to
+// migrate down, so that a misconfigured deployment cannot destroy another one.
+func (r *DownMigrationReport) checkConfirmation(confirmDatabase string) error {
+	if confirmDatabase == "" {
+		return fmt.Errorf("%w: pass the name of the database, %q, to confirm", ErrDownMigrationNotConfirmed, r.Database)
+	}
+	if confirmDatabase != r.Database {
+		return fmt.Errorf("%w: connected to database %q but %q was confirmed", ErrDownMigrationNotConfirmed, r.Database, confirmDatabase)
+	}
+	return nil
+}
+
+// DownMigrationPlan reports what a down migration to the target version
+// would destroy, and the SQL it would run, without changing anything.
+func DownMigrationPlan(db *sql.DB, target uint) (*DownMigrationReport, error) {
+	report := &DownMigrationReport{ToVersion: target}
+
+	if err := db.QueryRow(currentDatabaseSQL).Scan(&report.Database); err != nil {
+		return nil, err
+	}
+
+	version, _, err := migrationVersion(db)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the migration version: %w", err)
+	}
+	report.FromVersion = version
+	if report.Steps, err = downMigrationSteps(version, target); err != nil {
+		return nil, err
+	}
+
+	if err := db.QueryRow(catalogExistsSQL).Scan(&report.CatalogInstalled); err != nil {
+		return nil, err
+	}
+	if !report.CatalogInstalled {
+		return report, nil
+	}
+
+	if err := db.QueryRow(seriesRowCountSQL).Scan(&report.SeriesCount); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(metricRowCountsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m MetricRowCount
+		if err := rows.Scan(&m.Metric, &m.Rows); err != nil {
+			return nil, err
+		}
+		report.Metrics = append(report.Metrics, m)
+	}
+	return report, rows.Err()
+}
+
+// downMigrationSteps returns the down migrations reverting the schema from
+// version to target.
+func downMigrationSteps(version int, target uint) ([]DownMigrationStep, error) {
+	if version <= int(target) {
+		return nil, nil
+	}
+	src, err := httpfs.New(migrations.SqlFiles, "/")
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	files := &mySrc{src}
+
+	var steps []DownMigrationStep
+	for v := uint(version); v > target; {
+		r, identifier, err := files.ReadDown(v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the down migration of version %d: %w", v, err)
+		}
+		statements, err := ioutil.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, DownMigrationStep{Version: v, Identifier: identifier, SQL: string(statements)})
+
+		prev, err := files.Prev(v)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		v = prev
+	}
+	return steps, nil
+}
+
+// MigrateDown reverts the schema to the target version, 0 dropping it
+// entirely along with all the stored data. confirmDatabase must be the name
+// of the database being migrated. The report of what is destroyed is always
+// returned, so that it can be shown when the confirmation is refused.
+func MigrateDown(db *sql.DB, target uint, confirmDatabase string) (report *DownMigrationReport, err error) {
+	report, err = DownMigrationPlan(db, target)
+	if err != nil {
+		return nil, err
+	}
+	if err = report.checkConfirmation(confirmDatabase); err != nil {
+		return report, err
+	}
+	if report.FromVersion <= int(target) {
+		migrateLogger.Info("msg", "Schema is already at or below the requested version", "version", report.FromVersion)
+		return report, nil
+	}
+
+	driver, err := postgres.WithInstance(db, &postgres.Config{MigrationsTable: "prom_schema_migrations"})
+	if err != nil {
+		return report, fmt.Errorf("cannot create driver due to %w", err)
+	}
+
+	src, err := httpfs.New(migrations.SqlFiles, "/")
+	if err != nil {
+		return report, err
+	}
+
+	m, err := migrate.NewWithInstance("SqlFiles", &mySrc{src}, "Postgresql", driver)
+	if err != nil {
+		return report, err
+	}
+	defer func() {
+		sourceErr, databaseErr := m.Close()
+		if err != nil {
+			return
+		}
+		if sourceErr != nil {
+			err = sourceErr
+			return
+		}
+		err = databaseErr
+	}()
+
+	unlock, err := lockMigration(db)
+	if err != nil {
+		return report, fmt.Errorf("cannot take the migration lock: %w", err)
+	}
+	defer unlock()
+
+	migrateLogger.Warn("msg", "Migrating the schema down", "database", report.Database, "from", report.FromVersion, "to", target)
+	if target == 0 {
+		err = m.Down()
+	} else {
+		err = m.Migrate(target)
+	}
+	if err != nil {
+		return report, err
+	}
+
+	// The reverted migrations may be applied again from different files.
+	if _, err = db.Exec(createChecksumTableSQL); err != nil {
+		return report, err
+	}
+	if _, err = db.Exec(deleteChecksumsAboveSQL, int64(target)); err != nil {
+		return report, fmt.Errorf("cannot remove reverted migration checksums: %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,97 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDownMigrationConfirmation(t *testing.T) {
+	report := &DownMigrationReport{Database: "metrics"}
+
+	for _, confirm := range []string{"", "other", "METRICS"} {
+		if err := report.checkConfirmation(confirm); !errors.Is(err, ErrDownMigrationNotConfirmed) {
+			t.Errorf("confirmation %q: expected ErrDownMigrationNotConfirmed, got %v", confirm, err)
+		}
+	}
+	if err := report.checkConfirmation("metrics"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDownMigrationReport(t *testing.T) {
+	report := &DownMigrationReport{
+		Database:         "metrics",
+		FromVersion:      3,
+		ToVersion:        0,
+		CatalogInstalled: true,
+		SeriesCount:      12,
+		Metrics: []MetricRowCount{
+			{Metric: "cpu_usage", Rows: 100},
+			{Metric: "mem_usage", Rows: 50},
+		},
+	}
+
+	if report.TotalRows() != 150 {
+		t.Errorf("unexpected total rows: got %d wanted 150", report.TotalRows())
+	}
+
+	out := report.String()
+	for _, e := range []string{
+		`database "metrics" from version 3 to version 0`,
+		"12 series and approximately 150 samples in 2 metrics",
+		"cpu_usage: ~100 samples",
+		"mem_usage: ~50 samples",
+	} {
+		if !strings.Contains(out, e) {
+			t.Errorf("missing %q in report:\n%s", e, out)
+		}
+	}
+
+	empty := &DownMigrationReport{Database: "metrics", FromVersion: 1}
+	if !strings.Contains(empty.String(), "catalog is not installed") {
+		t.Errorf("unexpected report for a missing catalog:\n%s", empty.String())
+	}
+}
+
+func TestDownMigrationSteps(t *testing.T) {
+	latest, err := LatestMigrationVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, err := downMigrationSteps(int(latest), latest-2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 2 || steps[0].Version != latest || steps[1].Version != latest-1 {
+		t.Fatalf("unexpected steps %+v", steps)
+	}
+	for _, s := range steps {
+		if s.Identifier == "" || s.SQL == "" || strings.Contains(s.SQL, "SCHEMA_") {
+			t.Errorf("unexpected step %+v", s)
+		}
+	}
+
+	report := &DownMigrationReport{Database: "metrics", FromVersion: int(latest), ToVersion: latest - 2, Steps: steps}
+	out := report.SQL()
+	for _, s := range steps {
+		header := fmt.Sprintf("-- %d_%s.down.sql\n", s.Version, s.Identifier)
+		if !strings.Contains(out, header) {
+			t.Errorf("missing %q in the SQL:\n%s", header, out)
+		}
+	}
+	if !strings.Contains(report.String(), fmt.Sprintf("Migrations reverted: %d_%s, %d_%s.", latest, steps[0].Identifier, latest-1, steps[1].Identifier)) {
+		t.Errorf("missing the reverted migrations in the report:\n%s", report.String())
+	}
+
+	if steps, err = downMigrationSteps(int(latest), latest); err != nil || len(steps) != 0 {
+		t.Errorf("unexpected steps %+v, %v at the target version", steps, err)
+	}
+	if _, err = downMigrationSteps(int(latest)+1, 0); err == nil {
+		t.Error("expected an error for a version without a down migration")
+	}
+}
@@ -0,0 +1,281 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/source/httpfs"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel/migrations"
+)
+
+const (
+	// The checksums live next to prom_schema_migrations in the public
+	// schema, so that they survive the down migrations dropping our schemas.
+	createChecksumTableSQL = `CREATE TABLE IF NOT EXISTS public.prom_schema_migration_checksums (
+		version     BIGINT PRIMARY KEY,
+		checksum    TEXT NOT NULL,
+		recorded_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`
+	selectChecksumsSQL = "SELECT version, checksum FROM public.prom_schema_migration_checksums"
+	insertChecksumSQL  = "INSERT INTO public.prom_schema_migration_checksums(version, checksum) VALUES ($1, $2) ON CONFLICT (version) DO NOTHING"
+	updateChecksumSQL  = "UPDATE public.prom_schema_migration_checksums SET checksum = $2, recorded_at = now() WHERE version = $1"
+	// The migration table is managed by golang-migrate, which keeps a single
+	// row in it.
+	selectMigrationVersionSQL = "SELECT version, dirty FROM public.prom_schema_migrations LIMIT 1"
+	resetMigrationVersionSQL  = "TRUNCATE public.prom_schema_migrations"
+	setMigrationVersionSQL    = "INSERT INTO public.prom_schema_migrations(version, dirty) VALUES ($1, false)"
+)
+
+// MigrationRepairError is returned by Migrate when the installed schema does
+// not match the migrations embedded in the binary: either a migration was
+// only partially applied, or an applied migration file has since changed.
+// Migrating stops until an operator inspects the database and confirms the
+// repair by passing RepairToken to RepairMigrations.
+type MigrationRepairError struct {
+	// DirtyVersion is the version of the partially applied migration, 0 if
+	// none.
+	DirtyVersion uint
+	// Mismatched lists the applied versions whose recorded checksum differs
+	// from the embedded migration file.
+	Mismatched []uint
+}
+
+func (e *MigrationRepairError) Error() string {
+	problems := make([]string, 0, 2)
+	if e.DirtyVersion != 0 {
+		problems = append(problems, fmt.Sprintf("migration %d was only partially applied", e.DirtyVersion))
+	}
+	if len(e.Mismatched) > 0 {
+		problems = append(problems, fmt.Sprintf("applied migrations %s were modified", joinVersions(e.Mismatched)))
+	}
+	return "schema needs repair: " + strings.Join(problems, " and ")
+}
+
+// RepairToken identifies the exact state to repair. Requiring the operator
+// to pass it back ensures the repair applies to the state they inspected.
+func (e *MigrationRepairError) RepairToken() string {
+	parts := make([]string, 0, 2)
+	if e.DirtyVersion != 0 {
+		parts = append(parts, fmt.Sprintf("dirty-%d", e.DirtyVersion))
+	}
+	if len(e.Mismatched) > 0 {
+		parts = append(parts, "checksums-"+joinVersions(e.Mismatched))
+	}
+	return strings.Join(parts, "+")
+}
+
+func joinVersions(versions []uint) string {
+	s := make([]string, len(versions))
+	for i, v := range versions {
+		s[i] = strconv.FormatUint(uint64(v), 10)
+	}
+	return strings.Join(s, ",")
+}
+
+// embeddedChecksums returns the SHA-256 of every embedded up migration,
+// keyed by version.
+func embeddedChecksums() (map[uint]string, error) {
+	src, err := httpfs.New(migrations.SqlFiles, "/")
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	checksums := make(map[uint]string)
+	version, err := src.First()
+	for err == nil {
+		r, _, readErr := src.ReadUp(version)
+		if readErr != nil {
+			return nil, readErr
+		}
+		content, readErr := ioutil.ReadAll(r)
+		r.Close()
+		if readErr != nil {
+			return nil, readErr
+		}
+		sum := sha256.Sum256(content)
+		checksums[version] = hex.EncodeToString(sum[:])
+
+		version, err = src.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return checksums, nil
+}
+
+func recordedChecksums(db *sql.DB) (map[uint]string, error) {
+	rows, err := db.Query(selectChecksumsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checksums := make(map[uint]string)
+	for rows.Next() {
+		var (
+			version  int64
+			checksum string
+		)
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, err
+		}
+		checksums[uint(version)] = checksum
+	}
+	return checksums, rows.Err()
+}
+
+// checkMigrationState compares the installed schema against the embedded
+// migrations. It returns a *MigrationRepairError if a repair is needed.
+func checkMigrationState(version int, dirty bool, recorded, embedded map[uint]string) error {
+	repair := &MigrationRepairError{}
+	if dirty {
+		repair.DirtyVersion = uint(version)
+	}
+
+	for v, sum := range recorded {
+		if int(v) > version || (dirty && int(v) == version) {
+			continue
+		}
+		if expected, ok := embedded[v]; ok && expected != sum {
+			repair.Mismatched = append(repair.Mismatched, v)
+		}
+	}
+	sort.Slice(repair.Mismatched, func(i, j int) bool { return repair.Mismatched[i] < repair.Mismatched[j] })
+
+	if repair.DirtyVersion == 0 && len(repair.Mismatched) == 0 {
+		return nil
+	}
+	return repair
+}
+
+// migrationVersion returns the applied migration version, or
+// database.NilVersion if none was applied, and whether it is dirty.
+func migrationVersion(db *sql.DB) (int, bool, error) {
+	var (
+		version int64
+		dirty   bool
+	)
+	err := db.QueryRow(selectMigrationVersionSQL).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return database.NilVersion, false, nil
+	}
+	return int(version), dirty, err
+}
+
+// verifyMigrations returns a *MigrationRepairError if the schema needs to be
+// repaired before migrating.
+func verifyMigrations(db *sql.DB, embedded map[uint]string) error {
+	if _, err := db.Exec(createChecksumTableSQL); err != nil {
+		return fmt.Errorf("cannot create the migration checksum table: %w", err)
+	}
+
+	version, dirty, err := migrationVersion(db)
+	if err != nil {
+		return err
+	}
+
+	recorded, err := recordedChecksums(db)
+	if err != nil {
+		return err
+	}
+
+	return checkMigrationState(version, dirty, recorded, embedded)
+}
+
+// recordChecksums records the checksum of every applied migration which does
+// not have one yet. Migrations applied before checksums were introduced are
+// trusted on first use.
+func recordChecksums(db *sql.DB, embedded map[uint]string) error {
+	version, dirty, err := migrationVersion(db)
+	if err != nil {
+		return err
+	}
+
+	for v, sum := range embedded {
+		if int(v) > version || (dirty && int(v) == version) {
+			continue
+		}
+		if _, err := db.Exec(insertChecksumSQL, int64(v), sum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RepairMigrations repairs the state reported by a *MigrationRepairError. The
+// token must equal the error's RepairToken, confirming that the operator
+// inspected this exact state. A partially applied migration is marked as not
+// applied, so that it runs again on the next Migrate, and modified migrations
+// have their checksums replaced by the embedded ones.
+func RepairMigrations(db *sql.DB, token string) error {
+	embedded, err := embeddedChecksums()
+	if err != nil {
+		return err
+	}
+
+	err = verifyMigrations(db, embedded)
+	if err == nil {
+		migrateLogger.Info("msg", "Migration repair requested but the schema does not need repair")
+		return nil
+	}
+	var repair *MigrationRepairError
+	if !errors.As(err, &repair) {
+		return err
+	}
+	if token != repair.RepairToken() {
+		return fmt.Errorf("repair token %q does not match the current state %q: %w", token, repair.RepairToken(), repair)
+	}
+
+	if repair.DirtyVersion != 0 {
+		previous := database.NilVersion
+		for v := range embedded {
+			if v < repair.DirtyVersion && int(v) > previous {
+				previous = int(v)
+			}
+		}
+		if err := setMigrationVersion(db, previous); err != nil {
+			return err
+		}
+		migrateLogger.Warn("msg", "Marked partially applied migration as not applied", "version", repair.DirtyVersion)
+	}
+
+	for _, v := range repair.Mismatched {
+		if _, err := db.Exec(updateChecksumSQL, int64(v), embedded[v]); err != nil {
+			return err
+		}
+		migrateLogger.Warn("msg", "Accepted modified migration checksum", "version", v)
+	}
+	return nil
+}
+
+func setMigrationVersion(db *sql.DB, version int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(resetMigrationVersionSQL); err != nil {
+		return err
+	}
+	if version != database.NilVersion {
+		if _, err := tx.Exec(setMigrationVersionSQL, int64(version)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,105 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/database"
+)
+
+func TestEmbeddedChecksums(t *testing.T) {
+	checksums, err := embeddedChecksums()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := LatestMigrationVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checksums) != int(latest) {
+		t.Errorf("unexpected number of checksums: got %d wanted %d", len(checksums), latest)
+	}
+	for v, sum := range checksums {
+		if len(sum) != 64 {
+			t.Errorf("invalid checksum for version %d: %s", v, sum)
+		}
+	}
+}
+
+func TestCheckMigrationState(t *testing.T) {
+	embedded := map[uint]string{1: "a", 2: "b", 3: "c"}
+	testCases := []struct {
+		name     string
+		version  int
+		dirty    bool
+		recorded map[uint]string
+		expected *MigrationRepairError
+		token    string
+	}{
+		{
+			name:     "Fresh database",
+			version:  database.NilVersion,
+			recorded: map[uint]string{},
+		},
+		{
+			name:     "Checksums not recorded yet",
+			version:  3,
+			recorded: map[uint]string{},
+		},
+		{
+			name:     "Matching checksums",
+			version:  2,
+			recorded: map[uint]string{1: "a", 2: "b"},
+		},
+		{
+			name:     "Modified migrations",
+			version:  3,
+			recorded: map[uint]string{1: "x", 2: "b", 3: "y"},
+			expected: &MigrationRepairError{Mismatched: []uint{1, 3}},
+			token:    "checksums-1,3",
+		},
+		{
+			name:     "Partially applied migration",
+			version:  3,
+			dirty:    true,
+			recorded: map[uint]string{1: "a", 2: "b", 3: "y"},
+			expected: &MigrationRepairError{DirtyVersion: 3},
+			token:    "dirty-3",
+		},
+		{
+			name:     "Both",
+			version:  2,
+			dirty:    true,
+			recorded: map[uint]string{1: "x"},
+			expected: &MigrationRepairError{DirtyVersion: 2, Mismatched: []uint{1}},
+			token:    "dirty-2+checksums-1",
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			err := checkMigrationState(c.version, c.dirty, c.recorded, embedded)
+			if c.expected == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			repair, ok := err.(*MigrationRepairError)
+			if !ok {
+				t.Fatalf("expected a repair error, got %v", err)
+			}
+			if !reflect.DeepEqual(repair, c.expected) {
+				t.Errorf("unexpected repair error:\ngot\n%+v\nwanted\n%+v", repair, c.expected)
+			}
+			if repair.RepairToken() != c.token {
+				t.Errorf("unexpected repair token: got %s wanted %s", repair.RepairToken(), c.token)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	// The migration lock uses the two-key form of the advisory locks, so
+	// that it cannot collide with the single bigint keys used for leader
+	// election and by golang-migrate.
+	migrationLockSQL       = "SELECT pg_advisory_lock(1953656173, 1)"
+	migrationUnlockSQL     = "SELECT pg_advisory_unlock(1953656173, 1)"
+	migrationInProgressSQL = `SELECT EXISTS (
+		SELECT 1 FROM pg_locks
+		WHERE locktype = 'advisory' AND granted
+		AND database = (SELECT oid FROM pg_database WHERE datname = current_database())
+		AND classid = 1953656173 AND objid = 1 AND objsubid = 2
+	)`
+
+	// The migrator lock serializes the connectors migrating the schema,
+	// such as several replicas starting at the same time.
+	migratorTryLockSQL = "SELECT pg_try_advisory_lock(1953656173, 2)"
+	migratorLockSQL    = "SELECT pg_advisory_lock(1953656173, 2)"
+	migratorUnlockSQL  = "SELECT pg_advisory_unlock(1953656173, 2)"
+
+	// DefaultMigrationWait is how long a query failing during a schema
+	// migration waits for it to complete before giving up.
+	DefaultMigrationWait  = 5 * time.Second
+	migrationPollInterval = 250 * time.Millisecond
+)
+
+// ErrMigrationInProgress is returned by queries failing while the schema is
+// being migrated. They can be retried once the migration completes.
+var ErrMigrationInProgress = errors.New("schema migration in progress")
+
+// lockMigration takes the migration lock, telling queriers that the schema
+// is being changed. The returned function releases it.
+func lockMigration(db *sql.DB) (func(), error) {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(context.Background(), migrationLockSQL); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return func() {
+		// Closing the session releases the lock even if unlocking fails.
+		if _, err := conn.ExecContext(context.Background(), migrationUnlockSQL); err != nil {
+			migrateLogger.Debug("msg", "Cannot release the migration lock", "err", err)
+		}
+		_ = conn.Close()
+	}, nil
+}
+
+// lockMigrators takes the migrator lock, waiting for the other connectors
+// migrating the schema to complete. The returned function releases it.
+func lockMigrators(db *sql.DB) (func(), error) {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var locked bool
+	if err = conn.QueryRowContext(context.Background(), migratorTryLockSQL).Scan(&locked); err == nil && !locked {
+		migrateLogger.Info("msg", "Waiting for another connector to complete the schema migration")
+		_, err = conn.ExecContext(context.Background(), migratorLockSQL)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return func() {
+		// Closing the session releases the lock even if unlocking fails.
+		if _, err := conn.ExecContext(context.Background(), migratorUnlockSQL); err != nil {
+			migrateLogger.Debug("msg", "Cannot release the migrator lock", "err", err)
+		}
+		_ = conn.Close()
+	}, nil
+}
+
+// migrationInProgress reports whether another session holds the migration
+// lock. Failing to check is reported as no migration in progress, so that the
+// original error surfaces.
+func migrationInProgress(conn pgxConn) bool {
+	rows, err := conn.Query(context.Background(), migrationInProgressSQL)
+	if err != nil {
+		return false
+	}
+	defer rows.Close()
+
+	var inProgress bool
+	if !rows.Next() || rows.Scan(&inProgress) != nil {
+		return false
+	}
+	return inProgress
+}
+
+// waitForMigration waits up to timeout for a migration in progress to
+// complete. It returns false if the migration is still running.
+func waitForMigration(conn pgxConn, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for migrationInProgress(conn) {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(migrationPollInterval)
+	}
+	return true
+}
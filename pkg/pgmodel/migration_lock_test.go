@@ -0,0 +1,102 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestQueryDuringMigration(t *testing.T) {
+	sqlErr := &pgconn.PgError{Code: pgerrcode.UndefinedTable, Message: "relation does not exist"}
+	connErr := errors.New("connection refused")
+	testCases := []struct {
+		name         string
+		queryErr     map[int]error
+		queryResults []rowResults
+		err          error
+		queries      int
+	}{
+		{
+			name:         "not migrating",
+			queryErr:     map[int]error{0: sqlErr},
+			queryResults: []rowResults{nil, {{false}}},
+			err:          sqlErr,
+			queries:      2,
+		},
+		{
+			name:         "connection error",
+			queryErr:     map[int]error{0: connErr},
+			queryResults: []rowResults{nil, {{true}}},
+			err:          connErr,
+			queries:      1,
+		},
+		{
+			name:         "migration still running",
+			queryErr:     map[int]error{0: sqlErr},
+			queryResults: []rowResults{nil, {{true}}, {{true}}},
+			err:          ErrMigrationInProgress,
+			queries:      3,
+		},
+		{
+			name:         "migration completed",
+			queryErr:     map[int]error{0: sqlErr},
+			queryResults: []rowResults{nil, {{true}}, {{false}}, {}},
+			queries:      4,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &mockPGXConn{
+				QueryErr:     c.queryErr,
+				QueryResults: c.queryResults,
+			}
+			querier := pgxQuerier{conn: mock, metricTableNames: &mockMetricCache{metricCache: map[string]string{}}}
+
+			_, err := querier.Query(context.Background(), &prompb.Query{
+				Matchers: []*prompb.LabelMatcher{
+					{Type: prompb.LabelMatcher_EQ, Name: "foo", Value: "bar"},
+				},
+			})
+
+			if !errors.Is(err, c.err) {
+				t.Errorf("unexpected error: got %v wanted %v", err, c.err)
+			}
+			if len(mock.QuerySQLs) != c.queries {
+				t.Errorf("unexpected number of queries: got %d wanted %d", len(mock.QuerySQLs), c.queries)
+			}
+		})
+	}
+}
+
+func TestQueryCanceled(t *testing.T) {
+	sqlErr := &pgconn.PgError{Code: pgerrcode.QueryCanceled, Message: "canceling statement due to user request"}
+	mock := &mockPGXConn{
+		QueryErr:     map[int]error{0: sqlErr},
+		QueryResults: []rowResults{nil, {{true}}},
+	}
+	querier := pgxQuerier{conn: mock, metricTableNames: &mockMetricCache{metricCache: map[string]string{}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := querier.Query(ctx, &prompb.Query{
+		Matchers: []*prompb.LabelMatcher{
+			{Type: prompb.LabelMatcher_EQ, Name: "foo", Value: "bar"},
+		},
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error: got %v wanted %v", err, context.Canceled)
+	}
+	// A canceled query is not mistaken for one failing during a migration.
+	if len(mock.QuerySQLs) != 1 {
+		t.Errorf("unexpected number of queries: got %d wanted 1", len(mock.QuerySQLs))
+	}
+}
@@ -21,6 +21,34 @@ var SqlFiles = func() http.FileSystem {
 			name:    "/",
 			modTime: time.Time{},
 		},
+		"/10_cache_invalidation.down.sql": &vfsgen۰CompressedFileInfo{
+			name:             "10_cache_invalidation.down.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 266,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x09\xf2\x0f\x50\x08\x09\xf2\x74\x77\x77\x0d\x52\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\xc8\xcb\x2f\xc9\x4c\xab\x8c\x2f\x4e\x2d\xca\x4c\x2d\x8e\x4f\x49\xcd\x49\x2d\x49\x55\xf0\xf7\x53\x08\x76\xf6\x70\xf5\x75\x8c\x77\x76\x0c\x71\xf4\xf1\x77\xd7\x83\xc8\x5b\x73\x81\x4d\x71\x0b\xf5\x73\x0e\xf1\xf4\xf7\x43\x32\x06\x4d\x39\x36\x53\x35\x34\xa1\xda\x71\x3a\x22\x37\xb5\xa4\x28\x33\x39\x3e\x39\x23\x31\x2f\x1d\x9b\x23\x20\xf2\xa4\x3a\x02\xc5\x54\x90\x23\x00\x03\x00\x28\xb1\xd7\xae\x0a\x01\x00\x00"),
+		},
+		"/10_cache_invalidation.up.sql": &vfsgen۰CompressedFileInfo{
+			name:             "10_cache_invalidation.up.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 1461,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xac\x54\x5d\x6f\xa3\x3a\x10\x7d\xe7\x57\x9c\x87\x4a\x69\x25\x92\x1f\xd0\x54\x95\xb8\xc4\xa1\x48\x14\xb8\x0e\xdc\xde\x37\xe4\x05\x27\x58\x02\x3b\x32\xde\x76\x2b\xf5\xc7\xaf\x6c\xd3\xed\xd7\x56\xdb\xee\x6e\x5e\x22\xec\x99\x33\x33\xe7\x9c\xf1\x72\x89\x5c\x19\xb1\x17\x7c\x82\xe9\x39\x5a\x25\x25\x6f\x8d\xd2\x53\x08\x25\xdd\xd1\x51\xab\xb1\x69\x59\xdb\xf3\x46\xc8\x5b\x36\x88\x8e\x19\xa1\x24\xda\x9e\x49\xc9\x87\x10\x6a\x6f\xe3\x82\xe5\x12\x2d\x33\x6c\x50\x07\x77\x75\xe0\x13\x9e\xe2\xe5\xc1\xc6\x08\x0d\x07\x34\x9d\xdb\x2f\x8c\xdc\x68\xd1\x4e\x68\x35\x67\x86\x77\xe1\x9c\xd7\x41\x69\x8b\xd6\x69\x75\x3c\xda\xe3\x3b\x61\x7a\xdf\x0a\xbb\x1f\x14\xeb\xe6\xc4\xf3\x0b\xff\x0f\xc9\x46\x7e\x19\x82\xc9\xce\x45\x4d\x5c\xdb\x71\x3a\x3e\x70\x8b\x6a\xa1\xde\x20\xf8\x98\x1f\x08\xa2\xbb\x5c\xa1\xea\x39\xa4\x23\xa3\x75\x13\x4e\x76\x32\x06\xa3\x99\x9c\x58\x6b\x4f\x5e\x40\x4d\x6c\x7c\xc2\x63\x9a\xdb\x82\xe2\x96\x6b\xdb\xbf\x6c\x79\x88\xbb\x9e\x4b\x08\x83\x56\x8d\xa3\x30\xd3\x2a\x88\x29\x89\x2a\x82\x82\x82\x92\x32\x8b\x62\x82\x6d\x9d\xc7\x55\x5a\xe4\xd8\xc5\x57\xe4\x3a\x6a\xe2\xa8\x8a\xb2\x22\x59\xb9\x36\xee\x1b\xdf\x5d\xe3\x69\x39\x3d\x0b\x28\xa9\x6a\x9a\xef\x50\xd1\x34\x49\x08\x0d\xa2\x1d\x4e\xf6\x5f\x65\x7b\x12\xfc\x43\x92\x34\x0f\x00\x20\xdd\xa2\x4a\x9a\xa2\xc4\xc5\x25\x16\x69\xbe\x23\xb4\x5a\xa0\xba\x22\xfe\xd6\xfe\x4a\x42\xb7\x05\xbd\xc6\xf1\xd0\xf8\x3a\xa7\x8b\x77\x34\x5e\x84\x58\xcc\x5c\x2f\xf0\xf0\x80\x22\xdb\xac\xe6\x9e\x2c\xe7\x67\x6b\x87\x49\xf2\x0d\xd2\xed\xfa\x6d\xf5\x0d\xc9\x48\x45\xfe\x5a\xf5\x9c\xdc\xfc\xa2\xba\xe7\x07\x79\x9d\x65\xeb\x80\xe4\x9b\x60\x66\x27\x8b\xf2\xa4\x8e\x12\x82\x32\x2b\x93\xdd\xbf\x19\xfe\x2b\xb2\xa8\x4a\x33\xb2\x0e\x1e\x45\x99\x29\xc5\xcf\x98\x77\xd8\xd1\xb6\x22\x14\x9e\x50\x2b\x61\x5d\x6e\x66\x31\xfd\x98\x78\xab\xa2\x07\x71\xd9\xdb\x82\x82\x44\xf1\x15\x68\x71\xe3\xdb\xfe\x9f\xc4\x75\x45\x50\xd2\x22\x26\x9b\x9a\x92\x8f\x59\x60\x1d\x7c\xde\x45\xde\xeb\x8d\xdf\x87\x0f\xba\xe8\x73\x2a\xcd\xdb\xf4\xda\x23\xa2\x3b\xfb\x7d\x5d\x96\x4b\xc4\x83\x92\xbc\x83\x51\xcf\xd7\xda\xb0\x2f\x03\xb7\x9b\xc9\x6f\xb9\xbe\x9f\x9f\x82\x10\xfc\x9b\x98\xdc\x33\xa3\xf4\xe3\x7b\x82\x81\x19\xae\x43\x4c\xca\x82\x99\x9e\x19\x08\x83\xbd\xd0\x7c\xc2\x5e\x69\x07\xea\x48\x71\xbb\xbe\xd7\x6a\xb4\x47\x23\x8c\x52\xab\x77\x6c\xf1\x82\xca\x67\xb6\x78\xd7\x02\x3e\xe1\xcf\x2c\xf0\x4a\xbf\x75\xf0\x7d\x00\x8c\x39\xa3\x99\xb5\x05\x00\x00"),
+		},
+		"/11_series_tombstone.down.sql": &vfsgen۰CompressedFileInfo{
+			name:             "11_series_tombstone.down.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 54,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x73\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x08\x76\xf6\x70\xf5\x75\x8c\x77\x76\x0c\x71\xf4\xf1\x77\xd7\x2b\x4e\x2d\xca\x4c\x2d\x8e\x2f\xc9\xcf\x4d\x2a\x2e\xc9\xcf\x4b\xb5\xe6\x02\x00\x7a\x80\x0b\xf1\x36\x00\x00\x00"),
+		},
+		"/11_series_tombstone.up.sql": &vfsgen۰CompressedFileInfo{
+			name:             "11_series_tombstone.up.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 717,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x85\x90\xdd\x6e\xc2\x30\x0c\x85\xef\x79\x0a\x5f\x0e\x09\x78\x81\x5d\x05\xc8\x58\xb5\xb6\x20\x08\x12\x6c\x9a\xaa\x40\x0c\x8d\xd4\x26\x28\x09\x63\xbc\xfd\xdc\x96\x9f\xa9\x62\x5a\xa5\x5e\xc4\xe7\xf3\xb1\x7d\xfa\x7d\x10\x39\x42\xb0\xe5\xc6\x07\x6b\xd0\x83\xdd\x41\xa0\x8a\x47\xa7\xe9\xa5\xb0\xc0\x80\x8a\x4a\xce\x1e\xf7\x79\x2d\x35\xb5\x2b\xc1\x66\x11\x9c\x74\xc8\x41\x76\xfa\x7d\xd8\x3b\xb9\x45\x38\x90\x64\xd5\xa0\xb2\xd6\x0e\xbc\x2c\x0f\x05\x91\x1b\x0c\x27\x44\x03\x3e\x48\x17\xb2\xa0\x4b\x04\x69\x14\xa0\x51\x97\x87\x43\xc8\xb5\x52\x68\x2a\xa7\x9d\xb3\x25\x38\x94\xca\xf7\x6a\xec\x90\x9f\xbd\xde\xca\xa2\x38\xdf\x96\xda\x9c\xeb\x7d\x0a\x82\xd0\x81\x35\xd5\xe4\xa3\xdb\x63\x26\x77\x81\x0a\xda\xc3\x41\xfa\xd0\xab\xdc\x8e\x86\x36\xf0\x35\x7e\xbb\xb5\x02\x1c\x96\xf6\x8b\xac\x76\xda\xf9\x40\x12\x81\x97\xf3\x08\x2d\x07\x9d\xd1\x9c\x33\xc1\x41\xb0\x61\xcc\x61\x31\x7a\xe5\x09\xcb\x46\x4c\xb0\x78\x3a\x19\x34\x01\x64\x77\xbf\xa7\x0e\xd0\xa7\x15\x0c\xa3\xc9\x82\xcf\x23\x16\xc3\x6c\x1e\x25\x6c\xbe\x86\x37\xbe\xee\xd5\x6a\x89\xc1\xe9\x6d\x66\x24\x1d\x2c\xf8\x4a\x40\x3a\xa5\x7f\x19\xc7\x8d\x1c\xe4\xa6\xc0\x46\x4d\x59\xc2\x5b\xea\x65\xa2\x56\xbe\x1a\x11\xa5\xe2\xe3\xb3\x4d\xdc\xb3\x15\x51\xc2\x17\x82\x25\x33\xf1\xde\x82\x6e\x89\xff\x8d\x6c\x29\x79\x8a\x38\x93\xe1\x21\x04\x63\xfe\xc2\x96\xb1\x00\x63\x4f\x4f\xdd\xa6\xe5\x77\xf4\x8f\x7a\x3a\xdd\xe7\x6b\x9c\x51\x3a\xe6\x2b\x68\xe7\x97\xdd\x8f\xa7\x13\xbf\x61\x9a\xfe\x9f\xf8\xbd\x85\xdc\x7f\x00\x97\xcd\x68\x2f\xcd\x02\x00\x00"),
+		},
 		"/1_base_schema.down.sql": &vfsgen۰CompressedFileInfo{
 			name:             "1_base_schema.down.sql",
 			modTime:          time.Time{},
@@ -35,10 +63,142 @@ var SqlFiles = func() http.FileSystem {
 
 			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xec\xbd\x7b\x73\x22\xc9\x95\x28\xfe\x3f\x9f\xe2\x78\x43\x6d\x60\x06\x98\x56\xcf\x6f\xfd\xdb\x55\x5b\x6d\x33\x52\xa9\x9b\x35\x0d\x32\xa0\x79\xdc\xb9\x1d\x6c\xaa\x2a\x81\x1a\x15\x55\x4c\x65\x22\x35\x8e\x0d\x7f\xf6\x1b\x79\xf2\x51\x99\xf5\x80\x42\x8f\x69\x3b\xd6\x8a\x8d\x75\x4f\x91\xcf\x93\xe7\x9c\x3c\xef\xec\x76\x47\xe3\x99\x37\x6d\x74\xbb\xb3\x55\xc8\xc0\x4f\x02\x0a\x84\xb1\xed\x9a\x32\xe0\x2b\xc2\x81\x93\xdb\x88\x42\x4c\xc4\x07\x9f\xc4\x90\xc4\xd1\x0e\x6e\x29\xfc\xe1\x5b\xf0\x57\x24\x65\x10\x25\xf1\xb2\xd1\xb8\x1c\xc3\xc9\x49\x03\x00\xe0\x3b\xef\xfd\x60\x84\xff\x12\x7f\x17\x13\xaf\x3f\xf3\x60\x32\x1e\x7a\xb0\x49\x93\xf5\x3c\xa5\x24\xa0\xe9\x5b\x6c\xe0\xfd\x78\xe1\x5d\xcf\x06\xe3\x11\xfc\xf0\xc1\x1b\x41\xb0\xdd\x44\xa1\x4f\x38\x9d\x27\xb7\xbf\x50\x9f\xc3\xec\x83\x97\x8d\x34\xe9\x0f\xa6\x1e\x8c\xc6\xb3\xc1\x85\x07\xcd\x34\x89\xa8\x3d\x20\x90\x48\xfc\x63\x07\xf4\x73\xc8\x38\xeb\x00\xbb\x0b\x37\x9b\x30\x5e\x82\x9f\x52\xc2\x69\xf3\x6d\x36\x90\x37\xbb\x99\x8c\xd4\x0a\x46\x97\x8d\x93\x93\xb7\xf5\x97\xff\x90\x86\xfc\x59\x97\x2f\x07\x7c\xe2\xf2\xdf\x4f\xfa\xa3\x99\x03\x8e\xd9\xd8\x5d\x6f\x43\xed\x64\x7a\xf1\xc1\xfb\xd8\x87\xc1\x95\x58\x0a\x78\x3f\x0e\xa6\xb3\xa9\xfa\x38\xbf\xe8\xcf\xfa\xc3\xf1\xfb\xb7\xd0\xed\x82\x4f\x38\x89\x92\xa5\x3c\x7e\x06\x5f\x43\x18\x73\x9a\xc6\x24\x82\xc5\x36\xf6\x79\x98\xc4\x4c\xcd\x7a\x33\xed\xbf\xf7\x60\x3c\xd2\x43\xbb\x83\x99\x85\xe8\x73\x97\x9d\xa6\xde\xd0\xbb\x98\x89\x5e\xfd\xe1\x10\x66\xfd\xef\x86\xde\x14\x06\x75\xc7\xe8\x0f\x67\xde\x04\x2e\xbd\xab\xfe\xcd\x70\x06\xd7\x93\xc1\xf7\x83\xa1\xf7\x7e\xdf\x08\xf9\x59\xd5\x8c\xe5\x8b\xab\xb9\x23\x0d\x5a\x7b\xec\x0e\x0c\x46\x53\x6f\x32\xeb\xc0\xcd\xf5\x65\x7f\xe6\x75\xe0\xd2\x1b\x7a\x33\xef\xd8\x9d\xea\xb1\x9f\xb6\xd3\x7d\xab\xc9\x41\xe0\x18\x3c\xb9\x9e\x8c\x3f\x22\x92\x6c\xb6\xb7\x51\xe8\xd7\xc5\x08\xd1\xad\x00\xf1\x3a\xf3\x79\x3f\xce\x70\xba\x64\xc3\xc3\x75\xf8\x37\x1a\xc0\x3d\x4d\x99\x98\x10\x92\x45\x36\xbb\x22\x95\x00\x6e\x77\xc0\x57\x14\xe8\x67\x4e\x63\xd1\x6c\xff\xb2\xbc\x1f\x67\x8f\x5a\xd5\xd4\x9b\x0c\xbc\x29\x2e\x8c\xd1\x34\xa4\x0c\xee\x43\xfa\x70\x00\x06\xb2\xd3\x93\x88\xa2\x62\x88\xfa\x98\xa2\x06\xa8\x49\x12\x75\x40\xf1\xd1\x9b\x4d\x06\x17\x08\x8a\x35\xe5\x69\xe8\xd7\x01\x85\xec\xf4\x24\x50\x54\x0c\x51\x1f\x14\x6a\x80\x67\x04\xc5\x65\x7f\xd6\x3f\xc0\x47\x44\x93\x27\x6d\xbb\x74\x80\xfa\x9b\xc6\xee\xcf\xc1\x10\x9d\x75\x3c\x27\x37\x2c\x1d\xf8\x09\x1b\x7c\x21\x3e\x28\xe6\xd1\x6c\xe0\x30\xa4\x9e\x83\xf6\xf7\x8d\x73\x1c\x7c\x8e\xe4\x02\x47\xef\xee\xb9\xd1\xa1\x6a\xfc\xa7\xef\xfa\x31\xc8\x51\x07\x3b\x06\xa3\xab\xf1\x01\xc0\x89\x26\x4f\xc2\x87\xd2\x01\xea\x83\x04\xbb\x1f\xc9\xfc\x2e\xc7\x1f\xfb\x66\x20\xbc\xd3\x7b\x11\xb9\xa5\xd1\x9c\xa4\x29\xd9\x41\x7f\x2a\x24\xc5\x9f\x3f\x21\x40\x46\x37\xc3\xe1\xdb\x46\xa3\xdb\xc5\xfb\x98\x87\x6b\xca\x7c\x12\xd1\xb9\x18\x98\xf2\x15\xdd\xb2\x39\xfd\xcc\x53\x92\x5d\xd5\xe0\x27\x31\x27\xa1\xb8\xd9\xf3\x97\xbd\xb8\xeb\x59\xb2\xa6\x62\xb8\x64\x01\xc9\x36\xb5\xae\x7e\x12\x07\x90\x6c\x68\x4a\x78\x92\xb2\x1e\xcc\x12\xa0\x31\xdb\xa6\x14\x27\xf6\x93\x34\x15\xf2\xb8\x35\x90\xf8\x4c\x52\x1c\x6b\xcb\x68\xd0\xb1\x85\x81\xf5\x96\x71\xa1\xe1\xdc\xd2\x45\x92\x52\x20\x51\xa4\xe7\x4b\xf8\x8a\xa6\xc0\xfc\x15\x5d\x13\x06\x61\x8c\xc3\x30\x4a\x52\x7f\x05\x1b\xc2\x57\x4a\x8d\xb8\xf4\x2e\x86\xfd\x89\x27\x24\xf4\x98\x3e\xcc\xc5\x2f\xc0\xe9\x67\xfe\xb6\x61\x94\x0b\xf3\xfd\xec\x1c\xfc\x6d\x9a\xd2\x98\xcf\x19\xe5\x3c\x8c\x97\xad\xa6\x1c\x11\x7f\x6f\xb6\xe1\x7f\xfe\x07\x16\x49\xba\x26\xbc\xd5\xec\xbc\x1a\x9a\xff\x6b\x76\xa0\x99\x2d\xda\xfa\x2f\x71\x24\xd6\x7f\xca\x2b\xce\xfa\xa0\x04\xc5\x66\x1b\x55\x08\xfa\x99\xfa\x5b\x4e\xcd\x14\x0a\x79\xfa\xb3\xfe\x77\xfd\xa9\x07\xaf\x06\x30\xf5\x66\x60\xad\x08\xce\xe1\x15\x6b\x76\xcc\xaa\x03\xc2\xc9\x2d\x61\xb4\xd5\xee\x98\x5d\x95\x0f\x5d\x31\x90\xd5\x49\xab\x33\x02\x65\x4a\xff\xc4\x79\xcd\x50\x21\x0d\xe8\x22\x8c\x43\x79\xf8\xf8\xbd\xbc\xbd\xc6\x5a\x44\x6a\x25\xaf\xf6\x10\xaf\xc3\x98\x71\x12\x45\x44\x0c\x31\x0f\xe3\x45\x02\x2d\x54\xa9\xee\xe8\x0e\x66\x02\x0d\xae\x27\x83\x8f\xfd\xc9\x4f\xf0\x17\xef\xa7\x0e\xfe\x72\x4f\xa2\x2d\xc5\xdf\x1a\xed\xb7\x8d\x86\xe4\x1a\x30\x18\x09\x4a\xd9\x37\x70\xeb\x8e\xee\x3a\xb2\x77\x1b\xbe\xef\x0f\x6f\xbc\x29\x8e\xd7\x6a\x6a\x25\x4b\x62\x54\xb3\xa3\x55\xbc\xc2\x51\x75\x54\x87\x8c\x70\xa0\x7f\x3d\xc8\xfa\x39\x67\x6f\x5a\x67\x54\xe5\x4e\x60\xe3\x8d\x69\xac\x64\xd8\xfc\x52\x4c\x63\xc9\x39\xb3\xf6\x4a\xd0\xab\x6c\xaf\xf0\xce\xb4\x17\x78\x52\x6c\x9d\xb5\x17\x28\x97\xb5\x16\x70\x13\x58\x93\x5f\x7c\xd3\xe2\x5c\x02\x83\x73\x07\xec\xc2\xad\xa7\xf6\x24\x0f\x36\x0c\xe0\x36\x5c\x86\x31\x37\xac\x49\x4e\x26\x37\x32\x0f\x03\x28\xfe\x86\x9c\x8d\x55\x32\x3b\xdd\x18\xba\x5d\xd5\x92\xa4\x14\x96\x51\x72\x4b\xa2\x68\x07\xdb\x38\xfc\x75\x2b\xf8\x88\x4f\xb6\x8c\x0a\x26\xb2\x4a\x1e\x60\x43\x52\xae\x10\x57\xb4\x46\x44\xa6\x41\xa3\x0d\xd7\xfd\xc9\x6c\x80\xe6\x84\xef\x7e\x82\xe1\x60\x3a\x6b\x99\xa5\xb5\xdf\xea\x7d\x0e\x46\x97\xde\x8f\x4a\xe1\x98\xcb\x49\xc5\xd2\xcd\xd5\x92\xdf\xfb\xcd\x74\x30\x7a\x0f\xef\x07\x23\x68\xc9\xd6\xd9\x50\x53\xef\xaf\x37\xde\xe8\xa2\x02\x6a\xf3\x30\x78\xbb\x1f\xba\x38\x5e\x06\x5c\xd1\x8d\x44\x70\xf1\xc1\xbb\xf8\x0b\xb4\xc2\x00\xde\xc1\x6b\x75\x9e\x9a\xa6\x6c\x3a\x12\x0c\x51\xfe\xb7\x45\x68\xa2\x5f\x1b\x06\xa3\x8b\xe1\xcd\xa5\x07\x36\xe1\xc8\xa6\x37\xa3\xc1\x5f\x6f\xdc\x1f\xb2\xd6\x61\xd0\x46\xc2\x54\xa6\x2c\x69\xb8\x92\x3a\x21\x03\xa2\x4f\x63\x4d\xd0\xae\xd2\xe8\x76\x6f\x29\x7f\xa0\x34\x96\x87\x2c\xd6\x28\xaf\x11\xbe\xa2\x61\x0a\x7e\x12\x6d\xd7\xb1\xb2\x7b\x11\x3f\x4d\x18\x53\x98\xc2\x7a\x7a\x86\x90\x41\x90\xc4\xc8\xe0\x60\xcb\xc8\x6d\x18\x85\x7c\x27\x8e\xd9\xea\xdc\x01\xca\x36\xd4\x0f\x11\x21\x16\x49\x2a\x38\x58\x94\xc4\x4b\x39\x1f\x5a\xd8\x96\x94\x83\xbf\xe5\x90\x2c\x16\xbd\xc3\x00\x9f\xdf\xd1\x9d\x81\xb9\x20\xca\xfe\xb0\x12\xc8\x73\xb9\x90\xb9\x58\x08\x8c\xfa\x1f\xbd\x8e\xea\x58\xf1\x43\xfe\x24\x6c\xa0\x0b\x98\x4b\xf8\xd6\x5a\xe2\x7c\x93\x30\xc4\x72\x85\x20\x0a\x95\x71\x42\x3c\x7a\xe8\x76\x53\xba\xa0\x29\x8d\x7d\xaa\x41\xdb\xb3\x5b\x09\xe2\x52\x9f\xc3\x00\x61\xbc\xa1\x29\x72\x85\xd8\xa7\x90\x52\xc2\x92\x98\xb9\x3b\x07\xb4\x66\x82\x59\xc4\x9e\x8e\x3d\xec\xb9\x49\xc4\x35\xce\x5d\xe4\xb2\x16\xd1\x11\x63\x5b\x28\xb6\x49\xd8\x61\x18\x28\xce\x98\x3b\xa4\xe2\x7d\x92\x07\x49\x8e\xf7\x20\xfe\xca\x5f\x0d\x3c\xb2\x5f\x11\xaf\xc5\x0d\xe3\x27\xeb\x4d\x44\x39\x0d\xe0\xbb\xf1\x78\xe8\xf5\x47\x19\x57\xd2\x22\xe0\x82\x44\x8c\xca\x6e\x01\x5d\x90\x6d\xc4\xe7\xfe\x6a\x1b\xdf\xcd\xd1\xa6\x77\x4f\xa2\xea\xae\x3c\xdd\xaa\x9e\x29\xe5\x34\xc6\x19\x37\x34\x0d\x93\x40\x5c\x7c\xde\xe4\xfb\x7e\xd6\x16\x17\x27\x8e\x40\x0c\xc0\x13\x21\x58\xa1\x80\xa4\xe6\x2c\x8c\x50\x05\x74\x0b\xde\x19\x0c\x5c\x5c\xb4\xbe\x1f\x3c\x0e\x3d\xfd\x13\xae\xf7\xf2\x11\x91\x0b\xb9\xd7\x7a\xab\xe9\x02\xb6\xd9\x81\x96\x81\x53\xf3\x3f\x60\x95\x6c\x53\xd6\x6c\x9f\x9d\x89\xf3\x6e\x77\x1a\xad\x66\x1e\x28\xa2\xc7\x7f\xbe\x86\xaf\x32\xf0\x36\x4f\x21\x20\x3b\xd3\x09\x19\xdc\x05\x89\x93\x38\xf4\x49\x04\x51\xe2\xdf\x41\x92\x06\x34\x0d\xe3\xe5\x59\xa3\xdb\x55\x4c\xaa\xd1\xed\xe2\x8d\x8b\x90\x6a\xe8\xfb\xa9\xd1\xed\xaa\x7b\x61\x43\x84\xf4\xe6\xfc\xb7\xbc\x95\xc4\xf0\x7e\x12\x33\x9e\x92\x30\xe6\x4c\x0c\xd9\x85\xd9\x4a\x59\xab\x2d\xf3\x0e\x70\x72\x47\x19\x2e\xc0\xc8\xc2\xb8\x90\x33\xc8\x66\xee\x40\x7e\xfc\x9e\x39\xad\xf1\x04\x26\xde\xf5\xb0\x7f\xe1\xc1\xd5\xcd\xe8\x02\x6f\xbe\x1c\xa4\x97\x94\xcf\xcb\x51\xb6\xd5\x6e\x64\xc6\xf0\xa9\x81\x56\xa3\x3f\x85\x13\xa1\x16\x48\x63\xbe\xd2\x6a\xf0\x90\xce\xce\x0c\x48\xaf\x26\xe3\x8f\x55\x68\xf2\xc3\x07\x6f\xe2\x09\x34\x39\xcf\x9f\xe5\xdb\x86\x1a\x79\xd8\x1f\xbd\xbf\x11\x0a\xdd\xf4\xaf\x43\x98\x4a\xa4\xbb\xee\x4f\xfa\xc3\xa1\x37\x84\x69\xff\xca\xd3\x4a\x9c\xf7\xa3\x77\x71\x23\x35\xc9\xc7\xec\xb0\x52\x07\x3b\x12\x72\x79\x1c\xfb\x2d\x60\x57\xc0\xeb\x17\x87\x5e\x71\x97\x45\xf8\xa9\x8b\x7b\x93\x26\x3e\x0d\x84\x7a\xb8\x08\x63\x12\x85\x7f\x43\x67\x17\x35\x4c\x55\xdc\xe1\x44\x5f\x3e\x88\xfc\x8b\x30\x65\x1c\x91\x18\x92\x85\xa1\xb2\xac\xc3\x8a\x6c\x36\x34\x46\x3a\x58\x93\x3b\xaa\xe8\x64\x2e\x65\x10\x25\x55\xc8\xc9\xe4\x20\xba\xfd\x8a\xa6\x54\xc8\x13\x3f\x50\x60\x9b\x28\xe4\x90\x1f\x38\x8c\x79\x02\xfc\x21\xc1\x6e\x4c\xb0\xd5\x75\x18\xa3\x62\x0c\x11\xe1\x34\xf6\x77\x10\x6c\x05\xed\x43\x18\x33\x9a\x22\x05\x77\xbb\xad\x87\x55\xe8\xaf\xec\x55\x89\xf9\x8b\x2b\x43\xbd\xab\x07\x5e\x26\xa2\xc4\x09\xa7\x0f\x49\xca\x57\x3b\x21\xde\x08\xf9\xa4\xd1\xed\x12\xce\x89\xbf\x12\x93\x88\x61\x0c\x29\x8b\xd5\x48\x0d\x18\x49\x5c\x0e\x69\xef\xcc\x88\xbe\xa1\xe0\xfe\xbf\x6e\xc3\x94\x0a\x16\x44\x62\xa0\x9f\xfd\x68\xcb\xc2\x7b\x8a\xfc\xa3\x03\x72\xbd\xa1\x90\xd3\x56\xe1\x72\xd5\xd5\x7b\x93\x3a\xbd\x60\x1b\x78\x0c\x52\x01\x27\x4a\xe9\xe7\xab\x50\x0c\xa7\xad\x00\x10\x24\x54\xca\xd4\x71\xc2\x81\x30\xf0\xd3\x90\x4b\x26\x29\x47\xeb\x3e\x84\x8c\xc2\xed\x96\x63\x23\x12\xb1\x04\x5b\xc6\xd4\xa7\x8c\x91\x74\xd7\xe8\x76\x79\xa2\x85\x05\x01\x34\x64\x67\x62\x97\x12\xb6\x92\xaf\xc9\xd3\xdc\xca\x99\x36\x5b\x6e\xce\xb0\xd1\xed\x8e\x12\x4e\xcf\xa4\x54\x47\x40\x70\x51\xfa\xeb\x56\x08\x38\xd2\xe4\x20\xe4\x45\xca\xc2\x65\xac\x41\x6b\x43\x2f\x83\xaa\x80\x02\x02\x9c\x06\x72\x45\x6e\x2b\x1a\x73\x20\x0b\x4e\x53\x79\xac\x21\x03\xc6\xe9\x46\xc0\x47\xac\x49\x23\xd0\x3a\x5c\xae\x38\x6e\xef\x56\x74\xa6\x02\x93\xb4\xf9\x04\xa5\x59\x8d\xc2\xbf\x6e\xe5\xc8\x29\x76\x20\x0f\x64\x27\x86\x4a\x18\x35\xbf\x88\x29\x9b\x1c\xfc\x64\xbd\x16\x98\x9e\x3c\xd0\x7b\x71\x08\x0a\xa9\x03\x1a\x11\x01\x39\x21\x0f\xc7\x62\x73\xe1\x22\xf4\x49\xcc\xc5\x7c\x9b\x54\x1c\x95\xaf\xa1\x23\x8e\xba\xab\xae\x08\x35\xbb\xba\x24\x04\x60\xe7\x85\x0b\x83\xc6\xbc\x78\x7f\x68\x1e\x78\x3d\x19\x5f\x78\x97\x37\x93\xc2\x7d\xaf\x49\x5a\x63\xba\x26\xa5\x56\x1b\x19\x9c\xa0\x7d\xc7\x44\x03\x29\x4c\xbc\x8b\xf1\xe4\xf2\x6d\x26\x58\xd1\x00\x6e\x93\x24\xa2\x24\xb6\x6c\x36\x70\x35\x9e\x40\x0a\x96\x77\x58\xb1\xc8\xaf\xcc\x87\x32\xe6\x28\x97\x61\x9a\x48\x1e\x29\x04\xad\xa2\x08\x67\x1a\x8d\x27\x97\xde\x44\xa8\x81\x29\x89\x83\x64\xad\x18\xf6\x70\x3c\xbe\xce\xcf\xbd\x67\x10\x14\x5d\xd4\x76\x6a\xac\x10\xd6\xb9\x35\xae\x85\xf8\x7c\x0e\x69\x2f\xb4\xba\x8f\x27\xca\x4c\xfa\xb6\x91\x4d\x74\x65\xa0\xe6\xb8\xbc\xc5\x9f\x90\xf2\x23\x4a\x18\x85\x34\x79\x40\x62\x77\x7e\xbe\x18\x7f\xfc\x38\x98\xbd\xcd\x7d\x1b\xcd\x06\xa3\x1b\x2f\xfb\xea\x8d\x2e\x61\x70\x65\xcd\xa8\xaf\x06\x65\x5a\x52\xae\x7b\xfd\x27\x6d\x58\x8e\x30\x78\xd9\x9f\xf5\x7b\xca\x98\xd5\x72\x1a\xa3\xa8\xad\x2d\x93\xc1\x6d\x4f\xc0\x31\xa5\x8c\x75\x6a\xb5\x9a\x33\xba\x5c\xd3\x98\xdf\xee\xe0\x1c\x9a\x46\x73\x6e\xd6\xec\x8d\xc4\x20\xfb\x8a\xdf\x9b\x4e\xaf\xf6\x5b\x38\x39\xe9\x40\xda\xb3\xa4\x5d\x0b\x06\xdd\x2e\xca\x0b\x0c\x1e\x90\xd5\xa2\xc7\x53\xd0\x64\xb8\xa6\x82\x85\x28\xeb\x65\x9c\x3c\xb4\xda\xdd\x53\x94\x3c\xe1\x21\x8c\x22\xc1\x0f\xf4\xfc\x16\x5e\x5c\x7b\x93\xab\xf1\xe4\x23\x90\x20\x98\x9b\xe5\xc9\x09\xe6\x9b\x24\x0a\xfd\x5d\xcb\xd8\xf1\x1c\x90\x36\x73\x2b\xec\x38\x92\xab\x98\xb6\xe9\xae\x3a\x48\x24\xd7\x52\x0b\xe4\xe4\x4e\x5c\x2c\xee\x85\xe0\xdc\x73\x0f\x49\x7a\xa7\x38\x9e\x6a\xec\xa0\x91\x44\xc7\x0a\x9c\x16\xe7\x5d\xa2\x2a\x9d\xc3\x6c\x72\xe3\x29\x3c\x37\x58\xee\x2c\xf3\x81\x4a\x70\xc5\x94\x06\x72\xc1\xb8\x30\xa1\x4e\x56\x5d\x87\x2c\x41\x99\x58\xdc\x76\x71\xf2\x60\x8d\xc5\x13\x20\xf7\x49\x18\xc8\x21\xb6\x9b\x65\x4a\x02\xda\x83\x01\xb7\xee\xa8\xc2\x8e\xd1\xb4\xf0\xb0\x0a\x23\x2a\x2f\xba\x6c\x38\x1c\x05\x2d\x1c\x77\x34\xee\x99\x1f\x86\xe3\x8b\xbf\x28\xac\x1f\x8f\x86\x3f\x55\x18\x84\x06\x23\xe8\x5f\x5c\x78\xd3\x29\x78\x3f\x5e\x0c\x6f\xa6\x83\xef\x3d\x58\x27\x01\xad\x4b\x5d\x25\xc4\x95\x9b\xa1\x3f\x9b\xf5\x2f\x3e\x58\xe6\xac\xa2\x03\xa6\xf7\xea\xf4\x64\x80\xcc\x44\x2a\x4e\x62\x55\xad\x57\x6f\x4e\x86\x6d\x33\x55\x1e\xf5\x3b\x78\x44\xed\x8c\x29\xd8\xac\x43\x30\x08\xc1\x1d\xd1\x84\xfc\xb6\x21\x99\x3c\x18\x49\xf3\x7a\x78\xfd\x7e\xfa\xd7\xe1\xdb\x86\xe8\xe3\x8d\xd0\xcd\xf1\x98\xfb\x63\x30\x85\xe6\x95\x91\x18\x73\xa2\x9a\xb8\x36\x1d\xd9\x92\xad\x92\x6d\x14\x08\x7a\x4b\xb7\xb1\x0e\x4a\xf0\x93\x38\xa6\x3e\x17\x58\xb4\xe5\xc9\x9a\xe0\xe1\x47\xbb\x66\x89\xd0\xfb\x88\x15\x16\x3d\x55\x4a\xe2\x35\x32\x52\xc8\x40\x4c\x28\xa3\x24\x08\xf0\x34\x5c\x2e\x69\x2a\x78\x48\x0c\x04\x62\xfa\xa0\xb7\x15\x9a\x78\x0a\x81\xa8\xa8\x28\x72\x06\xdb\x8d\x94\x24\x65\x9b\x5f\xb6\x8c\x03\x8d\x93\xed\x72\x95\x97\x92\x50\x6e\x0d\x79\x0f\x3e\xba\x50\x92\x92\x42\x46\x89\x61\x0c\x7b\xb6\x43\x6e\x93\x7b\xda\x83\x29\xd5\x8e\x9c\xb5\x60\xb6\x42\xe8\x13\xd2\xa7\x90\xa0\xcc\xc6\x04\x61\x8a\x36\xd2\xbe\x23\x88\x53\x7e\x11\xf2\x11\x4a\xd6\x52\xf4\x72\x04\x35\x2d\x17\x32\x1a\xb3\x90\x0b\xe6\xa3\x87\xeb\xc1\x54\x9e\x1e\x86\xbb\x29\xa7\x94\xb3\xdf\x28\x59\x86\xbe\x94\xcf\xd8\x76\xb3\x49\x52\xae\xf6\xcf\xcc\x52\x94\x02\x91\x93\x7c\x6c\xe5\x58\x6a\xe5\x65\x4a\x72\x7d\x4d\xaf\x20\xd5\xe7\xd4\x3b\x75\xc4\xf8\x2d\xd3\xf0\x32\x01\x48\x5a\xcb\x42\x34\xe8\x58\xd2\x4e\x8e\x09\x34\xcb\x4c\x2c\xea\x0a\x68\xe1\x9d\x33\x1b\x7c\xf4\xa6\xb3\xfe\xc7\xeb\xd9\xff\xc9\x6c\x55\xca\xaa\x72\x39\xbe\x41\x35\x6f\xe2\x5d\x0c\xa6\x83\xf1\x48\xef\x58\x4d\x6b\xda\xb7\x4b\x2e\x4e\xf1\x37\xf2\x7e\x70\x6f\xc1\xea\x05\x4a\x03\x39\x0a\x94\x66\x8e\xb9\x58\xe0\xfc\x15\x03\x97\x19\x09\x81\xa0\x65\x1a\x75\xf0\xea\xb4\x8c\x4f\xd2\xb4\xb3\x67\x45\xa2\x4f\xd9\xca\xf4\x5d\x2a\xe9\x67\xbe\xda\x6d\x68\x2a\x4f\xa6\xf2\x0a\xcd\x0d\xd3\x51\xf2\x40\xf9\xdc\xe6\x4f\x1a\x0c\x70\x73\xda\x6a\x70\xfe\xee\x08\x03\xc3\xa1\xe1\xe5\xfa\x75\xef\x30\x0e\xe8\x67\xca\xce\xdf\xa1\x3d\x51\x5f\xea\x4a\x0e\x2d\x99\x35\x49\xe7\x6a\x04\x8d\x62\xad\xe6\x1c\xf7\x37\x9f\xab\x2d\xdb\x56\x3f\x1c\x4d\x9a\xdb\x66\x93\xc1\xc5\xcc\x20\xa6\x64\xf1\xdd\xae\x50\x4d\x25\xd1\x6b\xb5\x52\x92\xcf\xcf\xa7\x9f\x04\xb7\x52\xf6\x7d\x65\xab\xb7\xbd\x2c\x71\xa0\xed\x86\xca\x05\x82\x9a\x4a\x60\x5d\xdd\x9a\x12\xa5\xff\x66\x4b\x52\x12\x73\x71\xef\xe7\x5c\x39\x8d\xfd\xb7\x63\x15\x89\x38\x97\x9e\x2b\x7d\x56\x39\xa5\xf4\xdf\x3e\xe7\x94\xfe\xab\xe9\xa4\x72\x3b\xa1\x9b\xa6\x95\x01\xf0\x1c\xc4\xf5\x0b\xfd\xd1\xa5\x05\xd5\xc1\x34\xa3\xcc\xb2\xee\xd9\xea\xce\xe1\xd5\xb7\x27\x85\x46\xe3\xd1\x74\x36\xe9\x0b\x02\xcf\x3b\xac\xe6\xaf\xbe\x3d\x61\xf9\x53\xb1\x3d\x39\x87\x46\xda\xdc\xd1\x9d\x1c\xc4\x32\xe4\xa2\x0b\x48\xf7\x91\xff\x12\x62\x84\x4b\x5c\x1d\x83\x58\x1d\x45\xc5\x0a\x95\x25\xc3\x14\xdf\x94\x27\x3a\x67\xaf\x52\x52\x04\x7c\x3f\x1e\xf6\x67\x83\xe1\x31\x76\xaa\x12\x1e\x5d\x19\x71\x34\x9b\x0c\xde\xbf\xf7\x26\x45\x6b\xcd\xdc\xe1\xe4\x57\x42\x0c\x53\x46\xea\x92\x09\x33\xa5\x53\x48\x59\x9e\x10\xc8\x26\xe3\x1f\x1c\x04\xae\x94\x2f\x4a\x56\xfb\xb6\xd1\xa8\xf6\xca\xa3\x5b\x7e\x50\x88\x0f\xde\xe3\x97\xef\x62\x50\xc8\x84\xf2\x6d\x2a\xc4\x8e\x2c\xc6\x1c\x6e\xb7\x61\xc4\x61\x91\x26\x6b\x20\xb0\xd8\x46\x91\xf4\x80\x08\x1a\x26\xc0\xb6\x8b\x45\xf8\xb9\xd7\x50\x16\x69\xf1\xb3\xec\x25\x84\xe1\x74\x1b\xfb\xa8\x83\x0a\x31\xdc\x18\x57\xb0\x07\xf8\x78\x97\x2f\x42\xb4\x4a\x88\x6e\x38\x06\x76\x65\x28\x70\x0b\x49\x9f\x44\x0f\x64\x27\xf4\x12\xa0\x9f\x89\xcf\xa3\x1d\xfc\xe1\x8d\x8c\x71\x3f\xe6\x3a\xde\x2c\x25\x8b\x7b\x08\xf9\x6a\x2e\xa7\xcf\x48\x3e\xdb\x90\xf4\x81\xa9\xe5\xa1\x61\xdf\xb9\xb4\x45\x9b\x72\x7b\x6c\x8b\x6d\x6f\x19\x4f\xc3\x78\xd9\xca\x46\x13\x12\xc7\x1f\xde\x74\x5b\x62\xb5\xf3\x88\xc6\x4b\xbe\x6a\xc9\xb1\xdb\x5f\x9f\xb6\x31\x86\xa4\x39\x6f\x8a\xff\x51\x5f\xcf\xce\x70\x86\x32\x93\xec\xe0\xe3\xc7\x9b\xa7\x59\x65\xcb\x40\x20\xf7\x8b\x1b\x2d\x33\xcb\x66\xb8\x20\x44\x50\xc5\xca\xe5\xd6\x24\x2a\x18\x2c\x08\x03\x75\xfe\x78\xe6\x68\x77\xcc\x5c\x4d\x19\x44\xf4\x39\xc3\x77\x5b\x0e\x21\x06\xfc\x88\x6e\x19\xca\x04\x09\x65\x71\x93\x0b\xa4\xe8\xc0\x92\xc6\x34\x55\x7e\xe2\xdc\x02\x70\xb6\x91\xb9\x7a\x38\x2a\xdb\x3e\x89\x95\x69\x8d\x80\x9f\x44\x51\x88\x51\x16\xd2\xa1\x8c\x82\xf4\x96\x51\x74\xf5\x2a\xef\x3e\x58\x48\x8c\xff\x14\xa0\x31\x08\x6d\xee\xb3\xb2\x5e\x18\xe3\x2c\x8f\x54\xe0\xa3\x42\xd2\x25\xe5\x59\x77\x12\x07\xa2\x97\x9f\xc4\xf7\x34\x65\x34\xda\x75\x30\x68\x49\xf6\x76\x67\x12\xf7\x9b\x19\xac\x87\x90\xff\x01\xe7\x05\x02\x6b\xf2\x59\x2e\x4e\x35\x48\x16\x62\x42\xb1\xcf\x3f\x7c\x6b\x96\x68\x79\xd5\x31\x5c\x4b\xbb\xd7\x85\x60\x0f\xf2\xc2\xe1\xbb\x8d\x04\x5d\x00\xff\x2d\xf9\x87\xf8\x8f\xff\xee\x89\x99\xa4\x36\x6d\x45\x67\x21\x48\x43\xa6\xc9\x18\x03\xb2\xd4\x45\xce\xe0\x81\x46\x51\x47\xd0\xf3\x8a\xdc\x53\xd1\x2d\xa5\x8c\xa6\xf7\x62\xb1\x6c\x43\x7c\x6a\x24\xed\x6d\x1c\xd0\x94\xf9\x49\x4a\x1f\x43\xaa\x72\xc2\x12\x2a\x9d\x93\x74\xf9\x78\x4a\xbd\xe8\x4f\x3d\xdb\xa4\x36\x02\x9b\x3c\x9d\x49\xda\xf0\x47\x01\xeb\x82\xf5\xcc\x69\xa4\x68\x56\xff\xe6\x0d\xad\xe1\x71\xda\x23\x18\x51\xe9\x04\x7a\x97\xae\x15\xca\xb6\xc2\xbd\x30\xc3\x50\x07\x71\x80\x57\x5c\x98\x90\x8e\x18\xbd\x90\x02\x21\xd1\x2c\x03\xcb\xf0\x9e\xc6\x5a\x39\xd5\xc4\x8b\x9c\x62\xcb\x28\x2a\xaf\x2c\x11\x2c\x5f\x59\xe5\x99\x40\x2d\x66\xe9\x79\xb7\x54\x29\xc7\x8d\x6e\x77\x20\x83\x04\xe5\xf0\xe8\x59\x10\x94\xb0\xa3\x5c\xa6\xe8\xc8\x91\xa9\xa5\x58\x2b\xe5\x4f\x3a\x6c\x32\x1d\xd9\x49\xa4\xe9\x08\xfc\x56\xce\x0e\xa4\x27\x56\xe1\x98\xd1\x7a\x39\x4f\x60\x11\xa6\x4e\x3f\xe2\xf3\x2d\xca\xa4\x9a\xf6\xcc\x32\x65\x6c\x89\x7f\xc7\xb4\x79\xbd\x53\x1c\xf9\xe7\x3a\xea\xe7\xa7\x23\x88\x48\x89\xf8\x8e\xb8\xd0\xc8\x89\xaf\x39\x5a\x1a\xdf\xcc\x40\x4a\xb4\xf2\xdf\xb9\x48\x87\x76\xa3\x4c\x4d\x8d\xe9\x83\x12\x83\xb5\x92\xaa\xbe\x9c\x43\x4c\x3f\x73\xa1\xcf\x6c\x96\x73\xa1\x77\xc8\x40\xa4\xb9\x3e\xe5\x56\xb3\x54\x36\x6a\x76\x9a\x61\xd0\x6c\xb7\xcf\xce\x70\x48\x63\x5b\xdf\xe3\xf7\xd7\x81\x1d\x42\x72\x74\x82\x44\xec\x70\x04\x43\x8d\x92\x09\xa8\x75\x17\x35\xad\x1c\x68\x8a\x0d\xf6\xd3\x48\xbe\xbb\x9a\x47\x05\x09\xe0\x60\xe3\x91\x90\x9b\xaf\x86\x42\x97\xba\x1c\x0b\x49\xfe\xc3\x60\xf4\xde\x62\x5e\x83\xd1\xfb\xf2\x2d\xa2\x66\x5b\xfe\x4b\xb6\xd5\x4c\x5f\x43\xdd\xd9\x7c\xd7\xea\x9a\x64\xca\xe8\xce\x13\x57\x93\x8c\x17\xf5\xa5\x15\x4c\x59\x8a\xd6\x04\x1d\x8e\x90\xaa\xcb\x3f\xde\xf1\x55\x18\x2f\x91\xe5\xf3\x74\x27\xd8\x3c\x8d\xa8\xcf\xf1\xe6\x8c\x92\x64\xa3\x87\x5e\x71\xbe\x61\x67\xdf\x7c\xc3\x38\xf1\xef\x92\x7b\x9a\x2e\xa2\xe4\xa1\xe7\x27\xeb\x6f\xc8\x37\xa7\xff\xfe\x9f\xff\xfe\xfa\xdb\x37\xff\x9f\x92\x75\x07\x33\xc9\x7b\xaf\xc6\x37\xa3\x4b\x57\x67\x5d\xe3\x3e\xd7\x35\xf6\x24\x05\xe9\x43\xae\x13\xe5\x36\xb1\xc2\x7a\xce\xf3\xc7\xac\x16\x50\x58\x96\x63\xc0\x3c\xa8\x79\xc0\x11\xbc\xb5\x8c\x3e\x5d\xd6\x6a\xd9\x0a\x5d\xd6\x6a\xe2\xa8\xd0\x77\x63\xb3\xd8\x3b\xba\x7b\x49\xd6\x7a\x34\xf7\xc9\x45\xc6\x81\x8a\xb0\xce\x02\xc3\x14\xcb\x19\x8c\xd4\xbf\x2b\xc2\xe3\x54\xbb\xc2\x0f\x8d\x97\xe6\x49\x66\x03\x8f\x60\x4b\xd9\x31\x21\x67\xca\x62\x23\xed\x6d\x74\x72\xdb\xaa\xcf\xa8\x14\x20\x8f\x65\x50\xba\x9b\xcb\x98\x1e\x39\x8a\x54\x60\xc2\xa0\xd9\x31\xe6\xbe\x57\xd2\xcf\xa6\x86\x6f\x3f\x9e\xe5\xd9\xd1\x82\x05\xae\x97\xfd\x58\x02\xd1\x3d\x03\xd9\x0d\x5d\xa6\x72\xf0\x64\xfe\x79\xf8\x67\x74\x87\x20\x8b\xee\xca\x80\x83\x3f\x3e\x01\x0c\x95\x2c\x37\x43\xf7\xe8\xce\x62\xbb\xe2\xc3\xb9\x46\xd6\xe7\x61\xb3\xc7\x73\xd9\x8c\x0f\x09\xb6\x53\xca\x62\xdf\xa3\xe6\x66\x62\x8e\x91\xb5\x86\x0b\x48\xe2\x4c\x25\x7d\x14\x27\x2c\xb3\xb8\x3a\x0c\xf1\xd9\x98\x61\xdb\x55\x77\x14\x32\xd4\x3e\xd4\x3a\x67\x2a\x8f\x34\xba\xeb\xc9\x53\xad\xd8\x9b\xf8\xb5\x21\x83\x42\x65\x9e\x54\x23\x17\x93\x51\x36\x55\x01\x40\x7b\x06\x47\xa6\x32\x1c\x7c\x1c\xcc\xe0\xb4\x54\xf5\x79\x04\xa6\x54\x9d\x93\x44\x18\x9e\x14\x10\x06\x24\xc6\x98\x0b\x59\x69\xd9\x26\xbe\x5a\xde\xcb\x06\xa1\x7a\x70\x25\x3e\xc4\x3b\xad\x03\x88\x21\x1e\x28\x3c\x90\x58\x9a\xc4\x74\x47\x34\x9c\xdc\xa2\x9e\xed\x27\xeb\x0d\xf1\x31\x66\x6a\x93\x30\x16\xde\x46\x34\x33\xb2\xe0\xfd\x8e\x97\xfb\x26\xa5\x9c\xef\x60\x45\xc9\xfd\x4e\xc5\x7d\x32\x69\x7b\x61\x1b\x92\x86\xf1\x32\x42\xa9\x40\xeb\x20\xc5\x58\xf0\xce\xde\xc8\x50\x68\x85\xb1\x8c\x2c\xd5\xe6\x85\x76\xe7\x48\x02\xc0\x5c\xa2\x84\xcd\x17\x49\xea\x22\x7f\x31\xfc\x5c\xac\xcb\xfc\xa7\xab\xd2\x87\x31\x2f\xbd\xee\x21\x03\x3a\x5e\xce\xf2\x76\xfc\xcc\xe7\xc5\xcf\x8e\x32\x27\x88\xc6\x8e\x23\xea\x76\x05\xcc\x82\x64\x8b\xa6\x94\x15\xf5\xef\x10\x64\x61\xbc\xc4\x58\x32\xd5\x66\x11\x32\xae\xb2\xe0\x18\x17\x8a\xa4\x68\x78\x66\xf1\x5f\xb3\xb9\x4d\xc2\x0c\xb7\x6c\x54\xdc\xab\x25\x81\xf9\xd1\xdd\x26\xe3\x9f\xa6\x5f\x74\xb7\xe9\xb9\x22\x6c\x09\x60\xed\x16\xa6\x27\xfa\x0e\xee\x36\x16\xcd\xe6\x7b\x69\x98\x67\x57\x81\x5e\x8c\x62\xd8\x83\x2b\xc9\xa9\x73\xa5\x33\xa4\x61\x3e\x6b\x0b\xf9\x98\x20\x45\xf4\x35\x04\x76\x97\xfc\x1c\xf3\xba\xe8\xd7\x3a\xb0\x59\xcb\x4b\x65\xf7\xd5\x77\x36\x46\x66\x10\xe9\x01\xb6\x03\x25\xb4\xf5\xec\x01\xb3\x0e\x21\x8c\x81\x2e\x16\xe2\x62\xf6\x57\x24\x5e\xea\x48\x12\x99\xe8\x64\xe3\x00\xc6\x28\xae\x31\xce\xda\x64\x33\xba\x18\x77\x4b\x23\x71\x81\x30\x93\xe4\x18\xc6\xc0\x69\xba\x66\x32\x0f\xc5\x88\x0d\x65\xae\xab\xa6\x15\x31\x92\x73\x8b\x0e\x46\x30\xfd\xd0\x9f\x78\x3a\xba\x26\x8b\x15\xf9\x38\xbe\xf4\x9a\x1d\x67\xf7\x6d\xbd\x7d\x46\xfd\x24\x0e\x14\x4a\xcb\x88\x1d\x13\xaa\xf3\xcf\x80\xb3\x7b\x91\xf6\x59\x11\x76\x70\x95\x31\xa0\x73\xc8\xdc\xa2\xce\x38\xee\x49\x9f\x9d\xc3\x29\x96\x58\x38\xed\x4a\x4f\x6c\x20\x6f\x02\xd6\x01\xdd\x1d\x51\x0f\x23\x95\x69\x44\xd7\x34\xe6\x72\x62\xdb\x50\x98\x3b\x06\xe4\x55\xe4\x33\x26\xb6\xc0\xd7\x70\x6a\x7e\x70\xce\xe5\xb8\xb3\x29\x9e\xcf\xa3\xce\x48\xc2\xdb\x81\x81\x1b\x73\xe8\x82\x67\x30\x95\x99\x2b\x05\x1b\x6a\x01\x8a\x6f\x10\x8a\x0a\x42\x70\xaa\x8d\xca\x32\x55\x48\x83\xd2\xb6\x7a\xe2\xb1\x15\x8e\x50\x7b\xf9\x6b\xde\xef\xfa\xb8\xb5\xdf\xbc\x8e\x42\x67\x96\x6d\x56\xa3\xc2\xa5\x0a\x39\x4a\xea\x5f\xce\x5e\x0b\x2a\x91\x19\xa5\x4a\x35\xb2\xa9\xb3\x0a\xdd\x47\xe3\x59\x29\xca\x63\x79\xa3\xe6\x05\x6a\xfc\x42\x27\x59\x84\xd2\xdb\x41\x1f\xcc\x20\xcd\xfa\x50\x54\xe0\x53\xce\x5e\x21\x14\x38\x19\x42\x6f\x6b\xf4\x55\xed\x4b\xfa\x36\x4a\x69\xf4\x99\x35\x82\x32\x71\xa4\xcc\xb0\x6d\x49\x7a\xa5\xf6\x12\xc5\x47\x89\xe2\xaa\xca\x63\xa2\xdc\x9b\x52\xea\xd3\x7a\x03\xea\x0c\x8f\x90\x98\x4c\x78\x86\x23\x13\x69\x71\xde\xfa\x90\x29\x0e\xb6\x0e\x20\x05\x9b\x32\x4b\xc5\x5e\xc6\x6e\x27\x71\x36\x32\xdc\x36\x7d\xcc\x6a\x3a\xd9\x3a\x9e\xa8\xe5\xeb\x48\x66\xa5\x85\x56\x69\x89\x65\xf7\x55\xbe\xef\x7e\xf5\x14\xa2\x92\x5b\x4a\xde\x31\x06\xc6\xfd\xd1\xa5\xf9\x49\x46\x49\x9d\x5b\x10\xff\xcd\x35\xd8\x02\x32\xd8\xc8\x5a\xa2\x96\x3c\xa4\x64\xb3\x11\x88\x99\x26\xdb\x38\x80\x5f\x58\x12\xdf\xce\x29\xf1\x57\x73\xcc\x65\xe4\x09\x9a\x0a\x81\xc0\x2d\xe5\x02\x81\xd3\xe4\x61\x4e\x19\x0f\xd7\x84\xd3\x46\xb7\x2b\x78\xad\x0a\x5c\x69\x9d\xbe\x46\x8e\x71\xfa\xfa\x75\xfb\x08\xec\x95\x0b\xcd\xcd\xdb\xfa\x85\xc9\xa5\x48\x64\x15\x20\xcf\x50\x37\x4b\x3c\x6e\x37\x8c\xb0\x3f\xf5\x66\xe3\x2b\x48\xa9\x9f\xa4\x41\x03\x6c\xed\xae\x51\xe5\xd9\xd2\x01\x4a\x93\xf1\x0f\x53\x38\x7d\x6d\x48\x41\xf0\x91\x13\xe3\xa7\x2f\xae\xac\xdd\xee\x7d\x65\xb5\x3c\xe2\x70\xaa\xf6\x9a\xc4\xb7\xd9\xe1\x58\x2e\xb2\xdc\xe1\x6c\xe3\x98\xb2\xec\x4c\xb2\x13\x01\x7d\x22\x4f\x3b\x04\x39\x7e\xcb\x8e\x3a\x22\xf1\x0e\xff\x51\x80\x34\x89\x77\x46\x38\x79\x3e\x68\x17\x57\xd0\x7e\x0a\xa4\xd5\x70\x66\x13\x45\x18\x57\x46\xb6\xec\xf9\x2b\xeb\x03\xd7\xb2\x86\x5a\xff\x7a\xc0\xa0\x66\x9f\x83\xf3\x1c\x79\x07\x14\xb4\xa0\x79\xb8\x98\xcb\x42\x84\xd5\x1a\xb4\xab\x32\xcb\x73\x6b\x69\xaf\xde\x1e\x8f\x9e\x6b\x31\xca\x1a\x66\xde\xed\x43\x7e\x16\x9d\x9d\x52\x94\x26\xf7\x6c\xc4\x91\xfe\x5f\x28\x13\x71\x1f\x1c\x5d\x3e\x6a\x47\xbe\x5c\xbb\x45\xf4\x90\x4a\xa9\xbc\xde\x71\x6b\x89\xed\x2d\x29\xfa\xb9\x8d\x9d\x06\x63\x98\xa4\xec\x63\xfb\x9f\x65\xbf\x70\x01\x21\x7f\xa2\xaf\xe5\x90\xea\xbc\xc7\xd8\x72\xc0\xe3\x2b\x3f\x2a\xd3\xd3\x4e\x5c\x43\x3a\x23\xbd\x3e\xe6\x74\x64\x9a\xfb\xd3\x10\x68\xcf\xf6\xf2\xea\x63\xa9\xd1\xb1\x83\x09\xf3\x07\x4c\x8f\x8e\x2b\xee\x88\x59\x5f\xde\x1a\x59\x3c\xd3\xca\xeb\x7f\x53\x8d\xb5\xfb\xed\x93\x4f\x37\x69\x0b\x91\xfa\x80\x65\xaf\x84\x43\x0d\x46\x33\xc4\xa5\x13\x65\xab\x40\x29\x5b\x95\x28\x52\x51\x18\x49\x4a\x81\x7e\xde\xd0\x18\xf3\x90\xb4\x2c\x95\x45\x78\x2c\x2a\x65\xee\x12\x81\xf1\x37\x30\x70\x54\xc0\xa6\xa6\x71\xae\xaa\xb7\x32\xaa\xbb\x08\x9e\xdf\x5d\x0d\x65\xa7\xe6\x0a\x3b\x87\x16\xa3\x72\x1f\x35\xde\xbf\x98\x05\x1e\xd1\xea\x80\xd0\xfb\x9e\x5a\x7a\xd7\x5c\x55\x17\x21\x96\xcb\x15\x36\x24\x4c\x9f\x88\xe2\x61\xe0\x38\x6d\xf6\x68\x64\xfb\x31\x5c\x5a\x82\x94\x07\x10\x37\x43\xef\x69\xcc\x8d\x8f\x5e\x86\x56\xde\xd2\x30\x5e\x62\x11\x34\xd8\x6a\xff\xa0\x10\x7f\x64\x4e\x74\x18\xed\xca\x8e\xff\x90\xfe\xf3\x54\xed\xe7\xd1\x08\x58\x50\x65\x6d\x98\xfd\x26\x98\x74\x58\x73\xc2\xdb\xda\x8e\x38\xcd\x8c\xb9\x84\x69\xab\x9e\x3c\x1c\x81\x6b\x28\xe5\x37\xba\xdd\xd7\x0c\x52\xba\x49\x29\x13\x67\x98\x55\x0f\xd2\xf9\xee\x8c\x72\x68\x3d\x50\x08\x12\xc1\x96\xb6\x8c\xa2\x39\xac\xd1\xed\xb2\x50\x9c\x75\x18\x73\x39\xae\x91\x01\x4c\xd6\x12\x6f\xdb\x15\x8d\xd4\x4f\xd4\xad\x9a\x63\xd2\x14\xe5\x68\x2a\xf3\x3e\x64\xd2\x58\x81\xd8\x93\xc4\xb6\x6b\xda\x8f\x42\x4c\x54\x8f\x03\x99\x72\xe6\xaf\x30\x8d\x92\xd6\x8b\xd0\xcc\x27\x31\x18\xb5\xae\xdd\x30\xda\x44\x75\x01\x42\x45\x01\x3f\x0c\x66\x1f\x20\x0c\x3e\xcf\xef\x49\x24\x3e\xb7\xf6\x19\x41\xbb\x5d\x95\xea\x45\xa2\x48\x85\x12\xeb\x30\x76\x9e\x68\xb1\x4a\x08\x26\x02\x8f\x8d\x0b\x2d\x3f\x04\xfa\xd9\xf1\x7e\x08\x03\xa6\x2e\x8c\x9d\x3a\x12\xbc\x29\xa0\x55\x51\x8f\xa8\xed\x0c\xe5\x27\x24\xa2\xcc\xa7\x2d\xc1\xb2\x37\x49\x21\x19\xf9\x08\x8e\xf6\x0b\xeb\xbe\x7b\x67\xe7\xdd\x50\x64\xaa\x6d\x01\x99\x4e\xc5\xa4\xbd\x62\x1c\x48\x3d\xcc\xc7\xb1\xc5\x14\xd2\xac\xd3\x16\xc4\xe7\x98\x94\xa1\x4a\x93\x6d\x03\x75\x67\x1c\x7a\x57\x33\xf8\xaf\xf1\xa0\x5c\x43\x83\x28\xb7\x3e\x41\xa6\xad\x48\x5d\x6f\xb8\x0c\x79\xe5\xf5\x34\x73\xd1\x6b\x6a\xd4\x9f\xa4\xda\xbc\x6d\xe6\xcc\x7f\x29\x46\xd8\x96\x5d\xde\xb9\x33\x71\x98\xa1\xdb\xcf\xda\x4f\xbe\x45\xb6\x93\x6e\x37\xa6\x34\x40\x44\x95\x15\x2b\x6e\x77\x52\x08\xca\x78\x7e\x40\x49\xa0\x2a\xf5\x2c\x4a\x2f\xdc\x30\x30\x19\x9f\x98\x61\x2d\xcb\x05\x99\x8d\xea\x7a\x04\x91\x59\x49\xdb\x76\xbc\xf5\x27\x93\xfe\x4f\x79\xfa\xca\x10\x4a\x11\xa1\x38\x81\x0e\xbc\x6e\x57\x3b\x19\x34\x57\x54\x86\xdf\x32\x68\x02\x9c\x96\xa7\xad\xb5\x74\x54\x1f\xf9\x2c\x26\x6c\x4b\x7c\x53\x53\xbb\xc7\xde\x86\x65\x05\x1a\x68\x76\x21\xb0\x49\xaf\x3a\x0c\x3e\x0b\x91\x49\x0e\xd1\x3e\x3b\xab\xe0\x3c\x7b\x2e\x14\x2b\xed\xb8\x06\xa7\x43\x36\x37\x98\x42\x53\x06\xf4\x73\x71\x45\xe0\x57\x71\xa0\xc4\x0e\x02\x28\xcb\x1a\xae\x39\x41\x65\xfa\xd1\x31\x5c\xd9\xc6\x6a\x19\x5a\x62\xe8\x86\xe1\xfd\xf7\xf3\x27\xfd\x09\x89\x4f\x7f\xfc\x17\x17\x97\x1b\xa8\xcf\xc5\x2d\xd8\xb8\xc2\xf3\xdd\xfd\x0b\xb2\x73\x39\x38\x4e\x52\xc9\xd0\xd1\x2c\x27\xfe\xd5\x72\x6c\x70\x02\x05\xda\x1d\xb8\x19\x8d\xbc\xe9\xac\x65\xe3\x40\xbb\x2d\x8e\xf1\xee\xbe\x60\xff\x2f\x52\xe3\xf1\x9c\x5f\xae\x38\xc7\xfa\xcd\xf2\xff\x11\x78\x7f\xc5\x49\x1e\xbc\x03\xe4\xce\xaa\x2f\x01\xc3\xa2\xad\x86\xff\xe2\xd1\x2f\xc3\xa3\x33\x01\x5f\x30\x38\xcd\xd3\x72\x2c\xdb\x4a\x43\xe9\x28\x99\x3e\x59\xa0\xe0\xde\x91\x99\x60\xfa\x93\x66\x8d\xcf\xc1\xdc\x25\x17\xce\xad\xac\xcc\xd7\xa8\x12\xee\x58\x56\xf7\x54\x2d\xc3\x32\xd7\x28\x98\x69\x03\xa3\x31\x84\x18\x69\xe3\x96\x5a\x65\xba\x73\x2c\xb1\xee\x7d\x22\xe8\x4c\xaa\x68\x72\x07\xfb\xb3\x99\x8d\x5b\x27\xbb\x5f\x94\x67\x27\xbb\x5b\xb2\xbb\x23\x77\x43\xe0\x08\x73\xb2\x5c\x4a\x76\xd1\xee\x38\x5f\x2c\x16\x61\xe1\x7c\xd1\xc1\xc1\xda\x46\xfd\x57\x6d\x06\xa3\x91\x37\xd9\xc7\xb1\x14\x8b\xc2\xc0\x70\xdd\xb7\x5d\xc0\xc5\x72\x0b\xf4\x21\xbc\xcc\xc3\xaf\x02\x70\x05\xf4\x34\x09\xef\x98\xb2\x27\x6b\xcc\xc9\x78\x92\x33\xd0\x45\x6d\x0d\x6e\x90\x58\x56\x5e\x13\x1f\x25\x9e\xd4\xc6\xce\xba\xeb\x2b\xcb\xf4\xd2\x38\x6a\xd4\x60\x85\x9d\x2a\x72\x50\xa7\x49\x62\x25\x13\x1b\x63\x6b\xa2\x1e\x0e\x79\x00\xe1\x32\x51\x45\x2e\xa0\x12\xb9\xa4\x4a\x23\x5f\x7e\x6a\x49\x2a\x17\x58\x59\x40\xa8\xc3\xb8\xff\x5c\x98\x51\x6f\x7b\x07\xd0\x82\xc0\x7f\x4d\xc7\xa3\xef\x40\x6e\xac\xf6\xa9\xcb\xb9\x8f\x39\xeb\x4b\x59\x8c\x0f\x25\x37\x55\x1a\x0a\x23\x1e\x64\x70\x9c\x5b\x2b\xaf\xe8\xc7\x38\x3e\x65\x24\x7f\x7b\x39\x95\x16\x3a\xf9\xcf\x96\xc3\x22\x2b\x97\x9c\xf1\x07\x09\xae\x2a\x9e\x95\xdd\xd1\x37\x33\xab\xf0\xc9\x77\x83\xf7\xb9\x48\x8a\xdc\xc3\x01\x59\x53\x59\x23\x22\x8b\x21\x75\x7f\xcd\xb2\x4d\xf2\x69\x25\x59\x41\xb2\xb6\x95\x4b\xe2\x86\x01\x82\x5d\xc2\xa2\x24\x2a\xc9\xa9\x60\x31\xb0\x93\xdf\x30\xf8\x5f\xa1\x6c\x4e\xac\x38\x39\xed\xc0\xc9\x9b\x0e\x9c\x7c\x9b\x6d\xbe\x3a\x6a\x03\x9c\xc8\x0d\xc5\x57\x4f\x4e\x3a\x45\xe8\x5b\xf1\x97\x66\x6f\xd2\x58\x88\x75\xce\x1d\xb8\x14\xd7\x29\xcf\xa3\x10\x5a\x91\x41\x52\xd9\xbf\xe2\x6d\x14\x99\x56\x55\xd5\x3e\x8c\x2f\xca\x95\x87\x4b\xa1\x66\x9a\xa8\xc0\x76\x49\x64\xe7\x70\x72\xfa\xe8\xad\x3e\x62\x43\x2f\x9d\xfd\xa0\x48\x0a\xbd\x7c\x4e\x86\x4c\x35\x03\xd8\xa3\x7d\x96\x33\x16\xb3\x35\xc9\xd8\xf2\x56\x41\x49\x53\x8a\x4b\x67\xe4\x54\xa4\xa4\x42\x7d\x6d\xf1\xc9\xe1\x01\x56\xc4\x76\xb7\x3b\xa5\x14\x74\x1e\x97\x2c\x29\xa4\xdc\x1c\x36\xff\xc6\xdb\x29\x4e\xd0\xa8\x7b\x9b\x6c\xb9\x8e\xea\xb6\x3c\x84\x6b\x1e\xcb\xa4\x43\x1e\x5b\x69\x87\x8f\x8a\x54\xc6\xfd\x3b\x76\xa4\xb6\x18\xb6\x91\x8b\x4f\xce\x27\x67\x36\x6a\x57\x8a\x0b\x63\x5d\x29\x4e\x86\x02\x67\x55\xe2\xf2\x44\xf1\xeb\x96\xa6\x3b\x4b\x5d\xbf\x98\x79\x65\xaa\x7a\xa5\xd4\x7a\x72\xda\x2e\xea\x2b\x25\x3e\x86\x42\x31\x1d\xc2\xd4\xd1\x36\x4a\x88\x4b\x2b\x1b\x5f\xc9\x31\x7c\xae\x48\xaa\xcc\xaf\xb0\x1f\xa3\x5f\xbd\x39\x19\x76\xe0\xd5\xa9\xf8\xff\x25\xa3\xba\x7e\x05\x41\xcf\x12\x20\x36\xe0\x2d\x66\x84\xcd\x2d\x22\x6e\x14\xc8\xdc\xa9\x47\x63\x7d\x95\xef\xa3\xec\x23\xd9\x43\x32\x41\x46\x3e\x96\x9d\x29\xb5\xc4\x2c\x7d\xf6\x81\xbc\x74\x75\x25\x94\x35\xe1\xfe\x0a\x9d\x15\x4a\x1c\x58\x28\x38\xd7\x96\x08\xf2\x33\x3f\xc6\x00\x65\x93\xc6\x5e\x4a\x7c\xb4\x65\xaa\x10\x9e\x90\x65\x42\xd5\xbb\xb8\xab\x59\x88\x2e\x7d\xb1\x26\x77\x34\x8b\xe6\x2f\x24\xc1\xc8\x78\x91\x97\x61\x19\x4e\x91\xfd\x9a\xbc\xa2\xdb\xc5\x2a\xbf\x26\x6f\x47\xd5\xd2\xb9\x95\x85\x3a\x69\xa0\xcb\x4f\x67\x19\x6d\xa6\xd4\x9f\x4c\x61\x88\x03\xf3\xa2\x92\xea\xa1\x2b\x7f\x16\xcb\x1a\xfb\x7e\x92\x06\x28\xf1\x25\x6e\x65\xfb\x03\x5c\x07\xaa\x99\x9a\xcc\x68\x10\xcc\x22\xab\x7c\x29\xf9\xd9\xc7\xf1\xa5\x74\x4f\x96\x90\x6b\xfb\xa5\x18\x9d\x16\x8b\xfe\x97\x32\x3c\xc7\x76\x99\x91\xa4\x4b\x8b\xfb\x19\xe2\x8b\x44\xbe\xee\xe7\x26\xf5\xac\x2a\xb2\x0e\xd6\x35\x49\xc9\x9a\x72\x9a\xc2\x9a\xc4\xe1\x66\x2b\x1f\x82\xb2\xde\x48\x3d\x2e\x3e\x8f\xd1\x7c\xd9\xbe\x79\x12\xbb\x21\x44\x45\x5e\x87\x39\xd1\xfa\x6d\x0f\x5d\x8e\x37\x13\x92\xee\x93\x30\xc8\x95\xac\xc1\xf2\x9e\x60\xfa\xc8\xb2\xb3\x24\x40\x5a\x3c\x7d\x25\xd8\xbd\xac\x44\x1d\x53\xc6\x74\xd9\x77\xd3\x5a\x17\xdc\x52\xf5\x88\x4d\x05\xf6\x28\x5c\xc6\x59\x3d\x2e\x35\x8f\xd5\x88\x71\xb2\x5c\xd2\x54\xd9\x8e\x74\xd5\x61\x01\xad\x5f\x92\x5b\xf5\x42\x8b\x42\xbe\x0c\x0c\x4e\xb5\x43\xab\x66\x4f\x45\x65\xc5\x56\x21\x35\xec\xc9\x9c\xb3\xdd\x3e\x3b\x4b\xe9\xd2\x8f\x88\x5d\x25\xda\x81\xf9\x57\xd0\x3a\xed\xbd\xfe\xba\xd5\xd2\xf5\xbb\xbf\x7a\xdd\x7b\x7d\xda\xee\xbe\xee\xbd\x7e\xfd\xef\xed\x76\xbb\xfc\x31\x84\x0c\x77\xeb\x5a\x30\x58\x75\x71\xc7\xdc\x0b\x2f\x45\x2c\x50\x11\x76\x96\xb9\xac\xe6\x93\x27\xee\x53\x66\x25\x4f\x9e\xb8\x1f\xaa\x4a\x92\xe0\x83\x42\x42\x13\xd4\x05\x9c\xbd\x99\x89\x04\xc1\x74\xb3\x4b\xef\x52\x1a\xe5\xf6\x56\x9a\x3c\x8e\x40\xf2\x8b\x6b\x17\x58\x6e\x49\x05\x3d\xc9\x66\xcb\xe1\x9c\x4b\x40\x4c\xc5\x6a\x1f\x6f\x64\xde\x73\x9e\xd9\x01\x0a\x91\x8d\xa9\x40\x24\x6c\x94\x51\xe2\xc2\x49\xfd\x65\xd0\x42\x69\x42\x10\xb1\xb8\x86\x63\xfa\xd0\xc6\x7a\x60\x42\x37\xc1\x47\x18\x36\x51\xe8\x87\x1c\x92\x7b\x9a\xa6\x61\x40\x9b\xc7\x61\x9e\xae\xd7\xeb\x2e\xb4\xc8\x8e\x8e\x42\x45\x9b\x27\x6d\x19\x3d\x14\x96\x69\xa7\x56\xca\x14\x67\x99\xd4\x8c\x45\x94\x12\x8c\x8a\xf9\x46\xca\x1b\xdf\x20\x64\x64\x71\x62\xa1\xdc\x2c\x29\xd3\x85\xf1\x2d\x07\x3a\x96\x6a\x96\xf2\xc9\x76\x13\x10\x4e\x05\xfb\xc2\x48\x79\x94\xcb\xdc\xdf\x7a\x7b\xf0\xf2\x10\x47\xa9\x04\x60\xaf\x24\x59\xe9\x60\x8d\xf3\x8a\x77\x9d\xce\xb3\xa0\xd8\xac\xd8\xf9\x60\x64\xee\xf4\x30\xa8\xe4\x86\xfb\x82\x99\xeb\xad\x7d\x7f\x85\xd8\x27\xd2\x6d\x29\xdd\xed\xe5\xa9\x75\x68\xaf\x1c\xa3\x25\x16\x17\x09\x90\x94\x92\x1f\xe0\x63\x2a\x8b\xd0\xd7\x1e\xa0\x16\x16\xb8\xd3\x34\xc6\xec\x37\xb1\xda\x47\x50\x5c\x4a\xeb\xd3\xdc\x21\xd2\x7a\x3c\x3e\xe9\xf8\x66\xbb\x76\xfe\x13\xb1\xe9\xc5\x70\x26\x93\xca\x8b\x0b\xaa\x2a\x89\xfc\x02\x88\xb5\xef\xe0\xe4\x61\x49\x25\x1c\x0b\xac\x57\x31\xf5\x02\x56\x61\xd5\x4b\x9d\x39\xae\x76\x53\x0f\x9b\x4a\xce\xa5\xf0\x76\x52\x35\x42\x39\xaf\x45\xb9\xf5\x0e\xc7\xfd\xa1\x37\xbd\xf0\x5a\xeb\x5e\x7e\xbc\x42\xad\x9c\xfd\x0f\x37\x1d\xba\x95\x9d\x7a\x5b\xcf\xc2\xd1\xf6\xc0\xc2\xe5\x69\xb5\x15\xaa\x1a\x0f\x70\x55\x45\xa2\x3e\x5f\x2a\x4a\x61\x62\xb7\x2c\xcd\x11\xef\x8a\x15\xc4\x93\xc2\xd0\x95\x0f\x04\xbe\x80\xc8\x59\xf2\x6a\x5e\xfe\xd3\x73\x88\x9d\x2f\x24\xd9\x15\x40\x57\x2e\xdb\x99\x66\xa0\x00\xfa\x45\xa4\xbb\x83\xac\x41\xaa\x9b\x47\x9e\xfe\xff\x42\x29\x6f\x2f\x5f\xa9\x2b\xe7\x15\xc0\x7c\x5e\x0a\xfd\x17\x14\xf8\xf6\xb3\xc7\x17\x15\xcb\x4a\xb9\x59\xb9\x60\x56\x4e\x3b\xbf\x89\x68\x76\xc4\x5d\xfa\x48\xe1\xac\x04\x09\x30\xd6\xff\x45\xc5\xb2\x97\x14\x8a\xca\xaf\xa9\xbc\x58\x54\xf3\x4c\xab\x04\xa3\x6e\x37\x48\x93\x8d\x36\x52\x61\x7a\x85\x66\xa4\xb8\x7f\x19\xe9\x12\xd0\x88\xaa\x04\x4a\xb2\xd9\xa4\xc9\x26\x0d\x91\x3d\xa0\x7d\xf0\x98\x6c\x49\x31\x99\x23\xf4\xb1\x12\xce\x99\x44\x01\x4d\xe7\x7c\x45\x62\xfb\xf9\x14\x37\xad\x47\x23\x09\x54\xbc\xdf\x52\x5a\x6c\x0a\xf0\x75\x10\xea\x4b\x7b\x99\x3d\xb8\xfc\x0d\xad\x68\x41\xb8\x96\xef\xc3\x9b\xd7\x5f\x20\x17\xd1\x10\xc6\xfc\xe7\x4f\x76\xe9\xaa\xf2\x42\x4b\xf6\xab\x1d\xf6\x62\x2a\xc5\xb8\x63\xcc\x6d\x2e\x4b\xb1\x20\xf6\x75\xf1\xa5\xb1\x6c\x35\xd9\xe6\x55\xff\x2c\x79\x0b\x21\x62\xf6\x0e\x2a\x8b\xab\xf8\x8b\x3d\x6d\xe0\x94\x7f\x50\x5b\x2d\x00\x31\xdb\xef\xdc\x7a\xee\x6d\xae\x4a\x40\xf7\xb2\x87\x60\x60\xa5\x06\x33\x81\x63\xa5\x1d\xb2\x45\xe2\x0b\xef\xad\xc0\x1a\x42\x06\x7e\xac\x7a\xfa\xc9\x0b\x49\xf8\xab\x9e\x2c\x22\x65\x2a\x0b\x59\xf6\x50\xcc\x33\x80\x95\xed\x44\x3a\x2f\x1e\x97\x89\x23\x15\x5b\x86\xfe\xf4\xc2\x16\x55\x1d\x58\x12\xe0\xf8\x66\xa4\x75\x24\x2d\x53\x27\xaa\x5d\x76\x65\xdf\xc5\xc9\x03\x56\xe2\x97\x83\xa0\x73\x11\xfc\x2d\xef\x26\x8b\x85\x79\xc6\x2e\x8c\x97\xcc\xbc\x54\x27\xa8\x68\xa3\xee\x6f\x75\x14\x0e\xa4\x42\xf5\xea\x44\x8f\x27\xf2\x3b\x27\xeb\x4d\x2b\x25\xf1\x92\xce\x69\x1c\x58\x31\x14\xd9\x2a\x0f\x9c\x92\xd4\xbe\xfc\x5a\x07\x24\xb5\xb9\xec\xb9\x64\xf0\x7d\x3c\x28\x5f\xc6\xfa\xf9\xbe\x6a\x11\x9a\x95\xd4\x3c\xf0\x39\x8b\x42\x9f\x42\xc0\xe4\xb9\x33\x33\x5e\xae\x85\x19\xb9\xdb\x35\x9b\x16\x82\x4f\xf6\x9c\x1e\x53\xcf\xed\x89\x8f\xf7\x34\x95\x15\x42\xe1\x8f\xce\xa9\x65\xaf\xaf\x46\x2c\xc9\xfa\xda\x88\x15\xb0\x9e\xc3\x2e\xce\x4b\x58\x88\x40\xaf\x80\xf5\xb2\x85\xfc\xf1\xbc\xfa\xb4\xb6\x71\xf8\x79\xbe\x0e\xfd\x34\x91\x55\xc3\x58\x2b\x5b\x51\xdb\xc5\xc4\x6c\xc0\x4b\xaf\x14\x1f\x07\x57\xf6\x76\x4a\x2b\x41\x29\x67\x3a\x9a\xc3\x4a\xca\x10\x75\xbb\xfe\x8a\x60\xdd\x62\x92\x3d\x4b\x40\xe5\xab\x89\xea\xe9\xc1\x95\xc4\x46\xd8\x24\xe2\xa0\x11\x41\xe5\xb3\x04\x98\x3a\xcc\x38\xb0\x70\x1d\x46\x24\x35\xfe\x14\xfd\x38\xc5\x83\x18\x2d\x64\x1a\x97\xb1\x3a\xab\xcc\xcd\x5c\x84\x11\x97\xe9\x3a\x24\x8a\xcc\x33\xba\xa2\x39\x8e\x7c\x4b\x69\xec\x50\x40\xb7\x7b\xbb\xe5\x26\xed\x2f\x6e\xca\x6a\x6f\xf8\x0c\x1a\x8e\x27\x97\x2b\x1f\x66\x8d\x5d\x47\xf3\xce\xe9\x21\x1d\xba\x8c\xf2\xb2\x18\x27\xdb\x27\x9a\x05\x2e\x0d\x66\x1f\x60\x93\xe0\x0d\x4c\xa2\x68\x37\xc7\xfb\x4d\xbf\xf8\x37\xcd\x05\xbf\x6b\xae\x89\xea\x89\xcf\x73\x01\x4c\xfa\x2f\xef\xe9\x44\x17\xa7\xd3\x42\xe2\x1e\xb2\xe5\x3f\xe2\x03\x45\xce\xaf\xde\x8f\x17\xde\xf5\xec\xa5\x27\x7e\x67\x3d\x8d\xa4\x57\xf2\xad\xb5\x92\x76\x07\xfc\x24\x5e\x84\xe9\x9a\x06\xb5\xa0\xb2\x67\x4d\x15\x00\x2e\x59\xda\x68\x3c\x03\xef\xc7\xc1\x74\x96\x9f\xc4\x9e\xe9\xb4\xf8\x0b\x4e\x53\xf4\x8d\xcb\x07\xd3\xb2\xd8\x03\xf7\x4f\xb1\x80\xac\x49\xcf\x0e\x3e\xac\x58\xb4\xd5\xc6\x80\xee\xdd\xb9\x0b\x3b\xf3\x27\xb5\x40\xc9\x7a\xf1\x01\xe9\x00\xbe\x96\x09\xf9\x51\x78\x47\xa3\x9d\x7c\x08\x21\x0e\xb0\x10\xa9\x64\x61\x8c\x93\x54\x2a\xbf\x1c\x28\x49\xa3\x10\x4b\xdc\x84\x6b\x5a\x1c\xdd\x70\x12\x5c\x84\xbe\xd3\x9c\x3f\xcb\x99\x6d\xfe\xda\xf6\x19\x4b\xc1\x30\xa8\x38\xdc\x4b\x6f\xe8\x09\x0a\x12\x52\x65\x85\xef\xbe\x91\x87\xa7\xab\x99\x65\xd0\x92\x6e\xf6\x32\x94\xb2\x33\x24\xec\x78\xc9\x4e\x3e\x5f\xaf\x10\x8f\x29\x93\x3f\xd4\x7f\x5c\x0e\xa6\xb3\xc1\x28\x57\x35\x87\xb5\x81\xb0\x7c\x64\xbb\xc2\x17\x77\xef\x6d\x37\x68\xc2\x96\x20\x6c\x89\xb6\x63\xc9\x60\x6d\x79\x07\x17\x63\x16\x2d\x9d\x1c\x1f\xd3\x61\x74\x43\x52\x21\x70\xe3\xe8\xd2\xae\x91\x08\x51\xe3\x62\xe6\x59\xb9\xc7\x59\x0a\xc2\xbf\x31\x4a\xff\x4d\x0d\x65\x05\x94\xa4\xc9\x03\xd3\xcb\x96\xaf\x48\x8a\xdd\xa9\x0f\xbd\x32\xae\x57\x88\xed\xc8\x9d\x80\x8a\xb2\xa8\x22\xea\x02\xe0\x0c\xf0\x14\x90\x4f\x4e\x33\x00\xeb\x7c\x2e\xfb\xb9\x32\x78\x7e\xd2\x76\x22\x47\x14\x7e\xed\x27\x71\xa7\x51\x4f\x6d\xf9\xf7\xbf\x97\xe8\xf3\xb3\xfc\xef\x9e\x5e\xfb\xa7\xa3\xa9\xc8\xfc\x4b\x91\xcb\xfe\xfa\x01\x50\x41\x29\x5f\x95\x52\x88\x42\xe2\xb7\xd5\xc8\xd9\xae\x8a\x9c\xd5\x75\x04\x71\x1c\xa5\xab\x65\x42\xf2\xf9\x3b\x17\xc3\x2d\x01\xfb\xfc\x9d\x2b\x60\xdb\xe8\x7f\xfe\xce\x92\x67\xde\x66\xe1\x2b\x4a\x7b\xae\x17\xc3\xd2\xe8\x76\xc7\x3a\xa3\x57\x86\x12\xc8\xf7\x9c\x98\xd4\x22\xd6\x24\xc5\xf8\xf8\x65\x78\x4f\x19\x6c\x19\x30\x2c\x18\x84\x3d\xc2\x58\x90\x13\x27\x5c\x96\xb9\x44\xdd\x36\x5c\x2c\x68\x4a\x63\xde\xe8\x76\x4d\x64\x15\xc6\x79\x9a\x5f\xb2\x1e\xec\x51\x9e\x04\x26\xf6\xcb\xe7\x82\x3c\xe7\x19\x38\x5b\x56\xc2\x27\x16\x01\x2b\xf7\xb5\x67\x66\x61\xb0\x1f\xe9\xa8\xf3\xbc\x7a\xe1\xf1\xf2\x52\x02\xd2\xb4\x53\x4c\x6a\x63\xab\xe4\x41\x1f\x7d\xa6\x63\x9d\xbf\x33\xaf\x0d\x0c\xe4\x3b\x9d\xb9\xe3\x5e\x3b\x8f\x76\x16\xe9\x41\xff\xd9\x68\x31\x1a\xff\xd0\x6a\x43\xf7\x28\x6f\x8c\x6b\x64\xb3\x33\xbf\x15\x56\xc8\x33\x47\xf1\xdd\xae\xf4\xc1\x49\x7a\x4f\x9c\x02\xa2\xc5\x97\xe5\xcb\xbd\x0f\x8f\xf2\x37\x54\x9d\x7e\x59\xbe\x87\x2a\x20\x94\x3d\x97\x2c\xaf\x80\xec\x71\x62\x3f\xdd\xf3\xd8\x3f\xda\x90\x6c\x7a\xd5\x69\x5f\xe5\x0f\xfb\xdb\xb5\xb7\x83\x04\xf3\x9b\xa2\x24\xd9\x28\x62\xba\x0b\x37\x3a\x34\xd1\x08\xcf\xa2\x89\x7c\x6b\x54\xa6\xcd\x57\x43\xf5\x6a\x3c\x81\x14\x06\xa3\x3c\xe2\xee\x47\xdb\x1a\x24\x03\xf9\x27\xff\x55\x7d\x69\xb5\x0e\x96\x95\x77\xe6\x36\xeb\x82\x24\x96\x7c\x20\xd3\xe9\xc1\x62\x72\x8f\xa4\xa7\x75\xcf\x3c\x93\x9e\x75\x1f\x4f\x60\x34\x86\xbf\x78\x3f\x19\x9b\xe5\x5f\x06\xd7\x18\x88\xe9\x5d\x5a\xb5\x7a\xf5\x83\xfe\x32\x39\xc1\x14\x90\xb5\x5a\x54\x54\x72\x2d\xb1\xa1\xa5\x6e\xfa\xf3\x23\x88\x29\xcd\x5b\xac\xb3\x65\x96\x3d\x28\xfe\x1b\x9f\xf1\x3f\x30\x24\xe4\xd3\xea\xc7\x3e\xab\x5e\x42\xa9\x83\x29\x34\xc5\x17\x26\xfd\x34\x4e\xd4\xaf\x09\x36\xb6\xac\xbd\x49\x14\xfa\xbb\x03\xcf\xab\xa7\x74\xb9\x8d\x48\x1a\xed\xe4\xc5\x27\x98\x07\xfc\x92\xdc\x1e\x61\xc4\x0f\xd9\x9c\x71\x12\xd1\xb9\xb8\x55\x69\x2a\x9f\x7f\xd6\x55\xfa\x37\x29\xf5\xf1\x91\xc7\x43\xc6\x7b\x85\x18\x8b\x28\x21\xfc\x3f\x18\x8d\x03\xf5\x8c\x34\x9c\x43\xf3\xff\x7e\xfe\xff\x17\x8b\xd7\xd6\xdf\x9b\xe6\x71\x0f\xea\x1d\x32\xaa\xe7\xb7\x50\x5c\xbc\x13\xee\x9f\x6e\xa9\x2e\x6a\x26\x37\x1b\x32\x20\x70\x9d\xa2\x8a\x45\x85\x38\x21\x06\x03\x39\x58\xed\x40\xff\x83\x8b\x78\xb4\xfb\x39\x64\xf3\x58\xdc\xc7\xd1\x3c\x26\xf1\x4b\x9d\xcf\x7f\x58\xe7\x73\xfa\xfc\xe7\x63\x6d\xe0\x51\xa7\x33\x22\xa3\x63\x4e\x62\xdf\x74\x8f\x3e\x07\x27\xf0\xd6\x94\x8f\xc1\x94\x08\xb0\x6b\x82\x7a\x3f\xce\xaa\x93\xb1\x71\x4f\x95\x0e\x82\xaa\x2a\x62\x4e\x0a\xf5\x33\xe5\xc9\xaa\xe8\xca\x62\x2e\x8c\x4c\x69\x90\xc0\x57\xcf\xd0\xea\xb2\x20\xb5\xcf\x40\x0f\xfe\x18\x60\xdb\x3c\x3c\x2b\x4a\x61\x3d\xa7\x83\xc1\xea\x58\xe0\xdc\xfc\xac\x0a\xc5\x85\x41\x56\x41\x13\x45\x21\xb5\x2d\x7c\xcc\xac\xcc\x8b\x14\xb2\xb9\xa9\x83\x7f\x9b\x24\x11\x25\x71\x26\x36\x39\x3a\xae\x2c\x47\xd1\x1f\xfd\xd4\x92\x5a\xa1\x7a\xa9\x1e\x9a\x08\x28\xf1\x8f\x2c\xeb\xb4\x03\x4d\x95\x26\xf4\x49\xac\xc3\x76\x9e\x58\x13\xe2\x25\x3b\xb8\x72\xd6\x60\x8c\xb7\xd9\xa4\x67\xe7\x6a\x34\xf9\x92\xb1\xf9\x41\xdc\x53\x96\x31\x57\x0c\x64\xf5\x57\x5a\x57\xab\x5e\xb1\x95\x0c\x90\xed\x76\xaf\xf8\x2e\x97\x79\x4a\xe1\x09\xa3\x16\x1e\x49\xb2\xd7\xff\x02\xa9\x0d\x07\x30\x47\xe2\x8b\x46\x96\xa7\x64\x65\x95\xd4\xec\x37\x74\x9b\xaf\xd2\x59\xee\xe2\x2c\x75\x6f\x5a\x99\x21\x62\x07\x32\x53\x0b\x65\x13\x31\x45\x36\x24\x7e\xaa\x4a\xbf\xca\x73\x9f\x66\x47\xbe\x86\x8d\x0f\x69\x63\x01\x08\x47\x79\xd3\x99\xd1\xcd\x3c\x29\x2b\xd3\xa6\x44\xea\x9f\x5f\xb1\x4f\x58\xcc\x46\xa8\x86\x9b\x84\xe1\x9b\x7d\xa5\xa1\x6e\x07\xce\x00\x43\x9c\xd0\x35\x61\xe9\x76\x1d\x10\xe4\x93\x29\x6c\x9b\x84\xb5\x0b\x2e\xc8\x3c\x70\xf6\x33\xd4\x3d\xa5\x46\x4b\x2a\xd5\x14\xcf\xd3\x69\x20\x54\x25\x41\x96\xbf\xb3\xdf\x2f\xc9\x3f\xb0\xe9\xe4\x03\x95\xf9\x50\xcd\x19\x5a\xf9\x42\x95\x9b\x28\x89\xfe\xcb\x57\xf1\xdd\xbf\xe8\x5c\x52\xb7\x90\xb4\xfb\x33\x3b\xa9\xbb\x88\xed\xdf\x0f\xbc\x1f\xf4\x3a\x6c\x7b\x5a\x7f\x9a\x53\x06\x1c\x04\x42\xd7\x69\x66\xd3\x75\x4d\x03\x39\x63\xad\xf8\x7b\xf5\xe6\x84\x39\xba\x84\x6b\xb7\xa8\xb0\xe9\x99\x29\xa4\xd1\xeb\xa4\x63\x83\x33\x8f\x1a\x4a\xbe\x7f\x84\x15\xea\x71\x79\xe0\x19\x7b\x79\x0e\xae\xa2\x0e\xf1\x37\xe0\x2a\x96\x7b\xfc\xc5\xd8\x4a\x81\x8d\x3c\x1b\x17\x11\xe7\xfa\x0f\xc8\x44\xac\xe3\x7b\x01\x26\x52\x9a\x75\xf8\x0c\x5c\xa4\x62\xd5\x4f\xe4\x22\x1f\x3d\xb1\xea\x3a\x5c\x44\x28\xc2\x3d\xf4\x59\x11\x86\xbe\xab\x4e\xf1\x67\x29\x9e\x12\x26\xe5\xd4\x92\x06\x96\x1b\xae\x92\x23\x39\xf8\xf8\x38\xc6\x64\x38\x92\x98\xd4\x69\x54\x28\xb4\x56\xcd\xc7\x30\xda\x41\x2d\x06\x45\x7d\x77\x07\x6d\xc3\xe7\xec\x13\xff\x72\x8c\xce\x66\x4a\x95\xaf\xfa\xd4\x78\x15\x02\x86\xa8\x56\xc8\x52\xcd\x49\x2a\x23\xd1\x64\xca\x7d\xca\xa0\xd6\x23\x10\x86\xa5\x5e\x8e\x3f\xf6\x07\xae\x0e\xa2\x46\x52\x44\x7b\x4f\x05\xbc\x31\xb2\x0b\x6d\x71\xa3\x9b\xe1\xf0\x6d\x8d\xde\x31\x5d\x92\xe3\x7b\x67\xe2\x7b\x5f\x6a\x84\xb5\x7a\x6d\x08\xe7\x34\x8d\x4b\xfa\x1c\x73\x73\x24\xdb\x98\xab\x52\xb3\x77\x74\xc7\x5a\xbf\xe4\xab\x85\xe8\x8a\xd9\x05\xf3\x00\x76\x6d\x7d\x25\x5f\x61\x96\xba\x9f\xf6\x3e\xd9\x75\x9e\xd4\xb0\xed\x36\xdc\x97\xc7\x43\x56\x5a\x0a\xea\x63\x5a\x61\x13\x6e\xf5\x84\x63\x75\x77\x75\x9a\xba\x2c\xd4\x9e\xa2\xca\x79\xac\x29\x87\x54\xae\xaa\x60\x49\x61\x41\x2c\x3f\x09\xdd\xd3\x36\x74\xbb\xd0\x3d\x85\x30\x0e\x42\x1f\x2b\x5f\xc7\x09\xb0\xad\xbf\x02\xd7\xe5\xb7\xbf\x52\xb0\x5c\x77\xd7\x2e\x7a\xe2\x38\xfc\x5f\xbc\x6c\x70\xae\xb8\x60\x01\x4a\xf5\xdf\x16\x39\x64\x97\xd0\x47\x55\x52\xa9\x83\x68\xee\x60\x42\x17\xb1\x2c\x87\xf5\x12\x60\xc8\x20\x5c\xc6\x49\x4a\x83\x1e\xcc\x56\xd4\xb4\xf7\x49\x0c\xb7\x54\x56\xec\xc6\x62\x14\xdc\x5f\x01\x59\x92\x30\x66\xdc\xad\x3f\xa8\xa2\x68\xff\xfc\x0e\x92\x14\xfe\x04\xc9\x86\xa6\x44\x30\xa7\xda\xa6\x0f\x77\xfd\x45\x8c\x2d\x32\x47\xa0\xbf\x5a\x15\x5b\x1f\xf7\xb0\x0d\x4e\x4d\x7f\x55\x88\x72\x5a\x59\x94\x4b\x49\xe1\x6f\x0e\x17\x7e\xab\xca\x5b\x20\x8c\x6d\xd7\x54\xbb\xf4\x65\xc8\x96\x55\xf4\x11\xc2\x38\xab\xec\x7e\x8a\x2c\x1d\x9b\xc4\x09\x04\xdb\x4d\x84\x34\x00\x34\xe6\x46\x7e\x57\x84\x23\x6b\x12\x46\x34\x5e\xf2\x95\xde\x45\x07\x4e\xdb\x70\x5e\xf6\xd3\x1b\xfc\x09\x71\x56\x6d\xf8\xcf\xef\xf4\xd6\x7e\x7e\x73\xf6\xe9\x79\x0d\x98\xf4\xd7\xaa\x9a\x6e\xd5\xb5\x9e\xca\xad\x9a\x0f\x89\x8d\x6b\x32\xec\x8e\xfe\xba\x25\x51\x47\xe2\xad\x0e\xe0\xb6\x00\x5a\x1b\xf1\x1e\xb3\xca\x47\x33\xd4\x3a\xa8\x66\xae\xf2\x7d\x9c\xa3\x3e\xc2\x55\x62\x50\x0d\x14\x6a\x39\xbf\xe9\x85\xe1\x8f\x5f\xc3\xa9\x1b\x8e\x92\xc3\x2a\xdd\xf8\xcb\xa0\x54\x11\x5c\x55\xd6\x72\x9b\x87\x39\x82\x94\x85\x63\xf8\x12\x81\x76\x21\xc5\x09\x97\xd1\x47\x79\xa6\xfa\x92\xc8\x57\xd8\xcf\xd3\x31\xb0\x1a\x01\x05\x0b\x9e\x97\x5f\xf9\x8f\x46\x36\xf4\xa4\x9b\x92\x8d\x7a\x11\x79\x94\xc7\x17\x30\x48\x84\x8f\x14\xc7\x51\x18\xab\x5a\x3e\xfb\x50\x55\x63\x6a\x1d\x49\x68\x5e\x22\x0f\xec\xc1\x63\x81\xc6\x55\x57\xd4\xdc\x54\xdf\x7c\xb6\x1b\x7c\x1f\x2e\x94\xd5\xdf\xca\x23\xb1\x54\x04\x64\xe1\xd1\xdf\x8a\x41\x56\xdd\xd6\xa5\x5a\x07\x24\x1b\x01\xd2\x83\x4a\x49\xed\x97\x04\xfd\x24\xe6\x42\x16\x79\x7e\x8c\xb6\x7d\x18\xcf\x8d\x07\x47\xbe\x2e\x68\x36\x79\xf4\x21\x68\x70\x5e\x7b\x93\xfe\x6c\x3c\x71\xf6\xf0\xe7\x77\x2a\xda\x49\x88\xc0\xfd\xc9\x7b\x38\xaf\x84\x9e\xd4\x8f\x07\xef\x3f\xa8\x76\xf2\xc5\x4c\x69\x30\xd1\x2b\x3f\xdf\xbf\xf6\x46\x7b\x1f\x4e\xfc\xe9\x19\x51\x02\xcf\xe6\x20\x3e\x3c\xcb\x15\xeb\xe2\xc8\xef\x7f\xff\xc8\x2b\xef\x48\x74\x90\x1b\x7c\xee\x4b\xa3\x0c\x45\xfe\xf4\x68\x0c\xd9\xb7\x88\x7a\x88\x83\xbd\x10\x6b\xbe\x00\x02\x68\xdb\x45\x4d\x04\x18\x8d\x67\xd0\x2a\x62\x41\x39\x4b\xf8\x82\x68\x60\xb6\xf5\x25\xd1\x40\x2f\xe2\x58\x34\xa8\x64\x1e\xe7\xe7\xf0\xbb\xf3\x73\x38\x3f\xff\x3b\xfc\xee\xfc\xef\xcf\xc8\x49\x16\x61\x1c\xa0\xf5\x1a\xef\x51\x7c\x22\x8c\x27\x72\x45\xe5\x36\xab\x0e\x6c\x08\x2f\x33\x4c\x3d\xc5\x62\x62\xca\x64\xd8\xc5\xe7\xc3\x40\x57\xa3\xff\xf9\x13\x1a\x9d\x7e\xfe\x74\xc8\xd0\x00\x75\x1f\xa0\x06\xf3\x38\x86\xbd\x61\x7c\xe4\xc4\x98\x39\x36\x84\xbf\xdc\x7d\x97\x83\x7b\x05\xa8\xcb\xc0\xfc\x94\xa8\x89\xdc\xdc\x71\xc2\x5f\xfa\xdc\x35\x25\xbc\xc8\xb9\x9b\xc1\xff\x09\xcf\x3d\x83\xfd\x97\x39\xfb\x94\x2e\xe9\xe7\x7f\xd1\xbb\x39\xf7\xbf\xff\x46\xe7\x2e\xe1\xfe\xe5\xe8\xfd\x85\xcf\xfd\x9f\x8e\xde\x7f\xab\x73\xcf\x60\xff\x2c\x67\x5f\x26\xc3\x9c\x9f\xd7\x10\x62\xc4\x5c\xfb\x44\x18\x35\x75\x3d\xc9\xc5\xbd\xc5\x1c\x49\xb6\x6c\x81\xbf\xfb\x82\x2b\x34\xfc\xf6\xe0\x2a\x85\x90\xf5\xa5\x56\x89\x18\x52\x03\x8e\x5f\x6e\x85\x06\x8f\xab\x05\x56\x47\x78\xfd\x3e\xa4\x0f\xa5\xaf\xf8\x97\x0b\xae\x76\x50\xc0\x60\x74\x35\xd6\x91\x09\x32\x28\xc0\x8e\x07\x58\x67\x4f\xb8\x39\xa1\x0a\xe6\x9b\xe5\x0f\x6f\xd8\x9e\xf9\xe3\xb3\x8b\x80\xb0\x42\x81\x19\x39\x66\xa1\xca\x75\x65\x6e\xbf\x29\x6f\x67\xca\xf4\x49\x03\x9f\xfb\xaa\xd8\xc1\x9a\x17\xa6\xb5\xca\x3f\x2e\xad\x7e\x61\x1a\x95\x17\xae\x80\x5c\x56\x22\xee\xcf\x2d\xc2\xa7\x20\x56\xfe\x3e\x9a\xfd\xe6\xda\x91\x21\x31\xb0\x27\x2c\x66\x5d\xfa\x48\x77\xf6\x86\x1b\xdd\xb5\xed\xf2\xd8\xd8\x62\x15\xf6\x78\xc2\x49\x34\x67\xe1\xdf\x30\xe2\x43\xfc\xaf\x3a\x9a\xd3\xde\x6b\xe8\x42\x6b\xb3\xc4\x1f\xe7\xb7\x3b\x4e\x59\xcb\x5f\xb1\x9e\xae\xaa\x4c\x83\xb9\xec\x8c\x3f\xb5\xcf\xce\xe2\xed\x9a\x0a\x64\xfb\x06\x8a\x9d\xb6\xf1\xa1\x6e\xed\x36\x7c\x05\xa7\xaf\x5f\x23\x34\xb3\xc2\xcd\xf3\x94\xf0\x30\x91\x6b\x12\x03\xc9\xbe\x32\xf1\x23\xfb\x1a\x6f\xd7\xb7\x34\x9d\x5b\x73\xe8\xa2\xd0\xd9\x60\xe6\x63\xa3\x46\x14\x4f\xe6\xf4\x75\x31\x52\xc6\x5b\x85\x49\xec\x94\x5e\x09\x8b\x6f\xf7\xb5\x04\x6c\x11\xaf\x64\x76\x67\x79\xe5\x94\x30\x57\x3a\xa5\x97\x7b\xac\xe5\xf0\x32\xac\xdd\x59\xb8\xcc\x38\xe1\x02\x29\x59\xc9\xc2\x04\xbc\xac\xa6\x6a\xea\x63\xd2\x12\xb3\x62\xd5\xb9\x45\x1e\xac\x51\x53\x06\xa7\xa3\xeb\xcb\xf4\xdc\xa2\xfa\x55\xac\x4f\x4a\x33\xc8\xf9\x2c\xc6\x17\xdd\xf5\x0c\x67\x8f\xee\x8a\xa1\xd3\xe6\x17\x37\x54\x5b\x7e\x76\x72\xde\xa5\x00\xb4\x47\x8e\xca\xc9\x50\x72\xe6\x8c\x22\x9d\x28\x80\xfe\x14\x4c\xa5\xfa\xbd\x4c\x01\xa2\x3b\x74\x74\x37\xba\xdd\x4b\x6a\x17\x38\x57\x9e\x27\x4e\xee\x28\x6c\x22\x82\x0f\x74\x03\xb1\xb2\x9b\xac\xea\x39\xf2\xe1\x51\xac\xa1\x23\x73\x11\x57\x34\x0a\x80\xf8\x69\xc2\x58\xa3\xdb\x0d\xcc\xc0\x73\x55\xba\x86\x44\x11\x33\xc9\xf2\x84\x67\x0f\xf7\x89\xe9\x18\x96\x50\x80\x15\x25\xf7\x21\x4d\xd5\x88\xaa\xdc\x09\x8d\x83\x5e\x65\xca\x65\x56\x97\xd1\x9d\x8f\xcd\xb1\x40\x4a\xab\x50\x85\xaa\x03\xeb\x30\x2e\xd4\x9f\x2a\x4b\xd4\x94\xfc\x38\x4d\x1e\xf6\x54\xd9\x91\x41\x5d\x59\xbd\x99\xca\xd6\xa6\x89\xec\x61\x11\x4f\x65\x97\xac\x8d\xec\xa3\xd7\x6d\x2e\xb5\xc2\x03\x5c\xe6\x7e\x58\xf5\xbe\x72\x42\x11\x73\xd3\x1d\x51\x0c\x4a\x95\x1c\xa9\x2a\xce\x84\xc4\xb5\x87\xf8\xdc\xa0\xc9\x20\xb7\x2c\x17\x6e\xb5\x6e\x5f\x5d\x51\x2a\x7f\xe7\x3a\x1b\x14\x57\xad\xa1\x91\x30\xc8\xd5\xe5\xd9\x2f\x27\x08\x00\x6b\x61\x21\x26\x51\x4b\x43\xbd\xed\xd6\x13\xcb\x9f\x85\x95\x92\x99\xe1\x4d\x31\x35\xd3\xcf\xe7\xb9\xd6\x2c\xb8\x64\x3a\x3d\xb2\xf6\x53\x69\x8d\x26\x38\x07\xac\xe1\x74\xdc\xe0\xa0\x07\xd4\x45\xa4\xe0\x1c\x7c\x7b\x14\x25\x17\xb9\x75\x99\x9c\xa3\xb6\x5b\x77\xbb\xb2\x8c\x1e\x56\x53\xc2\xea\x29\xd2\x25\x1e\xc6\xaa\xde\x93\x69\x29\x50\xad\x48\x03\xef\xce\xb3\xea\x4e\xd8\xdd\x69\xef\xf7\xf2\x57\x37\x26\x85\x4d\x4d\xec\x5e\x89\xa0\x56\x1c\xcd\xc9\x85\x9d\xf4\x07\x53\x2c\x87\x31\xb8\xf0\xa0\x39\xd3\x60\xea\x5a\x19\x8a\x21\x83\x8c\x1f\x85\xf1\x52\xa2\xc4\x19\xbc\xea\xbd\x32\x0f\x11\x08\x30\x58\x84\x63\x7f\xb6\x1f\xbb\xd1\xb3\x9a\x6a\x10\x39\x3e\xd7\xca\x5f\xba\x47\x8c\x6e\x5f\xc2\x8f\x4e\xb3\xfd\x7f\x01\x00\x00\xff\xff\x39\x60\xab\xd4\xab\xdd\x00\x00"),
 		},
+		"/2_connector_instance.down.sql": &vfsgen۰CompressedFileInfo{
+			name:             "2_connector_instance.down.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 56,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x08\x76\xf6\x70\xf5\x75\x8c\x77\x76\x0c\x71\xf4\xf1\x77\xd7\x4b\xce\xcf\xcb\x4b\x4d\x2e\xc9\x2f\x8a\xcf\xcc\x2b\x2e\x49\xcc\x4b\x4e\xb5\xe6\x02\x0c\x00\x22\x72\x4b\x5c\x38\x00\x00\x00"),
+		},
+		"/2_connector_instance.up.sql": &vfsgen۰CompressedFileInfo{
+			name:             "2_connector_instance.up.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 394,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x8c\x90\x41\x6b\x22\x41\x10\x85\xef\xf3\x2b\xde\x51\xc1\xf1\x0f\xec\xa9\xd7\xed\xdd\x95\xcc\xa8\x68\x0b\x31\x97\xa1\xed\x29\xed\x86\xb1\x5b\xaa\x2a\x8a\xff\x3e\xa0\x24\x98\x5b\x8e\xf5\xd5\xfb\xe0\xf1\xea\x1a\xf6\x42\x7c\x03\xbf\xe7\x9c\xf2\x11\xa1\xe4\x4c\x41\x0b\x83\xe9\x98\x44\x89\x05\x49\x85\x86\x03\x22\x31\xc1\xe7\x1e\x67\xe2\x54\xfa\x14\xfc\x30\xdc\xc0\x74\x60\x92\x48\x52\xd5\x35\x06\x2f\xda\x45\xf2\xac\x7b\xf2\x3a\x81\x14\x94\x33\xb1\xd7\xc2\x82\xe0\x33\x84\x08\xd7\x98\x42\xc4\x3e\x65\xcf\x89\x04\x9e\x09\x57\x4e\x9a\xf2\x71\x5a\xcd\xd6\xd6\x38\x0b\x67\x7e\x37\x16\x9b\xd9\x7f\xdb\x9a\x6e\x66\x9c\x69\x96\xff\xa6\x5f\xd5\xba\x94\x45\x7d\x0e\x84\x51\x05\x00\x9f\x67\x97\x7a\x38\xfb\xea\xb0\x5a\xcf\x5b\xb3\xde\xe1\xc5\xee\x26\xf7\x44\x2c\xa2\xd9\x9f\xe8\xfe\x7e\xa0\x0b\xb1\xa4\x92\x9f\x48\x28\xa7\x53\xd2\x2e\x7a\x89\x4f\x54\xd4\xb3\x52\xdf\x79\x85\x9b\xb7\x76\xe3\x4c\xbb\x72\x6f\x58\x2c\x1d\x16\xdb\xa6\xc1\x1f\xfb\xd7\x6c\x1b\x87\x5c\xae\xa3\xf1\x43\xf9\xbe\xc2\x0f\xb4\x6a\xfc\xab\xfa\x18\x00\x41\x1b\xb4\x50\x8a\x01\x00\x00"),
+		},
+		"/3_bulk_series_ids.down.sql": &vfsgen۰CompressedFileInfo{
+			name:             "3_bulk_series_ids.down.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 105,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x09\xf2\x0f\x50\x70\x0b\xf5\x73\x0e\xf1\xf4\xf7\x53\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x08\x76\xf6\x70\xf5\x75\x8c\x77\x76\x0c\x71\xf4\xf1\x77\xd7\x4b\x4f\x2d\x89\x2f\x4e\x2d\xca\x4c\x2d\x8e\xcf\x4c\x29\x8e\x4f\xcb\x2f\x8a\xcf\x4e\xad\x8c\x2f\x4b\xcc\x29\x4d\x8d\x4f\x2c\x2a\x4a\xac\x2c\xd6\x08\x71\x8d\x08\xd1\x51\x28\x49\xad\x28\x89\x8e\x45\xd0\x99\x79\x25\xd1\xb1\x9a\xd6\x5c\x80\x01\x00\x1f\xe2\xad\x1e\x69\x00\x00\x00"),
+		},
+		"/3_bulk_series_ids.up.sql": &vfsgen۰CompressedFileInfo{
+			name:             "3_bulk_series_ids.up.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 1151,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xa4\x52\x4d\x4f\xeb\x3a\x10\xdd\xe7\x57\x9c\x05\x0b\x10\x69\x25\xb6\x54\x6f\x61\x82\x09\x95\x42\x52\x52\xf7\x09\x84\x50\xe4\x36\x53\x1a\x91\xda\x95\xed\xbc\x77\xf9\xf7\x57\x8e\x4b\x68\x74\x3f\x74\xa5\xeb\x8d\xbf\xce\x9c\x99\x39\x67\x26\x13\x64\x5a\xbf\x5b\x74\x87\x18\xda\x60\x63\x48\x3a\xb2\x31\xdc\x8e\x60\xc9\x34\x64\xd1\xd4\x16\x5b\x6d\xb0\x97\xea\x03\xad\x5c\x53\x0b\x4b\xce\x42\x6f\x03\x4a\xee\x09\x7b\x72\xa6\xd9\x44\x93\x09\x1a\x05\x09\xdb\xa8\xb7\x96\x60\x74\xa7\x6a\x38\xd3\x1c\xa6\x10\x3b\x3a\x0d\x96\x86\x70\x90\xd6\x52\x8d\x6d\x2b\x9d\x23\x45\xf5\x75\x00\x54\x1b\xdd\x29\x67\x3d\xd9\x4e\xb7\xb5\xed\xb3\xa8\x6e\xbf\x26\xe3\x73\xf6\x98\x3e\x3b\xc9\xcd\xce\xb3\xc5\xc7\xb8\x77\xfa\xb0\x90\xaa\x3e\x5e\xff\x93\x6d\x47\xb6\xe7\xf0\x5c\x6e\x47\x27\xb1\xb2\x3d\x16\xb2\x96\x9b\x77\x38\xdd\xef\xa1\xca\xa6\x0e\xe5\x19\x72\x9d\x51\x54\xfb\x96\x1a\x75\xe8\x1c\xb4\xa9\xc9\x4c\xa3\xa4\xe4\x4c\x70\x14\x25\x4a\xbe\xc8\x58\xc2\x71\xb7\xca\x13\x31\x2f\x72\x2c\x93\x7b\xfe\xc0\xaa\x84\x09\x96\x15\xe9\xf4\x8d\x5c\x15\x54\xac\x9a\xda\x56\x5b\x6d\x7c\x91\xa1\xb2\x4a\x1a\x23\x3f\xec\x79\x90\xae\x52\x5e\x46\xc1\x9f\xc4\xa8\x1b\x47\xdf\xdc\xcb\x6b\x3c\xee\x68\xfc\x18\xd4\x42\xa3\xdc\xcb\xeb\x45\x54\x72\xb1\x2a\xf3\x25\x04\xbb\xc9\xf8\xb9\x93\xeb\x96\x02\x75\xce\x1e\x78\x8c\xa1\x18\xdc\xcc\xd3\x79\x2e\x2e\x22\xb6\xc4\xd9\xb6\x53\x9b\xb3\xe8\x96\x27\x19\x2b\x79\x04\x00\xd6\x49\xe3\xaa\x83\xee\x79\x71\xfd\x0f\xae\x66\xfd\xfb\x49\x4a\xff\x33\x8b\x6e\x78\x3a\xcf\xfb\xaf\xbb\xa2\xe4\x2c\xb9\x1f\x41\xe6\x39\x58\x59\xb2\xe7\xb1\xb1\x1e\x9d\x15\xc5\xa2\x3f\xf8\x15\x8a\xc6\xe3\x8a\x97\xcf\xc3\xa3\x5f\x4b\x9e\xf1\x44\xc0\x4e\xbf\x1a\x89\x61\xa7\x43\x17\x23\xf0\x5d\x59\x3c\xfc\xd6\x80\x9f\xe9\x7f\x3e\xa2\xf0\xeb\xc4\x8f\xf8\x87\xcf\x2f\x6b\x5e\x06\x89\xae\x87\xd3\xe5\x49\x9b\x93\xab\xd7\x5f\x85\x07\x1b\xff\x80\xe0\x02\x76\x36\x70\x0c\x20\x6f\xc7\xd7\xe5\xf2\x54\xdb\x80\xe6\xf9\x6d\xaf\xef\x2c\xe2\xf9\x6d\x74\x74\x37\x63\x79\xba\x62\x29\xc7\x22\x5b\xa4\xcb\xc7\x0c\xff\x16\x19\x13\xf3\x8c\xcf\xa2\xb4\x64\xb9\x00\x7f\xe2\xc9\xca\x8f\x75\xfe\x57\xe3\x1c\x46\xf8\x73\x44\x3f\xf7\x30\x9d\x10\x05\x0e\x46\xef\xab\xff\x4d\xe3\xc8\xcc\xa2\xef\x03\x00\x0f\xc7\xfd\x68\x7f\x04\x00\x00"),
+		},
+		"/4_ha_lease.down.sql": &vfsgen۰CompressedFileInfo{
+			name:             "4_ha_lease.down.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 124,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x09\xf2\x0f\x50\x70\x0b\xf5\x73\x0e\xf1\xf4\xf7\x53\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x08\x76\xf6\x70\xf5\x75\x8c\x77\x76\x0c\x71\xf4\xf1\x77\xd7\x2b\x2d\x48\x49\x2c\x49\x8d\xcf\x48\x8c\xcf\x49\x4d\x2c\x4e\xd5\x08\x71\x8d\x08\xd1\x51\x80\x90\x9e\x7e\x21\xae\x41\x61\x8e\x3e\x9a\xd6\x5c\x60\xd3\x42\x1c\x9d\x7c\x5c\x71\x1b\x05\x33\xc3\x9a\x0b\x30\x00\x2b\x13\x55\x8a\x7c\x00\x00\x00"),
+		},
+		"/4_ha_lease.up.sql": &vfsgen۰CompressedFileInfo{
+			name:             "4_ha_lease.up.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 1477,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x8c\x53\x4f\x8f\xbb\x36\x10\xbd\xf3\x29\xde\xe1\x77\x08\x2d\x89\xb6\xd7\xfd\x53\xc9\x0b\x4e\x82\xea\x98\x14\xcc\x36\xdb\x4b\x84\x82\xb3\x20\x11\x1c\xd9\xce\x66\xfb\xed\x2b\x20\xb0\x24\xda\x76\x7f\x17\x90\xed\x99\x79\x6f\xde\xbc\x99\x4e\xc1\x64\x66\xa4\x81\xac\xe4\xce\x96\xf5\x9b\x87\xbd\xd2\x90\xef\x52\xff\x83\x5d\x75\x32\x56\x6a\xa8\x3d\x96\x04\x6b\xad\x0e\xd2\x16\xf2\x64\xa0\xe5\xb1\x2a\x77\x99\xf1\x60\x0b\xd9\x9f\x9c\xe9\x14\xe7\x42\x19\x09\x93\x1d\x8e\x95\x34\xc8\xb4\x84\xb1\x4a\xcb\x7c\x06\x51\x7c\xde\xab\x7d\x9b\xa7\x6c\x21\xf5\x50\xab\x8d\xce\xb5\x3a\x1e\x65\x3e\x73\xfc\x98\x12\x41\x21\xc8\x33\xa3\x48\xfc\x25\x5d\x91\xad\x4f\x04\x61\xd1\x62\x56\x64\xdb\xaa\x21\x8d\x89\x03\xa0\x67\xb9\xad\xb3\x83\x84\xa0\x1b\x81\x75\x1c\xae\x48\xfc\x8a\x3f\xe8\xab\xd7\x86\x54\x32\xcb\xaf\x22\x78\x24\xc0\x53\xc6\x86\x67\x23\xb7\xc6\x66\xda\x42\x84\x2b\x9a\x08\xb2\x5a\x8b\xbf\x87\x28\x04\x74\x4e\x52\x26\x50\xab\xf3\xc4\x1d\xe7\x9c\x6a\x5b\x56\x5f\xe6\x38\xee\x83\xd3\x28\x42\x3f\xac\xac\x73\xd3\x36\xdc\xb1\xbe\x74\xdf\x8b\x5b\xee\x7b\x09\x50\xa8\x2a\x37\x28\xad\x07\xa5\x51\x64\x75\x7b\x80\x7a\x97\x1a\x56\x35\xc5\xfa\xc0\x72\xdf\x3c\xc8\x8f\x63\xd9\x6a\x1b\x4b\x7b\xd2\xf5\x80\x91\x4b\x8d\xac\xce\x51\xa8\x33\x2a\x55\xbf\xa1\xb4\xe6\x82\x5d\x65\xc6\x9a\x41\xdd\x28\x46\x4c\xd7\x8c\xf8\x14\xf3\x94\xfb\x22\x8c\xf8\xad\xd6\xa7\x63\x9e\x59\xb9\xed\x25\x9f\xf4\xac\x1b\x15\xbd\x81\x4f\x77\xea\x24\xb1\xe5\x41\xaa\x93\x45\xc8\x05\x8d\x5f\x08\x73\x9d\x98\x8a\x34\xe6\x49\x37\xcc\xc9\xed\x2c\xfa\x3c\x2d\x0f\x59\x59\x97\xf5\xdb\xf6\x60\xf0\x1c\x2e\x42\x2e\x5c\x87\x24\xf8\xb1\x3f\xd5\xbb\x1f\xad\xe6\x21\x4f\x68\x2c\x9a\xca\xd1\x7f\x7a\x82\x24\xa8\x30\x19\x7b\xc2\x1b\x8f\xdf\x1b\x0f\xdb\x1b\x4f\xd1\x6d\x21\x5e\x08\x4b\x69\x32\x14\x18\x5a\xf4\x2e\xb3\xef\x7e\xf8\xf5\xba\xd9\x2e\x37\xe2\xf0\x23\x3e\x67\xa1\x2f\xae\x19\xb8\x08\x22\xa4\xeb\xa0\xd1\x3c\xa1\xa2\x0d\xbe\xb5\xe5\x13\x7c\x92\x50\xfc\xb5\xa4\x1c\xd5\x6c\xec\xae\xc7\x0b\xa6\x68\x9e\xe8\xc6\x67\x69\x40\x83\xd9\x38\x97\xb2\x84\xa2\xba\xbe\xe2\x81\x37\xc6\x19\xfc\xfd\x3d\x0e\xe1\xc1\x4d\xb1\xc7\xdf\xbf\xc6\x6d\x19\x75\x49\x9f\x14\x06\xa4\x2f\x28\x74\x40\xdf\x53\x88\xe2\x1b\x06\x4f\xff\x43\x60\xfc\x32\x54\xba\xe2\x73\xb9\xe2\x41\x4b\xa7\xb3\x63\xc8\x17\xd7\x18\x1e\x26\x74\x23\x62\xe2\x8b\x09\x5d\x47\xfe\x12\xf3\x38\x5a\xdd\x94\x98\x76\xfc\x5c\xfc\x82\xdf\xee\xee\xee\xdc\xfb\xfb\xce\xa8\xce\xc5\xa4\x8c\xf0\x45\x4a\x16\x14\xc9\x9f\x0c\x2f\x11\x23\x22\x64\xf4\xc1\x59\xc4\x84\x0b\xd0\x0d\xf5\xd3\x66\xeb\xf8\x4f\x6f\x5b\xb7\x1f\xdd\x77\xd8\x27\x88\x08\x47\xad\x0e\xdb\xb3\x2e\xad\xd4\x0f\xce\xbf\x03\x00\x84\x1b\xea\xe0\xc5\x05\x00\x00"),
+		},
+		"/5_label_retention.down.sql": &vfsgen۰CompressedFileInfo{
+			name:             "5_label_retention.down.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 2357,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xcc\x56\x41\x6f\xa3\x3a\x10\xbe\xf3\x2b\xe6\x90\x55\x92\xa7\x24\xd2\x3b\x47\x5d\x89\x82\x93\x45\x25\x98\x35\x64\xbb\x7b\x42\x04\xa6\x09\xaf\x60\x23\xdb\xe9\xbe\xfe\xfb\x27\x03\x25\xa4\x69\xab\xa8\x87\xa7\xe5\x10\x29\x66\xbe\xcf\xe3\x6f\xbe\xf1\xe0\x32\x1a\xc2\x0f\x8f\xdc\x83\xb7\x02\xf2\xd3\x8b\xe2\x08\x22\xe7\x1b\xd9\xd8\x89\x17\xac\xe8\xa2\x4c\x77\x58\x26\x12\x35\x72\x5d\x08\xbe\xb4\x1a\xc0\x6a\x1b\x38\xb1\x47\x83\x4b\x50\xc8\xe8\x66\x21\x51\xa1\x4e\x5e\x41\x93\x1a\x65\x21\xf2\x49\x4c\x7e\xc6\x33\x30\xbf\xd3\xeb\xd8\xae\xe2\x9a\x81\x17\xc4\x84\xfd\xb0\xfd\xe9\xd2\xb2\xe6\x73\x2a\x73\x94\xb0\x7b\x06\x99\xf2\x5c\x54\xf0\xbb\xd0\x07\x50\x3a\xdd\x95\x08\x55\x2a\x1f\x0b\xbe\x87\x7d\xf1\x84\x0a\x8e\x0a\x54\x5a\x21\x88\x06\x51\x70\x48\x4d\x9c\xc6\x0a\xb9\x86\x94\xe7\x90\x17\x0f\x0f\x28\x91\x6b\x6b\x3e\x6f\xa3\x0a\xbe\x57\x26\xb2\x7f\x73\x42\x28\xcb\x61\xc4\x8e\x09\x50\x06\x8c\x84\xbe\xed\x90\xd3\xf9\xba\x53\x39\x76\x6c\xfb\x74\xbd\xd8\xa3\x4e\x2a\xd4\xb2\xc8\x54\xa2\x0f\xa9\x4e\x38\x62\x9e\xe4\x52\xd4\x49\x76\x38\xf2\xc7\xc9\xd4\x62\x24\xde\xb2\x20\x82\x88\xc4\x74\xf5\x1a\xdf\x62\x2d\x3b\x82\xd1\xc8\x82\xee\x89\x88\x4f\x9c\x18\xaa\xc5\x5f\xfd\xd2\x8a\xd1\xcd\xdb\x58\xa8\xfa\xa0\xfb\x6f\x84\x91\x17\xf9\x27\xfd\xf2\x80\xf2\xef\x86\xe8\xec\x8d\x3a\x88\xdf\x6d\xae\x6a\x72\x78\xae\x51\x36\x02\xdf\x7c\x7d\x10\xb2\x4a\xf5\x64\xfc\xc5\x5b\x7c\xf1\xc6\x33\x18\x77\xbb\xbb\x76\x6c\x8f\x67\x50\x2d\x9a\xb8\x84\xa7\x15\x4e\x67\x67\x8c\x67\x8f\x28\x73\x94\x46\x1a\x7e\xf3\x35\xa0\xf7\x93\x29\xcc\xdf\xd7\xf0\xd2\x1d\x55\x77\xca\x76\x9f\xe9\xb4\xdf\x68\x3e\xef\x5c\xd1\xd6\x3c\x2d\x95\x00\x2d\xa0\x96\xf8\xd4\x15\x53\x3e\xa5\x86\xa9\x47\x50\xe6\x12\x06\xb7\xbf\x3a\x3b\x4d\xa6\xd6\x68\x64\xf9\x76\xb0\xde\xda\x6b\x02\xd1\x77\x1f\xa2\xd8\xbe\xf5\x49\xe3\xbd\xfa\xb8\x2b\x8b\x0c\x6a\x29\x32\xcc\x8f\x12\x0d\xf7\x0e\x21\x4b\xcb\x12\x73\xe3\xc9\x4c\x0a\xfe\x86\x4f\x42\x46\x1d\xe2\x6e\x19\x39\xb3\xff\xc9\x0f\x6a\x32\xed\x8a\xed\x12\xc7\xb7\x19\x69\xd2\x93\xc0\x88\x43\x99\xbb\xb4\x6e\xc9\xda\x0b\xac\xf6\x80\xb9\x00\xc1\x11\x4a\x21\xea\xce\xfb\x8f\x45\x0d\xa5\xc8\x1e\x31\x6f\x5c\xad\x0f\xc8\x9b\x10\xe3\x3c\xd8\x99\x37\xea\x03\x11\x56\x94\x81\x84\x8e\x7e\x60\x8a\x8f\x5d\x76\x85\xc3\x0d\xd4\xa7\x34\x1c\x14\xc7\xe4\xf2\x92\x87\x82\x4c\xf0\xec\x28\x9b\x2e\x1b\x48\x01\x82\xb7\x6d\xdb\x38\xa9\x07\x87\x84\xad\x28\xdb\x7c\xd6\xfe\xd5\xa2\xc8\xe1\x06\xe4\xa2\xc8\x4f\x70\xca\x20\xa0\x70\x47\x7e\xc1\x36\x74\x4d\xc9\xa2\x3b\x2f\x04\x9f\x3a\x77\xc4\x5d\x5a\x7d\x9c\x43\x83\xd8\x0b\xb6\xc4\x50\x05\x10\xd0\x18\x56\x74\x1b\x0c\x23\x5e\x92\x7b\x95\x4b\x73\xaa\xce\xa9\x5d\x9d\xe5\xd0\xb9\x33\xf8\x84\xf7\xe5\xb9\xf7\x97\x83\x34\x37\x1b\x2f\x6e\xff\x93\xc0\x6d\xb4\xef\x72\xfc\xff\x6a\xfc\x07\x2b\x41\x4c\xc9\x46\x23\xe8\x9b\x3b\xf4\xc3\x75\xf4\xdd\x5f\x5a\x26\x9e\x04\x31\xd0\xe0\xaa\x4e\xf5\x22\x18\x9b\x15\x05\x79\xaa\x53\x48\xb3\x4c\xc8\xdc\x8c\x1d\x2d\x4c\xf3\xb5\xab\x7d\xb6\x50\x8b\xb2\xc8\x9e\x17\x10\x1f\x0a\x35\xb8\x3a\xd4\x41\x1c\xcb\xdc\x5c\x1f\xf2\xc8\x41\xe2\xfe\x58\xa6\xb2\x7c\x6e\xe7\x94\xb9\x47\xe0\x1f\xb1\x1b\x2f\xad\x76\x92\x9e\xf2\xba\x18\xa5\xbd\xc6\x58\xa2\xc6\x8b\x81\x8a\xff\xd6\x85\xc4\x7c\x72\xdd\x5c\x1e\xea\xfe\x9a\x49\xa1\x2c\x50\x7d\x82\x68\x58\xf8\xb7\x47\xfd\xa7\xf8\x3e\xf8\x74\x78\xe1\x6b\xae\xee\xf7\xc9\x2e\xbe\x81\xfe\x1b\x00\x08\x17\x94\x8d\x35\x09\x00\x00"),
+		},
+		"/5_label_retention.up.sql": &vfsgen۰CompressedFileInfo{
+			name:             "5_label_retention.up.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 9341,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xd4\x5a\x5d\x73\xa3\x3a\xd2\xbe\xe7\x57\xf4\x45\xe6\xc4\x7e\xcb\x50\xf5\xde\x1e\x9f\x9c\x2a\xc6\x96\x33\xec\x10\xf0\x01\x3c\x1f\xb5\xb5\xe5\x52\x40\x89\xd9\xf0\xe1\x95\x70\x66\xf2\xef\xb7\xf4\x01\x08\x03\x8e\x9d\x99\x39\x55\x9b\x8b\x99\x42\x88\x56\xab\xfb\x51\x3f\xdd\x2d\x9b\x26\x04\xa4\x22\x45\x95\x96\x05\x94\xcf\x84\xd2\x34\x21\x0c\x1e\x4a\x0a\xd5\x8e\x00\x23\x34\x25\x0c\x62\x4c\xe9\x4b\x5a\x3c\x02\x86\x0c\xdf\x93\x6c\x06\x38\xa6\x25\x63\x80\xb3\x0c\x72\x52\xd1\x34\x66\x96\x61\x9a\x60\xd7\x5f\xe4\xb8\x8a\x77\xfc\x0b\x46\x9e\x09\xc5\x99\x26\xfb\x89\x90\x3d\x83\xb4\x62\x90\xe0\x0a\x37\x4b\x65\x65\xf1\x48\x58\x05\xe5\x03\x7f\xcc\x2d\x63\x11\x20\x3b\x42\x10\xd9\xef\x5d\x04\xe1\xe2\x03\xba\xb3\xb7\x0b\x3b\xb2\x5d\xff\xd6\x12\x5a\x6c\x69\xa3\xf9\xc4\x00\x00\x78\x22\x2f\x10\xa1\x2f\x11\x78\x7e\x04\xde\xc6\x75\x67\x62\xf8\x19\x67\x07\x32\xf4\xa2\xf9\x7e\xbb\x27\x34\x2d\x13\x70\xbc\x08\x05\x9f\x6c\xf7\x68\xde\x3a\x70\xee\xec\xe0\x2b\x7c\x44\x5f\x61\xf2\x44\x5e\x66\x52\xe4\xd4\x98\xce\x0d\xc3\x34\x75\xed\x85\x62\xad\xe0\x66\xdb\x80\xf7\xfb\x4c\x98\xb0\x2a\x01\xd7\x56\x92\x7b\x55\x16\x9c\x19\xa6\xc9\xd7\x84\xf4\x01\x8a\xb2\x20\x90\x94\x84\xd5\x56\xf0\x03\x08\xd0\xda\xb5\x17\x08\x56\x1b\x6f\x11\x39\xbe\x77\x6c\x93\x47\x52\x6d\xa5\xa4\xed\x91\x79\xd4\xf6\x26\xea\x6d\x81\x73\x69\x8e\xa9\x11\xa0\x68\x13\x78\x61\xb3\x71\xc3\x0e\xe1\xea\xca\x00\x00\x08\x91\x8b\x16\x11\xe4\xf8\xfb\x84\x5a\xc7\xa2\xa6\x62\xca\x2a\xf0\xef\x5e\xf3\x0c\x15\x33\x1d\xcf\x43\x01\xfc\xc3\x77\xbc\xc1\xf9\x90\x81\xef\xc1\x24\xb3\xb8\x07\x6f\x80\x8a\xff\x6d\x6f\x09\x99\x25\x9d\xc7\xc7\x94\xcd\x01\x00\x3e\x7f\x40\x01\x02\xf4\xc5\x09\xa3\x50\xf9\x5e\x53\xf9\xff\x9b\x81\x21\x05\x95\xe9\x59\x33\x69\x5c\x37\x69\x2f\xc8\x85\x72\xb9\x95\x26\x70\x03\x4c\x8d\x6e\xd3\x64\xda\x88\x90\xfa\xe4\x96\x6e\xe0\x1b\x78\xd5\x21\xfa\xfc\x46\x16\xdf\x36\x93\x66\x61\xf0\xdb\x6f\x60\x07\x81\xfd\xf5\x9f\x99\x95\x26\xff\x12\x73\xa6\xc6\xd5\x95\xe1\xda\xde\xed\xc6\xbe\x45\x10\xfe\xe5\x42\x28\x8f\xc8\xda\x0e\x6c\xd7\x45\x2e\x84\xf6\x0a\xcd\x8d\xdb\xc0\xf6\x22\x40\x5f\xd0\x62\xc3\xf1\xe3\xfd\x00\x6e\x04\x56\x20\xf2\x61\x4f\xcb\x7c\x4b\x09\x4e\x08\x15\xc8\x8f\x77\x87\xe2\x89\x01\xa6\x04\x9e\xc8\xbe\xea\x9d\xe5\x46\x12\x48\x49\x1c\xf0\xb8\x78\xe9\xc2\xdf\x30\x4d\x75\x00\xf4\x90\xf3\x2d\xad\x76\x80\x81\xed\x4a\x5a\x11\x0a\xfc\x3c\xf0\x65\x12\x92\x91\x8a\x24\xf0\x40\xcb\x5c\xc4\x89\xb7\x1d\x10\xa1\xf8\xcf\x39\x20\xb7\x62\xfd\x30\x9a\x8c\xaf\x76\x6a\x9d\xe9\xac\xf1\xbc\xfe\xf7\x23\x67\x7b\xfa\x2b\x21\x32\x62\xb9\x51\x88\x68\x2e\xed\x44\x3b\xf8\xb6\x2b\x19\xe9\x23\x24\x65\x8d\xcf\xab\x1d\x2e\xc4\x17\xdc\xf9\xe5\x83\x61\x9a\x9c\x34\x24\xe6\x66\x12\x20\xd5\x8e\xa4\xb4\x27\xe4\x42\x4c\x1c\x5b\x54\xaa\x7b\x02\x0c\xc2\x98\x13\x39\x6d\x9b\x26\xf0\xde\xb9\x75\xbc\x68\x36\xce\x27\x53\x0d\x36\x9f\x9d\xe8\x83\xb2\x48\xbb\x26\xd8\x03\x71\x8c\x59\x69\x32\x83\x85\x6f\xbb\x28\x5c\xa0\xc9\x48\x24\x1e\xc6\xcf\x39\x48\xea\x39\x71\xdc\x18\x56\x07\x5e\x5c\xdb\xe3\x8f\xff\xf6\x98\xeb\xa2\x55\x74\x06\xa3\x08\x01\xb6\xf7\x75\x52\xc7\xd4\xe9\xb9\x22\x74\x12\x13\xc2\xa8\xa2\xa7\xac\xa1\x27\xda\xd0\x53\xa6\xd3\xd3\x69\x4a\x78\xdd\xc2\x8d\x90\xdb\xc0\xdf\xac\xe1\xfd\x57\x01\x05\x15\xfd\x75\x80\x50\x81\x10\xd6\x47\x45\x4b\xcf\x3d\xa4\x31\xaa\x51\xe8\xc0\xa7\xf0\xc7\xe5\xa7\xff\x4c\xe0\x8c\x84\xa5\x0b\x03\xd1\xc8\x71\x1d\x8d\x40\x92\x32\x98\x88\x24\xe4\xfb\x3e\xa5\x24\x01\x86\xf3\x7d\xd6\x86\xa4\x61\xce\xe9\x85\x15\xd3\x6c\x42\x92\x62\xbe\x6e\x02\x27\x02\x95\x78\xc3\xbf\x80\x8a\xe2\x82\xe1\x98\x4b\xb0\x60\x51\xe6\x7b\x4a\x18\x23\x89\xfa\xd6\x30\x4d\x49\x68\x71\xfb\xa6\xe6\x4f\xa9\x32\xe0\x22\x01\xed\x2d\x7e\xc4\x69\xd1\xe4\xc5\xeb\xc0\x5f\xa0\xe5\x26\xe8\xe5\xc6\xf2\xe3\x9e\x99\xd4\xd6\x07\xc2\x9a\x8c\x4d\x4b\xb4\x70\xed\x00\x19\x00\xa0\xf6\xb3\xad\xf0\x7d\x46\xc0\xb3\xef\xd0\x5c\x0e\xa7\x45\x2b\xb0\x09\x6e\xf2\x9d\xdc\x76\x80\x16\x7e\xb0\x94\x23\x6a\x41\x78\xef\xfb\x2e\xb2\xbd\xb9\xf1\x1e\xdd\x3a\x5e\x27\xb7\xb4\xc4\x0a\x2d\xe6\x1d\x2f\xf2\x3b\x8b\x8f\xa6\x99\x75\x98\x30\xc6\xcf\xda\x69\x3b\xe8\x93\xe7\x46\x47\xab\xb4\x98\xb0\x91\x8c\x57\x2a\xa8\x9b\x61\x54\xc3\xf3\x98\x65\x0a\x4c\xad\xee\xac\xba\x66\x77\x42\x51\x81\x80\x1f\x1c\xdb\x5d\xbd\x88\x3e\x20\xaf\x89\x14\x92\x9a\xa4\xe1\x91\xb7\x04\x67\xa5\xc4\xae\xfc\x40\xf9\xc6\xf1\x8e\x09\x26\xb6\x58\xbc\x23\x39\x16\x9a\xcc\x20\xd6\xdc\xc1\x9f\x5a\xe8\xa9\x73\x9f\x26\x62\x71\x55\x1b\x81\x1d\x6a\xe8\xec\xd2\xd0\xb6\x4a\x73\xc2\x62\x9c\x91\xe4\x7e\x9b\x16\x15\xa1\x05\xce\xac\xaa\x94\xe3\x15\xce\xf7\x93\x84\x59\x14\x17\x8f\x64\xcb\x2a\x4c\x2b\xc9\x29\xed\xf3\xdb\xc5\x91\x22\xd1\x84\x91\xe2\x88\x99\x3a\xa2\x62\x5c\xe1\xac\x7c\xb4\x76\x2f\x7b\x42\xa5\xd9\x77\x43\x1c\x35\xf8\x51\x92\xe6\xa4\x60\xdc\x23\x89\xa0\x87\x44\x13\xb3\x15\xb4\xb3\xb3\x74\xc2\x3a\x5b\xde\x96\x65\x69\x4c\x20\x61\x52\x2c\xd3\xde\x08\xb1\xc9\x45\x62\xa5\xeb\xe2\xb2\x60\x15\xc5\x69\x51\x41\x1c\x0b\xb9\x71\x7c\xbc\xa2\x92\xce\x2e\x17\x0f\x4a\xa4\x64\xdb\x38\xb6\x6a\xbc\x1c\xf3\xe1\x4e\x47\x1c\xdc\xc0\xb5\x3a\x34\x4b\x3b\xb2\xaf\x05\xa5\xee\x34\x10\xc2\x4d\x3f\x18\xd4\x25\x52\x22\xd7\x9a\x68\x87\x36\x4d\xa6\x27\x7c\xdc\xba\x4b\xa9\x72\xd2\x59\x62\x89\x0e\x42\xe1\x8f\x71\x18\x1e\x8a\xf4\xfb\x36\x4f\x79\x4f\x84\xc4\x65\x91\xb0\x49\x51\x7e\x9b\x4c\xc1\xec\x1e\xdd\x56\xbc\x1f\x2c\x51\xc0\x89\xbd\xbb\x86\x78\xef\xfa\xfe\xba\x99\x58\x73\xe2\x43\x49\x73\x5c\x4d\xae\xfe\x73\x20\xf4\xe5\xaa\x73\x38\x94\x05\x7a\xd5\xf0\x68\x55\x3c\x94\xa9\x71\x07\x58\xef\x1c\x48\x7a\xb3\xc6\x53\xb5\x13\x11\xee\x9d\x3b\x05\x09\x5f\x66\xb5\x79\x32\x87\x6e\xf3\x34\xed\xad\x24\xfd\x92\x58\xdc\xca\xf0\xe7\x0d\xbc\x73\x95\xa7\xc5\xc0\x1f\xbd\xe7\xda\xc6\xcc\x1a\x4d\x46\xdb\x94\x89\xff\x29\xeb\xcd\x3a\xa8\x6a\x9e\x54\xd8\xe3\xc8\xd5\x7d\xd2\x1d\x12\xd1\x45\xb0\x80\xe2\x91\xb9\xd1\x88\x5f\xf8\x5e\xe4\x78\x1b\xc4\x37\xe2\x89\x30\xd9\x9f\xe3\xac\x94\x38\x8d\xd7\x3b\x81\x9c\xff\xad\x51\xb0\xf2\x83\x3b\x2d\x37\x90\x01\x78\xa2\x50\x70\xfd\xce\xb1\xde\x39\xd7\xb5\x66\xdd\x20\x2e\x86\xda\x33\x34\xfd\xfd\x77\x4a\x1e\xe3\x0c\x33\x36\x9d\xb7\xb0\xd2\x29\xe2\x4c\x9c\x2d\x91\x8b\x22\x74\x06\x6a\x36\xa1\xe3\xdd\x5e\x0a\x16\xa3\x8f\x84\x51\xe8\x74\xe6\x6a\x90\xf8\x39\x98\xf9\x49\x28\x79\xab\xd3\x7f\xb5\xcb\x5b\xb4\xde\xdd\x39\x51\x9b\x31\xf0\xb0\x33\x37\x90\xb7\x9c\x1b\x57\x57\xd0\xa4\xe9\x6b\x77\x7d\x1b\xfe\xe5\xce\x8d\x37\x35\x5a\xd8\xb6\xda\xe1\x6a\x5b\x10\x92\x6c\x13\x5a\xee\xd5\xa6\xda\x52\x3a\x44\x91\xbf\x1a\x4e\xee\xb4\x9a\xb9\x93\x33\xfe\xdf\xc9\x52\xb3\x93\x18\x9e\xe8\x15\xea\x91\x51\x08\xea\xbc\x61\xbb\xf2\x9b\xd4\x95\x4d\x5a\xa2\xb8\xf9\xf3\xd8\x1b\x1d\xfe\x9a\x75\x12\xda\x53\x85\x79\x99\x25\x84\x72\xd3\x14\x37\x7f\x7a\xfe\x67\x81\xc8\xcb\x9b\x55\xdd\xa2\xbc\x0d\x73\xa6\x49\x71\x91\x94\x39\x94\x34\x21\x14\x70\xc6\x4a\xa8\x4a\xd8\x53\xf2\x4c\x8a\x0a\x38\x5e\x9f\x71\x93\xbb\x76\xe8\x48\x7e\x38\x19\xaf\xd4\x06\x50\xd0\x2b\x44\xd6\x81\x7f\x67\xb5\xde\x66\x93\xa1\x12\x83\x36\xb5\x42\x5b\x16\x98\x66\x52\x8a\x1a\x2a\x2b\xcb\xbd\xac\xc6\xd8\x53\xba\x87\xac\x8c\x9f\x48\x22\xca\xa1\x6a\x47\x0a\x31\x85\xe3\x0a\xee\xf9\x1b\x76\x62\x73\x3c\xfb\xa5\x03\x99\xef\x69\x0c\x9d\x81\xdf\x1e\x51\x9b\x26\xd7\xa5\xd6\x83\x41\x5c\x16\xf1\x81\x52\xae\x93\x66\x0a\xe0\x05\x38\xce\x09\x74\x13\x9a\xfa\xec\xbf\x11\xdc\xaa\x4d\x42\x2d\x2d\x34\xf2\x9d\x7b\xbe\xb8\xb2\xd8\xac\x97\xdc\x65\xe1\x47\x67\x0d\xae\xbf\xf8\x88\x96\x27\x49\x6b\xe5\x6f\x3c\x7d\x46\xad\xdc\x71\x99\xc9\x77\xa5\x23\x94\x4d\xa8\xd5\x09\x92\x6f\x46\x36\xed\x22\xfb\xb5\x98\xf5\x37\x7b\xfa\x7f\xc3\x1e\xa2\xf3\x5a\xd4\x9d\x04\xd1\xe4\x50\x3d\x8f\xba\x2e\xbf\x7f\x19\xbd\xad\x62\x4d\x7f\x5d\xc9\x92\x1b\x92\x87\xae\xee\xf6\x9f\x34\x7b\x3e\xd8\xcf\xba\x00\xd3\x97\x37\xc0\x3b\x46\xd2\x0b\xd5\xbe\x0b\x17\xb6\xeb\x5e\xd8\x36\xe9\xfa\xe0\x7c\xda\xe4\x2e\x42\x5e\x04\xbe\x77\x56\xa0\x74\x42\xb8\xe6\x23\xea\x72\x14\xc7\x71\x49\x13\x75\x6d\x28\x3a\x43\x7c\x54\x6b\x4c\x95\x59\x1a\xbf\xcc\x20\x2d\xe2\xec\x20\xe7\xed\xc8\xb8\x5b\x2d\x88\x76\x29\xe3\x1d\xb2\x98\x24\x07\x4a\x38\xcf\x1d\xb2\x04\xee\x09\xd0\x43\x01\x94\x3c\x1e\x32\x4c\xb3\x17\x48\x0b\xc0\x10\xd3\xb2\x80\x7f\x97\xf7\xd7\x67\x91\xbf\xd8\x0c\x23\xd5\x98\x7f\xe4\x70\x7d\x45\x3b\x93\x4a\x6e\xdb\xbb\xd9\x19\x14\xe4\xdb\xf6\x44\x0b\xbd\x4e\x19\x54\x3f\x49\x70\xca\xc3\xa1\x88\xaf\x54\x67\x26\x44\x41\x24\x53\xf3\xd3\xdd\x5c\xed\x06\xb7\xdf\xb2\x97\xe7\xfd\x93\xed\x6e\x50\x08\xad\xce\x1d\x75\x87\x35\x95\x5f\xfa\x1e\x8f\xa8\x2b\xd7\x59\x44\x9d\xab\x62\x58\xfa\x4d\x1c\x46\x51\x6f\x59\xb8\x01\xf4\x65\xe1\x6e\x96\x68\xd9\x4b\x4c\xe7\x7a\x73\xaa\xa2\x07\x32\x37\xd4\xbe\x3b\x0c\xfd\xc9\x77\xed\xc8\x71\x51\x07\x72\x97\x3a\x49\x3a\x42\xfe\xdb\x5a\x9e\x83\x92\x91\x0a\x70\xff\xba\xe6\xe8\x87\x02\xf5\xa5\x0c\x3c\xa6\xcf\xa4\x50\x40\x4c\x0b\xfd\x77\x02\x30\xa9\x38\x06\xdb\x50\xa3\xdd\x09\x1d\x8b\x9f\x5e\x00\x3d\x4a\x7e\x00\x7c\xaf\xa1\x6b\xa0\xf6\x19\x41\x97\xd6\x90\x54\xb7\x05\xcd\xd2\xbc\x10\x69\xee\x0b\x5a\x05\x7e\xba\x7f\x4f\x5a\xa2\xf5\xb0\xf4\x2b\x25\x79\xf9\xac\xbc\xd0\xbf\xae\x55\x4e\x1a\xea\x93\x1f\x79\x59\xf3\xd4\x27\x07\x7d\xae\x15\x72\xbc\x95\x7f\x6c\x22\xb0\x43\x6d\xcb\x4d\x50\x16\xd7\x2b\x33\xed\x51\x1e\x36\x6d\xe0\x78\x33\xed\x3b\x71\x49\x3e\x98\xdc\x0f\x13\xd1\xd9\x64\xf4\x0b\x7e\xbc\x70\x46\x61\x72\x76\xdb\x66\xf4\x82\xed\xb8\x92\x6e\x2e\xd0\xe0\x46\xa6\x8b\xaf\xfd\xcc\xa0\xdf\x3d\xe9\x14\x0a\x47\x6c\x6b\x87\xf5\xf1\x3e\xff\x87\x21\x73\xe3\xbf\x03\x00\xd6\xcf\x11\x85\x7d\x24\x00\x00"),
+		},
+		"/6_lifecycle_policy.down.sql": &vfsgen۰CompressedFileInfo{
+			name:             "6_lifecycle_policy.down.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 6352,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xd4\x59\x6d\x6f\xe3\x36\x12\xfe\xee\x5f\x31\x28\xb2\x8d\xdd\xc6\x02\xf6\xee\xa3\xeb\x00\x5a\x9b\xc9\xea\xaa\x48\x3e\x59\xde\xdd\x5e\x51\x08\xb4\x34\x8e\x79\x91\x44\x1d\x49\x27\x9b\x7f\x7f\x20\x29\x5b\x94\x1d\x67\xd3\x05\x7a\xc0\xe9\x53\xcc\x97\xe1\xcc\x33\x0f\xe7\x85\x99\x27\xf1\x02\x3e\x05\xe4\x33\x04\x37\x40\xbe\x04\xcb\x74\x09\xcb\xd9\x47\x72\xe7\x67\x41\x74\x13\x7b\x25\xdb\x60\xfe\x9c\x97\x98\x35\xbc\x64\xf9\xf3\x64\x60\x76\xdc\xac\xa2\x59\x1a\xc4\xd1\xe9\xae\x45\x12\xdf\x79\x02\x25\xaa\xac\x42\x25\x58\x9e\x1d\x8b\x18\xa6\xe4\x4b\x3a\x7a\x9b\xa0\x6f\x89\xb9\x82\x20\x4a\x49\xf2\xc9\x0f\x5f\xfa\xeb\xdb\x87\xcc\xfc\xd4\x0f\xe3\x5b\x4f\xec\xea\xb3\x7a\x0e\xc6\xe3\x42\xf0\x06\xf2\xed\xae\x7e\x90\xb0\x11\xbc\x02\xab\x93\x04\x45\xd7\x25\x4a\xa0\x75\x01\x05\x96\xa8\x10\xd4\x16\x81\x36\x8d\xe0\x8d\x60\x54\x21\x48\x14\x0c\xa5\x37\x98\x25\xc4\x4f\x09\xc4\x09\x24\x64\x11\xfa\x33\xd2\x69\x75\xa4\x8b\x3e\x6c\x6f\xb4\x3d\x73\xd8\xfe\xaa\x69\x85\x60\xcd\xe6\x65\x81\x22\x53\x5b\x5a\x43\x1a\xdc\x91\x65\xea\xdf\x2d\xd2\x7f\x8d\x06\x00\x00\x09\x49\x57\x49\xb4\x84\x0f\x71\x1c\x12\x3f\x32\x63\xfe\x12\x2e\x36\xbb\x3a\xbf\x18\xcc\xc9\x2c\xf4\x13\x62\x46\x5b\xb9\xc6\x0a\x88\xfc\x3b\x32\x31\xc3\xf9\x16\xf3\x87\x4c\xb1\x0a\x5d\xe1\x76\x4e\x8f\x66\x05\xab\xb0\x96\x8c\xd7\x19\x2b\x34\xda\x76\xaa\xa4\x6b\x2c\x33\x2a\x04\x7d\x06\x56\xab\xdf\xff\x98\x0c\x3e\x90\xdb\xc0\x2a\xb0\x24\x21\x99\xa5\x16\x30\x63\x88\x19\x0d\xa2\x34\x86\x65\x9a\x04\xb3\xb4\xa7\x8c\x99\xbc\x49\xe2\xbb\x63\x70\xee\x51\x65\x5c\x64\xb9\x40\xaa\x30\x73\xb7\x18\xa1\x2e\x52\xda\x75\xce\xc9\x0e\x62\x3f\x1f\x18\x02\x97\xef\x61\xcb\x77\xe2\xb2\xd3\xa6\x33\xbe\xdd\x3f\x1e\xdf\xa2\x32\x7e\xd5\x83\x70\xb0\x1d\x58\x01\x1b\x2e\x5e\x98\x71\x8f\x2d\x3c\x56\x9c\x98\x7a\x02\x62\x67\xaf\x39\x59\xe6\xb4\xc4\x62\x9d\xe5\x54\xd1\x92\xdf\x7b\xdb\xe7\x06\x85\xf5\xd2\xb6\x15\x16\x91\x04\xfe\x11\x07\xd1\xcb\x1b\x3a\x25\x0b\x88\x23\x18\x16\x8e\x08\xed\xb3\x29\x6c\x3d\x56\x58\xba\x7c\xfe\x48\x12\x02\x5b\x4f\xe6\x5b\xac\xa8\x25\xd9\x14\x2e\x5b\xe0\xe7\x7e\xea\x5f\x82\x1f\xcd\x61\xeb\x75\x38\xc3\xf4\xd4\x5d\x71\x32\x27\x09\x7c\xf8\xcd\x98\x0c\xfe\x72\x66\x46\xc3\xe0\x2e\x48\xe1\x7d\x0f\x4b\x0a\x8a\xdd\x6f\x95\xeb\x92\x21\xf9\x32\x0b\x57\xcb\xe0\x13\x19\xc1\x1a\x73\xba\x93\x08\x4f\x08\x4f\xb4\x56\xa0\x38\x3c\xd4\xfc\x49\x03\xdd\x0a\xc1\xaf\x34\x57\x90\xef\xd4\x98\x6f\x36\xf0\xb4\x45\xa1\x2f\x1e\xab\xef\x25\x3c\xb1\xb2\x84\x35\x82\xbe\x45\x0d\x16\xae\x2b\x7a\x48\xb1\x5a\xa1\xa8\x69\xe9\x29\x6e\xc7\x15\xad\x9a\xa1\xa0\xf5\x3d\x66\x58\x17\xa3\xce\x67\x9d\x96\xdf\xf0\x92\xb9\xac\x90\xbf\xc9\x41\x66\x6d\x96\xf3\x5a\x2a\x41\x59\xad\x20\xcf\x8d\xa3\x72\xcf\x78\x27\xcf\xdb\x15\xac\x18\xbd\x49\x5e\x47\x26\x59\xb2\x1c\xa1\x90\xd6\xef\xf2\x20\xef\x68\xc5\x41\xf2\x78\x7c\x30\x1a\x98\x04\xfc\x9a\x97\x3b\xc9\x1e\x11\x24\xd7\x98\x4a\x33\xf8\x88\xe2\xd9\x00\x0c\xbf\xf4\xbc\xf6\xb4\x65\xf9\x56\xaf\xa0\xa5\xe4\xdd\x5e\x97\x58\x85\xf4\x7a\xe1\x62\x7a\xca\x7e\x43\xaf\x42\x7a\x9d\x22\xbf\x4c\xcf\x7b\x6b\x57\xb3\xaf\x59\xc5\x72\xc1\x25\xe6\xbc\x2e\xe4\xb0\xd3\x68\xd4\x67\x62\x27\x70\x4e\x5e\xe4\x63\x70\xe3\x9a\x13\x2c\x21\x5a\x85\x21\xa4\x1f\x89\x0d\x5a\x5d\x34\x85\x0d\x2d\x25\xda\x38\x47\xa2\x39\x04\x37\x07\x46\xe7\x5b\x5a\xe7\x28\x81\x1a\x12\x52\x1b\x2a\x74\x50\x01\xba\x51\x68\xe3\x83\x49\x20\x0d\xd7\x8e\x36\x04\xdd\xd2\x47\x9b\x2a\x2a\x2e\x15\x48\x56\xb1\x92\x8a\x56\x9e\xcd\x18\xa0\x38\x3c\x69\x69\x4c\xee\xb9\x7c\xa5\x5d\xb2\x61\x42\x2a\xd8\xb0\x52\x8b\x5e\x3f\x03\x2d\x4b\xd8\xef\xd0\xcb\x8d\xe4\x35\x62\xdd\xbb\x01\xe3\xf1\x7a\xa7\xec\x02\x2a\xb0\xbe\x54\xc0\x6a\xfb\xd3\xca\xb3\xea\xd6\x85\xd6\xa9\x86\x8a\x3e\x20\xc8\x9d\xb1\x07\x9f\x7b\x3b\x10\x0a\xaa\xa8\x44\x65\x91\xf8\x42\x66\xab\x94\xe8\x30\x58\x51\x35\x34\x63\x17\xff\xd9\xa1\x78\xbe\x38\xe0\xf7\x39\x48\x3f\x42\xc3\x15\xd6\x8a\xd1\xb2\x7c\xce\x4c\x7e\x6b\x55\xf6\x97\x30\x3c\xac\x74\xa3\x26\x93\x8a\xd5\xb9\x6a\x4d\xdb\x47\xc8\xfd\xe7\x66\x06\x1d\xa0\xbc\x77\xef\x2f\x82\xde\x0a\xcb\x3d\x13\x96\x7f\x81\x77\x7f\xbb\x08\x7b\xb3\xe4\xcb\x8c\x2c\xd2\xbf\xfa\xe0\xeb\xa9\x39\xd9\xb0\x7b\xaf\xc9\xdf\x1d\x4d\x46\x57\x90\xf3\x7a\xc3\x44\x85\xc5\x9b\x50\x79\x45\xa7\x33\x00\xbf\xa0\x5a\x14\xa7\xfb\x22\xa8\x7f\x88\x7b\xd2\xfb\xd3\x19\x73\xcc\x89\xed\x60\xf8\x90\xe1\x57\x26\x95\x3c\xdd\xd4\x86\x80\x6e\x89\x77\xb0\x01\xa6\xe7\x94\x76\xd6\x1c\xa0\xbb\x9e\xf6\xb1\x3b\x7c\xe3\xb1\x4e\x15\x36\xf4\xd2\xa6\xd1\x77\xfd\x67\xa8\xb8\x40\x28\xd9\x03\x96\xcf\xa0\xf4\x9d\xa9\x0b\x90\xbc\x42\x1b\xc2\xa4\xa2\x42\xe9\x3f\xa8\x02\xa4\xa2\x64\x28\x95\x39\xe5\x54\xfa\x21\x92\xe8\xe9\x43\x4e\xeb\x7d\x6d\x40\xe9\x4d\x8c\x5c\x1f\xdb\xc2\xb0\x38\xe3\xdc\x39\x09\x49\x4a\x6c\x55\xe9\x80\x9b\x2d\x49\x12\x90\xe5\x31\xbf\x2c\x9e\xa6\xe8\x82\xe1\x31\x2b\xac\x8b\x5e\xa4\xd4\xc8\x91\x61\x03\x5a\x10\xdd\x02\x2b\xae\x6c\xd1\xd6\x79\xae\x5b\xd8\x4a\xf7\x93\xc4\xff\x6d\x7f\xd4\x3c\x58\xa6\x41\x34\x4b\x61\x57\xd7\x28\xd5\xd0\x6e\x1e\x01\x95\x56\x8e\x4b\x4d\xa3\x4c\xdf\xf6\x91\x1b\x20\xae\x7a\x15\x84\x5b\xd1\x5e\x39\x35\xd8\xc8\xe6\x60\xa7\xb4\x3c\xc4\xdd\x1a\xb1\x30\x61\x72\x8d\x40\x41\x62\x43\x05\x55\x08\x46\xba\x89\x65\x35\x57\x40\x61\x96\x12\x90\xac\xce\xd1\x66\xb3\xc3\xae\x1f\x24\xe2\x0f\xad\x28\x1d\xd7\x5a\xf7\x08\xfe\x24\xf7\x6a\x03\x5d\xf3\x47\x04\x7a\x18\xf0\x5e\x8a\x7a\x6e\xc0\x33\xc1\xee\xc8\x03\x16\xa4\xb3\x97\xfa\x04\xb8\x03\x78\x2d\xc8\x17\xef\x3b\x80\xe5\x70\xbf\x7c\xf4\x57\x5e\x6d\x97\x7d\x7b\x7e\xbd\x7e\xc5\x7b\x8b\xbc\xd6\xe4\x1f\x7f\xb4\xf4\xf9\xdd\xfe\xf6\xf6\xba\xff\xf1\xa7\x6f\xd1\xe0\xe8\xba\xbc\xd4\x1a\x18\xe9\x83\xd7\x6e\xca\x4f\x2f\xde\x90\x96\xc4\x93\xf3\xe4\x1c\xc1\x6a\xa9\xef\xcb\x09\x0b\x17\x24\xb9\x89\x93\x3b\x93\x6a\xf7\xbd\x5a\x57\x24\x4f\xaf\xfb\x0c\x77\x0a\xec\xe9\x75\xbf\xc0\x76\xe9\x3f\xbd\xee\xfe\xb6\x5a\xd9\x1b\x0b\x4a\xec\x70\x32\x20\xd1\x7c\xd0\xb6\x72\xa1\x1f\xdd\xae\xfc\x5b\x02\x8b\x70\x71\xbb\xfc\x67\x08\x9f\xe2\xd0\x4f\x83\x90\x4c\x06\x7f\xa2\xdf\xbc\x3f\xf4\xd8\x52\x9f\xa9\xb2\x1a\xf7\xd0\x18\x93\x86\xa3\xc1\xbe\xa3\x5c\x92\x34\xbe\x39\xde\x6f\xf7\x0e\x74\x87\x79\x71\x1c\x3d\x2a\xef\xa7\xc1\x4b\xb9\xb3\xbf\x17\xaa\x23\xb7\xbd\x48\xe2\x3d\x7f\x8d\xa0\xde\x8c\xdc\xf2\xa7\x3d\xfc\x5d\x9f\x33\xbd\x6e\x2f\xe8\xe5\xbb\xc0\x7b\x17\x5c\x5e\x1d\x43\x5e\x39\x0d\xcd\xe8\xea\x94\x93\xfb\xcf\x75\x4d\x14\x7f\x1e\x8e\x60\x7c\x1e\x43\xab\x47\x26\xd0\xa4\x36\x5e\x67\x0d\x0a\xc6\x8b\x61\xe5\xb9\xad\xe9\xa8\x63\xb4\xa9\xbf\x0b\x5e\x01\x17\x05\x0a\x5b\x48\x2b\x0e\x8d\xc0\x47\xac\x95\x49\x56\x8f\x54\xed\xbb\xca\xe3\xf2\xb6\xe0\xd5\x70\x34\xb8\x70\xb8\xa0\x79\xb0\x4c\xfd\x0f\x67\x58\xb0\x48\xe2\x19\x99\xaf\x12\xd2\x7b\x67\x71\x09\x3c\x6a\x5d\xe9\x3e\x14\x08\x48\xc8\x2c\x4e\xe6\x6e\x47\x3f\x1e\x17\x1c\x78\x8d\x50\x72\xde\xc0\x13\x53\x5b\x90\x0f\xac\x81\x92\xe7\x0f\x58\x74\xd5\xa4\x5e\xa2\x79\x05\x6b\x3d\x23\x5f\x31\xee\x26\x4e\x40\x40\x10\x1d\xb3\xe8\x75\x0e\xbd\x81\xbf\x00\x00\x61\x1c\x2f\x1c\xd0\xb5\x2e\x7b\x3d\xa4\x0e\x09\xf9\x4e\x08\xad\x93\x03\x05\xf0\x1a\x24\xad\x10\xba\x26\x17\x9c\x5b\xff\x9d\xe4\xae\x6c\x4b\x26\x3c\x37\x59\xc6\x09\x44\x31\xfc\x4a\x7e\x83\xd5\x62\xae\x5d\xb6\xfc\x35\x58\x40\x18\xcf\x7e\x25\xf3\xc9\xe0\xb0\x6e\x16\x47\x69\x10\xad\x88\x16\x15\x99\x78\x7f\x13\xaf\x22\x77\xc5\x5e\xb9\x6f\x3f\x2a\x09\x97\x91\x57\xf0\xdd\xcc\x16\x7d\x66\x4f\x1c\x65\xef\xee\x82\xb4\xeb\x99\xb4\x07\x5a\x4d\xff\x77\x9e\xfe\xff\xc0\xc3\x94\x20\xb5\xfb\x86\x28\x69\xd5\x94\xa8\xfb\xf1\x86\x09\x2c\x74\xab\x67\xd2\x0f\x1c\xce\x03\xfe\x88\x42\xb0\x02\xdb\x37\xc9\xee\x79\xc4\x1a\x74\x68\xf7\xe0\x01\x1b\xf5\x2a\xec\xbd\xe0\xf4\x3d\x9c\x3e\x0f\x92\xcd\x99\xdf\x08\x87\xa6\xf3\x8e\x53\xd3\x7d\x9f\xba\x70\xe6\x87\xe1\x89\xff\x0c\x52\x27\xd2\x5b\xb4\x8e\x7c\x70\x0c\x39\xd1\x37\xe6\xe2\x02\x8e\xf3\xe7\x64\xa0\x5d\x44\xa2\x14\xe2\xe8\x4d\x81\x32\x58\xc2\xa5\x1e\x91\xa6\xc5\x01\x9a\xe7\x5c\x14\xba\xb3\x50\xfc\xd0\x2b\x3b\x0e\xb3\xef\xca\x57\xc0\xea\xbc\xdc\xd9\x75\x5b\x3c\xef\x56\x0f\x52\x5d\xb1\x36\x82\xe7\x58\xe8\x6e\x5c\x6e\xf9\xae\x2c\x60\x8d\x20\x76\x35\x08\xbc\xdf\x95\x54\x94\xfa\xb1\x15\x28\xe4\x82\xd7\xf0\x6f\xbe\xbe\x9c\x0c\xde\xfa\xea\xed\xb8\xc9\xb1\x2b\xeb\xf2\x5d\xef\xa9\xde\xe4\x94\xf3\xc2\xce\xfc\xa7\xc0\xae\x3a\xdd\x96\xc4\x61\xb8\x5a\xc0\xcc\x5f\xce\xfc\x39\x99\x0c\xdc\xc2\xae\xd9\xad\x4b\x96\x7b\x8d\xe0\x55\xc6\x6a\xa9\x68\x59\x9a\x24\x91\xb1\x7a\xc3\x5b\xc6\x3d\xe0\xb3\x7e\xa5\x14\xbc\x2c\x77\x4d\x5b\x5a\x5d\x4e\x06\xff\x1d\x00\x44\x7d\x86\x43\xd0\x18\x00\x00"),
+		},
+		"/6_lifecycle_policy.up.sql": &vfsgen۰CompressedFileInfo{
+			name:             "6_lifecycle_policy.up.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 16222,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xcc\x3b\x5b\x73\xdb\xba\x99\xef\xfc\x15\xdf\x74\x9c\x4a\x4a\x29\xb5\x39\xed\xee\x43\x1c\x79\x86\x91\x68\x87\x7b\x64\xd1\xa5\xa8\x9c\x64\x3b\x1d\x0d\x4c\x42\x12\xd7\x14\xc0\x05\x20\x3b\xfe\xf7\x3b\x00\x78\x01\x2f\xba\x25\x39\xdd\xf2\xc9\xc6\xf5\xc3\x77\xbf\x69\x38\x84\x59\xb2\xc6\xd1\x6b\x94\x62\xc8\x68\x9a\x44\x09\xe6\xef\x41\x6c\x31\x30\xf4\x02\x1c\xed\xb2\x14\x73\xa0\x6b\x40\xb0\xc3\x82\x25\x11\x20\x86\x81\xd1\x34\xc5\x31\xec\x33\x48\x88\xa0\xd6\x70\x08\x68\xb3\x61\x78\x83\x04\xe6\x80\x04\x20\x88\x28\x62\x1c\x33\x39\x8f\xd9\x33\x4a\x6d\x78\xd9\x26\xd1\x56\xed\x7e\xc2\x99\x80\x94\x92\x0d\x66\x20\xb6\x88\x94\xd7\xc5\x48\xa0\x91\x35\x09\x5c\x27\x74\x61\x31\xf9\xe4\xde\x3b\xe0\xdd\xc2\xdc\x0f\xc1\xfd\xe2\x2d\xc2\x45\x3e\xb8\x0a\xfc\xd9\x6c\xf9\x70\x6d\xdd\x05\xce\x3c\x84\xe5\xc2\xb9\x73\xc1\x9f\x17\x5b\x6a\x8b\x20\xf4\x21\x63\x74\xb7\x62\x18\xc5\x98\x15\x7b\x16\xee\xcc\x9d\x84\x72\x93\x33\x9b\x41\xe8\x7c\x9c\xb9\x0b\xf0\xce\x3c\xc2\x99\x85\x6e\x00\x53\xf7\xd6\x59\xce\x42\x78\x08\xbc\xcf\xde\xcc\xbd\x3b\x72\x40\xf3\xce\xfc\xbe\x6e\xd0\xce\x7b\xce\x0b\x4b\x44\xf3\x39\x36\x78\xf3\x85\x1b\x84\x36\x2c\x1f\xa6\x4e\xe8\xda\x30\x75\x67\x6e\xe8\x5e\xf8\xcc\xe2\xe8\x1f\x7a\xe6\x31\x58\x1a\xcf\x2f\xee\xb3\xf4\x0e\xf0\xe6\x72\x66\xff\x98\x26\xd1\x48\x2d\x48\x08\x17\x28\x4d\x91\x48\x28\x59\x25\x64\x4d\xfb\x4f\xf8\xd5\x86\x67\x94\xee\xf1\x00\x3e\x3b\xb3\xa5\xbb\xb0\x00\x00\xfa\x3d\xc9\x99\xfb\x0c\x78\xb4\xc5\x3b\xd4\xb3\xa1\xf8\x7a\x35\x48\x7b\x83\x6b\xab\xe0\x33\x05\x4c\xf1\x90\x89\x13\x3a\x33\xff\x6e\x94\x16\x42\xb1\x52\x42\xf1\x0a\x7d\x75\xbe\x16\x81\x15\x41\x3b\x0c\xa1\xfb\x45\x21\xe5\xde\x09\xbe\xc2\xaf\xee\x57\x5b\xad\x60\xe8\x65\xc5\xb0\xc0\x44\xc2\x2a\x5f\xe2\x06\x9f\x9d\x99\x62\xe1\xf9\x72\x36\xcb\x17\x29\x20\x57\x85\x6c\x9c\x58\x76\xf2\xb8\xe1\xb0\x29\xaf\x8f\x78\x4d\x19\x06\xb1\x4d\x38\x88\x64\x87\xeb\x42\x6b\xab\xbd\xb0\x27\x22\x49\x95\xec\xad\x13\xc6\x05\xb0\x3d\x29\xaf\xc5\xf1\x6a\x9f\xad\xf4\x8a\xd0\xbb\x77\x17\xa1\x73\xff\x10\xfe\xb7\xbe\x2f\x45\x5c\xac\xd8\x9e\x1c\x98\xc1\x8c\x51\xa6\xd0\xa3\x07\x27\x9f\xdc\xc9\xaf\xd0\x6f\x3e\xfa\xa6\x7a\x4e\xef\x2f\xbd\x41\xd7\xda\xea\xe5\x37\x75\xc4\x0e\x2c\x49\xc0\xe1\x50\xbe\x39\xda\xee\xc9\x13\x57\x2f\x8c\x19\xcd\x32\x1c\xb7\x9e\x9f\x2b\xb4\xf2\xb4\x0c\xb3\x84\xc6\x52\xab\xc9\x71\x4d\x54\xdb\x1a\x0e\x1f\xf7\x02\x08\x7e\xc6\xac\x44\xa4\xd8\x4a\x6d\xc6\x30\x10\x2a\x2a\x04\xc2\x2b\x16\x23\x8d\xc4\x97\x2d\x26\x72\x72\x9b\x90\x0d\x44\x88\xc0\x63\x09\x46\xa9\xc9\xfc\x00\x02\xf7\x61\xe6\x4c\x5c\xb8\x5d\xce\x27\xa1\xe7\xcf\x9b\xfc\xb6\xc1\x62\x95\x33\x97\xdc\xbd\xd2\x8f\x5a\xd1\x34\xc6\x6c\x25\x75\x64\xbf\xc9\x7a\x03\x2b\x70\xc3\x65\x30\x5f\x98\x54\xb0\x9c\x05\x5c\x5d\x59\x00\x50\x28\x9b\x89\xb3\x70\xad\x42\x08\x7e\xfb\xe4\xce\x21\x1b\x99\x47\x79\x0b\xfd\x8e\xf0\x93\x3b\x2f\xd7\xc9\x8f\xd0\x97\xfe\x00\x86\x47\x00\x55\x30\x56\x34\x59\x69\xac\xf6\x4f\x3e\xc5\xbc\x7f\xd0\x84\xad\xc9\x7c\x07\xe1\x93\xa3\xe5\x80\x3b\x33\x5e\x29\xbf\x99\xeb\x2c\xc2\xfe\xbf\xe8\x0d\x76\xed\x6a\xfd\xb5\x5e\x52\xbd\xd4\x9d\x4f\xd5\xdf\xb7\x81\x7f\x0f\xfd\x9c\x4e\xef\x06\xe0\x2c\x80\x12\x6c\x69\xf0\x6f\x43\xf8\x2f\xdf\x9b\x9f\xd4\x4b\x19\xf8\x73\xe8\xd7\x69\x3a\x86\x0b\x69\x70\x75\x65\xcd\x9c\xf9\xdd\x52\xda\x9d\xc5\xdf\x67\xb0\x50\x3a\xb1\xb0\x2d\xee\x17\x77\xb2\xd4\x8a\xfb\xfb\xd9\x57\xb1\x6c\xcb\xe0\x59\xc3\xa1\x5c\x5f\xc8\xf0\x9a\xd1\x5d\x2e\x8e\x1c\x04\x7a\x94\x12\x88\x48\x0c\x31\x4e\xb1\xc0\x4a\x58\x51\x96\x31\x9a\xb1\x04\x09\x0c\x1c\xb3\x04\xf3\x91\x35\x1c\xea\xbf\x80\x8b\x24\x4d\x81\xe1\x35\x66\x98\x44\x52\x11\xbc\x6a\xc9\x57\xea\x84\xd7\x05\xbe\x74\x45\x2e\x91\x53\xf5\x3a\x93\x77\x78\x4b\x30\x6d\xa8\x9e\x6d\xca\xa6\x66\x80\x42\x68\x3f\xfa\xfe\xcc\x75\x34\x4b\x4b\xa1\x5d\xef\x49\x74\x65\x4d\xdd\xc9\xcc\x09\x5c\xd3\xd6\x28\x34\xc0\xdc\xb9\x77\xaf\xd5\x70\xb4\xc5\xd1\xd3\x4a\x29\x75\xe3\x70\x3d\x27\x47\x57\x71\xb2\xc3\x84\x2b\x4b\x19\x4b\x15\x7b\x9d\x6b\xe6\x47\x9c\xae\x10\x63\xe8\x15\x12\x22\xfe\xf1\xcf\x6b\xd3\xc4\xac\x93\x54\x60\xad\xb3\xe1\xfd\x18\x7a\xbd\x6b\xeb\xa3\x7b\xe7\xcd\x4d\x4d\xa2\x00\x51\xcf\x54\xa3\xca\x48\x2f\xc2\xc0\x9b\x84\x35\x50\x2b\xd6\xee\xe0\x11\xca\x56\x11\xc3\x48\xe0\x95\xb9\x45\x1d\x6a\xe2\x51\x2a\x77\xe3\x66\x03\x9f\x7f\x32\x8c\xc6\x3b\xd8\xd2\x3d\xeb\x55\xd0\x54\xa8\xc9\xf7\x0f\x87\x77\x58\x28\x92\xcb\x41\x28\x31\x03\x49\x0c\x6b\xca\x3a\x66\xcc\x6b\xe3\x51\x12\xb7\x9e\xda\x42\x71\xf5\x5e\x75\x33\x8f\x50\x8a\xe3\xc7\x55\x84\x04\x4a\xe9\x66\xb4\x7d\xcd\x30\xd3\x34\xdc\xe6\x87\xcd\xdd\x40\xcb\x76\xe7\x86\x0a\xc8\x58\x89\x76\x6c\x1c\x21\x29\x3a\x86\xed\x28\x89\x35\x33\xfd\xf6\xc9\x0d\x5c\xd8\x8e\xb4\xb7\x53\x08\x7f\xe1\xea\x4c\x9d\xd0\xe9\x81\x33\x9f\xc2\x76\x54\xe1\x19\xc6\x6d\x72\xf9\xc1\xd4\x0d\xe0\xe3\x57\xf5\x64\x70\x16\x13\x35\x3a\xf3\xee\xbd\x10\xde\xd5\x70\x89\x40\x24\x9b\xad\x30\x49\xd2\x77\xbf\x4c\x66\xcb\x85\xf7\xd9\x1d\xc0\x23\x8e\xd0\x9e\x63\x78\xc1\xf0\x82\x88\x00\x41\xe1\x89\xd0\x17\x89\xe8\xfc\x10\xfc\x0d\x45\x02\xa2\xbd\x18\xd2\xf5\x5a\x5a\x4f\x6d\xa7\xc9\x86\xc3\x8b\x14\xde\xca\x7c\x9a\xa4\xa8\x61\x4a\x79\x10\x04\xa5\x23\x41\xf5\xb8\x40\xbb\xac\xcf\x10\xd9\xe0\x15\x26\xf1\xa0\xa2\x59\x05\xe5\x09\x2a\x29\x51\x86\xe8\x2c\x02\xa9\xb5\xab\x88\x12\x2e\x18\x4a\x88\x80\x28\x52\x84\x8a\x46\x8a\x3a\x51\x94\xaf\x48\xe2\xc1\x59\xe7\x55\xcc\xc4\xd3\x24\xc2\x10\x73\x4d\x77\x5e\x9e\xd7\x58\x51\x9e\x3c\x1c\x96\x8f\x86\x84\x03\xfe\x16\xa5\x7b\x9e\x3c\x63\xe0\x54\xfb\x3e\x72\xf0\x19\xb3\x57\x85\x60\xf8\x50\xa3\x9a\x0e\xc7\x12\x0e\x28\xe5\xb4\xda\x6b\x32\x56\xcc\x47\x35\x65\x32\x6e\x73\xbf\x62\xaf\x98\x8f\x2a\x40\x3e\x8c\x0f\x53\x6b\x4f\x92\x6f\xab\x5d\x12\x31\xca\x71\x44\x49\xcc\xfb\x15\x44\x83\x3a\x27\x56\x07\x4e\xdd\x4e\x7e\xf4\x6e\xcd\xe7\x74\xba\x09\x5a\xd7\xc2\x1a\xa5\x1c\x6b\x75\xe7\xce\xa7\xe0\xdd\x56\x27\x08\xba\x62\x78\x13\xa5\x88\xf3\xfe\x9a\xb2\x1d\x12\xfd\x7a\xa0\x30\x7a\xe3\xf5\xec\x9a\xc0\x0c\x06\xea\x32\x3f\xec\xb8\xb0\xae\x4d\xdf\x8f\x21\x3f\xf3\x4a\x8f\x5c\xd5\xfc\x04\x89\x39\x23\xb2\xed\xb7\x9d\x88\xc2\x35\x68\xcf\x98\xfa\xb5\x00\xf4\xdd\x95\x57\x00\x80\xbf\x25\x5c\xf0\xf6\x36\x4d\xd6\xda\xa2\x91\x36\x9d\x9a\xbc\x19\x55\x7e\x10\x4a\xd3\x57\x6d\xc7\x73\x0b\x5b\xae\x69\x1f\x99\x13\xa5\x36\x51\xb9\x3a\xc5\xc3\x1b\x28\xec\x20\xc6\x70\x18\x6d\x11\x89\xb0\x76\xe4\x95\xdb\x2d\xb5\xb3\xd4\xf0\x80\xd6\x02\x6b\x65\x2d\x81\x82\x8c\x4a\xa9\x53\xda\x62\x8b\x9e\xb1\x9a\xd8\x51\x2e\x80\x27\xbb\x24\x45\x2c\x3f\x4f\x03\x0d\x82\xc2\x8b\x3c\x2d\xe1\x85\x62\xb1\x81\xd3\x3c\xe4\xc9\x29\xf5\xf8\x0a\x28\x4d\xa1\xd8\x21\x97\xab\x93\x1f\x31\x26\x35\x75\xa4\x43\x84\x22\x28\x20\x3d\x01\x09\xd1\xff\xea\xf3\x34\xb8\x24\x96\x30\x11\xd8\xa1\x27\x0c\x7c\xaf\xde\x83\x5f\x6b\x3b\xb0\xca\x75\x70\x2c\x34\x26\x72\x0f\x2b\x67\x17\x35\x76\xf5\xbf\x7b\xcc\x5e\x2b\x96\xf9\xcd\x0b\x3f\x1d\x22\x10\x38\x4d\xfe\x29\x4c\x58\xc2\x45\x42\x22\x01\xdd\x14\x34\xd9\x48\x5a\x0b\xc5\x44\x56\x9b\x63\x94\x8d\xfc\x00\x6f\x7e\xb9\x9a\xd5\x66\xdd\x2f\x13\xf7\x21\xfc\xbd\x2f\xbe\x19\xab\x9b\x95\xc0\x14\x90\xfc\xd5\x80\x64\x60\x43\x44\xc9\x3a\x61\x3b\x1c\x9f\x85\x95\x23\x30\x1d\x40\x70\x07\x68\x3f\x24\xba\xad\xb7\x83\xe2\x87\x13\x82\x6b\x2c\xb9\x4c\x6c\x2b\xd4\xdd\x8c\xeb\xb8\x2b\xbf\xe1\x50\xda\x6d\x6d\x07\x51\x96\x49\xc5\xfb\x27\xd8\x51\x86\x21\x4d\x9e\x70\xfa\x0a\x42\xca\x0c\x89\x81\xd3\x1d\xd6\xf6\x84\x0b\xc4\x84\xfc\x03\x09\xc0\x88\xa5\x09\xe6\x42\xdd\xd2\x3e\xbd\x54\xeb\x72\xba\x74\x30\x4e\x2b\x12\x78\xf3\xb7\x2b\x6e\x12\x5a\x47\x02\xf1\x01\x0a\xe7\x19\x26\x15\x46\x18\x18\x5e\x2d\xdc\xc0\x73\x17\x4d\x26\xd3\x48\x55\x4e\x72\x19\x86\x55\x28\x53\x74\xea\xe4\xab\x81\x71\x86\x36\x31\xde\xfc\x0e\x92\xd8\xd6\x4e\xb6\x01\xaf\xd5\x60\x07\x27\x08\x9c\xaf\xc5\x55\x53\x6f\x11\x7a\xf3\x49\x08\x7b\x42\x30\x17\x7d\xbd\x79\x00\x88\xeb\x73\x4c\xfe\x54\xc0\xd4\xdf\x3e\x30\xb5\x44\x5d\xbf\x9a\x11\x88\x6d\x78\xc5\x76\xdd\x40\x0d\xb4\x93\x64\x44\x06\xa5\x2e\x26\x18\xc7\x4a\x75\x3e\x62\x40\xc0\x71\x86\x18\x12\x18\xd4\x65\x4a\xbf\x11\x2a\x00\xc1\x24\x74\x81\x27\x24\xca\x53\x2d\xe5\xae\x3f\x70\x8c\xff\x60\xa4\xa6\x72\x6a\x31\xfa\xc2\x8b\x57\x00\x7a\xa4\xcf\x18\x50\x39\x30\xea\xd2\x84\xa6\x12\x54\x0a\xb0\x41\x10\x8d\xb3\x83\x82\xde\xc2\x63\x89\xcb\x1c\xe7\x57\xef\x2a\x7c\xf3\x7e\xb1\x7c\xf0\x7b\x8a\xbb\xc9\x8c\x05\xbb\x1d\x17\xfb\xda\xa2\x51\xfe\xe4\x3f\xfe\x51\x73\xd3\x3f\xf4\xff\xa3\x02\xf6\x7f\x5e\x6a\xa2\x07\x56\x43\x7a\xba\x62\x37\x75\xba\x75\x4c\x70\xde\x76\x0a\x4c\xce\xd3\xd7\x87\x79\x75\x00\xcb\x85\x14\x9f\x16\x17\x3e\xb8\xc1\xad\x1f\xdc\x83\x91\x4f\xe8\x57\x51\xcc\xf8\xa6\xce\xf0\x46\x04\x34\xbe\xa9\x47\x40\xa6\x34\x8c\x6f\xaa\xbf\x35\x54\x5a\x80\x41\xb0\x3d\xbe\xb6\x64\x66\x26\x8f\xc4\xcb\x7c\xc8\xc3\xec\xe1\x4e\xe6\x44\x3e\xfb\x33\x27\xf4\x64\x56\xe4\xbb\xd2\x7a\x5c\xde\x29\x56\x04\x17\xa8\x51\x4f\xea\x57\x59\xbc\x85\x1b\xfa\xb7\xcd\xfd\x7a\xaf\x91\xd5\x33\x98\x6d\x37\x7a\x6b\x75\xd9\xd3\xfa\x5e\xd8\x35\xc8\x56\x4b\x08\xe6\xc3\xdf\x93\x8e\xac\x65\x90\x6a\xfe\xb7\x76\xb8\x6b\x77\xc8\xfc\xdc\x61\xf1\x29\xa4\x47\x3d\xa3\x35\xcb\xb7\xf4\xa5\x60\x80\x2a\x14\x1e\xdf\x14\xfe\xfa\x1b\x4f\x3b\xe9\x0d\xa2\xef\x8c\x98\xb7\x33\x43\x67\x7c\x26\x83\xfc\x20\x26\x06\x75\xe5\x51\xe4\xfa\xca\x70\x2d\xa6\x3b\xa0\x2c\xc6\x4c\xc7\x5d\x82\x42\xc6\xf0\x33\x26\x42\x99\xd3\x67\x55\xe2\xb0\x5a\x66\x53\x6f\xec\x1f\xce\xd4\x75\xf0\xe4\x43\xe0\x4f\xdc\xe9\x32\x28\x6b\x1b\x0f\x81\x7f\x3f\x32\xc5\x69\x90\x33\x96\x99\x75\x62\x10\xb8\x13\x3f\x98\x9a\x09\xa0\xe1\x30\xa6\x40\x09\x86\x94\xd2\x0c\x5e\x12\xb1\x05\xfe\x94\x64\x90\xd2\xe8\x09\xc7\x95\xbf\x2b\x97\x48\x2e\x87\x47\x39\xc3\x8f\x3c\xee\xd6\x0f\x80\x81\x37\x6f\xf2\xf4\x71\x8e\x3e\x43\x9a\x00\x00\x66\xbe\xff\x60\x20\x5d\xc2\x52\xc0\xc1\xa5\x82\x8a\xf6\x8c\x49\x98\x0c\x54\x00\x25\x32\xe5\x8f\xa1\xca\x89\x80\xa1\x83\xbe\x53\xd4\x76\x3a\x82\x67\x23\xd3\x92\xfb\x01\xcc\x7d\x59\x2f\xca\xeb\x62\xb0\xf8\xd5\x7b\x80\x99\x3f\xf9\xd5\x9d\x5e\x5b\xe5\xba\x89\x3f\x0f\xbd\xf9\xd2\xd5\xe2\x29\xad\xcf\xad\xbf\x9c\x9b\x2b\x0a\xe0\x4e\x67\x28\x99\xc9\xa1\xf6\xe5\xb2\xce\xea\x1c\x7e\x6d\x00\x79\x7f\xef\x85\x55\x34\x27\x31\x9f\x43\xf8\xaf\xa3\xf0\xbf\x37\x1e\x94\x03\x44\xcc\x0c\x76\x51\x5a\xc2\xdf\xb2\x84\xe9\x1c\xb5\x32\x7e\x46\x7d\x8a\x3e\x63\xc6\x92\x18\xe7\x19\xf1\x2a\x7b\xa6\x61\xaa\xaa\x52\x32\x7d\x7d\x14\xdd\x35\xe5\xf4\x3d\x3c\x7c\x18\x49\xda\x62\xb7\x4a\x27\x1d\x86\x21\xcf\x95\xb4\x49\x37\x91\x45\xe9\x26\xdd\x14\xa6\x5a\xa7\xe7\xd8\x6a\xd0\xa0\x89\x72\x57\x4a\xc8\xd5\x15\x34\xad\xf7\xb5\x25\x49\xe4\xce\x55\xf1\xfd\x1c\xc5\xe8\x2d\xa0\x27\x47\xb8\x0a\xba\x00\x45\x11\x65\xb1\x8c\x75\x04\x2d\xa3\x77\xb3\xa0\xa8\x8a\x31\x36\x24\x24\x4a\xf7\x7a\xdd\x16\x1f\x21\xab\x54\x99\x4f\x18\x67\xc5\xca\x82\x27\xd2\x56\x2f\x86\x4e\x44\x74\x54\x1f\x43\xe9\x71\x67\x8c\x46\x38\xde\x33\x2c\xad\xe4\x3e\x95\x95\x4f\x59\xc6\x05\x86\x37\xfb\x14\xb1\x54\xe6\xfa\x01\x41\xc4\x28\x81\xff\xa1\x8f\x3d\x5d\x34\xa5\x69\xca\xe5\x39\x1d\x5d\x1e\x46\x79\x24\xcf\x53\x14\xf5\x5a\xa9\x3a\x77\x99\xf6\xdd\x0b\x9f\x1f\x5b\xc3\xa1\x2c\xf9\xca\x3b\x6d\x5d\xa9\x51\x38\xab\xd5\x5c\xa4\xfc\x94\x6d\x1e\x09\x6b\x95\x61\x47\xe0\xca\x82\x31\xb7\x86\x43\x55\xa5\xc6\x12\xd5\x38\x2e\xee\xd7\xa8\x55\x87\x33\x2c\xf6\x8c\xa8\x29\x2e\x30\x52\x05\x5c\x86\x12\x9e\xa7\x74\x8a\xa4\x91\x35\x1c\x52\xb1\xc5\xac\xc2\x61\x5e\x17\xda\x93\x4b\x4a\x3d\x6c\x4f\x56\xcd\x72\xdb\xb1\x2a\xac\xfb\x25\xb4\xba\x2b\x39\x7a\x6f\x69\x58\x8d\x4c\x61\xab\xc0\x5e\x9b\xed\xae\x23\x1d\xad\xe7\x60\xa6\xab\x38\x75\xf3\x7d\xd0\x04\xb2\x3d\x29\xc9\xae\xec\x9f\x06\xd5\x4c\xbd\x67\xa3\xb7\x75\x67\xaa\x4a\xaf\x1b\x8b\x3b\x83\x86\x56\xad\xb2\x99\x0f\x3f\xa0\x82\x54\xfe\x7b\xd7\x28\x69\x66\xed\xb2\xb1\xd6\x50\xcd\xda\x67\x17\xdd\x5a\x5a\x50\xea\xcb\xdc\xfe\xfa\xb7\x90\x75\x18\x61\xef\xb6\xb2\xba\x9d\x69\x65\xa9\xd2\x3a\x12\x99\x15\xde\x35\xee\x5f\x50\x22\x00\xc1\xcb\x96\xa6\x95\x30\x19\x29\x4d\x4c\x62\xdd\x5e\xf5\xb8\x8f\x9e\xb0\x50\x75\xa9\x54\x55\x37\xb5\x54\x36\x93\xcb\x9a\x65\xde\xe7\xb9\x78\xbd\xa9\x9f\xbf\xb2\xd1\x62\x61\x97\x45\xfc\xee\xf9\x81\xe1\x4d\x78\xb7\xe6\xa2\xae\x0a\xbc\x1f\x1c\x5a\xf1\xa1\xc1\xce\xcd\x52\xfd\x91\xa0\xde\xfc\xcc\xee\x9f\x76\x6e\xbb\xaf\x33\x18\x65\x82\xc6\x86\x5d\x42\x6c\xd8\xa1\x6f\x36\xa0\xe7\x8d\xcc\x01\xee\x89\x18\x1c\x0a\x2e\x4c\x6c\xc9\x6c\xa2\xad\x46\x06\xcd\x03\xfb\xba\xa7\x48\x9d\x5b\xfe\x8d\x9e\x37\xe5\xdf\xea\x96\xfe\xdb\xf6\x3d\xa7\x53\x9a\x1d\x69\xcd\xbf\x36\xd2\x9a\x7f\xcb\xff\x97\x9c\x67\x1a\xa7\x84\xaf\x64\x07\x14\x5e\xed\x10\x7b\xc2\x2c\x87\xa6\x75\xfa\x5d\xe0\x2f\x1f\x64\xbc\xf0\xce\x86\x5f\x6a\xb3\x65\xec\x9d\x93\xb0\x12\x67\xfb\x00\x73\xb4\x43\xa6\x89\xef\xcc\xdc\xc5\xc4\xed\x77\xb3\x81\x0d\xbd\x61\x42\xd6\x09\x49\xc4\x6b\x6f\x60\xd7\x78\xc2\xe4\x34\xf9\x15\xae\xef\x59\x0a\xa3\xa2\x64\xd8\xea\x4d\x1a\xd7\xae\xe9\xc8\xdb\x34\xb5\x43\x5b\x1f\x5c\x9b\x8d\x19\x95\x1c\xe7\x81\x8f\xe1\xb7\x29\x46\x31\xda\x18\x09\x4e\x94\x99\xc9\x0d\x55\x61\xf0\xa4\x4f\x06\x84\x16\xe3\xb5\xf0\xaf\xb4\xb5\x36\x28\xb7\xb0\xf4\x12\x38\x10\x5a\xdc\xb0\xe7\xca\x43\x78\x95\xd9\xd7\xa6\xf4\x1b\x26\xe1\xfd\xf8\x80\x78\x97\x06\xf6\xda\xba\x40\x02\x2f\xab\x2f\x9c\x9f\xea\x3f\x52\xae\x3a\x26\x1d\x1d\xd5\x86\x03\x15\x87\xdf\x1d\x94\xbc\xfe\x50\x5b\x72\x7e\xcd\xe1\x64\xdd\xe1\xa2\xda\xc3\xb9\x09\xc9\xe3\x49\xc9\x83\xfa\xea\x78\x15\xe2\x27\x54\x22\xba\x0f\xed\xca\x50\xd6\x73\x93\x67\xa5\xff\x2f\x2f\x01\xfc\xac\x32\xc0\xc9\x52\x40\xfb\x35\x3f\x5a\x12\x38\x5a\x16\x38\xa5\xf2\x5b\xda\xe4\x60\x2d\xe0\x0c\xed\x71\x49\x62\xfe\x54\x72\xfe\x7b\x12\xf4\x3f\x59\x26\xbe\x37\x59\xff\xf3\x12\xf6\x17\x88\x84\xd5\xc1\xfb\xa7\x13\xf8\x3f\x90\xc4\x3f\xce\x59\x07\xb3\xf9\xb5\x4c\xcd\xa1\x94\x7e\x07\xa3\xd6\xf2\xfa\x8d\x1e\xee\x7a\x62\xbf\xcd\xd2\xd7\x56\x65\x31\x64\x7c\xa7\x32\x69\x7e\xf8\xc9\x0d\x16\x75\x1f\x15\x33\xd5\xac\xb1\xf8\xfb\xcc\x0d\x82\xfb\xd2\xa7\xcf\x21\xbf\xc8\x57\x91\x3e\x4a\xd9\x1e\x9d\x1b\x68\xdb\x6c\x8b\x1e\xcb\xdb\xac\xcb\xfd\x93\x3c\xe8\xc0\x8c\x9d\x5b\xa6\x38\xbb\x79\xb3\x33\xd0\xad\xf7\x6b\x96\x1d\xfa\xa7\xc3\x67\xe5\xaf\x72\x23\x4d\x74\x2a\x84\xb6\x0f\xf4\xcc\x1f\x6f\x95\xb7\x0f\x77\xc7\x0f\xac\x66\x93\xe5\x65\x0d\x96\x3a\x36\x6e\x5f\xd9\xd1\x13\x99\x35\xbd\x66\xa3\x19\xb1\x71\xcc\xa5\x61\x72\x37\x7b\x1c\x41\x6c\x9d\x67\x8a\x18\xb6\xfd\x9a\x2a\x21\xa7\x42\x8d\xd6\x82\x0f\x37\x2d\xa4\xd7\xc3\x5f\xc7\x5b\xb8\x86\x58\xf5\x22\x44\x08\x15\x20\xfb\x77\x36\xd8\x70\x85\xab\x68\x97\xae\xe1\x8d\x36\xc9\x6f\x40\x50\x78\x53\xb5\x54\x69\x29\x6f\xc2\x60\x43\x17\x5a\xe5\xa7\xd5\xcb\x27\x6f\x1e\xca\x46\x47\x86\x79\xde\xdf\xd9\x48\x9c\xbd\x96\xc9\x28\x13\x1e\x4d\x69\xd5\xa9\xd3\xeb\x6c\x41\xda\x73\x0c\x27\xda\x54\xbb\x5a\x1c\x1f\x31\xa8\x93\x05\x95\xf4\x81\x3f\xeb\x9f\x1f\xfc\x59\xfa\xf0\x3a\x51\x98\x70\x48\xc8\x06\x73\x51\xef\x6a\xfc\x7f\xe8\xa8\xfd\xa1\x9e\xb7\x56\xbf\x5b\xc3\x31\xe8\x75\xfd\xa2\xa6\x3c\xb3\xdf\xec\x59\xae\x7e\xc5\x62\xf8\x5b\x92\xb2\xd5\xf8\x2e\x21\x30\xf5\x97\xf2\xb8\x87\xc0\x9d\x78\x0b\xcf\x9f\xab\xd8\xbc\x63\x14\x3d\x6f\x3a\x46\x55\xbc\x0e\x1f\xbd\x3b\xf3\xe0\x41\xef\x60\x41\xb0\xa3\x4f\xed\xc8\x4b\xbd\xf9\xd4\xfd\x52\xfd\x54\xab\x7a\x2c\xf4\x8d\xbc\x82\xca\x34\x80\x37\x9f\xcc\x96\x53\x17\xfa\xe8\x79\x33\xe8\xd9\x87\x6e\x1a\x0e\x29\x29\x1a\x71\x32\xcc\xe0\x05\xe3\x27\x29\x40\xff\x21\x91\xb1\x17\x65\x9c\xd9\xb2\xaa\x39\x1b\x54\x25\xd2\xb3\xa9\x6b\x43\x4f\x82\xd8\x3b\x51\x25\x55\x9f\x02\x4c\x75\x93\x94\xc2\x59\xda\xde\x62\x00\xde\xc2\x2f\x7f\x79\xf7\x9f\x67\x1d\xa7\x81\x8e\xf1\x1a\xed\x53\xa9\x00\x62\xfc\x0d\xf3\xf1\x8d\x2a\x21\x77\xf5\x09\x76\x24\x89\x0e\x29\xd2\x7e\x4d\xc7\xd4\x8c\x4c\x4b\xc1\xb4\xcd\x89\x76\xad\xf4\x6f\xcb\xe0\x67\x1c\xe5\xcf\x65\x51\xef\x76\xe6\xe9\x9f\x00\x4e\xfc\xf9\x22\x0c\x1c\xc9\x96\x4d\xc0\x57\xd9\x13\x7e\x85\xa9\x9f\xbb\x1e\xa5\x63\x51\xbb\x17\xc6\xa0\x1a\xaf\xa7\xee\x74\xd4\x0d\x50\xf7\xd2\x56\x8e\xc0\xea\x28\xa2\x35\x8d\x78\xbb\xca\x73\x10\x1f\x83\x9a\xbf\x72\x49\x5f\x85\x51\xa2\xb9\xd8\xa9\xd0\x8e\x44\xe5\x1c\xb4\xff\xd2\x35\x1d\x59\x74\x39\xfa\xeb\x56\x99\x7e\xad\xe3\x59\x55\x1e\xb4\xcc\x01\x12\x4d\x6a\xeb\x0d\x4d\x84\xf7\x55\x3f\x54\x55\xef\x31\xca\x2a\xcd\xf2\xc7\xa0\x77\xbe\x5b\xc5\xf0\x31\x1c\x1c\xae\x4d\xb4\xfd\xa0\x53\xe1\xc2\x25\x3e\xc9\x51\xa8\xda\x9e\x6c\x6e\xfc\x0e\xbe\xeb\x18\xaf\x0d\xae\x5b\x7c\x74\x19\x0f\x1d\xc7\xa0\xc6\x9a\xe4\x13\x86\x77\xf4\x39\x27\x5c\xcb\xb7\x30\xf8\xc5\x2e\xcb\x78\x89\xe0\x35\x3f\xc3\xce\x4b\x56\x1c\x0b\xae\x27\x1b\x84\x2f\x4b\x89\x5a\xf7\x19\x6c\xf0\xd9\x73\x7f\x2b\xa0\xf6\xe6\xb7\x7e\x9b\x1c\xce\xc2\x40\x65\xa9\x66\x6b\x84\xb1\x8d\xe1\xba\x7e\x30\x27\x0e\xe5\x7c\xb3\x96\xa2\xa8\xe6\x0e\x35\xde\x94\x61\x59\xad\xf5\x06\x9c\x45\x21\x1f\x1a\x2f\x8d\x3b\xcc\x0c\x72\x39\x55\x26\x36\x9b\x4b\x8c\xd3\x52\xb4\x31\xcf\x2a\xa2\xae\xd6\x98\x8a\xbb\x2e\xf5\xbe\x0f\xfe\x00\xef\x82\x1a\xd5\xb5\xf5\x7f\x03\x00\x52\xca\x8d\x70\x5e\x3f\x00\x00"),
+		},
+		"/7_series_liveness.down.sql": &vfsgen۰CompressedFileInfo{
+			name:             "7_series_liveness.down.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 53,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x08\x76\xf6\x70\xf5\x75\x8c\x77\x76\x0c\x71\xf4\xf1\x77\xd7\x2b\x4e\x2d\xca\x4c\x2d\x8e\xcf\xc9\x2c\x4b\xcd\x4b\x2d\x2e\xb6\xe6\x02\x0c\x00\x0b\xe4\x02\xea\x35\x00\x00\x00"),
+		},
+		"/7_series_liveness.up.sql": &vfsgen۰CompressedFileInfo{
+			name:             "7_series_liveness.up.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 316,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x34\x8f\x41\x6e\x83\x30\x10\x45\xf7\x9c\xe2\x2f\x5b\x29\xce\x05\xaa\x2e\x9c\xc8\x4a\x51\x21\x41\xc4\x5d\xa4\x1b\x64\xc1\xb4\xb6\x0a\xb6\xe4\x99\x94\xeb\x57\x04\xba\x99\xd5\xfb\x4f\xf3\x94\x82\xf5\x04\x09\x13\x81\x5c\xef\xc1\x94\x03\x31\x46\xc7\x82\x99\xa2\x80\xc5\x8d\xb4\x43\xa6\x3e\xe5\x81\x06\x84\xc8\x42\x6e\x40\xfa\x02\x4b\xca\x21\x7e\x43\x3c\x15\x4a\xad\x64\x24\x66\x4c\x2e\xff\x50\xe6\x85\x69\x72\x9a\x48\x3c\xdd\x19\xb3\xa7\xb8\xb0\xe8\x53\x8c\xd4\x4b\xca\xc8\xf7\xc8\x98\x83\xf8\x65\xaf\x1e\x02\xb5\x8d\x5f\xc7\xf0\xfb\xb0\xed\xd1\x92\x1b\x18\x34\x05\x81\xdb\xdc\x70\x02\xf1\xcb\x09\x13\xed\x8b\x63\x6b\xb4\x35\xb0\xfa\x50\x19\x5c\x8f\x6f\xa6\xd6\xdd\x51\x5b\x5d\x5d\x4e\xfb\xb5\xa8\xfb\xd7\xe1\xa9\x00\xb0\x75\x76\x61\xc0\xa1\x3c\x95\x67\x8b\xa6\x2d\x6b\xdd\xde\xf0\x6e\x6e\xbb\x95\x58\xbe\xe9\x9c\xc0\x96\xb5\xb9\x5a\x5d\x37\xf6\x13\xe7\x8b\xc5\xf9\xa3\xaa\x8a\xe7\x97\xe2\x6f\x00\x23\xf2\x9b\x8e\x3c\x01\x00\x00"),
+		},
+		"/8_event.down.sql": &vfsgen۰CompressedFileInfo{
+			name:             "8_event.down.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 43,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x08\x76\xf6\x70\xf5\x75\x8c\x77\x76\x0c\x71\xf4\xf1\x77\xd7\x4b\x2d\x4b\xcd\x2b\xb1\xe6\x02\x0c\x00\xe6\x46\x99\x4d\x2b\x00\x00\x00"),
+		},
+		"/8_event.up.sql": &vfsgen۰CompressedFileInfo{
+			name:             "8_event.up.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 375,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x74\x90\xc1\x4a\x03\x31\x10\x86\xef\xfb\x14\xff\xad\x16\x9a\xbe\x40\x4f\xe9\x36\x6a\x65\xbb\x2b\x6e\x0a\xc5\xcb\x92\x66\x47\x13\x68\x93\x92\x4c\xad\x22\xbe\xbb\xb8\x8b\x0a\x82\xd7\xff\x9f\xf9\xe6\x63\x84\x80\x76\x84\x78\xa2\x64\xd8\xc7\x60\x0e\xa0\x17\x0a\x9c\x11\x9f\xc0\x8e\x60\x63\x08\x64\x39\xa6\x3c\x43\x3e\x5b\x07\x93\x71\x24\x4e\xde\x82\xcd\xfe\x40\x19\x36\x91\x61\xea\x11\x53\x21\x04\x0e\x64\x7a\x4a\xd9\xf9\x13\xac\x33\xe1\x99\xf2\x0c\x97\xe4\x99\x29\xe0\xe2\x28\xc0\xfc\x22\x91\xce\x21\xe3\xe2\xd9\x41\x8c\x57\xc5\xc0\x9c\x17\xe5\x83\x92\x5a\x41\xcb\x65\xa5\xd0\x96\xb7\x6a\x23\xbb\x52\x6a\x59\x35\x37\xf3\x61\x12\x57\x05\x00\xb0\x3f\x12\xf4\x7a\xa3\x5a\x2d\x37\xf7\xfa\x11\x75\xa3\x51\x6f\xab\x6a\x36\xd6\x6f\x27\x82\x56\x3b\xfd\x27\xf7\x21\xb3\x09\x96\x3a\xdf\x0f\xf5\x98\x1a\xe6\xe4\xf7\x67\xa6\x8c\xbb\xb6\xa9\x97\x3f\x4b\x58\xa9\x6b\xb9\xad\x34\x26\xef\x1f\x93\x62\xba\xf8\xd6\x5b\xd7\x2b\xb5\x1b\xff\xd5\x7d\x99\x74\xbe\x7f\x45\x53\xff\x23\x3c\xb8\xae\x54\x5b\x4e\x17\xc5\xe7\x00\x39\xa1\xe6\x8c\x77\x01\x00\x00"),
+		},
+		"/9_native_histogram.down.sql": &vfsgen۰CompressedFileInfo{
+			name:             "9_native_histogram.down.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 1699,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xcc\x94\xcf\x8e\xe2\x38\x10\xc6\xef\x79\x8a\xef\xd0\xd2\x34\xab\x81\x17\x40\x7b\xc8\x04\xc3\x44\x1d\xe2\x6c\x12\xb4\x7f\x2e\x51\x88\x6b\x3a\x5e\x1c\x3b\x72\x4c\xef\xf2\xf6\x2b\x27\x40\x33\xb4\xba\xb5\xda\xc3\x6a\xb8\x51\xa9\x2a\xff\xfc\x7d\x55\x8e\x72\x16\x96\x0c\x3c\x47\xce\xb2\x24\x8c\x18\xb2\x9c\x47\x6c\xb5\xcb\x19\x8a\xe8\x2b\xdb\x86\x55\x96\xf3\xed\x42\x58\xd3\x57\x4d\x7b\xd4\x87\xe1\x71\x16\x84\x05\x1e\x1e\x82\x15\x8b\x92\x30\x67\x01\x00\x58\xe4\x2c\xe2\xf9\x6a\x19\x7c\x61\x9b\x38\x1d\x63\xf3\xb9\x30\x30\x9a\xa0\x8c\xe9\xf1\x97\x74\x2d\x86\x83\xec\xa1\x4c\x73\x20\x81\x5a\x0b\xb8\x96\xf4\x98\xe2\xda\xda\x61\xef\xbf\x0c\x70\x06\xbd\xa5\x17\xd2\x0e\x83\xab\xed\x4b\xed\xa4\xd1\x63\xc7\x35\xcf\x61\x71\x6e\xef\x7f\x05\x4b\x58\x54\xe2\xa7\x6b\x60\x9d\xf3\xed\x05\x3c\x0a\xcb\x30\xe1\x9b\xc5\x33\xb9\xaa\x23\x67\x65\x33\x54\xfe\x9c\x4a\x13\x89\xea\xf5\x46\x8f\xb3\xb1\x3c\xe1\x3c\xbb\xf6\x99\xcf\x3d\xcb\x85\x63\x40\x63\x74\x73\xb4\xd6\x33\xdd\x48\x01\xa3\x31\xd4\x1d\xc1\xd5\x7b\x45\xd7\xe2\x8c\xe5\x6b\x9e\x6f\xd1\x2d\x3e\x06\x9b\xa0\xd0\x5d\x93\x7e\xfd\xca\x72\x86\x6e\x21\x05\x7e\x86\x5d\x48\xf1\x5a\xce\x73\xa4\x1c\x4f\xec\x77\xec\xb2\x95\xb7\xac\x78\x8a\x33\x24\x3c\x7a\x62\xab\x65\x70\xcd\x8b\x78\x5a\xc6\xe9\x8e\xf9\x56\x29\x52\x5e\x62\xcd\x77\xe9\x6d\xc6\x05\xee\x8e\x65\xbc\xd5\x04\x74\xf1\xd9\x9e\x01\x2b\x5d\x77\xf4\xf9\x7d\x55\x6f\xa4\x1c\x2a\xa3\x04\x59\x2f\xb3\xfe\xbe\x7e\x36\x5b\xde\x40\x6e\xb7\x71\x39\xfd\x67\xe9\x6a\x54\xfe\x4c\xf8\xff\x39\xfc\x63\xeb\x30\x9f\x8f\xab\x21\x48\x91\xf3\xdb\x41\x7e\xce\x7a\x45\x03\xe8\xef\x5e\x5a\x12\xd8\x9f\xa0\xea\x3d\x29\x58\x72\xa4\xfd\x8a\xc0\xbc\x90\xb5\x52\xd0\x80\x6f\xd6\x74\xbe\xea\xdc\x6b\x62\x9a\x96\xac\xb6\x84\x03\xf5\xee\x43\xb9\xbb\x5b\xe4\xff\x32\xc3\xef\x8b\x34\x42\x57\x57\xe8\xaa\x27\x2b\x8d\x78\xfc\xee\xc4\x19\xe2\x62\x1c\xde\x74\x97\x24\x6f\xad\x8b\xc2\x24\x79\xe3\xdb\xa8\xd4\x9b\xee\x67\xb5\xee\x3c\xb8\x97\x9c\xf9\x0d\x79\x78\x40\x12\xa6\x9b\x5d\xb8\x61\xc8\x92\x6c\x53\xfc\x92\x2c\x03\x6f\x11\x4b\x4b\xf0\xf4\x5f\x3d\x8c\x71\x81\x4f\x3e\x32\x40\xd4\xae\x46\xdd\x34\xc6\x0a\xa9\x9f\xe1\xcc\x68\xe2\x18\x7d\x35\xac\x37\x4a\x36\xa7\xcf\x90\xba\x51\xc7\x29\xaf\xa5\x0f\x6c\xf5\x4f\xe6\x81\xa8\xbf\x64\x5e\x66\x42\xc9\x6f\xd4\x9c\x1a\x45\x53\x47\x49\x03\xda\xfa\x85\xa0\x8d\x83\x35\x4a\x91\xc0\xb1\xc7\x89\xdc\x02\x65\x2b\x07\xf4\xd6\x34\x24\x8e\x96\x30\xb4\xe6\xa8\x04\xf6\x04\x7b\xd4\xb0\xf4\x7c\x54\xb5\x55\x27\x48\x8d\x1a\x8d\x35\x1a\x7f\x9a\xfd\xa7\x65\x10\xac\x72\x9e\xa1\x0c\xbf\x24\x0c\xf1\x1a\xec\xb7\xb8\x28\x8b\x7b\x0f\x5a\x39\x38\xf3\x6c\xeb\x6e\x39\xa5\xaf\x77\x69\x54\xc6\x3c\x7d\xbf\x62\x54\xef\x5a\x76\xd1\xb1\x8c\xb7\xac\x28\xc3\x6d\x56\xfe\x31\x5b\x06\xff\x0c\x00\xe2\x7a\x6f\xf9\xa3\x06\x00\x00"),
+		},
+		"/9_native_histogram.up.sql": &vfsgen۰CompressedFileInfo{
+			name:             "9_native_histogram.up.sql",
+			modTime:          time.Time{},
+			uncompressedSize: 3387,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xcc\x57\xdf\x6f\xea\xb8\x12\x7e\xcf\x5f\x31\x0f\x95\x80\x2b\x40\xf7\xf9\xa2\x56\x4a\xc1\xed\x89\x4e\x48\x38\x21\x9c\xbb\x3f\xb4\x8a\x4c\x32\x10\x6f\x1d\x3b\x6b\x3b\xf4\xb0\x7f\xfd\xca\x4e\xf8\xd1\xd2\xd2\x6a\x1f\x56\x9b\xa7\xd6\x9e\x19\x7f\xfe\xe6\x9b\x19\x33\x1a\x41\x5a\x22\x08\x6a\xd8\x0e\xa1\x64\xda\xc8\xad\xa2\x15\x68\x5a\xd5\x1c\x35\xc8\x0d\x50\xce\xc1\x94\x08\x15\x1a\xc5\x72\x3d\x76\x0e\xeb\x26\x7f\x42\xa3\x81\x2a\x04\x6d\xa4\xc2\x02\xa8\xf6\x46\x23\x60\x02\x16\x4a\x56\x68\x4a\x6c\xf4\x10\xa8\x06\x5d\x53\xe1\x02\xe5\x52\x68\xcc\x1b\x77\x52\xe7\x3f\x84\x75\x63\xe0\x99\x99\x12\xe8\x5a\x4b\xde\x18\x84\x5c\x36\xc2\xe8\xff\xd9\x60\x1b\x2e\xa9\xc9\xda\x05\x30\xc8\xb9\x76\x48\xdc\xf2\x09\xac\x06\x5a\x53\x65\x60\xa3\x64\xe5\xf6\x99\x30\xb8\x45\x05\x52\xa0\x1e\xc2\x73\x29\x35\xda\x68\x5d\x1c\x0b\xb9\x40\x6e\x28\xa0\xc8\x65\x81\x05\x48\xe1\xdc\x9e\x99\xc2\xb1\x37\x4d\x88\x9f\x12\x48\xfd\xfb\x90\xc0\x72\xfa\x85\xcc\xfd\x6c\xea\xa7\x7e\x18\x3f\x8e\x4f\xfc\xf4\x3d\x00\x00\xc3\x2a\x84\x34\x98\x93\x65\xea\xcf\x17\xe9\x2f\x10\xc5\x29\x44\xab\x30\x1c\xba\x6d\x8d\x8a\xa1\xce\x58\x01\xf7\xc1\x63\x10\xa5\xaf\xb6\x1d\x1e\x98\xc5\x2b\x7b\xd2\x22\x21\xd3\x60\x19\xc4\xd1\xeb\x18\x4d\xf5\xa1\x49\x5e\x62\x45\xe1\xf2\x80\x3f\x51\xc9\xcc\x94\x0a\x75\x29\x79\xf1\x51\x18\x67\xfd\x29\x4c\x02\xb7\x4e\x2f\x99\x4d\x6d\x26\x37\x1b\x6d\xa5\x10\x44\xe9\xaf\xbf\x5d\xb5\xe4\x28\xb6\xa6\xfc\xc0\xb2\xcb\xd2\x6b\x08\x17\x0e\xb5\xd4\xec\x73\x20\x5e\x5a\x5e\x03\x71\xb4\xfc\x2c\x88\xbc\xd1\x46\x56\xd9\x8e\xf2\x06\x3f\x61\xae\x50\xa3\xc9\x4a\x26\x0c\x2c\xe7\x7e\x18\x9e\xa7\x0c\x66\xe4\xc1\x5f\x85\x29\xfc\xb7\xb5\x7d\xa1\xfd\xfb\x38\x0e\x89\x7f\xca\x83\x37\x98\x58\x45\xaf\x04\xfb\xa3\x41\xd0\x12\x4c\x49\x8d\x13\xb1\xb2\x55\xda\x15\x2e\x3c\x2b\x66\x10\xf4\x13\xab\xdd\xde\x79\xc5\x70\x85\xb4\xd8\x77\xb5\x7b\x54\xfd\x2a\x0a\xbe\xad\x08\x04\xd1\x8c\xfc\x74\x32\xcf\x8e\x42\xce\xac\xe2\x33\x56\xfc\x80\x38\xba\x52\x1c\x47\xfb\xa1\x2b\x91\xc1\xc4\x5b\x92\x90\x4c\x53\xc8\x15\x52\x83\x59\xb9\xaf\x51\x19\xba\xe6\xd8\xef\xbd\x17\xa5\x37\x84\x9e\x75\xee\xb5\x74\xbc\xf9\xe5\x65\x23\x9e\x3a\x4c\xc2\xa0\xda\x51\x7e\x7b\xf7\x2a\xe0\x16\x4d\x56\xe0\x86\x36\xdc\x64\xad\xfd\xc1\xb4\x3f\xb8\x16\xba\x45\x7a\xf0\x64\xa2\xc0\x1f\xa8\x6f\xef\x36\x94\x6b\x7b\xa1\x03\x63\x71\x02\x09\x59\x84\xfe\x94\xc0\xc3\x2a\x9a\xa6\xc1\x25\x31\x85\x92\x75\x76\x22\xd3\x81\xd0\x7d\xc9\x0b\x54\x99\x29\xa9\x38\xef\x20\x03\x2f\x21\xe9\x2a\x89\x96\xf0\x3d\x0e\x66\x9e\xbf\x84\x9b\x4d\x23\xf2\x1b\xef\x9e\x3c\x06\x91\x83\xbb\x20\xc9\x43\x9c\xcc\xc1\x85\xed\x82\x39\x2e\x33\x41\x2b\xbc\xbd\xeb\x9d\x53\xd8\xf6\x86\xc3\xce\x4b\x60\xbd\x21\x9c\x40\xdc\xde\x9d\xfe\x1e\x4c\x3c\x12\xcd\xbc\xee\xe4\xd0\x8f\x1e\x57\xfe\x23\x81\x45\xb8\x78\x5c\x7e\x0b\xe1\x7b\x1c\xfa\x69\x10\x92\x37\x49\x58\x24\xf1\x94\xcc\x56\xc9\xb1\x77\x2e\x92\x78\x3e\x3e\xc7\x3a\x70\xb7\xba\xf1\x66\x64\x1a\xfa\x09\x69\x0b\x03\x12\x32\x8d\x93\xd9\xe4\xec\x9e\xa3\x51\x21\x6d\x0b\x07\x2e\x65\xdd\xce\x08\xa7\x64\x2e\xf3\x27\x3b\x6e\x44\x61\x45\x2d\x9c\x89\x93\xff\xda\xee\x68\x30\x12\x6a\x85\x3b\x14\x06\xb4\xa1\x6a\x47\x0d\x93\xc2\x45\x7c\x88\x13\x50\xd0\x85\xb7\x5f\xa7\xca\xff\x1c\x17\x1e\x92\x78\x0e\x6f\x08\xa8\x9b\x7d\x96\x1d\x93\x09\xc4\x22\x3b\xdd\xa8\x3f\x70\xee\x61\x1c\x2f\x8e\x71\x46\x23\x8b\xe5\x80\x43\xdb\xd1\x97\x37\x4a\x59\x4c\x67\x54\x80\x14\x76\xd0\x22\xb8\xf4\x1d\x9d\x0f\x09\xae\xc6\xd7\x81\xb5\xa0\xa0\x3a\x1a\xfd\xff\x0b\x49\x08\x54\x63\x56\xc0\x2d\xa8\x31\x2b\x4e\xee\x71\x02\x51\x0c\x5f\xc9\xcf\xb0\x5a\xcc\x6c\xca\x96\x5f\x83\x05\x84\xf1\xf4\x2b\x99\x4d\xbc\xa3\xdd\x34\x8e\xd2\x20\x5a\x11\x1b\xaa\xed\x36\x0f\xf1\x2a\x3a\xb7\x38\x80\x7b\x4b\xe3\x2d\xa0\x43\x9e\x55\x07\xd0\x89\x6f\xf8\x3e\xab\x67\x54\xea\xec\x24\xc2\x97\xfe\x83\xc1\xe4\x0c\xe4\x7c\x1e\xa4\xed\xff\x24\x9a\x39\xe6\x3b\x84\xff\x5c\x86\xff\xdd\x3c\x8c\x46\xae\x34\x0a\xe4\x68\xd0\xf5\xfe\xc3\x83\x0e\x7f\xd4\xcc\xbe\xd6\xd6\x7b\xe0\x74\x8d\x1c\x14\x1a\x14\xb6\x44\x40\xee\x50\x29\x56\xa0\x3e\xbe\xa4\xba\x58\x9d\x5a\x5d\x91\x51\x85\xf0\x84\xb5\xb9\x4a\x77\x75\x0e\xf9\xef\x68\xf8\x7d\x92\x1c\xe8\xec\x08\x3a\xab\x51\x31\x59\xf4\x5f\x9c\x38\x80\x60\x79\x1a\x95\x17\xa9\x9b\xfa\x61\x78\x91\x37\xc7\xd4\x45\xf4\x8e\xad\x57\x39\x78\x87\xf2\xae\x23\x5d\x3c\xa6\xdd\x1b\x94\xe5\xa5\x7b\x49\x6f\x24\xe7\xf2\xd9\xd9\x75\xb3\xe5\x2c\x03\xed\x65\xbc\x8f\xf4\x75\x31\x4b\x84\x7c\xee\x0f\x60\x04\x57\x46\xdf\x05\x63\x83\xb6\xcb\x4f\xbc\x9b\x1b\x78\xdd\xe2\x27\x9e\xd5\x16\x89\x52\x88\xa3\x4f\x75\xf4\x60\x09\x3d\xbb\xa2\xa1\xa0\x86\x02\xcd\x73\xa9\x0a\x26\xb6\x60\x64\x7b\x55\xbb\x7a\x76\x4f\xc9\x59\xbe\x1f\x02\x13\x39\x6f\x5a\xbb\x12\xaf\xe8\xf1\x0a\xb3\x76\xeb\x09\xb1\x3e\x04\x39\xe8\x9c\xb3\x0d\xe6\xfb\x9c\x63\x7b\x18\x43\x0d\x25\xdd\x21\x08\x69\x40\x49\xce\xb1\x80\xa6\x86\x3d\x1a\xfb\x7b\x86\x69\xa8\x95\xcc\xb1\x68\x14\x82\x2e\x65\xc3\x0b\x58\x23\xa8\x46\x80\xc2\x6d\xc3\xa9\xe2\x7b\x60\x02\x28\xe4\x4a\x0a\xf8\x5d\xae\x7b\x13\xef\xaf\x01\x00\x21\xab\x40\x64\x3b\x0d\x00\x00"),
+		},
 	}
 	fs["/"].(*vfsgen۰DirInfo).entries = []os.FileInfo{
+		fs["/10_cache_invalidation.down.sql"].(os.FileInfo),
+		fs["/10_cache_invalidation.up.sql"].(os.FileInfo),
+		fs["/11_series_tombstone.down.sql"].(os.FileInfo),
+		fs["/11_series_tombstone.up.sql"].(os.FileInfo),
 		fs["/1_base_schema.down.sql"].(os.FileInfo),
 		fs["/1_base_schema.up.sql"].(os.FileInfo),
+		fs["/2_connector_instance.down.sql"].(os.FileInfo),
+		fs["/2_connector_instance.up.sql"].(os.FileInfo),
+		fs["/3_bulk_series_ids.down.sql"].(os.FileInfo),
+		fs["/3_bulk_series_ids.up.sql"].(os.FileInfo),
+		fs["/4_ha_lease.down.sql"].(os.FileInfo),
+		fs["/4_ha_lease.up.sql"].(os.FileInfo),
+		fs["/5_label_retention.down.sql"].(os.FileInfo),
+		fs["/5_label_retention.up.sql"].(os.FileInfo),
+		fs["/6_lifecycle_policy.down.sql"].(os.FileInfo),
+		fs["/6_lifecycle_policy.up.sql"].(os.FileInfo),
+		fs["/7_series_liveness.down.sql"].(os.FileInfo),
+		fs["/7_series_liveness.up.sql"].(os.FileInfo),
+		fs["/8_event.down.sql"].(os.FileInfo),
+		fs["/8_event.up.sql"].(os.FileInfo),
+		fs["/9_native_histogram.down.sql"].(os.FileInfo),
+		fs["/9_native_histogram.up.sql"].(os.FileInfo),
 	}
 
 	return fs
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS notify_series_delete ON SCHEMA_CATALOG.series;
+DROP FUNCTION IF EXISTS SCHEMA_CATALOG.notify_series_delete();
+DROP TRIGGER IF EXISTS notify_metric_change ON SCHEMA_CATALOG.metric;
+DROP FUNCTION IF EXISTS SCHEMA_CATALOG.notify_metric_change();
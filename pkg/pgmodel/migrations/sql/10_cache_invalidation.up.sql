@@ -0,0 +1,41 @@
+-- Notifies the connectors, on the prom_cache_invalidation channel, of the
+-- catalog changes invalidating their caches: the metrics created, changed or
+-- dropped, with the payload metric:<metric name>, and the series deleted,
+-- with the payload series:<metric id>. The notifications of a transaction
+-- with the same payload are delivered once, when it commits.
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.notify_metric_change()
+RETURNS TRIGGER
+AS $func$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        PERFORM pg_notify('prom_cache_invalidation', 'metric:' || OLD.metric_name);
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        PERFORM pg_notify('prom_cache_invalidation', 'metric:' || NEW.metric_name);
+    END IF;
+    RETURN NULL;
+END
+$func$
+LANGUAGE PLPGSQL VOLATILE;
+
+CREATE TRIGGER notify_metric_change
+    AFTER INSERT OR UPDATE OR DELETE ON SCHEMA_CATALOG.metric
+    FOR EACH ROW
+    EXECUTE PROCEDURE SCHEMA_CATALOG.notify_metric_change();
+
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.notify_series_delete()
+RETURNS TRIGGER
+AS $func$
+BEGIN
+    PERFORM pg_notify('prom_cache_invalidation', 'series:' || OLD.metric_id);
+    RETURN NULL;
+END
+$func$
+LANGUAGE PLPGSQL VOLATILE;
+
+-- Cloned to the series table of every metric, existing or created later, so
+-- that it fires for the deletions from them too.
+CREATE TRIGGER notify_series_delete
+    AFTER DELETE ON SCHEMA_CATALOG.series
+    FOR EACH ROW
+    EXECUTE PROCEDURE SCHEMA_CATALOG.notify_series_delete();
@@ -0,0 +1,15 @@
+-- The tombstones of the series deleted through the delete series API with a
+-- grace period. Their samples between start_time and end_time are hidden
+-- from reads, and physically deleted by the leader once purge_after is past,
+-- unless the tombstone is removed first to undelete them.
+CREATE TABLE SCHEMA_CATALOG.series_tombstone (
+    id BIGSERIAL PRIMARY KEY,
+    metric_name TEXT NOT NULL,
+    table_name NAME NOT NULL,
+    series_ids BIGINT[] NOT NULL,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    purge_after TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX series_tombstone_table_name_idx ON SCHEMA_CATALOG.series_tombstone (table_name);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS SCHEMA_CATALOG.connector_instance;
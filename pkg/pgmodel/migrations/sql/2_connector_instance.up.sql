@@ -0,0 +1,10 @@
+-- Every running connector registers itself here and periodically refreshes
+-- last_heartbeat, so operators can see which binaries are writing.
+CREATE TABLE SCHEMA_CATALOG.connector_instance (
+    instance_id TEXT PRIMARY KEY,
+    hostname TEXT,
+    version TEXT,
+    commit_hash TEXT,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_heartbeat TIMESTAMPTZ NOT NULL DEFAULT now()
+);
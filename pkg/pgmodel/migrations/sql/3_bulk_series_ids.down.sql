@@ -0,0 +1 @@
+DROP FUNCTION IF EXISTS SCHEMA_CATALOG.get_series_ids_for_key_value_arrays(TEXT, text[], text[], int[]);
@@ -0,0 +1,25 @@
+-- Looks up, or creates, the series ids for many label sets of the same metric
+-- in a single round trip. The label sets are passed flattened: label_counts
+-- holds the number of labels of each set, label_keys and label_values hold
+-- the labels of all sets back to back. The ids are returned in input order.
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.get_series_ids_for_key_value_arrays(metric_name TEXT, label_keys text[], label_values text[], label_counts int[])
+RETURNS TABLE(table_name NAME, series_id BIGINT)
+AS $func$
+DECLARE
+    start_pos int := 1;
+    label_count int;
+BEGIN
+    FOREACH label_count IN ARRAY label_counts
+    LOOP
+        RETURN QUERY
+            SELECT s.table_name, s.series_id
+            FROM SCHEMA_CATALOG.get_series_id_for_key_value_array(
+                metric_name,
+                label_keys[start_pos:start_pos+label_count-1],
+                label_values[start_pos:start_pos+label_count-1]) s;
+        start_pos := start_pos + label_count;
+    END LOOP;
+END
+$func$
+LANGUAGE PLPGSQL VOLATILE;
+GRANT EXECUTE ON FUNCTION SCHEMA_CATALOG.get_series_ids_for_key_value_arrays(TEXT, text[], text[], int[]) TO prom_writer;
@@ -0,0 +1,2 @@
+DROP FUNCTION IF EXISTS SCHEMA_CATALOG.update_ha_lease(TEXT, TEXT, INTERVAL);
+DROP TABLE IF EXISTS SCHEMA_CATALOG.ha_lease;
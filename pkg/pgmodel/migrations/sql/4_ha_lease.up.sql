@@ -0,0 +1,24 @@
+-- Leases electing, for every cluster of HA Prometheus replicas, the replica
+-- whose samples are stored. The samples of the other replicas are dropped.
+CREATE TABLE SCHEMA_CATALOG.ha_lease (
+    cluster_name TEXT PRIMARY KEY,
+    leader_name TEXT NOT NULL,
+    lease_start TIMESTAMPTZ NOT NULL DEFAULT now(),
+    lease_until TIMESTAMPTZ NOT NULL
+);
+
+-- Extends the lease of the cluster if replica holds it, or hands it over to
+-- replica if it expired. Returns the leader and how long its lease lasts.
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.update_ha_lease(cluster TEXT, replica TEXT, lease_timeout INTERVAL)
+RETURNS TABLE(leader_name TEXT, lease_remaining_ms BIGINT)
+AS $func$
+    INSERT INTO SCHEMA_CATALOG.ha_lease AS l (cluster_name, leader_name, lease_start, lease_until)
+    VALUES (cluster, replica, now(), now() + lease_timeout)
+    ON CONFLICT (cluster_name) DO UPDATE SET
+        leader_name = CASE WHEN l.lease_until < now() THEN EXCLUDED.leader_name ELSE l.leader_name END,
+        lease_start = CASE WHEN l.lease_until < now() AND l.leader_name <> EXCLUDED.leader_name THEN now() ELSE l.lease_start END,
+        lease_until = CASE WHEN l.lease_until < now() OR l.leader_name = EXCLUDED.leader_name THEN EXCLUDED.lease_until ELSE l.lease_until END
+    RETURNING l.leader_name, (EXTRACT(EPOCH FROM l.lease_until - now()) * 1000)::BIGINT
+$func$
+LANGUAGE SQL VOLATILE;
+GRANT EXECUTE ON FUNCTION SCHEMA_CATALOG.update_ha_lease(TEXT, TEXT, INTERVAL) TO prom_writer;
@@ -0,0 +1,60 @@
+DROP VIEW IF EXISTS SCHEMA_INFO.label_retention;
+DROP FUNCTION IF EXISTS SCHEMA_PROM.reset_label_retention_period(TEXT, TEXT);
+DROP FUNCTION IF EXISTS SCHEMA_PROM.set_label_retention_period(TEXT, TEXT, INTERVAL);
+
+--Order by random with stable marking gives us same order in a statement and different
+-- orderings in different statements
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+RETURNS SETOF SCHEMA_CATALOG.metric
+AS $$
+        SELECT m.*
+        FROM SCHEMA_CATALOG.metric m
+        WHERE EXISTS (
+            SELECT 1 FROM
+            show_chunks(hypertable=>format('%I.%I', 'SCHEMA_DATA', m.table_name),
+                         older_than=>NOW() - SCHEMA_CATALOG.get_metric_retention_period(m.metric_name)))
+        --random order also to prevent starvation
+        ORDER BY random()
+$$
+LANGUAGE SQL STABLE;
+
+--public procedure to be called by cron
+CREATE OR REPLACE PROCEDURE SCHEMA_PROM.drop_chunks()
+AS $$
+DECLARE
+    r RECORD;
+BEGIN
+    --do one loop with skip locked and then one that blocks to prevent starvation
+    FOR r IN
+        SELECT *
+        FROM SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+    LOOP
+        --lock prevents concurrent drop_chunks on same table
+        PERFORM m.*
+        FROM SCHEMA_CATALOG.metric m
+        WHERE m.id = r.id
+        FOR NO KEY UPDATE SKIP LOCKED;
+
+        CONTINUE WHEN NOT FOUND;
+
+        PERFORM SCHEMA_CATALOG.drop_metric_chunks(r.metric_name, NOW() - SCHEMA_CATALOG.get_metric_retention_period(r.metric_name));
+        COMMIT;
+    END LOOP;
+
+    FOR r IN
+        SELECT *
+        FROM SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+    LOOP
+        PERFORM SCHEMA_CATALOG.drop_metric_chunks(r.metric_name, NOW() - SCHEMA_CATALOG.get_metric_retention_period(r.metric_name));
+        COMMIT;
+    END LOOP;
+END;
+$$ LANGUAGE PLPGSQL;
+COMMENT ON PROCEDURE SCHEMA_PROM.drop_chunks()
+IS 'drops data according to the data retention policy. This procedure should be run regularly in a cron job';
+
+DROP PROCEDURE IF EXISTS SCHEMA_CATALOG.delete_label_retention_expired(TEXT);
+DROP FUNCTION IF EXISTS SCHEMA_CATALOG.get_label_retention_series(TEXT);
+DROP FUNCTION IF EXISTS SCHEMA_CATALOG.get_metric_chunk_retention_period(TEXT);
+DROP FUNCTION IF EXISTS SCHEMA_CATALOG.get_metric_label_retention_period(TEXT);
+DROP TABLE IF EXISTS SCHEMA_CATALOG.label_retention;
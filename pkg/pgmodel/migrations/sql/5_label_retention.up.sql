@@ -0,0 +1,226 @@
+-- Retention overrides for the series carrying a label, across all metrics.
+-- A series matching several overrides keeps its data for the longest of them.
+CREATE TABLE SCHEMA_CATALOG.label_retention (
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    retention_period INTERVAL NOT NULL,
+    PRIMARY KEY (key, value)
+);
+
+--the longest label retention override applying to a series of the metric,
+--NULL if none does
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.get_metric_label_retention_period(metric_name TEXT)
+RETURNS INTERVAL
+AS $$
+    SELECT max(r.retention_period)
+    FROM SCHEMA_CATALOG.label_retention r
+    INNER JOIN SCHEMA_CATALOG.label l ON (l.key = r.key AND l.value = r.value)
+    WHERE EXISTS (
+        SELECT 1
+        FROM SCHEMA_CATALOG.series s
+        INNER JOIN SCHEMA_CATALOG.metric m ON (m.id = s.metric_id)
+        WHERE m.metric_name = get_metric_label_retention_period.metric_name
+        AND s.labels && ARRAY[l.id]
+    )
+$$
+LANGUAGE SQL STABLE PARALLEL SAFE;
+GRANT EXECUTE ON FUNCTION SCHEMA_CATALOG.get_metric_label_retention_period(TEXT) TO prom_reader;
+
+--chunks are kept for the longest retention period of any series of the
+--metric, the series with a shorter one are deleted from them
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.get_metric_chunk_retention_period(metric_name TEXT)
+RETURNS INTERVAL
+AS $$
+    SELECT GREATEST(SCHEMA_CATALOG.get_metric_retention_period(metric_name),
+                    SCHEMA_CATALOG.get_metric_label_retention_period(metric_name))
+$$
+LANGUAGE SQL STABLE PARALLEL SAFE;
+GRANT EXECUTE ON FUNCTION SCHEMA_CATALOG.get_metric_chunk_retention_period(TEXT) TO prom_reader;
+
+--the series of the metric whose retention period is shorter than the one of
+--its chunks, with their retention period
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.get_label_retention_series(metric_name TEXT)
+RETURNS TABLE(series_id BIGINT, retention_period INTERVAL)
+AS $$
+    WITH series_retention AS (
+        SELECT s.id, COALESCE(max(r.retention_period),
+                              SCHEMA_CATALOG.get_metric_retention_period(get_label_retention_series.metric_name)) AS retention_period
+        FROM SCHEMA_CATALOG.series s
+        INNER JOIN SCHEMA_CATALOG.metric m ON (m.id = s.metric_id)
+        LEFT JOIN SCHEMA_CATALOG.label l ON (l.id = ANY(s.labels))
+        LEFT JOIN SCHEMA_CATALOG.label_retention r ON (r.key = l.key AND r.value = l.value)
+        WHERE m.metric_name = get_label_retention_series.metric_name
+        GROUP BY s.id
+    )
+    SELECT sr.id, sr.retention_period
+    FROM series_retention sr
+    WHERE sr.retention_period < SCHEMA_CATALOG.get_metric_chunk_retention_period(get_label_retention_series.metric_name)
+$$
+LANGUAGE SQL STABLE;
+GRANT EXECUTE ON FUNCTION SCHEMA_CATALOG.get_label_retention_series(TEXT) TO prom_reader;
+
+--deletes the expired samples of the series with a shorter retention period
+--than the chunks of the metric, one chunk per transaction. Compressed chunks
+--are decompressed for the delete and compressed again.
+CREATE PROCEDURE SCHEMA_CATALOG.delete_label_retention_expired(metric_name TEXT)
+AS $$
+DECLARE
+    metric_table NAME;
+    min_retention INTERVAL;
+    chunk RECORD;
+    expired BOOLEAN;
+BEGIN
+    SELECT m.table_name
+    INTO metric_table
+    FROM SCHEMA_CATALOG.metric m
+    WHERE m.metric_name = delete_label_retention_expired.metric_name;
+
+    SELECT min(s.retention_period)
+    INTO min_retention
+    FROM SCHEMA_CATALOG.get_label_retention_series(metric_name) s;
+
+    IF metric_table IS NULL OR min_retention IS NULL THEN
+        RETURN;
+    END IF;
+
+    FOR chunk IN
+        SELECT c.schema_name, c.table_name, c.compressed_chunk_id IS NOT NULL AS compressed,
+            _timescaledb_internal.to_timestamp(ds.range_start) AS range_start,
+            _timescaledb_internal.to_timestamp(ds.range_end) AS range_end
+        FROM _timescaledb_catalog.hypertable h
+        INNER JOIN _timescaledb_catalog.dimension d ON (d.hypertable_id = h.id)
+        INNER JOIN _timescaledb_catalog.dimension_slice ds ON (ds.dimension_id = d.id)
+        INNER JOIN _timescaledb_catalog.chunk_constraint cc ON (cc.dimension_slice_id = ds.id)
+        INNER JOIN _timescaledb_catalog.chunk c ON (c.id = cc.chunk_id)
+        WHERE h.schema_name = 'SCHEMA_DATA' AND h.table_name = metric_table
+        AND d.id = (SELECT min(id) FROM _timescaledb_catalog.dimension WHERE hypertable_id = h.id)
+        AND ds.range_start < _timescaledb_internal.to_unix_microseconds(now() - min_retention)
+        ORDER BY ds.range_start
+    LOOP
+        EXECUTE format($query$
+            SELECT EXISTS (
+                SELECT 1
+                FROM SCHEMA_DATA.%I d
+                INNER JOIN SCHEMA_CATALOG.get_label_retention_series(%L) s ON (s.series_id = d.series_id)
+                WHERE d.time >= %L AND d.time < %L AND d.time < now() - s.retention_period
+            )
+        $query$, metric_table, metric_name, chunk.range_start, chunk.range_end) INTO expired;
+
+        CONTINUE WHEN NOT expired;
+
+        IF chunk.compressed THEN
+            PERFORM decompress_chunk(format('%I.%I', chunk.schema_name, chunk.table_name)::regclass);
+        END IF;
+
+        EXECUTE format($query$
+            DELETE FROM SCHEMA_DATA.%I d
+            USING SCHEMA_CATALOG.get_label_retention_series(%L) s
+            WHERE s.series_id = d.series_id
+            AND d.time >= %L AND d.time < %L AND d.time < now() - s.retention_period
+        $query$, metric_table, metric_name, chunk.range_start, chunk.range_end);
+
+        IF chunk.compressed THEN
+            PERFORM compress_chunk(format('%I.%I', chunk.schema_name, chunk.table_name)::regclass);
+        END IF;
+        COMMIT;
+    END LOOP;
+END;
+$$ LANGUAGE PLPGSQL;
+
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+RETURNS SETOF SCHEMA_CATALOG.metric
+AS $$
+        SELECT m.*
+        FROM SCHEMA_CATALOG.metric m
+        WHERE EXISTS (
+            SELECT 1 FROM
+            show_chunks(hypertable=>format('%I.%I', 'SCHEMA_DATA', m.table_name),
+                         older_than=>NOW() - SCHEMA_CATALOG.get_metric_chunk_retention_period(m.metric_name)))
+        --random order also to prevent starvation
+        ORDER BY random()
+$$
+LANGUAGE SQL STABLE;
+
+CREATE OR REPLACE PROCEDURE SCHEMA_PROM.drop_chunks()
+AS $$
+DECLARE
+    r RECORD;
+BEGIN
+    --do one loop with skip locked and then one that blocks to prevent starvation
+    FOR r IN
+        SELECT *
+        FROM SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+    LOOP
+        --lock prevents concurrent drop_chunks on same table
+        PERFORM m.*
+        FROM SCHEMA_CATALOG.metric m
+        WHERE m.id = r.id
+        FOR NO KEY UPDATE SKIP LOCKED;
+
+        CONTINUE WHEN NOT FOUND;
+
+        PERFORM SCHEMA_CATALOG.drop_metric_chunks(r.metric_name, NOW() - SCHEMA_CATALOG.get_metric_chunk_retention_period(r.metric_name));
+        COMMIT;
+    END LOOP;
+
+    FOR r IN
+        SELECT *
+        FROM SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+    LOOP
+        PERFORM SCHEMA_CATALOG.drop_metric_chunks(r.metric_name, NOW() - SCHEMA_CATALOG.get_metric_chunk_retention_period(r.metric_name));
+        COMMIT;
+    END LOOP;
+
+    --then delete the samples expired by label retention overrides from the
+    --chunks that are kept
+    FOR r IN
+        SELECT m.metric_name
+        FROM SCHEMA_CATALOG.metric m
+        WHERE SCHEMA_CATALOG.get_metric_label_retention_period(m.metric_name) IS NOT NULL
+    LOOP
+        CALL SCHEMA_CATALOG.delete_label_retention_expired(r.metric_name);
+    END LOOP;
+END;
+$$ LANGUAGE PLPGSQL;
+COMMENT ON PROCEDURE SCHEMA_PROM.drop_chunks()
+IS 'drops data according to the data retention policy, including the label retention overrides. This procedure should be run regularly in a cron job';
+
+CREATE OR REPLACE FUNCTION SCHEMA_PROM.set_label_retention_period(label_key TEXT, label_value TEXT, new_retention_period INTERVAL)
+RETURNS BOOLEAN
+AS $func$
+    INSERT INTO SCHEMA_CATALOG.label_retention(key, value, retention_period)
+    VALUES (label_key, label_value, new_retention_period)
+    ON CONFLICT (key, value) DO UPDATE SET retention_period = EXCLUDED.retention_period;
+    SELECT true;
+$func$
+LANGUAGE SQL VOLATILE;
+COMMENT ON FUNCTION SCHEMA_PROM.set_label_retention_period(TEXT, TEXT, INTERVAL)
+IS 'set a retention period for the series with the given label in all metrics (this overrides the metric retention period)';
+
+CREATE OR REPLACE FUNCTION SCHEMA_PROM.reset_label_retention_period(label_key TEXT, label_value TEXT)
+RETURNS BOOLEAN
+AS $func$
+    DELETE FROM SCHEMA_CATALOG.label_retention
+    WHERE key = label_key AND value = label_value;
+    SELECT true;
+$func$
+LANGUAGE SQL VOLATILE;
+COMMENT ON FUNCTION SCHEMA_PROM.reset_label_retention_period(TEXT, TEXT)
+IS 'removes the retention period override of the series with the given label';
+
+CREATE VIEW SCHEMA_INFO.label_retention AS
+    SELECT
+        r.key,
+        r.value,
+        r.retention_period,
+        ARRAY(
+            SELECT m.metric_name
+            FROM SCHEMA_CATALOG.metric m
+            INNER JOIN SCHEMA_CATALOG.label l ON (l.key = r.key AND l.value = r.value)
+            WHERE EXISTS (
+                SELECT 1
+                FROM SCHEMA_CATALOG.series s
+                WHERE s.metric_id = m.id AND s.labels && ARRAY[l.id]
+            )
+            ORDER BY m.metric_name) AS metrics
+    FROM SCHEMA_CATALOG.label_retention r;
@@ -0,0 +1,165 @@
+DROP VIEW IF EXISTS SCHEMA_INFO.lifecycle_policy;
+DROP FUNCTION IF EXISTS SCHEMA_PROM.reset_metric_lifecycle_policy(TEXT);
+DROP FUNCTION IF EXISTS SCHEMA_PROM.set_metric_lifecycle_policy(TEXT, INTERVAL, INTERVAL, INTERVAL);
+DROP FUNCTION IF EXISTS SCHEMA_CATALOG.run_lifecycle_policy(TEXT);
+
+--drop chunks from metrics tables and delete the appropriate series.
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.drop_metric_chunks(metric_name TEXT, older_than TIMESTAMPTZ)
+    RETURNS BOOLEAN
+    AS $func$
+DECLARE
+    metric_table NAME;
+    check_time TIMESTAMPTZ;
+    time_dimension_id INT;
+    label_array int[];
+BEGIN
+    SELECT table_name
+    INTO STRICT metric_table
+    FROM SCHEMA_CATALOG.get_or_create_metric_table_name(metric_name);
+
+    SELECT older_than + INTERVAL '1 hour'
+    INTO check_time;
+
+    --Get the time dimension id for the time dimension
+    SELECT d.id
+    INTO STRICT time_dimension_id
+    FROM _timescaledb_catalog.hypertable h
+    INNER JOIN _timescaledb_catalog.dimension d ON (d.hypertable_id = h.id)
+    WHERE h.schema_name = 'SCHEMA_DATA' AND h.table_name = metric_table
+    ORDER BY d.id ASC
+    LIMIT 1;
+
+    --Get a tight older_than (EXCLUSIVE) because we want to know the
+    --exact cut-off where things will be dropped
+    SELECT _timescaledb_internal.to_timestamp(range_end)
+    INTO older_than
+    FROM _timescaledb_catalog.chunk c
+    INNER JOIN _timescaledb_catalog.chunk_constraint cc ON (c.id = cc.chunk_id)
+    INNER JOIN _timescaledb_catalog.dimension_slice ds ON (ds.id = cc.dimension_slice_id)
+    --range_end is exclusive so this is everything < older_than (which is also exclusive)
+    WHERE ds.dimension_id = time_dimension_id AND ds.range_end <= _timescaledb_internal.to_unix_microseconds(older_than)
+    ORDER BY range_end DESC
+    LIMIT 1;
+
+    IF older_than IS NULL THEN
+        RETURN false;
+    END IF;
+
+    --chances are that the hour after the drop point will have the most similar
+    --series to what is dropped, so first filter by all series that have been dropped
+    --but that aren't in that first hour and then make sure they aren't in the dataset
+    EXECUTE format(
+    $query$
+        WITH potentially_drop_series AS (
+            SELECT distinct series_id
+            FROM SCHEMA_DATA.%1$I
+            WHERE time < %2$L
+            EXCEPT
+            SELECT distinct series_id
+            FROM SCHEMA_DATA.%1$I
+            WHERE time >= %2$L AND time < %3$L
+        ), confirmed_drop_series AS (
+            SELECT series_id
+            FROM potentially_drop_series
+            WHERE NOT EXISTS (
+                 SELECT 1
+                 FROM  SCHEMA_DATA.%1$I  data_exists
+                 WHERE data_exists.series_id = potentially_drop_series.series_id AND time >= %3$L
+                 --use chunk append + more likely to find something starting at earliest time
+                 ORDER BY time ASC
+                 LIMIT 1
+            )
+        ), deleted_series AS (
+          DELETE from SCHEMA_DATA_SERIES.%1$I
+          WHERE id IN (SELECT series_id FROM confirmed_drop_series)
+          RETURNING id, labels
+        )
+        SELECT ARRAY(SELECT DISTINCT unnest(labels) as label_id
+        FROM deleted_series)
+    $query$, metric_table, older_than, check_time) INTO label_array;
+
+    --needs to be a separate query and not a CTE since this needs to "see"
+    --the series rows deleted above as deleted.
+    EXECUTE format($query$
+    WITH confirmed_drop_labels AS (
+            SELECT label_id
+            FROM unnest($1) as labels(label_id)
+            WHERE NOT EXISTS (
+                 SELECT 1
+                 FROM  SCHEMA_DATA_SERIES.%1$I series_exists
+                 WHERE series_exists.labels && ARRAY[labels.label_id]
+                 LIMIT 1
+            )
+        )
+        DELETE FROM SCHEMA_CATALOG.label
+        WHERE id IN (SELECT * FROM confirmed_drop_labels);
+    $query$, metric_table) USING label_array;
+
+   PERFORM drop_chunks(table_name=>metric_table, schema_name=> 'SCHEMA_DATA', older_than=>older_than);
+   RETURN true;
+END
+$func$
+LANGUAGE PLPGSQL VOLATILE;
+
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+RETURNS SETOF SCHEMA_CATALOG.metric
+AS $$
+        SELECT m.*
+        FROM SCHEMA_CATALOG.metric m
+        WHERE EXISTS (
+            SELECT 1 FROM
+            show_chunks(hypertable=>format('%I.%I', 'SCHEMA_DATA', m.table_name),
+                         older_than=>NOW() - SCHEMA_CATALOG.get_metric_chunk_retention_period(m.metric_name)))
+        --random order also to prevent starvation
+        ORDER BY random()
+$$
+LANGUAGE SQL STABLE;
+
+CREATE OR REPLACE PROCEDURE SCHEMA_PROM.drop_chunks()
+AS $$
+DECLARE
+    r RECORD;
+BEGIN
+    --do one loop with skip locked and then one that blocks to prevent starvation
+    FOR r IN
+        SELECT *
+        FROM SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+    LOOP
+        --lock prevents concurrent drop_chunks on same table
+        PERFORM m.*
+        FROM SCHEMA_CATALOG.metric m
+        WHERE m.id = r.id
+        FOR NO KEY UPDATE SKIP LOCKED;
+
+        CONTINUE WHEN NOT FOUND;
+
+        PERFORM SCHEMA_CATALOG.drop_metric_chunks(r.metric_name, NOW() - SCHEMA_CATALOG.get_metric_chunk_retention_period(r.metric_name));
+        COMMIT;
+    END LOOP;
+
+    FOR r IN
+        SELECT *
+        FROM SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+    LOOP
+        PERFORM SCHEMA_CATALOG.drop_metric_chunks(r.metric_name, NOW() - SCHEMA_CATALOG.get_metric_chunk_retention_period(r.metric_name));
+        COMMIT;
+    END LOOP;
+
+    --then delete the samples expired by label retention overrides from the
+    --chunks that are kept
+    FOR r IN
+        SELECT m.metric_name
+        FROM SCHEMA_CATALOG.metric m
+        WHERE SCHEMA_CATALOG.get_metric_label_retention_period(m.metric_name) IS NOT NULL
+    LOOP
+        CALL SCHEMA_CATALOG.delete_label_retention_expired(r.metric_name);
+    END LOOP;
+END;
+$$ LANGUAGE PLPGSQL;
+COMMENT ON PROCEDURE SCHEMA_PROM.drop_chunks()
+IS 'drops data according to the data retention policy, including the label retention overrides. This procedure should be run regularly in a cron job';
+
+DROP FUNCTION IF EXISTS SCHEMA_CATALOG.get_metric_drop_chunks_older_than(TEXT);
+DROP TABLE IF EXISTS SCHEMA_CATALOG.lifecycle_policy;
+DROP SCHEMA IF EXISTS SCHEMA_ROLLUP CASCADE;
+DELETE FROM public.prom_installation_info WHERE key = 'rollup schema';
@@ -0,0 +1,392 @@
+-- Lifecycle policies: the raw samples of a metric are rolled up into
+-- aggregates at a coarser interval, which are kept longer than the raw data.
+CREATE SCHEMA IF NOT EXISTS SCHEMA_ROLLUP;
+GRANT USAGE ON SCHEMA SCHEMA_ROLLUP TO prom_reader;
+GRANT SELECT ON ALL TABLES IN SCHEMA SCHEMA_ROLLUP TO prom_reader;
+ALTER DEFAULT PRIVILEGES IN SCHEMA SCHEMA_ROLLUP GRANT SELECT ON TABLES TO prom_reader;
+GRANT USAGE ON SCHEMA SCHEMA_ROLLUP TO prom_writer;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA SCHEMA_ROLLUP TO prom_writer;
+ALTER DEFAULT PRIVILEGES IN SCHEMA SCHEMA_ROLLUP GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO prom_writer;
+
+INSERT INTO public.prom_installation_info(key, value) VALUES
+    ('rollup schema',         'SCHEMA_ROLLUP');
+
+CREATE TABLE SCHEMA_CATALOG.lifecycle_policy (
+    metric_name TEXT PRIMARY KEY,
+    raw_retention INTERVAL NOT NULL,
+    rollup_interval INTERVAL NOT NULL,
+    rollup_retention INTERVAL NOT NULL,
+    --the raw samples before this time are rolled up, NULL until the first run
+    rolled_up_until TIMESTAMPTZ,
+    last_run TIMESTAMPTZ,
+    last_error TEXT,
+    CHECK (rollup_interval > INTERVAL '0'),
+    CHECK (rollup_retention > raw_retention)
+);
+
+--raw chunks are dropped before this time: the retention period of the metric,
+--but never samples that are not rolled up yet. NULL when nothing can be dropped.
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.get_metric_drop_chunks_older_than(metric_name TEXT)
+RETURNS TIMESTAMPTZ
+AS $$
+    SELECT CASE
+        WHEN p.metric_name IS NULL THEN
+            now() - SCHEMA_CATALOG.get_metric_chunk_retention_period(get_metric_drop_chunks_older_than.metric_name)
+        WHEN p.rolled_up_until IS NULL THEN
+            NULL
+        ELSE
+            LEAST(now() - SCHEMA_CATALOG.get_metric_chunk_retention_period(get_metric_drop_chunks_older_than.metric_name),
+                  p.rolled_up_until)
+        END
+    FROM (SELECT 1) AS one
+    LEFT JOIN SCHEMA_CATALOG.lifecycle_policy p ON (p.metric_name = get_metric_drop_chunks_older_than.metric_name)
+$$
+LANGUAGE SQL STABLE;
+GRANT EXECUTE ON FUNCTION SCHEMA_CATALOG.get_metric_drop_chunks_older_than(TEXT) TO prom_reader;
+
+--drop chunks from metrics tables and delete the appropriate series.
+--series still referenced by the rollups of the metric are kept.
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.drop_metric_chunks(metric_name TEXT, older_than TIMESTAMPTZ)
+    RETURNS BOOLEAN
+    AS $func$
+DECLARE
+    metric_table NAME;
+    check_time TIMESTAMPTZ;
+    time_dimension_id INT;
+    label_array int[];
+    rollup_filter TEXT := '';
+BEGIN
+    SELECT table_name
+    INTO STRICT metric_table
+    FROM SCHEMA_CATALOG.get_or_create_metric_table_name(metric_name);
+
+    SELECT older_than + INTERVAL '1 hour'
+    INTO check_time;
+
+    --Get the time dimension id for the time dimension
+    SELECT d.id
+    INTO STRICT time_dimension_id
+    FROM _timescaledb_catalog.hypertable h
+    INNER JOIN _timescaledb_catalog.dimension d ON (d.hypertable_id = h.id)
+    WHERE h.schema_name = 'SCHEMA_DATA' AND h.table_name = metric_table
+    ORDER BY d.id ASC
+    LIMIT 1;
+
+    --Get a tight older_than (EXCLUSIVE) because we want to know the
+    --exact cut-off where things will be dropped
+    SELECT _timescaledb_internal.to_timestamp(range_end)
+    INTO older_than
+    FROM _timescaledb_catalog.chunk c
+    INNER JOIN _timescaledb_catalog.chunk_constraint cc ON (c.id = cc.chunk_id)
+    INNER JOIN _timescaledb_catalog.dimension_slice ds ON (ds.id = cc.dimension_slice_id)
+    --range_end is exclusive so this is everything < older_than (which is also exclusive)
+    WHERE ds.dimension_id = time_dimension_id AND ds.range_end <= _timescaledb_internal.to_unix_microseconds(older_than)
+    ORDER BY range_end DESC
+    LIMIT 1;
+
+    IF older_than IS NULL THEN
+        RETURN false;
+    END IF;
+
+    IF to_regclass(format('SCHEMA_ROLLUP.%I', metric_table)) IS NOT NULL THEN
+        rollup_filter := format($filter$
+            AND NOT EXISTS (
+                 SELECT 1
+                 FROM SCHEMA_ROLLUP.%1$I rollup_exists
+                 WHERE rollup_exists.series_id = potentially_drop_series.series_id
+                 LIMIT 1
+            )
+        $filter$, metric_table);
+    END IF;
+
+    --chances are that the hour after the drop point will have the most similar
+    --series to what is dropped, so first filter by all series that have been dropped
+    --but that aren't in that first hour and then make sure they aren't in the dataset
+    EXECUTE format(
+    $query$
+        WITH potentially_drop_series AS (
+            SELECT distinct series_id
+            FROM SCHEMA_DATA.%1$I
+            WHERE time < %2$L
+            EXCEPT
+            SELECT distinct series_id
+            FROM SCHEMA_DATA.%1$I
+            WHERE time >= %2$L AND time < %3$L
+        ), confirmed_drop_series AS (
+            SELECT series_id
+            FROM potentially_drop_series
+            WHERE NOT EXISTS (
+                 SELECT 1
+                 FROM  SCHEMA_DATA.%1$I  data_exists
+                 WHERE data_exists.series_id = potentially_drop_series.series_id AND time >= %3$L
+                 --use chunk append + more likely to find something starting at earliest time
+                 ORDER BY time ASC
+                 LIMIT 1
+            ) %4$s
+        ), deleted_series AS (
+          DELETE from SCHEMA_DATA_SERIES.%1$I
+          WHERE id IN (SELECT series_id FROM confirmed_drop_series)
+          RETURNING id, labels
+        )
+        SELECT ARRAY(SELECT DISTINCT unnest(labels) as label_id
+        FROM deleted_series)
+    $query$, metric_table, older_than, check_time, rollup_filter) INTO label_array;
+
+    --needs to be a separate query and not a CTE since this needs to "see"
+    --the series rows deleted above as deleted.
+    EXECUTE format($query$
+    WITH confirmed_drop_labels AS (
+            SELECT label_id
+            FROM unnest($1) as labels(label_id)
+            WHERE NOT EXISTS (
+                 SELECT 1
+                 FROM  SCHEMA_DATA_SERIES.%1$I series_exists
+                 WHERE series_exists.labels && ARRAY[labels.label_id]
+                 LIMIT 1
+            )
+        )
+        DELETE FROM SCHEMA_CATALOG.label
+        WHERE id IN (SELECT * FROM confirmed_drop_labels);
+    $query$, metric_table) USING label_array;
+
+   PERFORM drop_chunks(table_name=>metric_table, schema_name=> 'SCHEMA_DATA', older_than=>older_than);
+   RETURN true;
+END
+$func$
+LANGUAGE PLPGSQL VOLATILE;
+
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+RETURNS SETOF SCHEMA_CATALOG.metric
+AS $$
+        SELECT m.*
+        FROM SCHEMA_CATALOG.metric m
+        WHERE CASE
+            WHEN SCHEMA_CATALOG.get_metric_drop_chunks_older_than(m.metric_name) IS NULL THEN false
+            ELSE EXISTS (
+                SELECT 1 FROM
+                show_chunks(hypertable=>format('%I.%I', 'SCHEMA_DATA', m.table_name),
+                             older_than=>SCHEMA_CATALOG.get_metric_drop_chunks_older_than(m.metric_name)))
+            END
+        --random order also to prevent starvation
+        ORDER BY random()
+$$
+LANGUAGE SQL STABLE;
+
+CREATE OR REPLACE PROCEDURE SCHEMA_PROM.drop_chunks()
+AS $$
+DECLARE
+    r RECORD;
+BEGIN
+    --do one loop with skip locked and then one that blocks to prevent starvation
+    FOR r IN
+        SELECT *
+        FROM SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+    LOOP
+        --lock prevents concurrent drop_chunks on same table
+        PERFORM m.*
+        FROM SCHEMA_CATALOG.metric m
+        WHERE m.id = r.id
+        FOR NO KEY UPDATE SKIP LOCKED;
+
+        CONTINUE WHEN NOT FOUND;
+
+        PERFORM SCHEMA_CATALOG.drop_metric_chunks(r.metric_name, SCHEMA_CATALOG.get_metric_drop_chunks_older_than(r.metric_name));
+        COMMIT;
+    END LOOP;
+
+    FOR r IN
+        SELECT *
+        FROM SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+    LOOP
+        PERFORM SCHEMA_CATALOG.drop_metric_chunks(r.metric_name, SCHEMA_CATALOG.get_metric_drop_chunks_older_than(r.metric_name));
+        COMMIT;
+    END LOOP;
+
+    --then delete the samples expired by label retention overrides from the
+    --chunks that are kept
+    FOR r IN
+        SELECT m.metric_name
+        FROM SCHEMA_CATALOG.metric m
+        WHERE SCHEMA_CATALOG.get_metric_label_retention_period(m.metric_name) IS NOT NULL
+    LOOP
+        CALL SCHEMA_CATALOG.delete_label_retention_expired(r.metric_name);
+    END LOOP;
+END;
+$$ LANGUAGE PLPGSQL;
+COMMENT ON PROCEDURE SCHEMA_PROM.drop_chunks()
+IS 'drops data according to the data retention policy, including the label retention overrides and keeping the samples lifecycle policies have not rolled up yet. This procedure should be run regularly in a cron job';
+
+--rolls up the raw samples of the metric in the intervals completed since the
+--last run, and drops the rollups older than their retention period. Errors
+--are recorded in the policy and returned instead of raised, so that the
+--other policies still run.
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.run_lifecycle_policy(metric_name TEXT)
+RETURNS TEXT
+AS $func$
+DECLARE
+    policy RECORD;
+    rollup_until TIMESTAMPTZ;
+    rollup_older_than TIMESTAMPTZ;
+    label_array int[];
+    err TEXT;
+BEGIN
+    --lock prevents concurrent runs of the same policy
+    SELECT p.*, m.table_name
+    INTO policy
+    FROM SCHEMA_CATALOG.lifecycle_policy p
+    INNER JOIN SCHEMA_CATALOG.metric m ON (m.metric_name = p.metric_name)
+    WHERE p.metric_name = run_lifecycle_policy.metric_name
+    FOR UPDATE OF p SKIP LOCKED;
+
+    IF NOT FOUND THEN
+        RETURN NULL;
+    END IF;
+
+    BEGIN
+        --wait a whole interval after the end of a bucket for late samples
+        rollup_until := time_bucket(policy.rollup_interval, now() - policy.rollup_interval);
+
+        IF policy.rolled_up_until IS NULL OR policy.rolled_up_until < rollup_until THEN
+            EXECUTE format($query$
+                INSERT INTO SCHEMA_ROLLUP.%1$I(time, series_id, min, max, avg, count)
+                SELECT time_bucket(%2$L, time), series_id, min(value), max(value), avg(value), count(*)
+                FROM SCHEMA_DATA.%1$I
+                WHERE time >= %3$L AND time < %4$L AND NOT SCHEMA_PROM.is_stale_marker(value)
+                GROUP BY 1, 2
+            $query$, policy.table_name, policy.rollup_interval,
+                COALESCE(policy.rolled_up_until, '-infinity'), rollup_until);
+
+            UPDATE SCHEMA_CATALOG.lifecycle_policy p
+            SET rolled_up_until = rollup_until
+            WHERE p.metric_name = policy.metric_name;
+        END IF;
+
+        --delete the series which are neither in the rollups kept nor in the
+        --raw samples, then the labels no series uses anymore
+        rollup_older_than := now() - policy.rollup_retention;
+        EXECUTE format($query$
+            WITH potentially_drop_series AS (
+                SELECT distinct series_id
+                FROM SCHEMA_ROLLUP.%1$I
+                WHERE time < %2$L
+                EXCEPT
+                SELECT distinct series_id
+                FROM SCHEMA_ROLLUP.%1$I
+                WHERE time >= %2$L
+            ), confirmed_drop_series AS (
+                SELECT series_id
+                FROM potentially_drop_series
+                WHERE NOT EXISTS (
+                     SELECT 1
+                     FROM SCHEMA_DATA.%1$I data_exists
+                     WHERE data_exists.series_id = potentially_drop_series.series_id
+                     LIMIT 1
+                )
+            ), deleted_series AS (
+              DELETE from SCHEMA_DATA_SERIES.%1$I
+              WHERE id IN (SELECT series_id FROM confirmed_drop_series)
+              RETURNING id, labels
+            )
+            SELECT ARRAY(SELECT DISTINCT unnest(labels) as label_id
+            FROM deleted_series)
+        $query$, policy.table_name, rollup_older_than) INTO label_array;
+
+        EXECUTE format($query$
+        WITH confirmed_drop_labels AS (
+                SELECT label_id
+                FROM unnest($1) as labels(label_id)
+                WHERE NOT EXISTS (
+                     SELECT 1
+                     FROM  SCHEMA_DATA_SERIES.%1$I series_exists
+                     WHERE series_exists.labels && ARRAY[labels.label_id]
+                     LIMIT 1
+                )
+            )
+            DELETE FROM SCHEMA_CATALOG.label
+            WHERE id IN (SELECT * FROM confirmed_drop_labels);
+        $query$, policy.table_name) USING label_array;
+
+        PERFORM drop_chunks(table_name=>policy.table_name, schema_name=>'SCHEMA_ROLLUP', older_than=>rollup_older_than);
+    EXCEPTION WHEN OTHERS THEN
+        err := SQLERRM;
+    END;
+
+    UPDATE SCHEMA_CATALOG.lifecycle_policy p
+    SET last_run = now(), last_error = err
+    WHERE p.metric_name = policy.metric_name;
+    RETURN err;
+END
+$func$
+LANGUAGE PLPGSQL VOLATILE;
+GRANT EXECUTE ON FUNCTION SCHEMA_CATALOG.run_lifecycle_policy(TEXT) TO prom_writer;
+
+CREATE OR REPLACE FUNCTION SCHEMA_PROM.set_metric_lifecycle_policy(metric_name TEXT, raw_retention INTERVAL,
+    rollup_interval INTERVAL, rollup_retention INTERVAL)
+RETURNS BOOLEAN
+AS $func$
+DECLARE
+    metric_table NAME;
+    current_interval INTERVAL;
+BEGIN
+    SELECT p.rollup_interval
+    INTO current_interval
+    FROM SCHEMA_CATALOG.lifecycle_policy p
+    WHERE p.metric_name = set_metric_lifecycle_policy.metric_name;
+
+    IF current_interval IS NOT NULL AND current_interval <> rollup_interval THEN
+        RAISE EXCEPTION 'cannot change the rollup interval of % from % to %', metric_name, current_interval, rollup_interval
+        USING HINT = 'reset the lifecycle policy and drop the rollup table first';
+    END IF;
+
+    --use get_or_create_metric_table_name because we want to be able to set /before/ any data is ingested
+    SELECT table_name
+    INTO STRICT metric_table
+    FROM SCHEMA_CATALOG.get_or_create_metric_table_name(metric_name);
+
+    IF to_regclass(format('SCHEMA_ROLLUP.%I', metric_table)) IS NULL THEN
+        EXECUTE format('CREATE TABLE SCHEMA_ROLLUP.%I(time TIMESTAMPTZ NOT NULL, series_id INT NOT NULL, min DOUBLE PRECISION, max DOUBLE PRECISION, avg DOUBLE PRECISION, count BIGINT NOT NULL)',
+                       metric_table);
+        EXECUTE format('CREATE INDEX ON SCHEMA_ROLLUP.%I (series_id, time) INCLUDE (avg)', metric_table);
+        --one chunk per week of 5 minute rollups
+        PERFORM create_hypertable(format('SCHEMA_ROLLUP.%I', metric_table), 'time',
+                                  chunk_time_interval=>rollup_interval * 2016,
+                                  create_default_indexes=>false);
+    END IF;
+
+    INSERT INTO SCHEMA_CATALOG.lifecycle_policy(metric_name, raw_retention, rollup_interval, rollup_retention)
+    VALUES (metric_name, raw_retention, rollup_interval, rollup_retention)
+    ON CONFLICT ON CONSTRAINT lifecycle_policy_pkey DO UPDATE
+    SET raw_retention = EXCLUDED.raw_retention, rollup_retention = EXCLUDED.rollup_retention;
+
+    PERFORM SCHEMA_PROM.set_metric_retention_period(metric_name, raw_retention);
+    RETURN true;
+END
+$func$
+LANGUAGE PLPGSQL VOLATILE;
+COMMENT ON FUNCTION SCHEMA_PROM.set_metric_lifecycle_policy(TEXT, INTERVAL, INTERVAL, INTERVAL)
+IS 'keep the raw samples of a metric for raw_retention and rollups at rollup_interval for rollup_retention (this overrides the metric retention period)';
+
+CREATE OR REPLACE FUNCTION SCHEMA_PROM.reset_metric_lifecycle_policy(metric_name TEXT)
+RETURNS BOOLEAN
+AS $func$
+    DELETE FROM SCHEMA_CATALOG.lifecycle_policy p
+    WHERE p.metric_name = reset_metric_lifecycle_policy.metric_name;
+    SELECT SCHEMA_PROM.reset_metric_retention_period(metric_name);
+$func$
+LANGUAGE SQL VOLATILE;
+COMMENT ON FUNCTION SCHEMA_PROM.reset_metric_lifecycle_policy(TEXT)
+IS 'removes the lifecycle policy of a metric, keeping its rollup table, and resets its retention period to the default';
+
+CREATE VIEW SCHEMA_INFO.lifecycle_policy AS
+    SELECT
+        p.metric_name,
+        p.raw_retention,
+        p.rollup_interval,
+        p.rollup_retention,
+        format('%I.%I', 'SCHEMA_ROLLUP', m.table_name) AS rollup_table,
+        p.rolled_up_until,
+        now() - p.rolled_up_until AS rollup_lag,
+        p.last_run,
+        p.last_error
+    FROM SCHEMA_CATALOG.lifecycle_policy p
+    LEFT JOIN SCHEMA_CATALOG.metric m ON (m.metric_name = p.metric_name);
@@ -0,0 +1,7 @@
+-- The time each series last went stale, recorded instead of storing the
+-- staleness markers of Prometheus when the connector runs with
+-- -stale-markers=liveness. Reads emit a marker at that time.
+CREATE TABLE SCHEMA_CATALOG.series_liveness (
+    series_id BIGINT PRIMARY KEY,
+    stale_at TIMESTAMPTZ NOT NULL
+);
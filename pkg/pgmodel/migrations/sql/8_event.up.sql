@@ -0,0 +1,9 @@
+-- The operational events of the connectors, such as metric tables created or
+-- leadership changes, written when a connector runs with -events-table.
+CREATE TABLE SCHEMA_CATALOG.event (
+    time TIMESTAMPTZ NOT NULL,
+    type TEXT NOT NULL,
+    instance_id TEXT,
+    attributes JSONB NOT NULL DEFAULT '{}'
+);
+CREATE INDEX event_time_idx ON SCHEMA_CATALOG.event (time DESC);
@@ -0,0 +1,46 @@
+CREATE OR REPLACE PROCEDURE SCHEMA_PROM.drop_chunks()
+AS $$
+DECLARE
+    r RECORD;
+BEGIN
+    --do one loop with skip locked and then one that blocks to prevent starvation
+    FOR r IN
+        SELECT *
+        FROM SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+    LOOP
+        --lock prevents concurrent drop_chunks on same table
+        PERFORM m.*
+        FROM SCHEMA_CATALOG.metric m
+        WHERE m.id = r.id
+        FOR NO KEY UPDATE SKIP LOCKED;
+
+        CONTINUE WHEN NOT FOUND;
+
+        PERFORM SCHEMA_CATALOG.drop_metric_chunks(r.metric_name, SCHEMA_CATALOG.get_metric_drop_chunks_older_than(r.metric_name));
+        COMMIT;
+    END LOOP;
+
+    FOR r IN
+        SELECT *
+        FROM SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+    LOOP
+        PERFORM SCHEMA_CATALOG.drop_metric_chunks(r.metric_name, SCHEMA_CATALOG.get_metric_drop_chunks_older_than(r.metric_name));
+        COMMIT;
+    END LOOP;
+
+    --then delete the samples expired by label retention overrides from the
+    --chunks that are kept
+    FOR r IN
+        SELECT m.metric_name
+        FROM SCHEMA_CATALOG.metric m
+        WHERE SCHEMA_CATALOG.get_metric_label_retention_period(m.metric_name) IS NOT NULL
+    LOOP
+        CALL SCHEMA_CATALOG.delete_label_retention_expired(r.metric_name);
+    END LOOP;
+END;
+$$ LANGUAGE PLPGSQL;
+COMMENT ON PROCEDURE SCHEMA_PROM.drop_chunks()
+IS 'drops data according to the data retention policy, including the label retention overrides and keeping the samples lifecycle policies have not rolled up yet. This procedure should be run regularly in a cron job';
+
+DROP TABLE IF EXISTS SCHEMA_CATALOG.histogram;
+DROP FUNCTION IF EXISTS SCHEMA_CATALOG.drop_histogram_chunks(TIMESTAMPTZ);
@@ -0,0 +1,83 @@
+-- The native histogram samples of all the metrics. The buckets are stored as
+-- in Prometheus, as spans of consecutive buckets, but with absolute counts:
+-- float_counts tells the float histograms apart from the integer ones, whose
+-- counts are delta encoded on the wire.
+CREATE TABLE SCHEMA_CATALOG.histogram (
+    time TIMESTAMPTZ NOT NULL,
+    series_id BIGINT NOT NULL,
+    count DOUBLE PRECISION NOT NULL,
+    sum DOUBLE PRECISION NOT NULL,
+    schema INT NOT NULL,
+    zero_threshold DOUBLE PRECISION NOT NULL,
+    zero_count DOUBLE PRECISION NOT NULL,
+    negative_span_offsets INT[] NOT NULL,
+    negative_span_lengths INT[] NOT NULL,
+    negative_counts DOUBLE PRECISION[] NOT NULL,
+    positive_span_offsets INT[] NOT NULL,
+    positive_span_lengths INT[] NOT NULL,
+    positive_counts DOUBLE PRECISION[] NOT NULL,
+    custom_values DOUBLE PRECISION[] NOT NULL,
+    reset_hint SMALLINT NOT NULL DEFAULT 0,
+    float_counts BOOLEAN NOT NULL
+);
+-- Unique so that the retries of a write skip the histograms already stored.
+CREATE UNIQUE INDEX histogram_series_id_time_idx ON SCHEMA_CATALOG.histogram (series_id, time);
+SELECT create_hypertable('SCHEMA_CATALOG.histogram', 'time',
+                         chunk_time_interval=>SCHEMA_CATALOG.get_default_chunk_interval(),
+                         create_default_indexes=>false);
+
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.drop_histogram_chunks(older_than TIMESTAMPTZ)
+RETURNS VOID
+AS $func$
+BEGIN
+    PERFORM drop_chunks(table_name=>'histogram', schema_name=>'SCHEMA_CATALOG', older_than=>older_than);
+END
+$func$
+LANGUAGE PLPGSQL VOLATILE;
+
+CREATE OR REPLACE PROCEDURE SCHEMA_PROM.drop_chunks()
+AS $$
+DECLARE
+    r RECORD;
+BEGIN
+    --do one loop with skip locked and then one that blocks to prevent starvation
+    FOR r IN
+        SELECT *
+        FROM SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+    LOOP
+        --lock prevents concurrent drop_chunks on same table
+        PERFORM m.*
+        FROM SCHEMA_CATALOG.metric m
+        WHERE m.id = r.id
+        FOR NO KEY UPDATE SKIP LOCKED;
+
+        CONTINUE WHEN NOT FOUND;
+
+        PERFORM SCHEMA_CATALOG.drop_metric_chunks(r.metric_name, SCHEMA_CATALOG.get_metric_drop_chunks_older_than(r.metric_name));
+        COMMIT;
+    END LOOP;
+
+    FOR r IN
+        SELECT *
+        FROM SCHEMA_CATALOG.get_metrics_that_need_drop_chunk()
+    LOOP
+        PERFORM SCHEMA_CATALOG.drop_metric_chunks(r.metric_name, SCHEMA_CATALOG.get_metric_drop_chunks_older_than(r.metric_name));
+        COMMIT;
+    END LOOP;
+
+    --then delete the samples expired by label retention overrides from the
+    --chunks that are kept
+    FOR r IN
+        SELECT m.metric_name
+        FROM SCHEMA_CATALOG.metric m
+        WHERE SCHEMA_CATALOG.get_metric_label_retention_period(m.metric_name) IS NOT NULL
+    LOOP
+        CALL SCHEMA_CATALOG.delete_label_retention_expired(r.metric_name);
+    END LOOP;
+
+    --and the native histograms, which all follow the default retention period
+    PERFORM SCHEMA_CATALOG.drop_histogram_chunks(now() - SCHEMA_CATALOG.get_default_retention_period());
+END;
+$$ LANGUAGE PLPGSQL;
+COMMENT ON PROCEDURE SCHEMA_PROM.drop_chunks()
+IS 'drops data according to the data retention policy, including the label retention overrides and the native histograms, and keeping the samples lifecycle policies have not rolled up yet. This procedure should be run regularly in a cron job';
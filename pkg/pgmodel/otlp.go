@@ -0,0 +1,231 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/value"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// otlpAttributeValue returns the label value of an attribute. The arrays are
+// formatted as JSON lists of their values, and the bytes in base64.
+func otlpAttributeValue(v *commonpb.AnyValue) string {
+	switch v := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64)
+	case *commonpb.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(v.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]string, 0, len(v.ArrayValue.GetValues()))
+		for _, e := range v.ArrayValue.GetValues() {
+			values = append(values, otlpAttributeValue(e))
+		}
+		data, _ := json.Marshal(values)
+		return string(data)
+	}
+	return ""
+}
+
+// ParseOTLPMetrics converts the metrics of an OTLP/HTTP export request, in
+// protobuf or in JSON when contentType says so, into a write request for
+// Ingest, along with the number of data points that have no Prometheus
+// equivalent and were dropped: those of delta sums and histograms, and of
+// exponential histograms.
+//
+// The gauges, the sums and the summaries map to the series of the same name,
+// monotonic sums getting a _total suffix, and the histograms to their
+// _bucket, _sum and _count series. The attributes of the data points become
+// labels, and the service.name and service.instance.id attributes of the
+// resource the job and instance labels.
+func ParseOTLPMetrics(data []byte, contentType string) (*prompb.WriteRequest, int, error) {
+	var (
+		req colmetricspb.ExportMetricsServiceRequest
+		err error
+	)
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/json" {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, &req)
+	} else {
+		err = proto.Unmarshal(data, &req)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("parsing OTLP metrics: %w", err)
+	}
+
+	c := otlpConverter{req: NewWriteRequest(), series: make(map[string]int)}
+	for _, rm := range req.GetResourceMetrics() {
+		resource := otlpResourceLabels(rm.GetResource().GetAttributes())
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				c.convert(m, resource)
+			}
+		}
+	}
+	return c.req, c.dropped, nil
+}
+
+// otlpResourceLabels returns the job and instance labels of the attributes
+// of a resource, following the conventions of the Prometheus exporters of
+// OpenTelemetry.
+func otlpResourceLabels(attributes []*commonpb.KeyValue) labels.Labels {
+	var name, namespace, instance string
+	for _, a := range attributes {
+		switch a.GetKey() {
+		case "service.name":
+			name = otlpAttributeValue(a.GetValue())
+		case "service.namespace":
+			namespace = otlpAttributeValue(a.GetValue())
+		case "service.instance.id":
+			instance = otlpAttributeValue(a.GetValue())
+		}
+	}
+	ls := make(labels.Labels, 0, 2)
+	if name != "" {
+		if namespace != "" {
+			name = namespace + "/" + name
+		}
+		ls = append(ls, labels.Label{Name: "job", Value: name})
+	}
+	if instance != "" {
+		ls = append(ls, labels.Label{Name: "instance", Value: instance})
+	}
+	return ls
+}
+
+// otlpConverter gathers the samples of the same series into a single time
+// series of the write request.
+type otlpConverter struct {
+	req     *prompb.WriteRequest
+	series  map[string]int
+	dropped int
+}
+
+func (c *otlpConverter) convert(m *metricspb.Metric, resource labels.Labels) {
+	name := sanitizeName(m.GetName(), true)
+	switch data := m.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		for _, p := range data.Gauge.GetDataPoints() {
+			c.add(name, resource, p.GetAttributes(), p.GetFlags(), p.GetTimeUnixNano(), otlpNumberValue(p))
+		}
+	case *metricspb.Metric_Sum:
+		sum := data.Sum
+		if sum.GetAggregationTemporality() != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+			c.dropped += len(sum.GetDataPoints())
+			return
+		}
+		if sum.GetIsMonotonic() && !strings.HasSuffix(name, "_total") {
+			name += "_total"
+		}
+		for _, p := range sum.GetDataPoints() {
+			c.add(name, resource, p.GetAttributes(), p.GetFlags(), p.GetTimeUnixNano(), otlpNumberValue(p))
+		}
+	case *metricspb.Metric_Histogram:
+		histogram := data.Histogram
+		if histogram.GetAggregationTemporality() != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+			c.dropped += len(histogram.GetDataPoints())
+			return
+		}
+		for _, p := range histogram.GetDataPoints() {
+			c.addHistogram(name, resource, p)
+		}
+	case *metricspb.Metric_Summary:
+		for _, p := range data.Summary.GetDataPoints() {
+			c.addSummary(name, resource, p)
+		}
+	case *metricspb.Metric_ExponentialHistogram:
+		c.dropped += len(data.ExponentialHistogram.GetDataPoints())
+	}
+}
+
+func otlpNumberValue(p *metricspb.NumberDataPoint) float64 {
+	switch v := p.GetValue().(type) {
+	case *metricspb.NumberDataPoint_AsDouble:
+		return v.AsDouble
+	case *metricspb.NumberDataPoint_AsInt:
+		return float64(v.AsInt)
+	}
+	return 0
+}
+
+func (c *otlpConverter) addHistogram(name string, resource labels.Labels, p *metricspb.HistogramDataPoint) {
+	var cumulative uint64
+	counts := p.GetBucketCounts()
+	for i, bound := range p.GetExplicitBounds() {
+		if i < len(counts) {
+			cumulative += counts[i]
+		}
+		le := labels.Label{Name: labels.BucketLabel, Value: strconv.FormatFloat(bound, 'g', -1, 64)}
+		c.add(name+"_bucket", resource, p.GetAttributes(), p.GetFlags(), p.GetTimeUnixNano(), float64(cumulative), le)
+	}
+	inf := labels.Label{Name: labels.BucketLabel, Value: "+Inf"}
+	c.add(name+"_bucket", resource, p.GetAttributes(), p.GetFlags(), p.GetTimeUnixNano(), float64(p.GetCount()), inf)
+	if p.Sum != nil {
+		c.add(name+"_sum", resource, p.GetAttributes(), p.GetFlags(), p.GetTimeUnixNano(), p.GetSum())
+	}
+	c.add(name+"_count", resource, p.GetAttributes(), p.GetFlags(), p.GetTimeUnixNano(), float64(p.GetCount()))
+}
+
+func (c *otlpConverter) addSummary(name string, resource labels.Labels, p *metricspb.SummaryDataPoint) {
+	for _, q := range p.GetQuantileValues() {
+		quantile := labels.Label{Name: "quantile", Value: strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64)}
+		c.add(name, resource, p.GetAttributes(), p.GetFlags(), p.GetTimeUnixNano(), q.GetValue(), quantile)
+	}
+	c.add(name+"_sum", resource, p.GetAttributes(), p.GetFlags(), p.GetTimeUnixNano(), p.GetSum())
+	c.add(name+"_count", resource, p.GetAttributes(), p.GetFlags(), p.GetTimeUnixNano(), float64(p.GetCount()))
+}
+
+// add appends a sample to the series named name, labeled with the resource
+// labels, the attributes and extra. The data points without a recorded value
+// are stored as staleness markers.
+func (c *otlpConverter) add(name string, resource labels.Labels, attributes []*commonpb.KeyValue, flags uint32, timeUnixNano uint64, v float64, extra ...labels.Label) {
+	b := labels.NewBuilder(resource)
+	for _, a := range attributes {
+		b.Set(sanitizeName(a.GetKey(), false), otlpAttributeValue(a.GetValue()))
+	}
+	for _, l := range extra {
+		b.Set(l.Name, l.Value)
+	}
+	b.Set(labels.MetricName, name)
+	lset := b.Labels()
+
+	if flags&uint32(metricspb.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK) != 0 {
+		v = math.Float64frombits(value.StaleNaN)
+	}
+	sample := prompb.Sample{Timestamp: int64(timeUnixNano) / 1e6, Value: v}
+
+	key := lset.String()
+	i, ok := c.series[key]
+	if !ok {
+		i = len(c.req.Timeseries)
+		c.series[key] = i
+		pbLabels := make([]prompb.Label, 0, len(lset))
+		for _, l := range lset {
+			pbLabels = append(pbLabels, prompb.Label{Name: l.Name, Value: l.Value})
+		}
+		c.req.Timeseries = append(c.req.Timeseries, prompb.TimeSeries{Labels: pbLabels})
+	}
+	c.req.Timeseries[i].Samples = append(c.req.Timeseries[i].Samples, sample)
+}
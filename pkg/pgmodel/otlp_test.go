@@ -0,0 +1,166 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/prometheus/pkg/value"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const otlpTestJSON = `{"resourceMetrics": [{
+  "resource": {"attributes": [
+    {"key": "service.name", "value": {"stringValue": "api"}},
+    {"key": "service.namespace", "value": {"stringValue": "shop"}},
+    {"key": "service.instance.id", "value": {"stringValue": "pod-1"}},
+    {"key": "host.name", "value": {"stringValue": "node-1"}}
+  ]},
+  "scopeMetrics": [{"metrics": [
+    {"name": "process.memory.usage", "gauge": {"dataPoints": [
+      {"attributes": [{"key": "state", "value": {"stringValue": "used"}}], "timeUnixNano": "1600000000000000000", "asDouble": 42.5}
+    ]}},
+    {"name": "http.requests", "sum": {"aggregationTemporality": 2, "isMonotonic": true, "dataPoints": [
+      {"attributes": [{"key": "http.method", "value": {"stringValue": "GET"}}], "timeUnixNano": "1600000000000000000", "asInt": "7"}
+    ]}},
+    {"name": "http.delta", "sum": {"aggregationTemporality": "AGGREGATION_TEMPORALITY_DELTA", "dataPoints": [
+      {"timeUnixNano": "1600000000000000000", "asInt": "1"}
+    ]}},
+    {"name": "latency", "histogram": {"aggregationTemporality": 2, "dataPoints": [
+      {"timeUnixNano": "1600000000000000000", "count": "6", "sum": 4.5, "bucketCounts": ["1", "2", "3"], "explicitBounds": [0.1, 1]}
+    ]}},
+    {"name": "duration", "summary": {"dataPoints": [
+      {"timeUnixNano": "1600000000000000000", "count": "5", "sum": 10, "quantileValues": [{"quantile": 0.5, "value": 0.2}]}
+    ]}},
+    {"name": "sizes", "exponentialHistogram": {"dataPoints": [{"count": "1"}, {"count": "2"}]}}
+  ]}]
+}]}`
+
+// The protobuf encoding of otlpTestJSON.
+func otlpTestProto() []byte {
+	str := func(v string) *commonpb.AnyValue {
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}
+	}
+	kv := func(key, value string) *commonpb.KeyValue {
+		return &commonpb.KeyValue{Key: key, Value: str(value)}
+	}
+	const ts = 1600000000000000000
+	sum := 4.5
+	req := &colmetricspb.ExportMetricsServiceRequest{ResourceMetrics: []*metricspb.ResourceMetrics{{
+		Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+			kv("service.name", "api"),
+			kv("service.namespace", "shop"),
+			kv("service.instance.id", "pod-1"),
+			kv("host.name", "node-1"),
+		}},
+		ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: []*metricspb.Metric{
+			{Name: "process.memory.usage", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{
+				{Attributes: []*commonpb.KeyValue{kv("state", "used")}, TimeUnixNano: ts, Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 42.5}},
+			}}}},
+			{Name: "http.requests", Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+				DataPoints: []*metricspb.NumberDataPoint{
+					{Attributes: []*commonpb.KeyValue{kv("http.method", "GET")}, TimeUnixNano: ts, Value: &metricspb.NumberDataPoint_AsInt{AsInt: 7}},
+				},
+			}}},
+			{Name: "http.delta", Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+				DataPoints:             []*metricspb.NumberDataPoint{{TimeUnixNano: ts, Value: &metricspb.NumberDataPoint_AsInt{AsInt: 1}}},
+			}}},
+			{Name: "latency", Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				DataPoints: []*metricspb.HistogramDataPoint{
+					{TimeUnixNano: ts, Count: 6, Sum: &sum, BucketCounts: []uint64{1, 2, 3}, ExplicitBounds: []float64{0.1, 1}},
+				},
+			}}},
+			{Name: "duration", Data: &metricspb.Metric_Summary{Summary: &metricspb.Summary{DataPoints: []*metricspb.SummaryDataPoint{
+				{TimeUnixNano: ts, Count: 5, Sum: 10, QuantileValues: []*metricspb.SummaryDataPoint_ValueAtQuantile{{Quantile: 0.5, Value: 0.2}}},
+			}}}},
+			{Name: "sizes", Data: &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{
+				DataPoints: []*metricspb.ExponentialHistogramDataPoint{{Count: 1}, {Count: 2}},
+			}}},
+		}}},
+	}}}
+	data, err := proto.Marshal(req)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func TestParseOTLPMetrics(t *testing.T) {
+	series := func(v float64, nameAndLabels ...string) prompb.TimeSeries {
+		ts := prompb.TimeSeries{Samples: []prompb.Sample{{Timestamp: 1600000000000, Value: v}}}
+		for i := 0; i < len(nameAndLabels); i += 2 {
+			ts.Labels = append(ts.Labels, prompb.Label{Name: nameAndLabels[i], Value: nameAndLabels[i+1]})
+		}
+		return ts
+	}
+	expected := []prompb.TimeSeries{
+		series(42.5, "__name__", "process_memory_usage", "instance", "pod-1", "job", "shop/api", "state", "used"),
+		series(7, "__name__", "http_requests_total", "http_method", "GET", "instance", "pod-1", "job", "shop/api"),
+		series(1, "__name__", "latency_bucket", "instance", "pod-1", "job", "shop/api", "le", "0.1"),
+		series(3, "__name__", "latency_bucket", "instance", "pod-1", "job", "shop/api", "le", "1"),
+		series(6, "__name__", "latency_bucket", "instance", "pod-1", "job", "shop/api", "le", "+Inf"),
+		series(4.5, "__name__", "latency_sum", "instance", "pod-1", "job", "shop/api"),
+		series(6, "__name__", "latency_count", "instance", "pod-1", "job", "shop/api"),
+		series(0.2, "__name__", "duration", "instance", "pod-1", "job", "shop/api", "quantile", "0.5"),
+		series(10, "__name__", "duration_sum", "instance", "pod-1", "job", "shop/api"),
+		series(5, "__name__", "duration_count", "instance", "pod-1", "job", "shop/api"),
+	}
+
+	testCases := []struct {
+		name        string
+		data        []byte
+		contentType string
+	}{
+		{name: "JSON", data: []byte(otlpTestJSON), contentType: "application/json"},
+		{name: "protobuf", data: otlpTestProto(), contentType: "application/x-protobuf"},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			req, dropped, err := ParseOTLPMetrics(c.data, c.contentType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(req.Timeseries, expected) {
+				t.Errorf("unexpected series:\ngot\n%v\nwanted\n%v", req.Timeseries, expected)
+			}
+			if dropped != 3 {
+				t.Errorf("unexpected dropped data points: got %d wanted 3", dropped)
+			}
+		})
+	}
+}
+
+func TestParseOTLPMetricsNoRecordedValue(t *testing.T) {
+	data := `{"resourceMetrics": [{"scopeMetrics": [{"metrics": [
+	  {"name": "up", "gauge": {"dataPoints": [{"timeUnixNano": "1000000", "asDouble": 1, "flags": 1}]}}
+	]}]}]}`
+	req, _, err := ParseOTLPMetrics([]byte(data), "application/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Timeseries) != 1 || !value.IsStaleNaN(req.Timeseries[0].Samples[0].Value) {
+		t.Errorf("expected a staleness marker, got %v", req.Timeseries)
+	}
+}
+
+func TestParseOTLPMetricsInvalid(t *testing.T) {
+	if _, _, err := ParseOTLPMetrics([]byte("{"), "application/json"); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+	truncated := otlpTestProto()
+	if _, _, err := ParseOTLPMetrics(truncated[:len(truncated)-3], "application/x-protobuf"); err == nil {
+		t.Error("expected an error for a truncated protobuf message")
+	}
+}
@@ -0,0 +1,154 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// writeLedgerTTL is how long the metrics stored by a partially failed
+	// write are remembered, waiting for its retry.
+	writeLedgerTTL = 15 * time.Minute
+	// writeLedgerMaxEntries bounds the partially failed writes remembered.
+	writeLedgerMaxEntries = 10000
+	// maxReportedFailures bounds the failed metrics listed in the message
+	// of a PartialWriteError.
+	maxReportedFailures = 5
+)
+
+// PartialWriteError reports a write of which only some metrics were stored.
+// Retrying the same write, identified with WithWriteID, only inserts the
+// failed metrics, so that the stored samples are not written twice.
+type PartialWriteError struct {
+	// Inserted is the number of samples stored or acknowledged.
+	Inserted uint64
+	// Failed maps each failed metric to its error.
+	Failed map[string]error
+}
+
+func (e *PartialWriteError) Error() string {
+	metrics := make([]string, 0, len(e.Failed))
+	for metric := range e.Failed {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	failures := make([]string, 0, maxReportedFailures)
+	for _, metric := range metrics {
+		if len(failures) == maxReportedFailures {
+			failures = append(failures, "...")
+			break
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", metric, e.Failed[metric]))
+	}
+	return fmt.Sprintf("failed to insert %d metrics (%s)", len(e.Failed), strings.Join(failures, "; "))
+}
+
+type writeIDKey struct{}
+
+// WithWriteID returns ctx identifying the write request it serves, for
+// instance with a hash of its body, so that the metrics already stored are
+// skipped when the request is retried after a PartialWriteError.
+func WithWriteID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, writeIDKey{}, id)
+}
+
+func writeIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(writeIDKey{}).(string)
+	return id
+}
+
+// writeLedger remembers the metrics stored by the partially failed writes.
+type writeLedger struct {
+	lock    sync.Mutex
+	entries map[string]*ledgerEntry
+}
+
+type ledgerEntry struct {
+	stored  map[string]bool
+	expires time.Time
+}
+
+func newWriteLedger() *writeLedger {
+	return &writeLedger{entries: make(map[string]*ledgerEntry)}
+}
+
+// skipStored removes from rows the metrics already stored by a previous
+// attempt of the write id, returning the number of samples removed.
+func (l *writeLedger) skipStored(id string, rows map[string][]samplesInfo, now time.Time) uint64 {
+	if id == "" {
+		return 0
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	e, ok := l.entries[id]
+	if !ok {
+		return 0
+	}
+	if now.After(e.expires) {
+		delete(l.entries, id)
+		return 0
+	}
+
+	var skipped uint64
+	for metric := range e.stored {
+		if data, ok := rows[metric]; ok {
+			skipped += uint64(countSamples(data))
+			delete(rows, metric)
+		}
+	}
+	return skipped
+}
+
+// record remembers the metrics stored by an attempt of the write id.
+func (l *writeLedger) record(id string, stored []string, now time.Time) {
+	if id == "" || len(stored) == 0 {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	e, ok := l.entries[id]
+	if !ok {
+		if len(l.entries) >= writeLedgerMaxEntries {
+			l.evict(now)
+		}
+		e = &ledgerEntry{stored: make(map[string]bool)}
+		l.entries[id] = e
+	}
+	e.expires = now.Add(writeLedgerTTL)
+	for _, metric := range stored {
+		e.stored[metric] = true
+	}
+}
+
+// forget drops the write id once it fully succeeded.
+func (l *writeLedger) forget(id string) {
+	if id == "" {
+		return
+	}
+	l.lock.Lock()
+	delete(l.entries, id)
+	l.lock.Unlock()
+}
+
+// evict drops the expired entries, or an arbitrary one if none expired.
+func (l *writeLedger) evict(now time.Time) {
+	var victim string
+	for id, e := range l.entries {
+		if now.After(e.expires) {
+			delete(l.entries, id)
+		}
+		victim = id
+	}
+	if len(l.entries) >= writeLedgerMaxEntries {
+		delete(l.entries, victim)
+	}
+}
@@ -0,0 +1,133 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestWriteLedger(t *testing.T) {
+	l := newWriteLedger()
+	now := time.Now()
+	rows := func() map[string][]samplesInfo {
+		return map[string][]samplesInfo{
+			"a": {{samples: make([]prompb.Sample, 2)}},
+			"b": {{samples: make([]prompb.Sample, 3)}},
+		}
+	}
+
+	l.record("w1", []string{"a"}, now)
+	l.record("", []string{"b"}, now)
+
+	r := rows()
+	if skipped := l.skipStored("w1", r, now); skipped != 2 || len(r) != 1 || r["b"] == nil {
+		t.Errorf("stored metric not skipped: skipped %d, left %v", skipped, r)
+	}
+	r = rows()
+	if skipped := l.skipStored("w2", r, now); skipped != 0 || len(r) != 2 {
+		t.Errorf("metric of another write skipped: skipped %d, left %v", skipped, r)
+	}
+	r = rows()
+	if skipped := l.skipStored("w1", r, now.Add(writeLedgerTTL+time.Second)); skipped != 0 || len(r) != 2 {
+		t.Errorf("expired write skipped: skipped %d, left %v", skipped, r)
+	}
+
+	l.record("w1", []string{"a"}, now)
+	l.forget("w1")
+	r = rows()
+	if skipped := l.skipStored("w1", r, now); skipped != 0 {
+		t.Errorf("forgotten write skipped: skipped %d", skipped)
+	}
+}
+
+func TestPartialWriteError(t *testing.T) {
+	failed := make(map[string]error)
+	for i := 0; i < maxReportedFailures+1; i++ {
+		failed[fmt.Sprintf("metric_%d", i)] = fmt.Errorf("error %d", i)
+	}
+	expected := "failed to insert 6 metrics (metric_0: error 0; metric_1: error 1; metric_2: error 2; metric_3: error 3; metric_4: error 4; ...)"
+	if msg := (&PartialWriteError{Failed: failed}).Error(); msg != expected {
+		t.Errorf("unexpected message:\ngot\n%s\nwanted\n%s", msg, expected)
+	}
+}
+
+// failingTableConn fails the COPYs into a table.
+type failingTableConn struct {
+	*mockPGXConn
+	lock      sync.Mutex
+	failTable string
+}
+
+func (c *failingTableConn) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	copied, err := c.mockPGXConn.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if tableName[1] == c.failTable {
+		return 0, fmt.Errorf("copy failed")
+	}
+	return copied, err
+}
+
+func (c *failingTableConn) setFailTable(table string) {
+	c.lock.Lock()
+	c.failTable = table
+	c.lock.Unlock()
+}
+
+func TestPGXInserterPartialWrite(t *testing.T) {
+	mock := &failingTableConn{mockPGXConn: &mockPGXConn{}, failTable: "table_1"}
+	mockMetrics := &mockMetricCache{metricCache: map[string]string{"metric_0": "table_0", "metric_1": "table_1"}}
+	inserter, err := newPgxInserter(mock, mockMetrics, &Cfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inserter.Close()
+
+	rows := func() map[string][]samplesInfo {
+		return map[string][]samplesInfo{
+			"metric_0": {{seriesID: 1, samples: make([]prompb.Sample, 2)}},
+			"metric_1": {{seriesID: 2, samples: make([]prompb.Sample, 3)}},
+		}
+	}
+	ctx := WithWriteID(context.Background(), "w1")
+
+	inserted, err := inserter.InsertData(ctx, rows())
+	partial, ok := err.(*PartialWriteError)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if inserted != 2 || partial.Inserted != 2 || len(partial.Failed) != 1 || partial.Failed["metric_1"] == nil {
+		t.Fatalf("unexpected partial write: %d %+v", inserted, partial)
+	}
+
+	// The retry only inserts the failed metric.
+	mock.setFailTable("")
+	skippedBefore := testutil.ToFloat64(retrySkippedSamples)
+	if inserted, err = inserter.InsertData(ctx, rows()); err != nil || inserted != 5 {
+		t.Fatalf("unexpected retry result: %d %v", inserted, err)
+	}
+	if skipped := testutil.ToFloat64(retrySkippedSamples) - skippedBefore; skipped != 2 {
+		t.Errorf("unexpected skipped samples: got %v wanted 2", skipped)
+	}
+	tables := make([]string, 0)
+	for _, table := range mock.CopyFromTableName {
+		tables = append(tables, table[1])
+	}
+	if len(tables) != 3 || tables[2] != "table_1" {
+		t.Errorf("unexpected COPYs: %v", tables)
+	}
+
+	// Once it succeeded, the write is forgotten.
+	if inserted, err = inserter.InsertData(ctx, rows()); err != nil || inserted != 5 || len(mock.CopyFromTableName) != 5 {
+		t.Errorf("unexpected result of a new write: %d %v %v", inserted, err, mock.CopyFromTableName)
+	}
+}
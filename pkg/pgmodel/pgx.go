@@ -6,6 +6,7 @@ package pgmodel
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"runtime"
@@ -21,8 +22,10 @@ import (
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/prometheus/common/model"
-	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/timescale/timescale-prometheus/pkg/events"
 	"github.com/timescale/timescale-prometheus/pkg/prompb"
+	"github.com/timescale/timescale-prometheus/pkg/tracing"
 )
 
 const (
@@ -32,6 +35,7 @@ const (
 	dataSchema       = "prom_data"
 	dataSeriesSchema = "prom_data_series"
 	infoSchema       = "prom_info"
+	rollupSchema     = "prom_rollup"
 	catalogSchema    = "_prom_catalog"
 	extSchema        = "_prom_ext"
 
@@ -40,6 +44,7 @@ const (
 	getCreateMetricsTableWithNewSQL = "SELECT table_name, possibly_new FROM " + catalogSchema + ".get_or_create_metric_table_name($1)"
 	finalizeMetricCreation          = "CALL " + catalogSchema + ".finalize_metric_creation()"
 	getSeriesIDForLabelSQL          = "SELECT * FROM " + catalogSchema + ".get_series_id_for_key_value_array($1, $2, $3)"
+	getSeriesIDsForLabelsSQL        = "SELECT table_name, series_id FROM " + catalogSchema + ".get_series_ids_for_key_value_arrays($1, $2, $3, $4)"
 )
 
 var (
@@ -67,8 +72,17 @@ type MetricCache interface {
 	Set(metric string, tableName string) error
 }
 
+// missingMetricCache is implemented by the metric caches which can remember
+// the metrics without a table.
+type missingMetricCache interface {
+	IsMissing(metric string) bool
+	SetMissing(metric string)
+}
+
 type pgxConnImpl struct {
 	conn *pgxpool.Pool
+	// simple are the statements sent with the simple protocol.
+	simple map[string]bool
 }
 
 func (p *pgxConnImpl) getConn() *pgxpool.Pool {
@@ -84,13 +98,22 @@ func (p *pgxConnImpl) Close() {
 func (p *pgxConnImpl) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
 	conn := p.getConn()
 
-	return conn.Exec(ctx, sql, arguments...)
+	return conn.Exec(ctx, sql, p.protocolArgs(sql, arguments)...)
 }
 
 func (p *pgxConnImpl) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
 	conn := p.getConn()
 
-	return conn.Query(ctx, sql, args...)
+	return conn.Query(ctx, sql, p.protocolArgs(sql, args)...)
+}
+
+// protocolArgs prepends the simple protocol option to the arguments of the
+// statements sent with it.
+func (p *pgxConnImpl) protocolArgs(sql string, args []interface{}) []interface{} {
+	if !p.simple[sql] {
+		return args
+	}
+	return append([]interface{}{pgx.QuerySimpleProtocol(true)}, args...)
 }
 
 func (p *pgxConnImpl) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
@@ -158,12 +181,12 @@ func (t *SampleInfoIterator) Values() ([]interface{}, error) {
 	info := t.sampleInfos[t.sampleInfoIndex]
 	sample := info.samples[t.sampleIndex]
 	row := []interface{}{
-		model.Time(sample.Timestamp).Time(),
+		sampleTime(sample.Timestamp, info.microseconds),
 		sample.Value,
 		info.seriesID,
 	}
-	if t.minSeen > sample.Timestamp {
-		t.minSeen = sample.Timestamp
+	if ms := sampleMillis(sample.Timestamp, info.microseconds); t.minSeen > ms {
+		t.minSeen = ms
 	}
 	return row, nil
 }
@@ -174,46 +197,183 @@ func (t *SampleInfoIterator) Err() error {
 	return nil
 }
 
+// numSamples returns the number of rows copied from the iterator.
+func (t *SampleInfoIterator) numSamples() int {
+	n := 0
+	for _, si := range t.sampleInfos {
+		n += len(si.samples)
+	}
+	return n
+}
+
 type Cfg struct {
-	AsyncAcks      bool
+	AsyncAcks bool
+	// AckMode is when the writes are acknowledged. Empty selects
+	// AckModeSync, or AckModeAsync with AsyncAcks.
+	AckMode        AckMode
 	ReportInterval int
+	// MaxInFlightSamples bounds the number of samples accepted but not yet
+	// written to the database. 0 means unbounded.
+	MaxInFlightSamples int64
+	// MaxInFlightBytes bounds the estimated memory held by the samples
+	// accepted but not yet written, and by the labels of their series, so
+	// that series with large label sets are bounded as well. 0 means
+	// unbounded.
+	MaxInFlightBytes int64
+	// InFlightWaitTimeout is how long a request waits for in-flight budget
+	// before failing with ErrInFlightLimitExceeded. 0 means wait forever.
+	InFlightWaitTimeout time.Duration
+	// Priorities decides which metrics give way first when the in-flight
+	// budget runs out. Ignored without MaxInFlightSamples.
+	Priorities PriorityConfig
+	// Retry configures the retries of the writes failing with a transient
+	// error.
+	Retry RetryConfig
+	// Batch decides how many samples are gathered before being copied.
+	Batch BatchConfig
+	// DedupSamples skips the samples already stored for the same series and
+	// time, instead of copying them again, at the cost of slower inserts.
+	DedupSamples bool
+	// StaleMarkers is what is done with the staleness markers Prometheus
+	// sends when a series disappears. Empty stores them.
+	StaleMarkers StaleMarkerPolicy
+	// SampleBounds rejects the samples too old or too far in the future.
+	SampleBounds SampleBoundsConfig
+	// SeriesLimit limits the number of active series of every metric.
+	SeriesLimit SeriesLimitConfig
+	// MicrosecondMetrics is a regular expression matching the names of the
+	// metrics whose sample timestamps are sent in microseconds rather than
+	// milliseconds.
+	MicrosecondMetrics string
+	// RelabelConfigFile is a YAML file listing relabel configs applied to
+	// the ingested series. Empty disables relabeling.
+	RelabelConfigFile string
+	// ExternalLabels are added to the ingested series lacking them, before
+	// relabeling.
+	ExternalLabels labels.Labels
+	// BypassTriggers runs the COPYs into the data tables without firing
+	// their triggers, if the database makes it safe.
+	BypassTriggers bool
+	// InsertersPerMetric is the number of insert routines, and thus
+	// concurrent COPYs, used for each metric. Series are sharded between the
+	// routines so that each series is always handled by the same one.
+	InsertersPerMetric int
+	// SeriesCacheSize is the maximum number of series ids cached. The cache
+	// is shared by all the insert routines. 0 selects DefaultSeriesCacheSize.
+	SeriesCacheSize int
+	// SeriesCacheTTL is how long the series ids are cached for. 0 caches
+	// them until they are evicted.
+	SeriesCacheTTL time.Duration
+	// SharedCache, if set, shares the series ids with the other connectors
+	// using the same cache server.
+	SharedCache *SharedCache
+	// StatementProtocols sets the statements of the writes sent with the
+	// simple protocol.
+	StatementProtocols StatementProtocols
+	// LabelValidation is what is done with the series with invalid labels.
+	// Empty rejects them.
+	LabelValidation LabelValidation
+	// LabelLimits bounds the number and the lengths of the labels of a
+	// series.
+	LabelLimits LabelLimits
+	// Shards, if set, are the other databases the metrics are spread
+	// across, along with the database of the connector named LocalShard,
+	// with ShardVirtualNodes points each on the hash ring.
+	Shards            []ShardPool
+	LocalShard        string
+	ShardVirtualNodes int
 }
 
 // NewPgxIngestorWithMetricCache returns a new Ingestor that uses connection pool and a metrics cache
 // for caching metric table names.
 func NewPgxIngestorWithMetricCache(c *pgxpool.Pool, cache MetricCache, cfg *Cfg) (*DBIngestor, error) {
 
-	conn := &pgxConnImpl{
-		conn: c,
+	var conn pgxConn = &pgxConnImpl{
+		conn:   c,
+		simple: cfg.StatementProtocols.simpleStatements(),
+	}
+	if cfg.BypassTriggers {
+		var err error
+		if conn, err = withTriggerBypass(c, conn); err != nil {
+			return nil, err
+		}
 	}
 
+	bounds, err := newSampleBounds(cfg.SampleBounds)
+	if err != nil {
+		return nil, err
+	}
+	if err = cfg.LabelValidation.validate(); err != nil {
+		return nil, err
+	}
+	if err = cfg.LabelLimits.validate(); err != nil {
+		return nil, err
+	}
+	microsecondMetrics, err := compileMetricPattern(cfg.MicrosecondMetrics)
+	if err != nil {
+		return nil, fmt.Errorf("invalid microsecond metrics pattern: %w", err)
+	}
+	relabeler, err := NewRelabeler(cfg.RelabelConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	seriesLimit, err := newSeriesLimiter(cfg.SeriesLimit)
+	if err != nil {
+		return nil, err
+	}
 	pi, err := newPgxInserter(conn, cache, cfg)
 	if err != nil {
 		return nil, err
 	}
-
-	series, _ := bigcache.NewBigCache(DefaultCacheConfig())
-
-	bc := &bCache{
-		series: series,
+	var (
+		db         inserter          = pi
+		histograms histogramInserter = &histogramWriter{conn: conn, cache: pi.seriesCache}
+	)
+	if len(cfg.Shards) > 0 {
+		sharded, err := newShardedInserter(pi, histograms.(*histogramWriter), cfg)
+		if err != nil {
+			return nil, err
+		}
+		db, histograms = sharded, sharded
 	}
 
 	return &DBIngestor{
-		db:    pi,
-		cache: bc,
+		db:                 db,
+		cache:              pi.seriesCache,
+		staleMarkers:       cfg.StaleMarkers,
+		labelValidation:    cfg.LabelValidation,
+		labelLimits:        cfg.LabelLimits,
+		bounds:             bounds,
+		microsecondMetrics: microsecondMetrics,
+		relabeler:          relabeler,
+		externalLabels:     cfg.ExternalLabels,
+		seriesLimit:        seriesLimit,
+		histograms:         histograms,
 	}, nil
 }
 
 // NewPgxIngestor returns a new Ingestor that write to PostgreSQL using PGX
 func NewPgxIngestor(c *pgxpool.Pool) (*DBIngestor, error) {
 	metrics, _ := bigcache.NewBigCache(DefaultCacheConfig())
-	cache := &MetricNameCache{metrics}
+	cache := &MetricNameCache{Metrics: metrics}
 	return NewPgxIngestorWithMetricCache(c, cache, &Cfg{})
 }
 
 var ConnectionsPerProc = 5
 
+func newSeriesCache(cfg *Cfg) Cache {
+	local := NewSeriesCacheWithTTL(cfg.SeriesCacheSize, cfg.SeriesCacheTTL)
+	if cfg.SharedCache == nil {
+		return local
+	}
+	return NewSharedSeriesCache(local, cfg.SharedCache)
+}
+
 func newPgxInserter(conn pgxConn, cache MetricCache, cfg *Cfg) (*pgxInserter, error) {
+	ackMode, err := cfg.ackMode()
+	if err != nil {
+		return nil, err
+	}
 	cmc := make(chan struct{}, 1)
 
 	maxProcs := runtime.GOMAXPROCS(-1)
@@ -226,33 +386,80 @@ func newPgxInserter(conn pgxConn, cache MetricCache, cfg *Cfg) (*pgxInserter, er
 
 	// we leave one connection per-core for other usages
 	numCopiers := maxProcs*ConnectionsPerProc - maxProcs
+	batches, err := newBatchSizer(cfg.Batch)
+	if err != nil {
+		return nil, err
+	}
 	toCopiers := make(chan copyRequest, numCopiers)
+	retry := newRetryPolicy(cfg.Retry)
+	var flusher *walFlusher
+	if ackMode == AckModeFlush {
+		flusher = newWALFlusher(conn, retry, numCopiers)
+	}
+	copiers := &sync.WaitGroup{}
 	for i := 0; i < numCopiers; i++ {
-		go runCopyFrom(conn, toCopiers)
+		copiers.Add(1)
+		go func() {
+			defer copiers.Done()
+			runCopyFrom(conn, toCopiers, retry, cfg.DedupSamples, flusher, batches)
+		}()
+	}
+	if flusher != nil {
+		// The copiers stop once the inserter is closed.
+		go func() {
+			copiers.Wait()
+			flusher.close()
+		}()
+	}
+
+	if err := cfg.StaleMarkers.validate(); err != nil {
+		return nil, err
 	}
 
 	inserter := &pgxInserter{
 		conn:                   conn,
 		metricTableNames:       cache,
+		metricTables:           newMetricTableCreator(conn),
+		writeLedger:            newWriteLedger(),
+		seriesCache:            newSeriesCache(cfg),
 		completeMetricCreation: cmc,
-		asyncAcks:              cfg.AsyncAcks,
+		ackMode:                ackMode,
 		toCopiers:              toCopiers,
+		retry:                  retry,
+		batches:                batches,
+		inFlightWaitTimeout:    cfg.InFlightWaitTimeout,
+		insertersPerMetric:     cfg.InsertersPerMetric,
+		recordLiveness:         cfg.StaleMarkers == StaleMarkersLiveness,
+	}
+	if inserter.insertersPerMetric < 1 {
+		inserter.insertersPerMetric = 1
+	}
+	if cfg.MaxInFlightSamples > 0 {
+		inserter.inFlight = newInFlightBudget(cfg.MaxInFlightSamples)
+		priorities, err := newPriorities(cfg.Priorities, cfg.MaxInFlightSamples)
+		if err != nil {
+			return nil, err
+		}
+		inserter.priorities = priorities
 	}
-	if cfg.AsyncAcks && cfg.ReportInterval > 0 {
+	if cfg.MaxInFlightBytes > 0 {
+		inserter.inFlightBytes = newInFlightBudget(cfg.MaxInFlightBytes)
+	}
+	if ackMode == AckModeAsync && cfg.ReportInterval > 0 {
 		inserter.insertedDatapoints = new(int64)
 		reportInterval := int64(cfg.ReportInterval)
 		go func() {
-			log.Info("msg", fmt.Sprintf("outputting throughpput info once every %ds", reportInterval))
+			ingestLogger.Info("msg", fmt.Sprintf("outputting throughpput info once every %ds", reportInterval))
 			tick := time.Tick(time.Duration(reportInterval) * time.Second)
 			for range tick {
 				inserted := atomic.SwapInt64(inserter.insertedDatapoints, 0)
-				log.Info("msg", "Samples write throughput", "samples/sec", inserted/reportInterval)
+				ingestLogger.Info("msg", "Samples write throughput", "samples/sec", inserted/reportInterval)
 			}
 		}()
 	}
 	//on startup run a completeMetricCreation to recover any potentially
 	//incomplete metric
-	err := inserter.CompleteMetricCreation()
+	err = inserter.CompleteMetricCreation()
 	if err != nil {
 		return nil, err
 	}
@@ -265,11 +472,22 @@ func newPgxInserter(conn pgxConn, cache MetricCache, cfg *Cfg) (*pgxInserter, er
 type pgxInserter struct {
 	conn                   pgxConn
 	metricTableNames       MetricCache
+	metricTables           *metricTableCreator
+	writeLedger            *writeLedger
+	seriesCache            Cache
 	inserters              sync.Map
 	completeMetricCreation chan struct{}
-	asyncAcks              bool
+	ackMode                AckMode
 	insertedDatapoints     *int64
 	toCopiers              chan copyRequest
+	retry                  *retryPolicy
+	batches                *batchSizer
+	inFlight               *inFlightBudget
+	inFlightBytes          *inFlightBudget
+	inFlightWaitTimeout    time.Duration
+	priorities             *priorities
+	insertersPerMetric     int
+	recordLiveness         bool
 }
 
 func (p *pgxInserter) CompleteMetricCreation() error {
@@ -284,7 +502,7 @@ func (p *pgxInserter) runCompleteMetricCreationWorker() {
 	for range p.completeMetricCreation {
 		err := p.CompleteMetricCreation()
 		if err != nil {
-			log.Warn("Got an error finalizing metric: %v", err)
+			ingestLogger.Warn("Got an error finalizing metric: %v", err)
 		}
 	}
 }
@@ -292,14 +510,17 @@ func (p *pgxInserter) runCompleteMetricCreationWorker() {
 func (p *pgxInserter) Close() {
 	close(p.completeMetricCreation)
 	p.inserters.Range(func(key, value interface{}) bool {
-		close(value.(chan insertDataRequest))
+		for _, c := range value.([]chan insertDataRequest) {
+			close(c)
+		}
 		return true
 	})
 	close(p.toCopiers)
+	p.metricTables.close()
 }
 
-func (p *pgxInserter) InsertNewData(rows map[string][]samplesInfo) (uint64, error) {
-	return p.InsertData(rows)
+func (p *pgxInserter) InsertNewData(ctx context.Context, rows map[string][]samplesInfo) (uint64, error) {
+	return p.InsertData(ctx, rows)
 }
 
 type insertDataRequest struct {
@@ -307,55 +528,219 @@ type insertDataRequest struct {
 	data     []samplesInfo
 	finished *sync.WaitGroup
 	errChan  chan error
+	span     *tracing.Span
 }
 
 type insertDataTask struct {
 	finished *sync.WaitGroup
 	errChan  chan error
+	span     *tracing.Span
 }
 
-func (p *pgxInserter) InsertData(rows map[string][]samplesInfo) (uint64, error) {
+func (p *pgxInserter) InsertData(ctx context.Context, rows map[string][]samplesInfo) (uint64, error) {
+	writeID := writeIDFromContext(ctx)
+	skipped := p.writeLedger.skipStored(writeID, rows, time.Now())
+	if skipped > 0 {
+		retrySkippedSamples.Add(float64(skipped))
+	}
+
 	var numRows uint64
-	workFinished := &sync.WaitGroup{}
-	workFinished.Add(len(rows))
-	errChan := make(chan error, 1)
-	for metricName, data := range rows {
+	for _, data := range rows {
 		for _, si := range data {
 			numRows += uint64(len(si.samples))
 		}
-		p.insertMetricData(metricName, data, workFinished, errChan)
+	}
+
+	_, span := tracing.Start(ctx, "pgxInserter.InsertData", tracing.KindInternal,
+		tracing.Int("metrics", int64(len(rows))),
+		tracing.Int("samples", int64(numRows)),
+		tracing.String("ack_mode", string(p.ackMode)),
+		tracing.Int("skipped_samples", int64(skipped)),
+	)
+
+	// Shed samples are acknowledged like the inserted ones, since having
+	// Prometheus retry them would only add to the backpressure.
+	var shed uint64
+	if p.priorities != nil {
+		acquired, err := p.priorities.acquire(ctx, p.inFlight, rows, p.inFlightWaitTimeout)
+		if err != nil {
+			span.SetError(err)
+			span.End()
+			return 0, err
+		}
+		if shed = numRows - uint64(acquired); shed > 0 {
+			span.SetAttributes(tracing.Int("shed_samples", int64(shed)))
+		}
+		numRows = uint64(acquired)
+	} else if p.inFlight != nil {
+		if err := p.inFlight.acquire(ctx, int64(numRows), p.inFlightWaitTimeout); err != nil {
+			span.SetError(err)
+			span.End()
+			return 0, err
+		}
+	}
+	// The bytes are counted once the shed metrics are removed from rows.
+	var numBytes int64
+	if p.inFlightBytes != nil {
+		numBytes = inFlightBytes(rows)
+		if err := p.inFlightBytes.acquire(ctx, numBytes, p.inFlightWaitTimeout); err != nil {
+			p.releaseInFlight(numRows, 0)
+			span.SetError(err)
+			span.End()
+			return 0, err
+		}
+	}
+
+	workFinished := &sync.WaitGroup{}
+	errChans := make(map[string]chan error, len(rows))
+	for metricName, data := range rows {
+		errChan := make(chan error, 1)
+		errChans[metricName] = errChan
+		p.insertMetricData(metricName, data, workFinished, errChan, span)
 	}
 
 	var err error
-	if !p.asyncAcks {
+	if p.ackMode != AckModeAsync {
+		unacked := inFlightSamples.WithLabelValues(inFlightUnacked)
+		unacked.Add(float64(numRows))
 		workFinished.Wait()
-		select {
-		case err = <-errChan:
-		default:
+		unacked.Sub(float64(numRows))
+		p.releaseInFlight(numRows, numBytes)
+		var inserted uint64
+		inserted, err = p.collectResults(writeID, rows, errChans)
+		span.SetError(err)
+		span.End()
+		if partial, ok := err.(*PartialWriteError); ok {
+			partialWrites.Inc()
+			partial.Inserted = inserted + shed + skipped
+			return partial.Inserted, partial
 		}
-		close(errChan)
 	} else {
+		acked := inFlightSamples.WithLabelValues(inFlightAcked)
+		acked.Add(float64(numRows))
 		go func() {
 			workFinished.Wait()
-			select {
-			case err = <-errChan:
-			default:
-			}
-			close(errChan)
+			acked.Sub(float64(numRows))
+			p.releaseInFlight(numRows, numBytes)
+			inserted, err := p.collectResults(writeID, rows, errChans)
+			span.SetError(err)
+			span.End()
 			if err != nil {
-				log.Error("msg", fmt.Sprintf("error on async send, dropping %d datapoints", numRows), "error", err)
+				ingestLogger.Error("msg", fmt.Sprintf("error on async send, dropping %d datapoints", numRows), "error", err)
+				emitDataLoss(dataLossAsyncAck, numRows-inserted, failedMetrics(rows, err))
 			} else if p.insertedDatapoints != nil {
 				atomic.AddInt64(p.insertedDatapoints, int64(numRows))
 			}
 		}()
 	}
 
-	return numRows, err
+	return numRows + shed + skipped, err
+}
+
+// collectResults gathers the outcome of each metric of a write. If only some
+// metrics failed, the stored ones are recorded for the retries of the write
+// and a PartialWriteError counting the samples of the stored metrics is
+// returned.
+func (p *pgxInserter) collectResults(writeID string, rows map[string][]samplesInfo, errChans map[string]chan error) (uint64, error) {
+	var (
+		inserted uint64
+		stored   []string
+		failed   map[string]error
+		firstErr error
+	)
+	for metric, errChan := range errChans {
+		select {
+		case err := <-errChan:
+			if failed == nil {
+				failed = make(map[string]error)
+			}
+			failed[metric] = err
+			if firstErr == nil {
+				firstErr = err
+			}
+		default:
+			stored = append(stored, metric)
+			inserted += uint64(countSamples(rows[metric]))
+		}
+		close(errChan)
+	}
+
+	switch {
+	case len(failed) == 0:
+		p.writeLedger.forget(writeID)
+		return inserted, nil
+	case len(stored) == 0:
+		return 0, firstErr
+	default:
+		p.writeLedger.record(writeID, stored, time.Now())
+		return inserted, &PartialWriteError{Inserted: inserted, Failed: failed}
+	}
+}
+
+// failedMetrics returns the metrics of rows that err failed.
+func failedMetrics(rows map[string][]samplesInfo, err error) []string {
+	metrics := make([]string, 0, len(rows))
+	if partial, ok := err.(*PartialWriteError); ok {
+		for metric := range partial.Failed {
+			metrics = append(metrics, metric)
+		}
+		return metrics
+	}
+	for metric := range rows {
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
+func (p *pgxInserter) releaseInFlight(numRows uint64, numBytes int64) {
+	if p.inFlight != nil {
+		p.inFlight.release(int64(numRows))
+	}
+	if p.inFlightBytes != nil {
+		p.inFlightBytes.release(numBytes)
+	}
+}
+
+func (p *pgxInserter) insertMetricData(metric string, data []samplesInfo, finished *sync.WaitGroup, errChan chan error, span *tracing.Span) {
+	inserters := p.getMetricInserters(metric)
+	defer recordQueueDepth(metric, inserters)
+
+	if len(inserters) == 1 {
+		finished.Add(1)
+		inserters[0] <- insertDataRequest{metric: metric, data: data, finished: finished, errChan: errChan, span: span}
+		return
+	}
+
+	shards := make([][]samplesInfo, len(inserters))
+	for _, si := range data {
+		shard := seriesShard(si.labels, len(inserters))
+		shards[shard] = append(shards[shard], si)
+	}
+	for i, shard := range shards {
+		if len(shard) == 0 {
+			continue
+		}
+		finished.Add(1)
+		inserters[i] <- insertDataRequest{metric: metric, data: shard, finished: finished, errChan: errChan, span: span}
+	}
+}
+
+func recordQueueDepth(metric string, inserters []chan insertDataRequest) {
+	depth := 0
+	for _, c := range inserters {
+		depth += len(c)
+	}
+	inserterQueueDepth.WithLabelValues(metric).Set(float64(depth))
 }
 
-func (p *pgxInserter) insertMetricData(metric string, data []samplesInfo, finished *sync.WaitGroup, errChan chan error) {
-	inserter := p.getMetricInserter(metric, errChan)
-	inserter <- insertDataRequest{metric: metric, data: data, finished: finished, errChan: errChan}
+// seriesShard maps a series to one of numShards insert routines. The mapping
+// is stable so a series is always inserted, and its ID cached, by the same
+// routine.
+func seriesShard(labels *Labels, numShards int) int {
+	if labels == nil {
+		return 0
+	}
+	return int(labels.fingerprint % uint64(numShards))
 }
 
 func (p *pgxInserter) createMetricTable(metric string) (string, error) {
@@ -411,17 +796,22 @@ func (p *pgxInserter) getMetricTableName(metric string) (string, error) {
 	return tableName, err
 }
 
-func (p *pgxInserter) getMetricInserter(metric string, errChan chan error) chan insertDataRequest {
-	inserter, ok := p.inserters.Load(metric)
+func (p *pgxInserter) getMetricInserters(metric string) []chan insertDataRequest {
+	inserters, ok := p.inserters.Load(metric)
 	if !ok {
-		c := make(chan insertDataRequest, 1000)
-		actual, old := p.inserters.LoadOrStore(metric, c)
-		inserter = actual
+		cs := make([]chan insertDataRequest, p.insertersPerMetric)
+		for i := range cs {
+			cs[i] = make(chan insertDataRequest, 1000)
+		}
+		actual, old := p.inserters.LoadOrStore(metric, cs)
+		inserters = actual
 		if !old {
-			go runInserterRoutine(p.conn, c, metric, p.completeMetricCreation, errChan, p.metricTableNames, p.toCopiers)
+			for _, c := range cs {
+				go runInserterRoutine(p.conn, c, metric, p.completeMetricCreation, p.metricTableNames, p.metricTables, p.seriesCache, p.toCopiers, p.retry, p.batches, p.recordLiveness)
+			}
 		}
 	}
-	return inserter.(chan insertDataRequest)
+	return inserters.([]chan insertDataRequest)
 }
 
 func getMetricTableName(conn pgxConn, metric string) (string, bool, error) {
@@ -453,20 +843,31 @@ type insertHandler struct {
 	conn            pgxConn
 	input           chan insertDataRequest
 	pending         *pendingBuffer
-	seriesCache     map[string]SeriesID
+	seriesCache     Cache
 	metricTableName string
 	toCopiers       chan copyRequest
+	retry           *retryPolicy
+	// batches tells when the pending buffer is full. The defaults apply
+	// if nil.
+	batches *batchSizer
+	// recordLiveness records the staleness markers in the catalog instead
+	// of copying them.
+	recordLiveness bool
+	// toFlusher hands the full buffers over to the flusher of the metric,
+	// so that the next buffer accumulates while one flushes. Without it,
+	// the buffers are flushed by the handler itself.
+	toFlusher chan *pendingBuffer
 }
 
 type pendingBuffer struct {
 	needsResponse []insertDataTask
 	batch         SampleInfoIterator
+	// samples is the number of samples of the batch.
+	samples int
+	// started is when the first request was added to the batch.
+	started time.Time
 }
 
-const (
-	flushSize = 2000
-)
-
 var pendingBuffers = sync.Pool{
 	New: func() interface{} {
 		pb := new(pendingBuffer)
@@ -491,16 +892,16 @@ func runInserterRoutineFailure(input chan insertDataRequest, err error) {
 	}
 }
 
-func runInserterRoutine(conn pgxConn, input chan insertDataRequest, metricName string, completeMetricCreationSignal chan struct{}, errChan chan error, metricTableNames MetricCache, toCopiers chan copyRequest) {
+// runInserterRoutine inserts the data of metricName sent to input. If the
+// table of the metric cannot be found, every request is answered with the
+// error on its own errChan: the routines of the shards of a metric start with
+// a request that may be answered before they are done looking the table up.
+func runInserterRoutine(conn pgxConn, input chan insertDataRequest, metricName string, completeMetricCreationSignal chan struct{}, metricTableNames MetricCache, metricTables *metricTableCreator, seriesCache Cache, toCopiers chan copyRequest, retry *retryPolicy, batches *batchSizer, recordLiveness bool) {
 	tableName, err := metricTableNames.Get(metricName)
 	if err == ErrEntryNotFound {
 		var possiblyNew bool
-		tableName, possiblyNew, err = getMetricTableName(conn, metricName)
+		tableName, possiblyNew, err = metricTables.get(metricName)
 		if err != nil {
-			select {
-			case errChan <- err:
-			default:
-			}
 			//won't be able to insert anyway
 			runInserterRoutineFailure(input, err)
 			return
@@ -510,6 +911,7 @@ func runInserterRoutine(conn pgxConn, input chan insertDataRequest, metricName s
 		}
 
 		if possiblyNew {
+			events.Emit(events.MetricTableCreated, "metric", metricName, "table", tableName)
 			//pass a signal if there is space
 			select {
 			case completeMetricCreationSignal <- struct{}{}:
@@ -517,12 +919,6 @@ func runInserterRoutine(conn pgxConn, input chan insertDataRequest, metricName s
 			}
 		}
 	} else if err != nil {
-		if err != nil {
-			select {
-			case errChan <- err:
-			default:
-			}
-		}
 		//won't be able to insert anyway
 		runInserterRoutineFailure(input, err)
 		return
@@ -532,10 +928,16 @@ func runInserterRoutine(conn pgxConn, input chan insertDataRequest, metricName s
 		conn:            conn,
 		input:           input,
 		pending:         pendingBuffers.Get().(*pendingBuffer),
-		seriesCache:     make(map[string]SeriesID),
+		seriesCache:     seriesCache,
 		metricTableName: tableName,
 		toCopiers:       toCopiers,
+		retry:           retry,
+		batches:         batches,
+		recordLiveness:  recordLiveness,
+		toFlusher:       make(chan *pendingBuffer),
 	}
+	go handler.runFlusher()
+	defer close(handler.toFlusher)
 
 	for {
 		if !handler.hasPendingReqs() {
@@ -548,15 +950,43 @@ func runInserterRoutine(conn pgxConn, input chan insertDataRequest, metricName s
 
 	hotReceive:
 		for handler.nonblockingHandleReq() {
-			if len(handler.pending.batch.sampleInfos) >= flushSize {
+			if handler.batches.full(handler.pending) {
 				break hotReceive
 			}
 		}
 
+		stillAlive := handler.waitForReqs()
 		handler.flush()
+		if !stillAlive {
+			return
+		}
 	}
 }
 
+// waitForReqs handles the requests received until the pending buffer is
+// full or has waited for the batch timeout. It returns false if the input
+// was closed.
+func (h *insertHandler) waitForReqs() bool {
+	timeout := h.batches.timeout()
+	if timeout <= 0 || !h.hasPendingReqs() || h.batches.full(h.pending) {
+		return true
+	}
+	timer := time.NewTimer(time.Until(h.pending.started.Add(timeout)))
+	defer timer.Stop()
+	for h.hasPendingReqs() && !h.batches.full(h.pending) {
+		select {
+		case req, ok := <-h.input:
+			if !ok {
+				return false
+			}
+			h.handleReq(req)
+		case <-timer.C:
+			return true
+		}
+	}
+	return true
+}
+
 func (h *insertHandler) hasPendingReqs() bool {
 	return len(h.pending.batch.sampleInfos) > 0
 }
@@ -584,12 +1014,23 @@ func (h *insertHandler) nonblockingHandleReq() bool {
 
 func (h *insertHandler) handleReq(req insertDataRequest) bool {
 	h.fillKnowSeriesIds(req.data)
-	needsFlush := h.pending.addReq(req)
-	if needsFlush {
-		h.flushPending()
-		return true
+	flushed := false
+	for {
+		rest := h.pending.addReq(req, h.batches)
+		if len(rest) > 0 {
+			// The rest of the request goes to the next batches, which
+			// report to it too. Count them before this batch can report.
+			req.finished.Add(1)
+		}
+		if len(rest) > 0 || h.batches.full(h.pending) {
+			h.flushPending()
+			flushed = true
+		}
+		if len(rest) == 0 {
+			return flushed
+		}
+		req.data = rest
 	}
-	return false
 }
 
 func (h *insertHandler) fillKnowSeriesIds(sampleInfos []samplesInfo) (numMissingSeries int) {
@@ -597,8 +1038,8 @@ func (h *insertHandler) fillKnowSeriesIds(sampleInfos []samplesInfo) (numMissing
 		if series.seriesID > -1 {
 			continue
 		}
-		id, ok := h.seriesCache[series.labels.String()]
-		if ok {
+		id, err := h.seriesCache.GetSeries(*series.labels)
+		if err == nil {
 			sampleInfos[i].seriesID = id
 			series.labels = nil
 		} else {
@@ -615,56 +1056,143 @@ func (h *insertHandler) flush() {
 	h.flushPending()
 }
 
+// flushPending flushes the pending buffer and starts a new one. With a
+// flusher, this only waits for the previous buffer to be flushed.
 func (h *insertHandler) flushPending() {
-	_, err := h.setSeriesIds(h.pending.batch.sampleInfos)
+	pending := h.pending
+	h.pending = pendingBuffers.Get().(*pendingBuffer)
+	if h.toFlusher != nil {
+		h.toFlusher <- pending
+		return
+	}
+	h.flushBuffer(pending)
+}
+
+// runFlusher flushes the buffers handed over by the handler, one at a time,
+// until toFlusher is closed.
+func (h *insertHandler) runFlusher() {
+	for pending := range h.toFlusher {
+		h.flushBuffer(pending)
+	}
+}
+
+// flushBuffer sets the series ids of the buffer and passes it to the
+// copiers. The buffer goes back to the pool once its results are reported.
+func (h *insertHandler) flushBuffer(pending *pendingBuffer) {
+	span := tracing.StartLinked("insertHandler.setSeriesIds", tracing.KindClient, pending.spans(),
+		tracing.String("db.system", "postgresql"),
+		tracing.String("db.statement", getSeriesIDsForLabelsSQL),
+		tracing.Int("series", int64(len(pending.batch.sampleInfos))),
+	)
+	err := h.retry.do("series", func() error {
+		_, err := h.setSeriesIds(pending.batch.sampleInfos)
+		return err
+	})
+	span.SetError(err)
+	span.End()
 	if err != nil {
-		h.pending.reportResults(err)
+		pending.reportResults(err)
+		pendingBuffers.Put(pending)
 		return
 	}
 
-	h.toCopiers <- copyRequest{h.pending, h.metricTableName}
-	h.pending = pendingBuffers.Get().(*pendingBuffer)
+	if h.recordLiveness {
+		staleAt, markers := extractLiveness(&pending.batch)
+		if len(staleAt) > 0 {
+			err = h.retry.do("liveness", func() error {
+				return recordLiveness(h.conn, staleAt)
+			})
+			if err != nil {
+				pending.reportResults(err)
+				pendingBuffers.Put(pending)
+				return
+			}
+			staleMarkers.WithLabelValues("recorded").Add(float64(markers))
+		}
+		if len(pending.batch.sampleInfos) == 0 {
+			pending.reportResults(nil)
+			pendingBuffers.Put(pending)
+			return
+		}
+	}
+
+	h.toCopiers <- copyRequest{pending, h.metricTableName}
 }
 
-func runCopyFrom(conn pgxConn, in chan copyRequest) {
+// runCopyFrom copies the batches sent to in, reporting their latency to
+// batches. With a flusher, the batches copied are reported once the WAL is
+// flushed.
+func runCopyFrom(conn pgxConn, in chan copyRequest, retry *retryPolicy, dedup bool, flusher *walFlusher, batches *batchSizer) {
 	for {
 		req, ok := <-in
 		if !ok {
 			return
 		}
-		_, err := conn.CopyFrom(
+		start := time.Now()
+		statement := copyStatement(req.table)
+		if dedup {
+			statement = dedupInsertStatement(req.table)
+		}
+		span := tracing.StartLinked("COPY", tracing.KindClient, req.data.spans(),
+			tracing.String("db.system", "postgresql"),
+			tracing.String("db.statement", statement),
+			tracing.Int("rows", int64(req.data.batch.numSamples())),
+			tracing.Int("requests", int64(len(req.data.needsResponse))),
+		)
+		var copied int64
+		err := retry.do("copy", func() (err error) {
+			req.data.batch.ResetPosition()
+			copied, err = copyFrom(conn, req, dedup)
+			return err
+		})
+
+		if err == nil {
+			samplesCopied.Add(float64(copied))
+			copyDuration.Observe(time.Since(start).Seconds())
+			batches.observe(time.Since(req.data.started), len(req.data.batch.sampleInfos))
+		}
+		span.SetError(err)
+		span.End()
+
+		if err == nil && flusher != nil {
+			flusher.enqueue(req.data)
+			continue
+		}
+		req.data.reportResults(err)
+		pendingBuffers.Put(req.data)
+	}
+}
+
+// copyFrom copies the samples of req, or inserts those not stored yet in
+// dedup mode, decompressing the chunks they fall in if needed.
+func copyFrom(conn pgxConn, req copyRequest, dedup bool) (int64, error) {
+	insert := func() (int64, error) {
+		if dedup {
+			return insertDeduplicated(conn, req)
+		}
+		return conn.CopyFrom(
 			context.Background(),
 			pgx.Identifier{dataSchema, req.table},
 			copyColumns,
 			&req.data.batch,
 		)
-		if err != nil {
-			if pgErr, ok := err.(*pgconn.PgError); ok && strings.Contains(pgErr.Message, "insert/update/delete not permitted") {
-				/* If the error was that the table is already compressed, decompress and try again. */
-				decompressErr := decompressChunks(conn, req.data, req.table)
-				if decompressErr != nil {
-					req.data.reportResults(err)
-					pendingBuffers.Put(req.data)
-					continue
-				}
-
-				req.data.batch.ResetPosition()
-				_, err = conn.CopyFrom(
-					context.Background(),
-					pgx.Identifier{dataSchema, req.table},
-					copyColumns,
-					&req.data.batch,
-				)
-			}
+	}
+
+	copied, err := insert()
+	if pgErr, ok := err.(*pgconn.PgError); ok && strings.Contains(pgErr.Message, "insert/update/delete not permitted") {
+		/* If the error was that the table is already compressed, decompress and try again. */
+		if decompressErr := decompressChunks(conn, req.data, req.table); decompressErr != nil {
+			return 0, err
 		}
 
-		req.data.reportResults(err)
-		pendingBuffers.Put(req.data)
+		req.data.batch.ResetPosition()
+		copied, err = insert()
 	}
+	return copied, err
 }
 
 func decompressChunks(conn pgxConn, pending *pendingBuffer, table string) error {
-	log.Warn("msg", fmt.Sprintf("Table %s was compressed, decompressing", table), "table", table)
+	ingestLogger.Warn("msg", fmt.Sprintf("Table %s was compressed, decompressing", table), "table", table)
 	minTime := model.Time(pending.batch.minSeen).Time()
 
 	//how much faster are we at ingestion than wall-clock time?
@@ -684,13 +1212,13 @@ func decompressChunks(conn pgxConn, pending *pendingBuffer, table string) error
 							WHERE h.schema_name = $1 and h.table_name = $2),
 							next_start=>$3)`, dataSchema, table, time.Now().Add(delayBy))
 	if rescheduleErr != nil {
-		log.Error("msg", rescheduleErr, "context", "Rescheduling compression")
+		ingestLogger.Error("msg", rescheduleErr, "context", "Rescheduling compression")
 		return rescheduleErr
 	}
 
 	_, decompressErr := conn.Exec(context.Background(), "CALL "+catalogSchema+".decompress_chunks_after($1, $2);", table, minTime)
 	if decompressErr != nil {
-		log.Error("msg", decompressErr, "context", "Decompressing chunks")
+		ingestLogger.Error("msg", decompressErr, "context", "Decompressing chunks")
 		return decompressErr
 	}
 
@@ -716,6 +1244,8 @@ func (pending *pendingBuffer) reportResults(err error) {
 	}
 	pending.batch = SampleInfoIterator{sampleInfos: pending.batch.sampleInfos[:0]}
 	pending.batch.ResetPosition()
+	pending.samples = 0
+	pending.started = time.Time{}
 }
 
 func (h *insertHandler) setSeriesIds(sampleInfos []samplesInfo) (string, error) {
@@ -733,13 +1263,15 @@ func (h *insertHandler) setSeriesIds(sampleInfos []samplesInfo) (string, error)
 	}
 	var lastSeenLabel *Labels
 
-	batch := h.conn.NewBatch()
-	numSQLFunctionCalls := 0
-	// Sort and remove duplicates. The sort is needed to remove duplicates. Each series is inserted
-	// in a different transaction, thus deadlocks are not an issue.
-	sort.Slice(seriesToInsert, func(i, j int) bool {
+	// Sort and remove duplicates. The sort is needed to remove duplicates.
+	// Batches already in order skip it after a linear check, which is far
+	// cheaper than sorting.
+	less := func(i, j int) bool {
 		return seriesToInsert[i].labels.Compare(seriesToInsert[j].labels) < 0
-	})
+	}
+	if !sort.SliceIsSorted(seriesToInsert, less) {
+		sort.Slice(seriesToInsert, less)
+	}
 
 	batchSeries := make([][]*samplesInfo, 0, len(seriesToInsert))
 	// group the seriesToInsert by labels, one slice array per unique labels
@@ -749,27 +1281,77 @@ func (h *insertHandler) setSeriesIds(sampleInfos []samplesInfo) (string, error)
 			continue
 		}
 
-		batch.Queue("BEGIN;")
-		batch.Queue(getSeriesIDForLabelSQL, curr.labels.metricName, curr.labels.names, curr.labels.values)
-		batch.Queue("COMMIT;")
-		numSQLFunctionCalls++
 		batchSeries = append(batchSeries, []*samplesInfo{curr})
-
 		lastSeenLabel = curr.labels
 	}
 
-	br, err := h.conn.SendBatch(context.Background(), batch)
+	tableName, err := h.setSeriesIdsBulk(batchSeries)
+	if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == pgerrcode.DeadlockDetected {
+		// The bulk lookup creates all the series in a single transaction,
+		// which can deadlock with other inserters creating overlapping
+		// labels. Retry with each series in its own transaction.
+		tableName, err = h.setSeriesIdsOneByOne(batchSeries)
+	}
+	return tableName, err
+}
+
+// setSeriesIdsBulk fetches, or creates, the ids of all the series in a single
+// round trip.
+func (h *insertHandler) setSeriesIdsBulk(batchSeries [][]*samplesInfo) (string, error) {
+	metricName := ""
+	keys := make([]string, 0, len(batchSeries))
+	values := make([]string, 0, len(batchSeries))
+	counts := make([]int32, 0, len(batchSeries))
+	for _, series := range batchSeries {
+		labels := series[0].labels
+		metricName = labels.metricName
+		keys = append(keys, labels.names...)
+		values = append(values, labels.values...)
+		counts = append(counts, int32(len(labels.names)))
+	}
+
+	rows, err := h.conn.Query(context.Background(), getSeriesIDsForLabelsSQL, metricName, keys, values, counts)
 	if err != nil {
 		return "", err
 	}
-	defer br.Close()
+	defer rows.Close()
+
+	var tableName string
+	for i := range batchSeries {
+		if !rows.Next() {
+			return "", fmt.Errorf("missing series id for series %d of %d", i+1, len(batchSeries))
+		}
+
+		var id SeriesID
+		if err = rows.Scan(&tableName, &id); err != nil {
+			return "", err
+		}
+		h.setSeriesID(batchSeries[i], id)
+	}
+
+	return tableName, nil
+}
+
+// setSeriesIdsOneByOne fetches, or creates, the ids of the series using a
+// separate transaction for every series. Since each series is inserted in a
+// different transaction, deadlocks are not an issue.
+func (h *insertHandler) setSeriesIdsOneByOne(batchSeries [][]*samplesInfo) (string, error) {
+	batch := h.conn.NewBatch()
+	for _, series := range batchSeries {
+		labels := series[0].labels
+		batch.Queue("BEGIN;")
+		batch.Queue(getSeriesIDForLabelSQL, labels.metricName, labels.names, labels.values)
+		batch.Queue("COMMIT;")
+	}
 
-	if numSQLFunctionCalls != len(batchSeries) {
-		return "", fmt.Errorf("unexpected difference in numQueries and batchSeries")
+	br, err := h.conn.SendBatch(context.Background(), batch)
+	if err != nil {
+		return "", err
 	}
+	defer br.Close()
 
 	var tableName string
-	for i := 0; i < numSQLFunctionCalls; i++ {
+	for i := range batchSeries {
 		_, err = br.Exec()
 		if err != nil {
 			return "", err
@@ -781,10 +1363,7 @@ func (h *insertHandler) setSeriesIds(sampleInfos []samplesInfo) (string, error)
 		if err != nil {
 			return "", err
 		}
-		h.seriesCache[batchSeries[i][0].labels.String()] = id
-		for _, lsi := range batchSeries[i] {
-			lsi.seriesID = id
-		}
+		h.setSeriesID(batchSeries[i], id)
 		_, err = br.Exec()
 		if err != nil {
 			return "", err
@@ -794,10 +1373,38 @@ func (h *insertHandler) setSeriesIds(sampleInfos []samplesInfo) (string, error)
 	return tableName, nil
 }
 
-func (p *pendingBuffer) addReq(req insertDataRequest) bool {
-	p.needsResponse = append(p.needsResponse, insertDataTask{finished: req.finished, errChan: req.errChan})
-	p.batch.sampleInfos = append(p.batch.sampleInfos, req.data...)
-	return len(p.batch.sampleInfos) > flushSize
+func (h *insertHandler) setSeriesID(series []*samplesInfo, id SeriesID) {
+	//ignore error since this is just an optimization
+	_ = h.seriesCache.SetSeries(*series[0].labels, id)
+	for _, lsi := range series {
+		lsi.seriesID = id
+	}
+}
+
+// addReq adds the series of req to the buffer, up to the number of series
+// and samples of a batch in total. It returns the series which did not fit.
+// An empty buffer takes at least one series.
+func (p *pendingBuffer) addReq(req insertDataRequest, batches *batchSizer) []samplesInfo {
+	maxSeries, maxSamples := batches.limits()
+	n := maxSeries - len(p.batch.sampleInfos)
+	if n > len(req.data) {
+		n = len(req.data)
+	}
+	samples := 0
+	for i, si := range req.data[:n] {
+		if maxSamples > 0 && p.samples+samples+len(si.samples) > maxSamples && (i > 0 || len(p.batch.sampleInfos) > 0) {
+			n = i
+			break
+		}
+		samples += len(si.samples)
+	}
+	if len(p.needsResponse) == 0 {
+		p.started = time.Now()
+	}
+	p.needsResponse = append(p.needsResponse, insertDataTask{finished: req.finished, errChan: req.errChan, span: req.span})
+	p.batch.sampleInfos = append(p.batch.sampleInfos, req.data[:n]...)
+	p.samples += samples
+	return req.data[n:]
 }
 
 // NewPgxReaderWithMetricCache returns a new DBReader that reads from PostgreSQL using PGX
@@ -808,6 +1415,8 @@ func NewPgxReaderWithMetricCache(c *pgxpool.Pool, cache MetricCache) *DBReader {
 			conn: c,
 		},
 		metricTableNames: cache,
+		migrationWait:    DefaultMigrationWait,
+		nativeHistograms: true,
 	}
 
 	return &DBReader{
@@ -818,7 +1427,7 @@ func NewPgxReaderWithMetricCache(c *pgxpool.Pool, cache MetricCache) *DBReader {
 // NewPgxReader returns a new DBReader that reads that from PostgreSQL using PGX.
 func NewPgxReader(c *pgxpool.Pool) *DBReader {
 	metrics, _ := bigcache.NewBigCache(DefaultCacheConfig())
-	cache := &MetricNameCache{metrics}
+	cache := &MetricNameCache{Metrics: metrics}
 	return NewPgxReaderWithMetricCache(c, cache)
 }
 
@@ -826,16 +1435,48 @@ type metricTimeRangeFilter struct {
 	metric    string
 	startTime string
 	endTime   string
+	// liveness also reads the time the series went stale.
+	liveness bool
+	// tombstoned are the metric tables with tombstones, whose samples they
+	// hide are skipped.
+	tombstoned map[string]bool
+	// microseconds returns the timestamps in microseconds. The time range
+	// is then in microseconds too.
+	microseconds bool
 }
 
 type pgxQuerier struct {
 	conn             pgxConn
 	metricTableNames MetricCache
+	// migrationWait is how long a query failing during a schema migration
+	// waits for it to complete before being retried.
+	migrationWait time.Duration
+	// livenessMarkers emits a staleness marker at the time recorded for
+	// each series with the StaleMarkersLiveness policy.
+	livenessMarkers bool
+	// snapshotReads pins the SELECTs of a query across several metrics to
+	// a single snapshot, unless the context of the query says otherwise.
+	snapshotReads bool
+	// tenantRoles runs the SELECTs of a query as the role of the tenant of
+	// its context, tenantRolePrefix followed by the tenant.
+	tenantRoles      bool
+	tenantRolePrefix string
+	// nativeHistograms also reads the native histograms of the series
+	// matching a query.
+	nativeHistograms bool
+	// tombstones skips the samples hidden by the tombstones of the series
+	// deleted with a grace period.
+	tombstones bool
 }
 
 // HealthCheck implements the healtchecker interface
 func (q *pgxQuerier) HealthCheck() error {
-	rows, err := q.conn.Query(context.Background(), "SELECT")
+	conn := q.conn
+	// The health is the primary's, which the writes depend on.
+	if r, ok := conn.(*replicaConn); ok {
+		conn = r.primary
+	}
+	rows, err := conn.Query(context.Background(), "SELECT")
 
 	if err != nil {
 		return err
@@ -845,100 +1486,206 @@ func (q *pgxQuerier) HealthCheck() error {
 	return nil
 }
 
-func (q *pgxQuerier) Query(query *prompb.Query) ([]*prompb.TimeSeries, error) {
+func (q *pgxQuerier) Query(ctx context.Context, query *prompb.Query) ([]*prompb.TimeSeries, error) {
+	results := make([]*prompb.TimeSeries, 0)
+	err := q.QueryStreamed(ctx, query, func(ts *prompb.TimeSeries) error {
+		results = append(results, ts)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// QueryStreamed runs the query, telling SQL errors caused by a schema migration
+// in progress apart. Such queries are retried once the migration completes,
+// unless results were already streamed or the migration takes longer than
+// migrationWait, in which case ErrMigrationInProgress is returned. The SQL is
+// canceled once ctx is done, and the returned error then wraps ctx.Err(). The
+// query stops with ErrQueryLimitExceeded once it goes over the QueryLimits set
+// with WithQueryLimits, or returns the series read until then with an
+// ErrResultsTruncated warning if they truncate the results.
+func (q *pgxQuerier) QueryStreamed(ctx context.Context, query *prompb.Query, process func(*prompb.TimeSeries) error) (err error) {
+	ctx, span := tracing.Start(ctx, "pgxQuerier.QueryStreamed", tracing.KindInternal)
+	if span != nil {
+		var stats queryStats
+		span.SetAttributes(
+			tracing.String("matchers", formatMatchers(query.GetMatchers())),
+			tracing.Int("start_ms", query.GetStartTimestampMs()),
+			tracing.Int("end_ms", query.GetEndTimestampMs()),
+		)
+		inner := process
+		process = func(ts *prompb.TimeSeries) error {
+			stats.add(ts)
+			return inner(ts)
+		}
+		defer func() {
+			span.SetAttributes(tracing.Int("series", int64(stats.series)), tracing.Int("samples", int64(stats.samples)))
+			span.SetError(err)
+			span.End()
+		}()
+	}
+
+	budget := queryBudgetFrom(ctx)
+	if budget.truncation() != nil {
+		return nil
+	}
+	// Canceling on a limit exceeded stops the SQL instead of reading the
+	// remaining rows.
+	queryCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	streamed := false
+	limited := func(ts *prompb.TimeSeries) error {
+		if err := budget.admit(ts); err != nil {
+			cancel()
+			return err
+		}
+		streamed = true
+		return process(ts)
+	}
+	err = q.queryStreamed(queryCtx, query, limited)
+	if errors.Is(err, ErrResultsTruncated) {
+		QueryWarningsFrom(ctx).Add(err)
+		return nil
+	}
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("%w: %v", ctx.Err(), err)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.QueryCanceled && budget.statementTimeout() > 0 {
+		return fmt.Errorf("%w: statement timeout of %v reached", ErrQueryLimitExceeded, budget.statementTimeout())
+	}
+	if !errors.As(err, &pgErr) || !migrationInProgress(q.conn) {
+		return err
+	}
+
+	if streamed || !waitForMigration(q.conn, q.migrationWait) {
+		return fmt.Errorf("%w: %v", ErrMigrationInProgress, err)
+	}
+	err = q.queryStreamed(queryCtx, query, limited)
+	if errors.Is(err, ErrResultsTruncated) {
+		QueryWarningsFrom(ctx).Add(err)
+		return nil
+	}
+	return err
+}
+
+func (q *pgxQuerier) queryStreamed(ctx context.Context, query *prompb.Query, process func(*prompb.TimeSeries) error) error {
 	if query == nil {
-		return []*prompb.TimeSeries{}, nil
+		return nil
 	}
 
 	metric, cases, values, err := buildSubQueries(query)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	micros := microsecondPrecision(ctx)
 	filter := metricTimeRangeFilter{
-		metric:    metric,
-		startTime: toRFC3339Nano(query.StartTimestampMs),
-		endTime:   toRFC3339Nano(query.EndTimestampMs),
+		metric:       metric,
+		startTime:    timestampToRFC3339Nano(query.StartTimestampMs, micros),
+		endTime:      timestampToRFC3339Nano(query.EndTimestampMs, micros),
+		liveness:     q.livenessMarkers,
+		microseconds: micros,
+	}
+
+	// Across several metrics, the series are looked up first, then read
+	// metric by metric. Without a snapshot, samples written in between may
+	// only be read for some of the metrics.
+	q, endRead, err := q.beginRead(ctx, metric == "")
+	if err != nil {
+		return err
+	}
+	defer endRead()
+
+	if q.tombstones {
+		if filter.tombstoned, err = q.tombstonedTables(ctx); err != nil {
+			return err
+		}
 	}
 
 	if metric != "" {
-		return q.querySingleMetric(metric, filter, cases, values)
+		if err = q.querySingleMetric(ctx, metric, filter, cases, values, process); err != nil {
+			return err
+		}
+		return q.queryHistograms(ctx, filter, cases, values, process)
 	}
 
 	sqlQuery := buildMetricNameSeriesIDQuery(cases)
-	rows, err := q.conn.Query(context.Background(), sqlQuery, values...)
+	rows, err := q.query(ctx, sqlQuery, values...)
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	defer rows.Close()
+	// Within a snapshot, the connection is busy until the rows are closed.
 	metrics, series, err := getSeriesPerMetric(rows)
+	rows.Close()
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	results := make([]*prompb.TimeSeries, 0, len(metrics))
-
 	for i, metric := range metrics {
-		tableName, err := q.getMetricTableName(metric)
+		tableName, err := q.getMetricTableName(ctx, metric)
 		if err != nil {
 			// If the metric table is missing, there are no results for this query.
-			if err == errMissingTableName {
+			if errors.Is(err, errMissingTableName) {
+				QueryWarningsFrom(ctx).Add(err)
 				continue
 			}
 
-			return nil, err
+			return err
 		}
 		filter.metric = tableName
-		sqlQuery = buildTimeseriesBySeriesIDQuery(filter, series[i])
-		rows, err = q.conn.Query(context.Background(), sqlQuery)
+		sqlQuery, args := buildTimeseriesBySeriesIDQuery(filter, series[i])
+		rows, err = q.query(ctx, sqlQuery, args...)
 
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		ts, err := buildTimeSeries(rows)
+		err = streamTimeSeries(rows, filter, process)
 		rows.Close()
 
 		if err != nil {
-			return nil, err
+			return err
 		}
-
-		results = append(results, ts...)
 	}
 
-	return results, nil
+	return q.queryHistograms(ctx, filter, cases, values, process)
 }
 
-func (q *pgxQuerier) querySingleMetric(metric string, filter metricTimeRangeFilter, cases []string, values []interface{}) ([]*prompb.TimeSeries, error) {
-	tableName, err := q.getMetricTableName(metric)
+func (q *pgxQuerier) querySingleMetric(ctx context.Context, metric string, filter metricTimeRangeFilter, cases matcherClauses, values []interface{}, process func(*prompb.TimeSeries) error) error {
+	tableName, err := q.getMetricTableName(ctx, metric)
 	if err != nil {
 		// If the metric table is missing, there are no results for this query.
-		if err == errMissingTableName {
-			return make([]*prompb.TimeSeries, 0), nil
+		if errors.Is(err, errMissingTableName) {
+			QueryWarningsFrom(ctx).Add(err)
+			return nil
 		}
 
-		return nil, err
+		return err
 	}
 	filter.metric = tableName
 
-	sqlQuery := buildTimeseriesByLabelClausesQuery(filter, cases)
-	rows, err := q.conn.Query(context.Background(), sqlQuery, values...)
+	sqlQuery, args := buildTimeseriesByLabelClausesQuery(filter, cases, values)
+	rows, err := q.query(ctx, sqlQuery, args...)
 
 	if err != nil {
 		// If we are getting undefined table error, it means the query
 		// is looking for a metric which doesn't exist in the system.
 		if e, ok := err.(*pgconn.PgError); !ok || e.Code != pgerrcode.UndefinedTable {
-			return nil, err
+			return err
 		}
+		QueryWarningsFrom(ctx).Add(&MissingMetricError{Metric: metric})
 	}
 
 	defer rows.Close()
-	return buildTimeSeries(rows)
+	return streamTimeSeries(rows, filter, process)
 }
 
-func (q *pgxQuerier) getMetricTableName(metric string) (string, error) {
+func (q *pgxQuerier) getMetricTableName(ctx context.Context, metric string) (string, error) {
 	var err error
 	var tableName string
 
@@ -952,9 +1699,18 @@ func (q *pgxQuerier) getMetricTableName(metric string) (string, error) {
 		return "", err
 	}
 
-	tableName, err = q.queryMetricTableName(metric)
+	missing, cachesMissing := q.metricTableNames.(missingMetricCache)
+	if cachesMissing && missing.IsMissing(metric) {
+		missingMetricCacheHits.Inc()
+		return "", &MissingMetricError{Metric: metric}
+	}
+
+	tableName, err = q.queryMetricTableName(ctx, metric)
 
 	if err != nil {
+		if cachesMissing && errors.Is(err, errMissingTableName) {
+			missing.SetMissing(metric)
+		}
 		return "", err
 	}
 
@@ -963,9 +1719,9 @@ func (q *pgxQuerier) getMetricTableName(metric string) (string, error) {
 	return tableName, err
 }
 
-func (q *pgxQuerier) queryMetricTableName(metric string) (string, error) {
+func (q *pgxQuerier) queryMetricTableName(ctx context.Context, metric string) (string, error) {
 	res, err := q.conn.Query(
-		context.Background(),
+		ctx,
 		getMetricsTableSQL,
 		metric,
 	)
@@ -977,7 +1733,7 @@ func (q *pgxQuerier) queryMetricTableName(metric string) (string, error) {
 	var tableName string
 	defer res.Close()
 	if !res.Next() {
-		return "", errMissingTableName
+		return "", &MissingMetricError{Metric: metric}
 	}
 
 	if err := res.Scan(&tableName); err != nil {
@@ -6,14 +6,17 @@ package pgmodel
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgproto3/v2"
 	"github.com/jackc/pgx/v4"
 	"github.com/prometheus/prometheus/pkg/labels"
@@ -30,6 +33,7 @@ type mockPGXConn struct {
 	ExecSQLs          []string
 	ExecArgs          [][]interface{}
 	ExecErr           error
+	ExecResult        pgconn.CommandTag
 	QuerySQLs         []string
 	QueryArgs         [][]interface{}
 	QueryResults      []rowResults
@@ -55,6 +59,9 @@ func (m *mockPGXConn) UseDatabase(dbName string) {
 func (m *mockPGXConn) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
 	m.ExecSQLs = append(m.ExecSQLs, sql)
 	m.ExecArgs = append(m.ExecArgs, arguments)
+	if m.ExecResult != nil {
+		return m.ExecResult, m.ExecErr
+	}
 	return pgconn.CommandTag([]byte{}), m.ExecErr
 }
 
@@ -78,7 +85,20 @@ func (m *mockPGXConn) CopyFrom(ctx context.Context, tableName pgx.Identifier, co
 	defer m.insertLock.Unlock()
 	m.CopyFromTableName = append(m.CopyFromTableName, tableName)
 	m.CopyFromColumns = append(m.CopyFromColumns, columnNames)
-	src := rowSrc.(*SampleInfoIterator)
+	src, ok := rowSrc.(*SampleInfoIterator)
+	if !ok {
+		for rowSrc.Next() {
+			values, err := rowSrc.Values()
+			if err != nil {
+				return 0, err
+			}
+			m.CopyFromRowsRows = append(m.CopyFromRowsRows, values)
+		}
+		if err := rowSrc.Err(); err != nil {
+			return 0, err
+		}
+		return int64(len(m.CopyFromRowsRows)), m.CopyFromError
+	}
 	rows := make([]samplesInfo, 0, len(src.sampleInfos))
 	rows = append(rows, src.sampleInfos...)
 	m.CopyFromRowSource = append(m.CopyFromRowSource, rows)
@@ -157,7 +177,11 @@ func (m *mockBatchResult) Exec() (pgconn.CommandTag, error) {
 
 // Query reads the results from the next query in the batch as if the query has been sent with Conn.Query.
 func (m *mockBatchResult) Query() (pgx.Rows, error) {
-	panic("not implemented")
+	defer func() { m.idx++ }()
+	if len(m.results) <= m.idx {
+		return &mockRows{results: nil, noNext: false}, nil
+	}
+	return &mockRows{results: m.results[m.idx], noNext: false}, nil
 }
 
 // Close closes the batch operation. This must be called before the underlying connection can be used again. Any error
@@ -190,7 +214,7 @@ func (m *mockRows) Close() {
 
 // Err returns any error that occurred while reading.
 func (m *mockRows) Err() error {
-	panic("not implemented")
+	return nil
 }
 
 // CommandTag returns the command tag from this query. It is only available after Rows is closed.
@@ -239,6 +263,10 @@ func (m *mockRows) Scan(dest ...interface{}) error {
 			if d, ok := dest[i].(*[]float64); ok {
 				*d = s
 			}
+		case []int32:
+			if d, ok := dest[i].(*[]int32); ok {
+				*d = s
+			}
 		case []int64:
 			if d, ok := dest[i].(*[]int64); ok {
 				*d = s
@@ -260,7 +288,9 @@ func (m *mockRows) Scan(dest ...interface{}) error {
 				*d = s
 			}
 		case float64:
-			if _, ok := dest[i].(float64); !ok {
+			_, ok1 := dest[i].(float64)
+			_, ok2 := dest[i].(*float64)
+			if !ok1 && !ok2 {
 				return fmt.Errorf("wrong value type float64")
 			}
 			dv := reflect.ValueOf(dest[i])
@@ -274,12 +304,20 @@ func (m *mockRows) Scan(dest ...interface{}) error {
 			dvp := reflect.Indirect(dv)
 			dvp.SetInt(int64(m.results[m.idx][i].(int32)))
 		case int32:
-			if _, ok := dest[i].(int32); !ok {
+			_, ok1 := dest[i].(int32)
+			_, ok2 := dest[i].(*int32)
+			if !ok1 && !ok2 {
 				return fmt.Errorf("wrong value type int32")
 			}
 			dv := reflect.ValueOf(dest[i])
 			dvp := reflect.Indirect(dv)
 			dvp.SetInt(int64(m.results[m.idx][i].(int32)))
+		case int16:
+			d, ok := dest[i].(*int16)
+			if !ok {
+				return fmt.Errorf("wrong value type int16")
+			}
+			*d = s
 		case uint64:
 			if _, ok := dest[i].(uint64); !ok {
 				return fmt.Errorf("wrong value type uint64")
@@ -290,12 +328,19 @@ func (m *mockRows) Scan(dest ...interface{}) error {
 		case int64:
 			_, ok1 := dest[i].(int64)
 			_, ok2 := dest[i].(*SeriesID)
-			if !ok1 && !ok2 {
+			_, ok3 := dest[i].(*int64)
+			if !ok1 && !ok2 && !ok3 {
 				return fmt.Errorf("wrong value type int64")
 			}
 			dv := reflect.ValueOf(dest[i])
 			dvp := reflect.Indirect(dv)
 			dvp.SetInt(m.results[m.idx][i].(int64))
+		case bool:
+			d, ok := dest[i].(*bool)
+			if !ok {
+				return fmt.Errorf("wrong value type bool")
+			}
+			*d = s
 		case string:
 			if _, ok := dest[i].(*string); !ok {
 				return fmt.Errorf("wrong value type string")
@@ -334,6 +379,15 @@ func createSeriesResults(x int64) []rowResults {
 	return ret
 }
 
+// createBulkSeriesResults returns the results of a single bulk series id lookup.
+func createBulkSeriesResults(x int64) []rowResults {
+	rows := make(rowResults, 0, x)
+	for _, r := range createSeriesResults(x) {
+		rows = append(rows, r...)
+	}
+	return []rowResults{rows}
+}
+
 func createSeries(x int) []*labels.Labels {
 	ret := make([]*labels.Labels, 0, x)
 	i := 1
@@ -363,6 +417,8 @@ func TestPGXInserterInsertSeries(t *testing.T) {
 		series       []*labels.Labels
 		queryResults []rowResults
 		queryErr     map[int]error
+		expectedErr  error
+		fallback     bool
 	}{
 		{
 			name: "Zero series",
@@ -370,22 +426,35 @@ func TestPGXInserterInsertSeries(t *testing.T) {
 		{
 			name:         "One series",
 			series:       createSeries(1),
-			queryResults: createSeriesResults(1),
+			queryResults: createBulkSeriesResults(1),
 		},
 		{
 			name:         "Two series",
 			series:       createSeries(2),
-			queryResults: createSeriesResults(2),
+			queryResults: createBulkSeriesResults(2),
 		},
 		{
 			name:         "Double series",
 			series:       append(createSeries(2), createSeries(1)...),
+			queryResults: createBulkSeriesResults(2),
+		},
+		{
+			name:         "Missing series in result",
+			series:       createSeries(2),
+			queryResults: createBulkSeriesResults(1),
+			expectedErr:  fmt.Errorf("missing series id for series 2 of 2"),
+		},
+		{
+			name:         "Deadlock falls back to one transaction per series",
+			series:       createSeries(2),
 			queryResults: createSeriesResults(2),
+			queryErr:     map[int]error{0: &pgconn.PgError{Code: pgerrcode.DeadlockDetected}},
+			fallback:     true,
 		},
 		{
 			name:         "Query err",
 			series:       createSeries(2),
-			queryResults: createSeriesResults(2),
+			queryResults: createBulkSeriesResults(2),
 			queryErr:     map[int]error{0: fmt.Errorf("some query error")},
 		},
 	}
@@ -397,7 +466,7 @@ func TestPGXInserterInsertSeries(t *testing.T) {
 				QueryResults: c.queryResults,
 			}
 
-			inserter := insertHandler{conn: mock, seriesCache: make(map[string]SeriesID)}
+			inserter := insertHandler{conn: mock, seriesCache: NewSeriesCache(0)}
 
 			lsi := make([]samplesInfo, 0)
 			for _, ser := range c.series {
@@ -411,6 +480,11 @@ func TestPGXInserterInsertSeries(t *testing.T) {
 			_, err := inserter.setSeriesIds(lsi)
 			if err != nil {
 				switch {
+				case c.expectedErr != nil:
+					if err.Error() != c.expectedErr.Error() {
+						t.Errorf("unexpected error:\ngot\n%s\nwanted\n%s", err, c.expectedErr)
+					}
+					return
 				case len(c.queryErr) > 0:
 					for _, qErr := range c.queryErr {
 						if err != qErr {
@@ -429,13 +503,192 @@ func TestPGXInserterInsertSeries(t *testing.T) {
 				}
 			}
 
+			if c.fallback {
+				if len(mock.Batch) != 1 {
+					t.Errorf("expected fallback to batched lookup")
+				}
+				return
+			}
+
+			if len(c.series) > 0 && (len(mock.QuerySQLs) != 1 || mock.QuerySQLs[0] != getSeriesIDsForLabelsSQL) {
+				t.Errorf("unexpected queries: %v", mock.QuerySQLs)
+			}
+
 			if c.queryErr != nil {
 				t.Errorf("expected query error:\ngot\n%v\nwanted\n%v", err, c.queryErr)
 			}
+
+			// The ids are stored in the series cache, shared with the other
+			// insert routines, which find them without querying.
+			for _, si := range lsi {
+				if id, err := inserter.seriesCache.GetSeries(*si.labels); err != nil || id != si.seriesID {
+					t.Errorf("series %v not cached: got %d, %v wanted %d", si.labels, id, err, si.seriesID)
+				}
+			}
+			other := insertHandler{conn: &mockPGXConn{}, seriesCache: inserter.seriesCache}
+			cached := make([]samplesInfo, 0, len(lsi))
+			for _, si := range lsi {
+				cached = append(cached, samplesInfo{labels: si.labels, seriesID: -1})
+			}
+			if _, err = other.setSeriesIds(cached); err != nil {
+				t.Fatal(err)
+			}
+			for i, si := range cached {
+				if si.seriesID != lsi[i].seriesID {
+					t.Errorf("unexpected cached id: got %d wanted %d", si.seriesID, lsi[i].seriesID)
+				}
+			}
+			if queries := other.conn.(*mockPGXConn).QuerySQLs; len(queries) != 0 {
+				t.Errorf("cached series looked up again: %v", queries)
+			}
 		})
 	}
 }
 
+func TestPGXInserterInsertSeriesOrder(t *testing.T) {
+	series := make([]*Labels, 0, 3)
+	for _, ser := range createSeries(3) {
+		ls, err := LabelsFromSlice(*ser)
+		if err != nil {
+			t.Fatal(err)
+		}
+		series = append(series, ls)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Compare(series[j]) < 0 })
+
+	for name, order := range map[string][]int{"sorted": {0, 0, 1, 2}, "unsorted": {2, 0, 1, 0}} {
+		t.Run(name, func(t *testing.T) {
+			mock := &mockPGXConn{QueryResults: createBulkSeriesResults(3)}
+			inserter := insertHandler{conn: mock, seriesCache: NewSeriesCache(0)}
+			lsi := make([]samplesInfo, 0, len(order))
+			for _, i := range order {
+				lsi = append(lsi, samplesInfo{labels: series[i], seriesID: -1})
+			}
+
+			if _, err := inserter.setSeriesIds(lsi); err != nil {
+				t.Fatal(err)
+			}
+			if len(mock.QueryArgs) != 1 || len(mock.QueryArgs[0][3].([]int32)) != 3 {
+				t.Fatalf("duplicates not removed: %v", mock.QueryArgs)
+			}
+			ids := make(map[*Labels]SeriesID)
+			for _, si := range lsi {
+				if id, ok := ids[si.labels]; ok && id != si.seriesID {
+					t.Errorf("duplicate series got ids %d and %d", id, si.seriesID)
+				}
+				ids[si.labels] = si.seriesID
+			}
+			if len(ids) != 3 {
+				t.Errorf("unexpected ids: %v", ids)
+			}
+		})
+	}
+}
+
+func TestInsertHandlerSplitsLargeRequests(t *testing.T) {
+	toCopiers := make(chan copyRequest, 3)
+	handler := insertHandler{
+		conn:        &mockPGXConn{},
+		pending:     pendingBuffers.Get().(*pendingBuffer),
+		seriesCache: NewSeriesCache(0),
+		toCopiers:   toCopiers,
+		retry:       newRetryPolicy(RetryConfig{}),
+	}
+
+	data := make([]samplesInfo, 2*DefaultBatchMaxSeries+10)
+	for i := range data {
+		data[i] = samplesInfo{seriesID: SeriesID(i), samples: []prompb.Sample{{Timestamp: int64(i)}}}
+	}
+	finished := &sync.WaitGroup{}
+	finished.Add(1)
+	if flushed := handler.handleReq(insertDataRequest{data: data, finished: finished, errChan: make(chan error, 1)}); !flushed {
+		t.Fatal("large request not flushed")
+	}
+
+	if len(toCopiers) != 2 {
+		t.Fatalf("unexpected number of flushes: %d", len(toCopiers))
+	}
+	if len(handler.pending.batch.sampleInfos) != 10 {
+		t.Errorf("unexpected pending series: %d", len(handler.pending.batch.sampleInfos))
+	}
+	handler.flush()
+	close(toCopiers)
+
+	copied := 0
+	for req := range toCopiers {
+		if n := len(req.data.batch.sampleInfos); n > DefaultBatchMaxSeries {
+			t.Errorf("batch over the flush size: %d series", n)
+		}
+		copied += len(req.data.batch.sampleInfos)
+		req.data.reportResults(nil)
+	}
+	if copied != len(data) {
+		t.Errorf("unexpected copied series: got %d wanted %d", copied, len(data))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		finished.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("request not finished after all its batches reported")
+	}
+}
+
+func TestInsertHandlerPipelinesFlushes(t *testing.T) {
+	toCopiers := make(chan copyRequest)
+	handler := insertHandler{
+		conn:        &mockPGXConn{},
+		pending:     pendingBuffers.Get().(*pendingBuffer),
+		seriesCache: NewSeriesCache(0),
+		toCopiers:   toCopiers,
+		retry:       newRetryPolicy(RetryConfig{}),
+		toFlusher:   make(chan *pendingBuffer),
+	}
+	go handler.runFlusher()
+	defer close(handler.toFlusher)
+
+	request := func(n int) insertDataRequest {
+		data := make([]samplesInfo, n)
+		for i := range data {
+			data[i] = samplesInfo{seriesID: SeriesID(i), samples: []prompb.Sample{{Timestamp: int64(i)}}}
+		}
+		finished := &sync.WaitGroup{}
+		finished.Add(1)
+		return insertDataRequest{data: data, finished: finished, errChan: make(chan error, 1)}
+	}
+
+	if flushed := handler.handleReq(request(DefaultBatchMaxSeries)); !flushed {
+		t.Fatal("full buffer not flushed")
+	}
+	// The first batch waits for a copier while the next one accumulates.
+	if flushed := handler.handleReq(request(10)); flushed {
+		t.Fatal("partial buffer flushed")
+	}
+	done := make(chan struct{})
+	go func() {
+		handler.flushPending()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("second batch handed over while the first one was flushing")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	for _, expected := range []int{DefaultBatchMaxSeries, 10} {
+		req := <-toCopiers
+		if n := len(req.data.batch.sampleInfos); n != expected {
+			t.Errorf("unexpected batch: got %d series wanted %d", n, expected)
+		}
+		req.data.reportResults(nil)
+	}
+	<-done
+}
+
 func createRows(x int) map[string][]samplesInfo {
 	return createRowsByMetric(x, 1)
 }
@@ -546,7 +799,7 @@ func TestPGXInserterInsertData(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			_, err = inserter.InsertData(c.rows)
+			_, err = inserter.InsertData(context.Background(), c.rows)
 
 			if err != nil {
 				var expErr error
@@ -566,7 +819,9 @@ func TestPGXInserterInsertData(t *testing.T) {
 					expErr = errMissingTableName
 				}
 
-				if err != expErr {
+				// The errors of the table lookup are answered by the
+				// failed insert routine, wrapped.
+				if !errors.Is(err, expErr) {
 					t.Errorf("unexpected error:\ngot\n%s\nwanted\n%s", err, expErr)
 				}
 
@@ -624,6 +879,92 @@ func TestPGXInserterInsertData(t *testing.T) {
 	}
 }
 
+func TestPGXInserterShardedInsertData(t *testing.T) {
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{{{"metricTableName_1", true}}},
+	}
+	mockMetrics := &mockMetricCache{metricCache: map[string]string{"metric_1": "metricTableName_1"}}
+	inserter, err := newPgxInserter(mock, mockMetrics, &Cfg{InsertersPerMetric: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inserter.Close()
+
+	data := make([]samplesInfo, 0)
+	shardsUsed := make(map[int]bool)
+	for i := 0; i < 50; i++ {
+		ls, err := LabelsFromSlice(labels.Labels{
+			{Name: MetricNameLabelName, Value: "metric_1"},
+			{Name: "series", Value: fmt.Sprintf("%d", i)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		shardsUsed[seriesShard(ls, 4)] = true
+		data = append(data, samplesInfo{
+			labels:   ls,
+			seriesID: SeriesID(i + 1),
+			samples:  []prompb.Sample{{Timestamp: int64(i), Value: float64(i)}},
+		})
+	}
+
+	inserted, err := inserter.InsertData(context.Background(), map[string][]samplesInfo{"metric_1": data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 50 {
+		t.Errorf("unexpected number of samples inserted: got %d wanted 50", inserted)
+	}
+
+	if len(mock.CopyFromRowSource) != len(shardsUsed) {
+		t.Errorf("unexpected number of copies: got %d wanted %d", len(mock.CopyFromRowSource), len(shardsUsed))
+	}
+
+	copied := make(map[SeriesID]int)
+	for _, rows := range mock.CopyFromRowSource {
+		shard := -1
+		for _, si := range rows {
+			copied[si.seriesID]++
+			s := seriesShard(si.labels, 4)
+			if shard != -1 && s != shard {
+				t.Errorf("series from different shards copied together")
+			}
+			shard = s
+		}
+	}
+	if len(copied) != 50 {
+		t.Errorf("unexpected number of series copied: got %d wanted 50", len(copied))
+	}
+}
+
+func TestPGXInserterShardedTableError(t *testing.T) {
+	// The routines of the shards without data of the first request look
+	// the table up after the request has been answered.
+	mockMetrics := &mockMetricCache{getMetricErr: fmt.Errorf("some metrics error")}
+	inserter, err := newPgxInserter(&mockPGXConn{}, mockMetrics, &Cfg{InsertersPerMetric: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inserter.Close()
+
+	for i := 0; i < 20; i++ {
+		ls, err := LabelsFromSlice(labels.Labels{
+			{Name: MetricNameLabelName, Value: fmt.Sprintf("metric_%d", i)},
+			{Name: "series", Value: "1"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := []samplesInfo{{labels: ls, seriesID: 1, samples: []prompb.Sample{{Timestamp: 1, Value: 1}}}}
+		_, err = inserter.InsertData(context.Background(), map[string][]samplesInfo{ls.metricName: data})
+		if err == nil || !strings.Contains(err.Error(), "some metrics error") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	// Let the routines of the other shards finish their lookups.
+	time.Sleep(10 * time.Millisecond)
+}
+
 func TestPGXQuerierQuery(t *testing.T) {
 	testCases := []struct {
 		name         string
@@ -726,14 +1067,14 @@ func TestPGXQuerierQuery(t *testing.T) {
 	FROM "prom_data"."foo" m
 	INNER JOIN "prom_data_series"."foo" s
 	ON m.series_id = s.id
-	WHERE m.series_id IN (1)
-	AND time >= '1970-01-01T00:00:01Z'
-	AND time <= '1970-01-01T00:00:02Z'
+	WHERE m.series_id = ANY($1)
+	AND time >= $2
+	AND time <= $3
 	GROUP BY s.id`},
 			sqlArgs: [][]interface{}{
 				{"foo", "bar"},
 				{"foo"},
-				nil,
+				{[]int64{1}, "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z"},
 			},
 			queryResults: []rowResults{
 				{{`foo`, []int64{1}}},
@@ -824,14 +1165,14 @@ func TestPGXQuerierQuery(t *testing.T) {
 	FROM "prom_data"."foo" m
 	INNER JOIN "prom_data_series"."foo" s
 	ON m.series_id = s.id
-	WHERE m.series_id IN (1)
-	AND time >= '1970-01-01T00:00:01Z'
-	AND time <= '1970-01-01T00:00:02Z'
+	WHERE m.series_id = ANY($1)
+	AND time >= $2
+	AND time <= $3
 	GROUP BY s.id`},
 			sqlArgs: [][]interface{}{
 				{"__name__", "bar"},
 				{"foo"},
-				nil,
+				{[]int64{1}, "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z"},
 			},
 			result: []*prompb.TimeSeries{
 				{
@@ -860,12 +1201,12 @@ func TestPGXQuerierQuery(t *testing.T) {
 	INNER JOIN "prom_data_series"."bar" s
 	ON m.series_id = s.id
 	WHERE labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $1 and l.value = $2)
-	AND time >= '1970-01-01T00:00:01Z'
-	AND time <= '1970-01-01T00:00:02Z'
+	AND time >= $3
+	AND time <= $4
 	GROUP BY s.id`},
 			sqlArgs: [][]interface{}{
 				{"bar"},
-				{MetricNameLabelName, "bar"},
+				{MetricNameLabelName, "bar", "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z"},
 			},
 			result: []*prompb.TimeSeries{
 				{
@@ -899,25 +1240,25 @@ func TestPGXQuerierQuery(t *testing.T) {
 	FROM "prom_data"."foo" m
 	INNER JOIN "prom_data_series"."foo" s
 	ON m.series_id = s.id
-	WHERE m.series_id IN (1)
-	AND time >= '1970-01-01T00:00:01Z'
-	AND time <= '1970-01-01T00:00:02Z'
+	WHERE m.series_id = ANY($1)
+	AND time >= $2
+	AND time <= $3
 	GROUP BY s.id`,
 				`SELECT table_name FROM _prom_catalog.get_metric_table_name_if_exists($1)`,
 				`SELECT (key_value_array(s.labels)).*, array_agg(m.time ORDER BY time), array_agg(m.value ORDER BY time)
 	FROM "prom_data"."bar" m
 	INNER JOIN "prom_data_series"."bar" s
 	ON m.series_id = s.id
-	WHERE m.series_id IN (1)
-	AND time >= '1970-01-01T00:00:01Z'
-	AND time <= '1970-01-01T00:00:02Z'
+	WHERE m.series_id = ANY($1)
+	AND time >= $2
+	AND time <= $3
 	GROUP BY s.id`},
 			sqlArgs: [][]interface{}{
 				{"__name__", "^$"},
 				{"foo"},
-				nil,
+				{[]int64{1}, "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z"},
 				{"bar"},
-				nil,
+				{[]int64{1}, "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z"},
 			},
 			result: []*prompb.TimeSeries{
 				{
@@ -959,25 +1300,25 @@ func TestPGXQuerierQuery(t *testing.T) {
 	FROM "prom_data"."foo" m
 	INNER JOIN "prom_data_series"."foo" s
 	ON m.series_id = s.id
-	WHERE m.series_id IN (1)
-	AND time >= '1970-01-01T00:00:01Z'
-	AND time <= '1970-01-01T00:00:02Z'
+	WHERE m.series_id = ANY($1)
+	AND time >= $2
+	AND time <= $3
 	GROUP BY s.id`,
 				`SELECT table_name FROM _prom_catalog.get_metric_table_name_if_exists($1)`,
 				`SELECT (key_value_array(s.labels)).*, array_agg(m.time ORDER BY time), array_agg(m.value ORDER BY time)
 	FROM "prom_data"."bar" m
 	INNER JOIN "prom_data_series"."bar" s
 	ON m.series_id = s.id
-	WHERE m.series_id IN (1)
-	AND time >= '1970-01-01T00:00:01Z'
-	AND time <= '1970-01-01T00:00:02Z'
+	WHERE m.series_id = ANY($1)
+	AND time >= $2
+	AND time <= $3
 	GROUP BY s.id`},
 			sqlArgs: [][]interface{}{
 				{"__name__", "foo", "__name__", "bar"},
 				{"foo"},
-				nil,
+				{[]int64{1}, "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z"},
 				{"bar"},
-				nil,
+				{[]int64{1}, "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z"},
 			},
 			result: []*prompb.TimeSeries{
 				{
@@ -1018,14 +1359,14 @@ func TestPGXQuerierQuery(t *testing.T) {
 	FROM "prom_data"."metric" m
 	INNER JOIN "prom_data_series"."metric" s
 	ON m.series_id = s.id
-	WHERE m.series_id IN (1,99,98)
-	AND time >= '1970-01-01T00:00:01Z'
-	AND time <= '1970-01-01T00:00:02Z'
+	WHERE m.series_id = ANY($1)
+	AND time >= $2
+	AND time <= $3
 	GROUP BY s.id`},
 			sqlArgs: [][]interface{}{
 				{"foo", "bar"},
 				{"metric"},
-				nil,
+				{[]int64{1, 99, 98}, "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z"},
 			},
 			result: []*prompb.TimeSeries{
 				{
@@ -1063,14 +1404,14 @@ func TestPGXQuerierQuery(t *testing.T) {
 	FROM "prom_data"."metric" m
 	INNER JOIN "prom_data_series"."metric" s
 	ON m.series_id = s.id
-	WHERE m.series_id IN (1,4,5)
-	AND time >= '1970-01-01T00:00:01Z'
-	AND time <= '1970-01-01T00:00:02Z'
+	WHERE m.series_id = ANY($1)
+	AND time >= $2
+	AND time <= $3
 	GROUP BY s.id`},
 			sqlArgs: [][]interface{}{
-				{"foo", "bar", "foo1", "bar1", "foo2", "^bar2$", "foo3", "^bar3$"},
+				{"foo", "bar", "foo1", "bar1", "foo2", "^(?:^bar2)$", "foo3", "^(?:bar3$)$"},
 				{"metric"},
-				nil,
+				{[]int64{1, 4, 5}, "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z"},
 			},
 			result: []*prompb.TimeSeries{
 				{
@@ -1111,14 +1452,14 @@ func TestPGXQuerierQuery(t *testing.T) {
 	FROM "prom_data"."metric" m
 	INNER JOIN "prom_data_series"."metric" s
 	ON m.series_id = s.id
-	WHERE m.series_id IN (1,2)
-	AND time >= '1970-01-01T00:00:01Z'
-	AND time <= '1970-01-01T00:00:02Z'
+	WHERE m.series_id = ANY($1)
+	AND time >= $2
+	AND time <= $3
 	GROUP BY s.id`},
 			sqlArgs: [][]interface{}{
-				{"foo", "", "foo1", "bar1", "foo2", "^bar2$", "foo3", "^bar3$"},
+				{"foo", "", "foo1", "bar1", "foo2", "^(?:^bar2$)$", "foo3", "^(?:bar3)$"},
 				{"metric"},
-				nil,
+				{[]int64{1, 2}, "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z"},
 			},
 			result: []*prompb.TimeSeries{
 				{
@@ -1150,7 +1491,7 @@ func TestPGXQuerierQuery(t *testing.T) {
 			}
 			querier := pgxQuerier{conn: mock, metricTableNames: mockMetrics}
 
-			result, err := querier.Query(c.query)
+			result, err := querier.Query(context.Background(), c.query)
 
 			if err != nil {
 				switch {
@@ -0,0 +1,69 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// The data tables store timestamps with microsecond precision, but the
+// remote write and read protocols carry milliseconds. Senders with finer
+// timestamps, such as OTLP bridges or custom agents, can send microseconds
+// in the same field for the metrics configured with MicrosecondMetrics, and
+// readers can ask for microseconds with WithMicrosecondPrecision.
+
+type microsecondPrecisionKey struct{}
+
+// WithMicrosecondPrecision returns a context whose reads take and return
+// timestamps in microseconds instead of milliseconds.
+func WithMicrosecondPrecision(ctx context.Context) context.Context {
+	return context.WithValue(ctx, microsecondPrecisionKey{}, true)
+}
+
+func microsecondPrecision(ctx context.Context) bool {
+	micros, _ := ctx.Value(microsecondPrecisionKey{}).(bool)
+	return micros
+}
+
+// sampleTime converts the timestamp of a sample to a time.
+func sampleTime(timestamp int64, microseconds bool) time.Time {
+	if microseconds {
+		return time.Unix(0, timestamp*int64(time.Microsecond))
+	}
+	return model.Time(timestamp).Time()
+}
+
+// sampleMillis converts the timestamp of a sample to milliseconds, rounding
+// microseconds down.
+func sampleMillis(timestamp int64, microseconds bool) int64 {
+	if !microseconds {
+		return timestamp
+	}
+	ms := timestamp / 1000
+	if timestamp < 0 && timestamp%1000 != 0 {
+		ms--
+	}
+	return ms
+}
+
+// toTimestamp converts t to a timestamp in milliseconds or microseconds.
+func toTimestamp(t time.Time, microseconds bool) int64 {
+	if microseconds {
+		return t.UnixNano() / int64(time.Microsecond)
+	}
+	return toMilis(t)
+}
+
+// timestampToRFC3339Nano formats a timestamp in milliseconds or
+// microseconds for SQL.
+func timestampToRFC3339Nano(timestamp int64, microseconds bool) string {
+	if !microseconds {
+		return toRFC3339Nano(timestamp)
+	}
+	return sampleTime(timestamp, true).UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,87 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestParseDataMicrosecondMetrics(t *testing.T) {
+	i := DBIngestor{
+		cache:              &mockCache{seriesCache: make(map[string]SeriesID)},
+		microsecondMetrics: regexp.MustCompile("^(?:otel_.*)$"),
+	}
+	tts := []prompb.TimeSeries{
+		{Labels: []prompb.Label{{Name: MetricNameLabelName, Value: "otel_latency"}}, Samples: []prompb.Sample{{Timestamp: 1500}}},
+		{Labels: []prompb.Label{{Name: MetricNameLabelName, Value: "up"}}, Samples: []prompb.Sample{{Timestamp: 1500}}},
+	}
+	data, _, err := i.parseData(tts, NewWriteRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !data["otel_latency"][0].microseconds || data["up"][0].microseconds {
+		t.Errorf("unexpected precisions: %+v", data)
+	}
+}
+
+func TestSampleInfoIteratorMicroseconds(t *testing.T) {
+	batch := NewSampleInfoIterator()
+	batch.Append(samplesInfo{samples: []prompb.Sample{{Timestamp: 1500}}, microseconds: true})
+	batch.Append(samplesInfo{samples: []prompb.Sample{{Timestamp: 2}}})
+
+	var times []time.Time
+	for batch.Next() {
+		row, err := batch.Values()
+		if err != nil {
+			t.Fatal(err)
+		}
+		times = append(times, row[0].(time.Time))
+	}
+	if !times[0].Equal(time.Unix(0, 1500*int64(time.Microsecond))) || !times[1].Equal(time.Unix(0, 2*int64(time.Millisecond))) {
+		t.Errorf("unexpected times: %v", times)
+	}
+	if batch.minSeen != 1 {
+		t.Errorf("unexpected min seen: %d", batch.minSeen)
+	}
+}
+
+func TestSampleMillis(t *testing.T) {
+	testCases := []struct {
+		timestamp    int64
+		microseconds bool
+		expected     int64
+	}{
+		{1500, false, 1500},
+		{1500, true, 1},
+		{-1500, true, -2},
+		{-2000, true, -2},
+	}
+	for _, c := range testCases {
+		if got := sampleMillis(c.timestamp, c.microseconds); got != c.expected {
+			t.Errorf("sampleMillis(%d, %v) = %d, expected %d", c.timestamp, c.microseconds, got, c.expected)
+		}
+	}
+}
+
+func TestTimestampPrecisionRoundTrip(t *testing.T) {
+	if got := timestampToRFC3339Nano(1500, true); got != "1970-01-01T00:00:00.0015Z" {
+		t.Errorf("unexpected microsecond time: %s", got)
+	}
+	if got := timestampToRFC3339Nano(1500, false); got != toRFC3339Nano(1500) {
+		t.Errorf("unexpected millisecond time: %s", got)
+	}
+	for _, micros := range []bool{false, true} {
+		if got := toTimestamp(sampleTime(123456, micros), micros); got != 123456 {
+			t.Errorf("round trip with microseconds %v gave %d", micros, got)
+		}
+	}
+	if microsecondPrecision(context.Background()) || !microsecondPrecision(WithMicrosecondPrecision(context.Background())) {
+		t.Error("unexpected context precision")
+	}
+}
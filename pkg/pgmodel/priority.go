@@ -0,0 +1,183 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLowPriorityShare is the default share of the in-flight budget
+	// low priority metrics may use.
+	DefaultLowPriorityShare = 0.5
+	// DefaultCriticalReserve is the default share of the in-flight budget
+	// only critical metrics may use.
+	DefaultCriticalReserve = 0.1
+)
+
+// priorityClass is the priority of the samples of a metric under
+// backpressure.
+type priorityClass int
+
+const (
+	priorityLow priorityClass = iota
+	priorityNormal
+	priorityCritical
+)
+
+func (c priorityClass) String() string {
+	switch c {
+	case priorityLow:
+		return "low"
+	case priorityCritical:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+// PriorityConfig assigns metrics to priority classes, which share the
+// in-flight budget set by Cfg.MaxInFlightSamples. Critical metrics may use
+// the whole budget. Normal metrics may not use the CriticalReserve share of
+// it, and wait for space like any write beyond it. Low priority metrics may
+// only use the LowShare of the budget, and are shed beyond it, so that they
+// give way first under backpressure.
+type PriorityConfig struct {
+	// Critical matches the names of the critical metrics.
+	Critical string
+	// Low matches the names of the low priority metrics.
+	Low string
+	// LowShare is the share of the in-flight budget low priority metrics
+	// may use.
+	LowShare float64
+	// CriticalReserve is the share of the in-flight budget reserved for the
+	// critical metrics.
+	CriticalReserve float64
+}
+
+// priorities classifies the metrics, caching the class of each metric name.
+type priorities struct {
+	critical *regexp.Regexp
+	low      *regexp.Regexp
+	lowMax   int64
+	otherMax int64
+
+	lock    sync.RWMutex
+	classes map[string]priorityClass
+}
+
+// newPriorities returns the priorities sharing a budget of limit samples, or
+// nil if no metric has a priority.
+func newPriorities(cfg PriorityConfig, limit int64) (*priorities, error) {
+	if cfg.Critical == "" && cfg.Low == "" {
+		return nil, nil
+	}
+	if cfg.LowShare <= 0 || cfg.LowShare > 1 {
+		return nil, fmt.Errorf("invalid low priority share %v, expected a value in (0, 1]", cfg.LowShare)
+	}
+	if cfg.CriticalReserve < 0 || cfg.CriticalReserve >= 1 {
+		return nil, fmt.Errorf("invalid critical reserve %v, expected a value in [0, 1)", cfg.CriticalReserve)
+	}
+
+	p := &priorities{
+		lowMax:   int64(cfg.LowShare * float64(limit)),
+		otherMax: int64((1 - cfg.CriticalReserve) * float64(limit)),
+		classes:  make(map[string]priorityClass),
+	}
+	var err error
+	if p.critical, err = compileMetricPattern(cfg.Critical); err != nil {
+		return nil, fmt.Errorf("invalid critical metrics pattern: %w", err)
+	}
+	if p.low, err = compileMetricPattern(cfg.Low); err != nil {
+		return nil, fmt.Errorf("invalid low priority metrics pattern: %w", err)
+	}
+	return p, nil
+}
+
+// compileMetricPattern compiles a regular expression matching whole metric
+// names, as in PromQL, or returns nil for an empty pattern.
+func compileMetricPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// class returns the class of a metric. Critical patterns take precedence.
+func (p *priorities) class(metric string) priorityClass {
+	p.lock.RLock()
+	c, ok := p.classes[metric]
+	p.lock.RUnlock()
+	if ok {
+		return c
+	}
+
+	switch {
+	case p.critical != nil && p.critical.MatchString(metric):
+		c = priorityCritical
+	case p.low != nil && p.low.MatchString(metric):
+		c = priorityLow
+	default:
+		c = priorityNormal
+	}
+	p.lock.Lock()
+	p.classes[metric] = c
+	p.lock.Unlock()
+	return c
+}
+
+// acquire reserves the in-flight budget of rows by class, from the most to
+// the least important. The rows of the low priority metrics not fitting in
+// their share are removed from rows and counted as shed. It returns the
+// number of samples reserved, which are all released on error.
+func (p *priorities) acquire(ctx context.Context, b *inFlightBudget, rows map[string][]samplesInfo, timeout time.Duration) (int64, error) {
+	var perClass [priorityCritical + 1]int64
+	for metric, data := range rows {
+		perClass[p.class(metric)] += countSamples(data)
+	}
+
+	var acquired int64
+	if n := perClass[priorityCritical]; n > 0 {
+		if err := b.acquire(ctx, n, timeout); err != nil {
+			return 0, err
+		}
+		acquired += n
+	}
+	if n := perClass[priorityNormal]; n > 0 {
+		if err := b.acquireUpTo(ctx, n, p.otherMax, timeout); err != nil {
+			b.release(acquired)
+			return 0, err
+		}
+		acquired += n
+	}
+	if n := perClass[priorityLow]; n > 0 {
+		if !b.tryAcquire(n, p.lowMax) {
+			var shed []string
+			for metric := range rows {
+				if p.class(metric) == priorityLow {
+					delete(rows, metric)
+					shed = append(shed, metric)
+				}
+			}
+			shedSamples.WithLabelValues(priorityLow.String()).Add(float64(n))
+			emitDataLoss(dataLossShed, uint64(n), shed)
+			return acquired, nil
+		}
+		acquired += n
+	}
+	return acquired, nil
+}
+
+func countSamples(data []samplesInfo) int64 {
+	var n int64
+	for _, si := range data {
+		n += int64(len(si.samples))
+	}
+	return n
+}
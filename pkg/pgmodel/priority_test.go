@@ -0,0 +1,163 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestNewPriorities(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cfg     PriorityConfig
+		isNil   bool
+		isError bool
+	}{
+		{name: "disabled", cfg: PriorityConfig{LowShare: 0.5}, isNil: true},
+		{name: "valid", cfg: PriorityConfig{Critical: "up|slo_.*", Low: "debug_.*", LowShare: 0.5, CriticalReserve: 0.1}},
+		{name: "invalid pattern", cfg: PriorityConfig{Low: "(", LowShare: 0.5}, isError: true},
+		{name: "invalid low share", cfg: PriorityConfig{Low: "debug_.*", LowShare: 0}, isError: true},
+		{name: "invalid critical reserve", cfg: PriorityConfig{Critical: "up", LowShare: 0.5, CriticalReserve: 1}, isError: true},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			p, err := newPriorities(c.cfg, 100)
+			if (err != nil) != c.isError {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !c.isError && (p == nil) != c.isNil {
+				t.Errorf("unexpected priorities: %v", p)
+			}
+		})
+	}
+}
+
+func TestPriorityClass(t *testing.T) {
+	p, err := newPriorities(PriorityConfig{Critical: "up|slo_.*", Low: "debug_.*|slo_debug", LowShare: 0.5}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]priorityClass{
+		"up":              priorityCritical,
+		"slo_errors":      priorityCritical,
+		"slo_debug":       priorityCritical,
+		"debug_requests":  priorityLow,
+		"http_requests":   priorityNormal,
+		"upstream_errors": priorityNormal,
+	}
+	for i := 0; i < 2; i++ {
+		for metric, class := range expected {
+			if got := p.class(metric); got != class {
+				t.Errorf("unexpected class of %s: got %s wanted %s", metric, got, class)
+			}
+		}
+	}
+}
+
+func priorityRows(samples map[string]int) map[string][]samplesInfo {
+	rows := make(map[string][]samplesInfo)
+	for metric, n := range samples {
+		rows[metric] = []samplesInfo{{samples: make([]prompb.Sample, n)}}
+	}
+	return rows
+}
+
+func TestPrioritiesAcquire(t *testing.T) {
+	p, err := newPriorities(PriorityConfig{Critical: "up", Low: "debug_.*", LowShare: 0.5, CriticalReserve: 0.2}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newInFlightBudget(10)
+
+	rows := priorityRows(map[string]int{"up": 1, "requests": 2, "debug_a": 2})
+	acquired, err := p.acquire(context.Background(), b, rows, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acquired != 5 || len(rows) != 3 {
+		t.Fatalf("unexpected acquisition: got %d samples of %d metrics", acquired, len(rows))
+	}
+
+	// Low priority samples over their share are shed, the others kept.
+	shedBefore := testutil.ToFloat64(shedSamples.WithLabelValues("low"))
+	rows = priorityRows(map[string]int{"requests": 1, "debug_a": 1, "debug_b": 2})
+	acquired, err = p.acquire(context.Background(), b, rows, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rows["requests"]; acquired != 1 || len(rows) != 1 || !ok {
+		t.Fatalf("unexpected acquisition: got %d samples of %v", acquired, rows)
+	}
+	if shed := testutil.ToFloat64(shedSamples.WithLabelValues("low")) - shedBefore; shed != 3 {
+		t.Errorf("unexpected shed samples: got %v wanted 3", shed)
+	}
+
+	// Normal samples may not use the critical reserve.
+	rows = priorityRows(map[string]int{"requests": 3})
+	if _, err = p.acquire(context.Background(), b, rows, 10*time.Millisecond); err != ErrInFlightLimitExceeded {
+		t.Fatalf("unexpected error:\ngot\n%v\nwanted\n%v", err, ErrInFlightLimitExceeded)
+	}
+
+	// Critical samples may.
+	rows = priorityRows(map[string]int{"up": 4})
+	if acquired, err = p.acquire(context.Background(), b, rows, time.Millisecond); err != nil || acquired != 4 {
+		t.Fatalf("unexpected acquisition: got %d, %v", acquired, err)
+	}
+
+	// A failed acquisition releases the samples acquired for the other classes.
+	b.release(b.inUse())
+	if err = b.acquire(context.Background(), 7, 0); err != nil {
+		t.Fatal(err)
+	}
+	rows = priorityRows(map[string]int{"up": 1, "requests": 2})
+	if _, err = p.acquire(context.Background(), b, rows, 10*time.Millisecond); err != ErrInFlightLimitExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b.inUse() != 7 {
+		t.Errorf("unexpected in-flight samples: got %d wanted 7", b.inUse())
+	}
+}
+
+func TestPGXInserterShedding(t *testing.T) {
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{{{"metricTableName_0", true}}},
+	}
+	mockMetrics := &mockMetricCache{metricCache: map[string]string{}}
+	cfg := &Cfg{
+		MaxInFlightSamples: 10,
+		Priorities:         PriorityConfig{Low: "metric_0", LowShare: 0.5},
+	}
+	inserter, err := newPgxInserter(mock, mockMetrics, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inserter.Close()
+
+	if err = inserter.inFlight.acquire(context.Background(), 5, 0); err != nil {
+		t.Fatal(err)
+	}
+	rows := createRows(3)
+	for i := range rows["metric_0"] {
+		rows["metric_0"][i].seriesID = SeriesID(i + 1)
+		rows["metric_0"][i].samples = []prompb.Sample{{Timestamp: 1, Value: 1}}
+	}
+	inserted, err := inserter.InsertData(context.Background(), rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 3 {
+		t.Errorf("shed samples not acknowledged: got %d wanted 3", inserted)
+	}
+	if len(mock.CopyFromRowSource) != 0 {
+		t.Errorf("shed samples copied: %v", mock.CopyFromRowSource)
+	}
+	if inserter.inFlight.inUse() != 5 {
+		t.Errorf("unexpected in-flight samples: got %d wanted 5", inserter.inFlight.inUse())
+	}
+}
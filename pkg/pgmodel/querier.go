@@ -5,18 +5,33 @@
 package pgmodel
 
 import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+
 	"github.com/timescale/timescale-prometheus/pkg/prompb"
 )
 
 // Reader reads the data based on the provided read request.
 type Reader interface {
-	Read(*prompb.ReadRequest) (*prompb.ReadResponse, error)
+	Read(context.Context, *prompb.ReadRequest) (*prompb.ReadResponse, error)
+}
+
+// StreamReader reads the data based on the provided read request and streams
+// the results as XOR-encoded chunks, one ChunkedReadResponse frame at a time.
+type StreamReader interface {
+	ReadStreamed(context.Context, *prompb.ReadRequest, io.Writer) error
 }
 
 // Querier queries the data using the provided query data and returns the
-// matching timeseries.
+// matching timeseries. Canceling the context cancels the SQL queries.
 type Querier interface {
-	Query(*prompb.Query) ([]*prompb.TimeSeries, error)
+	Query(context.Context, *prompb.Query) ([]*prompb.TimeSeries, error)
+	// QueryStreamed calls process for each matching timeseries as soon as it
+	// is read from the database, without materializing the whole result.
+	QueryStreamed(context.Context, *prompb.Query, func(*prompb.TimeSeries) error) error
 }
 
 //HealthChecker allows checking for proper operations
@@ -32,10 +47,41 @@ type QueryHealthChecker interface {
 
 // DBReader reads data from the database.
 type DBReader struct {
-	db QueryHealthChecker
+	db       QueryHealthChecker
+	cache    *queryCache
+	queryLog *queryLog
+	// stripLabels are the external labels removed from the series read.
+	stripLabels labels.Labels
+	// redactionRules redact the series read by the callers other than the
+	// admins.
+	redactionRules []RedactionRule
+}
+
+// EnableQueryCache caches the results of the queries run by Read. Streamed
+// reads are not cached.
+func (r *DBReader) EnableQueryCache(cfg QueryCacheConfig) error {
+	cache, err := newQueryCache(cfg)
+	if err != nil {
+		return err
+	}
+	r.cache = cache
+	return nil
 }
 
-func (r *DBReader) Read(req *prompb.ReadRequest) (*prompb.ReadResponse, error) {
+// EnableQueryLog logs the queries of the remote reads.
+func (r *DBReader) EnableQueryLog(cfg QueryLogConfig) {
+	r.queryLog = newQueryLog(cfg)
+}
+
+// EnableLivenessMarkers emits a staleness marker at the time each series
+// went stale, as recorded by connectors with the StaleMarkersLiveness policy.
+func (r *DBReader) EnableLivenessMarkers() {
+	if q, ok := r.db.(*pgxQuerier); ok {
+		q.livenessMarkers = true
+	}
+}
+
+func (r *DBReader) Read(ctx context.Context, req *prompb.ReadRequest) (*prompb.ReadResponse, error) {
 	if req == nil {
 		return nil, nil
 	}
@@ -45,7 +91,7 @@ func (r *DBReader) Read(req *prompb.ReadRequest) (*prompb.ReadResponse, error) {
 	}
 
 	for i, q := range req.Queries {
-		tts, err := r.db.Query(q)
+		tts, err := r.query(ctx, q)
 		if err != nil {
 			return nil, err
 		}
@@ -57,6 +103,51 @@ func (r *DBReader) Read(req *prompb.ReadRequest) (*prompb.ReadResponse, error) {
 	return &resp, nil
 }
 
+func (r *DBReader) query(ctx context.Context, q *prompb.Query) (tts []*prompb.TimeSeries, err error) {
+	begin := time.Now()
+	// The cache holds results in milliseconds.
+	if r.cache == nil || microsecondPrecision(ctx) {
+		tts, err = r.db.Query(ctx, q)
+	} else {
+		tts, err = r.cache.query(ctx, r.db, q, begin)
+	}
+
+	var stats queryStats
+	for _, ts := range tts {
+		stripExternalLabels(ts, r.stripLabels)
+		r.redact(ctx, ts)
+		stats.add(ts)
+	}
+	r.queryLog.log(q, stats, time.Since(begin), err)
+	return tts, err
+}
+
+// ReadStreamed executes the queries in the read request and writes the results
+// to w as chunk-encoded frames as soon as each series arrives.
+func (r *DBReader) ReadStreamed(ctx context.Context, req *prompb.ReadRequest, w io.Writer) error {
+	if req == nil {
+		return nil
+	}
+
+	for i, q := range req.Queries {
+		queryIndex := int64(i)
+		begin := time.Now()
+		var stats queryStats
+		err := r.db.QueryStreamed(ctx, q, func(ts *prompb.TimeSeries) error {
+			stripExternalLabels(ts, r.stripLabels)
+			r.redact(ctx, ts)
+			stats.add(ts)
+			return writeChunkedSeries(w, queryIndex, ts)
+		})
+		r.queryLog.log(q, stats, time.Since(begin), err)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // HealthCheck checks that the reader is properly connected
 func (r *DBReader) HealthCheck() error {
 	return r.db.HealthCheck()
@@ -4,10 +4,18 @@
 package pgmodel
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
 	"fmt"
+	"hash/crc32"
+	"io"
 	"reflect"
 	"testing"
 
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
 	"github.com/timescale/timescale-prometheus/pkg/prompb"
 )
 
@@ -17,10 +25,22 @@ type mockQuerier struct {
 	healthCheckCalled bool
 }
 
-func (q *mockQuerier) Query(query *prompb.Query) ([]*prompb.TimeSeries, error) {
+func (q *mockQuerier) Query(_ context.Context, query *prompb.Query) ([]*prompb.TimeSeries, error) {
 	return q.tts, q.err
 }
 
+func (q *mockQuerier) QueryStreamed(_ context.Context, query *prompb.Query, process func(*prompb.TimeSeries) error) error {
+	if q.err != nil {
+		return q.err
+	}
+	for _, ts := range q.tts {
+		if err := process(ts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (q *mockQuerier) HealthCheck() error {
 	q.healthCheckCalled = true
 	return nil
@@ -107,9 +127,9 @@ func TestDBReaderRead(t *testing.T) {
 				err: c.err,
 			}
 
-			r := DBReader{mq}
+			r := DBReader{db: mq}
 
-			res, err := r.Read(c.req)
+			res, err := r.Read(context.Background(), c.req)
 
 			if err != nil {
 				if c.err == nil || err != c.err {
@@ -144,10 +164,171 @@ func TestDBReaderRead(t *testing.T) {
 
 }
 
+// readChunkedFrames decodes a stream written by a ChunkedWriter.
+func readChunkedFrames(t *testing.T, stream []byte) []*prompb.ChunkedReadResponse {
+	r := bufio.NewReader(bytes.NewReader(stream))
+	res := make([]*prompb.ChunkedReadResponse, 0)
+	for {
+		size, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return res
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var checksum uint32
+		if err = binary.Read(r, binary.BigEndian, &checksum); err != nil {
+			t.Fatal(err)
+		}
+		frame := make([]byte, size)
+		if _, err = io.ReadFull(r, frame); err != nil {
+			t.Fatal(err)
+		}
+		if crc32.Checksum(frame, castagnoliTable) != checksum {
+			t.Fatal("checksum mismatch")
+		}
+		msg := &prompb.ChunkedReadResponse{}
+		if err = proto.Unmarshal(frame, msg); err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, msg)
+	}
+}
+
+func decodeChunks(t *testing.T, chks []prompb.Chunk) []prompb.Sample {
+	samples := make([]prompb.Sample, 0)
+	for _, chk := range chks {
+		c, err := chunkenc.FromData(chunkenc.Encoding(chk.Type), chk.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		it := c.Iterator(nil)
+		for it.Next() {
+			ts, v := it.At()
+			samples = append(samples, prompb.Sample{Timestamp: ts, Value: v})
+		}
+		if it.Err() != nil {
+			t.Fatal(it.Err())
+		}
+	}
+	return samples
+}
+
+func TestDBReaderReadStreamed(t *testing.T) {
+	manySamples := make([]prompb.Sample, 0, 3*maxSamplesInChunk+1)
+	for i := 0; i < cap(manySamples); i++ {
+		manySamples = append(manySamples, prompb.Sample{Timestamp: int64(i), Value: float64(i)})
+	}
+	testCases := []struct {
+		name string
+		req  *prompb.ReadRequest
+		tts  []*prompb.TimeSeries
+		err  error
+	}{
+		{
+			name: "No request",
+		},
+		{
+			name: "Query error",
+			req: &prompb.ReadRequest{
+				Queries: []*prompb.Query{
+					{StartTimestampMs: 1},
+				},
+			},
+			err: fmt.Errorf("some error"),
+		},
+		{
+			name: "Simple query",
+			req: &prompb.ReadRequest{
+				Queries: []*prompb.Query{
+					{StartTimestampMs: 1},
+				},
+			},
+			tts: []*prompb.TimeSeries{
+				{
+					Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "foo"}},
+					Samples: []prompb.Sample{{Timestamp: 1, Value: 2}},
+				},
+			},
+		},
+		{
+			name: "Multiple queries, multiple chunks",
+			req: &prompb.ReadRequest{
+				Queries: []*prompb.Query{
+					{StartTimestampMs: 1},
+					{StartTimestampMs: 1},
+				},
+			},
+			tts: []*prompb.TimeSeries{
+				{
+					Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "foo"}},
+					Samples: manySamples,
+				},
+				{
+					Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "bar"}},
+					Samples: []prompb.Sample{{Timestamp: 1, Value: 2}},
+				},
+			},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mq := &mockQuerier{
+				tts: c.tts,
+				err: c.err,
+			}
+
+			r := DBReader{db: mq}
+			buf := &bytes.Buffer{}
+
+			err := r.ReadStreamed(context.Background(), c.req, NewChunkedWriter(buf, nil))
+
+			if err != nil {
+				if c.err == nil || err != c.err {
+					t.Errorf("unexpected error:\ngot\n%s\nwanted\n%s\n", err, c.err)
+				}
+				return
+			}
+
+			frames := readChunkedFrames(t, buf.Bytes())
+			if c.req == nil {
+				if len(frames) != 0 {
+					t.Errorf("unexpected frames: got %d wanted 0", len(frames))
+				}
+				return
+			}
+
+			if len(frames) != len(c.req.Queries)*len(c.tts) {
+				t.Fatalf("unexpected number of frames: got %d wanted %d", len(frames), len(c.req.Queries)*len(c.tts))
+			}
+
+			for i, frame := range frames {
+				queryIndex := int64(i / len(c.tts))
+				if frame.QueryIndex != queryIndex {
+					t.Errorf("unexpected query index: got %d wanted %d", frame.QueryIndex, queryIndex)
+				}
+				expected := c.tts[i%len(c.tts)]
+				series := frame.ChunkedSeries[0]
+				if !reflect.DeepEqual(series.Labels, expected.Labels) {
+					t.Errorf("unexpected labels:\ngot\n%v\nwanted\n%v", series.Labels, expected.Labels)
+				}
+				if len(series.Chunks) != len(expected.Samples)/maxSamplesInChunk+1 {
+					t.Errorf("unexpected number of chunks: got %d", len(series.Chunks))
+				}
+				samples := decodeChunks(t, series.Chunks)
+				if !reflect.DeepEqual(samples, expected.Samples) {
+					t.Errorf("unexpected samples:\ngot\n%v\nwanted\n%v", samples, expected.Samples)
+				}
+			}
+		})
+	}
+}
+
 func TestHealthCheck(t *testing.T) {
 	mq := &mockQuerier{}
 
-	r := DBReader{mq}
+	r := DBReader{db: mq}
 
 	err := r.HealthCheck()
 	if err != nil {
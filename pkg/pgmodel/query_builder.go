@@ -16,6 +16,13 @@ import (
 	"github.com/timescale/timescale-prometheus/pkg/prompb"
 )
 
+// Label matchers follow the semantics of Prometheus, where a series without
+// a label matches as if it had the label with an empty value. The matchers
+// that match the empty value, such as {job=""}, {job!="api"} or {job=~".*"},
+// are therefore built as the negation of their opposite, which also selects
+// the series without the label: {job!="api"} becomes "no label job=api"
+// rather than "a label job with another value". The other matchers, such as
+// {job!=""} or {job=~".+"}, require the label.
 const (
 	subQueryEQ            = "labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value = $%d)"
 	subQueryEQMatchEmpty  = "NOT labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value != $%d)"
@@ -39,21 +46,58 @@ const (
 	INNER JOIN %[2]s s
 	ON m.series_id = s.id
 	WHERE %[3]s
-	AND time >= '%[4]s'
-	AND time <= '%[5]s'
+	AND time >= $%[4]d
+	AND time <= $%[5]d
 	GROUP BY s.id`
 
 	timeseriesBySeriesIDsSQLFormat = `SELECT (key_value_array(s.labels)).*, array_agg(m.time ORDER BY time), array_agg(m.value ORDER BY time)
 	FROM %[1]s m
 	INNER JOIN %[2]s s
 	ON m.series_id = s.id
-	WHERE m.series_id IN (%[3]s)
-	AND time >= '%[4]s'
-	AND time <= '%[5]s'
+	WHERE m.series_id = ANY($1)
+	AND time >= $2
+	AND time <= $3
 	GROUP BY s.id`
+
+	// The liveness variants also return the time the series last went stale,
+	// if it falls in the range.
+	timeseriesByMetricWithLivenessSQLFormat = `SELECT (key_value_array(s.labels)).*, array_agg(m.time ORDER BY time), array_agg(m.value ORDER BY time), l.stale_at
+	FROM %[1]s m
+	INNER JOIN %[2]s s
+	ON m.series_id = s.id
+	LEFT JOIN _prom_catalog.series_liveness l
+	ON l.series_id = s.id AND l.stale_at >= $%[4]d AND l.stale_at <= $%[5]d
+	WHERE %[3]s
+	AND time >= $%[4]d
+	AND time <= $%[5]d
+	GROUP BY s.id, l.stale_at`
+
+	timeseriesBySeriesIDsWithLivenessSQLFormat = `SELECT (key_value_array(s.labels)).*, array_agg(m.time ORDER BY time), array_agg(m.value ORDER BY time), l.stale_at
+	FROM %[1]s m
+	INNER JOIN %[2]s s
+	ON m.series_id = s.id
+	LEFT JOIN _prom_catalog.series_liveness l
+	ON l.series_id = s.id AND l.stale_at >= $2 AND l.stale_at <= $3
+	WHERE m.series_id = ANY($1)
+	AND time >= $2
+	AND time <= $3
+	GROUP BY s.id, l.stale_at`
 )
 
-func buildSubQueries(query *prompb.Query) (string, []string, []interface{}, error) {
+// subQueryShapes identifies the clause of each kind of label matcher in the
+// shape of a query.
+var subQueryShapes = map[string]byte{
+	subQueryEQ:            'a',
+	subQueryEQMatchEmpty:  'b',
+	subQueryNEQ:           'c',
+	subQueryNEQMatchEmpty: 'd',
+	subQueryRE:            'e',
+	subQueryREMatchEmpty:  'f',
+	subQueryNRE:           'g',
+	subQueryNREMatchEmpty: 'h',
+}
+
+func buildSubQueries(query *prompb.Query) (string, matcherClauses, []interface{}, error) {
 	var err error
 	metric := ""
 	metricMatcherCount := 0
@@ -61,7 +105,7 @@ func buildSubQueries(query *prompb.Query) (string, []string, []interface{}, erro
 	matchers, err := fromLabelMatchers(query.Matchers)
 
 	if err != nil {
-		return "", nil, nil, err
+		return "", matcherClauses{}, nil, err
 	}
 
 	for _, m := range matchers {
@@ -102,10 +146,8 @@ func buildSubQueries(query *prompb.Query) (string, []string, []interface{}, erro
 		}
 
 		if err != nil {
-			return "", nil, nil, err
+			return "", matcherClauses{}, nil, err
 		}
-
-		// Empty value (default case) is ignored.
 	}
 
 	// We can be certain that we want a single metric only if we find a single metric name matcher.
@@ -116,7 +158,7 @@ func buildSubQueries(query *prompb.Query) (string, []string, []interface{}, erro
 	}
 	clauses, values := cb.build()
 
-	if len(clauses) == 0 {
+	if len(clauses.formats) == 0 {
 		err = fmt.Errorf("no clauses generated")
 	}
 
@@ -151,59 +193,84 @@ func fromLabelMatchers(matchers []*prompb.LabelMatcher) ([]*labels.Matcher, erro
 	return result, nil
 }
 
+// matcherClauses are the SQL clauses of the label matchers of a query, with
+// their arguments numbered from $1 in order. Their shape, the kinds of the
+// clauses in order, is all the SQL of the query depends on besides the
+// metric table, so the clauses are only rendered for the shapes missing from
+// sqlTemplates.
+type matcherClauses struct {
+	shape   string
+	formats []string
+}
+
+func (c matcherClauses) String() string {
+	clauses := make([]string, 0, len(c.formats))
+	argIndex := 1
+	for _, format := range c.formats {
+		argCount := strings.Count(format, "%d")
+		argIndexes := make([]interface{}, 0, argCount)
+		for ; argCount > 0; argCount-- {
+			argIndexes = append(argIndexes, argIndex)
+			argIndex++
+		}
+		clauses = append(clauses, fmt.Sprintf(format, argIndexes...))
+	}
+	return strings.Join(clauses, " AND ")
+}
+
 type clauseBuilder struct {
-	clauses []string
+	clauses matcherClauses
+	shape   []byte
 	args    []interface{}
 }
 
 func (c *clauseBuilder) addClause(clause string, args ...interface{}) error {
-	argIndex := len(c.args) + 1
 	argCountInClause := strings.Count(clause, "%d")
 
 	if argCountInClause != len(args) {
 		return fmt.Errorf("invalid number of args")
 	}
 
-	argIndexes := make([]interface{}, 0, argCountInClause)
-
-	for argCountInClause > 0 {
-		argIndexes = append(argIndexes, argIndex)
-		argIndex++
-		argCountInClause--
-	}
-
-	c.clauses = append(c.clauses, fmt.Sprintf(clause, argIndexes...))
+	c.clauses.formats = append(c.clauses.formats, clause)
+	c.shape = append(c.shape, subQueryShapes[clause])
 	c.args = append(c.args, args...)
 
 	return nil
 }
 
-func (c *clauseBuilder) build() ([]string, []interface{}) {
+func (c *clauseBuilder) build() (matcherClauses, []interface{}) {
+	c.clauses.shape = string(c.shape)
 	return c.clauses, c.args
 }
 
-func buildTimeSeries(rows pgx.Rows) ([]*prompb.TimeSeries, error) {
-	results := make([]*prompb.TimeSeries, 0)
-
+// streamTimeSeries converts each row into a timeseries and hands it to process
+// as soon as it is read.
+func streamTimeSeries(rows pgx.Rows, filter metricTimeRangeFilter, process func(*prompb.TimeSeries) error) error {
 	for rows.Next() {
 		var (
 			keys       []string
 			vals       []string
 			timestamps []time.Time
 			values     []float64
+			staleAt    *time.Time
 		)
-		err := rows.Scan(&keys, &vals, &timestamps, &values)
+		dest := []interface{}{&keys, &vals, &timestamps, &values}
+		if filter.liveness {
+			dest = append(dest, &staleAt)
+		}
+		err := rows.Scan(dest...)
 
 		if err != nil {
-			return nil, err
+			return err
 		}
+		queryRowsScanned.Inc()
 
 		if len(timestamps) != len(values) {
-			return nil, fmt.Errorf("query returned a mismatch in timestamps and values")
+			return fmt.Errorf("query returned a mismatch in timestamps and values")
 		}
 
 		if len(keys) != len(vals) {
-			return nil, fmt.Errorf("query returned a mismatch in label keys and values")
+			return fmt.Errorf("query returned a mismatch in label keys and values")
 		}
 
 		promLabels := make([]prompb.Label, 0, len(keys))
@@ -226,45 +293,99 @@ func buildTimeSeries(rows pgx.Rows) ([]*prompb.TimeSeries, error) {
 
 		for i := range timestamps {
 			result.Samples = append(result.Samples, prompb.Sample{
-				Timestamp: toMilis(timestamps[i]),
+				Timestamp: toTimestamp(timestamps[i], filter.microseconds),
 				Value:     values[i],
 			})
 		}
+		if staleAt != nil {
+			result.Samples = insertStaleMarker(result.Samples, toTimestamp(*staleAt, filter.microseconds))
+		}
 
-		results = append(results, result)
+		if err := process(result); err != nil {
+			return err
+		}
 	}
 
-	return results, nil
+	// Errors raised while the rows are read, such as a statement timeout,
+	// end the iteration early.
+	return rows.Err()
 }
 
-func buildMetricNameSeriesIDQuery(cases []string) string {
-	return fmt.Sprintf(metricNameSeriesIDSQLFormat, strings.Join(cases, " AND "))
+func buildMetricNameSeriesIDQuery(cases matcherClauses) string {
+	return sqlTemplates.get("series\xff"+cases.shape, func() string {
+		return fmt.Sprintf(metricNameSeriesIDSQLFormat, cases)
+	})
 }
 
-func buildTimeseriesByLabelClausesQuery(filter metricTimeRangeFilter, cases []string) string {
-	return fmt.Sprintf(
-		timeseriesByMetricSQLFormat,
-		pgx.Identifier{dataSchema, filter.metric}.Sanitize(),
-		pgx.Identifier{dataSeriesSchema, filter.metric}.Sanitize(),
-		strings.Join(cases, " AND "),
-		filter.startTime,
-		filter.endTime,
-	)
+// buildTimeseriesByLabelClausesQuery returns the query of the samples of the
+// series matching cases in filter, and its arguments: values, the arguments
+// of cases, followed by the time range.
+func buildTimeseriesByLabelClausesQuery(filter metricTimeRangeFilter, cases matcherClauses, values []interface{}) (string, []interface{}) {
+	format := timeseriesByMetricSQLFormat
+	kind := "metric"
+	if filter.liveness {
+		format = timeseriesByMetricWithLivenessSQLFormat
+		kind = "metric_liveness"
+	}
+	tombstoned := filter.tombstoned[filter.metric]
+	if tombstoned {
+		kind += "_tombstones"
+	}
+	sql := sqlTemplates.get(kind+"\xff"+filter.metric+"\xff"+cases.shape, func() string {
+		sql := fmt.Sprintf(
+			format,
+			pgx.Identifier{dataSchema, filter.metric}.Sanitize(),
+			pgx.Identifier{dataSeriesSchema, filter.metric}.Sanitize(),
+			cases,
+			len(values)+1,
+			len(values)+2,
+		)
+		if tombstoned {
+			sql = withTombstoneFilter(sql, len(values)+3)
+		}
+		return sql
+	})
+	args := make([]interface{}, 0, len(values)+3)
+	args = append(args, values...)
+	args = append(args, filter.startTime, filter.endTime)
+	if tombstoned {
+		args = append(args, filter.metric)
+	}
+	return sql, args
 }
 
-func buildTimeseriesBySeriesIDQuery(filter metricTimeRangeFilter, series []SeriesID) string {
-	s := make([]string, 0, len(series))
+// buildTimeseriesBySeriesIDQuery returns the query of the samples of series
+// in filter, and its arguments.
+func buildTimeseriesBySeriesIDQuery(filter metricTimeRangeFilter, series []SeriesID) (string, []interface{}) {
+	ids := make([]int64, 0, len(series))
 	for _, sID := range series {
-		s = append(s, fmt.Sprintf("%d", sID))
+		ids = append(ids, int64(sID))
+	}
+	format := timeseriesBySeriesIDsSQLFormat
+	kind := "series_ids"
+	if filter.liveness {
+		format = timeseriesBySeriesIDsWithLivenessSQLFormat
+		kind = "series_ids_liveness"
+	}
+	tombstoned := filter.tombstoned[filter.metric]
+	if tombstoned {
+		kind += "_tombstones"
 	}
-	return fmt.Sprintf(
-		timeseriesBySeriesIDsSQLFormat,
-		pgx.Identifier{dataSchema, filter.metric}.Sanitize(),
-		pgx.Identifier{dataSeriesSchema, filter.metric}.Sanitize(),
-		strings.Join(s, ","),
-		filter.startTime,
-		filter.endTime,
-	)
+	sql := sqlTemplates.get(kind+"\xff"+filter.metric, func() string {
+		sql := fmt.Sprintf(
+			format,
+			pgx.Identifier{dataSchema, filter.metric}.Sanitize(),
+			pgx.Identifier{dataSeriesSchema, filter.metric}.Sanitize(),
+		)
+		if tombstoned {
+			sql = withTombstoneFilter(sql, 4)
+		}
+		return sql
+	})
+	if tombstoned {
+		return sql, []interface{}{ids, filter.startTime, filter.endTime, filter.metric}
+	}
+	return sql, []interface{}{ids, filter.startTime, filter.endTime}
 }
 
 func getSeriesPerMetric(rows pgx.Rows) ([]string, [][]SeriesID, error) {
@@ -295,26 +416,14 @@ func getSeriesPerMetric(rows pgx.Rows) ([]string, [][]SeriesID, error) {
 
 // anchorValue adds anchors to values in regexps since PromQL docs
 // states that "Regex-matches are fully anchored."
+// anchorValue anchors a regular expression the way Prometheus does, so that
+// it matches whole label values: "foo|bar" matches "foo" but not "foobar",
+// and "foo|" matches "foo" and the empty value, but no other value.
 func anchorValue(str string) string {
-	l := len(str)
-
-	if l == 0 {
+	if str == "" {
 		return "^$"
 	}
-
-	if str[0] == '^' && str[l-1] == '$' {
-		return str
-	}
-
-	if str[0] == '^' {
-		return fmt.Sprintf("%s$", str)
-	}
-
-	if str[l-1] == '$' {
-		return fmt.Sprintf("^%s", str)
-	}
-
-	return fmt.Sprintf("^%s$", str)
+	return fmt.Sprintf("^(?:%s)$", str)
 }
 
 func toMilis(t time.Time) int64 {
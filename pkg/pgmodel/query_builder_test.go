@@ -0,0 +1,149 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// evalClause evaluates a label matcher clause against the labels of a series
+// the way Postgres does, the regular expressions aside: the ids of the labels with the key and a value
+// passing the value operator are looked up, and the series overlaps them or,
+// for the negated clauses, does not.
+func evalClause(t *testing.T, format string, key, value string, series map[string]string) bool {
+	var op func(string) bool
+	switch {
+	case strings.Contains(format, "l.value = $"):
+		op = func(v string) bool { return v == value }
+	case strings.Contains(format, "l.value != $"):
+		op = func(v string) bool { return v != value }
+	case strings.Contains(format, "l.value ~ $"):
+		op = regexp.MustCompile(value).MatchString
+	case strings.Contains(format, "l.value !~ $"):
+		re := regexp.MustCompile(value)
+		op = func(v string) bool { return !re.MatchString(v) }
+	default:
+		t.Fatalf("unknown clause %s", format)
+	}
+	v, ok := series[key]
+	overlaps := ok && op(v)
+	if strings.HasPrefix(format, "NOT ") {
+		return !overlaps
+	}
+	return overlaps
+}
+
+func TestMatcherSemantics(t *testing.T) {
+	series := []map[string]string{
+		{},
+		{"job": "api"},
+		{"job": "db"},
+		{"job": "foobar"},
+		// An empty value stored for the label matches as its absence.
+		{"job": ""},
+	}
+	types := []prompb.LabelMatcher_Type{
+		prompb.LabelMatcher_EQ,
+		prompb.LabelMatcher_NEQ,
+		prompb.LabelMatcher_RE,
+		prompb.LabelMatcher_NRE,
+	}
+	values := []string{"", "api", ".*", ".+", "api|", "api|db", "foo", "^api$", "^api", "db$", "a.*", "(api)?"}
+
+	for _, typ := range types {
+		for _, value := range values {
+			if (typ == prompb.LabelMatcher_EQ || typ == prompb.LabelMatcher_NEQ) && strings.ContainsAny(value, "|.*+?()^$") {
+				continue
+			}
+			matcher := &prompb.LabelMatcher{Type: typ, Name: "job", Value: value}
+			expected, err := fromLabelMatchers([]*prompb.LabelMatcher{matcher})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, cases, args, err := buildSubQueries(&prompb.Query{Matchers: []*prompb.LabelMatcher{matcher}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range series {
+				wanted := expected[0].Matches(s["job"])
+				got := evalClause(t, cases.formats[0], args[0].(string), args[1].(string), s)
+				if got != wanted {
+					t.Errorf("{job%s%q} on %v: got %v wanted %v", matcherOp(typ), value, s, got, wanted)
+				}
+			}
+		}
+	}
+}
+
+func matcherOp(typ prompb.LabelMatcher_Type) string {
+	return map[prompb.LabelMatcher_Type]string{
+		prompb.LabelMatcher_EQ:  "=",
+		prompb.LabelMatcher_NEQ: "!=",
+		prompb.LabelMatcher_RE:  "=~",
+		prompb.LabelMatcher_NRE: "!~",
+	}[typ]
+}
+
+func TestMatcherClauses(t *testing.T) {
+	testCases := []struct {
+		matcher string
+		clause  string
+		value   string
+	}{
+		{matcher: `job=""`, clause: subQueryEQMatchEmpty, value: ""},
+		{matcher: `job="api"`, clause: subQueryEQ, value: "api"},
+		{matcher: `job!=""`, clause: subQueryNEQ, value: ""},
+		{matcher: `job!="api"`, clause: subQueryNEQMatchEmpty, value: "api"},
+		{matcher: `job=~""`, clause: subQueryREMatchEmpty, value: "^$"},
+		{matcher: `job=~".*"`, clause: subQueryREMatchEmpty, value: "^(?:.*)$"},
+		{matcher: `job=~".+"`, clause: subQueryRE, value: "^(?:.+)$"},
+		{matcher: `job=~"api|"`, clause: subQueryREMatchEmpty, value: "^(?:api|)$"},
+		{matcher: `job=~"api|db"`, clause: subQueryRE, value: "^(?:api|db)$"},
+		{matcher: `job!~""`, clause: subQueryNRE, value: "^$"},
+		{matcher: `job!~".*"`, clause: subQueryNRE, value: "^(?:.*)$"},
+		{matcher: `job!~".+"`, clause: subQueryNREMatchEmpty, value: "^(?:.+)$"},
+		{matcher: `job!~"api"`, clause: subQueryNREMatchEmpty, value: "^(?:api)$"},
+	}
+	for _, c := range testCases {
+		t.Run(c.matcher, func(t *testing.T) {
+			m, err := parseTestMatcher(c.matcher)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, cases, args, err := buildSubQueries(&prompb.Query{Matchers: []*prompb.LabelMatcher{m}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cases.formats[0] != c.clause {
+				t.Errorf("unexpected clause:\ngot\n%s\nwanted\n%s", cases.formats[0], c.clause)
+			}
+			if args[1] != c.value {
+				t.Errorf("unexpected value: got %q wanted %q", args[1], c.value)
+			}
+		})
+	}
+}
+
+func parseTestMatcher(s string) (*prompb.LabelMatcher, error) {
+	for _, op := range []struct {
+		op  string
+		typ labels.MatchType
+	}{{"!~", labels.MatchNotRegexp}, {"=~", labels.MatchRegexp}, {"!=", labels.MatchNotEqual}, {"=", labels.MatchEqual}} {
+		i := strings.Index(s, op.op)
+		if i < 0 {
+			continue
+		}
+		value := strings.Trim(s[i+len(op.op):], `"`)
+		return &prompb.LabelMatcher{Type: prompb.LabelMatcher_Type(op.typ), Name: s[:i], Value: value}, nil
+	}
+	return nil, fmt.Errorf("invalid matcher %s", s)
+}
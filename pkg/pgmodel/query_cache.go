@@ -0,0 +1,220 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allegro/bigcache"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	// DefaultQueryCacheTTL is the default time query results are cached for.
+	DefaultQueryCacheTTL = 10 * time.Minute
+	// DefaultQueryCacheAlignment is the default step the cached time ranges
+	// are aligned to.
+	DefaultQueryCacheAlignment = 10 * time.Minute
+	// DefaultQueryCacheRecentWindow is the default window before now that is
+	// never cached, since samples may still be written in it.
+	DefaultQueryCacheRecentWindow = 5 * time.Minute
+)
+
+// QueryCacheConfig configures the query results cache.
+type QueryCacheConfig struct {
+	// MaxSizeMB is the maximum size of the cache in megabytes, 0 meaning
+	// unlimited.
+	MaxSizeMB int
+	// TTL is how long results are cached for.
+	TTL time.Duration
+	// Alignment is the step the cached time ranges are aligned to. Queries
+	// of dashboards refreshing the same panels share cached results as long
+	// as their aligned ranges match.
+	Alignment time.Duration
+	// RecentWindow is the window before now that is always read from the
+	// database, since samples may still be written in it.
+	RecentWindow time.Duration
+}
+
+// queryCache caches the results of remote-read queries. A query is split at
+// the start of the recent window: the part before it is read from the cache,
+// over a time range aligned so that repeated queries share it, and the recent
+// part is always read from the database.
+type queryCache struct {
+	cache *bigcache.BigCache
+	cfg   QueryCacheConfig
+}
+
+func newQueryCache(cfg QueryCacheConfig) (*queryCache, error) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultQueryCacheTTL
+	}
+	if cfg.Alignment <= 0 {
+		cfg.Alignment = DefaultQueryCacheAlignment
+	}
+	if cfg.RecentWindow < 0 {
+		cfg.RecentWindow = 0
+	}
+
+	config := bigcache.DefaultConfig(cfg.TTL)
+	config.HardMaxCacheSize = cfg.MaxSizeMB
+	config.Logger = &log.CustomCacheLogger{}
+	cache, err := bigcache.NewBigCache(config)
+	if err != nil {
+		return nil, err
+	}
+	return &queryCache{cache: cache, cfg: cfg}, nil
+}
+
+// query returns the results of q, reading the part of its time range before
+// the recent window from the cache.
+func (c *queryCache) query(ctx context.Context, db Querier, q *prompb.Query, now time.Time) ([]*prompb.TimeSeries, error) {
+	alignment := c.cfg.Alignment.Milliseconds()
+	boundary := alignDown(toMilis(now.Add(-c.cfg.RecentWindow)), alignment)
+	if q.StartTimestampMs >= boundary {
+		queryCacheRequests.WithLabelValues("uncacheable").Inc()
+		return db.Query(ctx, q)
+	}
+
+	cachedStart := alignDown(q.StartTimestampMs, alignment)
+	cachedEnd := alignDown(q.EndTimestampMs, alignment) + alignment - 1
+	if cachedEnd >= boundary {
+		cachedEnd = boundary - 1
+	}
+	cached, err := c.cachedQuery(ctx, db, &prompb.Query{
+		StartTimestampMs: cachedStart,
+		EndTimestampMs:   cachedEnd,
+		Matchers:         q.Matchers,
+		Hints:            q.Hints,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := trimSeries(cached, q.StartTimestampMs, q.EndTimestampMs)
+	if q.EndTimestampMs < boundary {
+		return result, nil
+	}
+
+	recent, err := db.Query(ctx, &prompb.Query{
+		StartTimestampMs: boundary,
+		EndTimestampMs:   q.EndTimestampMs,
+		Matchers:         q.Matchers,
+		Hints:            q.Hints,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mergeSeries(result, recent), nil
+}
+
+func (c *queryCache) cachedQuery(ctx context.Context, db Querier, q *prompb.Query) ([]*prompb.TimeSeries, error) {
+	// The results of a tenant are only read back by that tenant.
+	key := TenantFrom(ctx) + "\xfd" + queryCacheKey(q)
+	if data, err := c.cache.Get(key); err == nil {
+		var res prompb.QueryResult
+		if err = res.Unmarshal(data); err == nil {
+			queryCacheRequests.WithLabelValues("hit").Inc()
+			return res.Timeseries, nil
+		}
+		queryLogger.Warn("msg", "Ignoring undecodable cached query result", "err", err)
+	}
+
+	queryCacheRequests.WithLabelValues("miss").Inc()
+	warnings := QueryWarningsFrom(ctx)
+	warned := warnings.len()
+	tts, err := db.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	// The results with warnings are not cached, since the warnings would
+	// be missing from the hits.
+	if warnings.len() > warned {
+		return tts, nil
+	}
+	res := prompb.QueryResult{Timeseries: tts}
+	data, err := res.Marshal()
+	if err == nil {
+		err = c.cache.Set(key, data)
+	}
+	if err != nil {
+		// Results larger than a cache shard cannot be cached.
+		queryLogger.Debug("msg", "Cannot cache query result", "err", err)
+	}
+	return tts, nil
+}
+
+// queryCacheKey normalizes the matchers of q, so that the same selector
+// written differently shares the cached results.
+func queryCacheKey(q *prompb.Query) string {
+	matchers := make([]string, 0, len(q.Matchers))
+	for _, m := range q.Matchers {
+		matchers = append(matchers, m.Name+"\xff"+m.Type.String()+"\xff"+m.Value)
+	}
+	sort.Strings(matchers)
+	return strconv.FormatInt(q.StartTimestampMs, 10) + "\xfe" +
+		strconv.FormatInt(q.EndTimestampMs, 10) + "\xfe" +
+		strings.Join(matchers, "\xfe")
+}
+
+func alignDown(ts, alignment int64) int64 {
+	r := ts % alignment
+	if r < 0 {
+		r += alignment
+	}
+	return ts - r
+}
+
+// trimSeries returns the samples of tts between start and end, both included,
+// dropping the series left without any.
+func trimSeries(tts []*prompb.TimeSeries, start, end int64) []*prompb.TimeSeries {
+	result := make([]*prompb.TimeSeries, 0, len(tts))
+	for _, ts := range tts {
+		from := sort.Search(len(ts.Samples), func(i int) bool { return ts.Samples[i].Timestamp >= start })
+		to := sort.Search(len(ts.Samples), func(i int) bool { return ts.Samples[i].Timestamp > end })
+		if from == to {
+			continue
+		}
+		result = append(result, &prompb.TimeSeries{
+			Labels:  ts.Labels,
+			Samples: ts.Samples[from:to],
+		})
+	}
+	return result
+}
+
+// mergeSeries appends the samples of later to the series of earlier with the
+// same labels. The samples of later must all be after the ones of earlier.
+func mergeSeries(earlier, later []*prompb.TimeSeries) []*prompb.TimeSeries {
+	index := make(map[string]*prompb.TimeSeries, len(earlier))
+	for _, ts := range earlier {
+		index[labelsKey(ts.Labels)] = ts
+	}
+	for _, ts := range later {
+		if e, ok := index[labelsKey(ts.Labels)]; ok {
+			samples := make([]prompb.Sample, 0, len(e.Samples)+len(ts.Samples))
+			e.Samples = append(append(samples, e.Samples...), ts.Samples...)
+			continue
+		}
+		earlier = append(earlier, ts)
+	}
+	return earlier
+}
+
+func labelsKey(labels []prompb.Label) string {
+	var b strings.Builder
+	for _, l := range labels {
+		b.WriteString(l.Name)
+		b.WriteByte('\xff')
+		b.WriteString(l.Value)
+		b.WriteByte('\xfe')
+	}
+	return b.String()
+}
@@ -0,0 +1,144 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// rangeQuerier returns the samples of its series within the queried range,
+// recording the ranges queried.
+type rangeQuerier struct {
+	tts     []*prompb.TimeSeries
+	queried [][2]int64
+	err     error
+}
+
+func (q *rangeQuerier) Query(_ context.Context, query *prompb.Query) ([]*prompb.TimeSeries, error) {
+	q.queried = append(q.queried, [2]int64{query.StartTimestampMs, query.EndTimestampMs})
+	if q.err != nil {
+		return nil, q.err
+	}
+	return trimSeries(q.tts, query.StartTimestampMs, query.EndTimestampMs), nil
+}
+
+func (q *rangeQuerier) QueryStreamed(context.Context, *prompb.Query, func(*prompb.TimeSeries) error) error {
+	panic("not implemented")
+}
+
+func TestQueryCache(t *testing.T) {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	const minute = int64(time.Minute / time.Millisecond)
+	now := time.Unix(0, 100*minute*int64(time.Millisecond))
+
+	series := func(name string, from, to int64) *prompb.TimeSeries {
+		ts := &prompb.TimeSeries{Labels: []prompb.Label{{Name: MetricNameLabelName, Value: name}}}
+		for t := from; t <= to; t += minute {
+			ts.Samples = append(ts.Samples, prompb.Sample{Timestamp: t, Value: float64(t / minute)})
+		}
+		return ts
+	}
+	db := &rangeQuerier{tts: []*prompb.TimeSeries{
+		series("old", 0, 50*minute),
+		series("current", 0, 100*minute),
+		series("new", 95*minute, 100*minute),
+	}}
+	cache, err := newQueryCache(QueryCacheConfig{
+		Alignment:    10 * time.Minute,
+		RecentWindow: 5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := func(start, end int64, matchers ...*prompb.LabelMatcher) []*prompb.TimeSeries {
+		res, err := cache.query(context.Background(), db, &prompb.Query{
+			StartTimestampMs: start,
+			EndTimestampMs:   end,
+			Matchers:         matchers,
+		}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	a := &prompb.LabelMatcher{Type: prompb.LabelMatcher_RE, Name: MetricNameLabelName, Value: ".*"}
+	b := &prompb.LabelMatcher{Type: prompb.LabelMatcher_NEQ, Name: "job", Value: "x"}
+
+	// The part before the recent window, aligned to 10 minutes, is cached.
+	hitsBefore := testutil.ToFloat64(queryCacheRequests.WithLabelValues("hit"))
+	res := query(42*minute+1, 100*minute, a, b)
+	expected := trimSeries(db.tts, 42*minute+1, 100*minute)
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("unexpected result:\ngot\n%v\nwanted\n%v", res, expected)
+	}
+	if !reflect.DeepEqual(db.queried, [][2]int64{{40 * minute, 90*minute - 1}, {90 * minute, 100 * minute}}) {
+		t.Errorf("unexpected ranges queried: %v", db.queried)
+	}
+
+	// A query over a range aligned the same way, with the matchers in another
+	// order, reads the old part from the cache.
+	db.queried = nil
+	res = query(45*minute, 99*minute, b, a)
+	expected = trimSeries(db.tts, 45*minute, 99*minute)
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("unexpected result:\ngot\n%v\nwanted\n%v", res, expected)
+	}
+	if !reflect.DeepEqual(db.queried, [][2]int64{{90 * minute, 99 * minute}}) {
+		t.Errorf("unexpected ranges queried: %v", db.queried)
+	}
+	if hits := testutil.ToFloat64(queryCacheRequests.WithLabelValues("hit")) - hitsBefore; hits != 1 {
+		t.Errorf("unexpected cache hits: got %v wanted 1", hits)
+	}
+
+	// Queries within the recent window are not cached.
+	db.queried = nil
+	query(91*minute, 100*minute, a)
+	query(91*minute, 100*minute, a)
+	if len(db.queried) != 2 {
+		t.Errorf("recent query was cached: %v", db.queried)
+	}
+
+	// Errors are not cached.
+	db.queried = nil
+	db.err = fmt.Errorf("some error")
+	if _, err = cache.query(context.Background(), db, &prompb.Query{StartTimestampMs: 0, EndTimestampMs: 10 * minute, Matchers: []*prompb.LabelMatcher{b}}, now); err != db.err {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	db.err = nil
+	res = query(0, 10*minute, b)
+	if len(res) != 2 || len(db.queried) != 2 {
+		t.Errorf("failed query was cached: %v", db.queried)
+	}
+
+	// The results of a tenant are not read back by another one.
+	db.queried = nil
+	tenantQuery := &prompb.Query{StartTimestampMs: 0, EndTimestampMs: 10 * minute, Matchers: []*prompb.LabelMatcher{a}}
+	for _, tenant := range []string{"alice", "bob", "alice"} {
+		if _, err = cache.query(WithTenant(context.Background(), tenant), db, tenantQuery, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(db.queried) != 2 {
+		t.Errorf("unexpected ranges queried by the tenants: %v", db.queried)
+	}
+}
+
+func TestAlignDown(t *testing.T) {
+	for _, c := range []struct{ ts, expected int64 }{{25, 20}, {20, 20}, {-5, -10}, {-10, -10}} {
+		if got := alignDown(c.ts, 10); got != c.expected {
+			t.Errorf("alignDown(%d, 10): got %d wanted %d", c.ts, got, c.expected)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const setStatementTimeoutSQL = "SELECT set_config('statement_timeout', $1, true)"
+
+var (
+	// ErrQueryLimitExceeded is returned when a read request matches more
+	// series or samples than its QueryLimits allow.
+	ErrQueryLimitExceeded = fmt.Errorf("query limit exceeded")
+	// ErrResultsTruncated is the warning of a read request whose results
+	// were cut at its QueryLimits, with Truncate set.
+	ErrResultsTruncated = fmt.Errorf("results truncated")
+)
+
+// QueryLimits bounds the resources a read request may use, so that a single
+// wide query cannot overload the database. A zero value disables the
+// corresponding limit.
+type QueryLimits struct {
+	// MaxSeries is the maximum number of series returned.
+	MaxSeries int64
+	// MaxSamples is the maximum number of samples returned.
+	MaxSamples int64
+	// StatementTimeout is the statement_timeout of every SQL query run.
+	StatementTimeout time.Duration
+	// Truncate returns the series within MaxSeries and MaxSamples, along
+	// with an ErrResultsTruncated warning, instead of failing the read.
+	Truncate bool
+}
+
+// Tighten returns l lowered to the limits set in other. Limits unset in l are
+// taken from other.
+func (l QueryLimits) Tighten(other QueryLimits) QueryLimits {
+	return QueryLimits{
+		MaxSeries:        minLimit(l.MaxSeries, other.MaxSeries),
+		MaxSamples:       minLimit(l.MaxSamples, other.MaxSamples),
+		StatementTimeout: time.Duration(minLimit(int64(l.StatementTimeout), int64(other.StatementTimeout))),
+		Truncate:         l.Truncate || other.Truncate,
+	}
+}
+
+func minLimit(a, b int64) int64 {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+type queryLimitsKey struct{}
+
+// queryBudget tracks what the queries of a read request returned so far
+// against its limits.
+type queryBudget struct {
+	limits  QueryLimits
+	series  int64
+	samples int64
+	// truncated is the warning of the limit the results were cut at.
+	truncated error
+}
+
+// WithQueryLimits returns a context enforcing limits on the read request
+// run with it. The limits cover all the queries of the request.
+func WithQueryLimits(ctx context.Context, limits QueryLimits) context.Context {
+	return context.WithValue(ctx, queryLimitsKey{}, &queryBudget{limits: limits})
+}
+
+func queryBudgetFrom(ctx context.Context) *queryBudget {
+	b, _ := ctx.Value(queryLimitsKey{}).(*queryBudget)
+	return b
+}
+
+// admit accounts for a returned series. A nil queryBudget admits everything.
+// With Truncate set, the series going over a limit is dropped instead, and
+// so are all the following ones: the returned error then wraps
+// ErrResultsTruncated.
+func (b *queryBudget) admit(ts *prompb.TimeSeries) error {
+	if b == nil {
+		return nil
+	}
+	if b.truncated != nil {
+		return b.truncated
+	}
+	b.series++
+	b.samples += int64(len(ts.Samples) + len(ts.Histograms))
+	if b.limits.MaxSeries > 0 && b.series > b.limits.MaxSeries {
+		return b.exceeded(fmt.Sprintf("more than %d series matched", b.limits.MaxSeries))
+	}
+	if b.limits.MaxSamples > 0 && b.samples > b.limits.MaxSamples {
+		return b.exceeded(fmt.Sprintf("more than %d samples returned", b.limits.MaxSamples))
+	}
+	return nil
+}
+
+func (b *queryBudget) exceeded(reason string) error {
+	if !b.limits.Truncate {
+		return fmt.Errorf("%w: %s", ErrQueryLimitExceeded, reason)
+	}
+	b.truncated = fmt.Errorf("%w: %s", ErrResultsTruncated, reason)
+	return b.truncated
+}
+
+// truncation returns the warning of the limit the results were cut at, if
+// they were.
+func (b *queryBudget) truncation() error {
+	if b == nil {
+		return nil
+	}
+	return b.truncated
+}
+
+func (b *queryBudget) statementTimeout() time.Duration {
+	if b == nil {
+		return 0
+	}
+	return b.limits.StatementTimeout
+}
+
+// batchRows closes the batch the rows are read from along with them.
+type batchRows struct {
+	pgx.Rows
+	batch pgx.BatchResults
+}
+
+func (r *batchRows) Close() {
+	r.Rows.Close()
+	_ = r.batch.Close()
+}
+
+// limitedQuery runs sql with the statement_timeout of the read request, if
+// any. The timeout is set locally to the implicit transaction of a batch, so
+// that it does not outlive the query on the pooled connection.
+func (q *pgxQuerier) limitedQuery(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	timeout := queryBudgetFrom(ctx).statementTimeout()
+	if timeout <= 0 {
+		return q.conn.Query(ctx, sql, args...)
+	}
+
+	// A statement_timeout of 0 disables it.
+	ms := timeout.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	batch := q.conn.NewBatch()
+	batch.Queue(setStatementTimeoutSQL, fmt.Sprintf("%dms", ms))
+	batch.Queue(sql, args...)
+	results, err := q.conn.SendBatch(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = results.Exec(); err != nil {
+		_ = results.Close()
+		return nil, err
+	}
+	rows, err := results.Query()
+	return &batchRows{Rows: rows, batch: results}, err
+}
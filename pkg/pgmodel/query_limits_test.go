@@ -0,0 +1,144 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestQueryLimitsTighten(t *testing.T) {
+	global := QueryLimits{MaxSeries: 100, MaxSamples: 1000}
+	got := global.Tighten(QueryLimits{MaxSeries: 10, MaxSamples: 5000, StatementTimeout: time.Second})
+	expected := QueryLimits{MaxSeries: 10, MaxSamples: 1000, StatementTimeout: time.Second}
+	if got != expected {
+		t.Errorf("unexpected limits: got %+v wanted %+v", got, expected)
+	}
+	if got = global.Tighten(QueryLimits{}); got != global {
+		t.Errorf("unexpected limits: got %+v wanted %+v", got, global)
+	}
+	if got = global.Tighten(QueryLimits{Truncate: true}); !got.Truncate {
+		t.Errorf("truncation not requested: %+v", got)
+	}
+}
+
+func TestQueryLimits(t *testing.T) {
+	series := rowResults{
+		{[]string{MetricNameLabelName}, []string{"foo"}, []time.Time{time.Unix(1, 0), time.Unix(2, 0)}, []float64{1, 2}},
+		{[]string{MetricNameLabelName}, []string{"foo"}, []time.Time{time.Unix(1, 0)}, []float64{3}},
+	}
+	query := &prompb.Query{
+		Matchers: []*prompb.LabelMatcher{
+			{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "foo"},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		limits   QueryLimits
+		queryErr map[int]error
+		series   int
+		err      error
+		warning  error
+	}{
+		{
+			name:   "no limits",
+			series: 2,
+		},
+		{
+			name:   "series limit",
+			limits: QueryLimits{MaxSeries: 1},
+			series: 1,
+			err:    ErrQueryLimitExceeded,
+		},
+		{
+			name:   "samples limit",
+			limits: QueryLimits{MaxSamples: 2},
+			series: 1,
+			err:    ErrQueryLimitExceeded,
+		},
+		{
+			name:    "series limit truncating",
+			limits:  QueryLimits{MaxSeries: 1, Truncate: true},
+			series:  1,
+			warning: ErrResultsTruncated,
+		},
+		{
+			name:    "samples limit truncating",
+			limits:  QueryLimits{MaxSamples: 2, Truncate: true},
+			series:  1,
+			warning: ErrResultsTruncated,
+		},
+		{
+			name:   "within limits",
+			limits: QueryLimits{MaxSeries: 2, MaxSamples: 3},
+			series: 2,
+		},
+		{
+			name:   "statement timeout",
+			limits: QueryLimits{StatementTimeout: time.Second},
+			series: 2,
+		},
+		{
+			name:     "statement timeout reached",
+			limits:   QueryLimits{StatementTimeout: time.Second},
+			queryErr: map[int]error{0: &pgconn.PgError{Code: pgerrcode.QueryCanceled}},
+			err:      ErrQueryLimitExceeded,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &mockPGXConn{
+				QueryResults: []rowResults{series},
+				QueryErr:     c.queryErr,
+			}
+			querier := pgxQuerier{conn: mock, metricTableNames: &mockMetricCache{metricCache: map[string]string{"foo": "foo"}}}
+
+			ctx, warnings := WithQueryWarnings(WithQueryLimits(context.Background(), c.limits))
+			returned := 0
+			err := querier.QueryStreamed(ctx, query, func(*prompb.TimeSeries) error {
+				returned++
+				return nil
+			})
+
+			if !errors.Is(err, c.err) {
+				t.Errorf("unexpected error: got %v wanted %v", err, c.err)
+			}
+			if returned != c.series {
+				t.Errorf("unexpected number of series: got %d wanted %d", returned, c.series)
+			}
+			if got := warnings.Warnings(); c.warning == nil && len(got) != 0 || c.warning != nil && (len(got) != 1 || !errors.Is(got[0], c.warning)) {
+				t.Errorf("unexpected warnings: got %v wanted %v", got, c.warning)
+			}
+			if c.warning != nil {
+				// The following queries of the read return nothing.
+				queries := len(mock.QuerySQLs)
+				if err = querier.QueryStreamed(ctx, query, func(*prompb.TimeSeries) error {
+					t.Error("series returned after the results were truncated")
+					return nil
+				}); err != nil || len(mock.QuerySQLs) != queries {
+					t.Errorf("query run after the results were truncated: %v", err)
+				}
+			}
+
+			if c.limits.StatementTimeout == 0 {
+				return
+			}
+			if len(mock.Batch) != 1 || len(mock.Batch[0].items) != 2 {
+				t.Fatalf("query not run in a batch setting the statement timeout")
+			}
+			item := mock.Batch[0].items[0]
+			if item.query != setStatementTimeoutSQL || item.arguments[0] != "1000ms" {
+				t.Errorf("unexpected statement timeout: %s %v", item.query, item.arguments)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// QueryLogConfig configures the logging of the queries of remote reads.
+type QueryLogConfig struct {
+	// Enabled logs every query at info level.
+	Enabled bool
+	// SlowThreshold logs the queries taking longer at warn level, whether
+	// the query log is enabled or not. 0 disables it.
+	SlowThreshold time.Duration
+}
+
+// queryLog logs the queries of remote reads, along with what they returned
+// and how long they took. A nil queryLog logs nothing.
+type queryLog struct {
+	cfg QueryLogConfig
+}
+
+func newQueryLog(cfg QueryLogConfig) *queryLog {
+	if !cfg.Enabled && cfg.SlowThreshold <= 0 {
+		return nil
+	}
+	return &queryLog{cfg: cfg}
+}
+
+// queryStats counts what a query returned.
+type queryStats struct {
+	series  int
+	samples int
+}
+
+func (s *queryStats) add(ts *prompb.TimeSeries) {
+	s.series++
+	s.samples += len(ts.Samples) + len(ts.Histograms)
+}
+
+func (l *queryLog) log(q *prompb.Query, stats queryStats, duration time.Duration, err error) {
+	if l == nil {
+		return
+	}
+	slow := l.cfg.SlowThreshold > 0 && duration >= l.cfg.SlowThreshold
+	if !slow && !l.cfg.Enabled {
+		return
+	}
+
+	keyvals := []interface{}{
+		"matchers", formatMatchers(q.Matchers),
+		"start", time.Unix(0, q.StartTimestampMs*int64(time.Millisecond)).UTC(),
+		"end", time.Unix(0, q.EndTimestampMs*int64(time.Millisecond)).UTC(),
+		"series", stats.series,
+		"samples", stats.samples,
+		"duration", duration,
+	}
+	if err != nil {
+		keyvals = append(keyvals, "err", err)
+	}
+	if slow {
+		queryLogger.Warn(append([]interface{}{"msg", "Slow query"}, keyvals...)...)
+		return
+	}
+	queryLogger.Info(append([]interface{}{"msg", "Query"}, keyvals...)...)
+}
+
+// formatMatchers formats matchers as a PromQL series selector.
+func formatMatchers(matchers []*prompb.LabelMatcher) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, m := range matchers {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(m.Name)
+		switch m.Type {
+		case prompb.LabelMatcher_EQ:
+			b.WriteString("=")
+		case prompb.LabelMatcher_NEQ:
+			b.WriteString("!=")
+		case prompb.LabelMatcher_RE:
+			b.WriteString("=~")
+		case prompb.LabelMatcher_NRE:
+			b.WriteString("!~")
+		}
+		b.WriteString(strconv.Quote(m.Value))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
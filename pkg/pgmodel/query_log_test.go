@@ -0,0 +1,40 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestFormatMatchers(t *testing.T) {
+	matchers := []*prompb.LabelMatcher{
+		{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "up"},
+		{Type: prompb.LabelMatcher_NEQ, Name: "job", Value: "a"},
+		{Type: prompb.LabelMatcher_RE, Name: "instance", Value: `host-\d+`},
+		{Type: prompb.LabelMatcher_NRE, Name: "env", Value: "dev|test"},
+	}
+	expected := `{__name__="up",job!="a",instance=~"host-\\d+",env!~"dev|test"}`
+	if got := formatMatchers(matchers); got != expected {
+		t.Errorf("unexpected selector: got %s wanted %s", got, expected)
+	}
+	if got := formatMatchers(nil); got != "{}" {
+		t.Errorf("unexpected empty selector: %s", got)
+	}
+}
+
+func TestNewQueryLog(t *testing.T) {
+	if l := newQueryLog(QueryLogConfig{}); l != nil {
+		t.Error("query log created while disabled")
+	}
+	if l := newQueryLog(QueryLogConfig{SlowThreshold: time.Second}); l == nil {
+		t.Error("slow query log not created")
+	}
+
+	// A nil query log logs nothing.
+	var l *queryLog
+	l.log(&prompb.Query{}, queryStats{}, time.Hour, nil)
+}
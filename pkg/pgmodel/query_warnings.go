@@ -0,0 +1,85 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// MissingMetricError reports a metric without a table, because none of its
+// samples were ever written. Reads of such a metric return no series along
+// with the error as a warning.
+type MissingMetricError struct {
+	Metric string
+}
+
+func (e *MissingMetricError) Error() string {
+	return fmt.Sprintf("metric %q does not exist", e.Metric)
+}
+
+// Is makes a MissingMetricError match errMissingTableName.
+func (e *MissingMetricError) Is(target error) bool {
+	return target == errMissingTableName
+}
+
+type queryWarningsKey struct{}
+
+// QueryWarnings collects the problems which did not fail the queries of a
+// read but made their results empty or partial, such as MissingMetricError,
+// so that the API can report them along with the results.
+type QueryWarnings struct {
+	lock     sync.Mutex
+	warnings []error
+	seen     map[string]struct{}
+}
+
+// WithQueryWarnings returns a context collecting the warnings of the reads
+// made with it.
+func WithQueryWarnings(ctx context.Context) (context.Context, *QueryWarnings) {
+	w := &QueryWarnings{seen: make(map[string]struct{})}
+	return context.WithValue(ctx, queryWarningsKey{}, w), w
+}
+
+// QueryWarningsFrom returns the warnings collector of the context, or nil.
+func QueryWarningsFrom(ctx context.Context) *QueryWarnings {
+	w, _ := ctx.Value(queryWarningsKey{}).(*QueryWarnings)
+	return w
+}
+
+// Add records a warning, once per message. A nil QueryWarnings ignores it.
+func (w *QueryWarnings) Add(err error) {
+	if w == nil {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if _, ok := w.seen[err.Error()]; ok {
+		return
+	}
+	w.seen[err.Error()] = struct{}{}
+	w.warnings = append(w.warnings, err)
+}
+
+// len returns the number of warnings recorded.
+func (w *QueryWarnings) len() int {
+	if w == nil {
+		return 0
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return len(w.warnings)
+}
+
+// Warnings returns the warnings recorded, in order.
+func (w *QueryWarnings) Warnings() []error {
+	if w == nil {
+		return nil
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return append([]error(nil), w.warnings...)
+}
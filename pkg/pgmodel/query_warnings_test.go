@@ -0,0 +1,101 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/allegro/bigcache"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestQueryWarningsMissingMetric(t *testing.T) {
+	mock := &mockPGXConn{QueryNoRows: true}
+	querier := pgxQuerier{conn: mock, metricTableNames: &mockMetricCache{metricCache: map[string]string{}}}
+	query := &prompb.Query{
+		Matchers: []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "missing"}},
+	}
+
+	ctx, warnings := WithQueryWarnings(context.Background())
+	for i := 0; i < 2; i++ {
+		tts, err := querier.Query(ctx, query)
+		if err != nil || len(tts) != 0 {
+			t.Fatalf("unexpected result for a missing metric: %v, %v", tts, err)
+		}
+	}
+	got := warnings.Warnings()
+	if len(got) != 1 {
+		t.Fatalf("unexpected warnings: %v", got)
+	}
+	var missing *MissingMetricError
+	if !errors.As(got[0], &missing) || missing.Metric != "missing" || !errors.Is(got[0], errMissingTableName) {
+		t.Errorf("unexpected warning: %v", got[0])
+	}
+
+	// Reads without a collector still succeed.
+	if _, err := querier.Query(context.Background(), query); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMissingMetricCached(t *testing.T) {
+	metrics, err := bigcache.NewBigCache(DefaultCacheConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := &mockPGXConn{QueryNoRows: true}
+	querier := pgxQuerier{conn: mock, metricTableNames: &MetricNameCache{Metrics: metrics, MissingTTL: time.Minute}}
+	for i := 0; i < 3; i++ {
+		_, err = querier.getMetricTableName(context.Background(), "missing")
+		if !errors.Is(err, errMissingTableName) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(mock.QuerySQLs) != 1 {
+		t.Errorf("missing metric looked up %d times", len(mock.QuerySQLs))
+	}
+}
+
+// warningQuerier returns no series and a warning for every query.
+type warningQuerier struct {
+	queries int
+}
+
+func (q *warningQuerier) Query(ctx context.Context, _ *prompb.Query) ([]*prompb.TimeSeries, error) {
+	q.queries++
+	QueryWarningsFrom(ctx).Add(&MissingMetricError{Metric: "missing"})
+	return nil, nil
+}
+
+func (q *warningQuerier) QueryStreamed(context.Context, *prompb.Query, func(*prompb.TimeSeries) error) error {
+	panic("not implemented")
+}
+
+func TestQueryCacheSkipsWarnings(t *testing.T) {
+	cache, err := newQueryCache(QueryCacheConfig{Alignment: time.Minute, RecentWindow: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &warningQuerier{}
+	query := &prompb.Query{
+		StartTimestampMs: 0,
+		EndTimestampMs:   1000,
+		Matchers:         []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "missing"}},
+	}
+	for i := 0; i < 2; i++ {
+		ctx, warnings := WithQueryWarnings(context.Background())
+		if _, err = cache.query(ctx, db, query, time.Unix(3600, 0)); err != nil {
+			t.Fatal(err)
+		}
+		if len(warnings.Warnings()) != 1 {
+			t.Errorf("warning missing from query %d", i)
+		}
+	}
+	if db.queries != 2 {
+		t.Errorf("result with warnings cached: %d queries", db.queries)
+	}
+}
@@ -0,0 +1,255 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	// replicaLagSQL returns whether the server is a standby, and how far
+	// behind the primary it is replaying. A standby having replayed all the
+	// WAL it received has no lag, even if the primary is idle.
+	replicaLagSQL = `SELECT pg_is_in_recovery(), CASE
+		WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END`
+
+	// DefaultReplicaMaxLag is the replication lag above which a replica
+	// stops being read.
+	DefaultReplicaMaxLag = 30 * time.Second
+	// DefaultReplicaCheckInterval is how often the replicas are checked.
+	DefaultReplicaCheckInterval = 5 * time.Second
+
+	primaryReadTarget = "primary"
+)
+
+// ReadReplicaConfig configures the routing of the reads to the replicas.
+type ReadReplicaConfig struct {
+	// MaxLag is the replication lag above which a replica is not read.
+	MaxLag time.Duration
+	// CheckInterval is how often the health and lag of the replicas are
+	// checked.
+	CheckInterval time.Duration
+}
+
+// ReadReplica is a read replica of the database.
+type ReadReplica struct {
+	// Name identifies the replica in the logs and metrics.
+	Name string
+	Pool *pgxpool.Pool
+}
+
+// readReplica is a replica with its last known health.
+type readReplica struct {
+	name    string
+	conn    pgxConn
+	healthy int32
+}
+
+func (r *readReplica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+// replicaConn is a pgxConn balancing the statements across the healthy
+// replicas, in turn, and falling back to the primary when none is healthy.
+// It is only used by the reads; the writes keep using the primary.
+type replicaConn struct {
+	primary  pgxConn
+	replicas []*readReplica
+	cfg      ReadReplicaConfig
+	next     uint32
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// ReadReplicas routes the reads of a DBReader to its replicas until closed.
+type ReadReplicas struct {
+	conn *replicaConn
+}
+
+// EnableReadReplicas balances the reads across the replicas whose
+// replication lag is below cfg.MaxLag, the reads running on the primary
+// while none is. A read across several metrics pinned to a snapshot, or run
+// as the role of its tenant, runs entirely on the replica picked for it. The
+// replicas are checked in the background until the returned ReadReplicas is
+// closed.
+func (r *DBReader) EnableReadReplicas(replicas []ReadReplica, cfg ReadReplicaConfig) *ReadReplicas {
+	q, ok := r.db.(*pgxQuerier)
+	if !ok || len(replicas) == 0 {
+		return nil
+	}
+	var simple map[string]bool
+	if c, ok := q.conn.(*pgxConnImpl); ok {
+		simple = c.simple
+	}
+	conns := make([]*readReplica, len(replicas))
+	for i, replica := range replicas {
+		conns[i] = &readReplica{name: replica.Name, conn: &pgxConnImpl{conn: replica.Pool, simple: simple}}
+	}
+	conn := newReplicaConn(q.conn, conns, cfg)
+	q.conn = conn
+	conn.start()
+	return &ReadReplicas{conn: conn}
+}
+
+// Close stops checking the replicas. Their pools are closed by their owner.
+func (r *ReadReplicas) Close() {
+	if r != nil {
+		r.conn.stopChecks()
+	}
+}
+
+func newReplicaConn(primary pgxConn, replicas []*readReplica, cfg ReadReplicaConfig) *replicaConn {
+	if cfg.MaxLag <= 0 {
+		cfg.MaxLag = DefaultReplicaMaxLag
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = DefaultReplicaCheckInterval
+	}
+	return &replicaConn{primary: primary, replicas: replicas, cfg: cfg, stop: make(chan struct{})}
+}
+
+// start checks the replicas once, so that the first reads already go to
+// them, and then in the background.
+func (c *replicaConn) start() {
+	c.checkReplicas()
+	c.done.Add(1)
+	go func() {
+		defer c.done.Done()
+		ticker := time.NewTicker(c.cfg.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				c.checkReplicas()
+			}
+		}
+	}()
+}
+
+func (c *replicaConn) stopChecks() {
+	close(c.stop)
+	c.done.Wait()
+}
+
+func (c *replicaConn) checkReplicas() {
+	for _, r := range c.replicas {
+		c.checkReplica(r)
+	}
+}
+
+// checkReplica marks a replica healthy if it answers, is a standby, and
+// lags behind the primary by less than MaxLag.
+func (c *replicaConn) checkReplica(r *readReplica) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.CheckInterval)
+	defer cancel()
+
+	healthy := false
+	var (
+		standby bool
+		lag     float64
+	)
+	rows, err := r.conn.Query(ctx, replicaLagSQL)
+	if err == nil {
+		if rows.Next() {
+			err = rows.Scan(&standby, &lag)
+		} else {
+			err = rows.Err()
+		}
+		rows.Close()
+	}
+	switch {
+	case err != nil:
+		queryLogger.Debug("msg", "Read replica check failed", "replica", r.name, "err", err)
+	case !standby:
+		queryLogger.Debug("msg", "Read replica is not a standby", "replica", r.name)
+	default:
+		readReplicaLag.WithLabelValues(r.name).Set(lag)
+		healthy = time.Duration(lag*float64(time.Second)) <= c.cfg.MaxLag
+	}
+
+	var value int32
+	if healthy {
+		value = 1
+	}
+	if atomic.SwapInt32(&r.healthy, value) != value {
+		if healthy {
+			queryLogger.Info("msg", "Reading from replica", "replica", r.name)
+		} else {
+			queryLogger.Warn("msg", "Not reading from replica", "replica", r.name, "lag_seconds", lag, "err", err)
+		}
+	}
+	readReplicaHealthy.WithLabelValues(r.name).Set(float64(value))
+}
+
+// pick returns the next healthy replica, in turn, or the primary.
+func (c *replicaConn) pick() pgxConn {
+	n := uint32(len(c.replicas))
+	start := atomic.AddUint32(&c.next, 1)
+	for i := uint32(0); i < n; i++ {
+		r := c.replicas[(start+i)%n]
+		if r.isHealthy() {
+			readReplicaStatements.WithLabelValues(r.name).Inc()
+			return r.conn
+		}
+	}
+	readReplicaStatements.WithLabelValues(primaryReadTarget).Inc()
+	return c.primary
+}
+
+// Close closes the primary and the replicas.
+func (c *replicaConn) Close() {
+	c.primary.Close()
+	for _, r := range c.replicas {
+		r.conn.Close()
+	}
+}
+
+func (c *replicaConn) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	return c.pick().Exec(ctx, sql, arguments...)
+}
+
+func (c *replicaConn) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return c.pick().Query(ctx, sql, args...)
+}
+
+// CopyFrom writes, and so always runs on the primary.
+func (c *replicaConn) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return c.primary.CopyFrom(ctx, tableName, columnNames, rowSrc)
+}
+
+func (c *replicaConn) CopyFromRows(rows [][]interface{}) pgx.CopyFromSource {
+	return pgx.CopyFromRows(rows)
+}
+
+func (c *replicaConn) NewBatch() pgxBatch {
+	return &pgx.Batch{}
+}
+
+func (c *replicaConn) SendBatch(ctx context.Context, b pgxBatch) (pgx.BatchResults, error) {
+	return c.pick().SendBatch(ctx, b)
+}
+
+// beginReadTx opens the transaction on the replica picked for it, so that
+// all its statements see the same snapshot of the same server.
+func (c *replicaConn) beginReadTx(ctx context.Context, iso pgx.TxIsoLevel) (pgxConn, func(), error) {
+	conn := c.pick()
+	b, ok := conn.(readTxBeginner)
+	if !ok {
+		return conn, func() {}, nil
+	}
+	return b.beginReadTx(ctx, iso)
+}
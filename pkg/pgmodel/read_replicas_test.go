@@ -0,0 +1,86 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+func TestReadReplicaRouting(t *testing.T) {
+	log.Init("debug")
+	var (
+		primary    = &mockPGXConn{}
+		healthy    = &mockPGXConn{QueryResults: []rowResults{{{true, float64(1)}}}}
+		lagging    = &mockPGXConn{QueryResults: []rowResults{{{true, float64(60)}}}}
+		standalone = &mockPGXConn{QueryResults: []rowResults{{{false, float64(0)}}}}
+		down       = &mockPGXConn{QueryErr: map[int]error{0: fmt.Errorf("connection refused")}}
+	)
+	replicas := []*readReplica{
+		{name: "healthy", conn: healthy},
+		{name: "lagging", conn: lagging},
+		{name: "standalone", conn: standalone},
+		{name: "down", conn: down},
+	}
+	c := newReplicaConn(primary, replicas, ReadReplicaConfig{MaxLag: 30 * time.Second})
+	c.checkReplicas()
+
+	for i, r := range replicas {
+		if r.isHealthy() != (i == 0) {
+			t.Errorf("replica %s: unexpected health %v", r.name, r.isHealthy())
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if conn := c.pick(); conn != healthy {
+			t.Fatalf("read %d: expected the healthy replica, got %v", i, conn)
+		}
+	}
+
+	if _, err := c.CopyFrom(context.Background(), nil, nil, c.CopyFromRows(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if len(primary.CopyFromTableName) != 1 {
+		t.Errorf("expected the copy to run on the primary")
+	}
+
+	q := &pgxQuerier{conn: c}
+	if err := q.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+	if len(primary.QuerySQLs) != 1 || len(healthy.QuerySQLs) != 1 {
+		t.Errorf("expected the health check to run on the primary, got %v", primary.QuerySQLs)
+	}
+
+	// The healthy replica now fails its check, the reads falling back to the
+	// primary.
+	healthy.QueryErr = map[int]error{1: fmt.Errorf("connection refused")}
+	c.checkReplicas()
+	if conn := c.pick(); conn != primary {
+		t.Errorf("expected the primary without a healthy replica, got %v", conn)
+	}
+}
+
+func TestReadReplicaLagLimit(t *testing.T) {
+	log.Init("debug")
+	replica := &readReplica{name: "replica", conn: &mockPGXConn{QueryResults: []rowResults{{{true, float64(10)}}, {{true, float64(10)}}}}}
+	c := newReplicaConn(&mockPGXConn{}, []*readReplica{replica}, ReadReplicaConfig{MaxLag: 5 * time.Second})
+	if c.cfg.CheckInterval != DefaultReplicaCheckInterval {
+		t.Errorf("unexpected check interval %v", c.cfg.CheckInterval)
+	}
+
+	c.checkReplica(replica)
+	if replica.isHealthy() {
+		t.Error("expected a replica lagging more than the maximum lag not to be read")
+	}
+	c.cfg.MaxLag = 10 * time.Second
+	c.checkReplica(replica)
+	if !replica.isHealthy() {
+		t.Error("expected a replica lagging the maximum lag to be read")
+	}
+}
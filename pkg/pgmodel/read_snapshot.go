@@ -0,0 +1,131 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type snapshotReadsKey struct{}
+
+// WithSnapshotReads returns a context whose reads are pinned to a single
+// snapshot of the database, or not, whatever EnableSnapshotReads set.
+func WithSnapshotReads(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, snapshotReadsKey{}, enabled)
+}
+
+func snapshotReadsFrom(ctx context.Context, fallback bool) bool {
+	if enabled, ok := ctx.Value(snapshotReadsKey{}).(bool); ok {
+		return enabled
+	}
+	return fallback
+}
+
+// EnableSnapshotReads pins the SELECTs of a query across several metrics
+// to a single snapshot by default, so that the series and samples they read
+// are consistent with each other even while samples are being written.
+func (r *DBReader) EnableSnapshotReads() {
+	if q, ok := r.db.(*pgxQuerier); ok {
+		q.snapshotReads = true
+	}
+}
+
+// readTxBeginner opens the transactions the SELECTs of a query run in.
+type readTxBeginner interface {
+	// beginReadTx returns a connection running its statements in a read-only
+	// transaction of the isolation level, until end is called.
+	beginReadTx(ctx context.Context, iso pgx.TxIsoLevel) (conn pgxConn, end func(), err error)
+}
+
+// beginReadTx opens a read-only transaction. In REPEATABLE READ, every
+// statement sees the snapshot taken by the first one.
+func (p *pgxConnImpl) beginReadTx(ctx context.Context, iso pgx.TxIsoLevel) (pgxConn, func(), error) {
+	tx, err := p.getConn().BeginTx(ctx, pgx.TxOptions{IsoLevel: iso, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, nil, err
+	}
+	// The transaction only reads, so it is rolled back rather than
+	// committed. The context may be canceled already.
+	end := func() { _ = tx.Rollback(context.Background()) }
+	return &txConn{tx: tx}, end, nil
+}
+
+// txConn runs the statements of a pgxConn in a transaction.
+type txConn struct {
+	tx pgx.Tx
+}
+
+func (t *txConn) Close() {}
+
+func (t *txConn) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	return t.tx.Exec(ctx, sql, arguments...)
+}
+
+func (t *txConn) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return t.tx.Query(ctx, sql, args...)
+}
+
+func (t *txConn) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return t.tx.CopyFrom(ctx, tableName, columnNames, rowSrc)
+}
+
+func (t *txConn) CopyFromRows(rows [][]interface{}) pgx.CopyFromSource {
+	return pgx.CopyFromRows(rows)
+}
+
+func (t *txConn) NewBatch() pgxBatch {
+	return &pgx.Batch{}
+}
+
+func (t *txConn) SendBatch(ctx context.Context, b pgxBatch) (pgx.BatchResults, error) {
+	return t.tx.SendBatch(ctx, b.(*pgx.Batch)), nil
+}
+
+// beginRead returns the querier running the SELECTs of a query, along with
+// the function ending them. The SELECTs of a query across several metrics
+// are pinned to a single snapshot if ctx asks for it, and with tenant roles,
+// the SELECTs of a tenant run as its role.
+func (q *pgxQuerier) beginRead(ctx context.Context, crossMetric bool) (*pgxQuerier, func(), error) {
+	snapshot := crossMetric && snapshotReadsFrom(ctx, q.snapshotReads)
+	tenant := TenantFrom(ctx)
+	if q.tenantRoles && tenant == "" {
+		return nil, nil, ErrTenantRequired
+	}
+	if !snapshot && !q.tenantRoles {
+		return q, func() {}, nil
+	}
+
+	b, ok := q.conn.(readTxBeginner)
+	if !ok {
+		if q.tenantRoles {
+			return nil, nil, fmt.Errorf("%w: the connection cannot switch roles", ErrTenantRequired)
+		}
+		return q, func() {}, nil
+	}
+	iso := pgx.ReadCommitted
+	if snapshot {
+		iso = pgx.RepeatableRead
+	}
+	conn, end, err := b.beginReadTx(ctx, iso)
+	if err != nil {
+		return nil, nil, err
+	}
+	if q.tenantRoles {
+		if _, err = conn.Exec(ctx, setTenantSQL, q.tenantRolePrefix+tenant, tenant); err != nil {
+			end()
+			return nil, nil, fmt.Errorf("switching to the role of tenant %q: %w", tenant, err)
+		}
+	}
+	if snapshot {
+		snapshotReads.Inc()
+	}
+	pinned := *q
+	pinned.conn = conn
+	return &pinned, end, nil
+}
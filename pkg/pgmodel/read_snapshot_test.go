@@ -0,0 +1,93 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// readTxPGXConn records the read transactions begun, and counts those ended.
+type readTxPGXConn struct {
+	*mockPGXConn
+	begun []pgx.TxIsoLevel
+	ended int
+}
+
+func (c *readTxPGXConn) beginReadTx(_ context.Context, iso pgx.TxIsoLevel) (pgxConn, func(), error) {
+	c.begun = append(c.begun, iso)
+	return c.mockPGXConn, func() { c.ended++ }, nil
+}
+
+func TestSnapshotReads(t *testing.T) {
+	crossMetric := &prompb.Query{
+		Matchers: []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: "job", Value: "api"}},
+	}
+	singleMetric := &prompb.Query{
+		Matchers: []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "up"}},
+	}
+	testCases := []struct {
+		name      string
+		query     *prompb.Query
+		enabled   bool
+		ctx       context.Context
+		snapshots int
+	}{
+		{
+			name:      "cross-metric query",
+			query:     crossMetric,
+			enabled:   true,
+			ctx:       context.Background(),
+			snapshots: 1,
+		},
+		{
+			name:    "single metric query",
+			query:   singleMetric,
+			enabled: true,
+			ctx:     context.Background(),
+		},
+		{
+			name:  "disabled",
+			query: crossMetric,
+			ctx:   context.Background(),
+		},
+		{
+			name:      "enabled by the query",
+			query:     crossMetric,
+			ctx:       WithSnapshotReads(context.Background(), true),
+			snapshots: 1,
+		},
+		{
+			name:    "disabled by the query",
+			query:   crossMetric,
+			enabled: true,
+			ctx:     WithSnapshotReads(context.Background(), false),
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			conn := &readTxPGXConn{mockPGXConn: &mockPGXConn{QueryNoRows: true}}
+			querier := pgxQuerier{
+				conn:             conn,
+				metricTableNames: &mockMetricCache{metricCache: map[string]string{"up": "up"}},
+				snapshotReads:    c.enabled,
+			}
+			if _, err := querier.Query(c.ctx, c.query); err != nil {
+				t.Fatal(err)
+			}
+			if len(conn.begun) != c.snapshots || conn.ended != c.snapshots {
+				t.Errorf("unexpected snapshots: %d begun and %d ended, wanted %d", len(conn.begun), conn.ended, c.snapshots)
+			}
+			for _, iso := range conn.begun {
+				if iso != pgx.RepeatableRead {
+					t.Errorf("unexpected isolation level: %s", iso)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	// RedactionDrop removes the label from the series.
+	RedactionDrop = "drop"
+	// RedactionMask replaces the value of the label.
+	RedactionMask = "mask"
+
+	defaultRedactionReplacement = "redacted"
+)
+
+// RedactionRule strips or masks the values of a label, such as a user ID, in
+// the series read by the callers that are not admins.
+type RedactionRule struct {
+	// Label is the name of the redacted label.
+	Label string `yaml:"label"`
+	// Action is RedactionDrop or RedactionMask.
+	Action string `yaml:"action"`
+	// Regex restricts the rule to the values it fully matches. All the
+	// values are redacted without it.
+	Regex string `yaml:"regex,omitempty"`
+	// Replacement is the value of the masked labels, "redacted" by default.
+	Replacement string `yaml:"replacement,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// LoadRedactionRules reads a YAML file listing redaction rules.
+func LoadRedactionRules(path string) ([]RedactionRule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading redaction rules: %w", err)
+	}
+	var rules []RedactionRule
+	if err = yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parsing redaction rules %s: %w", path, err)
+	}
+	for i := range rules {
+		if err = rules[i].validate(); err != nil {
+			return nil, fmt.Errorf("parsing redaction rules %s: rule %d: %w", path, i, err)
+		}
+	}
+	return rules, nil
+}
+
+func (r *RedactionRule) validate() error {
+	if !model.LabelName(r.Label).IsValid() || r.Label == MetricNameLabelName {
+		return fmt.Errorf("invalid label %q", r.Label)
+	}
+	switch r.Action {
+	case RedactionDrop:
+	case RedactionMask:
+		if r.Replacement == "" {
+			r.Replacement = defaultRedactionReplacement
+		}
+	default:
+		return fmt.Errorf("unknown action %q, expected %s or %s", r.Action, RedactionDrop, RedactionMask)
+	}
+	if r.Regex != "" {
+		re, err := regexp.Compile("^(?:" + r.Regex + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid regex %q: %w", r.Regex, err)
+		}
+		r.regex = re
+	}
+	return nil
+}
+
+func (r *RedactionRule) matches(l prompb.Label) bool {
+	return l.Name == r.Label && (r.regex == nil || r.regex.MatchString(l.Value))
+}
+
+type adminKey struct{}
+
+// WithAdmin returns a context whose reads are not redacted.
+func WithAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminKey{}, true)
+}
+
+// IsAdmin reports whether the reads of ctx are exempt from redaction.
+func IsAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminKey{}).(bool)
+	return admin
+}
+
+// redactLabels applies the rules to the labels of ts. The labels are
+// replaced rather than modified in place, since they may be shared with the
+// query cache.
+func redactLabels(ts *prompb.TimeSeries, rules []RedactionRule) {
+	if len(rules) == 0 {
+		return
+	}
+	var redacted []prompb.Label
+	for i, l := range ts.Labels {
+		rule := matchingRedactionRule(l, rules)
+		if rule == nil {
+			if redacted != nil {
+				redacted = append(redacted, l)
+			}
+			continue
+		}
+		if redacted == nil {
+			redacted = make([]prompb.Label, i, len(ts.Labels))
+			copy(redacted, ts.Labels[:i])
+		}
+		redactedLabels.WithLabelValues(rule.Action).Inc()
+		if rule.Action == RedactionMask {
+			redacted = append(redacted, prompb.Label{Name: l.Name, Value: rule.Replacement})
+		}
+	}
+	if redacted != nil {
+		ts.Labels = redacted
+	}
+}
+
+func matchingRedactionRule(l prompb.Label, rules []RedactionRule) *RedactionRule {
+	for i := range rules {
+		if rules[i].matches(l) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// EnableRedaction applies the rules to the series read, unless the context
+// of the read was marked with WithAdmin.
+func (r *DBReader) EnableRedaction(rules []RedactionRule) {
+	r.redactionRules = rules
+}
+
+// redact applies the redaction rules to ts when the read is not an admin's.
+func (r *DBReader) redact(ctx context.Context, ts *prompb.TimeSeries) {
+	if len(r.redactionRules) > 0 && !IsAdmin(ctx) {
+		redactLabels(ts, r.redactionRules)
+	}
+}
@@ -0,0 +1,143 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const testRedactionRules = `
+- label: user_id
+  action: drop
+- label: email
+  action: mask
+  regex: '.*@example\.com'
+- label: session
+  action: mask
+  replacement: '***'
+`
+
+func writeRedactionRules(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "redaction")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "rules.yaml")
+	if err = ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRedactionRules(t *testing.T) {
+	rules, err := LoadRedactionRules(writeRedactionRules(t, testRedactionRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 || rules[1].Replacement != defaultRedactionReplacement || rules[2].Replacement != "***" {
+		t.Errorf("unexpected rules: %+v", rules)
+	}
+
+	invalid := map[string]string{
+		"metric name":    "- label: __name__\n  action: drop\n",
+		"invalid label":  "- label: user-id\n  action: drop\n",
+		"unknown action": "- label: user_id\n  action: hash\n",
+		"invalid regex":  "- label: user_id\n  action: drop\n  regex: '('\n",
+		"not a list":     "label: user_id\n",
+	}
+	for name, content := range invalid {
+		if _, err := LoadRedactionRules(writeRedactionRules(t, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := LoadRedactionRules(filepath.Join(os.TempDir(), "missing-redaction-rules.yaml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestReadRedaction(t *testing.T) {
+	rules, err := LoadRedactionRules(writeRedactionRules(t, testRedactionRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := []prompb.Label{
+		{Name: "__name__", Value: "logins"},
+		{Name: "email", Value: "bob@example.com"},
+		{Name: "session", Value: "abc"},
+		{Name: "user_id", Value: "42"},
+	}
+	redacted := []prompb.Label{
+		{Name: "__name__", Value: "logins"},
+		{Name: "email", Value: "redacted"},
+		{Name: "session", Value: "***"},
+	}
+	newReader := func() *DBReader {
+		ts := &prompb.TimeSeries{
+			Labels:  append([]prompb.Label(nil), labels...),
+			Samples: []prompb.Sample{{Timestamp: 1, Value: 1}},
+		}
+		r := &DBReader{db: &mockQuerier{tts: []*prompb.TimeSeries{ts}}}
+		r.EnableRedaction(rules)
+		return r
+	}
+	req := &prompb.ReadRequest{Queries: []*prompb.Query{{}}}
+
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		expected []prompb.Label
+	}{
+		{name: "anonymous", ctx: context.Background(), expected: redacted},
+		{name: "tenant", ctx: WithTenant(context.Background(), "alice"), expected: redacted},
+		{name: "admin", ctx: WithAdmin(WithTenant(context.Background(), "root")), expected: labels},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			resp, err := newReader().Read(c.ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resp.Results[0].Timeseries[0].Labels; !reflect.DeepEqual(got, c.expected) {
+				t.Errorf("unexpected labels: got %v wanted %v", got, c.expected)
+			}
+
+			var streamed bytes.Buffer
+			if err = newReader().ReadStreamed(c.ctx, req, &streamed); err != nil {
+				t.Fatal(err)
+			}
+			for _, l := range labels {
+				if l.Name == "__name__" {
+					continue
+				}
+				if contains := bytes.Contains(streamed.Bytes(), []byte(l.Value)); contains != reflect.DeepEqual(c.expected, labels) {
+					t.Errorf("unexpected presence of %s in the streamed response: %v", l.Name, contains)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactLabelsKeepsOriginal(t *testing.T) {
+	rules := []RedactionRule{{Label: "user_id", Action: RedactionMask, Replacement: "x"}}
+	original := []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "user_id", Value: "42"}}
+	ts := &prompb.TimeSeries{Labels: original}
+	redactLabels(ts, rules)
+
+	if original[1].Value != "42" {
+		t.Errorf("the original labels were modified: %v", original)
+	}
+	if ts.Labels[1].Value != "x" {
+		t.Errorf("unexpected labels: %v", ts.Labels)
+	}
+}
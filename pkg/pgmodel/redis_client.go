@@ -0,0 +1,136 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisClient speaks the subset of the Redis protocol (RESP) used by the
+// shared caches.
+type redisClient struct {
+	conns    *cacheConnPool
+	password string
+	db       int
+}
+
+// command sends a command and returns its reply: a string for the simple
+// strings, an int64 for the integers, a []byte or nil for the bulk strings.
+func (r *redisClient) command(args ...string) (interface{}, error) {
+	var reply interface{}
+	err := r.conns.do(func(c *cacheConn) error {
+		if !c.ready {
+			if r.password != "" {
+				if _, err := redisRoundTrip(c, "AUTH", r.password); err != nil {
+					return err
+				}
+			}
+			if r.db != 0 {
+				if _, err := redisRoundTrip(c, "SELECT", strconv.Itoa(r.db)); err != nil {
+					return err
+				}
+			}
+			c.ready = true
+		}
+		var err error
+		reply, err = redisRoundTrip(c, args...)
+		return err
+	})
+	return reply, err
+}
+
+func redisRoundTrip(c *cacheConn, args ...string) (interface{}, error) {
+	fmt.Fprintf(c.rw, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(c.rw, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if err := c.rw.Flush(); err != nil {
+		return nil, err
+	}
+	return readRedisReply(c)
+}
+
+func readRedisReply(c *cacheConn) (interface{}, error) {
+	line, err := c.rw.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty Redis reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis bulk string length %q", line[1:])
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(c.rw, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	default:
+		return nil, fmt.Errorf("unexpected Redis reply %q", line)
+	}
+}
+
+func (r *redisClient) get(key string) ([]byte, bool, error) {
+	reply, err := r.command("GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("unexpected Redis reply %v to GET", reply)
+	}
+	return value, true, nil
+}
+
+func (r *redisClient) set(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(int64(ttl/time.Millisecond), 10))
+	}
+	_, err := r.command(args...)
+	return err
+}
+
+func (r *redisClient) delete(key string) error {
+	_, err := r.command("DEL", key)
+	return err
+}
+
+func (r *redisClient) incr(key string) (int64, error) {
+	reply, err := r.command("INCR", key)
+	if err != nil {
+		return 0, err
+	}
+	value, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected Redis reply %v to INCR", reply)
+	}
+	return value, nil
+}
+
+func (r *redisClient) close() {
+	r.conns.close()
+}
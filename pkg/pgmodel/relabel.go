@@ -0,0 +1,120 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sync/atomic"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	relabelResultDropped  = "dropped"
+	relabelResultModified = "modified"
+)
+
+// Relabeler applies relabel configs, in the format of the
+// write_relabel_configs of Prometheus, to the ingested series before they
+// reach the database. The configs are read from a YAML file holding a list
+// of them, and can be reloaded while ingesting.
+type Relabeler struct {
+	path string
+	// configs holds the []*relabel.Config in use.
+	configs atomic.Value
+}
+
+// NewRelabeler returns a Relabeler applying the configs of the file at
+// path, or nil if path is empty.
+func NewRelabeler(path string) (*Relabeler, error) {
+	if path == "" {
+		return nil, nil
+	}
+	r := &Relabeler{path: path}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the configs again. If the file is invalid, the previous
+// configs stay in use.
+func (r *Relabeler) Reload() error {
+	if r == nil {
+		return nil
+	}
+	configs, err := loadRelabelConfigs(r.path)
+	if err != nil {
+		relabelConfigReloadSuccess.Set(0)
+		return err
+	}
+	r.configs.Store(configs)
+	relabelConfigReloadSuccess.Set(1)
+	ingestLogger.Info("msg", "Loaded relabel configs", "file", r.path, "configs", len(configs))
+	return nil
+}
+
+func loadRelabelConfigs(path string) ([]*relabel.Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading relabel configs: %w", err)
+	}
+	var configs []*relabel.Config
+	if err = yaml.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("parsing relabel configs %s: %w", path, err)
+	}
+	for i, c := range configs {
+		if c == nil {
+			return nil, fmt.Errorf("parsing relabel configs %s: entry %d is empty", path, i)
+		}
+	}
+	return configs, nil
+}
+
+// apply relabels the series and removes the dropped ones, which includes
+// the series left without a metric name. It returns the remaining series and
+// the number of samples dropped. tts is left untouched since it belongs to a
+// pooled write request.
+func (r *Relabeler) apply(tts []prompb.TimeSeries) ([]prompb.TimeSeries, int) {
+	if r == nil {
+		return tts, 0
+	}
+	configs := r.configs.Load().([]*relabel.Config)
+	if len(configs) == 0 {
+		return tts, 0
+	}
+
+	kept := make([]prompb.TimeSeries, 0, len(tts))
+	dropped := 0
+	for _, t := range tts {
+		if len(t.Samples) == 0 {
+			continue
+		}
+		lset := make(labels.Labels, len(t.Labels))
+		for i, l := range t.Labels {
+			lset[i] = labels.Label{Name: l.Name, Value: l.Value}
+		}
+		lset = labels.New(lset...)
+		relabeled := relabel.Process(lset, configs...)
+		if relabeled == nil || relabeled.Get(MetricNameLabelName) == "" {
+			relabeledSeries.WithLabelValues(relabelResultDropped).Inc()
+			dropped += len(t.Samples)
+			continue
+		}
+		if !labels.Equal(relabeled, lset) {
+			relabeledSeries.WithLabelValues(relabelResultModified).Inc()
+			t.Labels = make([]prompb.Label, len(relabeled))
+			for i, l := range relabeled {
+				t.Labels[i] = prompb.Label{Name: l.Name, Value: l.Value}
+			}
+		}
+		kept = append(kept, t)
+	}
+	return kept, dropped
+}
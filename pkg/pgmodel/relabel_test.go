@@ -0,0 +1,136 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const testRelabelConfigs = `
+- source_labels: [__name__]
+  regex: "debug_.*"
+  action: drop
+- source_labels: [pod]
+  regex: "(.*)-[0-9a-f]{5}"
+  target_label: pod
+- regex: "uid"
+  action: labeldrop
+`
+
+func writeRelabelConfigs(t *testing.T, dir, configs string) string {
+	path := filepath.Join(dir, "relabel.yml")
+	if err := ioutil.WriteFile(path, []byte(configs), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewRelabeler(t *testing.T) {
+	if r, err := NewRelabeler(""); r != nil || err != nil {
+		t.Errorf("unexpected relabeler without file: %v, %v", r, err)
+	}
+	var r *Relabeler
+	if err := r.Reload(); err != nil {
+		t.Errorf("nil relabeler failed to reload: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "relabel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, configs := range []string{"- action: unknown", "- action: replace", "- ~", "not a list"} {
+		if _, err := NewRelabeler(writeRelabelConfigs(t, dir, configs)); err == nil {
+			t.Errorf("invalid configs accepted: %q", configs)
+		}
+	}
+	if _, err := NewRelabeler(filepath.Join(dir, "missing.yml")); err == nil {
+		t.Error("missing file accepted")
+	}
+}
+
+func TestIngestRelabeling(t *testing.T) {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "relabel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	relabeler, err := NewRelabeler(writeRelabelConfigs(t, dir, testRelabelConfigs))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inserter := &mockInserter{insertedSeries: make(map[string]SeriesID)}
+	i := DBIngestor{db: inserter, cache: &mockCache{seriesCache: make(map[string]SeriesID)}, relabeler: relabeler}
+	tts := []prompb.TimeSeries{
+		{
+			Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "debug_allocs"}},
+			Samples: []prompb.Sample{{Timestamp: 1}, {Timestamp: 2}},
+		},
+		{
+			Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "cpu"}, {Name: "pod", Value: "web-1a2b3"}, {Name: "uid", Value: "42"}},
+			Samples: []prompb.Sample{{Timestamp: 1}},
+		},
+		{
+			Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "up"}},
+			Samples: []prompb.Sample{{Timestamp: 1}},
+		},
+	}
+
+	droppedBefore := testutil.ToFloat64(relabeledSeries.WithLabelValues(relabelResultDropped))
+	modifiedBefore := testutil.ToFloat64(relabeledSeries.WithLabelValues(relabelResultModified))
+	count, err := i.Ingest(context.Background(), tts, NewWriteRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Errorf("unexpected acknowledged samples: got %d wanted 4", count)
+	}
+	if len(inserter.insertedData) != 1 || len(inserter.insertedData[0]) != 2 || inserter.insertedData[0]["debug_allocs"] != nil {
+		t.Fatalf("unexpected inserted data: %+v", inserter.insertedData)
+	}
+	cpu := inserter.insertedData[0]["cpu"][0].labels
+	if cpu.names[1] != "pod" || cpu.values[1] != "web" || len(cpu.names) != 2 {
+		t.Errorf("unexpected relabeled series: %v %v", cpu.names, cpu.values)
+	}
+	if tts[1].Labels[1].Value != "web-1a2b3" {
+		t.Error("relabeling modified the write request")
+	}
+	if testutil.ToFloat64(relabeledSeries.WithLabelValues(relabelResultDropped)) != droppedBefore+1 ||
+		testutil.ToFloat64(relabeledSeries.WithLabelValues(relabelResultModified)) != modifiedBefore+1 {
+		t.Error("relabeled series not counted")
+	}
+
+	// An invalid file keeps the previous configs, a valid one replaces them.
+	writeRelabelConfigs(t, dir, "- action: unknown")
+	if err = i.ReloadRelabelConfig(); err == nil {
+		t.Fatal("invalid configs reloaded")
+	}
+	if testutil.ToFloat64(relabelConfigReloadSuccess) != 0 {
+		t.Error("failed reload not reported")
+	}
+	if kept, _ := relabeler.apply(tts); len(kept) != 2 {
+		t.Errorf("previous configs not kept: %d series left", len(kept))
+	}
+	writeRelabelConfigs(t, dir, "[]")
+	if err = i.ReloadRelabelConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if kept, dropped := relabeler.apply(tts); len(kept) != 3 || dropped != 0 {
+		t.Errorf("reloaded configs not applied: %d series left, %d samples dropped", len(kept), dropped)
+	}
+}
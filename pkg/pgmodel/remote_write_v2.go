@@ -0,0 +1,144 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+	writev2 "github.com/timescale/timescale-prometheus/pkg/prompb/io/prometheus/write/v2"
+)
+
+// The messages of the remote-write protocols, as named by the proto
+// parameter of the Content-Type of the requests.
+const (
+	RemoteWriteV1Message = "prometheus.WriteRequest"
+	RemoteWriteV2Message = "io.prometheus.write.v2.Request"
+)
+
+// RemoteWriteV2Stats counts the content of a remote-write 2.0 request.
+type RemoteWriteV2Stats struct {
+	// Samples is the number of float samples.
+	Samples int
+	// Histograms is the number of native histogram samples.
+	Histograms int
+	// Exemplars is the number of exemplars, which are not stored.
+	Exemplars int
+}
+
+// ParseRemoteWriteV2 converts a remote-write 2.0 request into a write request
+// for Ingest, resolving the label references against the symbol table of
+// the request.
+//
+// The float samples and the native histograms are stored as received. The
+// exemplars, the metadata and the created timestamps are accepted but not
+// stored.
+func ParseRemoteWriteV2(data []byte) (*prompb.WriteRequest, RemoteWriteV2Stats, error) {
+	var (
+		stats RemoteWriteV2Stats
+		v2    writev2.Request
+	)
+	if err := v2.Unmarshal(data); err != nil {
+		return nil, stats, fmt.Errorf("parsing remote-write 2.0 request: %w", err)
+	}
+
+	req := NewWriteRequest()
+	for i := range v2.Timeseries {
+		s := &v2.Timeseries[i]
+		ls, err := resolveLabels(s.LabelsRefs, v2.Symbols)
+		if err != nil {
+			FinishWriteRequest(req)
+			return nil, stats, fmt.Errorf("parsing remote-write 2.0 request: series %d: %w", i, err)
+		}
+		if len(s.Samples) > 0 || len(s.Histograms) > 0 {
+			req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+				Labels:     ls,
+				Samples:    convertV2Samples(s.Samples),
+				Histograms: convertV2Histograms(s.Histograms),
+			})
+			stats.Samples += len(s.Samples)
+			stats.Histograms += len(s.Histograms)
+		}
+		stats.Exemplars += len(s.Exemplars)
+	}
+	return req, stats, nil
+}
+
+// resolveLabels returns the labels of the name and value references refs.
+func resolveLabels(refs []uint32, symbols []string) ([]prompb.Label, error) {
+	if len(refs)%2 != 0 {
+		return nil, errors.New("odd number of label references")
+	}
+	ls := make([]prompb.Label, 0, len(refs)/2)
+	for i := 0; i < len(refs); i += 2 {
+		name, value := refs[i], refs[i+1]
+		if int(name) >= len(symbols) || int(value) >= len(symbols) {
+			return nil, fmt.Errorf("label reference out of the %d symbols", len(symbols))
+		}
+		ls = append(ls, prompb.Label{Name: symbols[name], Value: symbols[value]})
+	}
+	return ls, nil
+}
+
+func convertV2Samples(samples []writev2.Sample) []prompb.Sample {
+	if len(samples) == 0 {
+		return nil
+	}
+	converted := make([]prompb.Sample, len(samples))
+	for i, s := range samples {
+		converted[i] = prompb.Sample{Timestamp: s.Timestamp, Value: s.Value}
+	}
+	return converted
+}
+
+func convertV2Histograms(histograms []writev2.Histogram) []prompb.Histogram {
+	if len(histograms) == 0 {
+		return nil
+	}
+	converted := make([]prompb.Histogram, len(histograms))
+	for i := range histograms {
+		h := &histograms[i]
+		c := prompb.Histogram{
+			Sum:            h.Sum,
+			Schema:         h.Schema,
+			ZeroThreshold:  h.ZeroThreshold,
+			NegativeSpans:  convertV2Spans(h.NegativeSpans),
+			NegativeDeltas: h.NegativeDeltas,
+			NegativeCounts: h.NegativeCounts,
+			PositiveSpans:  convertV2Spans(h.PositiveSpans),
+			PositiveDeltas: h.PositiveDeltas,
+			PositiveCounts: h.PositiveCounts,
+			ResetHint:      prompb.Histogram_ResetHint(h.ResetHint),
+			Timestamp:      h.Timestamp,
+			CustomValues:   h.CustomValues,
+		}
+		switch count := h.Count.(type) {
+		case *writev2.Histogram_CountInt:
+			c.Count = &prompb.Histogram_CountInt{CountInt: count.CountInt}
+		case *writev2.Histogram_CountFloat:
+			c.Count = &prompb.Histogram_CountFloat{CountFloat: count.CountFloat}
+		}
+		switch count := h.ZeroCount.(type) {
+		case *writev2.Histogram_ZeroCountInt:
+			c.ZeroCount = &prompb.Histogram_ZeroCountInt{ZeroCountInt: count.ZeroCountInt}
+		case *writev2.Histogram_ZeroCountFloat:
+			c.ZeroCount = &prompb.Histogram_ZeroCountFloat{ZeroCountFloat: count.ZeroCountFloat}
+		}
+		converted[i] = c
+	}
+	return converted
+}
+
+func convertV2Spans(spans []writev2.BucketSpan) []prompb.BucketSpan {
+	if len(spans) == 0 {
+		return nil
+	}
+	converted := make([]prompb.BucketSpan, len(spans))
+	for i, s := range spans {
+		converted[i] = prompb.BucketSpan{Offset: s.Offset, Length: s.Length}
+	}
+	return converted
+}
@@ -0,0 +1,152 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/prometheus/pkg/value"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+	writev2 "github.com/timescale/timescale-prometheus/pkg/prompb/io/prometheus/write/v2"
+)
+
+func marshalWriteV2(t *testing.T, req *writev2.Request) []byte {
+	data, err := req.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseRemoteWriteV2(t *testing.T) {
+	data := marshalWriteV2(t, &writev2.Request{
+		Symbols: []string{"", "__name__", "up", "job", "api", "rpc_seconds", "trace_id", "abc"},
+		Timeseries: []writev2.TimeSeries{
+			{
+				LabelsRefs: []uint32{1, 2, 3, 4},
+				Samples:    []writev2.Sample{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 0}},
+				// An exemplar and metadata, which are not stored.
+				Exemplars: []writev2.Exemplar{{LabelsRefs: []uint32{6, 7}, Value: 1}},
+				Metadata:  writev2.Metadata{Type: writev2.Metadata_METRIC_TYPE_GAUGE},
+			},
+			{
+				LabelsRefs: []uint32{3, 4, 1, 5},
+				Histograms: []writev2.Histogram{
+					{
+						Count:          &writev2.Histogram_CountInt{CountInt: 7},
+						Sum:            10,
+						ZeroThreshold:  0.001,
+						ZeroCount:      &writev2.Histogram_ZeroCountInt{ZeroCountInt: 1},
+						NegativeSpans:  []writev2.BucketSpan{{Offset: 0, Length: 1}},
+						NegativeDeltas: []int64{1},
+						PositiveSpans:  []writev2.BucketSpan{{Offset: 0, Length: 2}},
+						PositiveDeltas: []int64{2, 1},
+						Timestamp:      3000,
+					},
+					{
+						Count:          &writev2.Histogram_CountFloat{CountFloat: 6},
+						Sum:            2.5,
+						Schema:         customBucketsSchema,
+						PositiveSpans:  []writev2.BucketSpan{{Offset: 0, Length: 3}},
+						PositiveCounts: []float64{1, 2, 3},
+						Timestamp:      4000,
+						CustomValues:   []float64{0.1, 0.5},
+					},
+				},
+			},
+		},
+	})
+
+	req, stats, err := ParseRemoteWriteV2(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer FinishWriteRequest(req)
+
+	if expected := (RemoteWriteV2Stats{Samples: 2, Histograms: 2, Exemplars: 1}); stats != expected {
+		t.Errorf("unexpected stats: got %+v wanted %+v", stats, expected)
+	}
+
+	expected := []prompb.TimeSeries{
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "api"}},
+			Samples: []prompb.Sample{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 0}},
+		},
+		{
+			Labels: []prompb.Label{{Name: "job", Value: "api"}, {Name: "__name__", Value: "rpc_seconds"}},
+			Histograms: []prompb.Histogram{
+				{
+					Count:          &prompb.Histogram_CountInt{CountInt: 7},
+					Sum:            10,
+					ZeroThreshold:  0.001,
+					ZeroCount:      &prompb.Histogram_ZeroCountInt{ZeroCountInt: 1},
+					NegativeSpans:  []prompb.BucketSpan{{Offset: 0, Length: 1}},
+					NegativeDeltas: []int64{1},
+					PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 2}},
+					PositiveDeltas: []int64{2, 1},
+					Timestamp:      3000,
+				},
+				{
+					Count:          &prompb.Histogram_CountFloat{CountFloat: 6},
+					Sum:            2.5,
+					Schema:         customBucketsSchema,
+					PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 3}},
+					PositiveCounts: []float64{1, 2, 3},
+					Timestamp:      4000,
+					CustomValues:   []float64{0.1, 0.5},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(req.Timeseries, expected) {
+		t.Errorf("unexpected series:\ngot    %v\nwanted %v", req.Timeseries, expected)
+	}
+}
+
+func TestParseRemoteWriteV2StaleHistogram(t *testing.T) {
+	req, _, err := ParseRemoteWriteV2(marshalWriteV2(t, &writev2.Request{
+		Symbols: []string{"", "__name__", "rpc_seconds"},
+		Timeseries: []writev2.TimeSeries{{
+			LabelsRefs: []uint32{1, 2},
+			Histograms: []writev2.Histogram{{Sum: math.Float64frombits(value.StaleNaN), Timestamp: 5000}},
+		}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer FinishWriteRequest(req)
+
+	if len(req.Timeseries) != 1 || len(req.Timeseries[0].Histograms) != 1 {
+		t.Fatalf("unexpected series: %v", req.Timeseries)
+	}
+	if h := req.Timeseries[0].Histograms[0]; !value.IsStaleNaN(h.Sum) || h.Timestamp != 5000 {
+		t.Errorf("expected a stale marker, got %v", h)
+	}
+}
+
+func TestParseRemoteWriteV2Invalid(t *testing.T) {
+	symbols := []string{"", "__name__", "up"}
+	valid := marshalWriteV2(t, &writev2.Request{Symbols: symbols})
+	testCases := map[string][]byte{
+		"truncated": valid[:len(valid)-1],
+		"odd refs": marshalWriteV2(t, &writev2.Request{
+			Symbols:    symbols,
+			Timeseries: []writev2.TimeSeries{{LabelsRefs: []uint32{1, 2, 1}}},
+		}),
+		"ref out of range": marshalWriteV2(t, &writev2.Request{
+			Symbols:    symbols,
+			Timeseries: []writev2.TimeSeries{{LabelsRefs: []uint32{1, 3}}},
+		}),
+		// The timeseries field with the wire type of a varint.
+		"wrong wire type": append(valid, 5<<3, 1),
+	}
+	for name, data := range testCases {
+		if _, _, err := ParseRemoteWriteV2(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
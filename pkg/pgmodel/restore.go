@@ -0,0 +1,299 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	// DefaultRestoreSeriesBatch is the default number of series whose ids
+	// are looked up at once in the live database.
+	DefaultRestoreSeriesBatch = 1000
+
+	restoreSeriesSQLFormat  = `SELECT s.id, (key_value_array(s.labels)).* FROM %s s ORDER BY s.id`
+	restoreRangeSQLFormat   = `SELECT min(time), max(time) FROM %s HAVING count(*) > 0`
+	restoreOverlapSQLFormat = `SELECT EXISTS (SELECT 1 FROM %s WHERE time >= $1 AND time <= $2)`
+	restoreSamplesSQLFormat = `SELECT time, value, series_id FROM %s`
+)
+
+var (
+	// ErrMetricNotInBackup is returned by Restore for the metrics missing
+	// from the restored backup.
+	ErrMetricNotInBackup = errors.New("metric not found in the backup")
+	// ErrRestoreOverlap is returned by Restore when the live metric already
+	// has samples in the time range of the backup.
+	ErrRestoreOverlap = errors.New("the metric already has samples in the time range of the backup")
+)
+
+// RestoreConfig configures the restore of a metric.
+type RestoreConfig struct {
+	Metric string
+	// AllowOverlap restores the samples even if the live metric already has
+	// samples in the time range of the backup, which may then be stored
+	// twice.
+	AllowOverlap bool
+	// SeriesBatch is the number of series whose ids are looked up at once.
+	// 0 selects DefaultRestoreSeriesBatch.
+	SeriesBatch int
+}
+
+// RestoreStats counts what Restore copied.
+type RestoreStats struct {
+	Series  int
+	Samples int64
+}
+
+// MetricRestorer copies a metric from a database where a logical backup was
+// restored, such as a scratch database loaded with pg_restore, into the live
+// database, to recover a single metric without restoring the whole
+// database.
+//
+// The series ids of the backup may belong to other series in the live
+// database, so the series are looked up, or created, by their labels in the
+// live database and the samples are copied with the ids found there.
+type MetricRestorer struct {
+	source pgxConn
+	target pgxConn
+}
+
+// NewMetricRestorer returns a MetricRestorer copying from the backup
+// database to the live one.
+func NewMetricRestorer(backup, live *pgxpool.Pool) *MetricRestorer {
+	return &MetricRestorer{
+		source: &pgxConnImpl{conn: backup},
+		target: &pgxConnImpl{conn: live},
+	}
+}
+
+// Restore copies the series and samples of cfg.Metric. The samples are
+// copied with a single COPY, so that none is stored if the restore fails.
+func (r *MetricRestorer) Restore(ctx context.Context, cfg RestoreConfig) (RestoreStats, error) {
+	var stats RestoreStats
+	if cfg.SeriesBatch <= 0 {
+		cfg.SeriesBatch = DefaultRestoreSeriesBatch
+	}
+
+	sourceTable, found, err := lookupMetricTable(ctx, r.source, getMetricsTableSQL, cfg.Metric)
+	if err != nil {
+		return stats, fmt.Errorf("reading the backup: %w", err)
+	}
+	if !found {
+		return stats, fmt.Errorf("%w: %s", ErrMetricNotInBackup, cfg.Metric)
+	}
+	start, end, empty, err := r.sourceRange(ctx, sourceTable)
+	if err != nil || empty {
+		return stats, err
+	}
+
+	targetTable, _, err := lookupMetricTable(ctx, r.target, getCreateMetricsTableSQL, cfg.Metric)
+	if err != nil {
+		return stats, err
+	}
+	if _, err = r.target.Exec(ctx, finalizeMetricCreation); err != nil {
+		return stats, err
+	}
+	if !cfg.AllowOverlap {
+		if err = r.checkOverlap(ctx, targetTable, start, end); err != nil {
+			return stats, err
+		}
+	}
+
+	ids, err := r.remapSeries(ctx, cfg, sourceTable)
+	if err != nil {
+		return stats, err
+	}
+	stats.Series = len(ids)
+
+	rows, err := r.source.Query(ctx, fmt.Sprintf(restoreSamplesSQLFormat, pgx.Identifier{dataSchema, sourceTable}.Sanitize()))
+	if err != nil {
+		return stats, fmt.Errorf("reading the backup: %w", err)
+	}
+	defer rows.Close()
+	src := &restoreRows{rows: rows, ids: ids}
+	stats.Samples, err = r.target.CopyFrom(ctx, pgx.Identifier{dataSchema, targetTable}, copyColumns, src)
+	if err != nil {
+		return stats, err
+	}
+	return stats, rows.Err()
+}
+
+// lookupMetricTable returns the table of metric with sql, reporting whether
+// it exists.
+func lookupMetricTable(ctx context.Context, conn pgxConn, sql, metric string) (string, bool, error) {
+	rows, err := conn.Query(ctx, sql, metric)
+	if err != nil {
+		return "", false, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", false, rows.Err()
+	}
+	var table string
+	if err = rows.Scan(&table); err != nil {
+		return "", false, err
+	}
+	return table, true, nil
+}
+
+// sourceRange returns the time range of the samples of the backup, empty
+// if it has none.
+func (r *MetricRestorer) sourceRange(ctx context.Context, table string) (start, end time.Time, empty bool, err error) {
+	rows, err := r.source.Query(ctx, fmt.Sprintf(restoreRangeSQLFormat, pgx.Identifier{dataSchema, table}.Sanitize()))
+	if err != nil {
+		return start, end, false, fmt.Errorf("reading the backup: %w", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return start, end, true, rows.Err()
+	}
+	if err = rows.Scan(&start, &end); err != nil {
+		return start, end, false, err
+	}
+	return start, end, false, nil
+}
+
+func (r *MetricRestorer) checkOverlap(ctx context.Context, table string, start, end time.Time) error {
+	rows, err := r.target.Query(ctx, fmt.Sprintf(restoreOverlapSQLFormat, pgx.Identifier{dataSchema, table}.Sanitize()), start, end)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var overlap bool
+	if rows.Next() {
+		if err = rows.Scan(&overlap); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if overlap {
+		return fmt.Errorf("%w: %v to %v", ErrRestoreOverlap, start.UTC(), end.UTC())
+	}
+	return nil
+}
+
+// remapSeries returns the ids in the live database of the series of the
+// backup, by their id in the backup.
+func (r *MetricRestorer) remapSeries(ctx context.Context, cfg RestoreConfig, table string) (map[int64]int64, error) {
+	rows, err := r.source.Query(ctx, fmt.Sprintf(restoreSeriesSQLFormat, pgx.Identifier{dataSeriesSchema, table}.Sanitize()))
+	if err != nil {
+		return nil, fmt.Errorf("reading the backup: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		ids          = make(map[int64]int64)
+		backupIDs    []int64
+		keys, values []string
+		counts       []int32
+	)
+	flush := func() error {
+		liveIDs, err := seriesIDsForLabels(ctx, r.target, cfg.Metric, keys, values, counts)
+		if err != nil {
+			return err
+		}
+		if len(liveIDs) != len(backupIDs) {
+			return fmt.Errorf("got %d series ids for %d series", len(liveIDs), len(backupIDs))
+		}
+		for i, id := range backupIDs {
+			ids[id] = liveIDs[i]
+		}
+		backupIDs, keys, values, counts = backupIDs[:0], keys[:0], values[:0], counts[:0]
+		return nil
+	}
+	for rows.Next() {
+		var (
+			id                     int64
+			seriesKeys, seriesVals []string
+		)
+		if err = rows.Scan(&id, &seriesKeys, &seriesVals); err != nil {
+			return nil, err
+		}
+		backupIDs = append(backupIDs, id)
+		keys = append(keys, seriesKeys...)
+		values = append(values, seriesVals...)
+		counts = append(counts, int32(len(seriesKeys)))
+		if len(backupIDs) >= cfg.SeriesBatch {
+			if err = flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading the backup: %w", err)
+	}
+	if len(backupIDs) > 0 {
+		err = flush()
+	}
+	return ids, err
+}
+
+// seriesIDsForLabels returns the ids of the series of metric, creating those
+// missing, whose labels are given as by getSeriesIDsForLabelsSQL.
+func seriesIDsForLabels(ctx context.Context, conn pgxConn, metric string, keys, values []string, counts []int32) ([]int64, error) {
+	rows, err := conn.Query(ctx, getSeriesIDsForLabelsSQL, metric, keys, values, counts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make([]int64, 0, len(counts))
+	for rows.Next() {
+		var (
+			table string
+			id    int64
+		)
+		if err = rows.Scan(&table, &id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// restoreRows is the source of the COPY of the samples of the backup, with
+// the series ids of the live database.
+type restoreRows struct {
+	rows pgx.Rows
+	ids  map[int64]int64
+	row  []interface{}
+	err  error
+}
+
+func (r *restoreRows) Next() bool {
+	if r.err != nil || !r.rows.Next() {
+		return false
+	}
+	var (
+		t        time.Time
+		value    float64
+		seriesID int64
+	)
+	if r.err = r.rows.Scan(&t, &value, &seriesID); r.err != nil {
+		return false
+	}
+	id, ok := r.ids[seriesID]
+	if !ok {
+		r.err = fmt.Errorf("sample of the series %d missing from the backup", seriesID)
+		return false
+	}
+	r.row = []interface{}{t, value, id}
+	return true
+}
+
+func (r *restoreRows) Values() ([]interface{}, error) {
+	return r.row, nil
+}
+
+func (r *restoreRows) Err() error {
+	return r.err
+}
@@ -0,0 +1,127 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func TestMetricRestorer(t *testing.T) {
+	start, end := time.Unix(1, 0), time.Unix(2, 0)
+	backupResults := func(samples rowResults) []rowResults {
+		return []rowResults{
+			{{"foo_backup"}},
+			{{start, end}},
+			{
+				{int64(1), []string{"__name__", "job"}, []string{"foo", "a"}},
+				{int64(5), []string{"__name__", "job"}, []string{"foo", "b"}},
+			},
+			samples,
+		}
+	}
+	liveResults := func(overlap bool) []rowResults {
+		return []rowResults{
+			{{"foo"}},
+			{{overlap}},
+			{{"foo", int64(5)}},
+			{{"foo", int64(1)}},
+		}
+	}
+
+	testCases := []struct {
+		name         string
+		backup       []rowResults
+		live         []rowResults
+		cfg          RestoreConfig
+		err          error
+		stats        RestoreStats
+		copied       [][]interface{}
+		seriesLookup int
+	}{
+		{
+			name:   "restored",
+			backup: backupResults(rowResults{{start, float64(1), int64(1)}, {end, float64(2), int64(5)}}),
+			live:   liveResults(false),
+			cfg:    RestoreConfig{Metric: "foo", SeriesBatch: 1},
+			stats:  RestoreStats{Series: 2, Samples: 2},
+			copied: [][]interface{}{{start, float64(1), int64(5)}, {end, float64(2), int64(1)}},
+		},
+		{
+			name:   "overlap",
+			backup: backupResults(nil),
+			live:   liveResults(true),
+			cfg:    RestoreConfig{Metric: "foo"},
+			err:    ErrRestoreOverlap,
+		},
+		{
+			name:   "overlap allowed",
+			backup: backupResults(rowResults{{start, float64(1), int64(1)}}),
+			live:   []rowResults{{{"foo"}}, {{"foo", int64(5)}, {"foo", int64(1)}}},
+			cfg:    RestoreConfig{Metric: "foo", AllowOverlap: true},
+			stats:  RestoreStats{Series: 2, Samples: 1},
+			copied: [][]interface{}{{start, float64(1), int64(5)}},
+		},
+		{
+			name:   "not in backup",
+			backup: nil,
+			cfg:    RestoreConfig{Metric: "foo"},
+			err:    ErrMetricNotInBackup,
+		},
+		{
+			name:   "unknown series",
+			backup: backupResults(rowResults{{start, float64(1), int64(3)}}),
+			live:   []rowResults{{{"foo"}}, {{"foo", int64(5)}, {"foo", int64(1)}}},
+			cfg:    RestoreConfig{Metric: "foo", AllowOverlap: true},
+			err:    errors.New("sample of the series 3 missing from the backup"),
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			backup := &mockPGXConn{QueryResults: c.backup}
+			live := &mockPGXConn{QueryResults: c.live}
+			r := &MetricRestorer{source: backup, target: live}
+
+			stats, err := r.Restore(context.Background(), c.cfg)
+			switch {
+			case c.err == nil && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case c.err != nil && !errors.Is(err, c.err) && (err == nil || err.Error() != c.err.Error()):
+				t.Fatalf("unexpected error: got %v wanted %v", err, c.err)
+			case c.err != nil:
+				return
+			}
+			if stats != c.stats {
+				t.Errorf("unexpected stats: got %+v wanted %+v", stats, c.stats)
+			}
+			if !reflect.DeepEqual(live.CopyFromRowsRows, c.copied) {
+				t.Errorf("unexpected copied rows:\ngot    %v\nwanted %v", live.CopyFromRowsRows, c.copied)
+			}
+			if !reflect.DeepEqual(live.CopyFromTableName, []pgx.Identifier{{dataSchema, "foo"}}) {
+				t.Errorf("unexpected table: %v", live.CopyFromTableName)
+			}
+			if !reflect.DeepEqual(live.ExecSQLs, []string{finalizeMetricCreation}) {
+				t.Errorf("unexpected statements: %v", live.ExecSQLs)
+			}
+		})
+	}
+}
+
+func TestMetricRestorerEmptyBackup(t *testing.T) {
+	backup := &mockPGXConn{QueryResults: []rowResults{{{"foo_backup"}}}}
+	live := &mockPGXConn{}
+	r := &MetricRestorer{source: backup, target: live}
+	stats, err := r.Restore(context.Background(), RestoreConfig{Metric: "foo"})
+	if err != nil || stats != (RestoreStats{}) {
+		t.Fatalf("unexpected result: %+v, %v", stats, err)
+	}
+	if len(live.QuerySQLs) != 0 {
+		t.Errorf("unexpected queries of the live database: %v", live.QuerySQLs)
+	}
+}
@@ -0,0 +1,117 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+)
+
+const (
+	// DefaultRetryAttempts is the default number of attempts of a database
+	// write failing with a transient error.
+	DefaultRetryAttempts = 3
+	// DefaultRetryBackoff is the default wait before the first retry.
+	DefaultRetryBackoff = 100 * time.Millisecond
+	// DefaultRetryMaxBackoff is the default maximum wait between retries.
+	DefaultRetryMaxBackoff = 5 * time.Second
+)
+
+// RetryConfig configures how writes failing with a transient error, such as
+// a serialization failure or a connection lost in a failover, are retried.
+// The pool replaces broken connections, so a retry runs on a new connection.
+type RetryConfig struct {
+	// MaxAttempts is the number of attempts of a write, including the
+	// first one. 0 or 1 disables retries.
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubled after each retry.
+	Backoff time.Duration
+	// MaxBackoff caps the wait between retries.
+	MaxBackoff time.Duration
+}
+
+// retryPolicy retries writes as configured. A nil policy tries once.
+type retryPolicy struct {
+	cfg RetryConfig
+}
+
+func newRetryPolicy(cfg RetryConfig) *retryPolicy {
+	if cfg.MaxAttempts <= 1 {
+		return nil
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultRetryBackoff
+	}
+	if cfg.MaxBackoff < cfg.Backoff {
+		cfg.MaxBackoff = cfg.Backoff
+	}
+	return &retryPolicy{cfg: cfg}
+}
+
+// do runs f until it succeeds, fails with an error which is not transient,
+// or runs out of attempts, and returns the last error. op names the write in
+// the logs and the ts_prom_write_attempts_total metric.
+func (r *retryPolicy) do(op string, f func() error) error {
+	maxAttempts := 1
+	var backoff time.Duration
+	if r != nil {
+		maxAttempts = r.cfg.MaxAttempts
+		backoff = r.cfg.Backoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := f()
+		switch {
+		case err == nil:
+			writeAttempts.WithLabelValues(op, "success").Inc()
+			return nil
+		case attempt >= maxAttempts || !isRetryable(err):
+			writeAttempts.WithLabelValues(op, "failure").Inc()
+			return err
+		}
+
+		writeAttempts.WithLabelValues(op, "retry").Inc()
+		ingestLogger.Warn("msg", "Retrying write after transient error", "op", op, "attempt", attempt, "backoff", backoff, "err", err)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > r.cfg.MaxBackoff {
+			backoff = r.cfg.MaxBackoff
+		}
+	}
+}
+
+// isRetryable tells if err is transient, so that the same write may succeed
+// when tried again.
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgerrcode.SerializationFailure, pgerrcode.DeadlockDetected,
+			pgerrcode.AdminShutdown, pgerrcode.CrashShutdown, pgerrcode.CannotConnectNow,
+			// A failed over primary refuses writes until it is restarted as
+			// a replica.
+			pgerrcode.ReadOnlySQLTransaction:
+			return true
+		}
+		// Class 08 holds the connection exceptions.
+		return strings.HasPrefix(pgErr.Code, "08")
+	}
+
+	var netErr net.Error
+	return pgconn.SafeToRetry(err) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.As(err, &netErr)
+}
@@ -0,0 +1,135 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestIsRetryable(t *testing.T) {
+	testCases := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{name: "serialization failure", err: &pgconn.PgError{Code: pgerrcode.SerializationFailure}, retryable: true},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: pgerrcode.AdminShutdown}, retryable: true},
+		{name: "read only after failover", err: &pgconn.PgError{Code: pgerrcode.ReadOnlySQLTransaction}, retryable: true},
+		{name: "connection exception", err: &pgconn.PgError{Code: pgerrcode.ConnectionFailure}, retryable: true},
+		{name: "wrapped connection reset", err: fmt.Errorf("copy: %w", syscall.ECONNRESET), retryable: true},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, retryable: true},
+		{name: "unique violation", err: &pgconn.PgError{Code: pgerrcode.UniqueViolation}},
+		{name: "undefined table", err: &pgconn.PgError{Code: pgerrcode.UndefinedTable}},
+		{name: "other error", err: fmt.Errorf("some error")},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isRetryable(c.err); got != c.retryable {
+				t.Errorf("unexpected result: got %v wanted %v", got, c.retryable)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	transient := &pgconn.PgError{Code: pgerrcode.SerializationFailure}
+	testCases := []struct {
+		name     string
+		cfg      RetryConfig
+		errs     []error
+		attempts int
+		isError  bool
+	}{
+		{name: "disabled", cfg: RetryConfig{MaxAttempts: 1}, errs: []error{transient, nil}, attempts: 1, isError: true},
+		{name: "recovered", cfg: RetryConfig{MaxAttempts: 3, Backoff: time.Millisecond}, errs: []error{transient, transient, nil}, attempts: 3},
+		{name: "exhausted", cfg: RetryConfig{MaxAttempts: 2, Backoff: time.Millisecond}, errs: []error{transient, transient, nil}, attempts: 2, isError: true},
+		{name: "permanent", cfg: RetryConfig{MaxAttempts: 3, Backoff: time.Millisecond}, errs: []error{fmt.Errorf("some error"), nil}, attempts: 1, isError: true},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			retriesBefore := testutil.ToFloat64(writeAttempts.WithLabelValues("test", "retry"))
+			attempts := 0
+			err := newRetryPolicy(c.cfg).do("test", func() error {
+				attempts++
+				return c.errs[attempts-1]
+			})
+			if (err != nil) != c.isError {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if attempts != c.attempts {
+				t.Errorf("unexpected attempts: got %d wanted %d", attempts, c.attempts)
+			}
+			if retries := testutil.ToFloat64(writeAttempts.WithLabelValues("test", "retry")) - retriesBefore; int(retries) != c.attempts-1 {
+				t.Errorf("unexpected retries: got %v wanted %d", retries, c.attempts-1)
+			}
+		})
+	}
+}
+
+// flakyCopyConn fails the first COPYs with a connection reset.
+type flakyCopyConn struct {
+	*mockPGXConn
+	failures int
+}
+
+func (c *flakyCopyConn) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	copied, err := c.mockPGXConn.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	c.insertLock.Lock()
+	defer c.insertLock.Unlock()
+	if c.failures > 0 {
+		c.failures--
+		return 0, syscall.ECONNRESET
+	}
+	return copied, err
+}
+
+func TestPGXInserterRetry(t *testing.T) {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	mock := &flakyCopyConn{
+		mockPGXConn: &mockPGXConn{
+			QueryResults:   []rowResults{{{"metricTableName_0", true}}},
+			CopyFromResult: 1,
+		},
+		failures: 1,
+	}
+	mockMetrics := &mockMetricCache{metricCache: map[string]string{}}
+	cfg := &Cfg{Retry: RetryConfig{MaxAttempts: 2, Backoff: time.Millisecond}}
+	inserter, err := newPgxInserter(mock, mockMetrics, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inserter.Close()
+
+	rows := createRows(1)
+	rows["metric_0"][0].seriesID = 1
+	rows["metric_0"][0].samples = []prompb.Sample{{Timestamp: 1, Value: 1}}
+	if _, err = inserter.InsertData(context.Background(), rows); err != nil {
+		t.Fatalf("transient error not retried: %v", err)
+	}
+	if len(mock.CopyFromRowSource) != 2 {
+		t.Errorf("unexpected number of COPYs: got %d wanted 2", len(mock.CopyFromRowSource))
+	}
+
+	mock.failures = 2
+	if _, err = inserter.InsertData(context.Background(), rows); err == nil {
+		t.Error("error not surfaced once the retries are exhausted")
+	}
+}
@@ -0,0 +1,113 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+const (
+	rejectReasonTooOld = "too_old"
+	rejectReasonTooNew = "too_far_in_future"
+)
+
+// SampleBoundsConfig bounds the timestamps of the ingested samples relative
+// to the time they are received. A zero bound is disabled.
+type SampleBoundsConfig struct {
+	// MaxAge rejects the samples older than it, such as those of a
+	// Prometheus replaying a long outage, which would be written to
+	// compressed chunks.
+	MaxAge time.Duration
+	// MaxFuture rejects the samples further in the future than it, usually
+	// sent by a host with a wrong clock.
+	MaxFuture time.Duration
+}
+
+// SamplesOutOfBoundsError reports the samples of a write rejected because of
+// their timestamp. The other samples of the write are stored.
+type SamplesOutOfBoundsError struct {
+	TooOld int
+	TooNew int
+}
+
+func (e *SamplesOutOfBoundsError) Error() string {
+	return fmt.Sprintf("%d samples out of bounds: %d too old, %d too far in the future", e.TooOld+e.TooNew, e.TooOld, e.TooNew)
+}
+
+// Rejected returns the number of rejected samples.
+func (e *SamplesOutOfBoundsError) Rejected() int {
+	return e.TooOld + e.TooNew
+}
+
+// sampleBounds enforces a SampleBoundsConfig. A nil *sampleBounds accepts
+// every sample.
+type sampleBounds struct {
+	cfg SampleBoundsConfig
+	now func() time.Time
+}
+
+func newSampleBounds(cfg SampleBoundsConfig) (*sampleBounds, error) {
+	if cfg.MaxAge < 0 || cfg.MaxFuture < 0 {
+		return nil, fmt.Errorf("invalid sample bounds: max age %v, max future %v", cfg.MaxAge, cfg.MaxFuture)
+	}
+	if cfg.MaxAge == 0 && cfg.MaxFuture == 0 {
+		return nil, nil
+	}
+	return &sampleBounds{cfg: cfg, now: time.Now}, nil
+}
+
+// enforce removes the samples out of bounds from data, along with the series
+// and metrics left without samples. It returns an error counting them, or
+// nil if every sample is in bounds.
+func (b *sampleBounds) enforce(data map[string][]samplesInfo) *SamplesOutOfBoundsError {
+	if b == nil {
+		return nil
+	}
+	now := b.now()
+	minTime, maxTime := int64(model.Earliest), int64(model.Latest)
+	if b.cfg.MaxAge > 0 {
+		minTime = int64(model.TimeFromUnixNano(now.Add(-b.cfg.MaxAge).UnixNano()))
+	}
+	if b.cfg.MaxFuture > 0 {
+		maxTime = int64(model.TimeFromUnixNano(now.Add(b.cfg.MaxFuture).UnixNano()))
+	}
+
+	rejected := &SamplesOutOfBoundsError{}
+	for metric, series := range data {
+		keptSeries := series[:0]
+		for _, s := range series {
+			kept := s.samples[:0]
+			for _, sample := range s.samples {
+				switch ms := sampleMillis(sample.Timestamp, s.microseconds); {
+				case ms < minTime:
+					rejected.TooOld++
+				case ms > maxTime:
+					rejected.TooNew++
+				default:
+					kept = append(kept, sample)
+				}
+			}
+			s.samples = kept
+			if len(kept) > 0 {
+				keptSeries = append(keptSeries, s)
+			}
+		}
+		if len(keptSeries) == 0 {
+			delete(data, metric)
+			continue
+		}
+		data[metric] = keptSeries
+	}
+
+	if rejected.Rejected() == 0 {
+		return nil
+	}
+	outOfBoundsSamples.WithLabelValues(rejectReasonTooOld).Add(float64(rejected.TooOld))
+	outOfBoundsSamples.WithLabelValues(rejectReasonTooNew).Add(float64(rejected.TooNew))
+	return rejected
+}
@@ -0,0 +1,76 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestNewSampleBounds(t *testing.T) {
+	if b, err := newSampleBounds(SampleBoundsConfig{}); b != nil || err != nil {
+		t.Errorf("unexpected bounds without limits: %v, %v", b, err)
+	}
+	if _, err := newSampleBounds(SampleBoundsConfig{MaxAge: -time.Second}); err == nil {
+		t.Error("negative max age accepted")
+	}
+	var b *sampleBounds
+	if err := b.enforce(map[string][]samplesInfo{"metric": {{samples: []prompb.Sample{{Timestamp: 0}}}}}); err != nil {
+		t.Errorf("nil bounds rejected samples: %v", err)
+	}
+}
+
+func TestIngestSampleBounds(t *testing.T) {
+	now := time.Unix(10000, 0)
+	bounds, err := newSampleBounds(SampleBoundsConfig{MaxAge: time.Hour, MaxFuture: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bounds.now = func() time.Time { return now }
+	inserter := &mockInserter{insertedSeries: make(map[string]SeriesID)}
+	i := DBIngestor{db: inserter, cache: &mockCache{seriesCache: make(map[string]SeriesID)}, bounds: bounds}
+
+	ts := func(d time.Duration) int64 { return int64(model.TimeFromUnixNano(now.Add(d).UnixNano())) }
+	tts := []prompb.TimeSeries{
+		{
+			Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "mixed"}},
+			Samples: []prompb.Sample{{Timestamp: ts(-2 * time.Hour)}, {Timestamp: ts(-time.Hour)}, {Timestamp: ts(time.Minute)}, {Timestamp: ts(2 * time.Minute)}},
+		},
+		{
+			Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: "old"}},
+			Samples: []prompb.Sample{{Timestamp: ts(-3 * time.Hour)}},
+		},
+	}
+
+	tooOldBefore := testutil.ToFloat64(outOfBoundsSamples.WithLabelValues(rejectReasonTooOld))
+	tooNewBefore := testutil.ToFloat64(outOfBoundsSamples.WithLabelValues(rejectReasonTooNew))
+	count, err := i.Ingest(context.Background(), tts, NewWriteRequest())
+	var outOfBounds *SamplesOutOfBoundsError
+	if !errors.As(err, &outOfBounds) || outOfBounds.TooOld != 2 || outOfBounds.TooNew != 1 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("unexpected number of inserted samples: got %d wanted 2", count)
+	}
+	if n := testutil.ToFloat64(outOfBoundsSamples.WithLabelValues(rejectReasonTooOld)) - tooOldBefore; n != 2 {
+		t.Errorf("unexpected too old samples counted: %v", n)
+	}
+	if n := testutil.ToFloat64(outOfBoundsSamples.WithLabelValues(rejectReasonTooNew)) - tooNewBefore; n != 1 {
+		t.Errorf("unexpected too new samples counted: %v", n)
+	}
+
+	data := inserter.insertedData[0]
+	expected := []prompb.Sample{{Timestamp: ts(-time.Hour)}, {Timestamp: ts(time.Minute)}}
+	if _, ok := data["old"]; ok || len(data["mixed"]) != 1 || !reflect.DeepEqual(data["mixed"][0].samples, expected) {
+		t.Errorf("unexpected inserted data: %+v", data)
+	}
+}
@@ -0,0 +1,342 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	schemaVersionSQL = `SELECT
+		COALESCE((SELECT version FROM public.prom_schema_migrations LIMIT 1), 0),
+		COALESCE((SELECT dirty FROM public.prom_schema_migrations LIMIT 1), false),
+		COALESCE((SELECT value FROM _timescaledb_catalog.metadata WHERE key = 'version'), '')`
+	schemaListSQL = `SELECT value, key
+	FROM public.prom_installation_info
+	WHERE key LIKE '%schema'
+	ORDER BY value`
+	schemaRelationsSQL = `SELECT c.relname, c.relkind::text, COALESCE(obj_description(c.oid, 'pg_class'), '')
+	FROM pg_class c
+	INNER JOIN pg_namespace n ON (n.oid = c.relnamespace)
+	WHERE n.nspname = $1 AND c.relkind IN ('r', 'p', 'v', 'm') AND NOT c.relispartition
+	ORDER BY c.relname`
+	schemaColumnsSQL = `SELECT c.relname, a.attname, format_type(a.atttypid, a.atttypmod), NOT a.attnotnull, COALESCE(col_description(c.oid, a.attnum), '')
+	FROM pg_attribute a
+	INNER JOIN pg_class c ON (c.oid = a.attrelid)
+	INNER JOIN pg_namespace n ON (n.oid = c.relnamespace)
+	WHERE n.nspname = $1 AND c.relkind IN ('r', 'p', 'v', 'm') AND NOT c.relispartition AND a.attnum > 0 AND NOT a.attisdropped
+	ORDER BY c.relname, a.attnum`
+	schemaConstraintsSQL = `SELECT c.relname, con.conname, con.contype::text, pg_get_constraintdef(con.oid)
+	FROM pg_constraint con
+	INNER JOIN pg_class c ON (c.oid = con.conrelid)
+	INNER JOIN pg_namespace n ON (n.oid = c.relnamespace)
+	WHERE n.nspname = $1 AND con.contype IN ('p', 'u', 'f') AND NOT c.relispartition
+	ORDER BY c.relname, con.conname`
+	schemaFunctionsSQL = `SELECT p.proname, p.prokind::text, pg_get_function_identity_arguments(p.oid), COALESCE(pg_get_function_result(p.oid), ''), COALESCE(obj_description(p.oid, 'pg_proc'), '')
+	FROM pg_proc p
+	INNER JOIN pg_namespace n ON (n.oid = p.pronamespace)
+	WHERE n.nspname = $1
+	ORDER BY p.proname, 3`
+	metricObjectsSQL = `SELECT m.metric_name, m.table_name,
+		to_regclass(format('%I.%I', '` + dataSchema + `', m.table_name)) IS NOT NULL,
+		to_regclass(format('%I.%I', '` + dataSeriesSchema + `', m.table_name)) IS NOT NULL,
+		to_regclass(format('%I.%I', '` + metricViewSchema + `', m.table_name)) IS NOT NULL,
+		to_regclass(format('%I.%I', '` + seriesViewSchema + `', m.table_name)) IS NOT NULL
+	FROM ` + catalogSchema + `.metric m
+	ORDER BY m.metric_name`
+)
+
+// perMetricSchemas hold one object per metric. They are described once, in
+// the per-metric section, instead of listing every object.
+var perMetricSchemas = map[string]bool{
+	dataSchema:       true,
+	dataSeriesSchema: true,
+	metricViewSchema: true,
+	seriesViewSchema: true,
+}
+
+// SchemaDescription is the documentation of the installed Prometheus schema.
+type SchemaDescription struct {
+	MigrationVersion int64
+	Dirty            bool
+	Version          string
+	Schemas          []SchemaObjects
+	Metrics          []MetricObjects
+}
+
+// SchemaObjects lists the objects of one of the connector's schemas.
+type SchemaObjects struct {
+	Name      string
+	Purpose   string
+	Tables    []RelationDoc
+	Views     []RelationDoc
+	Functions []FunctionDoc
+}
+
+// RelationDoc describes a table or a view.
+type RelationDoc struct {
+	Name        string
+	Comment     string
+	Columns     []ColumnDoc
+	Constraints []ConstraintDoc
+}
+
+// ColumnDoc describes a column of a table or view.
+type ColumnDoc struct {
+	Name     string
+	Type     string
+	Nullable bool
+	Comment  string
+}
+
+// ConstraintDoc describes a primary key, unique or foreign key constraint.
+type ConstraintDoc struct {
+	Name       string
+	ForeignKey bool
+	Definition string
+}
+
+// FunctionDoc describes a function, procedure or aggregate.
+type FunctionDoc struct {
+	Name      string
+	Kind      string
+	Arguments string
+	Result    string
+	Comment   string
+}
+
+// MetricObjects lists the objects created for a single metric. A false
+// field means the object is missing, for example because the metric
+// creation was not finalized yet.
+type MetricObjects struct {
+	Metric      string
+	TableName   string
+	DataTable   bool
+	SeriesTable bool
+	MetricView  bool
+	SeriesView  bool
+}
+
+// SchemaDocReader introspects the installed schema for documentation.
+type SchemaDocReader struct {
+	conn pgxConn
+}
+
+// NewSchemaDocReader returns a new SchemaDocReader using the given connection pool.
+func NewSchemaDocReader(c *pgxpool.Pool) *SchemaDocReader {
+	return &SchemaDocReader{
+		conn: &pgxConnImpl{
+			conn: c,
+		},
+	}
+}
+
+// PerMetricSchemas returns the names of the schemas holding per-metric
+// objects, in the order the objects are reported in MetricObjects.
+func PerMetricSchemas() []string {
+	return []string{dataSchema, dataSeriesSchema, metricViewSchema, seriesViewSchema}
+}
+
+// Describe introspects the installed schema.
+func (r *SchemaDocReader) Describe() (*SchemaDescription, error) {
+	desc, err := r.version()
+	if err != nil {
+		return nil, err
+	}
+
+	schemas, err := r.schemas()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range schemas {
+		if perMetricSchemas[s.Name] {
+			continue
+		}
+		if err := r.describeSchema(&s); err != nil {
+			return nil, err
+		}
+		desc.Schemas = append(desc.Schemas, s)
+	}
+
+	desc.Metrics, err = r.metrics()
+	if err != nil {
+		return nil, err
+	}
+
+	return desc, nil
+}
+
+func (r *SchemaDocReader) version() (*SchemaDescription, error) {
+	rows, err := r.conn.Query(context.Background(), schemaVersionSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	desc := &SchemaDescription{}
+	if !rows.Next() {
+		return nil, fmt.Errorf("missing schema version")
+	}
+	if err := rows.Scan(&desc.MigrationVersion, &desc.Dirty, &desc.Version); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+func (r *SchemaDocReader) schemas() ([]SchemaObjects, error) {
+	rows, err := r.conn.Query(context.Background(), schemaListSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schemas := make([]SchemaObjects, 0)
+	for rows.Next() {
+		var s SchemaObjects
+		if err := rows.Scan(&s.Name, &s.Purpose); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, s)
+	}
+	return schemas, nil
+}
+
+func (r *SchemaDocReader) describeSchema(s *SchemaObjects) error {
+	relations, err := r.relations(s.Name)
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range relations {
+		if rel.kind == "v" || rel.kind == "m" {
+			s.Views = append(s.Views, rel.RelationDoc)
+		} else {
+			s.Tables = append(s.Tables, rel.RelationDoc)
+		}
+	}
+
+	s.Functions, err = r.functions(s.Name)
+	return err
+}
+
+type relation struct {
+	RelationDoc
+	kind string
+}
+
+func (r *SchemaDocReader) relations(schema string) ([]relation, error) {
+	rows, err := r.conn.Query(context.Background(), schemaRelationsSQL, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	relations := make([]relation, 0)
+	index := make(map[string]int)
+	for rows.Next() {
+		var rel relation
+		if err := rows.Scan(&rel.Name, &rel.kind, &rel.Comment); err != nil {
+			return nil, err
+		}
+		index[rel.Name] = len(relations)
+		relations = append(relations, rel)
+	}
+	rows.Close()
+
+	rows, err = r.conn.Query(context.Background(), schemaColumnsSQL, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			relName string
+			col     ColumnDoc
+		)
+		if err := rows.Scan(&relName, &col.Name, &col.Type, &col.Nullable, &col.Comment); err != nil {
+			return nil, err
+		}
+		if i, ok := index[relName]; ok {
+			relations[i].Columns = append(relations[i].Columns, col)
+		}
+	}
+	rows.Close()
+
+	rows, err = r.conn.Query(context.Background(), schemaConstraintsSQL, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			relName string
+			conType string
+			con     ConstraintDoc
+		)
+		if err := rows.Scan(&relName, &con.Name, &conType, &con.Definition); err != nil {
+			return nil, err
+		}
+		con.ForeignKey = conType == "f"
+		if i, ok := index[relName]; ok {
+			relations[i].Constraints = append(relations[i].Constraints, con)
+		}
+	}
+
+	return relations, nil
+}
+
+func (r *SchemaDocReader) functions(schema string) ([]FunctionDoc, error) {
+	rows, err := r.conn.Query(context.Background(), schemaFunctionsSQL, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	functions := make([]FunctionDoc, 0)
+	for rows.Next() {
+		var (
+			f    FunctionDoc
+			kind string
+		)
+		if err := rows.Scan(&f.Name, &kind, &f.Arguments, &f.Result, &f.Comment); err != nil {
+			return nil, err
+		}
+		switch kind {
+		case "p":
+			f.Kind = "procedure"
+		case "a":
+			f.Kind = "aggregate"
+		case "w":
+			f.Kind = "window function"
+		default:
+			f.Kind = "function"
+		}
+		functions = append(functions, f)
+	}
+	return functions, nil
+}
+
+func (r *SchemaDocReader) metrics() ([]MetricObjects, error) {
+	rows, err := r.conn.Query(context.Background(), metricObjectsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metrics := make([]MetricObjects, 0)
+	for rows.Next() {
+		var m MetricObjects
+		if err := rows.Scan(&m.Metric, &m.TableName, &m.DataTable, &m.SeriesTable, &m.MetricView, &m.SeriesView); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
@@ -0,0 +1,245 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSeriesCacheSize is the number of series cached when no size is
+// configured.
+const DefaultSeriesCacheSize = 500000
+
+type clockEntry struct {
+	// key is the full string of the labels, compared on lookups to rule out
+	// fingerprint collisions.
+	key         string
+	metric      string
+	fingerprint uint64
+	id          SeriesID
+	referenced  uint32
+	// expires is when the entry expires in Unix nanoseconds, 0 if the cache
+	// has no TTL.
+	expires int64
+}
+
+// SeriesCache is a size-bounded cache of series ids shared by all the insert
+// routines. It uses the CLOCK eviction algorithm, an LRU approximation where
+// a hit only sets a reference bit, so lookups only need the read lock.
+//
+// Series are indexed by the fingerprint of their labels. When two series
+// share a fingerprint, only the last one stored is cached.
+type SeriesCache struct {
+	lock    sync.RWMutex
+	index   map[uint64]int
+	entries []clockEntry
+	hand    int
+	maxSize int
+	// ttl is how long the series are cached for, 0 meaning until they are
+	// evicted.
+	ttl time.Duration
+}
+
+// NewSeriesCache returns a cache holding at most maxSize series. A
+// non-positive size selects DefaultSeriesCacheSize.
+func NewSeriesCache(maxSize int) *SeriesCache {
+	return NewSeriesCacheWithTTL(maxSize, 0)
+}
+
+// NewSeriesCacheWithTTL returns a cache holding at most maxSize series for at
+// most ttl, bounding how long a connector keeps using the id of a series
+// deleted without its cache being invalidated. A non-positive ttl caches the
+// series until they are evicted.
+func NewSeriesCacheWithTTL(maxSize int, ttl time.Duration) *SeriesCache {
+	if maxSize <= 0 {
+		maxSize = DefaultSeriesCacheSize
+	}
+	seriesCacheCapacity.Set(float64(maxSize))
+	interner.grow(maxSize)
+	return &SeriesCache{
+		index:   make(map[uint64]int),
+		entries: make([]clockEntry, 0),
+		maxSize: maxSize,
+		ttl:     ttl,
+	}
+}
+
+// GetSeries returns the id of the series, or ErrEntryNotFound.
+func (c *SeriesCache) GetSeries(lset Labels) (SeriesID, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	i, ok := c.index[lset.fingerprint]
+	if !ok || c.entries[i].key != lset.str {
+		seriesCacheMisses.Inc()
+		return 0, ErrEntryNotFound
+	}
+	// The expired entries are kept until they are set again or evicted.
+	if expires := c.entries[i].expires; expires != 0 && time.Now().UnixNano() > expires {
+		seriesCacheExpirations.Inc()
+		seriesCacheMisses.Inc()
+		return 0, ErrEntryNotFound
+	}
+	seriesCacheHits.Inc()
+	atomic.StoreUint32(&c.entries[i].referenced, 1)
+	return c.entries[i].id, nil
+}
+
+// SetSeries stores the id of the series, evicting a series which was not
+// used recently if the cache is full.
+func (c *SeriesCache) SetSeries(lset Labels, id SeriesID) error {
+	key := lset.str
+	var expires int64
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl).UnixNano()
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if i, ok := c.index[lset.fingerprint]; ok {
+		if c.entries[i].key != key {
+			seriesCacheCollisions.Inc()
+			c.entries[i].key = key
+			c.entries[i].metric = lset.metricName
+		}
+		c.entries[i].id = id
+		c.entries[i].expires = expires
+		atomic.StoreUint32(&c.entries[i].referenced, 1)
+		return nil
+	}
+
+	if len(c.entries) < c.maxSize {
+		c.index[lset.fingerprint] = len(c.entries)
+		c.entries = append(c.entries, clockEntry{key: key, metric: lset.metricName, fingerprint: lset.fingerprint, id: id, expires: expires})
+		seriesCacheEntries.Inc()
+		return nil
+	}
+
+	// Advance the hand, giving referenced entries a second chance, until
+	// an entry which was not used since the last sweep is found.
+	for atomic.LoadUint32(&c.entries[c.hand].referenced) == 1 {
+		atomic.StoreUint32(&c.entries[c.hand].referenced, 0)
+		c.hand = (c.hand + 1) % len(c.entries)
+	}
+
+	delete(c.index, c.entries[c.hand].fingerprint)
+	c.entries[c.hand] = clockEntry{key: key, metric: lset.metricName, fingerprint: lset.fingerprint, id: id, expires: expires}
+	c.index[lset.fingerprint] = c.hand
+	c.hand = (c.hand + 1) % len(c.entries)
+	seriesCacheEvictions.Inc()
+	return nil
+}
+
+// EvictSeries removes the series with the given ids from the cache, so that
+// deleted series are not written to with a stale id.
+func (c *SeriesCache) EvictSeries(ids []SeriesID) {
+	if len(ids) == 0 {
+		return
+	}
+	evicted := make(map[SeriesID]struct{}, len(ids))
+	for _, id := range ids {
+		evicted[id] = struct{}{}
+	}
+	c.evict(func(e *clockEntry) bool {
+		_, ok := evicted[e.id]
+		return ok
+	})
+}
+
+// EvictMetricSeries removes the series of metric from the cache, some of
+// which were deleted by another connector or by the retention.
+func (c *SeriesCache) EvictMetricSeries(metric string) {
+	c.evict(func(e *clockEntry) bool { return e.metric == metric })
+}
+
+// Reset removes all the series from the cache.
+func (c *SeriesCache) Reset() {
+	c.evict(func(*clockEntry) bool { return true })
+}
+
+// evict removes the entries for which evicted returns true.
+func (c *SeriesCache) evict(evicted func(*clockEntry) bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i := 0; i < len(c.entries); {
+		if !evicted(&c.entries[i]) {
+			i++
+			continue
+		}
+		// Move the last entry into the evicted one.
+		delete(c.index, c.entries[i].fingerprint)
+		last := len(c.entries) - 1
+		if i != last {
+			c.entries[i] = c.entries[last]
+			c.index[c.entries[i].fingerprint] = i
+		}
+		c.entries[last] = clockEntry{}
+		c.entries = c.entries[:last]
+		seriesCacheEntries.Dec()
+	}
+	if c.hand >= len(c.entries) {
+		c.hand = 0
+	}
+}
+
+// Len returns the number of series in the cache.
+func (c *SeriesCache) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return len(c.entries)
+}
+
+// SharedSeriesCache looks up the series ids in a local SeriesCache, then in a
+// SharedCache, so that the series created by any connector are found by all
+// of them.
+type SharedSeriesCache struct {
+	local  *SeriesCache
+	shared *SharedCache
+}
+
+// NewSharedSeriesCache returns a SharedSeriesCache in front of shared.
+func NewSharedSeriesCache(local *SeriesCache, shared *SharedCache) *SharedSeriesCache {
+	return &SharedSeriesCache{local: local, shared: shared}
+}
+
+// GetSeries returns the id of the series, or ErrEntryNotFound.
+func (c *SharedSeriesCache) GetSeries(lset Labels) (SeriesID, error) {
+	if id, err := c.local.GetSeries(lset); err == nil {
+		return id, nil
+	}
+	id, ok := c.shared.getSeries(lset)
+	if !ok {
+		return 0, ErrEntryNotFound
+	}
+	return id, c.local.SetSeries(lset, id)
+}
+
+// SetSeries stores the id of the series in both caches.
+func (c *SharedSeriesCache) SetSeries(lset Labels, id SeriesID) error {
+	c.shared.setSeries(lset, id)
+	return c.local.SetSeries(lset, id)
+}
+
+// EvictSeries removes the series from the local cache. The shared entries of
+// deleted series are invalidated by the catalog notifications, naming their
+// metric.
+func (c *SharedSeriesCache) EvictSeries(ids []SeriesID) {
+	c.local.EvictSeries(ids)
+}
+
+// EvictMetricSeries removes the series of metric from both caches.
+func (c *SharedSeriesCache) EvictMetricSeries(metric string) {
+	c.shared.evictMetricSeries(metric)
+	c.local.EvictMetricSeries(metric)
+}
+
+// Reset removes all the series from both caches.
+func (c *SharedSeriesCache) Reset() {
+	c.shared.evictMetricSeries("")
+	c.local.Reset()
+}
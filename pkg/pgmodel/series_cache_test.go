@@ -0,0 +1,256 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+func TestSeriesCache(t *testing.T) {
+	cache := NewSeriesCache(0)
+
+	label := labels.Labels{
+		labels.Label{
+			Name:  "name1",
+			Value: "val1",
+		},
+	}
+
+	l, err := LabelsFromSlice(label)
+	if err != nil {
+		t.Errorf("invalid labels %+v: %v", l, err)
+	}
+	if _, err := cache.GetSeries(*l); err == nil {
+		t.Errorf("found cache for a series that was not stored")
+	}
+
+	testLabels := []labels.Labels{
+		{
+			labels.Label{
+				Name:  "name1",
+				Value: "val1",
+			},
+		},
+		{
+			labels.Label{
+				Name:  "name1",
+				Value: "val2",
+			},
+		},
+		{
+			labels.Label{
+				Name:  "name2",
+				Value: "val2",
+			},
+		},
+		{
+			labels.Label{
+				Name:  "name1",
+				Value: "val1",
+			},
+			labels.Label{
+				Name:  "name2",
+				Value: "val2",
+			},
+		},
+	}
+
+	for i, series := range testLabels {
+		ls, err := LabelsFromSlice(series)
+		if err != nil {
+			t.Errorf("invalid series %+v, %v", ls, err)
+		}
+		if err := cache.SetSeries(*ls, SeriesID(i)); err != nil {
+			t.Errorf("got unexpected error while storing series: %d", i)
+
+		}
+	}
+
+	for i, series := range testLabels {
+		var res SeriesID
+		ls, err := LabelsFromSlice(series)
+		if err != nil {
+			t.Errorf("invalid series %+v, %v", ls, err)
+		}
+		if res, err = cache.GetSeries(*ls); err != nil {
+			t.Errorf("got unexpected error while getting series: %v", series)
+		}
+		if res != SeriesID(i) {
+			t.Errorf("wrong id returned: got %v expected %v", res, i)
+		}
+	}
+
+	if cache.Len() != len(testLabels) {
+		t.Errorf("wrong cache length: got %d expected %d", cache.Len(), len(testLabels))
+	}
+}
+
+func TestSeriesCacheEviction(t *testing.T) {
+	series := make([]*Labels, 4)
+	for i := range series {
+		l, err := LabelsFromSlice(labels.Labels{{Name: "name", Value: fmt.Sprint(i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		series[i] = l
+	}
+
+	cache := NewSeriesCache(3)
+	for i := 0; i < 3; i++ {
+		if err := cache.SetSeries(*series[i], SeriesID(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Series 0 and 2 were used recently, so series 1 is evicted.
+	for _, i := range []int{0, 2} {
+		if _, err := cache.GetSeries(*series[i]); err != nil {
+			t.Fatalf("series %d not found: %v", i, err)
+		}
+	}
+	if err := cache.SetSeries(*series[3], 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if cache.Len() != 3 {
+		t.Errorf("cache grew over its size: got %d expected 3", cache.Len())
+	}
+	if _, err := cache.GetSeries(*series[1]); err != ErrEntryNotFound {
+		t.Errorf("expected series 1 to be evicted, got %v", err)
+	}
+	for _, i := range []int{0, 2, 3} {
+		id, err := cache.GetSeries(*series[i])
+		if err != nil || id != SeriesID(i) {
+			t.Errorf("unexpected id for series %d: got %d, %v", i, id, err)
+		}
+	}
+}
+
+func TestSeriesCacheFingerprintCollision(t *testing.T) {
+	a := Labels{str: "a", fingerprint: 1}
+	b := Labels{str: "b", fingerprint: 1}
+	if a.Equal(&b) || a.Compare(&b) >= 0 {
+		t.Fatal("colliding labels considered equal")
+	}
+
+	cache := NewSeriesCache(0)
+	if err := cache.SetSeries(a, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.GetSeries(b); err != ErrEntryNotFound {
+		t.Errorf("colliding series found: %v", err)
+	}
+
+	collisions := testutil.ToFloat64(seriesCacheCollisions)
+	if err := cache.SetSeries(b, 2); err != nil {
+		t.Fatal(err)
+	}
+	if testutil.ToFloat64(seriesCacheCollisions) != collisions+1 {
+		t.Error("collision not counted")
+	}
+	if id, err := cache.GetSeries(b); err != nil || id != 2 {
+		t.Errorf("unexpected id for the last series stored: %d, %v", id, err)
+	}
+	if _, err := cache.GetSeries(a); err != ErrEntryNotFound {
+		t.Errorf("replaced series found: %v", err)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("unexpected cache length: %d", cache.Len())
+	}
+}
+
+func TestSeriesCacheEvictSeries(t *testing.T) {
+	series := make([]*Labels, 4)
+	cache := NewSeriesCache(0)
+	for i := range series {
+		l, err := LabelsFromSlice(labels.Labels{{Name: "name", Value: fmt.Sprint(i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		series[i] = l
+		if err := cache.SetSeries(*l, SeriesID(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache.EvictSeries([]SeriesID{0, 3, 42})
+	if cache.Len() != 2 {
+		t.Errorf("unexpected cache length: %d", cache.Len())
+	}
+	for i, l := range series {
+		id, err := cache.GetSeries(*l)
+		evicted := i == 0 || i == 3
+		if evicted && err != ErrEntryNotFound {
+			t.Errorf("series %d not evicted: %v", i, err)
+		}
+		if !evicted && (err != nil || id != SeriesID(i)) {
+			t.Errorf("unexpected id for series %d: got %d, %v", i, id, err)
+		}
+	}
+}
+
+func TestSeriesCacheEvictMetricSeries(t *testing.T) {
+	cache := NewSeriesCache(0)
+	series := make([]*Labels, 4)
+	for i := range series {
+		l, err := LabelsFromSlice(labels.Labels{
+			{Name: MetricNameLabelName, Value: fmt.Sprint("metric_", i%2)},
+			{Name: "name", Value: fmt.Sprint(i)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		series[i] = l
+		if err := cache.SetSeries(*l, SeriesID(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache.EvictMetricSeries("metric_1")
+	for i, l := range series {
+		_, err := cache.GetSeries(*l)
+		if evicted := i%2 == 1; evicted != (err == ErrEntryNotFound) {
+			t.Errorf("series %d: unexpected eviction %v", i, err)
+		}
+	}
+
+	cache.Reset()
+	if cache.Len() != 0 {
+		t.Errorf("unexpected cache length after reset: %d", cache.Len())
+	}
+}
+
+func TestSeriesCacheTTL(t *testing.T) {
+	cache := NewSeriesCacheWithTTL(0, time.Hour)
+	l, err := LabelsFromSlice(labels.Labels{{Name: MetricNameLabelName, Value: "foo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cache.SetSeries(*l, 1); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := cache.GetSeries(*l); err != nil || id != 1 {
+		t.Fatalf("unexpected series id %d, %v", id, err)
+	}
+
+	expirations := testutil.ToFloat64(seriesCacheExpirations)
+	cache.entries[0].expires = time.Now().Add(-time.Second).UnixNano()
+	if _, err = cache.GetSeries(*l); err != ErrEntryNotFound {
+		t.Fatalf("unexpected error %v for an expired series", err)
+	}
+	if testutil.ToFloat64(seriesCacheExpirations) != expirations+1 {
+		t.Error("expiration not counted")
+	}
+	if err = cache.SetSeries(*l, 2); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := cache.GetSeries(*l); err != nil || id != 2 {
+		t.Fatalf("unexpected series id %d, %v after setting it again", id, err)
+	}
+}
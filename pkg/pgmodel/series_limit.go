@@ -0,0 +1,141 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSeriesActiveWindow is how long a series counts against the
+	// limit of its metric after its last sample, unless configured.
+	DefaultSeriesActiveWindow = time.Hour
+
+	// seriesLimitSweepInterval bounds how often the series of a metric at
+	// its limit are scanned for the inactive ones.
+	seriesLimitSweepInterval = time.Minute
+)
+
+// SeriesLimitConfig limits the number of active series of every metric, so
+// that a cardinality explosion is stopped at ingest instead of bloating the
+// database.
+type SeriesLimitConfig struct {
+	// MaxSeriesPerMetric is the number of active series a metric may have.
+	// The samples of the series over it are dropped. 0 disables the limit.
+	MaxSeriesPerMetric int
+	// ActiveWindow is how long a series stays active after its last sample.
+	// 0 selects DefaultSeriesActiveWindow.
+	ActiveWindow time.Duration
+}
+
+// seriesLimiter enforces a SeriesLimitConfig. A nil *seriesLimiter accepts
+// every series. The series are only known since startup, so a restart lets
+// as many series in as the limit allows again.
+type seriesLimiter struct {
+	max    int
+	window time.Duration
+	now    func() time.Time
+
+	lock    sync.Mutex
+	metrics map[string]*activeSeries
+}
+
+// activeSeries tracks the active series of a metric by fingerprint. Series
+// sharing a fingerprint count as one.
+type activeSeries struct {
+	lock      sync.Mutex
+	lastSeen  map[uint64]int64
+	lastSweep int64
+}
+
+func newSeriesLimiter(cfg SeriesLimitConfig) (*seriesLimiter, error) {
+	if cfg.MaxSeriesPerMetric < 0 || cfg.ActiveWindow < 0 {
+		return nil, fmt.Errorf("invalid series limit: max series %d, active window %v", cfg.MaxSeriesPerMetric, cfg.ActiveWindow)
+	}
+	if cfg.MaxSeriesPerMetric == 0 {
+		return nil, nil
+	}
+	window := cfg.ActiveWindow
+	if window == 0 {
+		window = DefaultSeriesActiveWindow
+	}
+	return &seriesLimiter{
+		max:     cfg.MaxSeriesPerMetric,
+		window:  window,
+		now:     time.Now,
+		metrics: make(map[string]*activeSeries),
+	}, nil
+}
+
+func (l *seriesLimiter) activeSeries(metric string) *activeSeries {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	active, ok := l.metrics[metric]
+	if !ok {
+		active = &activeSeries{lastSeen: make(map[uint64]int64)}
+		l.metrics[metric] = active
+	}
+	return active
+}
+
+// enforce removes from data the series that would take a metric over its
+// limit, along with the metrics left without series. It returns the number
+// of samples dropped.
+func (l *seriesLimiter) enforce(data map[string][]samplesInfo) int {
+	if l == nil {
+		return 0
+	}
+	now := l.now().UnixNano()
+	dropped := 0
+	var limited []string
+	for metric, series := range data {
+		active := l.activeSeries(metric)
+		active.lock.Lock()
+		keptSeries := series[:0]
+		for _, s := range series {
+			if active.admit(s.labels.fingerprint, now, l.max, l.window) {
+				keptSeries = append(keptSeries, s)
+				continue
+			}
+			droppedSeriesOverLimit.WithLabelValues(metric).Inc()
+			dropped += len(s.samples)
+		}
+		active.lock.Unlock()
+		if len(keptSeries) < len(series) {
+			limited = append(limited, metric)
+		}
+
+		if len(keptSeries) == 0 {
+			delete(data, metric)
+			continue
+		}
+		data[metric] = keptSeries
+	}
+	emitDataLoss(dataLossSeriesLimit, uint64(dropped), limited)
+	return dropped
+}
+
+// admit marks the series active, unless it is new and the metric is at its
+// limit even after forgetting the inactive series.
+func (a *activeSeries) admit(fingerprint uint64, now int64, max int, window time.Duration) bool {
+	if _, ok := a.lastSeen[fingerprint]; !ok && len(a.lastSeen) >= max {
+		if now-a.lastSweep < int64(seriesLimitSweepInterval) {
+			return false
+		}
+		a.lastSweep = now
+		for fp, seen := range a.lastSeen {
+			if now-seen > int64(window) {
+				delete(a.lastSeen, fp)
+			}
+		}
+		if len(a.lastSeen) >= max {
+			return false
+		}
+	}
+	a.lastSeen[fingerprint] = now
+	return true
+}
@@ -0,0 +1,94 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestNewSeriesLimiter(t *testing.T) {
+	if l, err := newSeriesLimiter(SeriesLimitConfig{}); l != nil || err != nil {
+		t.Errorf("unexpected limiter without limit: %v, %v", l, err)
+	}
+	if _, err := newSeriesLimiter(SeriesLimitConfig{MaxSeriesPerMetric: -1}); err == nil {
+		t.Error("negative limit accepted")
+	}
+	l, err := newSeriesLimiter(SeriesLimitConfig{MaxSeriesPerMetric: 1})
+	if err != nil || l.window != DefaultSeriesActiveWindow {
+		t.Errorf("unexpected limiter: %+v, %v", l, err)
+	}
+	var nilLimiter *seriesLimiter
+	if dropped := nilLimiter.enforce(map[string][]samplesInfo{"metric": {{samples: []prompb.Sample{{}}}}}); dropped != 0 {
+		t.Errorf("nil limiter dropped %d samples", dropped)
+	}
+}
+
+func seriesOf(metric string, n int) []prompb.TimeSeries {
+	tts := make([]prompb.TimeSeries, n)
+	for i := range tts {
+		tts[i] = prompb.TimeSeries{
+			Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: metric}, {Name: "id", Value: fmt.Sprint(i)}},
+			Samples: []prompb.Sample{{Timestamp: 1}, {Timestamp: 2}},
+		}
+	}
+	return tts
+}
+
+func TestIngestSeriesLimit(t *testing.T) {
+	now := time.Unix(10000, 0)
+	limiter, err := newSeriesLimiter(SeriesLimitConfig{MaxSeriesPerMetric: 2, ActiveWindow: 10 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter.now = func() time.Time { return now }
+	inserter := &mockInserter{insertedSeries: make(map[string]SeriesID)}
+	i := DBIngestor{db: inserter, cache: &mockCache{seriesCache: make(map[string]SeriesID)}, seriesLimit: limiter}
+
+	ingest := func(tts []prompb.TimeSeries) map[string][]samplesInfo {
+		t.Helper()
+		inserter.insertedData = nil
+		count, err := i.Ingest(context.Background(), tts, NewWriteRequest())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != uint64(2*len(tts)) {
+			t.Errorf("unexpected acknowledged samples: got %d wanted %d", count, 2*len(tts))
+		}
+		if len(inserter.insertedData) == 0 {
+			return nil
+		}
+		return inserter.insertedData[0]
+	}
+
+	droppedBefore := testutil.ToFloat64(droppedSeriesOverLimit.WithLabelValues("limited"))
+	data := ingest(append(seriesOf("limited", 3), seriesOf("other", 2)...))
+	if len(data["limited"]) != 2 || len(data["other"]) != 2 {
+		t.Fatalf("unexpected inserted series: %+v", data)
+	}
+	if testutil.ToFloat64(droppedSeriesOverLimit.WithLabelValues("limited")) != droppedBefore+1 {
+		t.Error("dropped series not counted")
+	}
+
+	// Known series keep being accepted at the limit.
+	now = now.Add(5 * time.Minute)
+	if data = ingest(seriesOf("limited", 1)); len(data["limited"]) != 1 {
+		t.Errorf("active series dropped: %+v", data)
+	}
+
+	// Series 1 becomes inactive, making room for a new one.
+	now = now.Add(6 * time.Minute)
+	if data = ingest(seriesOf("limited", 3)[2:]); len(data["limited"]) != 1 {
+		t.Errorf("new series not admitted after series 1 expired: %+v", data)
+	}
+	if data = ingest(seriesOf("limited", 2)[1:]); len(data) != 0 {
+		t.Errorf("series over the limit admitted: %+v", data)
+	}
+}
@@ -0,0 +1,364 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	// DefaultRelabelBatchDuration is the default time range of the samples
+	// moved at once to the relabeled series.
+	DefaultRelabelBatchDuration = 24 * time.Hour
+
+	relabelSeriesBatch = 1000
+
+	relabelSeriesSQLFormat = `SELECT s.id, (key_value_array(s.labels)).* FROM %s s
+	WHERE s.id = ANY($1) ORDER BY s.id`
+	countRelabelSamplesSQLFormat = `SELECT count(*) FROM %s WHERE series_id = ANY($1)`
+	// The range covers both the samples and the native histograms.
+	relabelRangeSQLFormat = `SELECT min(r.first_time), max(r.last_time) FROM (
+		SELECT min(time) AS first_time, max(time) AS last_time FROM %s WHERE series_id = ANY($1)
+		UNION ALL
+		SELECT min(time), max(time) FROM ` + catalogSchema + `.histogram WHERE series_id = ANY($1)
+	) r HAVING count(r.first_time) > 0`
+
+	// The moves take the pairs of old and new series ids as two arrays, and
+	// the time range moved.
+	relabelPairsSQL          = `unnest($1::bigint[], $2::bigint[]) AS m(old_id, new_id)`
+	moveRelabelSamplesFormat = `WITH moved AS (
+		DELETE FROM %[1]s d
+		USING ` + relabelPairsSQL + `
+		WHERE d.series_id = m.old_id AND d.time >= $3 AND d.time < $4
+		RETURNING d.time, d.value, m.new_id
+	)
+	INSERT INTO %[1]s(time, value, series_id) SELECT time, value, new_id FROM moved`
+	// The histograms already stored for the new series at the same time
+	// are kept, as they are when a write is retried.
+	moveRelabelHistogramsSQL = `WITH moved AS (
+		DELETE FROM ` + catalogSchema + `.histogram h
+		USING ` + relabelPairsSQL + `
+		WHERE h.series_id = m.old_id AND h.time >= $3 AND h.time < $4
+		RETURNING h.time, m.new_id, h.count, h.sum, h.schema, h.zero_threshold, h.zero_count,
+		h.negative_span_offsets, h.negative_span_lengths, h.negative_counts, h.positive_span_offsets,
+		h.positive_span_lengths, h.positive_counts, h.custom_values, h.reset_hint, h.float_counts
+	)
+	INSERT INTO ` + catalogSchema + `.histogram(time, series_id, count, sum, schema, zero_threshold, zero_count,
+	negative_span_offsets, negative_span_lengths, negative_counts, positive_span_offsets, positive_span_lengths, positive_counts,
+	custom_values, reset_hint, float_counts)
+	SELECT * FROM moved
+	ON CONFLICT DO NOTHING`
+	moveRelabelRollupsSQLFormat = `UPDATE %s r SET series_id = m.new_id
+	FROM ` + relabelPairsSQL + `
+	WHERE r.series_id = m.old_id`
+)
+
+// SeriesRelabelConfig configures a rewrite of the labels of stored series.
+type SeriesRelabelConfig struct {
+	// Selectors match the series rewritten, like the match[] parameters of
+	// the Prometheus APIs.
+	Selectors [][]*prompb.LabelMatcher
+	// Set maps the names of the labels rewritten to their new value. An
+	// empty value removes the label.
+	Set map[string]string
+	// BatchDuration is the time range of the samples moved at once. 0
+	// selects DefaultRelabelBatchDuration.
+	BatchDuration time.Duration
+	// DryRun only reports the rewrites, without changing anything.
+	DryRun bool
+	// Progress, if set, is called after every batch moved.
+	Progress func(SeriesRelabelProgress)
+}
+
+// SeriesRelabelProgress reports how far the samples of a metric were moved.
+type SeriesRelabelProgress struct {
+	Metric string
+	// Through is the time up to which the samples were moved, out of the
+	// range of the samples from Start to End.
+	Through    time.Time
+	Start, End time.Time
+	Samples    int64
+}
+
+// SeriesRewrite is the rewrite of the labels of a series.
+type SeriesRewrite struct {
+	From []prompb.Label `json:"from"`
+	To   []prompb.Label `json:"to"`
+}
+
+// SeriesRelabeling reports the series of a metric rewritten.
+type SeriesRelabeling struct {
+	Metric   string          `json:"metric"`
+	Rewrites []SeriesRewrite `json:"rewrites"`
+	// Samples is the number of samples moved, or to be moved by a dry run.
+	Samples    int64 `json:"samples"`
+	Histograms int64 `json:"histograms"`
+	// DeletedSeries is the number of old series deleted from the catalog
+	// once their samples were moved.
+	DeletedSeries int64 `json:"deleted_series"`
+}
+
+// SeriesRelabeler rewrites the labels of stored series, such as to fix a
+// typo in a label value across their history. The series with the new
+// labels are created, or merged with those already stored, the samples are
+// moved to them in batches and the old series are deleted from the catalog.
+//
+// Every batch is moved by a single statement, so an interrupted rewrite is
+// resumed by running it again: the old series still match the selectors,
+// with the samples left to move.
+type SeriesRelabeler struct {
+	conn pgxConn
+}
+
+// NewSeriesRelabeler returns a SeriesRelabeler using the connection pool.
+func NewSeriesRelabeler(c *pgxpool.Pool) *SeriesRelabeler {
+	return &SeriesRelabeler{
+		conn: &pgxConnImpl{
+			conn: c,
+		},
+	}
+}
+
+// Relabel rewrites the labels of the series matching the selectors. The
+// rewrites are reported per metric, ordered by metric name.
+func (r *SeriesRelabeler) Relabel(ctx context.Context, cfg SeriesRelabelConfig) ([]SeriesRelabeling, error) {
+	if len(cfg.Set) == 0 {
+		return nil, fmt.Errorf("no label to rewrite")
+	}
+	if _, ok := cfg.Set[MetricNameLabelName]; ok {
+		return nil, fmt.Errorf("the %s label cannot be rewritten", MetricNameLabelName)
+	}
+	if cfg.BatchDuration <= 0 {
+		cfg.BatchDuration = DefaultRelabelBatchDuration
+	}
+
+	seriesPerMetric, err := matchingSeries(ctx, r.conn, cfg.Selectors)
+	if err != nil {
+		return nil, err
+	}
+	metrics := make([]string, 0, len(seriesPerMetric))
+	for metric := range seriesPerMetric {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	relabelings := make([]SeriesRelabeling, 0, len(metrics))
+	for _, metric := range metrics {
+		ids := make([]int64, 0, len(seriesPerMetric[metric]))
+		for id := range seriesPerMetric[metric] {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		relabeling, err := r.relabelMetric(ctx, cfg, metric, ids)
+		if err != nil {
+			return relabelings, fmt.Errorf("relabeling series of metric %s: %w", metric, err)
+		}
+		if !cfg.DryRun && len(relabeling.Rewrites) > 0 {
+			ingestLogger.Info("msg", "Relabeled series", "metric", metric, "series", len(relabeling.Rewrites),
+				"samples", relabeling.Samples, "histograms", relabeling.Histograms, "deleted_series", relabeling.DeletedSeries)
+		}
+		relabelings = append(relabelings, relabeling)
+	}
+	return relabelings, nil
+}
+
+func (r *SeriesRelabeler) relabelMetric(ctx context.Context, cfg SeriesRelabelConfig, metric string, ids []int64) (SeriesRelabeling, error) {
+	relabeling := SeriesRelabeling{Metric: metric}
+	table, err := metricTableName(ctx, r.conn, metric)
+	if err != nil {
+		return relabeling, err
+	}
+	dataTable := pgx.Identifier{dataSchema, table}.Sanitize()
+	seriesTable := pgx.Identifier{dataSeriesSchema, table}.Sanitize()
+
+	oldIDs, err := r.rewrites(ctx, cfg.Set, seriesTable, ids, &relabeling)
+	if err != nil || len(oldIDs) == 0 {
+		return relabeling, err
+	}
+	if cfg.DryRun {
+		err = queryCount(ctx, r.conn, &relabeling.Samples, fmt.Sprintf(countRelabelSamplesSQLFormat, dataTable), oldIDs)
+		return relabeling, err
+	}
+
+	newIDs, err := r.newSeriesIDs(ctx, metric, relabeling.Rewrites)
+	if err != nil {
+		return relabeling, err
+	}
+	if err = r.move(ctx, cfg, metric, table, oldIDs, newIDs, &relabeling); err != nil {
+		return relabeling, err
+	}
+
+	exists, err := rollupTableExists(ctx, r.conn, table)
+	if err != nil {
+		return relabeling, err
+	}
+	if exists {
+		rollupTable := pgx.Identifier{rollupSchema, table}.Sanitize()
+		if _, err = r.conn.Exec(ctx, fmt.Sprintf(moveRelabelRollupsSQLFormat, rollupTable), oldIDs, newIDs); err != nil {
+			return relabeling, err
+		}
+	}
+
+	deleted, err := querySeriesIDs(ctx, r.conn,
+		fmt.Sprintf(deleteOrphanedSeriesSQLFormat, seriesTable, dataTable, "", ""),
+		oldIDs)
+	relabeling.DeletedSeries = int64(len(deleted))
+	return relabeling, err
+}
+
+// rewrites reads the labels of the series and adds the rewrite of those
+// whose labels change to relabeling. It returns their ids.
+func (r *SeriesRelabeler) rewrites(ctx context.Context, set map[string]string, seriesTable string, ids []int64, relabeling *SeriesRelabeling) ([]int64, error) {
+	rows, err := r.conn.Query(ctx, fmt.Sprintf(relabelSeriesSQLFormat, seriesTable), ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var oldIDs []int64
+	for rows.Next() {
+		var (
+			id           int64
+			keys, values []string
+		)
+		if err = rows.Scan(&id, &keys, &values); err != nil {
+			return nil, err
+		}
+		from := make([]prompb.Label, len(keys))
+		for i := range keys {
+			from[i] = prompb.Label{Name: keys[i], Value: values[i]}
+		}
+		sort.Slice(from, func(i, j int) bool { return from[i].Name < from[j].Name })
+		to := rewriteLabels(from, set)
+		if sameLabels(from, to) {
+			continue
+		}
+		oldIDs = append(oldIDs, id)
+		relabeling.Rewrites = append(relabeling.Rewrites, SeriesRewrite{From: from, To: to})
+	}
+	return oldIDs, rows.Err()
+}
+
+// rewriteLabels returns the labels, sorted by name, with the values of set.
+func rewriteLabels(labels []prompb.Label, set map[string]string) []prompb.Label {
+	res := make([]prompb.Label, 0, len(labels)+len(set))
+	for _, l := range labels {
+		if _, ok := set[l.Name]; !ok {
+			res = append(res, l)
+		}
+	}
+	for name, value := range set {
+		if value != "" {
+			res = append(res, prompb.Label{Name: name, Value: value})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+func sameLabels(a, b []prompb.Label) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Value != b[i].Value {
+			return false
+		}
+	}
+	return true
+}
+
+// newSeriesIDs returns the ids of the series with the rewritten labels,
+// creating those missing.
+func (r *SeriesRelabeler) newSeriesIDs(ctx context.Context, metric string, rewrites []SeriesRewrite) ([]int64, error) {
+	ids := make([]int64, 0, len(rewrites))
+	for start := 0; start < len(rewrites); start += relabelSeriesBatch {
+		end := start + relabelSeriesBatch
+		if end > len(rewrites) {
+			end = len(rewrites)
+		}
+		var (
+			keys, values []string
+			counts       = make([]int32, 0, end-start)
+		)
+		for _, rewrite := range rewrites[start:end] {
+			for _, l := range rewrite.To {
+				keys = append(keys, l.Name)
+				values = append(values, l.Value)
+			}
+			counts = append(counts, int32(len(rewrite.To)))
+		}
+		batch, err := seriesIDsForLabels(ctx, r.conn, metric, keys, values, counts)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) != len(counts) {
+			return nil, fmt.Errorf("got %d series ids for %d series", len(batch), len(counts))
+		}
+		ids = append(ids, batch...)
+	}
+	return ids, nil
+}
+
+// move moves the samples and the native histograms of the old series to the
+// new ones, one batch of cfg.BatchDuration at a time.
+func (r *SeriesRelabeler) move(ctx context.Context, cfg SeriesRelabelConfig, metric, table string, oldIDs, newIDs []int64, relabeling *SeriesRelabeling) error {
+	dataTable := pgx.Identifier{dataSchema, table}.Sanitize()
+	rows, err := r.conn.Query(ctx, fmt.Sprintf(relabelRangeSQLFormat, dataTable), oldIDs)
+	if err != nil {
+		return err
+	}
+	var start, end time.Time
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&start, &end)
+	}
+	rows.Close()
+	if err != nil || !found {
+		return err
+	}
+
+	moveSamples := fmt.Sprintf(moveRelabelSamplesFormat, dataTable)
+	for from := start; !from.After(end); from = from.Add(cfg.BatchDuration) {
+		to := from.Add(cfg.BatchDuration)
+		tag, err := r.conn.Exec(ctx, moveSamples, oldIDs, newIDs, from, to)
+		if pgErr, ok := err.(*pgconn.PgError); ok && strings.Contains(pgErr.Message, "insert/update/delete not permitted") {
+			// The batch covers compressed chunks, decompress and try again.
+			ingestLogger.Warn("msg", fmt.Sprintf("Table %s was compressed, decompressing", table), "table", table)
+			if _, decompressErr := r.conn.Exec(ctx, "CALL "+catalogSchema+".decompress_chunks_after($1, $2);", table, from); decompressErr != nil {
+				return err
+			}
+			tag, err = r.conn.Exec(ctx, moveSamples, oldIDs, newIDs, from, to)
+		}
+		if err != nil {
+			return err
+		}
+		relabeling.Samples += tag.RowsAffected()
+
+		if tag, err = r.conn.Exec(ctx, moveRelabelHistogramsSQL, oldIDs, newIDs, from, to); err != nil {
+			return err
+		}
+		relabeling.Histograms += tag.RowsAffected()
+
+		if cfg.Progress != nil {
+			through := to
+			if through.After(end) {
+				through = end
+			}
+			cfg.Progress(SeriesRelabelProgress{Metric: metric, Through: through, Start: start, End: end, Samples: relabeling.Samples})
+		}
+	}
+	return nil
+}
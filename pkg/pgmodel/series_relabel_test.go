@@ -0,0 +1,119 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestSeriesRelabel(t *testing.T) {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	selectors := [][]*prompb.LabelMatcher{
+		{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "cpu"}},
+	}
+	start := time.Unix(3600, 0)
+	end := start.Add(90 * time.Minute)
+	seriesResults := []rowResults{
+		{{"cpu", []int64{2, 1}}},
+		{{"cpu_table"}},
+		{
+			{int64(1), []string{"__name__", "job", "mode"}, []string{"cpu", "apu", "idle"}},
+			{int64(2), []string{"__name__", "job"}, []string{"cpu", "api"}},
+		},
+	}
+	rewrite := SeriesRewrite{
+		From: []prompb.Label{{Name: "__name__", Value: "cpu"}, {Name: "job", Value: "apu"}, {Name: "mode", Value: "idle"}},
+		To:   []prompb.Label{{Name: "__name__", Value: "cpu"}, {Name: "job", Value: "api"}},
+	}
+	cfg := SeriesRelabelConfig{
+		Selectors:     selectors,
+		Set:           map[string]string{"job": "api", "mode": ""},
+		BatchDuration: time.Hour,
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		mock := &mockPGXConn{QueryResults: append(seriesResults, rowResults{{int64(7)}})}
+		cfg := cfg
+		cfg.DryRun = true
+		relabelings, err := (&SeriesRelabeler{conn: mock}).Relabel(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []SeriesRelabeling{{Metric: "cpu", Rewrites: []SeriesRewrite{rewrite}, Samples: 7}}
+		if !reflect.DeepEqual(relabelings, expected) {
+			t.Errorf("unexpected relabelings:\ngot    %+v\nwanted %+v", relabelings, expected)
+		}
+		if len(mock.ExecSQLs) != 0 {
+			t.Errorf("unexpected statements: %v", mock.ExecSQLs)
+		}
+		if !reflect.DeepEqual(mock.QueryArgs[3], []interface{}{[]int64{1}}) {
+			t.Errorf("unexpected counted series: %v", mock.QueryArgs[3])
+		}
+	})
+
+	t.Run("relabel", func(t *testing.T) {
+		mock := &mockPGXConn{
+			QueryResults: append(seriesResults,
+				rowResults{{"cpu", int64(5)}},
+				rowResults{{start, end}},
+				rowResults{{true}},
+				rowResults{{[]int64{1}}},
+			),
+			ExecResult: pgconn.CommandTag("INSERT 0 3"),
+		}
+		var progress []SeriesRelabelProgress
+		cfg := cfg
+		cfg.Progress = func(p SeriesRelabelProgress) { progress = append(progress, p) }
+		relabelings, err := (&SeriesRelabeler{conn: mock}).Relabel(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []SeriesRelabeling{{Metric: "cpu", Rewrites: []SeriesRewrite{rewrite}, Samples: 6, Histograms: 6, DeletedSeries: 1}}
+		if !reflect.DeepEqual(relabelings, expected) {
+			t.Errorf("unexpected relabelings:\ngot    %+v\nwanted %+v", relabelings, expected)
+		}
+
+		expectedArgs := []interface{}{"cpu", []string{"__name__", "job"}, []string{"cpu", "api"}, []int32{2}}
+		if !reflect.DeepEqual(mock.QueryArgs[3], expectedArgs) {
+			t.Errorf("unexpected new series:\ngot    %v\nwanted %v", mock.QueryArgs[3], expectedArgs)
+		}
+		if len(mock.ExecSQLs) != 5 {
+			t.Fatalf("unexpected statements: %v", mock.ExecSQLs)
+		}
+		for i, prefix := range []string{"WITH moved AS (\n\t\tDELETE FROM \"prom_data\".\"cpu_table\"", "WITH moved AS (\n\t\tDELETE FROM _prom_catalog.histogram"} {
+			if !strings.HasPrefix(mock.ExecSQLs[i], prefix) {
+				t.Errorf("unexpected move: %q", mock.ExecSQLs[i])
+			}
+		}
+		if !reflect.DeepEqual(mock.ExecArgs[2], []interface{}{[]int64{1}, []int64{5}, start.Add(time.Hour), start.Add(2 * time.Hour)}) {
+			t.Errorf("unexpected second batch: %v", mock.ExecArgs[2])
+		}
+		if !strings.HasPrefix(mock.ExecSQLs[4], `UPDATE "prom_rollup"."cpu_table"`) {
+			t.Errorf("rollups not moved: %q", mock.ExecSQLs[4])
+		}
+		if len(progress) != 2 || !progress[0].Through.Equal(start.Add(time.Hour)) || !progress[1].Through.Equal(end) || progress[1].Samples != 6 {
+			t.Errorf("unexpected progress: %+v", progress)
+		}
+	})
+}
+
+func TestSeriesRelabelInvalid(t *testing.T) {
+	r := &SeriesRelabeler{conn: &mockPGXConn{}}
+	for _, set := range []map[string]string{nil, {MetricNameLabelName: "other"}} {
+		if _, err := r.Relabel(context.Background(), SeriesRelabelConfig{Set: set}); err == nil {
+			t.Errorf("expected an error for %v", set)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/timescale/timescale-prometheus/pkg/events"
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+const (
+	insertTombstoneSQL = `INSERT INTO ` + catalogSchema + `.series_tombstone
+	(metric_name, table_name, series_ids, start_time, end_time, purge_after)
+	VALUES ($1, $2, $3, $4, $5, now() + $6 * interval '1 second')
+	RETURNING id, purge_after`
+
+	listTombstonesSQL = `SELECT id, metric_name, cardinality(series_ids), start_time, end_time, created_at, purge_after
+	FROM ` + catalogSchema + `.series_tombstone
+	ORDER BY id`
+
+	// Only the tombstones not yet due are removed, the others being purged
+	// by then.
+	undeleteTombstonesSQL = `DELETE FROM ` + catalogSchema + `.series_tombstone
+	WHERE id = ANY($1) AND purge_after > now()
+	RETURNING id, metric_name, cardinality(series_ids), start_time, end_time, created_at, purge_after`
+
+	expiredTombstonesSQL = `SELECT id, metric_name, table_name, series_ids, start_time, end_time
+	FROM ` + catalogSchema + `.series_tombstone
+	WHERE purge_after <= now()
+	ORDER BY id`
+
+	deleteTombstoneSQL = "DELETE FROM " + catalogSchema + ".series_tombstone WHERE id = $1"
+
+	tombstonedTablesSQL = "SELECT DISTINCT table_name FROM " + catalogSchema + ".series_tombstone"
+
+	// tombstoneFilterSQLFormat skips the samples hidden by the tombstones of
+	// the metric table, passed as the argument of the position formatted.
+	tombstoneFilterSQLFormat = `
+	AND NOT EXISTS (
+		SELECT 1 FROM _prom_catalog.series_tombstone t
+		WHERE t.table_name = $%d
+		AND m.series_id = ANY(t.series_ids)
+		AND m.time >= t.start_time
+		AND m.time <= t.end_time
+	)`
+)
+
+// SeriesTombstone hides the samples of series deleted with a grace period,
+// until they are purged or undeleted.
+type SeriesTombstone struct {
+	ID     int64  `json:"id"`
+	Metric string `json:"metric"`
+	// Series is the number of series tombstoned.
+	Series     int       `json:"series"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	CreatedAt  time.Time `json:"created_at"`
+	PurgeAfter time.Time `json:"purge_after"`
+}
+
+// tombstoneSeries records a tombstone hiding the samples between start and
+// end of the series of the metric stored in table, until the grace period is
+// over.
+func (d *SeriesDeleter) tombstoneSeries(ctx context.Context, deletion SeriesDeletion, table string, ids []int64, start, end time.Time) (SeriesDeletion, error) {
+	rows, err := d.conn.Query(ctx, insertTombstoneSQL, deletion.Metric, table, ids, start, end, d.grace.Seconds())
+	if err != nil {
+		return deletion, err
+	}
+	defer rows.Close()
+	var purgeAfter time.Time
+	if rows.Next() {
+		if err = rows.Scan(&deletion.Tombstone, &purgeAfter); err != nil {
+			return deletion, err
+		}
+		deletion.PurgeAfter = &purgeAfter
+	}
+	return deletion, rows.Err()
+}
+
+// Tombstones returns the tombstones not purged yet, ordered by id.
+func (d *SeriesDeleter) Tombstones(ctx context.Context) ([]SeriesTombstone, error) {
+	return queryTombstones(ctx, d.conn, listTombstonesSQL)
+}
+
+// Undelete removes the tombstones with the ids, making their samples
+// readable again, unless their grace period is over. The removed tombstones
+// are returned.
+func (d *SeriesDeleter) Undelete(ctx context.Context, ids []int64) ([]SeriesTombstone, error) {
+	tombstones, err := queryTombstones(ctx, d.conn, undeleteTombstonesSQL, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tombstones {
+		log.Info("msg", "Undeleted series", "metric", t.Metric, "series", t.Series, "tombstone", t.ID)
+		events.Emit(events.SeriesUndeleted, "metric", t.Metric, "series", t.Series, "tombstone", t.ID)
+	}
+	return tombstones, nil
+}
+
+func queryTombstones(ctx context.Context, conn pgxConn, sql string, args ...interface{}) ([]SeriesTombstone, error) {
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tombstones := make([]SeriesTombstone, 0)
+	for rows.Next() {
+		var (
+			t      SeriesTombstone
+			series int32
+		)
+		if err = rows.Scan(&t.ID, &t.Metric, &series, &t.Start, &t.End, &t.CreatedAt, &t.PurgeAfter); err != nil {
+			return nil, err
+		}
+		t.Series = int(series)
+		tombstones = append(tombstones, t)
+	}
+	return tombstones, rows.Err()
+}
+
+// EnableTombstones hides the samples of the series deleted with a grace
+// period from the reads, until they are purged.
+func (r *DBReader) EnableTombstones() {
+	if q, ok := r.db.(*pgxQuerier); ok {
+		q.tombstones = true
+	}
+}
+
+// tombstonedTables returns the metric tables with tombstones. Only the
+// samples of these tables are read through the tombstone filter.
+func (q *pgxQuerier) tombstonedTables(ctx context.Context) (map[string]bool, error) {
+	rows, err := q.query(ctx, tombstonedTablesSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := make(map[string]bool)
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables[table] = true
+	}
+	return tables, rows.Err()
+}
+
+// withTombstoneFilter adds the tombstone filter, whose table name is the
+// argument at position arg, to the WHERE clause of a timeseries query.
+func withTombstoneFilter(sql string, arg int) string {
+	i := strings.LastIndex(sql, "\n\tGROUP BY")
+	return sql[:i] + fmt.Sprintf(tombstoneFilterSQLFormat, arg) + sql[i:]
+}
+
+type expiredTombstone struct {
+	id         int64
+	metric     string
+	table      string
+	ids        []int64
+	start, end time.Time
+}
+
+// PurgeTombstones deletes the samples hidden by the tombstones whose grace
+// period is over, and the series left without samples, then removes the
+// tombstones. The deletions are reported per tombstone. A tombstone failing
+// to be purged is kept for the next run.
+func (d *SeriesDeleter) PurgeTombstones(ctx context.Context) ([]SeriesDeletion, error) {
+	rows, err := d.conn.Query(ctx, expiredTombstonesSQL)
+	if err != nil {
+		return nil, err
+	}
+	expired := make([]expiredTombstone, 0)
+	for rows.Next() {
+		var t expiredTombstone
+		if err = rows.Scan(&t.id, &t.metric, &t.table, &t.ids, &t.start, &t.end); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		expired = append(expired, t)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	deletions := make([]SeriesDeletion, 0, len(expired))
+	for _, t := range expired {
+		deletion, err := d.purgeSeries(ctx, SeriesDeletion{Metric: t.metric, Series: len(t.ids), Tombstone: t.id}, t.table, t.ids, t.start, t.end)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UndefinedTable {
+			// The metric was dropped along with the samples.
+			err = nil
+		}
+		if err != nil {
+			return deletions, fmt.Errorf("purging tombstone %d of metric %s: %w", t.id, t.metric, err)
+		}
+		if _, err = d.conn.Exec(ctx, deleteTombstoneSQL, t.id); err != nil {
+			return deletions, err
+		}
+		log.Info("msg", "Purged tombstoned series", "metric", t.metric, "series", deletion.Series,
+			"samples", deletion.Samples, "orphaned_series", deletion.OrphanedSeries, "tombstone", t.id)
+		deletions = append(deletions, deletion)
+	}
+	return deletions, nil
+}
@@ -0,0 +1,177 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestDeleteSeriesGracePeriod(t *testing.T) {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1000, 0)
+	purgeAfter := now.Add(time.Hour)
+	mock := &mockPGXConn{QueryResults: []rowResults{
+		{{"cpu", []int64{2, 1}}},
+		{{"cpu_table"}},
+		{{false}},
+		{{int64(7)}},
+		{{int64(1)}},
+		{{int64(5), purgeAfter}},
+	}}
+	deleter := &SeriesDeleter{conn: mock, grace: time.Hour, now: func() time.Time { return now }}
+	selectors := [][]*prompb.LabelMatcher{{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "cpu"}}}
+
+	deletions, err := deleter.DeleteSeries(context.Background(), selectors, time.Unix(100, 0), time.Unix(2000, 0), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []SeriesDeletion{{Metric: "cpu", Series: 2, Samples: 7, OrphanedSeries: 1, Tombstone: 5, PurgeAfter: &purgeAfter}}
+	if !reflect.DeepEqual(deletions, expected) {
+		t.Errorf("unexpected deletions: got %+v wanted %+v", deletions, expected)
+	}
+	if len(mock.ExecSQLs) != 0 {
+		t.Errorf("samples deleted within the grace period: %v", mock.ExecSQLs)
+	}
+	if mock.QuerySQLs[5] != insertTombstoneSQL {
+		t.Fatalf("unexpected query: %q", mock.QuerySQLs[5])
+	}
+	// The tombstone ends now, not hiding the samples written afterwards.
+	expectedArgs := []interface{}{"cpu", "cpu_table", []int64{1, 2}, time.Unix(100, 0), now, float64(3600)}
+	if !reflect.DeepEqual(mock.QueryArgs[5], expectedArgs) {
+		t.Errorf("unexpected tombstone args: got %v wanted %v", mock.QueryArgs[5], expectedArgs)
+	}
+	if !reflect.DeepEqual(mock.QueryArgs[3][2], now) {
+		t.Errorf("samples counted after now: %v", mock.QueryArgs[3])
+	}
+}
+
+func TestPurgeTombstones(t *testing.T) {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	start, end := time.Unix(100, 0), time.Unix(200, 0)
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{
+			{{int64(5), "cpu", "cpu_table", []int64{1, 2}, start, end}},
+			{{false}},
+			{{[]int64{2}}},
+		},
+		ExecResult: pgconn.CommandTag("DELETE 7"),
+	}
+	var evicted []SeriesID
+	deleter := &SeriesDeleter{conn: mock, evict: func(ids []SeriesID) { evicted = append(evicted, ids...) }}
+
+	deletions, err := deleter.PurgeTombstones(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []SeriesDeletion{{Metric: "cpu", Series: 2, Samples: 7, OrphanedSeries: 1, Tombstone: 5}}
+	if !reflect.DeepEqual(deletions, expected) {
+		t.Errorf("unexpected deletions: got %+v wanted %+v", deletions, expected)
+	}
+	if !reflect.DeepEqual(evicted, []SeriesID{2}) {
+		t.Errorf("unexpected evicted series: %v", evicted)
+	}
+	if len(mock.ExecSQLs) != 2 || mock.ExecSQLs[1] != deleteTombstoneSQL {
+		t.Fatalf("unexpected statements: %v", mock.ExecSQLs)
+	}
+	if !reflect.DeepEqual(mock.ExecArgs[0], []interface{}{[]int64{1, 2}, start, end}) {
+		t.Errorf("unexpected delete args: %v", mock.ExecArgs[0])
+	}
+	if !reflect.DeepEqual(mock.ExecArgs[1], []interface{}{int64(5)}) {
+		t.Errorf("unexpected tombstone removed: %v", mock.ExecArgs[1])
+	}
+}
+
+func TestUndeleteSeries(t *testing.T) {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	start, end, created, purgeAfter := time.Unix(100, 0), time.Unix(200, 0), time.Unix(300, 0), time.Unix(3900, 0)
+	mock := &mockPGXConn{QueryResults: []rowResults{
+		{{int64(5), "cpu", int32(2), start, end, created, purgeAfter}},
+	}}
+	deleter := &SeriesDeleter{conn: mock}
+
+	restored, err := deleter.Undelete(context.Background(), []int64{5, 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []SeriesTombstone{{ID: 5, Metric: "cpu", Series: 2, Start: start, End: end, CreatedAt: created, PurgeAfter: purgeAfter}}
+	if !reflect.DeepEqual(restored, expected) {
+		t.Errorf("unexpected tombstones: got %+v wanted %+v", restored, expected)
+	}
+	if mock.QuerySQLs[0] != undeleteTombstonesSQL || !reflect.DeepEqual(mock.QueryArgs[0], []interface{}{[]int64{5, 6}}) {
+		t.Errorf("unexpected query: %q %v", mock.QuerySQLs[0], mock.QueryArgs[0])
+	}
+}
+
+func TestTombstoneQueries(t *testing.T) {
+	filter := metricTimeRangeFilter{metric: "cpu", startTime: "start", endTime: "end", tombstoned: map[string]bool{"mem": true}}
+	cases := matcherClauses{shape: "t", formats: []string{"true"}}
+	if sql, args := buildTimeseriesByLabelClausesQuery(filter, cases, nil); strings.Contains(sql, "series_tombstone") || len(args) != 2 {
+		t.Errorf("tombstones read for a metric without tombstones: %s %v", sql, args)
+	}
+
+	filter.metric = "mem"
+	for _, liveness := range []bool{false, true} {
+		filter.liveness = liveness
+		byLabels, labelArgs := buildTimeseriesByLabelClausesQuery(filter, cases, []interface{}{"job", "api"})
+		bySeriesIDs, seriesArgs := buildTimeseriesBySeriesIDQuery(filter, []SeriesID{1})
+		if !strings.Contains(byLabels, "WHERE t.table_name = $5\n") || !reflect.DeepEqual(labelArgs, []interface{}{"job", "api", "start", "end", "mem"}) {
+			t.Errorf("tombstones not read: %s %v", byLabels, labelArgs)
+		}
+		if !strings.Contains(bySeriesIDs, "WHERE t.table_name = $4\n") || !reflect.DeepEqual(seriesArgs, []interface{}{[]int64{1}, "start", "end", "mem"}) {
+			t.Errorf("tombstones not read: %s %v", bySeriesIDs, seriesArgs)
+		}
+		for _, sql := range []string{byLabels, bySeriesIDs} {
+			if !strings.Contains(sql, "AND m.time <= t.end_time\n\t)\n\tGROUP BY s.id") {
+				t.Errorf("tombstone filter not in the WHERE clause: %s", sql)
+			}
+		}
+	}
+}
+
+func TestQueryTombstones(t *testing.T) {
+	query := &prompb.Query{
+		StartTimestampMs: 1000,
+		EndTimestampMs:   2000,
+		Matchers:         []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "cpu"}},
+	}
+	testCases := []struct {
+		name       string
+		tables     rowResults
+		tombstoned bool
+	}{
+		{name: "tombstoned metric", tables: rowResults{{"mem"}, {"cpu"}}, tombstoned: true},
+		{name: "other metric tombstoned", tables: rowResults{{"mem"}}},
+		{name: "no tombstones"},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &mockPGXConn{QueryResults: []rowResults{c.tables, nil}}
+			querier := pgxQuerier{conn: mock, metricTableNames: &mockMetricCache{metricCache: map[string]string{"cpu": "cpu"}}, tombstones: true}
+			if _, err := querier.Query(context.Background(), query); err != nil {
+				t.Fatal(err)
+			}
+			if len(mock.QuerySQLs) != 2 || mock.QuerySQLs[0] != tombstonedTablesSQL {
+				t.Fatalf("unexpected queries: %v", mock.QuerySQLs)
+			}
+			if filtered := strings.Contains(mock.QuerySQLs[1], "series_tombstone t"); filtered != c.tombstoned {
+				t.Errorf("unexpected tombstone filter: %s", mock.QuerySQLs[1])
+			}
+		})
+	}
+}
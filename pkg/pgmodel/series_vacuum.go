@@ -0,0 +1,160 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/timescale/timescale-prometheus/pkg/events"
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+const (
+	// DefaultSeriesVacuumGracePeriod is how long a series is left alone
+	// after its creation, unless configured, since its first samples may
+	// still be on their way to the database.
+	DefaultSeriesVacuumGracePeriod = 10 * time.Minute
+
+	maxSeriesIDSQL      = "SELECT coalesce(max(id), 0) FROM " + catalogSchema + ".series"
+	listMetricTablesSQL = "SELECT metric_name, table_name FROM " + catalogSchema + ".metric ORDER BY metric_name"
+
+	vacuumSeriesSQLFormat = `WITH deleted_series AS (
+		DELETE FROM %[1]s s
+		WHERE s.id <= $1 AND ` + orphanedSeriesFilterSQLFormat + `
+		RETURNING s.id
+	), deleted_liveness AS (
+		DELETE FROM ` + catalogSchema + `.series_liveness l
+		WHERE l.series_id IN (SELECT id FROM deleted_series)
+	)
+	SELECT coalesce(array_agg(id), '{}') FROM deleted_series`
+)
+
+// VacuumedSeries reports the series deleted from a metric by the vacuum.
+type VacuumedSeries struct {
+	Metric string `json:"metric"`
+	Series int    `json:"series"`
+}
+
+// SeriesVacuum deletes the series left without samples, once retention,
+// lifecycle policies or deletions removed all of them, and evicts them from
+// the series cache.
+type SeriesVacuum struct {
+	conn  pgxConn
+	evict func([]SeriesID)
+	grace time.Duration
+	now   func() time.Time
+
+	lock sync.Mutex
+	// watermark is the highest series id when it was recorded, at
+	// watermarkTime. Only the series up to it are vacuumed, once it is
+	// older than the grace period.
+	watermark     int64
+	watermarkTime time.Time
+}
+
+// NewSeriesVacuum returns a SeriesVacuum using the connection pool. evict is
+// called with the ids of the deleted series. The series are kept for the
+// grace period after their creation, DefaultSeriesVacuumGracePeriod if 0.
+func NewSeriesVacuum(c *pgxpool.Pool, evict func([]SeriesID), grace time.Duration) *SeriesVacuum {
+	if grace <= 0 {
+		grace = DefaultSeriesVacuumGracePeriod
+	}
+	return &SeriesVacuum{
+		conn: &pgxConnImpl{
+			conn: c,
+		},
+		evict: evict,
+		grace: grace,
+		now:   time.Now,
+	}
+}
+
+// Run deletes the series without samples created before the watermark,
+// then moves the watermark to the current series. The first run only
+// records the watermark, and the runs within the grace period of it delete
+// nothing. The deletions are reported per metric, ordered by
+// metric name, omitting the metrics without any.
+func (v *SeriesVacuum) Run(ctx context.Context) ([]VacuumedSeries, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	now := v.now()
+	if !v.watermarkTime.IsZero() && now.Sub(v.watermarkTime) < v.grace {
+		return []VacuumedSeries{}, nil
+	}
+	var maxID int64
+	if err := queryCount(ctx, v.conn, &maxID, maxSeriesIDSQL); err != nil {
+		return nil, err
+	}
+	limit := v.watermark
+	v.watermark, v.watermarkTime = maxID, now
+	if limit == 0 {
+		return []VacuumedSeries{}, nil
+	}
+
+	tables, err := v.metricTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vacuumed := make([]VacuumedSeries, 0)
+	total := 0
+	for _, t := range tables {
+		ids, err := v.vacuumMetric(ctx, t.table, limit)
+		if err != nil {
+			return vacuumed, fmt.Errorf("vacuuming series of metric %s: %w", t.metric, err)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		v.evict(ids)
+		vacuumedSeries.Add(float64(len(ids)))
+		vacuumed = append(vacuumed, VacuumedSeries{Metric: t.metric, Series: len(ids)})
+		events.Emit(events.SeriesVacuumed, "metric", t.metric, "series", len(ids))
+		total += len(ids)
+	}
+	log.Info("msg", "Vacuumed series without samples", "series", total, "metrics", len(vacuumed))
+	return vacuumed, nil
+}
+
+type metricTable struct {
+	metric string
+	table  string
+}
+
+func (v *SeriesVacuum) metricTables(ctx context.Context) ([]metricTable, error) {
+	rows, err := v.conn.Query(ctx, listMetricTablesSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := make([]metricTable, 0)
+	for rows.Next() {
+		var t metricTable
+		if err = rows.Scan(&t.metric, &t.table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
+
+func (v *SeriesVacuum) vacuumMetric(ctx context.Context, table string, limit int64) ([]SeriesID, error) {
+	rollupFilter, err := rollupSeriesFilter(ctx, v.conn, table)
+	if err != nil {
+		return nil, err
+	}
+	sql := fmt.Sprintf(vacuumSeriesSQLFormat,
+		pgx.Identifier{dataSeriesSchema, table}.Sanitize(),
+		pgx.Identifier{dataSchema, table}.Sanitize(),
+		"",
+		rollupFilter,
+	)
+	return querySeriesIDs(ctx, v.conn, sql, limit)
+}
@@ -0,0 +1,83 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+func TestSeriesVacuum(t *testing.T) {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	mock := &mockPGXConn{QueryResults: []rowResults{
+		// First run: the watermark is recorded.
+		{{int64(10)}},
+		// Second run.
+		{{int64(15)}},
+		{{"first", "first"}, {"second", "second_table"}},
+		{{false}},
+		{{[]int64{3, 7}}},
+		{{true}},
+		{{[]int64{}}},
+	}}
+	now := time.Unix(10000, 0)
+	var evicted []SeriesID
+	vacuum := &SeriesVacuum{
+		conn:  mock,
+		evict: func(ids []SeriesID) { evicted = append(evicted, ids...) },
+		grace: DefaultSeriesVacuumGracePeriod,
+		now:   func() time.Time { return now },
+	}
+
+	run := func() []VacuumedSeries {
+		t.Helper()
+		vacuumed, err := vacuum.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return vacuumed
+	}
+
+	if vacuumed := run(); len(vacuumed) != 0 || len(mock.QuerySQLs) != 1 {
+		t.Fatalf("first run vacuumed series: %v, %v", vacuumed, mock.QuerySQLs)
+	}
+	now = now.Add(DefaultSeriesVacuumGracePeriod / 2)
+	if vacuumed := run(); len(vacuumed) != 0 || len(mock.QuerySQLs) != 1 {
+		t.Fatalf("run within the grace period vacuumed series: %v, %v", vacuumed, mock.QuerySQLs)
+	}
+
+	before := testutil.ToFloat64(vacuumedSeries)
+	now = now.Add(DefaultSeriesVacuumGracePeriod)
+	vacuumed := run()
+	if expected := []VacuumedSeries{{Metric: "first", Series: 2}}; !reflect.DeepEqual(vacuumed, expected) {
+		t.Errorf("unexpected vacuumed series: got %v wanted %v", vacuumed, expected)
+	}
+	if !reflect.DeepEqual(evicted, []SeriesID{3, 7}) {
+		t.Errorf("unexpected evicted series: %v", evicted)
+	}
+	if testutil.ToFloat64(vacuumedSeries) != before+2 {
+		t.Error("vacuumed series not counted")
+	}
+	// The series created since the first run are kept.
+	for _, i := range []int{4, 6} {
+		if !reflect.DeepEqual(mock.QueryArgs[i], []interface{}{int64(10)}) {
+			t.Errorf("unexpected watermark: %v", mock.QueryArgs[i])
+		}
+	}
+	if !strings.Contains(mock.QuerySQLs[6], `"prom_data_series"."second_table"`) || !strings.Contains(mock.QuerySQLs[6], `"prom_rollup"."second_table" r`) {
+		t.Errorf("unexpected vacuum query: %s", mock.QuerySQLs[6])
+	}
+	if vacuum.watermark != 15 {
+		t.Errorf("watermark not moved: %d", vacuum.watermark)
+	}
+}
@@ -0,0 +1,254 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/timescale/timescale-prometheus/pkg/events"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+const (
+	// DefaultSpillMaxSize is the default maximum size of the spill buffer.
+	DefaultSpillMaxSize = 1 << 30
+	// DefaultSpillMaxAge is the default age after which spilled requests are
+	// dropped. It matches the two hours of WAL kept by Prometheus.
+	DefaultSpillMaxAge = 2 * time.Hour
+	// DefaultSpillReplayInterval is the default interval at which the
+	// database is probed while requests are spilled.
+	DefaultSpillReplayInterval = 5 * time.Second
+
+	spillSegmentSize = 64 << 20
+)
+
+var (
+	// ErrSpillBufferFull is returned when a request cannot be spilled because
+	// the spill buffer reached its maximum size.
+	ErrSpillBufferFull = fmt.Errorf("spill buffer is full")
+)
+
+// SpillConfig configures the spill buffer.
+type SpillConfig struct {
+	// Dir holds the segment files.
+	Dir string
+	// MaxSize is the maximum number of bytes buffered on disk. Writes are
+	// rejected once it is reached.
+	MaxSize int64
+	// MaxAge is how long spilled requests are kept before being dropped.
+	MaxAge time.Duration
+	// ReplayInterval is the interval at which the database is probed
+	// while requests are spilled.
+	ReplayInterval time.Duration
+}
+
+// SpillBuffer buffers write requests on disk while the database is
+// unreachable and replays them once it is back, so that a database restart
+// does not make Prometheus back up and eventually drop samples.
+//
+// A write failing while the database does not answer the probe switches the
+// buffer to spilling. The failed write is not spilled: Prometheus retries it,
+// and the retry is. Replay happens in the background, oldest segment first,
+// while new writes go to the database directly again.
+type SpillBuffer struct {
+	inserter DBInserter
+	probe    func() error
+	queue    *segmentQueue
+	cfg      SpillConfig
+
+	spilling int32
+	stop     chan struct{}
+	done     sync.WaitGroup
+}
+
+// NewSpillBuffer returns a SpillBuffer writing to inserter. probe checks
+// whether the database is reachable. Requests spilled by a previous run are
+// replayed.
+func NewSpillBuffer(inserter DBInserter, probe func() error, cfg SpillConfig) (*SpillBuffer, error) {
+	queue, err := openSegmentQueue(cfg.Dir, spillSegmentSize)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open the spill buffer: %w", err)
+	}
+	if cfg.ReplayInterval <= 0 {
+		cfg.ReplayInterval = DefaultSpillReplayInterval
+	}
+
+	b := &SpillBuffer{
+		inserter: inserter,
+		probe:    probe,
+		queue:    queue,
+		cfg:      cfg,
+		stop:     make(chan struct{}),
+	}
+	spillBufferBytes.Set(float64(queue.size()))
+
+	b.done.Add(1)
+	go b.runReplay()
+	return b, nil
+}
+
+// Ingest implements DBInserter.
+func (b *SpillBuffer) Ingest(ctx context.Context, tts []prompb.TimeSeries, req *prompb.WriteRequest) (uint64, error) {
+	if atomic.LoadInt32(&b.spilling) == 0 {
+		n, err := b.inserter.Ingest(ctx, tts, req)
+		if err == nil || b.probe() == nil {
+			return n, err
+		}
+		if atomic.CompareAndSwapInt32(&b.spilling, 0, 1) {
+			ingestLogger.Warn("msg", "Database unreachable, spilling write requests to disk", "dir", b.cfg.Dir, "err", err)
+			events.Emit(events.CircuitBreakerTripped, "spill_dir", b.cfg.Dir, "err", err)
+		}
+		return n, err
+	}
+	return b.spill(req)
+}
+
+func (b *SpillBuffer) spill(req *prompb.WriteRequest) (uint64, error) {
+	data, err := req.Marshal()
+	if err != nil {
+		return 0, err
+	}
+	record := snappy.Encode(nil, data)
+
+	if b.cfg.MaxSize > 0 && b.queue.size()+int64(len(record)+recordHeaderSize) > b.cfg.MaxSize {
+		return 0, ErrSpillBufferFull
+	}
+	if err := b.queue.append(record); err != nil {
+		return 0, fmt.Errorf("cannot spill write request: %w", err)
+	}
+	spillBufferBytes.Set(float64(b.queue.size()))
+
+	var samples uint64
+	for _, ts := range req.Timeseries {
+		samples += uint64(len(ts.Samples))
+	}
+	FinishWriteRequest(req)
+	samplesSpilled.Add(float64(samples))
+	return samples, nil
+}
+
+func (b *SpillBuffer) runReplay() {
+	defer b.done.Done()
+	ticker := time.NewTicker(b.cfg.ReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		b.dropExpired()
+		if b.queue.size() > 0 && b.probe() == nil {
+			if err := b.replay(); err != nil {
+				ingestLogger.Warn("msg", "Replaying spilled write requests failed", "err", err)
+			}
+		}
+
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *SpillBuffer) dropExpired() {
+	if b.cfg.MaxAge <= 0 {
+		return
+	}
+	dropped, err := b.queue.dropOlderThan(time.Now().Add(-b.cfg.MaxAge))
+	if err != nil {
+		ingestLogger.Warn("msg", "Dropping expired spill segments failed", "err", err)
+	}
+	if dropped > 0 {
+		ingestLogger.Warn("msg", "Dropped spilled write requests older than the maximum age", "bytes", dropped, "max_age", b.cfg.MaxAge)
+		spillBytesDropped.Add(float64(dropped))
+		// The segments are not decoded, so only their size is known.
+		events.Emit(events.DataLost, "reason", dataLossSpillExpiry, "bytes", dropped)
+		spillBufferBytes.Set(float64(b.queue.size()))
+	}
+}
+
+// replay writes the spilled requests to the database, oldest first. New
+// writes go to the database directly once the older segments are replayed,
+// and the requests spilled meanwhile are replayed last.
+func (b *SpillBuffer) replay() error {
+	for {
+		s, ok := b.queue.oldest()
+		if !ok {
+			if atomic.CompareAndSwapInt32(&b.spilling, 1, 0) {
+				ingestLogger.Info("msg", "Database reachable again, stopped spilling write requests")
+				events.Emit(events.CircuitBreakerReset, "spill_dir", b.cfg.Dir)
+			}
+			if b.queue.size() == 0 {
+				return nil
+			}
+			if err := b.queue.seal(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := b.replaySegment(s); err != nil {
+			return err
+		}
+		spillBufferBytes.Set(float64(b.queue.size()))
+	}
+}
+
+// replaySegment replays the records of a segment. If the database becomes
+// unreachable again, the records not yet replayed are kept for the next
+// attempt.
+func (b *SpillBuffer) replaySegment(s segment) error {
+	records, err := b.queue.read(s)
+	if err != nil {
+		return err
+	}
+
+	for i, record := range records {
+		if err := b.replayRecord(record); err != nil {
+			if rewriteErr := b.queue.rewrite(s, records[i:]); rewriteErr != nil {
+				ingestLogger.Error("msg", "Cannot keep the spilled write requests not yet replayed", "segment", s.seq, "err", rewriteErr)
+			}
+			return err
+		}
+	}
+	return b.queue.remove(s)
+}
+
+func (b *SpillBuffer) replayRecord(record []byte) error {
+	data, err := snappy.Decode(nil, record)
+	if err != nil {
+		ingestLogger.Warn("msg", "Skipping undecodable spilled write request", "err", err)
+		return nil
+	}
+	req := NewWriteRequest()
+	if err := req.Unmarshal(data); err != nil {
+		FinishWriteRequest(req)
+		ingestLogger.Warn("msg", "Skipping undecodable spilled write request", "err", err)
+		return nil
+	}
+
+	n, err := b.inserter.Ingest(context.Background(), req.GetTimeseries(), req)
+	if err != nil {
+		if b.probe() == nil {
+			// The database is up, so retrying will not help.
+			ingestLogger.Warn("msg", "Dropping spilled write request rejected by the database", "err", err)
+			return nil
+		}
+		return err
+	}
+	samplesReplayed.Add(float64(n))
+	return nil
+}
+
+// Close stops replaying and closes the current segment. Requests still
+// spilled are replayed on the next start.
+func (b *SpillBuffer) Close() error {
+	close(b.stop)
+	b.done.Wait()
+	return b.queue.close()
+}
@@ -0,0 +1,279 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+type mockSpillInserter struct {
+	lock     sync.Mutex
+	err      error
+	ingested []string
+}
+
+func (m *mockSpillInserter) Ingest(_ context.Context, tts []prompb.TimeSeries, req *prompb.WriteRequest) (uint64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.err != nil {
+		return 0, m.err
+	}
+	n := uint64(0)
+	for _, ts := range tts {
+		m.ingested = append(m.ingested, ts.Labels[0].Value)
+		n += uint64(len(ts.Samples))
+	}
+	return n, nil
+}
+
+func (m *mockSpillInserter) setErr(err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.err = err
+}
+
+func (m *mockSpillInserter) metrics() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]string(nil), m.ingested...)
+}
+
+type mockProbe struct {
+	lock sync.Mutex
+	err  error
+}
+
+func (p *mockProbe) probe() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.err
+}
+
+func (p *mockProbe) setErr(err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.err = err
+}
+
+func spillWriteRequest(metric string) *prompb.WriteRequest {
+	req := NewWriteRequest()
+	req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+		Labels:  []prompb.Label{{Name: MetricNameLabelName, Value: metric}},
+		Samples: []prompb.Sample{{Timestamp: 1, Value: 1}, {Timestamp: 2, Value: 2}},
+	})
+	return req
+}
+
+// tempSpillDir also sets up the logger, which the spill buffer uses to
+// report outages and corrupted records.
+func tempSpillDir(t *testing.T) string {
+	if err := log.Init("debug"); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "spill_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestSegmentQueue(t *testing.T) {
+	dir := tempSpillDir(t)
+	defer os.RemoveAll(dir)
+
+	q, err := openSegmentQueue(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := q.append([]byte(fmt.Sprintf("record-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Every record fills a segment past its maximum size.
+	if len(q.sealed) != 3 {
+		t.Fatalf("unexpected number of sealed segments: got %d wanted 3", len(q.sealed))
+	}
+	if err := q.append([]byte("last")); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash while writing leaves a truncated record behind.
+	f, err := os.OpenFile(q.path(3), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{0, 0, 0, 42, 1}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	q, err = openSegmentQueue(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.sealed) != 4 || q.currentSeq != 4 {
+		t.Fatalf("unexpected segments after reopening: %v next %d", q.sealed, q.currentSeq)
+	}
+
+	expected := []string{"record-0", "record-1", "record-2", "last"}
+	for _, e := range expected {
+		s, ok := q.oldest()
+		if !ok {
+			t.Fatalf("missing segment for %s", e)
+		}
+		records, err := q.read(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 1 || string(records[0]) != e {
+			t.Errorf("unexpected records: got %q wanted %s", records, e)
+		}
+		if err := q.remove(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if q.size() != 0 {
+		t.Errorf("unexpected size of the empty queue: %d", q.size())
+	}
+}
+
+func TestSegmentQueueRewriteAndExpire(t *testing.T) {
+	dir := tempSpillDir(t)
+	defer os.RemoveAll(dir)
+
+	q, err := openSegmentQueue(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []string{"a", "b", "c"} {
+		if err := q.append([]byte(r)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.seal(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, _ := q.oldest()
+	if err := q.rewrite(s, [][]byte{[]byte("c")}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := q.read(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || string(records[0]) != "c" {
+		t.Errorf("unexpected records after rewrite: %q", records)
+	}
+	if q.size() != recordHeaderSize+1 {
+		t.Errorf("unexpected size after rewrite: %d", q.size())
+	}
+
+	dropped, err := q.dropOlderThan(time.Now().Add(-time.Hour))
+	if err != nil || dropped != 0 {
+		t.Errorf("unexpected drop of a recent segment: %d %v", dropped, err)
+	}
+	dropped, err = q.dropOlderThan(time.Now().Add(time.Hour))
+	if err != nil || dropped != recordHeaderSize+1 {
+		t.Errorf("unexpected drop: %d %v", dropped, err)
+	}
+	if _, ok := q.oldest(); ok {
+		t.Error("expired segment was not dropped")
+	}
+}
+
+func TestSpillBuffer(t *testing.T) {
+	dir := tempSpillDir(t)
+	defer os.RemoveAll(dir)
+
+	inserter := &mockSpillInserter{}
+	probe := &mockProbe{}
+	b, err := NewSpillBuffer(inserter, probe.probe, SpillConfig{Dir: dir, ReplayInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	// A failure while the database is reachable is returned as is.
+	inserter.setErr(fmt.Errorf("bad data"))
+	if _, err := b.Ingest(context.Background(), spillWriteRequest("bad").Timeseries, spillWriteRequest("bad")); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// The database goes away: the failed write is returned to be retried,
+	// and the retry is spilled.
+	inserter.setErr(fmt.Errorf("connection refused"))
+	probe.setErr(fmt.Errorf("connection refused"))
+	req := spillWriteRequest("first")
+	if _, err := b.Ingest(context.Background(), req.Timeseries, req); err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, metric := range []string{"first", "second"} {
+		req := spillWriteRequest(metric)
+		n, err := b.Ingest(context.Background(), req.Timeseries, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Errorf("unexpected number of spilled samples: %d", n)
+		}
+	}
+	if b.queue.size() == 0 {
+		t.Fatal("nothing was spilled")
+	}
+
+	// The database is back: the spilled requests are replayed in order.
+	inserter.setErr(nil)
+	probe.setErr(nil)
+	deadline := time.Now().Add(5 * time.Second)
+	for b.queue.size() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if b.queue.size() != 0 {
+		t.Fatal("spilled requests were not replayed")
+	}
+
+	req = spillWriteRequest("third")
+	if _, err := b.Ingest(context.Background(), req.Timeseries, req); err != nil {
+		t.Fatal(err)
+	}
+	got := inserter.metrics()
+	expected := []string{"first", "second", "third"}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("unexpected ingested metrics: got %v wanted %v", got, expected)
+	}
+}
+
+func TestSpillBufferMaxSize(t *testing.T) {
+	dir := tempSpillDir(t)
+	defer os.RemoveAll(dir)
+
+	inserter := &mockSpillInserter{err: fmt.Errorf("connection refused")}
+	probe := &mockProbe{err: fmt.Errorf("connection refused")}
+	b, err := NewSpillBuffer(inserter, probe.probe, SpillConfig{Dir: dir, MaxSize: 1, ReplayInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	req := spillWriteRequest("first")
+	_, _ = b.Ingest(context.Background(), req.Timeseries, req)
+	req = spillWriteRequest("first")
+	if _, err := b.Ingest(context.Background(), req.Timeseries, req); err != ErrSpillBufferFull {
+		t.Errorf("unexpected error: got %v wanted %v", err, ErrSpillBufferFull)
+	}
+}
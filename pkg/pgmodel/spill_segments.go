@@ -0,0 +1,279 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentSuffix = ".seg"
+	// Every record is prefixed by its length and CRC-32 checksum.
+	recordHeaderSize = 8
+)
+
+// segment is a sealed segment file, which is no longer written to.
+type segment struct {
+	seq     uint64
+	size    int64
+	modTime time.Time
+}
+
+// segmentQueue is an on-disk FIFO of records split into segment files. Records
+// are appended to the current segment, which is sealed once it grows past
+// maxSegmentSize or when the records are about to be read back. Sealed
+// segments are consumed oldest first.
+type segmentQueue struct {
+	lock           sync.Mutex
+	dir            string
+	maxSegmentSize int64
+
+	current     *os.File
+	currentSeq  uint64
+	currentSize int64
+	sealed      []segment
+	sealedSize  int64
+}
+
+// openSegmentQueue opens the queue stored in dir, creating the directory if
+// needed. Segments left over from a previous run are sealed.
+func openSegmentQueue(dir string, maxSegmentSize int64) (*segmentQueue, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &segmentQueue{dir: dir, maxSegmentSize: maxSegmentSize}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), segmentSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		q.sealed = append(q.sealed, segment{seq: seq, size: f.Size(), modTime: f.ModTime()})
+		q.sealedSize += f.Size()
+		if seq >= q.currentSeq {
+			q.currentSeq = seq + 1
+		}
+	}
+	sort.Slice(q.sealed, func(i, j int) bool { return q.sealed[i].seq < q.sealed[j].seq })
+	return q, nil
+}
+
+func (q *segmentQueue) path(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, segmentSuffix))
+}
+
+// size returns the number of bytes stored in the queue.
+func (q *segmentQueue) size() int64 {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.sealedSize + q.currentSize
+}
+
+// append writes a record to the current segment, starting a new one if
+// needed.
+func (q *segmentQueue) append(record []byte) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.current == nil {
+		f, err := os.OpenFile(q.path(q.currentSeq), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+		if err != nil {
+			return err
+		}
+		q.current = f
+		q.currentSize = 0
+	}
+
+	buf := make([]byte, recordHeaderSize+len(record))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(record)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(record))
+	copy(buf[recordHeaderSize:], record)
+	n, err := q.current.Write(buf)
+	q.currentSize += int64(n)
+	if err != nil {
+		return err
+	}
+
+	if q.currentSize >= q.maxSegmentSize {
+		return q.sealLocked()
+	}
+	return nil
+}
+
+// seal closes the current segment so that it can be read.
+func (q *segmentQueue) seal() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.sealLocked()
+}
+
+func (q *segmentQueue) sealLocked() error {
+	if q.current == nil {
+		return nil
+	}
+	f := q.current
+	q.current = nil
+
+	err := f.Sync()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	q.sealed = append(q.sealed, segment{seq: q.currentSeq, size: q.currentSize, modTime: time.Now()})
+	q.sealedSize += q.currentSize
+	q.currentSeq++
+	q.currentSize = 0
+	return err
+}
+
+// oldest returns the oldest sealed segment.
+func (q *segmentQueue) oldest() (segment, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.sealed) == 0 {
+		return segment{}, false
+	}
+	return q.sealed[0], true
+}
+
+// read returns the records of a sealed segment. A truncated or corrupted
+// record, left by a crash while writing, ends the segment.
+func (q *segmentQueue) read(s segment) ([][]byte, error) {
+	f, err := os.Open(q.path(s.seq))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	records := make([][]byte, 0)
+	header := make([]byte, recordHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err != io.EOF {
+				ingestLogger.Warn("msg", "Ignoring truncated spill record", "segment", s.seq, "err", err)
+			}
+			return records, nil
+		}
+		record := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+		if _, err := io.ReadFull(r, record); err != nil {
+			ingestLogger.Warn("msg", "Ignoring truncated spill record", "segment", s.seq, "err", err)
+			return records, nil
+		}
+		if crc32.ChecksumIEEE(record) != binary.BigEndian.Uint32(header[4:8]) {
+			ingestLogger.Warn("msg", "Ignoring corrupted spill records", "segment", s.seq)
+			return records, nil
+		}
+		records = append(records, record)
+	}
+}
+
+// rewrite replaces a sealed segment with the given records, which are the
+// ones left after replaying part of it.
+func (q *segmentQueue) rewrite(s segment, records [][]byte) error {
+	tmp := q.path(s.seq) + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	var size int64
+	header := make([]byte, recordHeaderSize)
+	for _, record := range records {
+		binary.BigEndian.PutUint32(header[0:4], uint32(len(record)))
+		binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(record))
+		if _, err = w.Write(header); err == nil {
+			_, err = w.Write(record)
+		}
+		if err != nil {
+			break
+		}
+		size += int64(recordHeaderSize + len(record))
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, q.path(s.seq))
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for i := range q.sealed {
+		if q.sealed[i].seq == s.seq {
+			q.sealedSize += size - q.sealed[i].size
+			q.sealed[i].size = size
+		}
+	}
+	return nil
+}
+
+// remove deletes a sealed segment.
+func (q *segmentQueue) remove(s segment) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if err := os.Remove(q.path(s.seq)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := range q.sealed {
+		if q.sealed[i].seq == s.seq {
+			q.sealedSize -= q.sealed[i].size
+			q.sealed = append(q.sealed[:i], q.sealed[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// dropOlderThan deletes the sealed segments last written before cutoff,
+// returning the number of bytes dropped.
+func (q *segmentQueue) dropOlderThan(cutoff time.Time) (int64, error) {
+	var dropped int64
+	for {
+		s, ok := q.oldest()
+		if !ok || !s.modTime.Before(cutoff) {
+			return dropped, nil
+		}
+		if err := q.remove(s); err != nil {
+			return dropped, err
+		}
+		dropped += s.size
+	}
+}
+
+// close seals the current segment.
+func (q *segmentQueue) close() error {
+	return q.seal()
+}
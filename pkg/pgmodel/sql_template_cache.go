@@ -0,0 +1,50 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import "sync"
+
+// maxSQLTemplates bounds the number of SQL texts cached, which grows with the
+// metrics read and the shapes of the queries reading them.
+const maxSQLTemplates = 10000
+
+// sqlTemplates caches the SQL of the reads by shape. The values of the label
+// matchers and the time ranges are passed as arguments, so the dashboards
+// repeating a query with other values reuse both its SQL and the statement
+// prepared for it on the connection, which Postgres may plan once.
+var sqlTemplates = newSQLTemplateCache(maxSQLTemplates)
+
+type sqlTemplateCache struct {
+	mu        sync.RWMutex
+	templates map[string]string
+	max       int
+}
+
+func newSQLTemplateCache(max int) *sqlTemplateCache {
+	return &sqlTemplateCache{templates: make(map[string]string), max: max}
+}
+
+// get returns the SQL cached for key, building it on a miss. When the cache
+// is full it is cleared rather than evicting entries one by one, since the
+// shapes in use are quickly cached again.
+func (c *sqlTemplateCache) get(key string, build func() string) string {
+	c.mu.RLock()
+	sql, ok := c.templates[key]
+	c.mu.RUnlock()
+	if ok {
+		sqlTemplateCacheRequests.WithLabelValues("hit").Inc()
+		return sql
+	}
+	sqlTemplateCacheRequests.WithLabelValues("miss").Inc()
+
+	sql = build()
+	c.mu.Lock()
+	if len(c.templates) >= c.max {
+		c.templates = make(map[string]string)
+	}
+	c.templates[key] = sql
+	c.mu.Unlock()
+	return sql
+}
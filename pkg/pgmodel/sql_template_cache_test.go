@@ -0,0 +1,71 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"testing"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestSQLTemplatesByShape(t *testing.T) {
+	build := func(matchers ...*prompb.LabelMatcher) (string, []interface{}) {
+		_, cases, values, err := buildSubQueries(&prompb.Query{Matchers: matchers})
+		if err != nil {
+			t.Fatal(err)
+		}
+		filter := metricTimeRangeFilter{metric: "cpu", startTime: "start", endTime: "end"}
+		return buildTimeseriesByLabelClausesQuery(filter, cases, values)
+	}
+
+	sql, args := build(
+		&prompb.LabelMatcher{Type: prompb.LabelMatcher_EQ, Name: "job", Value: "api"},
+		&prompb.LabelMatcher{Type: prompb.LabelMatcher_RE, Name: "mode", Value: "idle|user"},
+	)
+	sameShape, sameShapeArgs := build(
+		&prompb.LabelMatcher{Type: prompb.LabelMatcher_EQ, Name: "job", Value: "db"},
+		&prompb.LabelMatcher{Type: prompb.LabelMatcher_RE, Name: "cpu", Value: "0|1"},
+	)
+	if sql != sameShape {
+		t.Errorf("matchers of the same shape built different SQL:\n%s\n%s", sql, sameShape)
+	}
+	if len(args) != 6 || args[1] != "api" || sameShapeArgs[1] != "db" || args[4] != "start" || args[5] != "end" {
+		t.Errorf("unexpected arguments: %v %v", args, sameShapeArgs)
+	}
+
+	otherShape, _ := build(
+		&prompb.LabelMatcher{Type: prompb.LabelMatcher_EQ, Name: "job", Value: "api"},
+		&prompb.LabelMatcher{Type: prompb.LabelMatcher_NRE, Name: "mode", Value: "idle|user"},
+	)
+	if otherShape == sql {
+		t.Errorf("matchers of different shapes built the same SQL:\n%s", sql)
+	}
+}
+
+func TestSQLTemplateCache(t *testing.T) {
+	c := newSQLTemplateCache(2)
+	builds := 0
+	get := func(key string) string {
+		return c.get(key, func() string {
+			builds++
+			return "SQL " + key
+		})
+	}
+
+	if sql := get("a"); sql != "SQL a" {
+		t.Errorf("unexpected SQL: %s", sql)
+	}
+	get("a")
+	get("b")
+	if builds != 2 {
+		t.Errorf("unexpected builds: got %d wanted 2", builds)
+	}
+	// A full cache is cleared before caching the next template.
+	get("c")
+	get("a")
+	if builds != 4 || len(c.templates) != 2 {
+		t.Errorf("unexpected builds %d and size %d of a full cache", builds, len(c.templates))
+	}
+}
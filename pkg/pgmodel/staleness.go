@@ -0,0 +1,171 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/value"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+// StaleMarkerPolicy decides what is done with the staleness markers
+// Prometheus sends when a series disappears.
+type StaleMarkerPolicy string
+
+const (
+	// StaleMarkersStore stores the markers as samples, like any other value.
+	StaleMarkersStore StaleMarkerPolicy = "store"
+	// StaleMarkersDrop drops the markers. Queries then see a disappeared
+	// series for the whole lookback delta after its last sample.
+	StaleMarkersDrop StaleMarkerPolicy = "drop"
+	// StaleMarkersLiveness records the time each series last went stale in
+	// the catalog instead of storing the markers, and reads emit a marker at
+	// that time.
+	StaleMarkersLiveness StaleMarkerPolicy = "liveness"
+
+	recordLivenessSQL = `INSERT INTO ` + catalogSchema + `.series_liveness AS l (series_id, stale_at)
+	SELECT * FROM unnest($1::bigint[], $2::timestamptz[])
+	ON CONFLICT (series_id) DO UPDATE SET stale_at = GREATEST(l.stale_at, EXCLUDED.stale_at)`
+)
+
+// validate returns an error if p is not a known policy. The empty policy
+// stores the markers.
+func (p StaleMarkerPolicy) validate() error {
+	switch p {
+	case "", StaleMarkersStore, StaleMarkersDrop, StaleMarkersLiveness:
+		return nil
+	}
+	return fmt.Errorf("invalid stale marker policy %q, expected %q, %q or %q", p, StaleMarkersStore, StaleMarkersDrop, StaleMarkersLiveness)
+}
+
+// removeStaleMarkers returns samples without their staleness markers,
+// calling found with the timestamp of each. samples is copied rather than
+// modified, since the write request may still refer to it.
+func removeStaleMarkers(samples []prompb.Sample, found func(timestamp int64)) []prompb.Sample {
+	var kept []prompb.Sample
+	for i, s := range samples {
+		if !value.IsStaleNaN(s.Value) {
+			if kept != nil {
+				kept = append(kept, s)
+			}
+			continue
+		}
+		found(s.Timestamp)
+		if kept == nil {
+			kept = make([]prompb.Sample, i, len(samples))
+			copy(kept, samples[:i])
+		}
+	}
+	if kept == nil {
+		return samples
+	}
+	return kept
+}
+
+// dropStaleMarkers removes the staleness markers from data, along with the
+// series and metrics left without samples, and returns how many it removed.
+func dropStaleMarkers(data map[string][]samplesInfo) int {
+	dropped := 0
+	for metric, series := range data {
+		kept := series[:0]
+		for _, s := range series {
+			s.samples = removeStaleMarkers(s.samples, func(int64) { dropped++ })
+			if len(s.samples) > 0 {
+				kept = append(kept, s)
+			}
+		}
+		if len(kept) == 0 {
+			delete(data, metric)
+			continue
+		}
+		data[metric] = kept
+	}
+	if dropped > 0 {
+		staleMarkers.WithLabelValues("dropped").Add(float64(dropped))
+	}
+	return dropped
+}
+
+// countStoredStaleMarkers counts the markers stored as regular samples.
+func countStoredStaleMarkers(data map[string][]samplesInfo) {
+	n := 0
+	for _, series := range data {
+		for _, s := range series {
+			for _, sample := range s.samples {
+				if value.IsStaleNaN(sample.Value) {
+					n++
+				}
+			}
+		}
+	}
+	if n > 0 {
+		staleMarkers.WithLabelValues("stored").Add(float64(n))
+	}
+}
+
+// extractLiveness removes the staleness markers from the series of the
+// batch, whose ids must be set, and returns the latest marker of each
+// series along with the number of markers removed. Series left without
+// samples are removed from the batch.
+func extractLiveness(batch *SampleInfoIterator) (map[SeriesID]int64, int) {
+	staleAt := make(map[SeriesID]int64)
+	markers := 0
+	kept := batch.sampleInfos[:0]
+	for _, info := range batch.sampleInfos {
+		info.samples = removeStaleMarkers(info.samples, func(timestamp int64) {
+			markers++
+			timestamp = sampleMillis(timestamp, info.microseconds)
+			if last, ok := staleAt[info.seriesID]; !ok || timestamp > last {
+				staleAt[info.seriesID] = timestamp
+			}
+		})
+		if len(info.samples) > 0 {
+			kept = append(kept, info)
+		}
+	}
+	for i := len(kept); i < len(batch.sampleInfos); i++ {
+		batch.sampleInfos[i] = samplesInfo{}
+	}
+	batch.sampleInfos = kept
+	return staleAt, markers
+}
+
+// recordLiveness stores the time each series went stale in the catalog,
+// keeping the latest one.
+func recordLiveness(conn pgxConn, staleAt map[SeriesID]int64) error {
+	seriesIDs := make([]int64, 0, len(staleAt))
+	for id := range staleAt {
+		seriesIDs = append(seriesIDs, int64(id))
+	}
+	// A consistent order keeps concurrent upserts from deadlocking.
+	sort.Slice(seriesIDs, func(i, j int) bool { return seriesIDs[i] < seriesIDs[j] })
+	times := make([]time.Time, 0, len(seriesIDs))
+	for _, id := range seriesIDs {
+		times = append(times, model.Time(staleAt[SeriesID(id)]).Time())
+	}
+
+	_, err := conn.Exec(context.Background(), recordLivenessSQL, seriesIDs, times)
+	return err
+}
+
+// insertStaleMarker inserts a staleness marker at timestamp into samples,
+// which are ordered by time, unless a sample already has that timestamp.
+func insertStaleMarker(samples []prompb.Sample, timestamp int64) []prompb.Sample {
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].Timestamp >= timestamp })
+	if i < len(samples) && samples[i].Timestamp == timestamp {
+		return samples
+	}
+	samples = append(samples, prompb.Sample{})
+	copy(samples[i+1:], samples[i:])
+	samples[i] = prompb.Sample{Timestamp: timestamp, Value: math.Float64frombits(value.StaleNaN)}
+	return samples
+}
@@ -0,0 +1,122 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/value"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+var staleNaN = math.Float64frombits(value.StaleNaN)
+
+func TestStaleMarkerPolicyValidate(t *testing.T) {
+	for _, p := range []StaleMarkerPolicy{"", StaleMarkersStore, StaleMarkersDrop, StaleMarkersLiveness} {
+		if err := p.validate(); err != nil {
+			t.Errorf("valid policy %q rejected: %v", p, err)
+		}
+	}
+	if err := StaleMarkerPolicy("keep").validate(); err == nil {
+		t.Error("invalid policy accepted")
+	}
+}
+
+func TestIngestDropStaleMarkers(t *testing.T) {
+	inserter := &mockInserter{insertedSeries: make(map[string]SeriesID)}
+	i := DBIngestor{db: inserter, cache: &mockCache{seriesCache: make(map[string]SeriesID)}, staleMarkers: StaleMarkersDrop}
+	samples := []prompb.Sample{{Timestamp: 1, Value: 1}, {Timestamp: 2, Value: staleNaN}}
+	tts := []prompb.TimeSeries{
+		{Labels: []prompb.Label{{Name: MetricNameLabelName, Value: "live"}}, Samples: samples},
+		{Labels: []prompb.Label{{Name: MetricNameLabelName, Value: "gone"}}, Samples: []prompb.Sample{{Timestamp: 2, Value: staleNaN}}},
+	}
+
+	droppedBefore := testutil.ToFloat64(staleMarkers.WithLabelValues("dropped"))
+	count, err := i.Ingest(context.Background(), tts, NewWriteRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("unexpected number of acknowledged samples: got %d wanted 3", count)
+	}
+	if dropped := testutil.ToFloat64(staleMarkers.WithLabelValues("dropped")) - droppedBefore; dropped != 2 {
+		t.Errorf("unexpected number of dropped markers: got %v wanted 2", dropped)
+	}
+
+	data := inserter.insertedData[0]
+	if _, ok := data["gone"]; ok || len(data["live"]) != 1 {
+		t.Fatalf("unexpected inserted data: %+v", data)
+	}
+	if got := data["live"][0].samples; !reflect.DeepEqual(got, []prompb.Sample{{Timestamp: 1, Value: 1}}) {
+		t.Errorf("unexpected inserted samples: %v", got)
+	}
+	if !value.IsStaleNaN(samples[1].Value) {
+		t.Error("samples of the request modified")
+	}
+}
+
+func TestExtractAndRecordLiveness(t *testing.T) {
+	batch := NewSampleInfoIterator()
+	batch.Append(samplesInfo{seriesID: 1, samples: []prompb.Sample{{Timestamp: 1, Value: staleNaN}, {Timestamp: 2, Value: 2}, {Timestamp: 3, Value: staleNaN}}})
+	batch.Append(samplesInfo{seriesID: 2, samples: []prompb.Sample{{Timestamp: 5, Value: staleNaN}}})
+	batch.Append(samplesInfo{seriesID: 3, samples: []prompb.Sample{{Timestamp: 4, Value: 4}}})
+
+	staleAt, markers := extractLiveness(&batch)
+	if markers != 3 || !reflect.DeepEqual(staleAt, map[SeriesID]int64{1: 3, 2: 5}) {
+		t.Errorf("unexpected liveness: %v, %d markers", staleAt, markers)
+	}
+	if len(batch.sampleInfos) != 2 || batch.numSamples() != 2 || batch.sampleInfos[1].seriesID != 3 {
+		t.Errorf("unexpected batch: %+v", batch.sampleInfos)
+	}
+
+	mock := &mockPGXConn{}
+	if err := recordLiveness(mock, staleAt); err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{[]int64{1, 2}, []time.Time{model.Time(3).Time(), model.Time(5).Time()}}
+	if !reflect.DeepEqual(mock.ExecSQLs, []string{recordLivenessSQL}) || !reflect.DeepEqual(mock.ExecArgs[0], expected) {
+		t.Errorf("unexpected liveness upsert: %v %v", mock.ExecSQLs, mock.ExecArgs)
+	}
+}
+
+func TestInsertStaleMarker(t *testing.T) {
+	samples := []prompb.Sample{{Timestamp: 10, Value: 1}, {Timestamp: 30, Value: 3}}
+
+	got := insertStaleMarker(append([]prompb.Sample(nil), samples...), 20)
+	if len(got) != 3 || got[1].Timestamp != 20 || !value.IsStaleNaN(got[1].Value) || got[2].Timestamp != 30 {
+		t.Errorf("marker not inserted in order: %v", got)
+	}
+	got = insertStaleMarker(append([]prompb.Sample(nil), samples...), 40)
+	if len(got) != 3 || got[2].Timestamp != 40 || !value.IsStaleNaN(got[2].Value) {
+		t.Errorf("marker not appended: %v", got)
+	}
+	got = insertStaleMarker(append([]prompb.Sample(nil), samples...), 30)
+	if !reflect.DeepEqual(got, samples) {
+		t.Errorf("marker replaced a sample: %v", got)
+	}
+}
+
+func TestLivenessQueries(t *testing.T) {
+	filter := metricTimeRangeFilter{metric: "metric", startTime: "start", endTime: "end"}
+	cases := matcherClauses{shape: "t", formats: []string{"true"}}
+	if sql, _ := buildTimeseriesByLabelClausesQuery(filter, cases, nil); strings.Contains(sql, "series_liveness") {
+		t.Error("liveness read without the liveness policy")
+	}
+	filter.liveness = true
+	byLabels, _ := buildTimeseriesByLabelClausesQuery(filter, cases, nil)
+	bySeriesIDs, _ := buildTimeseriesBySeriesIDQuery(filter, []SeriesID{1})
+	for _, sql := range []string{byLabels, bySeriesIDs} {
+		if !strings.Contains(sql, "LEFT JOIN _prom_catalog.series_liveness l") {
+			t.Errorf("liveness not read: %s", sql)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// StatementProtocol is how the statements of a class are sent to the
+// database.
+type StatementProtocol string
+
+const (
+	// StatementProtocolDefault sends the statements as set by the statement
+	// cache mode of the connections.
+	StatementProtocolDefault StatementProtocol = "default"
+	// StatementProtocolPrepare prepares the statements on every connection
+	// when it is opened, whatever the statement cache mode, so that they are
+	// parsed once per connection and may use a generic plan.
+	StatementProtocolPrepare StatementProtocol = "prepare"
+	// StatementProtocolSimple sends the statements with the simple protocol,
+	// in a single round trip without any prepare or describe, for the poolers
+	// supporting neither. The statements sent in batches keep the extended
+	// protocol.
+	StatementProtocolSimple StatementProtocol = "simple"
+)
+
+// statementClasses are the hot statements of each class whose protocol can
+// be set. The per-metric COPYs and SELECTs are built for each metric table,
+// and are left to the statement cache.
+var statementClasses = map[string][]string{
+	// The series id lookups of the insert routines.
+	"series": {getSeriesIDsForLabelsSQL, getSeriesIDForLabelSQL},
+	// The metric table name lookups of the writes and the reads.
+	"metric": {getMetricsTableSQL, getCreateMetricsTableSQL, getCreateMetricsTableWithNewSQL},
+	// The native histogram inserts.
+	"histogram": {insertHistogramSQL},
+}
+
+// StatementProtocols maps statement classes to the protocol of their
+// statements. The classes missing use StatementProtocolDefault.
+type StatementProtocols map[string]StatementProtocol
+
+// ParseStatementProtocols parses comma-separated class=protocol pairs, such
+// as series=prepare,metric=simple.
+func ParseStatementProtocols(s string) (StatementProtocols, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	protocols := make(StatementProtocols)
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid statement protocol %q, expected class=protocol", pair)
+		}
+		class, protocol := parts[0], StatementProtocol(parts[1])
+		if _, ok := statementClasses[class]; !ok {
+			return nil, fmt.Errorf("unknown statement class %q, expected one of %s", class, strings.Join(statementClassNames(), ", "))
+		}
+		switch protocol {
+		case StatementProtocolDefault, StatementProtocolPrepare, StatementProtocolSimple:
+		default:
+			return nil, fmt.Errorf("invalid protocol %q of statement class %s, expected %q, %q or %q",
+				protocol, class, StatementProtocolDefault, StatementProtocolPrepare, StatementProtocolSimple)
+		}
+		protocols[class] = protocol
+	}
+	return protocols, nil
+}
+
+func statementClassNames() []string {
+	names := make([]string, 0, len(statementClasses))
+	for class := range statementClasses {
+		names = append(names, class)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// statements returns the statements of the classes using protocol.
+func (p StatementProtocols) statements(protocol StatementProtocol) []string {
+	var res []string
+	for _, class := range statementClassNames() {
+		if p[class] == protocol {
+			res = append(res, statementClasses[class]...)
+		}
+	}
+	return res
+}
+
+// HasPrepared reports whether some statements are prepared when the
+// connections are opened.
+func (p StatementProtocols) HasPrepared() bool {
+	return len(p.statements(StatementProtocolPrepare)) > 0
+}
+
+// Prepare prepares the statements using StatementProtocolPrepare on conn,
+// named after their SQL so that the queries sending it use them. It is the
+// AfterConnect of the connection pools.
+func (p StatementProtocols) Prepare(ctx context.Context, conn *pgx.Conn) error {
+	for _, sql := range p.statements(StatementProtocolPrepare) {
+		if _, err := conn.Prepare(ctx, sql, sql); err != nil {
+			return fmt.Errorf("preparing statement %q: %w", sql, err)
+		}
+	}
+	return nil
+}
+
+// simpleStatements returns the set of statements sent with the simple
+// protocol, nil if none is.
+func (p StatementProtocols) simpleStatements() map[string]bool {
+	var res map[string]bool
+	for _, sql := range p.statements(StatementProtocolSimple) {
+		if res == nil {
+			res = make(map[string]bool)
+		}
+		res[sql] = true
+	}
+	return res
+}
+
+// EnableStatementProtocols sends the statements of the reads with the
+// simple protocol as set by protocols.
+func (r *DBReader) EnableStatementProtocols(protocols StatementProtocols) {
+	if q, ok := r.db.(*pgxQuerier); ok {
+		if c, ok := q.conn.(*pgxConnImpl); ok {
+			c.simple = protocols.simpleStatements()
+		}
+	}
+}
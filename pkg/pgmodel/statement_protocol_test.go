@@ -0,0 +1,73 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func TestParseStatementProtocols(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected StatementProtocols
+		err      bool
+	}{
+		{in: ""},
+		{in: "series=prepare", expected: StatementProtocols{"series": StatementProtocolPrepare}},
+		{
+			in:       "series=simple, metric=default,histogram=prepare",
+			expected: StatementProtocols{"series": StatementProtocolSimple, "metric": StatementProtocolDefault, "histogram": StatementProtocolPrepare},
+		},
+		{in: "series", err: true},
+		{in: "samples=prepare", err: true},
+		{in: "series=extended", err: true},
+	}
+	for _, c := range testCases {
+		got, err := ParseStatementProtocols(c.in)
+		if (err != nil) != c.err {
+			t.Errorf("%q: unexpected error %v", c.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%q: unexpected protocols: got %v wanted %v", c.in, got, c.expected)
+		}
+	}
+}
+
+func TestStatementProtocolStatements(t *testing.T) {
+	protocols := StatementProtocols{"series": StatementProtocolPrepare, "metric": StatementProtocolSimple}
+	if !protocols.HasPrepared() || StatementProtocols(nil).HasPrepared() {
+		t.Error("unexpected prepared statements")
+	}
+	if got := protocols.statements(StatementProtocolPrepare); !reflect.DeepEqual(got, statementClasses["series"]) {
+		t.Errorf("unexpected prepared statements: %v", got)
+	}
+	simple := protocols.simpleStatements()
+	if len(simple) != len(statementClasses["metric"]) || !simple[getMetricsTableSQL] {
+		t.Errorf("unexpected simple statements: %v", simple)
+	}
+	if StatementProtocols(nil).simpleStatements() != nil {
+		t.Error("expected no simple statements")
+	}
+}
+
+func TestProtocolArgs(t *testing.T) {
+	conn := &pgxConnImpl{simple: StatementProtocols{"metric": StatementProtocolSimple}.simpleStatements()}
+	args := []interface{}{"foo"}
+	if got := conn.protocolArgs(getMetricsTableSQL, args); !reflect.DeepEqual(got, []interface{}{pgx.QuerySimpleProtocol(true), "foo"}) {
+		t.Errorf("unexpected simple protocol arguments: %v", got)
+	}
+	if got := conn.protocolArgs(getSeriesIDsForLabelsSQL, args); !reflect.DeepEqual(got, args) {
+		t.Errorf("unexpected arguments: %v", got)
+	}
+
+	reader := &DBReader{db: &pgxQuerier{conn: &pgxConnImpl{}}}
+	reader.EnableStatementProtocols(StatementProtocols{"metric": StatementProtocolSimple})
+	if !reader.db.(*pgxQuerier).conn.(*pgxConnImpl).simple[getMetricsTableSQL] {
+		t.Error("simple protocol not enabled on the reads")
+	}
+}
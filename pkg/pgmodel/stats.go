@@ -0,0 +1,184 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	metricStatsSQL = `SELECT
+		m.metric_name,
+		(SELECT count(*) FROM ` + catalogSchema + `.series s WHERE s.metric_id = m.id),
+		COALESCE((SELECT total_bytes FROM public.hypertable_relation_size(format('%I.%I', '` + dataSchema + `', m.table_name)::regclass)), 0)
+	FROM ` + catalogSchema + `.metric m
+	ORDER BY m.metric_name`
+	seriesCountSQL = "SELECT count(*) FROM " + catalogSchema + ".series"
+
+	// storageStatsSQL adds the chunks of every metric to metricStatsSQL,
+	// and the size of its compressed chunks before and after compression.
+	storageStatsSQL = `SELECT
+		m.metric_name,
+		(SELECT count(*) FROM ` + catalogSchema + `.series s WHERE s.metric_id = m.id),
+		COALESCE((SELECT total_bytes FROM public.hypertable_relation_size(format('%I.%I', '` + dataSchema + `', m.table_name)::regclass)), 0),
+		COALESCE(h.num_chunks, 0)::BIGINT,
+		COALESCE(c.chunks, 0)::BIGINT,
+		COALESCE(c.before_bytes, 0)::BIGINT,
+		COALESCE(c.after_bytes, 0)::BIGINT
+	FROM ` + catalogSchema + `.metric m
+	LEFT JOIN timescaledb_information.hypertable h ON (h.table_schema = '` + dataSchema + `' AND h.table_name = m.table_name)
+	LEFT JOIN LATERAL (
+		SELECT count(*) chunks,
+			sum(s.uncompressed_heap_size + s.uncompressed_toast_size + s.uncompressed_index_size) before_bytes,
+			sum(s.compressed_heap_size + s.compressed_toast_size + s.compressed_index_size) after_bytes
+		FROM _timescaledb_catalog.hypertable ht
+		INNER JOIN _timescaledb_catalog.chunk ch ON (ch.hypertable_id = ht.id)
+		INNER JOIN _timescaledb_catalog.compression_chunk_size s ON (s.chunk_id = ch.id)
+		WHERE ht.schema_name = '` + dataSchema + `' AND ht.table_name = m.table_name
+	) c ON true
+	ORDER BY m.metric_name`
+)
+
+// MetricStats holds the storage statistics of a single metric.
+type MetricStats struct {
+	Name        string `json:"name"`
+	SeriesCount int64  `json:"series_count"`
+	TotalBytes  int64  `json:"total_bytes"`
+}
+
+// MetricStorageStats adds the chunks and the compression of a metric to its
+// MetricStats, along with the rate it is ingested at.
+type MetricStorageStats struct {
+	MetricStats
+	Chunks           int64 `json:"chunks"`
+	CompressedChunks int64 `json:"compressed_chunks"`
+	// BeforeCompressionBytes and AfterCompressionBytes are the sizes of
+	// the compressed chunks before and after their compression.
+	BeforeCompressionBytes int64 `json:"before_compression_bytes"`
+	AfterCompressionBytes  int64 `json:"after_compression_bytes"`
+	// IngestRate is the number of samples per second ingested by this
+	// connector. The database does not know it.
+	IngestRate float64 `json:"ingest_rate"`
+}
+
+// StatsReader reads per-metric statistics from the database.
+type StatsReader struct {
+	conn pgxConn
+}
+
+// NewStatsReader returns a new StatsReader using the given connection pool.
+func NewStatsReader(c *pgxpool.Pool) *StatsReader {
+	return &StatsReader{
+		conn: &pgxConnImpl{
+			conn: c,
+		},
+	}
+}
+
+// MetricStats returns the series count and on-disk size of every metric,
+// ordered by metric name.
+func (r *StatsReader) MetricStats() ([]MetricStats, error) {
+	rows, err := r.conn.Query(context.Background(), metricStatsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make([]MetricStats, 0)
+	for rows.Next() {
+		var s MetricStats
+		if err := rows.Scan(&s.Name, &s.SeriesCount, &s.TotalBytes); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, nil
+}
+
+// SeriesCount returns the number of series stored in the database.
+func (r *StatsReader) SeriesCount() (int64, error) {
+	rows, err := r.conn.Query(context.Background(), seriesCountSQL)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	err = rows.Scan(&count)
+	return count, err
+}
+
+// StorageStats returns the storage statistics of every metric, ordered by
+// metric name. Their ingest rates are left to the caller.
+func (r *StatsReader) StorageStats() ([]MetricStorageStats, error) {
+	rows, err := r.conn.Query(context.Background(), storageStatsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make([]MetricStorageStats, 0)
+	for rows.Next() {
+		var s MetricStorageStats
+		err := rows.Scan(&s.Name, &s.SeriesCount, &s.TotalBytes, &s.Chunks, &s.CompressedChunks,
+			&s.BeforeCompressionBytes, &s.AfterCompressionBytes)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
+
+// IngestRateTracker computes the ingest rate of every metric between the
+// last two snapshots of the samples ingested since startup.
+type IngestRateTracker struct {
+	ingested func() map[string]uint64
+
+	lock       sync.Mutex
+	lastCounts map[string]uint64
+	lastAt     time.Time
+	rates      map[string]float64
+}
+
+// NewIngestRateTracker returns a new IngestRateTracker reading the samples
+// ingested per metric since startup from ingested.
+func NewIngestRateTracker(ingested func() map[string]uint64) *IngestRateTracker {
+	return &IngestRateTracker{ingested: ingested, rates: make(map[string]float64)}
+}
+
+// Observe takes a snapshot at now, computing the rates since the previous
+// one.
+func (t *IngestRateTracker) Observe(now time.Time) {
+	counts := t.ingested()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if elapsed := now.Sub(t.lastAt).Seconds(); t.lastCounts != nil && elapsed > 0 {
+		rates := make(map[string]float64, len(counts))
+		for metric, count := range counts {
+			rates[metric] = float64(count-t.lastCounts[metric]) / elapsed
+		}
+		t.rates = rates
+	}
+	t.lastCounts, t.lastAt = counts, now
+}
+
+// Rates returns the samples per second ingested for every metric between
+// the last two snapshots.
+func (t *IngestRateTracker) Rates() map[string]float64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.rates
+}
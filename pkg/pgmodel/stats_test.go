@@ -0,0 +1,149 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStatsReaderMetricStats(t *testing.T) {
+	testCases := []struct {
+		name         string
+		queryResults []rowResults
+		queryErr     map[int]error
+		expected     []MetricStats
+	}{
+		{
+			name:     "No metrics",
+			expected: []MetricStats{},
+		},
+		{
+			name: "Two metrics",
+			queryResults: []rowResults{
+				{
+					{"first", int64(10), int64(8192)},
+					{"second", int64(1), int64(0)},
+				},
+			},
+			expected: []MetricStats{
+				{Name: "first", SeriesCount: 10, TotalBytes: 8192},
+				{Name: "second", SeriesCount: 1, TotalBytes: 0},
+			},
+		},
+		{
+			name:     "Query error",
+			queryErr: map[int]error{0: fmt.Errorf("some error")},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &mockPGXConn{
+				QueryResults: c.queryResults,
+				QueryErr:     c.queryErr,
+			}
+			r := &StatsReader{conn: mock}
+
+			stats, err := r.MetricStats()
+
+			if c.queryErr != nil {
+				if err != c.queryErr[0] {
+					t.Errorf("unexpected error:\ngot\n%v\nwanted\n%v", err, c.queryErr[0])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(mock.QuerySQLs) != 1 || mock.QuerySQLs[0] != metricStatsSQL {
+				t.Errorf("unexpected query SQL: %v", mock.QuerySQLs)
+			}
+
+			if !reflect.DeepEqual(stats, c.expected) {
+				t.Errorf("unexpected stats:\ngot\n%v\nwanted\n%v", stats, c.expected)
+			}
+		})
+	}
+}
+
+func TestStatsReaderSeriesCount(t *testing.T) {
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{{{int64(42)}}},
+	}
+	r := &StatsReader{conn: mock}
+
+	count, err := r.SeriesCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 42 {
+		t.Errorf("unexpected series count: got %d wanted 42", count)
+	}
+	if len(mock.QuerySQLs) != 1 || mock.QuerySQLs[0] != seriesCountSQL {
+		t.Errorf("unexpected query SQL: %v", mock.QuerySQLs)
+	}
+
+	mock = &mockPGXConn{QueryErr: map[int]error{0: fmt.Errorf("some error")}}
+	r = &StatsReader{conn: mock}
+	if _, err := r.SeriesCount(); err != mock.QueryErr[0] {
+		t.Errorf("unexpected error:\ngot\n%v\nwanted\n%v", err, mock.QueryErr[0])
+	}
+}
+
+func TestStatsReaderStorageStats(t *testing.T) {
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{
+			{
+				{"first", int64(10), int64(8192), int64(3), int64(2), int64(4000), int64(1000)},
+			},
+		},
+	}
+	r := &StatsReader{conn: mock}
+
+	stats, err := r.StorageStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.QuerySQLs) != 1 || mock.QuerySQLs[0] != storageStatsSQL {
+		t.Errorf("unexpected query SQL: %v", mock.QuerySQLs)
+	}
+	expected := []MetricStorageStats{{
+		MetricStats:            MetricStats{Name: "first", SeriesCount: 10, TotalBytes: 8192},
+		Chunks:                 3,
+		CompressedChunks:       2,
+		BeforeCompressionBytes: 4000,
+		AfterCompressionBytes:  1000,
+	}}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("unexpected stats:\ngot\n%v\nwanted\n%v", stats, expected)
+	}
+}
+
+func TestIngestRateTracker(t *testing.T) {
+	ingested := map[string]uint64{"first": 100}
+	tracker := NewIngestRateTracker(func() map[string]uint64 {
+		counts := make(map[string]uint64)
+		for k, v := range ingested {
+			counts[k] = v
+		}
+		return counts
+	})
+
+	start := time.Unix(1000, 0)
+	tracker.Observe(start)
+	if rates := tracker.Rates(); len(rates) != 0 {
+		t.Errorf("unexpected rates after a single snapshot: %v", rates)
+	}
+
+	ingested["first"] = 700
+	ingested["second"] = 60
+	tracker.Observe(start.Add(time.Minute))
+	if rates := tracker.Rates(); rates["first"] != 10 || rates["second"] != 1 {
+		t.Errorf("unexpected rates: %v", rates)
+	}
+}
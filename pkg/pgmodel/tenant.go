@@ -0,0 +1,45 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"errors"
+)
+
+// setTenantSQL switches the transaction of a read to the role of the tenant,
+// and sets app.tenant for the row-level security policies to refer to with
+// current_setting('app.tenant'). Both are reset when the transaction ends.
+const setTenantSQL = "SELECT set_config('role', $1, true), set_config('app.tenant', $2, true)"
+
+// ErrTenantRequired is returned by the reads without a tenant when tenant
+// roles are enabled.
+var ErrTenantRequired = errors.New("reads require an authenticated tenant")
+
+type tenantKey struct{}
+
+// WithTenant returns a context whose reads run as the role of tenant, when
+// tenant roles are enabled.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom returns the tenant of ctx, empty if there is none.
+func TenantFrom(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// EnableTenantRoles runs the SELECTs of the reads as the role of the tenant
+// set with WithTenant, rolePrefix followed by the tenant, so that the
+// row-level security policies of the database isolate the tenants rather
+// than the queries of the connector. Reads without a tenant fail with
+// ErrTenantRequired.
+func (r *DBReader) EnableTenantRoles(rolePrefix string) {
+	if q, ok := r.db.(*pgxQuerier); ok {
+		q.tenantRoles = true
+		q.tenantRolePrefix = rolePrefix
+	}
+}
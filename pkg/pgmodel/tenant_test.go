@@ -0,0 +1,86 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/timescale/timescale-prometheus/pkg/prompb"
+)
+
+func TestTenantRoles(t *testing.T) {
+	crossMetric := &prompb.Query{
+		Matchers: []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: "job", Value: "api"}},
+	}
+	singleMetric := &prompb.Query{
+		Matchers: []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: MetricNameLabelName, Value: "up"}},
+	}
+	testCases := []struct {
+		name     string
+		query    *prompb.Query
+		ctx      context.Context
+		snapshot bool
+		isoLevel pgx.TxIsoLevel
+		err      error
+	}{
+		{
+			name:     "single metric query",
+			query:    singleMetric,
+			ctx:      WithTenant(context.Background(), "alice"),
+			isoLevel: pgx.ReadCommitted,
+		},
+		{
+			name:     "cross-metric query in a snapshot",
+			query:    crossMetric,
+			ctx:      WithTenant(context.Background(), "alice"),
+			snapshot: true,
+			isoLevel: pgx.RepeatableRead,
+		},
+		{
+			name:  "no tenant",
+			query: singleMetric,
+			ctx:   context.Background(),
+			err:   ErrTenantRequired,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			conn := &readTxPGXConn{mockPGXConn: &mockPGXConn{QueryNoRows: true}}
+			querier := pgxQuerier{
+				conn:             conn,
+				metricTableNames: &mockMetricCache{metricCache: map[string]string{"up": "up"}},
+				snapshotReads:    c.snapshot,
+				tenantRoles:      true,
+				tenantRolePrefix: "tenant_",
+			}
+			_, err := querier.Query(c.ctx, c.query)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("unexpected error: got %v wanted %v", err, c.err)
+				}
+				if len(conn.begun) != 0 {
+					t.Errorf("unexpected transactions: %v", conn.begun)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(conn.begun, []pgx.TxIsoLevel{c.isoLevel}) || conn.ended != 1 {
+				t.Errorf("unexpected transactions: %v begun and %d ended", conn.begun, conn.ended)
+			}
+			if !reflect.DeepEqual(conn.ExecSQLs, []string{setTenantSQL}) {
+				t.Fatalf("unexpected statements: %v", conn.ExecSQLs)
+			}
+			if expected := []interface{}{"tenant_alice", "alice"}; !reflect.DeepEqual(conn.ExecArgs[0], expected) {
+				t.Errorf("unexpected tenant arguments: got %v wanted %v", conn.ExecArgs[0], expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/timescale/timescale-prometheus/pkg/tracing"
+)
+
+// spans returns the distinct spans of the requests buffered, which the
+// spans of the batch link to.
+func (p *pendingBuffer) spans() []*tracing.Span {
+	spans := make([]*tracing.Span, 0, len(p.needsResponse))
+	seen := make(map[*tracing.Span]bool, len(p.needsResponse))
+	for _, t := range p.needsResponse {
+		if t.span == nil || seen[t.span] {
+			continue
+		}
+		seen[t.span] = true
+		spans = append(spans, t.span)
+	}
+	return spans
+}
+
+// copyStatement returns the statement run by CopyFrom into a metric table.
+func copyStatement(table string) string {
+	return "COPY " + pgx.Identifier{dataSchema, table}.Sanitize() +
+		" (" + strings.Join(copyColumns, ", ") + ") FROM STDIN BINARY"
+}
+
+// tracedRows ends the span of the query the rows are read from once they are
+// closed.
+type tracedRows struct {
+	pgx.Rows
+	span *tracing.Span
+}
+
+func (r *tracedRows) Close() {
+	r.Rows.Close()
+	r.span.SetError(r.Rows.Err())
+	r.span.End()
+}
+
+// query runs sql in a span annotated with the statement, ended once the
+// returned rows are closed.
+func (q *pgxQuerier) query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	_, span := tracing.Start(ctx, "pgxQuerier.query", tracing.KindClient,
+		tracing.String("db.system", "postgresql"),
+		tracing.String("db.statement", sql),
+	)
+	rows, err := q.limitedQuery(ctx, sql, args...)
+	if span == nil {
+		return rows, err
+	}
+	span.SetError(err)
+	if rows == nil {
+		span.End()
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, err
+}
@@ -0,0 +1,26 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/timescale/timescale-prometheus/pkg/tracing"
+)
+
+func TestPendingBufferSpans(t *testing.T) {
+	a, b := &tracing.Span{}, &tracing.Span{}
+	pending := &pendingBuffer{needsResponse: []insertDataTask{{span: a}, {}, {span: b}, {span: a}}}
+	if spans := pending.spans(); !reflect.DeepEqual(spans, []*tracing.Span{a, b}) {
+		t.Errorf("unexpected spans: %v", spans)
+	}
+}
+
+func TestCopyStatement(t *testing.T) {
+	expected := `COPY "prom_data"."cpu usage" (time, value, series_id) FROM STDIN BINARY`
+	if got := copyStatement("cpu usage"); got != expected {
+		t.Errorf("unexpected statement: got %s wanted %s", got, expected)
+	}
+}
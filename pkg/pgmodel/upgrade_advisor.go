@@ -0,0 +1,295 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4/source/httpfs"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/timescale/timescale-prometheus/pkg/pgmodel/migrations"
+)
+
+const (
+	upgradeEnvironmentSQL = `SELECT
+		current_setting('server_version'),
+		COALESCE((SELECT extversion FROM pg_extension WHERE extname = 'timescaledb'), ''),
+		COALESCE((SELECT extversion FROM pg_extension WHERE extname = 'timescale_prometheus_extra'), ''),
+		to_regclass('public.prom_schema_migrations') IS NOT NULL,
+		to_regclass('` + catalogSchema + `.metric') IS NOT NULL,
+		to_regclass('` + catalogSchema + `.connector_instance') IS NOT NULL`
+	upgradeConnectorVersionSQL = "SELECT COALESCE((SELECT value FROM _timescaledb_catalog.metadata WHERE key = 'version'), '')"
+	upgradeCatalogBytesSQL     = `SELECT COALESCE(sum(pg_total_relation_size(c.oid)), 0)::BIGINT
+	FROM pg_class c
+	INNER JOIN pg_namespace n ON (n.oid = c.relnamespace)
+	WHERE n.nspname IN ('` + catalogSchema + `', '` + dataSeriesSchema + `') AND c.relkind = 'r'`
+	upgradeDataBytesSQL = `SELECT COALESCE(sum((SELECT total_bytes FROM public.hypertable_relation_size(format('%I.%I', '` + dataSchema + `', m.table_name)::regclass))), 0)::BIGINT
+	FROM ` + catalogSchema + `.metric m
+	WHERE to_regclass(format('%I.%I', '` + dataSchema + `', m.table_name)) IS NOT NULL`
+	// Connectors refresh their heartbeat every few seconds.
+	upgradeActiveConnectorsSQL = "SELECT count(*) FROM " + catalogSchema + ".connector_instance WHERE last_heartbeat > now() - interval '1 minute'"
+
+	// The duration estimate assumes every migration takes at least
+	// migrationBaseDuration, plus the time to rewrite the relations it
+	// changes at rewriteBytesPerSecond.
+	migrationBaseDuration = time.Second
+	rewriteBytesPerSecond = 50 << 20
+)
+
+// rewriteScope is what a migration rewrites, nothing by default.
+type rewriteScope int
+
+const (
+	rewritesCatalog rewriteScope = iota + 1
+	rewritesData
+)
+
+// migrationNote documents what a migration changes, for the upgrade
+// advisor. Every migration needs one.
+type migrationNote struct {
+	summary  string
+	breaking []string
+	rewrites rewriteScope
+}
+
+var migrationNotes = map[uint]migrationNote{
+	1: {
+		summary: "Creates the Prometheus schemas, the catalog tables and the SQL API.",
+	},
+	2: {
+		summary: "Adds the connector_instance table where running connectors register themselves.",
+	},
+	3: {
+		summary: "Adds get_series_ids_for_key_value_arrays, looking up the series ids of a whole batch in one round trip.",
+		breaking: []string{
+			"Connectors from this version onwards fail to insert new series into a schema without this migration, so migrate before rolling out new connectors.",
+		},
+	},
+	4: {
+		summary: "Adds the ha_lease table and update_ha_lease, electing the replica of each HA Prometheus cluster whose samples are stored.",
+	},
+	5: {
+		summary: "Adds retention overrides for the series carrying a label, applied by the drop_chunks procedure.",
+		breaking: []string{
+			"drop_chunks keeps the chunks of a metric as long as a label override of its series requires, and deletes the expired samples of the other series from them.",
+		},
+	},
+	6: {
+		summary: "Adds lifecycle policies rolling up the samples of a metric into the prom_rollup schema, kept longer than the raw samples.",
+	},
+	7: {
+		summary: "Adds the series_liveness table, recording when each series went stale for connectors running with -stale-markers=liveness.",
+	},
+	8: {
+		summary: "Adds the event table recording the operational events of connectors running with -events-table.",
+	},
+	9: {
+		summary: "Adds the histogram hypertable storing the native histograms, dropped by the drop_chunks procedure after the default retention period.",
+		breaking: []string{
+			"Connectors from this version onwards fail to write native histograms into a schema without this migration.",
+		},
+	},
+	10: {
+		summary: "Adds the triggers notifying the connectors of the metrics created, changed or dropped and of the series deleted, so that they invalidate their caches.",
+	},
+	11: {
+		summary: "Adds the series_tombstone table, recording the series deleted with -delete-series-grace-period until their samples are purged.",
+		breaking: []string{
+			"Connectors from this version onwards fail to read from a schema without this migration, since the reads skip the samples of the tombstones.",
+		},
+	},
+}
+
+// UpgradeAdvice describes the installed schema and what migrating it to the
+// version embedded in this binary involves.
+type UpgradeAdvice struct {
+	PostgresVersion       string
+	TimescaleDBVersion    string
+	ExtraExtensionVersion string
+	ConnectorVersion      string
+
+	Installed        bool
+	InstalledVersion int64
+	Dirty            bool
+	TargetVersion    uint
+
+	CatalogBytes     int64
+	DataBytes        int64
+	ActiveConnectors int64
+
+	Pending           []PendingMigration
+	EstimatedDuration time.Duration
+	Warnings          []string
+}
+
+// PendingMigration is a migration which is not applied yet.
+type PendingMigration struct {
+	Version           uint
+	Name              string
+	Summary           string
+	BreakingChanges   []string
+	EstimatedDuration time.Duration
+}
+
+// UpgradeAdvisor inspects the database ahead of an upgrade.
+type UpgradeAdvisor struct {
+	conn pgxConn
+}
+
+// NewUpgradeAdvisor returns a new UpgradeAdvisor using the given connection pool.
+func NewUpgradeAdvisor(c *pgxpool.Pool) *UpgradeAdvisor {
+	return &UpgradeAdvisor{
+		conn: &pgxConnImpl{
+			conn: c,
+		},
+	}
+}
+
+// Advise inspects the database and reports the migrations to apply.
+func (a *UpgradeAdvisor) Advise() (*UpgradeAdvice, error) {
+	advice := &UpgradeAdvice{}
+
+	var migrationsTable, catalog, connectorTable bool
+	err := a.queryRow(upgradeEnvironmentSQL, nil, &advice.PostgresVersion, &advice.TimescaleDBVersion,
+		&advice.ExtraExtensionVersion, &migrationsTable, &catalog, &connectorTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if advice.TimescaleDBVersion != "" {
+		if err := a.queryRow(upgradeConnectorVersionSQL, nil, &advice.ConnectorVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	if migrationsTable {
+		err := a.queryRow(selectMigrationVersionSQL, nil, &advice.InstalledVersion, &advice.Dirty)
+		if err != nil && err != errNoRows {
+			return nil, err
+		}
+		advice.Installed = err == nil
+	}
+
+	if catalog {
+		if err := a.queryRow(upgradeCatalogBytesSQL, nil, &advice.CatalogBytes); err != nil {
+			return nil, err
+		}
+		if err := a.queryRow(upgradeDataBytesSQL, nil, &advice.DataBytes); err != nil {
+			return nil, err
+		}
+	}
+	if connectorTable {
+		if err := a.queryRow(upgradeActiveConnectorsSQL, nil, &advice.ActiveConnectors); err != nil {
+			return nil, err
+		}
+	}
+
+	migrations, err := embeddedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	advise(advice, migrations)
+	return advice, nil
+}
+
+var errNoRows = errors.New("no rows")
+
+func (a *UpgradeAdvisor) queryRow(sql string, args []interface{}, dest ...interface{}) error {
+	rows, err := a.conn.Query(context.Background(), sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return errNoRows
+	}
+	return rows.Scan(dest...)
+}
+
+type embeddedMigration struct {
+	version uint
+	name    string
+}
+
+// embeddedMigrations lists the migrations embedded in the binary, in order.
+func embeddedMigrations() ([]embeddedMigration, error) {
+	src, err := httpfs.New(migrations.SqlFiles, "/")
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	res := make([]embeddedMigration, 0)
+	version, err := src.First()
+	for err == nil {
+		r, name, readErr := src.ReadUp(version)
+		if readErr != nil {
+			return nil, readErr
+		}
+		r.Close()
+		res = append(res, embeddedMigration{version: version, name: name})
+
+		version, err = src.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return res, nil
+}
+
+func rewriteDuration(bytes int64) time.Duration {
+	return time.Duration(float64(bytes) / rewriteBytesPerSecond * float64(time.Second))
+}
+
+// advise fills in the pending migrations, their estimated duration and the
+// warnings, from the inspected state of the database.
+func advise(advice *UpgradeAdvice, embedded []embeddedMigration) {
+	for _, m := range embedded {
+		if m.version > advice.TargetVersion {
+			advice.TargetVersion = m.version
+		}
+		if advice.Installed && int64(m.version) <= advice.InstalledVersion && !(advice.Dirty && int64(m.version) == advice.InstalledVersion) {
+			continue
+		}
+
+		note := migrationNotes[m.version]
+		pending := PendingMigration{
+			Version:           m.version,
+			Name:              m.name,
+			Summary:           note.summary,
+			BreakingChanges:   note.breaking,
+			EstimatedDuration: migrationBaseDuration,
+		}
+		switch note.rewrites {
+		case rewritesCatalog:
+			pending.EstimatedDuration += rewriteDuration(advice.CatalogBytes)
+		case rewritesData:
+			pending.EstimatedDuration += rewriteDuration(advice.DataBytes)
+		}
+		advice.EstimatedDuration += pending.EstimatedDuration
+		advice.Pending = append(advice.Pending, pending)
+	}
+
+	if advice.TimescaleDBVersion == "" {
+		advice.Warnings = append(advice.Warnings, "The timescaledb extension is not installed. Migrating installs it, which requires superuser privileges.")
+	}
+	if advice.ExtraExtensionVersion == "" {
+		advice.Warnings = append(advice.Warnings, "The timescale_prometheus_extra extension is not installed. Queries will be slower without it.")
+	}
+	if advice.Dirty {
+		advice.Warnings = append(advice.Warnings, fmt.Sprintf("Migration %d did not complete. Inspect the database and repair it with -migrate-repair before upgrading.", advice.InstalledVersion))
+	}
+	if advice.Installed && advice.InstalledVersion > int64(advice.TargetVersion) {
+		advice.Warnings = append(advice.Warnings, fmt.Sprintf("The schema version %d is newer than the version %d of this binary. Downgrading the connector is not supported.", advice.InstalledVersion, advice.TargetVersion))
+	}
+	if advice.ActiveConnectors > 0 && len(advice.Pending) > 0 {
+		advice.Warnings = append(advice.Warnings, fmt.Sprintf("%d connectors are running. Reads failing during the migration get 503 responses until it completes.", advice.ActiveConnectors))
+	}
+}
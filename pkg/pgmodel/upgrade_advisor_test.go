@@ -0,0 +1,150 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package pgmodel
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrationNotes(t *testing.T) {
+	embedded, err := embeddedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embedded) != len(migrationNotes) {
+		t.Errorf("unexpected number of migration notes: got %d wanted %d", len(migrationNotes), len(embedded))
+	}
+	for _, m := range embedded {
+		if migrationNotes[m.version].summary == "" {
+			t.Errorf("migration %d (%s) has no note", m.version, m.name)
+		}
+	}
+}
+
+func TestUpgradeAdvisorAdvise(t *testing.T) {
+	mock := &mockPGXConn{
+		QueryResults: []rowResults{
+			{{"12.3", "1.7.1", "", true, true, true}},
+			{{"0.1.0-beta.1"}},
+			{{int64(2), false}},
+			{{int64(10 << 20)}},
+			{{int64(1 << 30)}},
+			{{int64(2)}},
+		},
+	}
+	advisor := &UpgradeAdvisor{conn: mock}
+
+	advice, err := advisor.Advise()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if advice.PostgresVersion != "12.3" || advice.TimescaleDBVersion != "1.7.1" || advice.ConnectorVersion != "0.1.0-beta.1" {
+		t.Errorf("unexpected versions: %+v", advice)
+	}
+	if !advice.Installed || advice.InstalledVersion != 2 || advice.Dirty {
+		t.Errorf("unexpected installed version: %+v", advice)
+	}
+	if advice.CatalogBytes != 10<<20 || advice.DataBytes != 1<<30 || advice.ActiveConnectors != 2 {
+		t.Errorf("unexpected sizes: %+v", advice)
+	}
+	if len(advice.Pending) != 9 || advice.Pending[0].Version != 3 || advice.Pending[0].Name != "bulk_series_ids" {
+		t.Fatalf("unexpected pending migrations: %+v", advice.Pending)
+	}
+	if len(advice.Pending[0].BreakingChanges) == 0 {
+		t.Error("missing breaking changes of migration 3")
+	}
+	if advice.EstimatedDuration != 9*migrationBaseDuration {
+		t.Errorf("unexpected estimated duration: %v", advice.EstimatedDuration)
+	}
+	if len(mock.QuerySQLs) != 6 {
+		t.Errorf("unexpected number of queries: %d", len(mock.QuerySQLs))
+	}
+}
+
+func TestAdvise(t *testing.T) {
+	embedded := []embeddedMigration{{1, "a"}, {2, "b"}, {3, "c"}}
+	testCases := []struct {
+		name     string
+		advice   UpgradeAdvice
+		pending  []uint
+		warnings []string
+	}{
+		{
+			name:     "fresh database",
+			advice:   UpgradeAdvice{},
+			pending:  []uint{1, 2, 3},
+			warnings: []string{"timescaledb extension is not installed", "timescale_prometheus_extra extension is not installed"},
+		},
+		{
+			name:    "up to date",
+			advice:  UpgradeAdvice{TimescaleDBVersion: "1.7.1", ExtraExtensionVersion: "0.1", Installed: true, InstalledVersion: 3, ActiveConnectors: 1},
+			pending: nil,
+		},
+		{
+			name:     "dirty",
+			advice:   UpgradeAdvice{TimescaleDBVersion: "1.7.1", ExtraExtensionVersion: "0.1", Installed: true, InstalledVersion: 2, Dirty: true},
+			pending:  []uint{2, 3},
+			warnings: []string{"Migration 2 did not complete"},
+		},
+		{
+			name:     "newer schema",
+			advice:   UpgradeAdvice{TimescaleDBVersion: "1.7.1", ExtraExtensionVersion: "0.1", Installed: true, InstalledVersion: 4},
+			warnings: []string{"newer than the version 3 of this binary"},
+		},
+		{
+			name:     "running connectors",
+			advice:   UpgradeAdvice{TimescaleDBVersion: "1.7.1", ExtraExtensionVersion: "0.1", Installed: true, InstalledVersion: 2, ActiveConnectors: 3},
+			pending:  []uint{3},
+			warnings: []string{"3 connectors are running"},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			advice := c.advice
+			advise(&advice, embedded)
+
+			if advice.TargetVersion != 3 {
+				t.Errorf("unexpected target version: %d", advice.TargetVersion)
+			}
+			pending := make([]uint, 0)
+			for _, p := range advice.Pending {
+				pending = append(pending, p.Version)
+			}
+			if len(pending) != len(c.pending) {
+				t.Fatalf("unexpected pending migrations: got %v wanted %v", pending, c.pending)
+			}
+			for i := range pending {
+				if pending[i] != c.pending[i] {
+					t.Errorf("unexpected pending migrations: got %v wanted %v", pending, c.pending)
+				}
+			}
+			if advice.EstimatedDuration != time.Duration(len(c.pending))*migrationBaseDuration {
+				t.Errorf("unexpected estimated duration: %v", advice.EstimatedDuration)
+			}
+
+			if len(advice.Warnings) != len(c.warnings) {
+				t.Fatalf("unexpected warnings: got %q wanted %q", advice.Warnings, c.warnings)
+			}
+			for i, w := range c.warnings {
+				if !strings.Contains(advice.Warnings[i], w) {
+					t.Errorf("unexpected warning: got %q wanted %q", advice.Warnings[i], w)
+				}
+			}
+		})
+	}
+}
+
+func TestRewriteDuration(t *testing.T) {
+	if d := rewriteDuration(rewriteBytesPerSecond * 60); d != time.Minute {
+		t.Errorf("unexpected rewrite duration: got %v wanted 1m", d)
+	}
+	// A terabyte of data must not overflow.
+	if d := rewriteDuration(1 << 40); d <= 0 {
+		t.Errorf("unexpected rewrite duration of a terabyte: %v", d)
+	}
+}
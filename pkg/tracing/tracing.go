@@ -0,0 +1,277 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+// Package tracing records OpenTelemetry spans and exports them over OTLP/HTTP.
+// Tracing is disabled until Init is called with an endpoint: Start then
+// returns nil spans, on which every method is a no-op.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/timescale/timescale-prometheus/pkg/log"
+)
+
+// DefaultServiceName is the service.name of the exported spans.
+const DefaultServiceName = "timescale-prometheus"
+
+// shutdownTimeout bounds the export of the pending spans on Shutdown.
+const shutdownTimeout = 5 * time.Second
+
+// SpanKind tells how a span relates to other services.
+type SpanKind = trace.SpanKind
+
+// Span kinds.
+const (
+	KindInternal = trace.SpanKindInternal
+	KindServer   = trace.SpanKindServer
+	KindClient   = trace.SpanKindClient
+)
+
+// Config configures the export of the spans.
+type Config struct {
+	// Endpoint is the base URL of the OTLP/HTTP receiver, the spans being
+	// sent to its /v1/traces path. Empty disables tracing.
+	Endpoint string
+	// ServiceName is the service.name resource attribute.
+	ServiceName string
+	// SampleRatio is the fraction of the traces started by the connector
+	// that are recorded. Traces started by a caller follow its decision.
+	SampleRatio float64
+}
+
+// propagator reads the W3C traceparent header of the requests.
+var propagator = propagation.TraceContext{}
+
+var (
+	mu       sync.RWMutex
+	provider *sdktrace.TracerProvider
+)
+
+// Init starts exporting spans as configured.
+func Init(cfg Config) error {
+	if cfg.Endpoint == "" {
+		return nil
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return fmt.Errorf("invalid sample ratio %v, expected a value between 0 and 1", cfg.SampleRatio)
+	}
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(strings.TrimSuffix(cfg.Endpoint, "/")+"/v1/traces"))
+	if err != nil {
+		return err
+	}
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Warn("msg", "Exporting spans failed", "err", err)
+	}))
+	setProvider(newProvider(cfg, sdktrace.WithBatcher(exporter)))
+	return nil
+}
+
+// newProvider returns a provider of the tracer of the connector, sampling and
+// naming the spans as configured and exporting them with export.
+func newProvider(cfg Config, export sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = DefaultServiceName
+	}
+	return sdktrace.NewTracerProvider(
+		export,
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName))),
+	)
+}
+
+func setProvider(p *sdktrace.TracerProvider) {
+	mu.Lock()
+	old := provider
+	provider = p
+	mu.Unlock()
+	if old != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := old.Shutdown(ctx); err != nil {
+			log.Warn("msg", "Exporting the pending spans failed", "err", err)
+		}
+	}
+}
+
+// Shutdown exports the pending spans and stops tracing.
+func Shutdown() {
+	setProvider(nil)
+}
+
+func tracer() trace.Tracer {
+	mu.RLock()
+	defer mu.RUnlock()
+	if provider == nil {
+		return nil
+	}
+	return provider.Tracer(DefaultServiceName)
+}
+
+// Attribute is a key-value pair annotating a span.
+type Attribute = attribute.KeyValue
+
+// String returns a string attribute.
+func String(key, value string) Attribute {
+	return attribute.String(key, value)
+}
+
+// Int returns an integer attribute.
+func Int(key string, value int64) Attribute {
+	return attribute.Int64(key, value)
+}
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attribute {
+	return attribute.Bool(key, value)
+}
+
+// Span is an operation of a trace, recorded by the OpenTelemetry SDK. A nil
+// Span records nothing.
+type Span struct {
+	span trace.Span
+}
+
+// FromContext returns the recorded span of ctx, if any.
+func FromContext(ctx context.Context) *Span {
+	s := trace.SpanFromContext(ctx)
+	if !s.IsRecording() {
+		return nil
+	}
+	return &Span{span: s}
+}
+
+// ContextWithSpan returns ctx carrying s, which spans started from it are
+// children of.
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	if s == nil {
+		return ctx
+	}
+	return trace.ContextWithSpan(ctx, s.span)
+}
+
+// Start starts a span, child of the span of ctx, and returns a context
+// carrying it. The span is nil if tracing is disabled or the trace is not
+// sampled.
+func Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	t := tracer()
+	if t == nil {
+		return ctx, nil
+	}
+	spanCtx, s := t.Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+	if !s.IsRecording() {
+		return ctx, nil
+	}
+	return spanCtx, &Span{span: s}
+}
+
+// StartLinked starts a span for an operation done on behalf of several
+// traces, such as a batch gathering the data of many requests. The span is a
+// child of the first sampled span and links to the others.
+func StartLinked(name string, kind SpanKind, parents []*Span, attrs ...Attribute) *Span {
+	var (
+		first *Span
+		links []trace.Link
+	)
+	for _, p := range parents {
+		switch {
+		case p == nil:
+		case first == nil:
+			first = p
+		default:
+			links = append(links, trace.Link{SpanContext: p.span.SpanContext()})
+		}
+	}
+	t := tracer()
+	if first == nil || t == nil {
+		return nil
+	}
+	_, s := t.Start(trace.ContextWithSpan(context.Background(), first.span), name,
+		trace.WithSpanKind(kind), trace.WithAttributes(attrs...), trace.WithLinks(links...))
+	if !s.IsRecording() {
+		return nil
+	}
+	return &Span{span: s}
+}
+
+// SetAttributes annotates the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(attrs...)
+}
+
+// SetError marks the span as failed with err, if not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End ends the span and queues it for export. Only the first call counts.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.span.End()
+}
+
+// Handler traces the requests served by h in a server span, continuing the
+// trace of the caller if it sent a traceparent header.
+func Handler(name string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := Start(ctx, name, KindServer,
+			String("http.method", r.Method),
+			String("http.target", r.URL.Path),
+		)
+		if span == nil {
+			h.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		defer span.End()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r.WithContext(ctx))
+		span.SetAttributes(Int("http.status_code", int64(sw.status)))
+		if sw.status >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("%s", http.StatusText(sw.status)))
+		}
+	})
+}
+
+// statusWriter records the status code of a response. It keeps http.Flusher
+// working for the streamed remote reads.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,147 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startTracing installs a provider exporting to memory, returning the
+// exporter and a function stopping tracing.
+func startTracing(t *testing.T, ratio float64) (*tracetest.InMemoryExporter, func()) {
+	exporter := tracetest.NewInMemoryExporter()
+	setProvider(newProvider(Config{ServiceName: "test", SampleRatio: ratio}, sdktrace.WithSyncer(exporter)))
+	return exporter, Shutdown
+}
+
+func TestExport(t *testing.T) {
+	exporter, stop := startTracing(t, 1)
+	defer stop()
+
+	ctx, root := Start(context.Background(), "root", KindServer, String("path", "/write"))
+	_, child := Start(ctx, "child", KindClient, Int("rows", 3), Bool("async", true))
+	child.SetError(fmt.Errorf("copy failed"))
+	child.End()
+	child.End()
+	root.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("unexpected spans: %+v", spans)
+	}
+	c, r := spans[0], spans[1]
+	if c.Name != "child" || r.Name != "root" || c.SpanContext.TraceID() != r.SpanContext.TraceID() ||
+		c.Parent.SpanID() != r.SpanContext.SpanID() || r.Parent.IsValid() {
+		t.Errorf("unexpected span hierarchy: %+v %+v", c, r)
+	}
+	if c.SpanKind != KindClient || c.Status.Code != codes.Error || c.Status.Description != "copy failed" || r.Status.Code != codes.Unset {
+		t.Errorf("unexpected span status: %+v %+v", c, r)
+	}
+	expected := []attribute.KeyValue{attribute.Int64("rows", 3), attribute.Bool("async", true)}
+	if !reflect.DeepEqual(c.Attributes, expected) {
+		t.Errorf("unexpected attributes: %+v", c.Attributes)
+	}
+	if name, _ := c.Resource.Set().Value("service.name"); name.AsString() != "test" {
+		t.Errorf("unexpected resource: %v", c.Resource)
+	}
+}
+
+func TestStartLinked(t *testing.T) {
+	exporter, stop := startTracing(t, 1)
+	defer stop()
+
+	_, a := Start(context.Background(), "a", KindInternal)
+	_, b := Start(context.Background(), "b", KindInternal)
+	if s := StartLinked("batch", KindClient, []*Span{nil, nil}); s != nil {
+		t.Error("linked span started without sampled parents")
+	}
+	batch := StartLinked("batch", KindClient, []*Span{nil, a, b})
+	batch.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("unexpected spans: %+v", spans)
+	}
+	s := spans[0]
+	if s.Parent.SpanID() != a.span.SpanContext().SpanID() || s.SpanContext.TraceID() != a.span.SpanContext().TraceID() {
+		t.Errorf("batch span is not a child of the first parent: %+v", s)
+	}
+	if len(s.Links) != 1 || !s.Links[0].SpanContext.Equal(b.span.SpanContext()) {
+		t.Errorf("unexpected links: %+v", s.Links)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	exporter, stop := startTracing(t, 0)
+	defer stop()
+
+	var spans, inner []*Span
+	handler := Handler("/write", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		spans = append(spans, FromContext(r.Context()))
+		_, s := Start(r.Context(), "insert", KindInternal)
+		s.End()
+		inner = append(inner, s)
+		http.Error(w, "failed", http.StatusInternalServerError)
+	}))
+	serve := func(traceparent string) {
+		req := httptest.NewRequest("POST", "/write", nil)
+		if traceparent != "" {
+			req.Header.Set("traceparent", traceparent)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// The connector samples none of its own traces, but follows the
+	// decision of the caller.
+	serve("")
+	serve("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	serve("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	serve("00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+
+	if len(spans) != 4 || spans[0] != nil || spans[1] != nil || spans[2] == nil || spans[3] != nil {
+		t.Fatalf("unexpected sampling: %v", spans)
+	}
+	if inner[0] != nil || inner[1] != nil || inner[2] == nil || inner[3] != nil {
+		t.Fatalf("unexpected sampling of the child spans: %v", inner)
+	}
+	got := exporter.GetSpans()
+	if len(got) != 2 {
+		t.Fatalf("unexpected spans: %+v", got)
+	}
+	s := got[1]
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	if s.SpanContext.TraceID() != traceID || s.Parent.SpanID() != spanID || !s.Parent.IsRemote() || s.SpanKind != KindServer {
+		t.Errorf("trace of the caller not continued: %+v", s)
+	}
+	if s.Status.Code != codes.Error || s.Attributes[2] != attribute.Int64("http.status_code", 500) {
+		t.Errorf("failed request not recorded: %+v", s)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	Shutdown()
+	ctx, s := Start(context.Background(), "span", KindInternal)
+	if s != nil || FromContext(ctx) != nil {
+		t.Error("span started with tracing disabled")
+	}
+	s.SetAttributes(String("key", "value"))
+	s.SetError(fmt.Errorf("error"))
+	s.End()
+
+	if err := Init(Config{Endpoint: "http://localhost:4318", SampleRatio: 2}); err == nil {
+		t.Error("invalid sample ratio accepted")
+	}
+}
@@ -15,6 +15,8 @@ var (
 	maskPasswordReplaceString1 = "password=$1'****'"
 	maskPasswordRegex2         = regexp.MustCompile(`password:(\s*?)([^\s]+?)( |$)`)
 	maskPasswordReplaceString2 = "password:$1****$3"
+	maskPasswordRegex3         = regexp.MustCompile(`://([^:/@\s]*):([^@\s]+)@`)
+	maskPasswordReplaceString3 = "://$1:****@"
 )
 
 //ThroughputCalc runs on scheduled interval to calculate the throughput per second and sends results to a channel
@@ -67,5 +69,6 @@ func (dt *ThroughputCalc) Start() {
 // MaskPassword is used to mask sensitive password data before outputing to persistent stream like logs.
 func MaskPassword(s string) string {
 	s = maskPasswordRegex1.ReplaceAllString(s, maskPasswordReplaceString1)
-	return maskPasswordRegex2.ReplaceAllString(s, maskPasswordReplaceString2)
+	s = maskPasswordRegex2.ReplaceAllString(s, maskPasswordReplaceString2)
+	return maskPasswordRegex3.ReplaceAllString(s, maskPasswordReplaceString3)
 }